@@ -0,0 +1,219 @@
+package paper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Alert kinds.
+const (
+	AlertSyncFailed    = "sync_failed"
+	AlertErrorRate     = "error_rate"
+	AlertTokenExpiring = "token_expiring" // or already expired or revoked
+)
+
+// Alert is something the operator of an unattended install should hear
+// about.
+type Alert struct {
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Alerter delivers alerts.
+type Alerter interface {
+	Alert(ctx context.Context, a *Alert) error
+}
+
+type AlerterFunc func(ctx context.Context, a *Alert) error
+
+func (f AlerterFunc) Alert(ctx context.Context, a *Alert) error {
+	return f(ctx, a)
+}
+
+// HTTPAlerter POSTs alerts to a URL as JSON. The body also has a text
+// field, so Slack and Mattermost incoming webhooks can take it as is.
+type HTTPAlerter struct {
+	URL    string
+	Header http.Header
+	HTTP   *http.Client
+}
+
+func (h *HTTPAlerter) Alert(ctx context.Context, a *Alert) error {
+	body, err := json.Marshal(struct {
+		*Alert
+		Text string `json:"text"`
+	}{a, "paper: " + a.Message})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return redactURLError(err)
+	}
+	for k, v := range h.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := h.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return redactURLError(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("POST %s: %s", redactURL(h.URL), resp.Status)
+	}
+	return nil
+}
+
+// CommandAlerter runs a command for each alert, passing it in the
+// PAPER_ALERT_KIND, PAPER_ALERT_MESSAGE and PAPER_ALERT_TIME environment
+// variables.
+type CommandAlerter struct {
+	Name   string
+	Args   []string
+	Dir    string
+	Env    []string // added to the current environment
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+func (c *CommandAlerter) Alert(ctx context.Context, a *Alert) error {
+	cmd := exec.CommandContext(ctx, c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	cmd.Env = append(os.Environ(), c.Env...)
+	cmd.Env = append(cmd.Env,
+		"PAPER_ALERT_KIND="+a.Kind,
+		"PAPER_ALERT_MESSAGE="+a.Message,
+		"PAPER_ALERT_TIME="+a.Time.Format(time.RFC3339),
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+	return nil
+}
+
+// Alerts decides when to alert. Call Check after every sync: it alerts
+// when the sync failed, when too many API requests failed since the last
+// check, and when the token is about to expire or has been rejected. An
+// alert isn't repeated within Repeat of the last one of its kind.
+type Alerts struct {
+	Alerters []Alerter
+
+	// Metrics, the Syncer's, provides error rates and rejected tokens.
+	Metrics *Metrics
+
+	// ErrorRate is the fraction of API requests, between 0 and 1, that
+	// may fail between checks before an alert; 0 disables the check. It
+	// only applies once there have been MinRequests, which defaults to 20.
+	ErrorRate   float64
+	MinRequests int64
+
+	// TokenExpires is when the API token expires, if it does. Alerts start
+	// TokenWarning beforehand, which defaults to 72h.
+	TokenExpires time.Time
+	TokenWarning time.Duration
+
+	Repeat time.Duration // defaults to 1h
+
+	mu       sync.Mutex
+	last     map[string]time.Time // by kind
+	requests int64                // Metrics counts at the last check
+	errors   int64
+	rejected int64
+}
+
+// Check sends the alerts due after a sync that returned err, which is nil
+// for a successful one. It returns the alerters' errors.
+func (a *Alerts) Check(ctx context.Context, err error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	var alerts []*Alert
+	if err != nil {
+		alerts = append(alerts, &Alert{Kind: AlertSyncFailed, Message: "sync failed: " + err.Error()})
+	}
+	if a.Metrics != nil {
+		snap := a.Metrics.Snapshot()
+		var total int64
+		for _, n := range snap.APIErrors {
+			total += n
+		}
+		requests, failed, rejected := snap.APIRequests-a.requests, total-a.errors, snap.APIErrors["auth"]-a.rejected
+		a.requests, a.errors, a.rejected = snap.APIRequests, total, snap.APIErrors["auth"]
+		if rejected > 0 {
+			alerts = append(alerts, &Alert{Kind: AlertTokenExpiring, Message: "Dropbox rejected the API token; it has expired or been revoked"})
+		}
+		min := a.MinRequests
+		if min == 0 {
+			min = 20
+		}
+		if a.ErrorRate > 0 && requests >= min && float64(failed) >= a.ErrorRate*float64(requests) {
+			alerts = append(alerts, &Alert{
+				Kind:    AlertErrorRate,
+				Message: fmt.Sprintf("%d of %d API requests failed (%.0f%%)", failed, requests, 100*float64(failed)/float64(requests)),
+			})
+		}
+	}
+	if !a.TokenExpires.IsZero() {
+		warning := a.TokenWarning
+		if warning == 0 {
+			warning = 72 * time.Hour
+		}
+		switch left := a.TokenExpires.Sub(now); {
+		case left <= 0:
+			alerts = append(alerts, &Alert{Kind: AlertTokenExpiring, Message: "the API token expired at " + a.TokenExpires.Format(time.RFC3339)})
+		case left <= warning:
+			alerts = append(alerts, &Alert{
+				Kind:    AlertTokenExpiring,
+				Message: fmt.Sprintf("the API token expires in %s, at %s", left.Round(time.Minute), a.TokenExpires.Format(time.RFC3339)),
+			})
+		}
+	}
+
+	var errs []error
+	for _, alert := range alerts {
+		if !a.due(alert.Kind, now) {
+			continue
+		}
+		alert.Time = now
+		for _, x := range a.Alerters {
+			if err := x.Alert(ctx, alert); err != nil {
+				errs = append(errs, fmt.Errorf("alert %s: %w", alert.Kind, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// due reports whether an alert of kind may be sent, and if so records it.
+func (a *Alerts) due(kind string, now time.Time) bool {
+	repeat := a.Repeat
+	if repeat == 0 {
+		repeat = time.Hour
+	}
+	if t, ok := a.last[kind]; ok && now.Sub(t) < repeat {
+		return false
+	}
+	if a.last == nil {
+		a.last = map[string]time.Time{}
+	}
+	a.last[kind] = now
+	return true
+}
@@ -0,0 +1,148 @@
+package paper
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// AliasesName is the file the Syncer keeps doc aliases in, relative to the
+// sync directory.
+const AliasesName = "aliases.json"
+
+// Aliases remembers every slug each doc has been published under, so links
+// to a renamed doc's old URLs can be redirected rather than break. Entries
+// are kept after docs are removed.
+type Aliases struct {
+	Docs map[DocID]*AliasEntry `json:"docs"`
+}
+
+type AliasEntry struct {
+	Slug     string   `json:"slug"`
+	Previous []string `json:"previous,omitempty"` // oldest first
+
+	// Renamed records when the doc stopped using each previous slug, so
+	// when docs have shared one, the latest to have it gets its redirect.
+	Renamed map[string]time.Time `json:"renamed,omitempty"`
+}
+
+func NewAliases() *Aliases {
+	return &Aliases{Docs: map[DocID]*AliasEntry{}}
+}
+
+// LoadAliases reads aliases from disk. A missing file yields no aliases.
+func LoadAliases(name string) (*Aliases, error) {
+	blob, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return NewAliases(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	a := NewAliases()
+	if err := json.Unmarshal(blob, a); err != nil {
+		return nil, err
+	}
+	if a.Docs == nil {
+		a.Docs = map[DocID]*AliasEntry{}
+	}
+	return a, nil
+}
+
+func (a *Aliases) Save(name string) error {
+	blob, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(name, append(blob, '\n'), 0644)
+}
+
+// Record notes a doc's current slug. If it changed, the old one becomes an
+// alias; a slug the doc had before and has again stops being one.
+func (a *Aliases) Record(id DocID, slug string) {
+	e, ok := a.Docs[id]
+	if !ok {
+		a.Docs[id] = &AliasEntry{Slug: slug}
+		return
+	}
+	if e.Slug == slug {
+		return
+	}
+	prev := e.Previous[:0]
+	for _, p := range e.Previous {
+		if p != slug {
+			prev = append(prev, p)
+		}
+	}
+	if e.Renamed == nil {
+		e.Renamed = map[string]time.Time{}
+	}
+	delete(e.Renamed, slug)
+	e.Renamed[e.Slug] = time.Now().UTC()
+	e.Previous = append(prev, e.Slug)
+	e.Slug = slug
+}
+
+// Update records the slug of every doc in the manifest.
+func (a *Aliases) Update(m *Manifest) {
+	for _, e := range m.Entries() {
+		a.Record(e.ID, e.Slug)
+	}
+}
+
+// Resolve returns the doc a slug belongs to, now or previously, and the
+// doc's current slug. A slug that's current for one doc wins over another
+// doc's old one.
+func (a *Aliases) Resolve(slug string) (id DocID, current string, ok bool) {
+	for id, e := range a.Docs {
+		if e.Slug == slug {
+			return id, e.Slug, true
+		}
+	}
+	if id, ok := a.Redirects()[slug]; ok {
+		return id, a.Docs[id].Slug, true
+	}
+	return "", "", false
+}
+
+// Redirects maps each previous slug that no doc has now to the ID of the
+// doc that had it. If several docs had it, the one renamed from it most
+// recently wins, then the lowest ID.
+func (a *Aliases) Redirects() map[string]DocID {
+	current := map[string]bool{}
+	for _, e := range a.Docs {
+		current[e.Slug] = true
+	}
+	r := map[string]DocID{}
+	for id, e := range a.Docs {
+		for _, p := range e.Previous {
+			if current[p] {
+				continue
+			}
+			if other, ok := r[p]; ok {
+				t, ot := e.Renamed[p], a.Docs[other].Renamed[p]
+				if t.Before(ot) || t.Equal(ot) && other < id {
+					continue
+				}
+			}
+			r[p] = id
+		}
+	}
+	return r
+}
+
+// aliasPermalinks maps the permalinks docs in m had under their previous
+// slugs to their current ones.
+func aliasPermalinks(a *Aliases, m *Manifest, permalink func(*ManifestEntry) string) map[string]string {
+	links := map[string]string{}
+	for slug, id := range a.Redirects() {
+		e, ok := m.Get(id)
+		if !ok {
+			continue
+		}
+		old := *e
+		old.Slug = slug
+		links[permalink(&old)] = permalink(e)
+	}
+	return links
+}
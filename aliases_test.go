@@ -0,0 +1,60 @@
+package paper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAliasesRedirectsLatestRename(t *testing.T) {
+	a := NewAliases()
+	a.Record("docA", "launch")
+	a.Record("docA", "launch-2019")
+	a.Record("docB", "launch")
+	a.Record("docB", "launch-2020")
+
+	for i := 0; i < 20; i++ {
+		if got := a.Redirects()["launch"]; got != "docB" {
+			t.Fatalf("Redirects()[launch] = %q, want docB", got)
+		}
+	}
+	if id, cur, ok := a.Resolve("launch"); !ok || id != "docB" || cur != "launch-2020" {
+		t.Errorf("Resolve(launch) = %q, %q, %v, want docB, launch-2020, true", id, cur, ok)
+	}
+}
+
+func TestAliasesRedirectsTie(t *testing.T) {
+	// Aliases saved before rename times were recorded have none; the
+	// lowest ID wins.
+	a := NewAliases()
+	for _, id := range []DocID{"docC", "docA", "docB"} {
+		a.Docs[id] = &AliasEntry{Slug: "new-" + string(id), Previous: []string{"old"}}
+	}
+	for i := 0; i < 20; i++ {
+		if got := a.Redirects()["old"]; got != "docA" {
+			t.Fatalf("Redirects()[old] = %q, want docA", got)
+		}
+	}
+
+	now := time.Now()
+	a.Docs["docC"].Renamed = map[string]time.Time{"old": now}
+	if got := a.Redirects()["old"]; got != "docC" {
+		t.Errorf("Redirects()[old] = %q, want docC", got)
+	}
+}
+
+func TestAliasesRecordReuse(t *testing.T) {
+	a := NewAliases()
+	a.Record("doc", "a")
+	a.Record("doc", "b")
+	a.Record("doc", "a")
+	e := a.Docs["doc"]
+	if len(e.Previous) != 1 || e.Previous[0] != "b" {
+		t.Errorf("Previous = %q, want [b]", e.Previous)
+	}
+	if _, ok := e.Renamed["a"]; ok {
+		t.Errorf("Renamed still has the current slug: %v", e.Renamed)
+	}
+	if _, ok := a.Redirects()["a"]; ok {
+		t.Errorf("current slug a is redirected")
+	}
+}
@@ -0,0 +1,59 @@
+package paper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dropbox apps are either full Dropbox apps or app folder apps, which only
+// see their own folder, /Apps/<app name>. The paper/docs endpoints, and so
+// Syncer, DocFS and the rest of the docs/list based API, need a full
+// Dropbox app. The files endpoints, used for filesystem-based Paper, work
+// with either; for an app folder app, set APIClient.AppFolder and paths are
+// taken relative to the app folder.
+
+// AppFolderError is returned, without calling the API, for endpoints that
+// an app folder app can't use.
+type AppFolderError struct {
+	Op string // endpoint route
+}
+
+func (e *AppFolderError) Error() string {
+	return fmt.Sprintf("paper: %s needs a full Dropbox app; app folder apps can only use the files, users and check endpoints", e.Op)
+}
+
+// checkAppType fails calls an app folder app can't make.
+func (c *APIClient) checkAppType(url string) error {
+	if c.AppFolder == "" {
+		return nil
+	}
+	i := strings.Index(url, "/2/")
+	if i < 0 {
+		return nil
+	}
+	op := url[i+len("/2/"):]
+	if strings.HasPrefix(op, "files/") || strings.HasPrefix(op, "users/") || strings.HasPrefix(op, "check/") {
+		return nil
+	}
+	return &AppFolderError{Op: op}
+}
+
+// path converts a Dropbox path to the form the files endpoints expect:
+// the root is "" rather than "/", and for an app folder app a full path
+// under /Apps/<AppFolder> is made relative to it. IDs ("id:...") and
+// revisions ("rev:...") pass through.
+func (c *APIClient) path(p string) string {
+	if strings.HasPrefix(p, "id:") || strings.HasPrefix(p, "rev:") || strings.HasPrefix(p, "ns:") {
+		return p
+	}
+	if p != "" && !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	if c.AppFolder != "" {
+		prefix := "/apps/" + strings.ToLower(c.AppFolder)
+		if lower := strings.ToLower(p); lower == prefix || strings.HasPrefix(lower, prefix+"/") {
+			p = p[len(prefix):]
+		}
+	}
+	return strings.TrimRight(p, "/")
+}
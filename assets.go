@@ -0,0 +1,187 @@
+package paper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Asset is an image or file referenced by a doc that has been stored locally.
+type Asset struct {
+	URL  string // original remote URL
+	Name string // content-addressed file name
+	Link string // link written into the doc in place of URL
+	MIME string
+	Size int64
+}
+
+// AssetRewriter downloads the images and files a doc references, stores
+// them under Dir by content hash and rewrites the doc to point at the local
+// copies. Dropbox CDN links expire, so exported docs need their own copies.
+type AssetRewriter struct {
+	Dir  string       // directory assets are written to
+	Base string       // prefix for rewritten links, e.g. "assets/"
+	HTTP *http.Client // defaults to http.DefaultClient
+
+	// Match reports whether a URL should be downloaded. The default matches
+	// Dropbox Paper attachment and user content hosts.
+	Match func(*url.URL) bool
+
+	// Store uploads assets somewhere other than Dir, such as a bucket behind
+	// a CDN, and links to them there. Dir and Base are ignored when set.
+	Store BlobStore
+
+	// Images, when set, optimizes images before they're stored.
+	Images *ImageOptimizer
+}
+
+var assetURL = regexp.MustCompile(`https?://[^\s"'<>()\[\]]+`)
+
+func IsDropboxAsset(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "paper-attachments.dropbox.com":
+		return true
+	case host == "dropboxusercontent.com", strings.HasSuffix(host, ".dropboxusercontent.com"):
+		return true
+	}
+	return false
+}
+
+func (a *AssetRewriter) Rewrite(ctx context.Context, doc *Doc) error {
+	match := a.Match
+	if match == nil {
+		match = IsDropboxAsset
+	}
+	links := map[string]string{} // by raw URL, "" for those left alone
+	for _, raw := range assetURL.FindAllString(string(doc.Content), -1) {
+		if _, ok := links[raw]; ok {
+			continue
+		}
+		links[raw] = ""
+		u, err := url.Parse(html.UnescapeString(raw))
+		if err != nil || !match(u) {
+			continue
+		}
+		asset, err := a.fetch(ctx, u)
+		if err != nil {
+			return fmt.Errorf("asset %s: %w", u, err)
+		}
+		asset.URL = raw
+		links[raw] = asset.Link
+		doc.Assets = append(doc.Assets, *asset)
+	}
+	// Whole matches only, so a URL that extends another keeps its own link.
+	doc.Content = assetURL.ReplaceAllFunc(doc.Content, func(raw []byte) []byte {
+		if link := links[string(raw)]; link != "" {
+			return []byte(link)
+		}
+		return raw
+	})
+	return nil
+}
+
+func (a *AssetRewriter) fetch(ctx context.Context, u *url.URL) (*Asset, error) {
+	client := a.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, _ := http.NewRequest("GET", u.String(), nil)
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = cancelableBody(ctx, resp.Body)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	blob, err := readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	ctype := resp.Header.Get("Content-Type")
+	ext := assetExt(u, ctype)
+	if a.Images != nil {
+		out, otype, err := a.Images.Optimize(blob)
+		if err != nil {
+			return nil, err
+		}
+		if otype != "" {
+			blob, ctype, ext = out, otype, imageExts[otype]
+		}
+	}
+	sum := sha256.Sum256(blob)
+	name := hex.EncodeToString(sum[:]) + ext
+	store := a.Store
+	if store == nil {
+		store = &LocalStore{Dir: a.Dir, Base: a.Base}
+	}
+	link, err := store.Put(ctx, name, blob, ctype)
+	if err != nil {
+		return nil, err
+	}
+	return &Asset{
+		Name: name,
+		Link: link,
+		MIME: ctype,
+		Size: int64(len(blob)),
+	}, nil
+}
+
+func assetExt(u *url.URL, ctype string) string {
+	if ext := strings.ToLower(path.Ext(u.Path)); len(ext) > 1 && len(ext) <= 6 {
+		return ext
+	}
+	if mt, _, err := mime.ParseMediaType(ctype); err == nil {
+		if exts, _ := mime.ExtensionsByType(mt); len(exts) > 0 {
+			return exts[0]
+		}
+	}
+	return ""
+}
+
+// writeFileAtomic writes data to a temporary file next to name and renames
+// it into place with permissions perm, so readers never observe a partially
+// written file.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	// Without a sync, a crash soon after the rename can leave an empty file.
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	// CreateTemp makes the file readable by its owner alone.
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,150 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Audit rules, as reported in AuditFinding.Rule.
+const (
+	AuditPublicLink      = "public_link"       // anyone with the link can open the doc
+	AuditExternalShare   = "external_share"    // shared with someone outside the team
+	AuditSensitiveInTeam = "sensitive_in_team" // sensitive title in a team-visible folder
+)
+
+// Audit checks every doc's sharing for risky configurations, for security
+// reviews.
+type Audit struct {
+	Client *APIClient
+
+	// TeamDomains are the email domains of the team, e.g. "example.com".
+	// Invitees at other domains count as external; users are judged by
+	// whether Dropbox reports them as on the same team.
+	TeamDomains []string
+
+	// Sensitive matches doc and folder titles that shouldn't be visible to
+	// the whole team, e.g. `(?i)salary|layoff|password`.
+	Sensitive []*regexp.Regexp
+}
+
+type AuditFinding struct {
+	Rule   string `json:"rule"`
+	DocID  DocID  `json:"doc_id"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+var auditHeader = []string{"rule", "doc_id", "title", "detail"}
+
+// Run writes a finding per risk found, as each doc is checked.
+func (a *Audit) Run(ctx context.Context, w io.Writer, format InventoryFormat) error {
+	write, done, err := rowWriter(w, format, auditHeader)
+	if err != nil {
+		return err
+	}
+	ids, err := ListAllDocIDs(ctx, a.Client, &ListPaperDocsArgs{})
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		findings, err := a.check(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, f := range findings {
+			if err := write(f, []string{f.Rule, string(f.DocID), f.Title, f.Detail}); err != nil {
+				return err
+			}
+		}
+	}
+	return done()
+}
+
+func (a *Audit) check(ctx context.Context, id DocID) ([]*AuditFinding, error) {
+	c := a.Client
+	res, err := c.Metadata(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("metadata %s: %w", id, err)
+	}
+	var findings []*AuditFinding
+	flag := func(rule, detail string) {
+		findings = append(findings, &AuditFinding{Rule: rule, DocID: id, Title: res.Title, Detail: detail})
+	}
+
+	policy, err := c.GetSharingPolicy(ctx, &RefPaperDoc{DocID: id})
+	if err != nil {
+		return nil, fmt.Errorf("sharing policy %s: %w", id, err)
+	}
+	switch policy.PublicSharingPolicy {
+	case SharingPublicPolicyEdit, SharingPublicPolicyViewAndComment:
+		flag(AuditPublicLink, string(policy.PublicSharingPolicy))
+	}
+
+	users, err := c.ListDocUsers(ctx, &ListUsersOnPaperDocArgs{DocID: id, Limit: 1000})
+	for ; err == nil; users, err = c.ListDocUsersContinue(ctx, &ListUsersOnPaperDocContinueArgs{DocID: id, Cursor: users.Cursor.Value}) {
+		for _, u := range users.Users {
+			if !u.User.SameTeam {
+				flag(AuditExternalShare, u.User.Email+" ("+string(u.PermissionLevel)+")")
+			}
+		}
+		for _, i := range users.Invitees {
+			if !a.internal(i.Invitee.Email) {
+				flag(AuditExternalShare, i.Invitee.Email+" (invited, "+string(i.PermissionLevel)+")")
+			}
+		}
+		if !users.HasMore {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("doc users %s: %w", id, err)
+	}
+
+	if len(a.Sensitive) > 0 {
+		info, err := c.GetDocFolderInfo(ctx, &RefPaperDoc{DocID: id})
+		if err != nil {
+			return nil, fmt.Errorf("folder info %s: %w", id, err)
+		}
+		if info.FolderSharingPolicyType == FolderSharingPolicyTeam && len(info.Folders) > 0 {
+			var path []string
+			for _, f := range info.Folders {
+				path = append(path, f.Name)
+			}
+			if re := a.sensitive(append([]string{res.Title}, path...)); re != nil {
+				flag(AuditSensitiveInTeam, fmt.Sprintf("in %s, matches %s", strings.Join(path, "/"), re))
+			}
+		}
+	}
+	return findings, nil
+}
+
+// internal reports whether an email address is at one of the team's
+// domains.
+func (a *Audit) internal(email string) bool {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, d := range a.TeamDomains {
+		if strings.EqualFold(domain, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitive returns the first pattern matching any of names.
+func (a *Audit) sensitive(names []string) *regexp.Regexp {
+	for _, re := range a.Sensitive {
+		for _, n := range names {
+			if re.MatchString(n) {
+				return re
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package paper
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BlobStore stores assets, returning the URL each is served at. Names are
+// content-addressed, so storing one twice is harmless.
+type BlobStore interface {
+	Put(ctx context.Context, name string, data []byte, contentType string) (link string, err error)
+}
+
+// LocalStore writes assets to a directory that's published with the site.
+type LocalStore struct {
+	Dir  string
+	Base string // prefix for links, e.g. "/assets/"
+}
+
+func (s *LocalStore) Put(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	if err := writeFileAtomic(filepath.Join(s.Dir, name), data, 0644); err != nil {
+		return "", err
+	}
+	return s.Base + name, nil
+}
+
+// S3Store uploads assets to an S3 bucket, or any service with S3's API, and
+// links to them at PublicURL, typically a CDN in front of the bucket.
+// Requests are signed with AWS Signature Version 4.
+type S3Store struct {
+	Bucket string
+	Region string
+	Prefix string // key prefix, e.g. "assets/"
+
+	// Endpoint overrides the AWS endpoint for S3-compatible services, e.g.
+	// "https://<account>.r2.cloudflarestorage.com". Requests to it use
+	// path-style URLs.
+	Endpoint string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// PublicURL is the base URL objects are served from. It defaults to the
+	// bucket's own URL.
+	PublicURL string
+
+	HTTP *http.Client
+}
+
+func (s *S3Store) objectURL(key string) string {
+	escaped := (&url.URL{Path: "/" + key}).EscapedPath()
+	if s.Endpoint != "" {
+		return strings.TrimRight(s.Endpoint, "/") + "/" + s.Bucket + escaped
+	}
+	return "https://" + s.Bucket + ".s3." + s.Region + ".amazonaws.com" + escaped
+}
+
+func (s *S3Store) Put(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	key := s.Prefix + name
+	req, err := http.NewRequestWithContext(ctx, "PUT", s.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	sum := sha256.Sum256(data)
+	signV4(req, hex.EncodeToString(sum[:]), s.Region, "s3", s.AccessKeyID, s.SecretAccessKey, s.SessionToken, time.Now())
+	if err := doBlobRequest(s.HTTP, req); err != nil {
+		return "", err
+	}
+	if s.PublicURL != "" {
+		return strings.TrimRight(s.PublicURL, "/") + "/" + key, nil
+	}
+	return s.objectURL(key), nil
+}
+
+// signV4 adds AWS Signature Version 4 headers to req, signing every header
+// already set on it.
+func signV4(req *http.Request, payloadHash, region, service, keyID, secret, token string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			params = append(params, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Join(params, "&"),
+		canonHeaders.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", keyID, scope, signed, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEscape percent-encodes everything but RFC 3986 unreserved characters.
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// GCSStore uploads assets to a Google Cloud Storage bucket and links to them
+// at PublicURL.
+type GCSStore struct {
+	Bucket string
+	Prefix string
+
+	// Token returns an OAuth 2 access token with write access to the
+	// bucket, e.g. from golang.org/x/oauth2/google.
+	Token func(ctx context.Context) (string, error)
+
+	// PublicURL defaults to https://storage.googleapis.com/<bucket>.
+	PublicURL string
+
+	HTTP *http.Client
+}
+
+func (s *GCSStore) Put(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	key := s.Prefix + name
+	token, err := s.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	u := "https://storage.googleapis.com/upload/storage/v1/b/" + url.PathEscape(s.Bucket) +
+		"/o?uploadType=media&name=" + url.QueryEscape(key)
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if err := doBlobRequest(s.HTTP, req); err != nil {
+		return "", err
+	}
+	base := s.PublicURL
+	if base == "" {
+		base = "https://storage.googleapis.com/" + s.Bucket
+	}
+	return strings.TrimRight(base, "/") + "/" + key, nil
+}
+
+func doBlobRequest(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
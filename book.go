@@ -0,0 +1,294 @@
+package paper
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Book combines the docs synced into Dir from a folder into one document,
+// such as a team handbook, with a table of contents. Each doc is a chapter
+// titled with its title, its own headings below that.
+//
+// Markdown books start with a title block, so pandoc can make PDF and EPUB
+// from them; HTML books are standalone pages that print a chapter a page.
+// Links between chapters' permalinks become links within the book.
+type Book struct {
+	Dir   string
+	Title string
+
+	// Folder selects the docs in a folder, "/"-separated, and its
+	// subfolders. Empty selects every doc.
+	Folder string
+
+	// Order lists the docs that come first, in order, by ID, slug or
+	// title. The rest follow from oldest to newest.
+	Order []string
+
+	Format ExportFormat // defaults to markdown
+
+	// Permalink builds a doc's site-relative link, as in LinkRewriter. The
+	// default is "/<slug>/".
+	Permalink func(*ManifestEntry) string
+}
+
+// chapter is a doc in a book, rendered in the book's format.
+type chapter struct {
+	*ManifestEntry
+	Content string
+}
+
+// Chapters returns the docs in the book, in order.
+func (b *Book) Chapters() ([]*ManifestEntry, error) {
+	m, err := LoadManifest(filepath.Join(b.Dir, ManifestName))
+	if err != nil {
+		return nil, err
+	}
+	folder := strings.Trim(b.Folder, "/")
+	var docs []*ManifestEntry
+	for _, e := range m.Entries() {
+		path := strings.Join(e.Folders, "/")
+		if e.Path != "" && (folder == "" || path == folder || strings.HasPrefix(path, folder+"/")) {
+			docs = append(docs, e)
+		}
+	}
+	rank := map[string]int{}
+	for i, key := range b.Order {
+		if _, ok := rank[key]; !ok {
+			rank[key] = i + 1
+		}
+	}
+	position := func(e *ManifestEntry) int {
+		for _, key := range []string{string(e.ID), e.Slug, e.Title} {
+			if r, ok := rank[key]; ok {
+				return r
+			}
+		}
+		return len(b.Order) + 1
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		pi, pj := position(docs[i]), position(docs[j])
+		if pi != pj {
+			return pi < pj
+		}
+		if !docs[i].Created.Equal(docs[j].Created) {
+			return docs[i].Created.Before(docs[j].Created)
+		}
+		return docs[i].Title < docs[j].Title
+	})
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("no docs in folder %q", b.Folder)
+	}
+	return docs, nil
+}
+
+// Build returns the book.
+func (b *Book) Build() ([]byte, error) {
+	docs, err := b.Chapters()
+	if err != nil {
+		return nil, err
+	}
+	permalink := b.Permalink
+	if permalink == nil {
+		permalink = func(e *ManifestEntry) string { return "/" + e.Slug + "/" }
+	}
+	// HTML chapters' sections are identified by slug; markdown chapters
+	// are linked to by the anchor renderers derive from their heading.
+	// Renderers number repeated anchors in order, so chapters sharing a
+	// title are told apart the same way.
+	anchors := map[string]string{} // by permalink
+	seen := map[string]int{}
+	for _, e := range docs {
+		anchors[permalink(e)] = e.Slug
+		if b.Format != ExportFormatHTML {
+			anchors[permalink(e)] = uniqueAnchor(seen, e.Title)
+		}
+	}
+	var chapters []*chapter
+	for _, e := range docs {
+		content, err := os.ReadFile(filepath.Join(b.Dir, e.Path))
+		if err != nil {
+			return nil, err
+		}
+		format := ExportFormatMarkdown
+		if filepath.Ext(e.Path) == ".html" {
+			format = ExportFormatHTML
+		} else {
+			content = stripFrontMatter(content)
+		}
+		c := &chapter{ManifestEntry: e}
+		if b.Format == ExportFormatHTML || format == ExportFormatHTML {
+			rendered, _ := htmlHeadings([]byte(stripTitleHTML(string(RenderHTML(format, content)), e.Title)))
+			c.Content = demoteHTML(string(rendered), e.Slug, anchors)
+		} else {
+			c.Content = demoteMarkdown(stripTitleMarkdown(string(content), e.Title), anchors)
+		}
+		chapters = append(chapters, c)
+	}
+	if b.Format == ExportFormatHTML {
+		return b.html(chapters), nil
+	}
+	return b.markdown(chapters), nil
+}
+
+func (b *Book) markdown(chapters []*chapter) []byte {
+	var body strings.Builder
+	for _, c := range chapters {
+		body.WriteString("# " + escapeMarkdown(c.Title) + "\n\n" + strings.TrimSpace(c.Content) + "\n\n")
+	}
+	// The contents link to the anchors renderers give the book's headings.
+	var toc []*Heading
+	for _, h := range markdownHeadings([]byte(body.String())) {
+		if h.Level <= 2 {
+			toc = append(toc, h)
+		}
+	}
+	var out strings.Builder
+	if b.Title != "" {
+		out.WriteString("---\ntitle: " + strconv.Quote(b.Title) + "\n---\n\n")
+	}
+	out.WriteString(TOCMarkdown(nestHeadings(toc)) + "\n\n")
+	out.WriteString(body.String())
+	return []byte(strings.TrimRight(out.String(), "\n") + "\n")
+}
+
+func (b *Book) html(chapters []*chapter) []byte {
+	var toc []*Heading
+	for _, c := range chapters {
+		h := &Heading{Level: 1, Text: c.Title, ID: c.Slug}
+		_, sections := htmlHeadings([]byte(c.Content))
+		for _, s := range sections {
+			if s.Level == 2 {
+				h.Children = append(h.Children, s)
+			}
+		}
+		toc = append(toc, h)
+	}
+	var out strings.Builder
+	out.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	out.WriteString("<title>" + html.EscapeString(b.Title) + "</title>\n")
+	out.WriteString("<style>\nbody { max-width: 40em; margin: 2em auto; padding: 0 1em; font-family: Georgia, serif; line-height: 1.5; }\n" +
+		"@media print { section.chapter { break-before: page; } }\n</style>\n</head>\n<body>\n")
+	if b.Title != "" {
+		out.WriteString("<h1 class=\"title\">" + html.EscapeString(b.Title) + "</h1>\n")
+	}
+	out.WriteString(TOCHTML(toc) + "\n")
+	for _, c := range chapters {
+		out.WriteString(`<section class="chapter" id="` + html.EscapeString(c.Slug) + "\">\n<h1>" + html.EscapeString(c.Title) + "</h1>\n")
+		out.WriteString(strings.TrimSpace(c.Content) + "\n</section>\n")
+	}
+	out.WriteString("</body>\n</html>\n")
+	return []byte(out.String())
+}
+
+// stripTitleMarkdown removes the heading Paper starts docs with, their
+// title, since the chapter heading replaces it.
+func stripTitleMarkdown(content, title string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := atxHeading.FindStringSubmatch(line); m != nil && len(m[1]) == 1 && strings.EqualFold(plainMarkdown(m[2]), strings.TrimSpace(title)) {
+			return strings.Join(lines[i+1:], "\n")
+		}
+		break
+	}
+	return content
+}
+
+var leadingH1 = regexp.MustCompile(`(?is)^\s*<h1\b[^>]*>(.*?)</h1>`)
+
+func stripTitleHTML(content, title string) string {
+	if m := leadingH1.FindStringSubmatchIndex(content); m != nil {
+		text := html.UnescapeString(stripTags(content[m[2]:m[3]]))
+		if strings.EqualFold(strings.TrimSpace(text), strings.TrimSpace(title)) {
+			return content[m[1]:]
+		}
+	}
+	return content
+}
+
+// demoteMarkdown moves a chapter's headings down so they're below its own,
+// to six at most, and points links to chapters at their place in the book.
+func demoteMarkdown(content string, anchors map[string]string) string {
+	lines := strings.Split(content, "\n")
+	code := codeLines(lines)
+	top := 6
+	for i, line := range lines {
+		if m := atxHeading.FindStringSubmatch(line); m != nil && !code[i] {
+			top = min(top, len(m[1]))
+		}
+	}
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		if m := atxHeading.FindStringSubmatch(line); m != nil && top < 2 && len(m[1]) < 6 {
+			line = strings.Replace(line, m[1], m[1]+"#", 1)
+		}
+		lines[i] = outsideLiteralSpans(line, func(s string) string {
+			return chapterLink.ReplaceAllStringFunc(s, func(link string) string {
+				m := chapterLink.FindStringSubmatch(link)
+				if anchor, ok := anchors[m[2]]; ok {
+					return m[1] + "#" + anchor
+				}
+				return link
+			})
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	// chapterLink is siteLink with the fragment that may follow.
+	chapterLink    = regexp.MustCompile(siteLink.String() + `(?:#([^\s"'<>()]*))?`)
+	htmlHeadingTag = regexp.MustCompile(`(?i)<(/?)h([1-6])\b`)
+	htmlAnchor     = regexp.MustCompile(`\b(id|href)="(#?)([^"]*)"`)
+)
+
+// demoteHTML moves a chapter's headings down so they're below its own, to
+// six at most. So that chapters' ids can't collide, they're prefixed with
+// the chapter's slug, and links to chapters point at their place in the
+// book.
+func demoteHTML(content, slug string, anchors map[string]string) string {
+	shift := 0
+	for _, m := range htmlHeadingTag.FindAllStringSubmatch(content, -1) {
+		if m[2] == "1" {
+			shift = 1
+		}
+	}
+	content = htmlHeadingTag.ReplaceAllStringFunc(content, func(tag string) string {
+		m := htmlHeadingTag.FindStringSubmatch(tag)
+		level, _ := strconv.Atoi(m[2])
+		return "<" + m[1] + "h" + strconv.Itoa(min(level+shift, 6))
+	})
+	return htmlAnchor.ReplaceAllStringFunc(content, func(attr string) string {
+		m := htmlAnchor.FindStringSubmatch(attr)
+		switch {
+		case m[1] == "id":
+			return `id="` + slug + "-" + m[3] + `"`
+		case m[2] == "#":
+			return `href="#` + slug + "-" + m[3] + `"`
+		}
+		path, fragment := m[3], ""
+		if i := strings.IndexByte(path, '#'); i >= 0 {
+			path, fragment = path[:i], path[i+1:]
+		}
+		if i := strings.IndexByte(path, '?'); i >= 0 {
+			path = path[:i]
+		}
+		if chapter, ok := anchors[path]; ok {
+			if fragment != "" {
+				return `href="#` + chapter + "-" + fragment + `"`
+			}
+			return `href="#` + chapter + `"`
+		}
+		return attr
+	})
+}
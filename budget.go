@@ -0,0 +1,199 @@
+package paper
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Budget shares one token's rate limit among the pipelines using it, such
+// as a sync, a backup and a search indexer running side by side. Calls are
+// spaced to RequestsPerSecond in total, and while several pipelines are
+// waiting each gets turns in proportion to its weight, so a busy one can't
+// starve the rest. A 429 from any of them pauses them all for the
+// Retry-After the API asks for, and the call is retried, instead of every
+// pipeline retrying into a storm of 429s.
+type Budget struct {
+	// RequestsPerSecond is the token's rate limit. Zero leaves calls
+	// unspaced, only pausing after a 429.
+	RequestsPerSecond float64
+
+	// Retries is how many times a call that got a 429 is retried,
+	// defaulting to 3.
+	Retries int
+
+	mu        sync.Mutex
+	next      time.Time // earliest time for the next call
+	paused    time.Time // no calls before this, after a 429
+	vclock    float64   // virtual start of the last granted turn
+	pipelines map[string]*budgetPipeline
+	timer     *time.Timer
+	timerAt   time.Time
+}
+
+type budgetPipeline struct {
+	weight  float64
+	vtime   float64 // virtual finish of its last turn
+	waiting []chan struct{}
+}
+
+// Client returns a copy of c whose calls draw on the budget as the named
+// pipeline. Weight sets its share relative to the others; 0 means 1.
+func (b *Budget) Client(c *APIClient, pipeline string, weight int) *APIClient {
+	if weight <= 0 {
+		weight = 1
+	}
+	b.mu.Lock()
+	if b.pipelines == nil {
+		b.pipelines = map[string]*budgetPipeline{}
+	}
+	p, ok := b.pipelines[pipeline]
+	if !ok {
+		p = &budgetPipeline{}
+		b.pipelines[pipeline] = p
+	}
+	p.weight = float64(weight)
+	b.mu.Unlock()
+
+	clone := c.Clone()
+	base := clone.HTTP.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	clone.HTTP.Transport = &budgetTransport{base: base, budget: b, pipeline: p}
+	return clone
+}
+
+// wait blocks until it's p's turn.
+func (b *Budget) wait(ctx context.Context, p *budgetPipeline) error {
+	ch := make(chan struct{})
+	b.mu.Lock()
+	if len(p.waiting) == 0 && p.vtime < b.vclock {
+		// A pipeline that was idle doesn't get to catch up on the turns
+		// it didn't use.
+		p.vtime = b.vclock
+	}
+	p.waiting = append(p.waiting, ch)
+	b.dispatch()
+	b.mu.Unlock()
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, w := range p.waiting {
+			if w == ch {
+				p.waiting = append(p.waiting[:i], p.waiting[i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// Granted just as ctx was canceled; the turn is spent anyway.
+		return ctx.Err()
+	}
+}
+
+// dispatch grants turns that are due, choosing the waiting pipeline with
+// the earliest virtual finish time, and sets a timer for the next one. The
+// caller holds b.mu.
+func (b *Budget) dispatch() {
+	var interval time.Duration
+	if b.RequestsPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / b.RequestsPerSecond)
+	}
+	for {
+		var next *budgetPipeline
+		for _, p := range b.pipelines {
+			if len(p.waiting) > 0 && (next == nil || p.vtime < next.vtime) {
+				next = p
+			}
+		}
+		if next == nil {
+			return
+		}
+		now := time.Now()
+		at := b.next
+		if b.paused.After(at) {
+			at = b.paused
+		}
+		if at.After(now) {
+			b.wake(at)
+			return
+		}
+		close(next.waiting[0])
+		next.waiting = next.waiting[1:]
+		b.vclock = next.vtime
+		next.vtime += 1 / next.weight
+		b.next = now.Add(interval)
+	}
+}
+
+// wake arranges for dispatch to run at t.
+func (b *Budget) wake(t time.Time) {
+	if b.timer != nil && !b.timerAt.After(t) {
+		return
+	}
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.timerAt = t
+	b.timer = time.AfterFunc(time.Until(t), func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.timer = nil
+		b.dispatch()
+	})
+}
+
+// pause holds every pipeline's calls for d.
+func (b *Budget) pause(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until := time.Now().Add(d); until.After(b.paused) {
+		b.paused = until
+	}
+}
+
+type budgetTransport struct {
+	base     http.RoundTripper
+	budget   *Budget
+	pipeline *budgetPipeline
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := t.budget.Retries
+	if retries == 0 {
+		retries = 3
+	}
+	for attempt := 0; ; attempt++ {
+		if err := t.budget.wait(req.Context(), t.pipeline); err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+		t.budget.pause(retryAfter(resp))
+		if attempt == retries || req.GetBody == nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+}
+
+// retryAfter returns how long a 429 response asks callers to wait,
+// defaulting to a second.
+func retryAfter(resp *http.Response) time.Duration {
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return time.Second
+}
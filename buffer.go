@@ -0,0 +1,81 @@
+package paper
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// bufferPool holds the buffers response bodies of unknown length are read
+// into, so concurrent downloads don't each grow a fresh one.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer keeps unusually large buffers from being pinned by the
+// pool.
+const maxPooledBuffer = 16 << 20
+
+// readBody reads a response body into a slice of exactly its size. Bodies
+// with a known length are read straight into it; others are read through a
+// pooled buffer and copied out once.
+func readBody(resp *http.Response) ([]byte, error) {
+	if n := resp.ContentLength; n > 0 && n < 1<<30 {
+		blob := make([]byte, n)
+		if _, err := io.ReadFull(resp.Body, blob); err != nil {
+			return nil, err
+		}
+		return blob, nil
+	}
+	b := bufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if b.Cap() <= maxPooledBuffer {
+			b.Reset()
+			bufferPool.Put(b)
+		}
+	}()
+	if _, err := b.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	blob := make([]byte, b.Len())
+	copy(blob, b.Bytes())
+	return blob, nil
+}
+
+// cancelableBody makes reads from a response body stop when ctx is done.
+// Transports don't all abort a body already being read when its request's
+// context is canceled, and a large download left draining holds up
+// shutdown; closing the body unblocks the read, which then returns
+// ctx.Err().
+func cancelableBody(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	b := &ctxBody{ctx: ctx, body: body}
+	b.stop = context.AfterFunc(ctx, func() { body.Close() })
+	return b
+}
+
+type ctxBody struct {
+	ctx  context.Context
+	body io.ReadCloser
+	stop func() bool
+}
+
+func (b *ctxBody) Read(p []byte) (int, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := b.body.Read(p)
+	if err != nil && err != io.EOF && b.ctx.Err() != nil {
+		return n, b.ctx.Err()
+	}
+	return n, err
+}
+
+// Close closes the body unless canceling ctx already has.
+func (b *ctxBody) Close() error {
+	if b.stop() {
+		return b.body.Close()
+	}
+	return nil
+}
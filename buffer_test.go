@@ -0,0 +1,115 @@
+package paper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadBody(t *testing.T) {
+	for _, tc := range []struct {
+		body   string
+		length int64
+	}{
+		{"known length", 12},
+		{"unknown length", -1},
+		{"", 0},
+		{"second unknown", -1}, // from a pooled buffer, without the first's bytes
+	} {
+		resp := &http.Response{Body: io.NopCloser(strings.NewReader(tc.body)), ContentLength: tc.length}
+		got, err := readBody(resp)
+		if err != nil {
+			t.Fatalf("readBody(%q): %v", tc.body, err)
+		}
+		if string(got) != tc.body {
+			t.Errorf("readBody(%q) = %q", tc.body, got)
+		}
+		if len(got) != cap(got) {
+			t.Errorf("readBody(%q) has len %d, cap %d", tc.body, len(got), cap(got))
+		}
+	}
+}
+
+func TestReadBodyShort(t *testing.T) {
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader("short")), ContentLength: 10}
+	if _, err := readBody(resp); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("readBody of a short body: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+// closeCounter counts Close calls on a pipe's reader.
+type closeCounter struct {
+	*io.PipeReader
+	closed atomic.Int32
+}
+
+func (c *closeCounter) Close() error {
+	c.closed.Add(1)
+	return c.PipeReader.Close()
+}
+
+func TestCancelableBodyCancel(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &closeCounter{PipeReader: pr}
+	body := cancelableBody(ctx, c)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(body)
+		done <- err
+	}()
+	pw.Write([]byte("partial"))
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("read after cancel: got %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("read still blocked after cancel")
+	}
+	if _, err := body.Read(make([]byte, 1)); !errors.Is(err, context.Canceled) {
+		t.Errorf("next read: got %v, want %v", err, context.Canceled)
+	}
+
+	// Canceling closed the body, so closing doesn't close it again.
+	body.Close()
+	time.Sleep(10 * time.Millisecond)
+	if c.closed.Load() != 1 {
+		t.Errorf("body closed %d times, want 1", c.closed.Load())
+	}
+}
+
+func TestCancelableBodyClose(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("whole body"))
+		pw.Close()
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &closeCounter{PipeReader: pr}
+	body := cancelableBody(ctx, c)
+	var got bytes.Buffer
+	if _, err := got.ReadFrom(body); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "whole body" {
+		t.Errorf("read %q, want %q", got.String(), "whole body")
+	}
+	body.Close()
+
+	// Once closed, canceling doesn't close the body again.
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	if c.closed.Load() != 1 {
+		t.Errorf("body closed %d times, want 1", c.closed.Load())
+	}
+}
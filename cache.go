@@ -0,0 +1,155 @@
+package paper
+
+import (
+	"context"
+	"sync"
+)
+
+// CacheKey identifies one export of one revision of a doc.
+type CacheKey struct {
+	DocID    DocID
+	Revision int64
+	Format   ExportFormat
+}
+
+// Cache stores doc exports. A revision's export never changes, so entries
+// don't go stale; implementations only need to bound their size.
+type Cache interface {
+	Get(CacheKey) (*PaperDocExportResult, []byte, bool)
+	Put(CacheKey, *PaperDocExportResult, []byte)
+
+	// Latest returns the newest cached revision of a doc's export, or 0.
+	Latest(docID DocID, format ExportFormat) int64
+}
+
+type cacheEntry struct {
+	res     PaperDocExportResult
+	content []byte
+}
+
+// MemoryCache is an unbounded Cache held in memory.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[CacheKey]cacheEntry
+}
+
+func (c *MemoryCache) Get(key CacheKey) (*PaperDocExportResult, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, nil, false
+	}
+	res := e.res
+	return &res, append([]byte(nil), e.content...), true
+}
+
+func (c *MemoryCache) Put(key CacheKey, res *PaperDocExportResult, content []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[CacheKey]cacheEntry{}
+	}
+	c.entries[key] = cacheEntry{*res, append([]byte(nil), content...)}
+}
+
+func (c *MemoryCache) Latest(docID DocID, format ExportFormat) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var rev int64
+	for key := range c.entries {
+		if key.DocID == docID && key.Format == format && key.Revision > rev {
+			rev = key.Revision
+		}
+	}
+	return rev
+}
+
+// CachingClient wraps a Client, serving exports from Cache instead of
+// downloading them again when a doc's revision hasn't changed.
+type CachingClient struct {
+	Client
+	Cache Cache
+
+	// Revision reports a doc's current revision, e.g. from a Watcher's
+	// snapshot. Docs it reports 0 for are downloaded. When it's nil and
+	// Client is a ConditionalClient, docs with a cached export are only
+	// transferred if they've changed since; otherwise every doc is
+	// downloaded. Downloads are cached either way.
+	Revision func(ctx context.Context, docID DocID) (int64, error)
+}
+
+// ListDocsContinue continues a listing with Client.
+func (c *CachingClient) ListDocsContinue(ctx context.Context, in *ListPaperDocsContinueArgs) (*ListPaperDocsResponse, error) {
+	return listDocsContinue(ctx, c.Client, in)
+}
+
+func (c *CachingClient) DownloadDoc(ctx context.Context, in *PaperDocExport) (*PaperDocExportResult, []byte, error) {
+	format := in.Format
+	if format == "" {
+		format = ExportFormatMarkdown
+	}
+	if c.Revision != nil {
+		rev, err := c.Revision(ctx, in.DocID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if rev != 0 {
+			if res, content, ok := c.Cache.Get(CacheKey{in.DocID, rev, format}); ok {
+				return res, content, nil
+			}
+		}
+	}
+	if cond, ok := c.Client.(ConditionalClient); ok && c.Revision == nil {
+		if latest := c.Cache.Latest(in.DocID, format); latest != 0 {
+			res, content, err := cond.DownloadDocIfChanged(ctx, in, latest)
+			switch {
+			case err == ErrNotModified:
+				if res, content, ok := c.Cache.Get(CacheKey{in.DocID, latest, format}); ok {
+					return res, content, nil
+				}
+				// Evicted since Latest; download it again.
+			case err != nil:
+				return res, content, err
+			default:
+				c.Cache.Put(CacheKey{in.DocID, res.Revision, format}, res, content)
+				return res, content, nil
+			}
+		}
+	}
+	res, content, err := c.Client.DownloadDoc(ctx, in)
+	if err != nil {
+		return res, content, err
+	}
+	c.Cache.Put(CacheKey{in.DocID, res.Revision, format}, res, content)
+	return res, content, nil
+}
+
+// DownloadDocIfChanged downloads a doc as DownloadDoc does, unless it's still
+// at knownRevision, in which case it returns the doc's metadata and
+// ErrNotModified.
+func (c *CachingClient) DownloadDocIfChanged(ctx context.Context, in *PaperDocExport, knownRevision int64) (*PaperDocExportResult, []byte, error) {
+	format := in.Format
+	if format == "" {
+		format = ExportFormatMarkdown
+	}
+	if cond, ok := c.Client.(ConditionalClient); ok && c.Revision == nil {
+		res, content, err := cond.DownloadDocIfChanged(ctx, in, knownRevision)
+		if err == nil {
+			c.Cache.Put(CacheKey{in.DocID, res.Revision, format}, res, content)
+		}
+		return res, content, err
+	}
+	res, content, err := c.DownloadDoc(ctx, in)
+	if err == nil && res.Revision == knownRevision {
+		return res, nil, ErrNotModified
+	}
+	return res, content, err
+}
+
+var (
+	_ ConditionalClient = &CachingClient{}
+
+	_ Cache = &MemoryCache{}
+	_ Cache = &DiskCache{}
+)
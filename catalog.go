@@ -0,0 +1,123 @@
+package paper
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Catalog keeps doc metadata in a SQL database so large installations can
+// inspect their corpus with SQL. It's written for SQLite: open the database
+// with a driver such as modernc.org/sqlite or github.com/mattn/go-sqlite3 and
+// pass it to NewCatalog.
+type Catalog struct {
+	DB *sql.DB
+}
+
+type CatalogEntry struct {
+	ID       DocID
+	Title    string
+	Owner    string
+	Revision int64
+	Folder   string // folder path, "/"-separated
+	Status   string // "synced" or "removed"
+	Created  time.Time
+	Updated  time.Time
+	Synced   time.Time
+
+	Words       int
+	ReadingTime int // estimated minutes, or 0
+}
+
+const catalogSchema = `CREATE TABLE IF NOT EXISTS docs (
+	id       TEXT PRIMARY KEY,
+	title    TEXT NOT NULL,
+	owner    TEXT NOT NULL,
+	revision INTEGER NOT NULL,
+	folder   TEXT NOT NULL,
+	status   TEXT NOT NULL,
+	created  TIMESTAMP,
+	updated  TIMESTAMP,
+	synced   TIMESTAMP,
+	words    INTEGER NOT NULL DEFAULT 0,
+	reading_time INTEGER NOT NULL DEFAULT 0
+)`
+
+const catalogColumns = "id, title, owner, revision, folder, status, created, updated, synced, words, reading_time"
+
+// catalogAdded lists the columns added to the schema since it was first
+// released, with their definitions.
+var catalogAdded = [][2]string{
+	{"words", "INTEGER NOT NULL DEFAULT 0"},
+	{"reading_time", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// NewCatalog creates the docs table if it doesn't exist, and adds columns
+// that tables created by older versions lack.
+func NewCatalog(ctx context.Context, db *sql.DB) (*Catalog, error) {
+	if _, err := db.ExecContext(ctx, catalogSchema); err != nil {
+		return nil, err
+	}
+	for _, col := range catalogAdded {
+		if rows, err := db.QueryContext(ctx, "SELECT "+col[0]+" FROM docs LIMIT 0"); err == nil {
+			rows.Close()
+			continue
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE docs ADD COLUMN "+col[0]+" "+col[1]); err != nil {
+			return nil, err
+		}
+	}
+	return &Catalog{DB: db}, nil
+}
+
+// Put inserts or replaces an entry.
+func (c *Catalog) Put(ctx context.Context, e *CatalogEntry) error {
+	_, err := c.DB.ExecContext(ctx,
+		"INSERT OR REPLACE INTO docs ("+catalogColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		e.ID, e.Title, e.Owner, e.Revision, e.Folder, e.Status, e.Created, e.Updated, e.Synced, e.Words, e.ReadingTime)
+	return err
+}
+
+// MarkRemoved records that a doc is no longer in Paper.
+func (c *Catalog) MarkRemoved(ctx context.Context, id DocID, at time.Time) error {
+	_, err := c.DB.ExecContext(ctx, "UPDATE docs SET status = 'removed', synced = ? WHERE id = ?", at, id)
+	return err
+}
+
+func (c *Catalog) Get(ctx context.Context, id DocID) (*CatalogEntry, error) {
+	entries, err := c.Query(ctx, "id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return entries[0], nil
+}
+
+// Query returns the entries matching a SQL condition, e.g.
+// Query(ctx, "owner = ? AND updated > ?", owner, since). An empty condition
+// matches every entry. Entries are ordered by title.
+func (c *Catalog) Query(ctx context.Context, where string, args ...interface{}) ([]*CatalogEntry, error) {
+	q := "SELECT " + catalogColumns + " FROM docs"
+	if strings.TrimSpace(where) != "" {
+		q += " WHERE " + where
+	}
+	rows, err := c.DB.QueryContext(ctx, q+" ORDER BY title, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*CatalogEntry
+	for rows.Next() {
+		var e CatalogEntry
+		var created, updated, synced sql.NullTime
+		if err := rows.Scan(&e.ID, &e.Title, &e.Owner, &e.Revision, &e.Folder, &e.Status, &created, &updated, &synced, &e.Words, &e.ReadingTime); err != nil {
+			return nil, err
+		}
+		e.Created, e.Updated, e.Synced = created.Time, updated.Time, synced.Time
+		out = append(out, &e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,94 @@
+package paper
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Category groups the posts in one Paper folder.
+type Category struct {
+	Name      string
+	Slug      string
+	Permalink string
+	Posts     []*Post
+}
+
+// CategoryName maps a Paper folder to a category, overriding the folder's
+// own name and slug. An empty Name leaves the folder's posts uncategorized.
+type CategoryName struct {
+	Name string
+	Slug string
+}
+
+// category returns the category for a post's innermost folder.
+func (s *Site) category(e *ManifestEntry) (name, slug string) {
+	if len(e.Folders) == 0 {
+		return "", ""
+	}
+	folder := e.Folders[len(e.Folders)-1]
+	name = folder
+	if c, ok := s.Categories[folder]; ok {
+		if c.Name == "" {
+			return "", ""
+		}
+		name, slug = c.Name, c.Slug
+	}
+	if slug == "" {
+		slug = Slugify(name)
+	}
+	return name, slug
+}
+
+// CategoryList groups posts by category, sorted by name. Posts keep their
+// order within each category.
+func (s *Site) CategoryList(posts []*Post) []*Category {
+	bySlug := map[string]*Category{}
+	var cats []*Category
+	for _, p := range posts {
+		if p.Category == nil {
+			continue
+		}
+		c, ok := bySlug[p.Category.Slug]
+		if !ok {
+			c = &Category{Name: p.Category.Name, Slug: p.Category.Slug, Permalink: p.Category.Permalink}
+			bySlug[c.Slug] = c
+			cats = append(cats, c)
+		}
+		c.Posts = append(c.Posts, p)
+	}
+	sort.Slice(cats, func(i, j int) bool { return cats[i].Name < cats[j].Name })
+	return cats
+}
+
+func categoryPath(slug string) string {
+	if slug == "" {
+		return "/category/"
+	}
+	return "/category/" + slug + "/"
+}
+
+// writeCategories writes a page per category and a category index. Sites
+// whose posts have no folders get neither.
+func (s *Site) writeCategories(theme *Theme, posts []*Post) error {
+	cats := s.CategoryList(posts)
+	if len(cats) == 0 {
+		return nil
+	}
+	var b bytes.Buffer
+	if err := theme.Execute(&b, "categories", &PageData{Site: s, Posts: posts, Categories: cats}); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(pageFile(s.out(), categoryPath("")), b.Bytes(), 0644); err != nil {
+		return err
+	}
+	for _, c := range cats {
+		b.Reset()
+		if err := theme.Execute(&b, "category", &PageData{Site: s, Posts: c.Posts, Category: c, Categories: cats}); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(pageFile(s.out(), c.Permalink), b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
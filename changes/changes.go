@@ -0,0 +1,89 @@
+// Package changes classifies docs as added, modified or removed by comparing
+// their revisions against a previous snapshot. It knows nothing about how
+// revisions are fetched, so the syncer, the CLI and webhook handlers can all
+// share it.
+package changes
+
+import (
+	"context"
+	"sort"
+)
+
+// Snapshot maps doc IDs to the revision seen at some point in time.
+type Snapshot map[string]int64
+
+// Lister reports the current revision of every doc.
+type Lister interface {
+	Revisions(ctx context.Context) (Snapshot, error)
+}
+
+type Kind int
+
+const (
+	Added Kind = iota + 1
+	Modified
+	Removed
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Modified:
+		return "modified"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Change is one doc's difference between two snapshots. OldRevision is zero
+// for added docs and NewRevision is zero for removed ones.
+type Change struct {
+	ID          string
+	Kind        Kind
+	OldRevision int64
+	NewRevision int64
+}
+
+// Compare returns the changes from prev to cur, sorted by doc ID.
+func Compare(prev, cur Snapshot) []Change {
+	var out []Change
+	for id, rev := range cur {
+		old, ok := prev[id]
+		switch {
+		case !ok:
+			out = append(out, Change{ID: id, Kind: Added, NewRevision: rev})
+		case old != rev:
+			out = append(out, Change{ID: id, Kind: Modified, OldRevision: old, NewRevision: rev})
+		}
+	}
+	for id, old := range prev {
+		if _, ok := cur[id]; !ok {
+			out = append(out, Change{ID: id, Kind: Removed, OldRevision: old})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Detect lists the current revisions and compares them against prev. The
+// current snapshot is returned so it can be saved for the next call.
+func Detect(ctx context.Context, l Lister, prev Snapshot) (Snapshot, []Change, error) {
+	cur, err := l.Revisions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cur, Compare(prev, cur), nil
+}
+
+// Filter returns the changes of the given kind.
+func Filter(cs []Change, kind Kind) []Change {
+	var out []Change
+	for _, c := range cs {
+		if c.Kind == kind {
+			out = append(out, c)
+		}
+	}
+	return out
+}
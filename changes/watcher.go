@@ -0,0 +1,93 @@
+package changes
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Watcher polls a Lister and reports changes as they're detected.
+type Watcher struct {
+	Lister   Lister
+	Interval time.Duration // defaults to 1m
+	Jitter   time.Duration // up to this much is added to each interval at random
+
+	// Snapshot is the state changes are reported against. When nil, the
+	// first poll only establishes it. It's updated after every poll.
+	Snapshot Snapshot
+
+	OnAdded    func(Change)
+	OnModified func(Change)
+	OnRemoved  func(Change)
+
+	// Events, when set, receives every change after the callbacks run.
+	Events chan<- Change
+
+	// OnError is called when a poll fails. Polling continues either way.
+	OnError func(error)
+}
+
+// Run polls until ctx is canceled.
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval == 0 {
+		interval = time.Minute
+	}
+	if w.Snapshot == nil {
+		if err := w.Poll(ctx); err != nil && w.OnError != nil {
+			w.OnError(err)
+		}
+	}
+	for {
+		wait := interval
+		if w.Jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(w.Jitter)))
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		if err := w.Poll(ctx); err != nil && w.OnError != nil {
+			w.OnError(err)
+		}
+	}
+}
+
+// Poll checks for changes once. When there's no snapshot yet it takes one
+// without reporting anything.
+func (w *Watcher) Poll(ctx context.Context) error {
+	cur, err := w.Lister.Revisions(ctx)
+	if err != nil {
+		return err
+	}
+	prev := w.Snapshot
+	w.Snapshot = cur
+	if prev == nil {
+		return nil
+	}
+	for _, c := range Compare(prev, cur) {
+		var fn func(Change)
+		switch c.Kind {
+		case Added:
+			fn = w.OnAdded
+		case Modified:
+			fn = w.OnModified
+		case Removed:
+			fn = w.OnRemoved
+		}
+		if fn != nil {
+			fn(c)
+		}
+		if w.Events != nil {
+			select {
+			case w.Events <- c:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
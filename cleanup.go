@@ -0,0 +1,242 @@
+package paper
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Cleanup strips what Paper's editor leaves in exports: comment anchors
+// and highlights, mention markup, empty elements and metadata lines at the
+// end of a doc. Each is a rule that can be turned off.
+type Cleanup struct {
+	// Rules names the rules to apply, defaulting to CleanupRules.
+	Rules []string
+
+	// Metadata matches the trailing lines the metadata rule removes,
+	// defaulting to DefaultMetadataLines. Markdown is matched as it is,
+	// HTML by its text.
+	Metadata []*regexp.Regexp
+}
+
+// CleanupRules lists the rules a Cleanup knows.
+var CleanupRules = []string{"comments", "mentions", "empty", "metadata"}
+
+var DefaultMetadataLines = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^[\s*_]*(created|last edited|edited|last updated|updated|modified)(\s+(by|on|at)\b|:|\s+\d|\s+(today|yesterday|just now)\b)`),
+	regexp.MustCompile(`(?i)^[\s*_]*(made|powered|created|written) (with|by|in) (dropbox )?paper\b`),
+	regexp.MustCompile(`(?i)^[\s*_]*view (this doc )?in (dropbox )?paper\b`),
+}
+
+func (c *Cleanup) rules() (map[string]bool, error) {
+	names := c.Rules
+	if names == nil {
+		names = CleanupRules
+	}
+	rules := map[string]bool{}
+	for _, name := range names {
+		if !contains(CleanupRules, name) {
+			return nil, fmt.Errorf("unknown cleanup rule %q", name)
+		}
+		rules[name] = true
+	}
+	return rules, nil
+}
+
+func (c *Cleanup) metadata() []*regexp.Regexp {
+	if c.Metadata == nil {
+		return DefaultMetadataLines
+	}
+	return c.Metadata
+}
+
+func (c *Cleanup) Transform(doc *Doc) error {
+	rules, err := c.rules()
+	if err != nil {
+		return err
+	}
+	if doc.Format == ExportFormatHTML {
+		doc.Content = []byte(c.cleanHTML(string(doc.Content), rules))
+	} else {
+		doc.Content = []byte(c.cleanMarkdown(string(doc.Content), rules))
+	}
+	return nil
+}
+
+// isArtifact reports whether a start tag is markup a rule removes, keeping
+// the element's contents.
+func isArtifact(tok htmlToken, rules map[string]bool) bool {
+	class, _ := tok.attr("class")
+	class = strings.ToLower(class)
+	if tok.Data != "a" && tok.Data != "span" {
+		return false
+	}
+	_, href := tok.attr("href")
+	comment := strings.Contains(class, "comment") || tok.Data == "a" && !href
+	return rules["comments"] && comment || rules["mentions"] && strings.Contains(class, "mention")
+}
+
+// emptyable lists the elements the empty rule removes when they have no
+// content.
+var emptyable = map[string]bool{"div": true, "p": true, "span": true}
+
+func (c *Cleanup) cleanHTML(src string, rules map[string]bool) string {
+	type open struct {
+		tag     string
+		start   int // of the start tag in out
+		content int // of the content
+		removed bool
+	}
+	var out []byte
+	var stack []open
+	for _, tok := range tokenizeHTML(src) {
+		switch tok.Type {
+		case htmlStartTag:
+			e := open{tag: tok.Data, start: len(out), removed: isArtifact(tok, rules)}
+			if !e.removed {
+				out = append(out, tok.String()...)
+			}
+			e.content = len(out)
+			stack = append(stack, e)
+			continue
+		case htmlEndTag:
+			i := len(stack) - 1
+			for i >= 0 && stack[i].tag != tok.Data {
+				i--
+			}
+			if i < 0 {
+				break
+			}
+			e := stack[i]
+			stack = stack[:i]
+			if e.removed {
+				continue
+			}
+			if rules["empty"] && emptyable[e.tag] && blankHTML(string(out[e.content:])) {
+				out = out[:e.start]
+				continue
+			}
+		}
+		out = append(out, tok.String()...)
+	}
+	s := string(out)
+	if rules["metadata"] {
+		s = c.trimHTMLMetadata(s)
+	}
+	return s
+}
+
+var htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|&nbsp;|&#160;`)
+
+// blankHTML reports whether content holds nothing but whitespace and line
+// breaks.
+func blankHTML(content string) bool {
+	return strings.TrimSpace(htmlBreak.ReplaceAllString(content, "")) == ""
+}
+
+var htmlBlockEnd = regexp.MustCompile(`(?i)</(p|div|h[1-6])>\s*$`)
+
+// trimHTMLMetadata removes metadata paragraphs from the end of a doc, or
+// the end of its body.
+func (c *Cleanup) trimHTMLMetadata(s string) string {
+	tail := ""
+	if i := strings.LastIndex(strings.ToLower(s), "</body>"); i >= 0 {
+		s, tail = s[:i], s[i:]
+	}
+	for {
+		loc := htmlBlockEnd.FindStringSubmatchIndex(s)
+		if loc == nil {
+			break
+		}
+		tag := strings.ToLower(s[loc[2]:loc[3]])
+		start := strings.LastIndex(strings.ToLower(s[:loc[0]]), "<"+tag)
+		if start < 0 {
+			break
+		}
+		block := s[start:loc[0]]
+		if strings.Contains(strings.ToLower(block), "</"+tag) {
+			break // nested, so start isn't this block's
+		}
+		text := html.UnescapeString(stripTags(block))
+		if !matchesAny(c.metadata(), strings.TrimSpace(text)) {
+			break
+		}
+		s = strings.TrimRight(s[:start], " \t\r\n")
+	}
+	return s + tail
+}
+
+var anyTag = regexp.MustCompile(`<[^>]*>`)
+
+func stripTags(s string) string {
+	return anyTag.ReplaceAllString(s, "")
+}
+
+func matchesAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	commentAnchor = regexp.MustCompile(`<a\s+(?:name|id)="[^"]*"\s*>\s*</a>`)
+	commentSpan   = regexp.MustCompile(`<span\s+class="[^"]*comment[^"]*"[^>]*>(.*?)</span>`)
+	mentionSpan   = regexp.MustCompile(`<span\s+class="[^"]*mention[^"]*"[^>]*>(.*?)</span>`)
+	mentionLink   = regexp.MustCompile(`\[(@[^\]]+)\]\([^)\s]*\)`)
+	emptyLine     = regexp.MustCompile(`^\s*((<(div|p|span)[^>]*>\s*</(div|p|span)>|<br\s*/?>|&nbsp;)\s*)+$`)
+)
+
+func (c *Cleanup) cleanMarkdown(src string, rules map[string]bool) string {
+	lines := strings.Split(src, "\n")
+	code := codeLines(lines)
+	kept, dropped := lines[:0], false
+	for i, line := range lines {
+		if code[i] {
+			kept = append(kept, line)
+			continue
+		}
+		line = outsideLiteralSpans(line, func(s string) string {
+			if rules["comments"] {
+				s = commentAnchor.ReplaceAllString(s, "")
+				s = commentSpan.ReplaceAllString(s, "$1")
+			}
+			if rules["mentions"] {
+				s = mentionSpan.ReplaceAllString(s, "$1")
+				s = mentionLink.ReplaceAllString(s, "$1")
+			}
+			return s
+		})
+		if rules["empty"] && emptyLine.MatchString(line) {
+			dropped = true
+			continue
+		}
+		// Don't leave two blank lines where an empty element was.
+		if dropped && strings.TrimSpace(line) == "" && len(kept) > 0 && strings.TrimSpace(kept[len(kept)-1]) == "" {
+			continue
+		}
+		dropped = false
+		kept = append(kept, line)
+	}
+	lines, code = kept, codeLines(kept)
+	if rules["metadata"] {
+		end, removed := len(lines), false
+		for end > 0 {
+			line := strings.TrimSpace(lines[end-1])
+			if line != "" {
+				if code[end-1] || !matchesAny(c.metadata(), line) {
+					break
+				}
+				removed = true
+			}
+			end--
+		}
+		if removed {
+			lines = append(lines[:end], "")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
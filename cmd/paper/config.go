@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/kyleconroy/paper"
+	"github.com/kyleconroy/paper/config"
+)
+
+// conf holds the settings from the config file and the environment. The
+// daemon swaps in a new one when it reloads.
+var conf atomic.Pointer[config.Config]
+
+// configName is the config file given with -config or PAPER_CONFIG.
+var configName string
+
+// loadConfig reads the config file, if any, and the environment.
+func loadConfig() (*config.Config, error) {
+	c := config.Default()
+	if configName != "" {
+		var err error
+		if c, err = config.Load(configName); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseFlags parses a command's flags, then fills in the ones not given on
+// the command line from the config.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for name, value := range configFlags(conf.Load()) {
+		if fs.Lookup(name) != nil && !given[name] {
+			fs.Set(name, value)
+		}
+	}
+}
+
+// configFlags returns the flag values the config's settings amount to.
+func configFlags(c *config.Config) map[string]string {
+	flags := map[string]string{"dir": c.Sync.Dir}
+	set := func(name, value string) {
+		if value != "" {
+			flags[name] = value
+		}
+	}
+	set("cache", c.Sync.Cache)
+	set("trash", c.Sync.Trash)
+	set("out", c.Site.Out)
+	set("url", c.Site.URL)
+	set("title", c.Site.Title)
+	set("theme", c.Site.Theme)
+	if c.Sync.ASCIISlugs {
+		flags["ascii-slugs"] = "true"
+	}
+	if c.Sync.Sidecars {
+		flags["sidecars"] = "true"
+	}
+	if c.Sync.FrontMatter {
+		flags["front-matter"] = "true"
+	}
+	if c.Sync.Mirror {
+		flags["mirror-folders"] = "true"
+	}
+	if c.Auth.Team {
+		flags["team"] = "true"
+	}
+	if c.Site.PageSize != 0 {
+		flags["page-size"] = strconv.Itoa(c.Site.PageSize)
+	}
+	if c.Site.Search {
+		flags["search"] = "true"
+	}
+	if p := c.Publish; p != nil {
+		flags["publish"] = "true"
+		set("remote", p.Remote)
+		set("branch", p.Branch)
+		set("cname", p.CNAME)
+	}
+	if s := c.Schedule; s != nil {
+		set("schedule", s.Spec)
+		set("health-addr", s.HealthAddr)
+		flags["timeout"] = s.Timeout.String()
+		if s.MaxAge != 0 {
+			flags["max-age"] = s.MaxAge.String()
+		}
+	}
+	if a := c.Alerts; a != nil {
+		set("alert-url", a.URL)
+		set("alert-command", a.Command)
+		set("token-expires", a.TokenExpires)
+		flags["error-rate"] = strconv.Itoa(a.ErrorRate)
+		flags["alert-repeat"] = a.Repeat.String()
+	}
+	return flags
+}
+
+// newClient returns an API client for token that calls the config's hosts.
+func newClient(token string) *paper.APIClient {
+	c := paper.NewClient(token)
+	auth := conf.Load().Auth
+	c.APIHost, c.ContentHost = auth.APIHost, auth.ContentHost
+	return c
+}
+
+// apiToken returns the Dropbox API token.
+func apiToken() (string, error) {
+	return conf.Load().Token()
+}
+
+// githubToken returns the token publish pushes with.
+func githubToken() string {
+	if p := conf.Load().Publish; p != nil {
+		return os.Getenv(p.TokenEnv)
+	}
+	return os.Getenv("GITHUB_TOKEN")
+}
+
+// transforms builds the sync pipeline from the config's transforms.
+func transforms(c *config.Config, dir string, manifest *paper.Manifest, aliases *paper.Aliases) paper.Pipeline {
+	var p paper.Pipeline
+	for _, name := range c.Transforms {
+		switch name {
+		case "cleanup":
+			metadata := paper.DefaultMetadataLines[:len(paper.DefaultMetadataLines):len(paper.DefaultMetadataLines)]
+			for _, pattern := range c.Cleanup.Metadata {
+				metadata = append(metadata, regexp.MustCompile(pattern))
+			}
+			p = append(p, &paper.Cleanup{Rules: c.Cleanup.Rules, Metadata: metadata})
+		case "markdown":
+			p = append(p, &paper.MarkdownConverter{})
+		case "math":
+			p = append(p, &paper.Math{})
+		case "embeds":
+			e := &paper.Embeds{}
+			e.Handle("youtube", paper.YouTubeEmbed)
+			for name, cmd := range c.Embeds {
+				e.Handle(name, paper.CommandEmbed("sh", "-c", cmd, name))
+			}
+			p = append(p, e)
+		case "assets":
+			a := &paper.AssetRewriter{Dir: dir + "/assets", Base: "/assets/"}
+			if i := c.Images; i != nil {
+				a.Images = &paper.ImageOptimizer{MaxWidth: i.MaxWidth, MaxHeight: i.MaxHeight, Quality: i.Quality, Format: i.Format}
+				if i.Format == "webp" {
+					cwebp := i.CWebP
+					if cwebp == "" {
+						cwebp = "cwebp"
+					}
+					a.Images.WebP = paper.CWebP(cwebp)
+				}
+			}
+			p = append(p, a)
+		case "links":
+			p = append(p, &paper.LinkRewriter{Manifest: manifest, Aliases: aliases})
+		case "whitespace":
+			p = append(p, paper.NormalizeWhitespace)
+		case "tasks":
+			p = append(p, paper.NormalizeTaskLists)
+		case "footnotes":
+			p = append(p, &paper.Footnotes{})
+		case "toc":
+			p = append(p, &paper.TOC{})
+		case "code":
+			p = append(p, &paper.CodeLanguages{})
+		case "typography":
+			p = append(p, paper.SmartTypography)
+		case "shortcodes":
+			p = append(p, &paper.Emoji{})
+		case "emoji":
+			p = append(p, &paper.Emoji{ToUnicode: true})
+		}
+	}
+	return p
+}
+
+// configure applies the config's sync settings that have no flag.
+func configure(c *config.Config, s *paper.Syncer) {
+	s.Format = paper.ExportFormat(c.Sync.Format)
+	s.Extensions = c.Sync.Extensions
+	s.Sidecars = c.Sync.Sidecars
+	s.FrontMatter = c.Sync.FrontMatter
+	s.SpillThreshold = c.Sync.SpillThreshold
+	s.Overrides = &paper.Overrides{Dir: c.Overrides, Unmarshal: config.Unmarshal}
+	if c.Sync.Mirror {
+		s.FolderTree = &paper.FolderTree{Client: s.Client}
+	}
+	s.ReadingTime = &paper.ReadingTime{WordsPerMinute: c.Sync.WordsPerMinute, ImageSeconds: c.Sync.ImageSeconds}
+	for _, h := range c.Hooks {
+		switch {
+		case h.URL != "":
+			s.Hooks = append(s.Hooks, &paper.HTTPHook{URL: h.URL})
+		case h.Command != "":
+			s.Hooks = append(s.Hooks, &paper.CommandHook{Name: "sh", Args: []string{"-c", h.Command}, Stdout: os.Stdout, Stderr: os.Stderr})
+		case h.Git:
+			s.Hooks = append(s.Hooks, &paper.GitHook{Dir: s.Dir, Manifest: s.Manifest, Push: h.Push})
+		}
+	}
+	if l := c.Links; l != nil {
+		lc := linkChecker(c, s.Dir)
+		lc.Manifest, lc.Aliases, lc.Report = s.Manifest, s.Aliases, l.Report
+		s.Hooks = append(s.Hooks, lc)
+	}
+}
+
+// configureSite applies the config's site settings that have no flag.
+func configureSite(c *config.Config, site *paper.Site) {
+	site.Pinned = c.Site.Pinned
+	site.Overrides = &paper.Overrides{Dir: c.Overrides, Unmarshal: config.Unmarshal}
+}
+
+// linkChecker returns a link checker for dir with the config's settings.
+func linkChecker(c *config.Config, dir string) *paper.LinkChecker {
+	lc := &paper.LinkChecker{Dir: dir}
+	if l := c.Links; l != nil {
+		lc.External, lc.Concurrency, lc.Timeout = l.External, l.Concurrency, l.Timeout
+		for _, pattern := range l.Ignore {
+			lc.Ignore = append(lc.Ignore, regexp.MustCompile(pattern))
+		}
+	}
+	return lc
+}
@@ -0,0 +1,933 @@
+// Command paper syncs Dropbox Paper docs to disk and publishes them.
+//
+// Usage:
+//
+//	paper [-config paper.yaml] <command> [flags]
+//
+//	paper sync [-dir docs] [-cache dir] [-trash dir] [-ascii-slugs] [-sidecars] [-front-matter] [-mirror-folders] [-team] [-force] [-git] [-git-push] [-hook-url url] [-hook-cmd command]
+//	paper status [-dir docs]
+//	paper search [-dir docs] [-n 10] query
+//	paper duplicates [-dir docs] [-fix]
+//	paper usage [-dir docs]
+//	paper stats [-dir docs]
+//	paper links [-dir docs] [-external] [-n 8] [-format text|json]
+//	paper book [-dir docs] [-folder path] [-title title] [-order id,...] [-format markdown|html] > book.md
+//	paper trash [-trash dir] [-dir docs] [-retention 720h] list|restore doc-id|purge
+//	paper inventory [-format csv|json] [-team]
+//	paper access [-format csv|json] email
+//	paper new [-title title] template-doc-id [name=value ...]
+//	paper audit [-format csv|json] [-domains example.com] [-sensitive regexp]
+//	paper remediate [-folder path] [-public disabled] [-team-policy policy] [-dry-run] [-n 4] [-rollback file] [doc IDs]
+//	paper remediate -undo file
+//	paper build [-dir docs] [-out public] [-url https://blog.example.com] [-theme dir]
+//	paper wxr [-dir docs] [-url https://blog.example.com] > export.xml
+//	paper ghost [-dir docs] [-url https://blog.example.com] > export.json
+//	paper publish [-dir docs] [-url https://blog.example.com] [-remote origin] [-branch gh-pages] [-cname domain]
+//	paper preview [-dir docs] [-addr localhost:8080] [-interval 30s] [-theme dir] [-watch paths]
+//	paper mount [-cache dir] [-ttl 30s] [-read-only] dir
+//	paper daemon [-dir docs] [-schedule "@every 15m"] [-timeout 10m] [-out public] [-publish] [-remote origin] [-branch gh-pages] [-health-addr :8081] [-alert-url url] [-alert-command cmd]
+//
+// Settings can be kept in a YAML config file, given with -config or
+// PAPER_CONFIG, and in PAPER_* environment variables such as PAPER_TOKEN
+// and PAPER_SYNC_DIR; see package config. The environment overrides the
+// file, and flags override both.
+//
+// The API token is read from DROPBOX_API_KEY. When DROPBOX_SCOPES holds the
+// scopes it was granted, space-separated, sync checks them before starting.
+// publish pushes to HTTPS remotes with GITHUB_TOKEN, when set.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kyleconroy/paper"
+	"github.com/kyleconroy/paper/changes"
+	"github.com/kyleconroy/paper/daemon"
+	"github.com/kyleconroy/paper/fts"
+	"github.com/kyleconroy/paper/paperfs"
+	"github.com/kyleconroy/paper/server"
+)
+
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"sync":       runSync,
+	"status":     runStatus,
+	"search":     runSearch,
+	"duplicates": runDuplicates,
+	"usage":      runUsage,
+	"stats":      runStats,
+	"links":      runLinks,
+	"book":       runBook,
+	"trash":      runTrash,
+	"inventory":  runInventory,
+	"access":     runAccess,
+	"new":        runNew,
+	"audit":      runAudit,
+	"remediate":  runRemediate,
+	"build":      runBuild,
+	"wxr":        runWXR,
+	"ghost":      runGhost,
+	"publish":    runPublish,
+	"preview":    runPreview,
+	"mount":      runMount,
+	"daemon":     runDaemon,
+}
+
+func main() {
+	log.SetFlags(0)
+	args := os.Args[1:]
+	configName = os.Getenv("PAPER_CONFIG")
+	if len(args) >= 2 && args[0] == "-config" {
+		configName, args = args[1], args[2:]
+	}
+	c, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	conf.Store(c)
+	if len(args) < 1 || commands[args[0]] == nil {
+		fmt.Fprintln(os.Stderr, "usage: paper [-config paper.yaml] <sync|status|search|duplicates|usage|stats|links|book|trash|inventory|access|new|audit|remediate|build|wxr|ghost|publish|preview|mount|daemon> [flags]")
+		os.Exit(2)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if err := commands[args[0]](ctx, args[1:]); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+// indexName is the full-text index's file in the sync directory.
+const indexName = ".search-index.json"
+
+func newSyncer(dir string) (*paper.Syncer, error) {
+	c := conf.Load()
+	token, err := c.Token()
+	if err != nil {
+		return nil, err
+	}
+	scopes := os.Getenv("DROPBOX_SCOPES")
+	if c.Auth.Scopes != "" {
+		scopes = c.Auth.Scopes
+	}
+	if scopes != "" {
+		err := paper.Preflight(paper.ParseScopes(scopes),
+			"paper/docs/list", "paper/docs/list/continue", "paper/docs/download", "paper/docs/get_folder_info")
+		if err != nil {
+			return nil, err
+		}
+	}
+	manifest, err := paper.LoadManifest(dir + "/" + paper.ManifestName)
+	if err != nil {
+		return nil, err
+	}
+	index, err := fts.Open(dir + "/" + indexName)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := paper.LoadAliases(dir + "/" + paper.AliasesName)
+	if err != nil {
+		return nil, err
+	}
+	s := &paper.Syncer{
+		Client:   newClient(token),
+		Dir:      dir,
+		Folders:  true,
+		Index:    index,
+		Aliases:  aliases,
+		Manifest: manifest,
+		Pipeline: transforms(c, dir, manifest, aliases),
+	}
+	configure(c, s)
+	return s, nil
+}
+
+func runSync(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory to sync docs into")
+	hookURL := fs.String("hook-url", "", "build hook URL to POST to when docs change")
+	hookCmd := fs.String("hook-cmd", "", "shell command to run when docs change")
+	commit := fs.Bool("git", false, "commit changes to the git repository containing dir")
+	push := fs.Bool("git-push", false, "push after committing")
+	cache := fs.String("cache", "", "directory to cache exports in, so unchanged docs aren't downloaded again")
+	trash := fs.String("trash", "", "directory to keep a copy of removed docs in")
+	ascii := fs.Bool("ascii-slugs", false, "transliterate new docs' slugs to ASCII")
+	sidecars := fs.Bool("sidecars", false, "write each doc's metadata to a .meta.json file next to it")
+	front := fs.Bool("front-matter", false, "start markdown docs with YAML front matter")
+	mirror := fs.Bool("mirror-folders", false, "file docs in directories mirroring their Paper folders")
+	team := fs.Bool("team", false, "sync every team member's docs, with a team token")
+	force := fs.Bool("force", false, "download and rewrite every doc, even if it hasn't changed")
+	parseFlags(fs, args)
+	s, err := newSyncer(*dir)
+	if err != nil {
+		return err
+	}
+	if *team {
+		s.Client = &paper.TeamClient{Team: s.Client.(*paper.APIClient)}
+	}
+	if *ascii {
+		s.Manifest.Slugify = paper.SlugifyASCII
+	}
+	s.Sidecars = s.Sidecars || *sidecars
+	s.FrontMatter = s.FrontMatter || *front
+	s.Force = *force
+	if *mirror && s.FolderTree == nil {
+		s.FolderTree = &paper.FolderTree{Client: s.Client}
+	}
+	if *cache != "" {
+		s.Client = &paper.CachingClient{Client: s.Client, Cache: &paper.DiskCache{Dir: *cache}}
+	}
+	if *trash != "" {
+		s.Trash = &paper.Trash{Dir: *trash}
+	}
+	if *commit || *push {
+		s.Hooks = append(s.Hooks, &paper.GitHook{Dir: *dir, Manifest: s.Manifest, Push: *push})
+	}
+	if *hookURL != "" {
+		s.Hooks = append(s.Hooks, &paper.HTTPHook{URL: *hookURL})
+	}
+	if *hookCmd != "" {
+		s.Hooks = append(s.Hooks, &paper.CommandHook{Name: "sh", Args: []string{"-c", *hookCmd}, Stdout: os.Stdout, Stderr: os.Stderr})
+	}
+	res, err := s.Sync(ctx)
+	if res != nil {
+		log.Printf("added %d, modified %d, removed %d, unchanged %d, %s total",
+			len(res.Added), len(res.Modified), len(res.Removed), len(res.Unchanged), formatBytes(res.Size))
+		if len(res.Pending) > 0 {
+			log.Printf("interrupted: %d docs left for the next sync", len(res.Pending))
+		}
+	}
+	return err
+}
+
+// runStatus lists the docs that changed in Paper since the last sync,
+// without writing anything.
+func runStatus(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory docs are synced into")
+	parseFlags(fs, args)
+	s, err := newSyncer(*dir)
+	if err != nil {
+		return err
+	}
+	_, cs, err := changes.Detect(ctx, &paper.RevisionLister{Client: s.Client}, s.Manifest.Snapshot())
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		switch c.Kind {
+		case changes.Added:
+			fmt.Printf("added    %s (r%d)\n", c.ID, c.NewRevision)
+		case changes.Modified:
+			fmt.Printf("modified %s (r%d -> r%d)\n", c.ID, c.OldRevision, c.NewRevision)
+		case changes.Removed:
+			fmt.Printf("removed  %s (r%d)\n", c.ID, c.OldRevision)
+		}
+	}
+	return nil
+}
+
+// runSearch queries the full-text index built by sync.
+func runSearch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory docs are synced into")
+	n := fs.Int("n", 10, "maximum number of results")
+	parseFlags(fs, args)
+	index, err := fts.Open(*dir + "/" + indexName)
+	if err != nil {
+		return err
+	}
+	for _, hit := range index.Search(strings.Join(fs.Args(), " "), *n) {
+		fmt.Printf("%s\t%s\n\t%s\n", hit.ID, hit.Title, hit.Snippet)
+	}
+	return nil
+}
+
+// runDuplicates reports synced docs whose titles collide, and with -fix
+// gives them the suggested slugs.
+func runDuplicates(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("duplicates", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory docs are synced into")
+	fix := fs.Bool("fix", false, "rename colliding docs to their suggested slugs on the next sync")
+	parseFlags(fs, args)
+	name := *dir + "/" + paper.ManifestName
+	m, err := paper.LoadManifest(name)
+	if err != nil {
+		return err
+	}
+	dups := m.Duplicates()
+	for _, d := range dups {
+		fmt.Printf("%s\n", d.Slug)
+		for i, e := range d.Docs {
+			fmt.Printf("\t%s\t%s -> %s\t%s\n", e.ID, e.Slug, d.Suggested[i], strings.Join(e.Folders, "/"))
+		}
+	}
+	if !*fix {
+		return nil
+	}
+	if len(m.Disambiguate()) == 0 {
+		return nil
+	}
+	return m.Save(name)
+}
+
+// runTrash lists, restores and purges the docs kept by sync -trash.
+func runTrash(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("trash", flag.ExitOnError)
+	trash := fs.String("trash", ".paper-trash", "trash directory")
+	dir := fs.String("dir", "docs", "directory pruned docs are restored into")
+	retention := fs.Duration("retention", 30*24*time.Hour, "how long purge keeps entries")
+	parseFlags(fs, args)
+	t := &paper.Trash{Dir: *trash, Retention: *retention}
+	switch fs.Arg(0) {
+	case "", "list":
+		entries, err := t.List()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", e.Trashed.Format(time.RFC3339), e.Op, e.DocID, e.Title, e.RestoredAs)
+		}
+		return nil
+	case "restore":
+		if fs.NArg() != 2 {
+			return fmt.Errorf("usage: paper trash restore doc-id")
+		}
+		if token, err := apiToken(); err == nil {
+			t.Writer = newClient(token)
+		}
+		e, err := t.Restore(ctx, paper.DocID(fs.Arg(1)), *dir)
+		if err != nil {
+			return err
+		}
+		if e.RestoredAs != "" {
+			log.Printf("recreated %s as %s", e.DocID, e.RestoredAs)
+		} else {
+			log.Printf("restored %s to %s", e.DocID, e.Path)
+		}
+		return nil
+	case "purge":
+		n, err := t.Purge()
+		log.Printf("purged %d entries", n)
+		return err
+	default:
+		return fmt.Errorf("unknown trash command %q; want list, restore or purge", fs.Arg(0))
+	}
+}
+
+// runUsage reports the Dropbox account's space and the size of the synced
+// docs.
+func runUsage(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory docs are synced into")
+	parseFlags(fs, args)
+	token, err := apiToken()
+	if err != nil {
+		return err
+	}
+	u, err := newClient(token).GetSpaceUsage(ctx)
+	if err != nil {
+		return err
+	}
+	m, err := paper.LoadManifest(*dir + "/" + paper.ManifestName)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("dropbox\t%s used of %s (%s free)\n", formatBytes(int64(u.Used)), formatBytes(int64(u.Allocation.Allocated)), formatBytes(int64(u.Free())))
+	fmt.Printf("synced\t%s in %d docs\n", formatBytes(m.Size()), len(m.Docs))
+	return nil
+}
+
+// runStats prints word and doc counts for the synced docs as JSON.
+func runStats(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory docs are synced into")
+	parseFlags(fs, args)
+	stats, err := paper.DirStats(*dir)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stats)
+}
+
+// runLinks reports broken links in the synced docs, failing if there are
+// any.
+func runLinks(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("links", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory docs are synced into")
+	external := fs.Bool("external", false, "also request external URLs")
+	n := fs.Int("n", 0, "external URLs to request at once (default 8)")
+	format := fs.String("format", "text", "output format, text or json")
+	parseFlags(fs, args)
+	lc := linkChecker(conf.Load(), *dir)
+	lc.External = lc.External || *external
+	if *n > 0 {
+		lc.Concurrency = *n
+	}
+	aliases, err := paper.LoadAliases(*dir + "/" + paper.AliasesName)
+	if err != nil {
+		return err
+	}
+	lc.Aliases = aliases
+	report, err := lc.Check(ctx)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	case "text":
+		for _, b := range report.Broken {
+			fmt.Printf("%s\t%s\t%s\n", b.DocID, b.URL, b.Reason)
+		}
+	default:
+		return fmt.Errorf("unknown format %q; want text or json", *format)
+	}
+	if len(report.Broken) > 0 {
+		return fmt.Errorf("%d broken links in %d checked", len(report.Broken), report.Checked)
+	}
+	return nil
+}
+
+// runBook writes the docs in a folder as one document with a table of
+// contents, for pandoc to make a PDF or EPUB of, or to print.
+func runBook(ctx context.Context, args []string) error {
+	c := conf.Load().Book
+	fs := flag.NewFlagSet("book", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory docs are synced into")
+	folder := fs.String("folder", c.Folder, "folder to include, \"/\"-separated (default every doc)")
+	title := fs.String("title", c.Title, "book title (default book.title, else site.title)")
+	order := fs.String("order", strings.Join(c.Order, ","), "comma-separated IDs or slugs of the first docs; the rest follow by date")
+	format := fs.String("format", c.Format, "output format, markdown or html (default markdown)")
+	parseFlags(fs, args)
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if c.Title != "" && !given["title"] {
+		*title = c.Title // rather than the site's, which parseFlags fills in
+	}
+	b := &paper.Book{Dir: *dir, Title: *title, Folder: *folder}
+	switch *format {
+	case "", "markdown":
+		b.Format = paper.ExportFormatMarkdown
+	case "html":
+		b.Format = paper.ExportFormatHTML
+	default:
+		return fmt.Errorf("unknown format %q; want markdown or html", *format)
+	}
+	if *order == strings.Join(c.Order, ",") {
+		b.Order = c.Order // titles may contain commas
+	} else {
+		for _, key := range strings.Split(*order, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				b.Order = append(b.Order, key)
+			}
+		}
+	}
+	out, err := b.Build()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// formatBytes formats a size with a binary unit, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func runInventory(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("inventory", flag.ExitOnError)
+	format := fs.String("format", "csv", "output format, csv or json")
+	team := fs.Bool("team", false, "list every team member's docs, with a team token")
+	parseFlags(fs, args)
+	token, err := apiToken()
+	if err != nil {
+		return err
+	}
+	if *team {
+		tc := &paper.TeamClient{Team: newClient(token)}
+		return tc.Inventory(ctx, os.Stdout, paper.InventoryFormat(*format))
+	}
+	return newClient(token).Inventory(ctx, os.Stdout, paper.InventoryFormat(*format))
+}
+
+// runAccess lists every doc and folder a person can see.
+func runAccess(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("access", flag.ExitOnError)
+	format := fs.String("format", "csv", "output format, csv or json")
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: paper access [-format csv|json] email")
+	}
+	token, err := apiToken()
+	if err != nil {
+		return err
+	}
+	return newClient(token).AccessReport(ctx, fs.Arg(0), os.Stdout, paper.InventoryFormat(*format))
+}
+
+// runNew creates a doc from a template doc, replacing its {{name}}
+// placeholders with the values given, and prints the new doc's ID.
+// {{date}} is today's date unless it's given.
+func runNew(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	title := fs.String("title", "", "new doc's title (default the template's)")
+	parseFlags(fs, args)
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if !given["title"] {
+		*title = "" // not the site's, which parseFlags fills in
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("usage: paper new [-title title] template-doc-id [name=value ...]")
+	}
+	vars := map[string]string{"date": time.Now().Format("2006-01-02")}
+	for _, arg := range fs.Args()[1:] {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("variable %q: want name=value", arg)
+		}
+		vars[name] = value
+	}
+	token, err := apiToken()
+	if err != nil {
+		return err
+	}
+	res, err := newClient(token).CreateFromTemplate(ctx, paper.DocID(fs.Arg(0)), vars, *title)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%s\n", res.DocID, res.Title)
+	return nil
+}
+
+// runAudit reports risky sharing: public links, external shares and
+// sensitive docs in team-visible folders.
+func runAudit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	format := fs.String("format", "csv", "output format, csv or json")
+	domains := fs.String("domains", "", "comma-separated email domains of the team")
+	sensitive := fs.String("sensitive", "", "regexp matching sensitive doc and folder titles")
+	parseFlags(fs, args)
+	token, err := apiToken()
+	if err != nil {
+		return err
+	}
+	a := &paper.Audit{Client: newClient(token)}
+	if *domains != "" {
+		a.TeamDomains = strings.Split(*domains, ",")
+	}
+	if *sensitive != "" {
+		re, err := regexp.Compile(*sensitive)
+		if err != nil {
+			return err
+		}
+		a.Sensitive = []*regexp.Regexp{re}
+	}
+	return a.Run(ctx, os.Stdout, paper.InventoryFormat(*format))
+}
+
+// runRemediate applies a sharing policy to docs given by ID or folder,
+// recording the previous policies so the change can be undone.
+func runRemediate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("remediate", flag.ExitOnError)
+	folder := fs.String("folder", "", "apply to every doc in the folder with this ID or \"/\"-separated path")
+	public := fs.String("public", "", "public sharing policy to set, e.g. disabled or invite_only")
+	team := fs.String("team-policy", "", "team sharing policy to set")
+	dryRun := fs.Bool("dry-run", false, "print the changes without making them")
+	n := fs.Int("n", 4, "docs to update at once")
+	rollback := fs.String("rollback", "sharing-rollback.json", "file to record previous policies in")
+	undo := fs.String("undo", "", "restore the policies recorded in this rollback file")
+	parseFlags(fs, args)
+	token, err := apiToken()
+	if err != nil {
+		return err
+	}
+	c := newClient(token)
+	r := &paper.Remediation{Client: c, Concurrency: *n, DryRun: *dryRun}
+
+	var changes []*paper.PolicyChange
+	if *undo != "" {
+		prev, err := paper.LoadRollback(*undo)
+		if err != nil {
+			return err
+		}
+		changes, err = r.Rollback(ctx, prev)
+		printChanges(changes)
+		return err
+	}
+	policy := paper.SharingPolicy{
+		PublicSharingPolicy: paper.SharingPublicPolicyType(*public),
+		TeamSharingPolicy:   paper.SharingPublicPolicyType(*team),
+	}
+	if policy == (paper.SharingPolicy{}) {
+		return fmt.Errorf("set -public or -team-policy")
+	}
+	var ids []paper.DocID
+	for _, arg := range fs.Args() {
+		id, err := paper.ParseDocID(arg)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if *folder != "" {
+		in, err := paper.ListDocsInFolder(ctx, c, *folder)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, in...)
+	}
+	changes, err = r.Apply(ctx, ids, policy)
+	printChanges(changes)
+	if !*dryRun && len(changes) > 0 {
+		if err := paper.SaveRollback(*rollback, changes); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func printChanges(changes []*paper.PolicyChange) {
+	for _, c := range changes {
+		line := fmt.Sprintf("%s\tpublic %s -> %s\tteam %s -> %s", c.DocID,
+			c.Before.PublicSharingPolicy, c.After.PublicSharingPolicy,
+			c.Before.TeamSharingPolicy, c.After.TeamSharingPolicy)
+		if c.Error != "" {
+			line += "\terror: " + c.Error
+		}
+		fmt.Println(line)
+	}
+}
+
+func runBuild(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory docs were synced into")
+	out := fs.String("out", "", "output directory (defaults to -dir)")
+	url := fs.String("url", "", "base URL the site is published at")
+	title := fs.String("title", "", "site title")
+	theme := fs.String("theme", "", "theme directory layered over the default theme")
+	pageSize := fs.Int("page-size", 0, "posts per index page, 0 for a single page")
+	search := fs.Bool("search", false, "add a search page")
+	parseFlags(fs, args)
+	site := &paper.Site{
+		Dir:        *dir,
+		Out:        *out,
+		BaseURL:    *url,
+		Title:      *title,
+		Theme:      *theme,
+		PageSize:   *pageSize,
+		SearchPage: *search,
+	}
+	configureSite(conf.Load(), site)
+	return site.Build()
+}
+
+// runWXR writes a WordPress import file for the synced docs to stdout.
+func runWXR(ctx context.Context, args []string) error {
+	return runExport("wxr", args, (*paper.Site).WXR)
+}
+
+// runGhost writes a Ghost import file for the synced docs to stdout.
+func runGhost(ctx context.Context, args []string) error {
+	return runExport("ghost", args, (*paper.Site).Ghost)
+}
+
+func runExport(name string, args []string, export func(*paper.Site, []*paper.Post) ([]byte, error)) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory docs were synced into")
+	url := fs.String("url", "", "base URL assets are published at")
+	title := fs.String("title", "", "site title")
+	parseFlags(fs, args)
+	site := &paper.Site{Dir: *dir, BaseURL: *url, Title: *title}
+	configureSite(conf.Load(), site)
+	posts, err := site.Posts()
+	if err != nil {
+		return err
+	}
+	blob, err := export(site, posts)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(blob)
+	return err
+}
+
+func runPublish(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory to sync docs into")
+	url := fs.String("url", "", "base URL the site is published at")
+	title := fs.String("title", "", "site title")
+	theme := fs.String("theme", "", "theme directory layered over the default theme")
+	remote := fs.String("remote", "origin", "git remote or URL to push to")
+	branch := fs.String("branch", "gh-pages", "branch to push the site to")
+	cname := fs.String("cname", "", "custom domain for GitHub Pages")
+	parseFlags(fs, args)
+	s, err := newSyncer(*dir)
+	if err != nil {
+		return err
+	}
+	site := &paper.Site{Dir: *dir, BaseURL: *url, Title: *title, Theme: *theme}
+	configureSite(conf.Load(), site)
+	p := &paper.Publisher{
+		Syncer: s,
+		Site:   site,
+		Remote: *remote,
+		Branch: *branch,
+		Token:  githubToken(),
+		CNAME:  *cname,
+	}
+	return p.Publish(ctx)
+}
+
+func runPreview(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory to sync docs into")
+	addr := fs.String("addr", "localhost:8080", "address to serve the preview on")
+	interval := fs.Duration("interval", 30*time.Second, "least time between checks for changes in Paper")
+	theme := fs.String("theme", "", "theme directory layered over the default theme")
+	watch := fs.String("watch", "", "comma-separated local paths to watch for changes")
+	parseFlags(fs, args)
+	s, err := newSyncer(*dir)
+	if err != nil {
+		return err
+	}
+	p := &server.Preview{Syncer: s, Interval: *interval, Theme: *theme, Logf: log.Printf}
+	if *watch != "" {
+		p.Watch = strings.Split(*watch, ",")
+	}
+	log.Printf("previewing %s on http://%s", *dir, *addr)
+	return p.ListenAndServe(ctx, *addr)
+}
+
+// runMount mounts Paper as a FUSE filesystem until it's interrupted or
+// unmounted.
+func runMount(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("mount", flag.ExitOnError)
+	cache := fs.String("cache", "", "directory to cache exports in, so unchanged docs aren't downloaded again")
+	ttl := fs.Duration("ttl", 30*time.Second, "how long to cache the list of docs")
+	readOnly := fs.Bool("read-only", false, "don't write changes back to Paper")
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: paper mount [-cache dir] [-ttl 30s] [-read-only] dir")
+	}
+	token, err := apiToken()
+	if err != nil {
+		return err
+	}
+	client := newClient(token)
+	f := &paperfs.FS{Client: client, TTL: *ttl}
+	if !*readOnly {
+		f.Writer = client
+	}
+	if *cache != "" {
+		f.Cache = &paper.DiskCache{Dir: *cache}
+	}
+	log.Printf("mounting Paper at %s", fs.Arg(0))
+	return paperfs.Mount(ctx, fs.Arg(0), f)
+}
+
+// runDaemon syncs on a schedule, rebuilding or publishing the site whenever
+// docs change, and logs a JSON summary of each run to stdout. SIGHUP, or a
+// POST to /reload on the health address, reloads the config and flags. The
+// health address also serves Prometheus metrics on /metrics and expvar on
+// /debug/vars.
+func runDaemon(ctx context.Context, args []string) error {
+	metrics := &paper.Metrics{}
+	expvar.Publish("paper", expvar.Func(func() interface{} { return metrics.Snapshot() }))
+	job, opts, err := daemonJob(args, metrics)
+	if err != nil {
+		return err
+	}
+	token, err := apiToken()
+	if err != nil {
+		return err
+	}
+	d := &daemon.Daemon{Jobs: []*daemon.Job{job}, Log: os.Stdout}
+	if a := opts.alerts; a != nil {
+		a.Metrics = metrics
+		d.OnRun = func(s daemon.Summary) {
+			var err error
+			switch s.Status {
+			case daemon.StatusFailed, daemon.StatusTimeout:
+				err = errors.New(s.Error)
+			case daemon.StatusOK:
+			default:
+				return
+			}
+			if err := a.Check(ctx, err); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+	var mu sync.Mutex
+	reload := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		c, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		prev := conf.Swap(c)
+		job, _, err := daemonJob(args, metrics)
+		if err != nil {
+			conf.Store(prev)
+			return err
+		}
+		log.Printf("reloaded config")
+		return d.Reload([]*daemon.Job{job})
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for range hup {
+			if err := reload(); err != nil {
+				log.Printf("reload: %v", err)
+			}
+		}
+	}()
+	if opts.healthAddr != "" {
+		health := &daemon.Health{Daemon: d, Ping: newClient(token).Ping, MaxAge: opts.maxAge}
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", health)
+		mux.Handle("/readyz", health)
+		mux.Handle("/reload", daemon.ReloadHandler(reload))
+		mux.Handle("/metrics", metrics)
+		mux.Handle("/debug/vars", expvar.Handler())
+		srv := &http.Server{Addr: opts.healthAddr, Handler: mux}
+		go func() {
+			if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+		defer srv.Close()
+	}
+	return d.Run(ctx)
+}
+
+// daemonOptions are the daemon's settings that take a restart to change.
+type daemonOptions struct {
+	healthAddr string
+	maxAge     time.Duration
+	alerts     *paper.Alerts // nil without -alert-url or -alert-command
+}
+
+// daemonJob builds the daemon's sync job from its flags and the current
+// config. The syncer is created on the first run, so that a job replacing
+// one that's still running reads the state it leaves behind.
+func daemonJob(args []string, metrics *paper.Metrics) (*daemon.Job, *daemonOptions, error) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	dir := fs.String("dir", "docs", "directory to sync docs into")
+	schedule := fs.String("schedule", "@every 15m", "cron expression or @every interval to sync on")
+	timeout := fs.Duration("timeout", 10*time.Minute, "longest a run may take")
+	out := fs.String("out", "", "directory to build the site into (defaults to -dir)")
+	url := fs.String("url", "", "base URL the site is published at")
+	title := fs.String("title", "", "site title")
+	theme := fs.String("theme", "", "theme directory layered over the default theme")
+	publish := fs.Bool("publish", false, "push the site to a git branch instead of building it into -out")
+	remote := fs.String("remote", "origin", "git remote or URL to push to")
+	branch := fs.String("branch", "gh-pages", "branch to push the site to")
+	cname := fs.String("cname", "", "custom domain for GitHub Pages")
+	healthAddr := fs.String("health-addr", "", "address to serve /healthz, /readyz, /reload and /metrics on")
+	maxAge := fs.Duration("max-age", 0, "how old the last successful sync may be before /readyz fails")
+	alertURL := fs.String("alert-url", "", "URL to POST alerts to as JSON")
+	alertCmd := fs.String("alert-command", "", "shell command to run for each alert")
+	errorRate := fs.Int("error-rate", 20, "percent of API requests that may fail between syncs before an alert")
+	tokenExpires := fs.String("token-expires", "", "RFC 3339 time the API token expires at, to alert beforehand")
+	repeat := fs.Duration("alert-repeat", time.Hour, "how long to wait before repeating an alert")
+	parseFlags(fs, args)
+	sched, err := daemon.Parse(*schedule)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts := &daemonOptions{healthAddr: *healthAddr, maxAge: *maxAge}
+	if *alertURL != "" || *alertCmd != "" {
+		opts.alerts = &paper.Alerts{ErrorRate: float64(*errorRate) / 100, Repeat: *repeat}
+		if *alertURL != "" {
+			opts.alerts.Alerters = append(opts.alerts.Alerters, &paper.HTTPAlerter{URL: *alertURL})
+		}
+		if *alertCmd != "" {
+			opts.alerts.Alerters = append(opts.alerts.Alerters, &paper.CommandAlerter{Name: "sh", Args: []string{"-c", *alertCmd}, Stdout: os.Stdout, Stderr: os.Stderr})
+		}
+		if *tokenExpires != "" {
+			if opts.alerts.TokenExpires, err = time.Parse(time.RFC3339, *tokenExpires); err != nil {
+				return nil, nil, fmt.Errorf("-token-expires: %w", err)
+			}
+		}
+	}
+	if _, err := apiToken(); err != nil {
+		return nil, nil, err
+	}
+	site := &paper.Site{Dir: *dir, Out: *out, BaseURL: *url, Title: *title, Theme: *theme}
+	configureSite(conf.Load(), site)
+	// The daemon syncs itself, so that unchanged docs don't cause a push.
+	p := &paper.Publisher{
+		Site:   site,
+		Remote: *remote,
+		Branch: *branch,
+		Token:  githubToken(),
+		CNAME:  *cname,
+	}
+	var s *paper.Syncer
+	job := &daemon.Job{
+		Name:        "sync",
+		Schedule:    sched,
+		Timeout:     *timeout,
+		Immediately: true,
+		Run: func(ctx context.Context) (string, error) {
+			if s == nil {
+				var err error
+				if s, err = newSyncer(*dir); err != nil {
+					return "", err
+				}
+				s.Metrics = metrics
+				metrics.Instrument(s.Client.(*paper.APIClient))
+			}
+			res, err := s.Sync(ctx)
+			if res == nil {
+				return "", err
+			}
+			detail := fmt.Sprintf("added %d, modified %d, removed %d", len(res.Added), len(res.Modified), len(res.Removed))
+			if err != nil {
+				return fmt.Sprintf("%s, %d pending", detail, len(res.Pending)), err
+			}
+			if res.Empty() {
+				return detail, nil
+			}
+			if *publish {
+				return detail + ", published", p.Publish(ctx)
+			}
+			return detail + ", built", site.Build()
+		},
+	}
+	return job, opts, nil
+}
@@ -0,0 +1,73 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// CoalescingClient wraps a Client so that concurrent downloads of the same
+// export share a single request. Callers that arrive while a download is in
+// flight wait for it and get their own copy of its result, retrying if it
+// was only canceled on the first caller's behalf.
+type CoalescingClient struct {
+	Client
+
+	mu      sync.Mutex
+	flights map[PaperDocExport]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	waiters int
+	res     *PaperDocExportResult
+	content []byte
+	err     error
+}
+
+// ListDocsContinue continues a listing with Client.
+func (c *CoalescingClient) ListDocsContinue(ctx context.Context, in *ListPaperDocsContinueArgs) (*ListPaperDocsResponse, error) {
+	return listDocsContinue(ctx, c.Client, in)
+}
+
+func (c *CoalescingClient) DownloadDoc(ctx context.Context, in *PaperDocExport) (*PaperDocExportResult, []byte, error) {
+	key := *in
+	c.mu.Lock()
+	if f, ok := c.flights[key]; ok {
+		f.waiters++
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if (errors.Is(f.err, context.Canceled) || errors.Is(f.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+			return c.DownloadDoc(ctx, in)
+		}
+		if f.err != nil {
+			return nil, nil, f.err
+		}
+		res := *f.res
+		return &res, append([]byte(nil), f.content...), nil
+	}
+	f := &flight{done: make(chan struct{})}
+	if c.flights == nil {
+		c.flights = map[PaperDocExport]*flight{}
+	}
+	c.flights[key] = f
+	c.mu.Unlock()
+
+	f.res, f.content, f.err = c.Client.DownloadDoc(ctx, in)
+	c.mu.Lock()
+	delete(c.flights, key)
+	shared := f.waiters > 0
+	c.mu.Unlock()
+	close(f.done)
+	if shared && f.err == nil {
+		// Waiters copy f.content, so it mustn't be handed out to be
+		// modified.
+		res := *f.res
+		return &res, append([]byte(nil), f.content...), nil
+	}
+	return f.res, f.content, f.err
+}
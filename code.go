@@ -0,0 +1,202 @@
+package paper
+
+import (
+	"encoding/json"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// CodeLanguages normalizes the language hints on code blocks so syntax
+// highlighters downstream recognize them. Paper's exports often drop the
+// hint or carry it in an unusual form.
+type CodeLanguages struct {
+	// Aliases maps lowercased hints to canonical names. Entries override
+	// DefaultLanguageAliases.
+	Aliases map[string]string
+
+	// Detect guesses a language for blocks without a hint.
+	Detect bool
+
+	// Default is used for blocks that have no hint and can't be detected.
+	Default string
+}
+
+var DefaultLanguageAliases = map[string]string{
+	"golang":     "go",
+	"js":         "javascript",
+	"node":       "javascript",
+	"jsx":        "javascript",
+	"ts":         "typescript",
+	"py":         "python",
+	"python3":    "python",
+	"py3":        "python",
+	"rb":         "ruby",
+	"sh":         "bash",
+	"shell":      "bash",
+	"zsh":        "bash",
+	"console":    "bash",
+	"yml":        "yaml",
+	"c++":        "cpp",
+	"cc":         "cpp",
+	"h":          "c",
+	"cs":         "csharp",
+	"c#":         "csharp",
+	"rs":         "rust",
+	"kt":         "kotlin",
+	"md":         "markdown",
+	"htm":        "html",
+	"xhtml":      "html",
+	"postgres":   "sql",
+	"postgresql": "sql",
+	"mysql":      "sql",
+	"plain":      "text",
+	"plaintext":  "text",
+	"txt":        "text",
+}
+
+func (c *CodeLanguages) Transform(doc *Doc) error {
+	if doc.Format == ExportFormatHTML {
+		doc.Content = []byte(c.rewriteHTML(string(doc.Content)))
+		return nil
+	}
+	lines := strings.Split(string(doc.Content), "\n")
+	for i := 0; i < len(lines); i++ {
+		m := fenceOpen.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		indent, fence, info := m[1], m[2], strings.TrimSpace(m[3])
+		end := i + 1
+		for end < len(lines) && !isFenceClose(lines[end], fence) {
+			end++
+		}
+		lang, rest := info, ""
+		if sp := strings.IndexAny(info, " \t"); sp >= 0 {
+			lang, rest = info[:sp], info[sp:]
+		}
+		lang = c.Normalize(lang)
+		if lang == "" {
+			lang = c.guess(strings.Join(lines[i+1:min(end, len(lines))], "\n"))
+		}
+		lines[i] = indent + fence + lang + rest
+		i = end
+	}
+	doc.Content = []byte(strings.Join(lines, "\n"))
+	return nil
+}
+
+// Normalize maps a single language hint to its canonical name.
+func (c *CodeLanguages) Normalize(hint string) string {
+	hint = strings.ToLower(strings.TrimSpace(hint))
+	hint = strings.Trim(hint, "{}")
+	hint = strings.TrimPrefix(hint, ".")
+	for _, prefix := range []string{"language-", "lang-"} {
+		hint = strings.TrimPrefix(hint, prefix)
+	}
+	if alias, ok := c.Aliases[hint]; ok {
+		return alias
+	}
+	if alias, ok := DefaultLanguageAliases[hint]; ok {
+		return alias
+	}
+	return hint
+}
+
+func (c *CodeLanguages) guess(code string) string {
+	if c.Detect {
+		if lang := DetectLanguage(code); lang != "" {
+			return lang
+		}
+	}
+	return c.Default
+}
+
+var (
+	fenceOpen = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})(.*)$")
+
+	languageHints = []struct {
+		lang string
+		re   *regexp.Regexp
+	}{
+		{"go", regexp.MustCompile(`(?m)^package \w+$|^func (\(\w+ \*?\w+\) )?\w+\(|:= `)},
+		{"rust", regexp.MustCompile(`(?m)^\s*(pub )?fn \w+|let mut |^use \w+::`)},
+		{"python", regexp.MustCompile(`(?m)^\s*def \w+\(.*\):\s*$|^\s*(from \w+ )?import \w+$|^\s*class \w+(\(.*\))?:\s*$`)},
+		{"java", regexp.MustCompile(`(?m)^\s*public (static )?(class|void|interface) `)},
+		{"sql", regexp.MustCompile(`(?im)^\s*(select .+ from|insert into|create table|update \w+ set)`)},
+		{"bash", regexp.MustCompile(`(?m)^#!/bin/(ba|z)?sh|^\$ \w+`)},
+		{"html", regexp.MustCompile(`(?i)^\s*<(!doctype|html|div|p|span|body)[\s>]`)},
+		{"javascript", regexp.MustCompile(`(?m)^\s*(const|let|var) \w+ = |function\s*\w*\s*\(|=> \{|console\.log\(`)},
+		{"css", regexp.MustCompile(`(?m)^\s*[.#]?[\w-]+\s*\{\s*$|^\s*[\w-]+:\s*[^;]+;\s*$`)},
+	}
+)
+
+// DetectLanguage guesses the language of a code snippet from a handful of
+// telltale patterns. It returns "" when nothing matches.
+func DetectLanguage(code string) string {
+	trimmed := strings.TrimSpace(code)
+	if trimmed == "" {
+		return ""
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+	for _, h := range languageHints {
+		if h.re.MatchString(code) {
+			return h.lang
+		}
+	}
+	return ""
+}
+
+func isFenceClose(line, fence string) bool {
+	line = strings.TrimRight(strings.TrimLeft(line, " "), " \t")
+	return strings.HasPrefix(line, fence) && strings.Trim(line, fence[:1]) == ""
+}
+
+// rewriteHTML normalizes language-* classes on <pre> and <code> elements,
+// adding one to blocks without a hint when a language can be guessed.
+func (c *CodeLanguages) rewriteHTML(src string) string {
+	toks := tokenizeHTML(src)
+	var b strings.Builder
+	for i, tok := range toks {
+		if tok.Type == htmlStartTag && tok.Data == "code" && i > 0 && toks[i-1].Type == htmlStartTag && toks[i-1].Data == "pre" {
+			var code strings.Builder
+			for j := i + 1; j < len(toks) && !(toks[j].Type == htmlEndTag && toks[j].Data == "code"); j++ {
+				if toks[j].Type == htmlText {
+					code.WriteString(toks[j].Data)
+				}
+			}
+			tok.Attrs = c.classes(tok.Attrs, html.UnescapeString(code.String()))
+		}
+		b.WriteString(tok.String())
+	}
+	return b.String()
+}
+
+func (c *CodeLanguages) classes(attrs []htmlAttr, code string) []htmlAttr {
+	for i, a := range attrs {
+		if a.Key != "class" {
+			continue
+		}
+		classes := strings.Fields(a.Val)
+		for j, class := range classes {
+			if strings.HasPrefix(class, "language-") || strings.HasPrefix(class, "lang-") {
+				classes[j] = "language-" + c.Normalize(class)
+				attrs[i].Val = strings.Join(classes, " ")
+				return attrs
+			}
+		}
+	}
+	lang := c.guess(code)
+	if lang == "" {
+		return attrs
+	}
+	for i, a := range attrs {
+		if a.Key == "class" {
+			attrs[i].Val = strings.TrimSpace(a.Val + " language-" + lang)
+			return attrs
+		}
+	}
+	return append(attrs, htmlAttr{Key: "class", Val: "language-" + lang})
+}
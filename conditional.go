@@ -0,0 +1,62 @@
+package paper
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotModified is returned by DownloadDocIfChanged when the doc is still
+// at the known revision.
+var ErrNotModified = errors.New("paper: doc not modified")
+
+// ConditionalClient is implemented by clients that can skip transferring
+// docs that haven't changed.
+type ConditionalClient interface {
+	DownloadDocIfChanged(ctx context.Context, in *PaperDocExport, knownRevision int64) (*PaperDocExportResult, []byte, error)
+}
+
+const downloadURL = "https://api.dropboxapi.com/2/paper/docs/download"
+
+// DownloadDocIfChanged downloads a doc unless it's still at knownRevision,
+// in which case it returns the doc's metadata and ErrNotModified.
+//
+// The API has no metadata-only call that reports revisions, so this starts
+// a download, reads the revision from the response headers and hangs up
+// before the content is transferred.
+func (c *APIClient) DownloadDocIfChanged(ctx context.Context, in *PaperDocExport, knownRevision int64) (*PaperDocExportResult, []byte, error) {
+	var out PaperDocExportResult
+	resp, err := c.open(ctx, downloadURL, in, &out)
+	if err != nil {
+		return nil, nil, err
+	}
+	if out.Revision == knownRevision {
+		// Closing the body unread abandons the rest of the transfer.
+		resp.Body.Close()
+		return &out, nil, ErrNotModified
+	}
+	defer resp.Body.Close()
+	blob, err := readBody(resp)
+	return &out, blob, err
+}
+
+// Metadata returns a doc's title, owner and current revision without
+// transferring its content, as DownloadDocIfChanged does.
+func (c *APIClient) Metadata(ctx context.Context, id DocID) (*PaperDocExportResult, error) {
+	var out PaperDocExportResult
+	resp, err := c.open(ctx, downloadURL, &PaperDocExport{DocID: id, Format: ExportFormatMarkdown}, &out)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &out, nil
+}
+
+// Revision returns a doc's current revision without transferring its
+// content.
+func (c *APIClient) Revision(ctx context.Context, docID DocID) (int64, error) {
+	res, err := c.Metadata(ctx, docID)
+	if err != nil {
+		return 0, err
+	}
+	return res.Revision, nil
+}
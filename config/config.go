@@ -0,0 +1,525 @@
+// Package config loads the YAML file that configures paper's commands and
+// daemon in one place: how to authenticate, where docs sync to and how
+// they're transformed, the site, publishing, hooks and the schedule.
+//
+//	auth:
+//	  token_env: DROPBOX_API_KEY
+//	sync:
+//	  dir: docs
+//	  cache: .paper-cache
+//	transforms: [assets, links, whitespace, code]
+//	site:
+//	  url: https://blog.example.com
+//	  title: Notes
+//	publish:
+//	  branch: gh-pages
+//	hooks:
+//	  - url: https://api.netlify.com/build_hooks/abc
+//	schedule:
+//	  spec: "@every 15m"
+//	  health_addr: ":8081"
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kyleconroy/paper/daemon"
+)
+
+type Config struct {
+	Auth       Auth     `yaml:"auth"`
+	Sync       Sync     `yaml:"sync"`
+	Transforms []string `yaml:"transforms"` // defaults to DefaultTransforms
+
+	// Embeds maps shortcode names to shell commands that expand them, for
+	// the embeds transform. The shortcode's arguments are passed as $1,
+	// $2 and so on.
+	Embeds map[string]string `yaml:"embeds"`
+
+	// Overrides is the directory of per-doc override files, <doc ID>.yaml,
+	// defaulting to overrides; see paper.Override.
+	Overrides string `yaml:"overrides"`
+
+	Cleanup Cleanup `yaml:"cleanup"` // for the cleanup transform
+	Images  *Images `yaml:"images"`  // for the assets transform; nil leaves images as they are
+
+	Site Site `yaml:"site"`
+
+	// Publish is nil when the site isn't pushed. An empty "publish:"
+	// pushes with the defaults.
+	Publish  *Publish  `yaml:"publish"`
+	Hooks    []Hook    `yaml:"hooks"`
+	Schedule *Schedule `yaml:"schedule"` // for the daemon
+	Alerts   *Alerts   `yaml:"alerts"`   // for the daemon; nil sends none
+	Links    *Links    `yaml:"links"`    // nil checks none
+	Book     Book      `yaml:"book"`     // for paper book
+
+	file  string
+	lines map[string]int    // line each setting in the file is on
+	env   map[string]string // variable each setting from the environment came from
+}
+
+type Auth struct {
+	// Token is the Dropbox API token. It's better kept out of the file and
+	// read from the environment variable named by TokenEnv, which defaults
+	// to DROPBOX_API_KEY.
+	Token    string `yaml:"token"`
+	TokenEnv string `yaml:"token_env"`
+
+	// Scopes lists the scopes the token was granted, space-separated, to
+	// check before syncing.
+	Scopes string `yaml:"scopes"`
+
+	Team bool `yaml:"team"` // the token is a team token
+
+	// APIHost and ContentHost replace the Dropbox API's hosts for RPC and
+	// content calls, e.g. to go through proxies; see paper.APIClient.
+	APIHost     string `yaml:"api_host"`
+	ContentHost string `yaml:"content_host"`
+}
+
+type Sync struct {
+	Dir        string            `yaml:"dir"`    // defaults to docs
+	Format     string            `yaml:"format"` // markdown or html, defaults to markdown
+	Cache      string            `yaml:"cache"`
+	Trash      string            `yaml:"trash"` // keep removed docs here, see paper.Trash
+	ASCIISlugs bool              `yaml:"ascii_slugs"`
+	Sidecars   bool              `yaml:"sidecars"`       // write a .meta.json next to each doc
+	Mirror     bool              `yaml:"mirror_folders"` // file docs in directories named for their Paper folders
+	Extensions map[string]string `yaml:"extensions"`     // MIME type to extension
+
+	// Reading time estimates, see paper.ReadingTime.
+	WordsPerMinute int `yaml:"words_per_minute"`
+	ImageSeconds   int `yaml:"image_seconds"`
+
+	FrontMatter bool `yaml:"front_matter"` // start markdown docs with YAML front matter
+
+	// SpillThreshold keeps downloaded docs larger than this many bytes on
+	// disk until they're written; see paper.Syncer.
+	SpillThreshold int64 `yaml:"spill_threshold"`
+}
+
+type Site struct {
+	Out      string `yaml:"out"`
+	URL      string `yaml:"url"`
+	Title    string `yaml:"title"`
+	Theme    string `yaml:"theme"`
+	PageSize int    `yaml:"page_size"`
+	Search   bool   `yaml:"search"`
+
+	// Pinned lists docs, by ID or slug, shown first in the index and
+	// feeds.
+	Pinned []string `yaml:"pinned"`
+}
+
+type Publish struct {
+	Remote   string `yaml:"remote"` // defaults to origin
+	Branch   string `yaml:"branch"` // defaults to gh-pages
+	CNAME    string `yaml:"cname"`
+	TokenEnv string `yaml:"token_env"` // defaults to GITHUB_TOKEN
+}
+
+// Hook is run after a sync that changed something. Set exactly one of URL,
+// Command or Git.
+type Hook struct {
+	URL     string `yaml:"url"`     // POSTed to
+	Command string `yaml:"command"` // run with sh -c
+	Git     bool   `yaml:"git"`     // commit the sync directory
+	Push    bool   `yaml:"push"`    // and push, with Git
+}
+
+type Schedule struct {
+	Spec       string        `yaml:"spec"`    // cron expression or "@every 15m", the default
+	Timeout    time.Duration `yaml:"timeout"` // defaults to 10m
+	HealthAddr string        `yaml:"health_addr"`
+	MaxAge     time.Duration `yaml:"max_age"`
+}
+
+// Alerts are sent when a sync fails, too many API requests fail or the
+// token is about to expire. Set url, command or both.
+type Alerts struct {
+	URL          string        `yaml:"url"`           // POSTed each alert as JSON
+	Command      string        `yaml:"command"`       // run with sh -c for each alert
+	ErrorRate    int           `yaml:"error_rate"`    // percent of API requests failing, defaults to 20
+	TokenExpires string        `yaml:"token_expires"` // RFC 3339 time the token expires at
+	Repeat       time.Duration `yaml:"repeat"`        // defaults to 1h
+}
+
+// Links checks the synced docs' links after each sync that changed
+// something, saving a report; see paper.LinkChecker.
+type Links struct {
+	External    bool          `yaml:"external"`    // also request external URLs
+	Concurrency int           `yaml:"concurrency"` // external URLs at once, defaults to 8
+	Timeout     time.Duration `yaml:"timeout"`     // per URL, defaults to 10s
+	Report      string        `yaml:"report"`      // defaults to .link-report.json in the sync directory
+	Ignore      []string      `yaml:"ignore"`      // patterns of links not to check
+}
+
+// Book combines a folder's docs into one document; see paper.Book.
+type Book struct {
+	Title  string   `yaml:"title"`
+	Folder string   `yaml:"folder"` // "/"-separated; empty is every doc
+	Order  []string `yaml:"order"`  // IDs, slugs or titles of the first docs; the rest by date
+	Format string   `yaml:"format"` // markdown or html, defaults to markdown
+}
+
+// Images optimizes the images the assets transform downloads; see
+// paper.ImageOptimizer.
+type Images struct {
+	MaxWidth  int    `yaml:"max_width"`
+	MaxHeight int    `yaml:"max_height"`
+	Quality   int    `yaml:"quality"` // JPEG and WebP, defaults to 85
+	Format    string `yaml:"format"`  // jpeg, png or webp; empty keeps each image's
+	CWebP     string `yaml:"cwebp"`   // command that encodes webp, defaults to cwebp
+}
+
+type Cleanup struct {
+	Rules    []string `yaml:"rules"`    // defaults to all of CleanupRules
+	Metadata []string `yaml:"metadata"` // more patterns for trailing metadata lines
+}
+
+// CleanupRules names the cleanup transform's rules, as paper.CleanupRules.
+var CleanupRules = []string{"comments", "mentions", "empty", "metadata"}
+
+// Transforms names the transforms a config can list, in the order they're
+// best run in.
+var Transforms = []string{"cleanup", "markdown", "math", "embeds", "assets", "links", "whitespace", "tasks", "footnotes", "toc", "code", "typography", "shortcodes", "emoji"}
+
+var embedName = regexp.MustCompile(`^[A-Za-z][\w-]*$`)
+
+// DefaultTransforms are run when a config doesn't list any.
+var DefaultTransforms = []string{"assets", "links", "whitespace"}
+
+// Default returns the config used without a file: every setting at its
+// default.
+func Default() *Config {
+	c := &Config{lines: map[string]int{}}
+	c.defaults()
+	return c
+}
+
+// Load reads and validates a config file.
+func Load(name string) (*Config, error) {
+	blob, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(blob)
+	if err != nil {
+		var errs []error
+		for _, e := range unjoin(err) {
+			if ce, ok := e.(*Error); ok {
+				ce.File = name
+			}
+			errs = append(errs, e)
+		}
+		return nil, errors.Join(errs...)
+	}
+	c.file = name
+	return c, nil
+}
+
+// Parse reads a config, fills in defaults and validates it. Every problem
+// found is reported, each as an *Error, joined with errors.Join.
+func Parse(src []byte) (*Config, error) {
+	root, err := parseYAML(src)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	d := &decoder{lines: map[string]int{}}
+	d.decode(root, reflect.ValueOf(c).Elem(), "")
+	if len(d.errs) > 0 {
+		return nil, joinErrors(d.errs)
+	}
+	c.lines = d.lines
+	c.defaults()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Unmarshal decodes YAML into the struct v points to by its fields' yaml
+// tags, as Parse does a config, for other files kept in the same format.
+// Every problem found is reported, each as an *Error.
+func Unmarshal(src []byte, v interface{}) error {
+	root, err := parseYAML(src)
+	if err != nil {
+		return err
+	}
+	d := &decoder{lines: map[string]int{}}
+	d.decode(root, reflect.ValueOf(v).Elem(), "")
+	if len(d.errs) > 0 {
+		return joinErrors(d.errs)
+	}
+	return nil
+}
+
+func (c *Config) defaults() {
+	if c.Auth.TokenEnv == "" {
+		c.Auth.TokenEnv = "DROPBOX_API_KEY"
+	}
+	if c.Sync.Dir == "" {
+		c.Sync.Dir = "docs"
+	}
+	if c.Overrides == "" {
+		c.Overrides = "overrides"
+	}
+	if c.Sync.Format == "" {
+		c.Sync.Format = "markdown"
+	}
+	if c.Transforms == nil {
+		c.Transforms = DefaultTransforms
+	}
+	if p := c.Publish; p != nil {
+		if p.Remote == "" {
+			p.Remote = "origin"
+		}
+		if p.Branch == "" {
+			p.Branch = "gh-pages"
+		}
+		if p.TokenEnv == "" {
+			p.TokenEnv = "GITHUB_TOKEN"
+		}
+	}
+	if s := c.Schedule; s != nil {
+		if s.Spec == "" {
+			s.Spec = "@every 15m"
+		}
+		if s.Timeout == 0 {
+			s.Timeout = 10 * time.Minute
+		}
+	}
+	if a := c.Alerts; a != nil {
+		if a.ErrorRate == 0 {
+			a.ErrorRate = 20
+		}
+		if a.Repeat == 0 {
+			a.Repeat = time.Hour
+		}
+	}
+}
+
+// Validate checks settings that are well formed but wrong, such as an
+// unknown transform or a bad cron expression.
+func (c *Config) Validate() error {
+	var errs []*Error
+	fail := func(key, format string, args ...interface{}) {
+		e := &Error{File: c.file, Line: c.lines[key], Key: key, Msg: fmt.Sprintf(format, args...)}
+		if name, ok := c.env[key]; ok {
+			e.File, e.Env = "", name
+		}
+		errs = append(errs, e)
+	}
+	if c.Auth.Token != "" && c.Set("auth.token_env") && c.env["auth.token"] == "" {
+		fail("auth.token", "set token or token_env, not both")
+	}
+	for _, h := range [][2]string{{"auth.api_host", c.Auth.APIHost}, {"auth.content_host", c.Auth.ContentHost}} {
+		host := h[1]
+		if host == "" {
+			continue
+		}
+		if !strings.Contains(host, "://") {
+			host = "https://" + host
+		}
+		if u, err := url.Parse(host); err != nil || u.Host == "" || u.Path != "" && u.Path != "/" {
+			fail(h[0], "want a host like proxy.example.com or http://localhost:8080, got %q", h[1])
+		}
+	}
+	if c.Sync.Format != "markdown" && c.Sync.Format != "html" {
+		fail("sync.format", "want markdown or html, got %q", c.Sync.Format)
+	}
+	if c.Sync.WordsPerMinute < 0 {
+		fail("sync.words_per_minute", "want a positive number, got %d", c.Sync.WordsPerMinute)
+	}
+	for mime, ext := range c.Sync.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			fail("sync.extensions."+mime, "extension %q should start with a dot", ext)
+		}
+	}
+	known := map[string]bool{}
+	for _, t := range Transforms {
+		known[t] = true
+	}
+	seen := map[string]bool{}
+	for i, t := range c.Transforms {
+		key := fmt.Sprintf("transforms[%d]", i)
+		switch {
+		case !known[t]:
+			fail(key, "unknown transform %q; want one of %s", t, strings.Join(Transforms, ", "))
+		case seen[t]:
+			fail(key, "transform %q is listed twice", t)
+		case t == "markdown" && c.Sync.Format != "html":
+			fail(key, "the markdown transform converts HTML exports; set sync.format to html")
+		case t == "emoji" && seen["shortcodes"], t == "shortcodes" && seen["emoji"]:
+			fail(key, "shortcodes and emoji convert in opposite directions; list one")
+		}
+		seen[t] = true
+	}
+	for name := range c.Embeds {
+		if !embedName.MatchString(name) {
+			fail("embeds."+name, "shortcode names start with a letter and contain only letters, digits, _ and -")
+		}
+	}
+	if len(c.Embeds) > 0 && !seen["embeds"] {
+		fail("embeds", "list the embeds transform in transforms to expand these")
+	}
+	rules := map[string]bool{}
+	for _, r := range CleanupRules {
+		rules[r] = true
+	}
+	for i, rule := range c.Cleanup.Rules {
+		if !rules[rule] {
+			fail(fmt.Sprintf("cleanup.rules[%d]", i), "unknown rule %q; want one of %s", rule, strings.Join(CleanupRules, ", "))
+		}
+	}
+	for i, pattern := range c.Cleanup.Metadata {
+		if _, err := regexp.Compile(pattern); err != nil {
+			fail(fmt.Sprintf("cleanup.metadata[%d]", i), "%v", err)
+		}
+	}
+	if c.Site.URL != "" {
+		if u, err := url.Parse(c.Site.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			fail("site.url", "want an absolute URL like https://blog.example.com, got %q", c.Site.URL)
+		}
+	}
+	if c.Site.PageSize < 0 {
+		fail("site.page_size", "can't be negative")
+	}
+	for i, h := range c.Hooks {
+		key := fmt.Sprintf("hooks[%d]", i)
+		n := 0
+		for _, set := range []bool{h.URL != "", h.Command != "", h.Git} {
+			if set {
+				n++
+			}
+		}
+		if n != 1 {
+			fail(key, "set exactly one of url, command or git")
+		}
+		if h.Push && !h.Git {
+			fail(key+".push", "push only applies to git hooks")
+		}
+		if h.URL != "" {
+			if u, err := url.Parse(h.URL); err != nil || u.Scheme != "http" && u.Scheme != "https" {
+				fail(key+".url", "want an http or https URL, got %q", h.URL)
+			}
+		}
+	}
+	if s := c.Schedule; s != nil {
+		if _, err := daemon.Parse(s.Spec); err != nil {
+			fail("schedule.spec", "%v", err)
+		}
+		if s.Timeout < 0 {
+			fail("schedule.timeout", "can't be negative")
+		}
+		if s.MaxAge < 0 {
+			fail("schedule.max_age", "can't be negative")
+		}
+	}
+	if a := c.Alerts; a != nil {
+		if a.URL == "" && a.Command == "" {
+			fail("alerts", "set url, command or both")
+		}
+		if a.URL != "" {
+			if u, err := url.Parse(a.URL); err != nil || u.Scheme != "http" && u.Scheme != "https" {
+				fail("alerts.url", "want an http or https URL, got %q", a.URL)
+			}
+		}
+		if a.ErrorRate < 1 || a.ErrorRate > 100 {
+			fail("alerts.error_rate", "want a percentage between 1 and 100, got %d", a.ErrorRate)
+		}
+		if a.TokenExpires != "" {
+			if _, err := time.Parse(time.RFC3339, a.TokenExpires); err != nil {
+				fail("alerts.token_expires", "want a time like 2024-06-01T00:00:00Z, got %q", a.TokenExpires)
+			}
+		}
+		if a.Repeat < 0 {
+			fail("alerts.repeat", "can't be negative")
+		}
+	}
+	if i := c.Images; i != nil {
+		if i.MaxWidth < 0 {
+			fail("images.max_width", "can't be negative")
+		}
+		if i.MaxHeight < 0 {
+			fail("images.max_height", "can't be negative")
+		}
+		if i.Quality < 0 || i.Quality > 100 {
+			fail("images.quality", "want a quality between 1 and 100, got %d", i.Quality)
+		}
+		switch i.Format {
+		case "", "jpeg", "png", "webp":
+		default:
+			fail("images.format", "want jpeg, png or webp, got %q", i.Format)
+		}
+		if !seen["assets"] {
+			fail("images", "list the assets transform in transforms to optimize images")
+		}
+	}
+	if l := c.Links; l != nil {
+		if l.Concurrency < 0 {
+			fail("links.concurrency", "can't be negative")
+		}
+		if l.Timeout < 0 {
+			fail("links.timeout", "can't be negative")
+		}
+		for i, pattern := range l.Ignore {
+			if _, err := regexp.Compile(pattern); err != nil {
+				fail(fmt.Sprintf("links.ignore[%d]", i), "%v", err)
+			}
+		}
+	}
+	switch c.Book.Format {
+	case "", "markdown", "html":
+	default:
+		fail("book.format", "want markdown or html, got %q", c.Book.Format)
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	return nil
+}
+
+// Token returns the Dropbox API token.
+func (c *Config) Token() (string, error) {
+	if c.Auth.Token != "" {
+		return c.Auth.Token, nil
+	}
+	if token := os.Getenv(c.Auth.TokenEnv); token != "" {
+		return token, nil
+	}
+	return "", fmt.Errorf("%s is not set", c.Auth.TokenEnv)
+}
+
+// Set reports whether a setting, such as "site.title", appears in the
+// file or the environment, so that callers can tell it apart from one left
+// at its default.
+func (c *Config) Set(key string) bool {
+	_, env := c.env[key]
+	return c.lines[key] != 0 || env
+}
+
+func joinErrors(errs []*Error) error {
+	all := make([]error, len(errs))
+	for i, e := range errs {
+		all[i] = e
+	}
+	return errors.Join(all...)
+}
+
+// unjoin returns the errors joined into err.
+func unjoin(err error) []error {
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is a problem with a config file, located by line or environment
+// variable and, for settings, by key, e.g. "sync.format".
+type Error struct {
+	File string
+	Line int
+	Env  string // the environment variable the setting came from, if any
+	Key  string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	switch {
+	case e.Env != "":
+		b.WriteString("$" + e.Env + ": ")
+	case e.File != "" && e.Line > 0:
+		fmt.Fprintf(&b, "%s:%d: ", e.File, e.Line)
+	case e.File != "":
+		b.WriteString(e.File + ": ")
+	case e.Line > 0:
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Key != "" {
+		b.WriteString(e.Key + ": ")
+	}
+	b.WriteString(e.Msg)
+	return b.String()
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// decoder fills in a struct from a parsed file by its fields' yaml tags,
+// collecting every error rather than stopping at the first.
+type decoder struct {
+	lines map[string]int // line each key was set on
+	errs  []*Error
+}
+
+func (d *decoder) fail(n *node, key, format string, args ...interface{}) {
+	d.errs = append(d.errs, &Error{Line: n.line, Key: key, Msg: fmt.Sprintf(format, args...)})
+}
+
+func isNull(n *node) bool {
+	return n.kind == scalarNode && !n.quoted && (n.value == "null" || n.value == "~")
+}
+
+func (d *decoder) decode(n *node, v reflect.Value, key string) {
+	d.lines[key] = n.line
+	if isNull(n) {
+		// An empty section, like "publish:", turns it on with its defaults.
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		d.decode(n, v.Elem(), key)
+	case reflect.Struct:
+		d.decodeStruct(n, v, key)
+	case reflect.Slice:
+		if n.kind != sequenceNode {
+			d.fail(n, key, "want a list")
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), len(n.items), len(n.items))
+		for i, item := range n.items {
+			d.decode(item, s.Index(i), fmt.Sprintf("%s[%d]", key, i))
+		}
+		v.Set(s)
+	case reflect.Map:
+		if n.kind != mappingNode {
+			d.fail(n, key, "want a mapping")
+			return
+		}
+		m := reflect.MakeMap(v.Type())
+		for i, k := range n.keys {
+			e := reflect.New(v.Type().Elem()).Elem()
+			d.decode(n.values[i], e, key+"."+k.value)
+			m.SetMapIndex(reflect.ValueOf(k.value), e)
+		}
+		v.Set(m)
+	default:
+		d.decodeScalar(n, v, key)
+	}
+}
+
+func (d *decoder) decodeStruct(n *node, v reflect.Value, key string) {
+	if n.kind != mappingNode {
+		if key == "" {
+			d.fail(n, key, "want a mapping of settings, like \"sync:\"")
+		} else {
+			d.fail(n, key, "want a mapping")
+		}
+		return
+	}
+	fields := map[string]int{}
+	var names []string
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Tag.Get("yaml"); name != "" {
+			fields[name] = i
+			names = append(names, name)
+		}
+	}
+	for i, k := range n.keys {
+		sub := k.value
+		if key != "" {
+			sub = key + "." + k.value
+		}
+		f, ok := fields[k.value]
+		if !ok {
+			sort.Strings(names)
+			d.fail(k, sub, "unknown setting; want one of %s", strings.Join(names, ", "))
+			continue
+		}
+		d.decode(n.values[i], v.Field(f), sub)
+	}
+}
+
+func (d *decoder) decodeScalar(n *node, v reflect.Value, key string) {
+	if n.kind != scalarNode {
+		d.fail(n, key, "want a single value, not a list or mapping")
+		return
+	}
+	switch {
+	case v.Type() == durationType:
+		t, err := time.ParseDuration(n.value)
+		if err != nil {
+			d.fail(n, key, "want a duration like 90s or 10m, got %q", n.value)
+			return
+		}
+		v.SetInt(int64(t))
+	case v.Kind() == reflect.String:
+		v.SetString(n.value)
+	case v.Kind() == reflect.Bool:
+		switch strings.ToLower(n.value) {
+		case "true", "yes", "on":
+			v.SetBool(true)
+		case "false", "no", "off":
+			v.SetBool(false)
+		default:
+			d.fail(n, key, "want true or false, got %q", n.value)
+		}
+	case v.Kind() == reflect.Int:
+		i, err := strconv.Atoi(n.value)
+		if err != nil {
+			d.fail(n, key, "want a whole number, got %q", n.value)
+			return
+		}
+		v.SetInt(int64(i))
+	default:
+		panic("config: can't decode into " + v.Type().String())
+	}
+}
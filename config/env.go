@@ -0,0 +1,112 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Settings can be overridden by environment variables, which take
+// precedence over the file. Each is named PAPER_ followed by its key in
+// upper case with dots as underscores, e.g. PAPER_SYNC_DIR for sync.dir,
+// except that auth settings drop their section: PAPER_TOKEN,
+// PAPER_TOKEN_ENV, PAPER_SCOPES and PAPER_TEAM. PAPER_TRANSFORMS is a
+// comma-separated list. Hooks and sync.extensions can only be set in the
+// file.
+
+// EnvName returns the environment variable for a setting, such as
+// "sync.dir".
+func EnvName(key string) string {
+	key = strings.TrimPrefix(key, "auth.")
+	return "PAPER_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// EnvNames returns every environment variable a config reads, sorted.
+func EnvNames() []string {
+	var names []string
+	eachEnvSetting(reflect.ValueOf(&Config{}).Elem(), func(key string, _ func() reflect.Value) {
+		names = append(names, EnvName(key))
+	})
+	sort.Strings(names)
+	return names
+}
+
+// eachEnvSetting calls fn with each setting that can come from the
+// environment and a function returning its field, allocating the
+// section if it's a nil pointer.
+func eachEnvSetting(v reflect.Value, fn func(key string, field func() reflect.Value)) {
+	for i := 0; i < v.NumField(); i++ {
+		section := v.Type().Field(i).Tag.Get("yaml")
+		sv := v.Field(i)
+		st := sv.Type()
+		if st.Kind() == reflect.Ptr {
+			st = st.Elem()
+		}
+		switch {
+		case section == "":
+		case sv.Kind() == reflect.Slice && sv.Type().Elem().Kind() == reflect.String:
+			fn(section, func() reflect.Value { return sv })
+		case st.Kind() == reflect.Struct:
+			for j := 0; j < st.NumField(); j++ {
+				tag, kind := st.Field(j).Tag.Get("yaml"), st.Field(j).Type.Kind()
+				if tag == "" || kind == reflect.Map || kind == reflect.Slice {
+					continue
+				}
+				j := j
+				fn(section+"."+tag, func() reflect.Value {
+					if sv.Kind() == reflect.Ptr {
+						if sv.IsNil() {
+							sv.Set(reflect.New(st))
+						}
+						return sv.Elem().Field(j)
+					}
+					return sv.Field(j)
+				})
+			}
+		}
+	}
+}
+
+// ApplyEnv overrides settings with the environment variables lookup
+// finds, such as os.LookupEnv, then fills in defaults and validates the
+// result again. Setting any variable in a section, like
+// PAPER_PUBLISH_BRANCH, turns an optional section on.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	d := &decoder{lines: map[string]int{}}
+	if c.lines == nil {
+		c.lines = map[string]int{}
+	}
+	if c.env == nil {
+		c.env = map[string]string{}
+	}
+	eachEnvSetting(reflect.ValueOf(c).Elem(), func(key string, field func() reflect.Value) {
+		name := EnvName(key)
+		value, ok := lookup(name)
+		if !ok {
+			return
+		}
+		n := len(d.errs)
+		f := field()
+		if f.Kind() == reflect.Slice {
+			var list []string
+			for _, s := range strings.Split(value, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					list = append(list, s)
+				}
+			}
+			f.Set(reflect.ValueOf(list))
+		} else {
+			d.decodeScalar(&node{kind: scalarNode, value: value}, f, key)
+		}
+		for _, e := range d.errs[n:] {
+			e.Env = name
+		}
+		delete(c.lines, key)
+		c.env[key] = name
+	})
+	if len(d.errs) > 0 {
+		return joinErrors(d.errs)
+	}
+	c.defaults()
+	return c.Validate()
+}
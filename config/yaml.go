@@ -0,0 +1,332 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The config file is read with a small YAML parser covering what a config
+// needs: block mappings and sequences, plain and quoted scalars, flow
+// sequences like [a, b] and comments. Anchors, tags, multi-document
+// streams and block scalars aren't supported.
+
+type nodeKind int
+
+const (
+	scalarNode nodeKind = iota
+	mappingNode
+	sequenceNode
+)
+
+type node struct {
+	kind   nodeKind
+	line   int
+	value  string // scalars
+	quoted bool   // scalars written in quotes are always strings
+	keys   []*node
+	values []*node // mappings, parallel to keys
+	items  []*node // sequences
+}
+
+type line struct {
+	num    int
+	indent int
+	text   string
+}
+
+type parser struct {
+	lines []line
+	pos   int
+}
+
+func parseYAML(src []byte) (*node, error) {
+	var p parser
+	for i, raw := range strings.Split(string(src), "\n") {
+		text := strings.TrimRight(stripComment(raw), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, &Error{Line: i + 1, Msg: "tabs can't be used for indentation"}
+		}
+		p.lines = append(p.lines, line{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(p.lines) == 0 {
+		return &node{kind: mappingNode, line: 1}, nil
+	}
+	n, err := p.block(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, &Error{Line: p.lines[p.pos].num, Msg: fmt.Sprintf("unexpected %q", p.lines[p.pos].text)}
+	}
+	return n, nil
+}
+
+// stripComment removes a comment, which starts with a # at the start of the
+// line or after a space, outside quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// block parses the mapping or sequence starting at the current line, whose
+// entries are indented by indent.
+func (p *parser) block(indent int) (*node, error) {
+	if isItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func isItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *parser) sequence(indent int) (*node, error) {
+	n := &node{kind: sequenceNode, line: p.lines[p.pos].num}
+	for p.pos < len(p.lines) {
+		l := &p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, &Error{Line: l.num, Msg: "unexpected indentation"}
+		}
+		if !isItem(l.text) {
+			// A list that is a key's value can be followed by the next key
+			// at the same indentation.
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.nested(l.num, indent)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			continue
+		}
+		// The item's content continues the block at its own column, so
+		// "- name: a" followed by "  url: b" is one mapping.
+		l.indent += len(l.text) - len(rest)
+		l.text = rest
+		if isItem(rest) || isKey(rest) {
+			item, err := p.block(l.indent)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			continue
+		}
+		item, err := scalarOrFlow(l.num, rest)
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+		n.items = append(n.items, item)
+	}
+	return n, nil
+}
+
+func (p *parser) mapping(indent int) (*node, error) {
+	n := &node{kind: mappingNode, line: p.lines[p.pos].num}
+	seen := map[string]int{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, &Error{Line: l.num, Msg: "unexpected indentation"}
+		}
+		if isItem(l.text) {
+			return nil, &Error{Line: l.num, Msg: "unexpected list item; expected key: value"}
+		}
+		key, rest, ok := splitKey(l.text)
+		if !ok {
+			return nil, &Error{Line: l.num, Msg: fmt.Sprintf("expected key: value, got %q", l.text)}
+		}
+		if prev, dup := seen[key]; dup {
+			return nil, &Error{Line: l.num, Msg: fmt.Sprintf("key %q is already set on line %d", key, prev)}
+		}
+		seen[key] = l.num
+		p.pos++
+		var value *node
+		var err error
+		if rest == "" {
+			value, err = p.nested(l.num, indent)
+		} else {
+			value, err = scalarOrFlow(l.num, rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		n.keys = append(n.keys, &node{kind: scalarNode, line: l.num, value: key})
+		n.values = append(n.values, value)
+	}
+	return n, nil
+}
+
+// nested parses the value of a key or list item with nothing after it on
+// its line: a more indented block, a list at the key's own indentation, or
+// else null.
+func (p *parser) nested(num, indent int) (*node, error) {
+	if p.pos < len(p.lines) {
+		next := p.lines[p.pos]
+		if next.indent > indent || next.indent == indent && isItem(next.text) && !p.inSequence(indent) {
+			return p.block(next.indent)
+		}
+	}
+	return &node{kind: scalarNode, line: num, value: "null"}, nil
+}
+
+// inSequence reports whether the line before the current one is a list
+// item at indent, in which case a following item at indent is its sibling
+// rather than its value.
+func (p *parser) inSequence(indent int) bool {
+	prev := p.lines[p.pos-1]
+	return prev.indent == indent && isItem(prev.text)
+}
+
+func isKey(text string) bool {
+	_, _, ok := splitKey(text)
+	return ok
+}
+
+// splitKey splits "key: value" into its key, unquoted, and value.
+func splitKey(text string) (string, string, bool) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		key, err := unquote(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		rest := text[end+2:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(rest), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			key := strings.TrimSpace(text[:i])
+			return key, strings.TrimSpace(text[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+// closingQuote returns the index of the quote closing the string s starts
+// with, or -1.
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && q == '"':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return strconv.Unquote(s)
+}
+
+func scalarOrFlow(num int, text string) (*node, error) {
+	switch text[0] {
+	case '[':
+		return flowSequence(num, text)
+	case '{':
+		return nil, &Error{Line: num, Msg: "flow mappings ({...}) aren't supported; use an indented block"}
+	case '|', '>':
+		return nil, &Error{Line: num, Msg: "block scalars aren't supported; use a quoted string"}
+	case '&', '*', '!':
+		return nil, &Error{Line: num, Msg: "anchors, aliases and tags aren't supported"}
+	}
+	return scalar(num, text)
+}
+
+func scalar(num int, text string) (*node, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		if closingQuote(text) != len(text)-1 {
+			return nil, &Error{Line: num, Msg: fmt.Sprintf("unterminated or malformed string %s", text)}
+		}
+		s, err := unquote(text)
+		if err != nil {
+			return nil, &Error{Line: num, Msg: fmt.Sprintf("bad string %s", text)}
+		}
+		return &node{kind: scalarNode, line: num, value: s, quoted: true}, nil
+	}
+	return &node{kind: scalarNode, line: num, value: text}, nil
+}
+
+// flowSequence parses a one-line [a, "b", c] sequence of scalars.
+func flowSequence(num int, text string) (*node, error) {
+	if !strings.HasSuffix(text, "]") {
+		return nil, &Error{Line: num, Msg: "flow sequences must be closed on the same line"}
+	}
+	n := &node{kind: sequenceNode, line: num}
+	inner := strings.TrimSpace(text[1 : len(text)-1])
+	for inner != "" {
+		end := strings.IndexByte(inner, ',')
+		if inner[0] == '"' || inner[0] == '\'' {
+			q := closingQuote(inner)
+			if q < 0 {
+				return nil, &Error{Line: num, Msg: "unterminated string in flow sequence"}
+			}
+			end = strings.IndexByte(inner[q:], ',')
+			if end >= 0 {
+				end += q
+			}
+		}
+		item := inner
+		if end >= 0 {
+			item, inner = inner[:end], strings.TrimSpace(inner[end+1:])
+		} else {
+			inner = ""
+		}
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, &Error{Line: num, Msg: "empty item in flow sequence"}
+		}
+		if strings.ContainsAny(item[:1], "[{") {
+			return nil, &Error{Line: num, Msg: "nested flow collections aren't supported"}
+		}
+		s, err := scalar(num, item)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, s)
+	}
+	return n, nil
+}
@@ -0,0 +1,254 @@
+// Package daemon runs jobs, such as syncing docs and publishing a site, on
+// cron schedules, so a Paper-backed site can be kept up to date by one
+// long-running process.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Job is a named task and when to run it.
+type Job struct {
+	Name     string
+	Schedule Schedule
+
+	// Timeout bounds each run; zero means no limit.
+	Timeout time.Duration
+
+	// Immediately runs the job once at startup, before its first
+	// scheduled time.
+	Immediately bool
+
+	// Run does the work, returning a short description of what it did for
+	// the run's summary.
+	Run func(ctx context.Context) (string, error)
+}
+
+// Run statuses.
+const (
+	StatusOK       = "ok"
+	StatusFailed   = "failed"
+	StatusTimeout  = "timeout"
+	StatusSkipped  = "skipped"  // the previous run was still going
+	StatusCanceled = "canceled" // the daemon was shutting down
+)
+
+// Summary describes one run of a job.
+type Summary struct {
+	Job     string    `json:"job"`
+	Start   time.Time `json:"start"`
+	Seconds float64   `json:"seconds"`
+	Status  string    `json:"status"`
+	Detail  string    `json:"detail,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Daemon runs jobs on their schedules. A job never overlaps itself: a run
+// that comes due while the previous one is still going is skipped.
+type Daemon struct {
+	Jobs []*Job
+
+	// Log, if set, receives each run's summary as a line of JSON.
+	Log io.Writer
+
+	// OnRun, if set, is called with each run's summary.
+	OnRun func(Summary)
+
+	mu      sync.Mutex
+	ctx     context.Context    // Run's
+	stop    context.CancelFunc // stops the current jobs' schedules
+	wg      sync.WaitGroup     // schedules and runs
+	running map[string]chan struct{}
+	status  map[string]*JobStatus
+}
+
+// JobStatus tracks a job's recent runs. Skipped runs aren't counted.
+type JobStatus struct {
+	Job                 string    `json:"job"`
+	LastRun             time.Time `json:"last_run,omitzero"`
+	LastSuccess         time.Time `json:"last_success,omitzero"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+// Status returns the status of each job, in the order of Jobs.
+func (d *Daemon) Status() []JobStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]JobStatus, len(d.Jobs))
+	for i, j := range d.Jobs {
+		out[i] = JobStatus{Job: j.Name}
+		if st, ok := d.status[j.Name]; ok {
+			out[i] = *st
+		}
+	}
+	return out
+}
+
+// Run runs the jobs until ctx is done, then waits for runs in progress to
+// finish and returns ctx's error. Runs see ctx canceled too, so jobs should
+// wind down promptly, leaving consistent state, as Syncer.Sync does.
+func (d *Daemon) Run(ctx context.Context) error {
+	if err := validate(d.Jobs); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.ctx = ctx
+	d.start()
+	d.mu.Unlock()
+	<-ctx.Done()
+	d.wg.Wait()
+	return ctx.Err()
+}
+
+// Reload replaces the jobs, for a changed config. Runs in progress finish
+// undisturbed, and a new job with the same name as a running one waits its
+// turn as if it were the same job. Jobs set to run Immediately do so, to
+// pick up the change.
+func (d *Daemon) Reload(jobs []*Job) error {
+	if err := validate(jobs); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Jobs = jobs
+	if d.ctx != nil && d.ctx.Err() == nil {
+		d.stop()
+		d.start()
+	}
+	return nil
+}
+
+func validate(jobs []*Job) error {
+	for _, j := range jobs {
+		if j.Schedule == nil || j.Run == nil {
+			return errors.New("daemon: job " + j.Name + " needs a schedule and a run function")
+		}
+	}
+	return nil
+}
+
+// start starts scheduling d.Jobs. Schedules stop with stop, or with d.ctx;
+// runs only with d.ctx. The caller holds d.mu.
+func (d *Daemon) start() {
+	sched, cancel := context.WithCancel(d.ctx)
+	d.stop = cancel
+	for _, j := range d.Jobs {
+		d.wg.Add(1)
+		go func(j *Job) {
+			defer d.wg.Done()
+			d.loop(sched, j)
+		}(j)
+	}
+}
+
+// busy returns the channel that holds a token while the named job runs.
+func (d *Daemon) busy(name string) chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.running == nil {
+		d.running = map[string]chan struct{}{}
+	}
+	ch, ok := d.running[name]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		d.running[name] = ch
+	}
+	return ch
+}
+
+// loop schedules one job until sched is done. Runs happen in their own
+// goroutine so that a slow run doesn't push back the schedule.
+func (d *Daemon) loop(sched context.Context, j *Job) {
+	busy := d.busy(j.Name)
+	start := func() {
+		select {
+		case busy <- struct{}{}:
+		default:
+			d.report(Summary{Job: j.Name, Start: time.Now(), Status: StatusSkipped})
+			return
+		}
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			defer func() { <-busy }()
+			d.report(d.run(d.ctx, j))
+		}()
+	}
+	if j.Immediately {
+		start()
+	}
+	for {
+		now := time.Now()
+		next := j.Schedule.Next(now)
+		if next.IsZero() {
+			return
+		}
+		t := time.NewTimer(next.Sub(now))
+		select {
+		case <-sched.Done():
+			t.Stop()
+			return
+		case <-t.C:
+			start()
+		}
+	}
+}
+
+// run runs a job once and summarizes the result.
+func (d *Daemon) run(ctx context.Context, j *Job) Summary {
+	parent := ctx
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+	s := Summary{Job: j.Name, Start: time.Now(), Status: StatusOK}
+	detail, err := j.Run(ctx)
+	s.Seconds = time.Since(s.Start).Seconds()
+	s.Detail = detail
+	switch {
+	case err == nil:
+	case parent.Err() != nil:
+		s.Status, s.Error = StatusCanceled, err.Error()
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil:
+		s.Status, s.Error = StatusTimeout, err.Error()
+	default:
+		s.Status, s.Error = StatusFailed, err.Error()
+	}
+	return s
+}
+
+func (d *Daemon) report(s Summary) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s.Status != StatusSkipped {
+		if d.status == nil {
+			d.status = map[string]*JobStatus{}
+		}
+		st, ok := d.status[s.Job]
+		if !ok {
+			st = &JobStatus{Job: s.Job}
+			d.status[s.Job] = st
+		}
+		st.LastRun = s.Start
+		if s.Status == StatusOK {
+			st.LastSuccess, st.ConsecutiveFailures, st.LastError = s.Start, 0, ""
+		} else {
+			st.ConsecutiveFailures++
+			st.LastError = s.Error
+		}
+	}
+	if d.Log != nil {
+		line, _ := json.Marshal(s)
+		d.Log.Write(append(line, '\n'))
+	}
+	if d.OnRun != nil {
+		d.OnRun(s)
+	}
+}
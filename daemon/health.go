@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Health serves probes for the daemon:
+//
+//	/healthz  liveness: fails once a job has failed MaxFailures times in a
+//	          row, so a wedged daemon gets restarted
+//	/readyz   readiness: fails until every job has succeeded once, while
+//	          Ping fails, or when a job's last success is older than MaxAge
+//
+// Both report each job's status and whether Dropbox is reachable as JSON.
+type Health struct {
+	Daemon *Daemon
+
+	// Ping checks that Dropbox is reachable, e.g. APIClient.Ping. It is
+	// called with a 5s timeout on each /readyz request.
+	Ping func(ctx context.Context) error
+
+	MaxFailures int           // defaults to 3
+	MaxAge      time.Duration // zero means no limit
+}
+
+type healthReport struct {
+	OK        bool        `json:"ok"`
+	Reachable *bool       `json:"dropbox_reachable,omitempty"`
+	PingError string      `json:"dropbox_error,omitempty"`
+	Jobs      []JobStatus `json:"jobs"`
+}
+
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var rep healthReport
+	switch r.URL.Path {
+	case "/healthz":
+		rep = h.live()
+	case "/readyz":
+		rep = h.ready(r.Context())
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !rep.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(rep)
+}
+
+func (h *Health) live() healthReport {
+	max := h.MaxFailures
+	if max == 0 {
+		max = 3
+	}
+	rep := healthReport{OK: true, Jobs: h.Daemon.Status()}
+	for _, st := range rep.Jobs {
+		if st.ConsecutiveFailures >= max {
+			rep.OK = false
+		}
+	}
+	return rep
+}
+
+func (h *Health) ready(ctx context.Context) healthReport {
+	rep := healthReport{OK: true, Jobs: h.Daemon.Status()}
+	for _, st := range rep.Jobs {
+		if st.LastSuccess.IsZero() || h.MaxAge > 0 && time.Since(st.LastSuccess) > h.MaxAge {
+			rep.OK = false
+		}
+	}
+	if h.Ping != nil {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		err := h.Ping(ctx)
+		reachable := err == nil
+		rep.Reachable = &reachable
+		if err != nil {
+			rep.OK, rep.PingError = false, err.Error()
+		}
+	}
+	return rep
+}
+
+// ReloadHandler calls reload for each POST, such as a function that reads
+// the config again and passes the new jobs to Daemon.Reload.
+func ReloadHandler(reload func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "use POST to reload", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := reload(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("reloaded\n"))
+	})
+}
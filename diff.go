@@ -0,0 +1,240 @@
+package paper
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+type DiffOp int
+
+const (
+	DiffEqual DiffOp = iota
+	DiffDelete
+	DiffInsert
+)
+
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// DocDiff is a line diff between two exports of a doc.
+type DocDiff struct {
+	Lines []DiffLine
+}
+
+// htmlBlockStart matches the start of block-level HTML tags, where HTML
+// exports are split into lines before diffing. Paper's HTML export is a
+// single line, which would otherwise diff as a whole.
+var htmlBlockStart = regexp.MustCompile(`(?i)<(?:p|div|h[1-6]|ul|ol|li|table|tr|pre|blockquote|hr|br)\b`)
+
+// Diff compares two exports of a doc line by line.
+func Diff(old, new []byte, format ExportFormat) *DocDiff {
+	a, b := diffLines(old, format), diffLines(new, format)
+
+	// Shared leading and trailing lines are common in edits and cheap to
+	// strip before the O(ND) search.
+	var pre, suf int
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	d := &DocDiff{}
+	for _, line := range a[:pre] {
+		d.Lines = append(d.Lines, DiffLine{DiffEqual, line})
+	}
+	d.Lines = append(d.Lines, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, line := range a[len(a)-suf:] {
+		d.Lines = append(d.Lines, DiffLine{DiffEqual, line})
+	}
+	return d
+}
+
+func diffLines(content []byte, format ExportFormat) []string {
+	s := strings.ReplaceAll(string(content), "\r\n", "\n")
+	if format == ExportFormatHTML {
+		s = htmlBlockStart.ReplaceAllStringFunc(s, func(tag string) string { return "\n" + tag })
+	}
+	s = strings.Trim(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// myers returns the shortest edit script turning a into b, using Myers'
+// greedy algorithm.
+func myers(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, max)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, a, b []string, max int) []DiffLine {
+	var out []DiffLine
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			out = append(out, DiffLine{DiffEqual, a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				out = append(out, DiffLine{DiffInsert, b[prevY]})
+			} else {
+				out = append(out, DiffLine{DiffDelete, a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// Empty reports whether the exports are identical, line for line.
+func (d *DocDiff) Empty() bool {
+	for _, l := range d.Lines {
+		if l.Op != DiffEqual {
+			return false
+		}
+	}
+	return true
+}
+
+// Unified formats the diff like diff -u, with context lines of context
+// around each change.
+func (d *DocDiff) Unified(oldName, newName string, context int) string {
+	if d.Empty() {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	lines := d.Lines
+	for i := 0; i < len(lines); {
+		if lines[i].Op == DiffEqual {
+			i++
+			continue
+		}
+		// Grow the hunk until a run of more than 2*context equal lines.
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != DiffEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Op == DiffEqual {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				end += context
+				if end > len(lines) {
+					end = len(lines)
+				}
+				break
+			}
+			end = run
+		}
+		oldStart, newStart := 1, 1
+		for _, l := range lines[:start] {
+			if l.Op != DiffInsert {
+				oldStart++
+			}
+			if l.Op != DiffDelete {
+				newStart++
+			}
+		}
+		var oldLen, newLen int
+		var body strings.Builder
+		for _, l := range lines[start:end] {
+			switch l.Op {
+			case DiffEqual:
+				oldLen++
+				newLen++
+				body.WriteString(" " + l.Text + "\n")
+			case DiffDelete:
+				oldLen++
+				body.WriteString("-" + l.Text + "\n")
+			case DiffInsert:
+				newLen++
+				body.WriteString("+" + l.Text + "\n")
+			}
+		}
+		if oldLen == 0 {
+			oldStart--
+		}
+		if newLen == 0 {
+			newStart--
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+		b.WriteString(body.String())
+		i = end
+	}
+	return b.String()
+}
+
+// HTML renders the diff as a table with deleted lines in <del> and inserted
+// lines in <ins>, for showing authors what changed.
+func (d *DocDiff) HTML() string {
+	var b strings.Builder
+	b.WriteString(`<table class="diff">` + "\n")
+	for _, l := range d.Lines {
+		text := html.EscapeString(l.Text)
+		switch l.Op {
+		case DiffEqual:
+			b.WriteString(`<tr class="diff-equal"><td> </td><td>` + text + "</td></tr>\n")
+		case DiffDelete:
+			b.WriteString(`<tr class="diff-delete"><td>-</td><td><del>` + text + "</del></td></tr>\n")
+		case DiffInsert:
+			b.WriteString(`<tr class="diff-insert"><td>+</td><td><ins>` + text + "</ins></td></tr>\n")
+		}
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
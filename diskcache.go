@@ -0,0 +1,225 @@
+package paper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskCache is a Cache stored in a directory, so it survives restarts.
+// Entries are written to <Dir>/<doc ID>/<revision>.<format>, and the least
+// recently used are evicted once they total more than MaxSize bytes.
+type DiskCache struct {
+	Dir     string
+	MaxSize int64 // zero means unbounded
+
+	mu     sync.Mutex
+	index  map[CacheKey]*CacheEntryInfo
+	size   int64
+	loaded bool
+}
+
+// CacheEntryInfo describes an entry in a DiskCache.
+type CacheEntryInfo struct {
+	Key  CacheKey
+	Size int64     // bytes on disk
+	Used time.Time // last read or written
+}
+
+func (c *DiskCache) path(key CacheKey) string {
+	return filepath.Join(c.Dir, url.PathEscape(string(key.DocID)), strconv.FormatInt(key.Revision, 10)+"."+string(key.Format))
+}
+
+// load indexes the entries already on disk. Last use is tracked by
+// modification time, so eviction order survives restarts too.
+func (c *DiskCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.index = map[CacheKey]*CacheEntryInfo{}
+	dirs, _ := os.ReadDir(c.Dir)
+	for _, d := range dirs {
+		id, err := url.PathUnescape(d.Name())
+		if err != nil || !d.IsDir() {
+			continue
+		}
+		files, _ := os.ReadDir(filepath.Join(c.Dir, d.Name()))
+		for _, f := range files {
+			name := f.Name()
+			dot := strings.IndexByte(name, '.')
+			if dot < 0 {
+				continue
+			}
+			rev, err := strconv.ParseInt(name[:dot], 10, 64)
+			if err != nil {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil {
+				continue
+			}
+			key := CacheKey{DocID(id), rev, ExportFormat(name[dot+1:])}
+			c.index[key] = &CacheEntryInfo{Key: key, Size: info.Size(), Used: info.ModTime()}
+			c.size += info.Size()
+		}
+	}
+}
+
+func (c *DiskCache) Get(key CacheKey) (*PaperDocExportResult, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	e, ok := c.index[key]
+	if !ok {
+		return nil, nil, false
+	}
+	blob, err := os.ReadFile(c.path(key))
+	if err != nil {
+		c.forget(key)
+		return nil, nil, false
+	}
+	// The result is stored as a line of JSON ahead of the content.
+	r := bufio.NewReader(bytes.NewReader(blob))
+	meta, err := r.ReadBytes('\n')
+	if err != nil {
+		c.remove(key)
+		return nil, nil, false
+	}
+	var res PaperDocExportResult
+	if err := json.Unmarshal(meta, &res); err != nil {
+		c.remove(key)
+		return nil, nil, false
+	}
+	content, _ := io.ReadAll(r)
+	e.Used = time.Now()
+	os.Chtimes(c.path(key), e.Used, e.Used)
+	return &res, content, true
+}
+
+func (c *DiskCache) Put(key CacheKey, res *PaperDocExportResult, content []byte) {
+	meta, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+	blob := append(append(meta, '\n'), content...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	if err := writeFileAtomic(c.path(key), blob, 0644); err != nil {
+		return
+	}
+	c.forget(key)
+	c.index[key] = &CacheEntryInfo{Key: key, Size: int64(len(blob)), Used: time.Now()}
+	c.size += int64(len(blob))
+	c.evict()
+}
+
+func (c *DiskCache) Latest(docID DocID, format ExportFormat) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	var rev int64
+	for key := range c.index {
+		if key.DocID == docID && key.Format == format && key.Revision > rev {
+			rev = key.Revision
+		}
+	}
+	return rev
+}
+
+func (c *DiskCache) forget(key CacheKey) {
+	if e, ok := c.index[key]; ok {
+		c.size -= e.Size
+		delete(c.index, key)
+	}
+}
+
+// evict removes the least recently used entries until the cache fits in
+// MaxSize.
+func (c *DiskCache) evict() {
+	if c.MaxSize <= 0 || c.size <= c.MaxSize {
+		return
+	}
+	for _, e := range c.entries() {
+		if c.size <= c.MaxSize {
+			break
+		}
+		c.remove(e.Key)
+	}
+}
+
+func (c *DiskCache) remove(key CacheKey) error {
+	c.forget(key)
+	err := os.Remove(c.path(key))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	// Drop the doc's directory once it's empty.
+	os.Remove(filepath.Dir(c.path(key)))
+	return err
+}
+
+// entries returns the index, least recently used first.
+func (c *DiskCache) entries() []CacheEntryInfo {
+	out := make([]CacheEntryInfo, 0, len(c.index))
+	for _, e := range c.index {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Used.Before(out[j].Used) })
+	return out
+}
+
+// Entries lists the cached exports, least recently used first.
+func (c *DiskCache) Entries() []CacheEntryInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	return c.entries()
+}
+
+// Size returns the total size of the cached exports in bytes.
+func (c *DiskCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	return c.size
+}
+
+// Remove purges every cached export of a doc.
+func (c *DiskCache) Remove(docID DocID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	for key := range c.index {
+		if key.DocID != docID {
+			continue
+		}
+		if err := c.remove(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge empties the cache.
+func (c *DiskCache) Purge() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	for key := range c.index {
+		if err := c.remove(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package paper
+
+import (
+	"context"
+)
+
+// Doc is a downloaded Paper doc as it moves from download to export.
+type Doc struct {
+	ID       DocID
+	Title    string
+	Owner    string
+	Revision int64
+	MIME     string
+	Format   ExportFormat
+	Content  []byte
+	Assets   []Asset
+	TOC      []*Heading
+
+	// Folders is set by a Syncer that records folders.
+	Folders *FoldersContainingPaperDoc
+
+	spill *Spill // holds Content until a Syncer writes the doc
+}
+
+// FetchDoc downloads a doc and wraps the result in a Doc.
+func (c *APIClient) FetchDoc(ctx context.Context, in *PaperDocExport) (*Doc, error) {
+	return fetchDoc(ctx, c, in)
+}
+
+func fetchDoc(ctx context.Context, c Client, in *PaperDocExport) (*Doc, error) {
+	res, blob, err := c.DownloadDoc(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return &Doc{
+		ID:       in.DocID,
+		Title:    res.Title,
+		Owner:    res.Owner,
+		Revision: res.Revision,
+		MIME:     res.MIME,
+		Format:   in.Format,
+		Content:  blob,
+	}, nil
+}
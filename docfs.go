@@ -0,0 +1,387 @@
+package paper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DocFS exposes the docs visible to a client as an fs.FS, so standard tools
+// such as http.FileServer and fs.WalkDir can read Paper directly. Folders
+// are directories and each doc is a file per format, e.g. "Team/notes.md".
+//
+// The tree is listed on first use and kept until Refresh, with RefreshDoc
+// updating single docs after they're written. Listing reads
+// every doc's title and revision; other formats are downloaded when opened
+// and cached until the doc's revision changes.
+type DocFS struct {
+	Client  Client
+	Context context.Context // used for API calls, defaults to Background
+	Formats []ExportFormat  // defaults to markdown and HTML
+
+	mu    sync.Mutex
+	root  *fsDir
+	cache map[fsKey][]byte
+}
+
+type fsKey struct {
+	id       DocID
+	format   ExportFormat
+	revision int64
+}
+
+type fsDir struct {
+	name  string
+	id    string // folder ID, empty for the root
+	dirs  map[string]*fsDir
+	files map[string]*fsDoc
+	names Filenames // stems of dirs and docs
+}
+
+type fsDoc struct {
+	name     string
+	id       DocID
+	format   ExportFormat
+	revision int64
+}
+
+// Refresh discards the listing, so the next call sees docs added, moved or
+// edited since.
+func (d *DocFS) Refresh() {
+	d.mu.Lock()
+	d.root = nil
+	d.mu.Unlock()
+}
+
+// RefreshDoc reads one doc again after it's been written, so the listing
+// needn't be refreshed as a whole. A doc that isn't listed yet is added to
+// the directory dir, e.g. a doc just created in that folder.
+func (d *DocFS) RefreshDoc(dir string, id DocID) error {
+	formats := d.formats()
+	doc, err := fetchDoc(d.ctx(), d.Client, &PaperDocExport{DocID: id, Format: formats[0]})
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.root == nil {
+		return nil
+	}
+	if d.cache == nil {
+		d.cache = map[fsKey][]byte{}
+	}
+	d.cache[fsKey{id, formats[0], doc.Revision}] = doc.Content
+	if d.root.setRevision(id, doc.Revision) {
+		return nil
+	}
+	parent := d.root
+	if dir != "." {
+		for _, elem := range strings.Split(dir, "/") {
+			sub, ok := parent.dirs[elem]
+			if !ok {
+				return &fs.PathError{Op: "refresh", Path: dir, Err: fs.ErrNotExist}
+			}
+			parent = sub
+		}
+	}
+	slug := uniqueFSName(parent, Slugify(doc.Title))
+	for _, format := range formats {
+		name := slug + formatExt(format)
+		parent.files[name] = &fsDoc{name: name, id: id, format: format, revision: doc.Revision}
+	}
+	return nil
+}
+
+// setRevision updates every format of a doc anywhere under dir, reporting
+// whether it was found.
+func (dir *fsDir) setRevision(id DocID, revision int64) bool {
+	found := false
+	for name, f := range dir.files {
+		if f.id == id {
+			dir.files[name] = &fsDoc{name: f.name, id: id, format: f.format, revision: revision}
+			found = true
+		}
+	}
+	for _, sub := range dir.dirs {
+		if sub.setRevision(id, revision) {
+			found = true
+		}
+	}
+	return found
+}
+
+func (d *DocFS) ctx() context.Context {
+	if d.Context != nil {
+		return d.Context
+	}
+	return context.Background()
+}
+
+func (d *DocFS) formats() []ExportFormat {
+	if len(d.Formats) > 0 {
+		return d.Formats
+	}
+	return []ExportFormat{ExportFormatMarkdown, ExportFormatHTML}
+}
+
+// tree lists the docs, caching the first format's content along the way.
+func (d *DocFS) tree() (*fsDir, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.root != nil {
+		return d.root, nil
+	}
+	ctx := d.ctx()
+	if d.cache == nil {
+		d.cache = map[fsKey][]byte{}
+	}
+	ids, err := ListAllDocIDs(ctx, d.Client, &ListPaperDocsArgs{})
+	if err != nil {
+		return nil, err
+	}
+	root := newFSDir(".")
+	live := map[fsKey]bool{}
+	formats := d.formats()
+	for _, id := range ids {
+		doc, err := fetchDoc(ctx, d.Client, &PaperDocExport{DocID: id, Format: formats[0]})
+		if err != nil {
+			return nil, fmt.Errorf("download %s: %w", id, err)
+		}
+		info, err := d.Client.GetDocFolderInfo(ctx, &RefPaperDoc{DocID: id})
+		if err != nil {
+			return nil, fmt.Errorf("folder info %s: %w", id, err)
+		}
+		dir := root
+		for _, f := range info.Folders {
+			name := SafeFilename(f.Name)
+			sub, ok := dir.dirs[name]
+			if !ok {
+				sub = newFSDir(name)
+				sub.id = f.ID
+				dir.dirs[name] = sub
+				dir.names.Add(name)
+			}
+			dir = sub
+		}
+		slug := uniqueFSName(dir, Slugify(doc.Title))
+		for _, format := range formats {
+			name := slug + formatExt(format)
+			dir.files[name] = &fsDoc{name: name, id: id, format: format, revision: doc.Revision}
+			live[fsKey{id, format, doc.Revision}] = true
+		}
+		d.cache[fsKey{id, formats[0], doc.Revision}] = doc.Content
+	}
+	for k := range d.cache {
+		if !live[k] {
+			delete(d.cache, k)
+		}
+	}
+	d.root = root
+	return root, nil
+}
+
+func newFSDir(name string) *fsDir {
+	return &fsDir{name: name, dirs: map[string]*fsDir{}, files: map[string]*fsDoc{}, names: Filenames{}}
+}
+
+// uniqueFSName picks a stem for a doc that no other doc or folder in dir
+// has, ignoring case, whatever format it's opened in.
+func uniqueFSName(dir *fsDir, slug string) string {
+	if slug == "" {
+		slug = "untitled"
+	}
+	return dir.names.Unique(truncateName(slug, maxFilename), "")
+}
+
+func (d *DocFS) content(f *fsDoc) ([]byte, error) {
+	key := fsKey{f.id, f.format, f.revision}
+	d.mu.Lock()
+	blob, ok := d.cache[key]
+	d.mu.Unlock()
+	if ok {
+		return blob, nil
+	}
+	doc, err := fetchDoc(d.ctx(), d.Client, &PaperDocExport{DocID: f.id, Format: f.format})
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cache == nil {
+		d.cache = map[fsKey][]byte{}
+	}
+	d.cache[fsKey{f.id, f.format, doc.Revision}] = doc.Content
+	return doc.Content, nil
+}
+
+// DocFSEntry describes what a DocFS path refers to.
+type DocFSEntry struct {
+	Dir      bool
+	FolderID string // for directories; empty for the root
+
+	ID       DocID // for docs
+	Format   ExportFormat
+	Revision int64
+	Size     int64 // length of the content, or -1 until it's been fetched
+}
+
+// Lookup returns what name refers to without fetching any content.
+func (d *DocFS) Lookup(name string) (*DocFSEntry, error) {
+	dir, doc, err := d.lookup("lookup", name)
+	if err != nil {
+		return nil, err
+	}
+	if doc != nil {
+		size := int64(-1)
+		d.mu.Lock()
+		if blob, ok := d.cache[fsKey{doc.id, doc.format, doc.revision}]; ok {
+			size = int64(len(blob))
+		}
+		d.mu.Unlock()
+		return &DocFSEntry{ID: doc.id, Format: doc.format, Revision: doc.revision, Size: size}, nil
+	}
+	return &DocFSEntry{Dir: true, FolderID: dir.id}, nil
+}
+
+func (d *DocFS) lookup(op, name string) (*fsDir, *fsDoc, error) {
+	if !fs.ValidPath(name) {
+		return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	root, err := d.tree()
+	if err != nil {
+		return nil, nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if name == "." {
+		return root, nil, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dir := root
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		if sub, ok := dir.dirs[elem]; ok {
+			dir = sub
+			if i == len(elems)-1 {
+				return dir, nil, nil
+			}
+			continue
+		}
+		if f, ok := dir.files[elem]; ok && i == len(elems)-1 {
+			return nil, f, nil
+		}
+		break
+	}
+	return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+func (d *DocFS) Open(name string) (fs.File, error) {
+	dir, doc, err := d.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return &fsDirFile{fsys: d, dir: dir}, nil
+	}
+	blob, err := d.content(doc)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &fsFile{doc: doc, Reader: bytes.NewReader(blob), size: int64(len(blob))}, nil
+}
+
+type fsFile struct {
+	*bytes.Reader
+	doc  *fsDoc
+	size int64
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) { return &fsInfo{name: f.doc.name, size: f.size}, nil }
+func (f *fsFile) Close() error               { return nil }
+
+type fsDirFile struct {
+	fsys    *DocFS
+	dir     *fsDir
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (f *fsDirFile) Stat() (fs.FileInfo, error) { return &fsInfo{name: f.dir.name, dir: true}, nil }
+func (f *fsDirFile) Close() error               { return nil }
+
+func (f *fsDirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.dir.name, Err: fs.ErrInvalid}
+}
+
+func (f *fsDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.read {
+		f.fsys.mu.Lock()
+		for name := range f.dir.dirs {
+			f.entries = append(f.entries, fs.FileInfoToDirEntry(&fsInfo{name: name, dir: true}))
+		}
+		for _, doc := range f.dir.files {
+			f.entries = append(f.entries, &fsEntry{fsys: f.fsys, doc: doc})
+		}
+		f.fsys.mu.Unlock()
+		sort.Slice(f.entries, func(i, j int) bool { return f.entries[i].Name() < f.entries[j].Name() })
+		f.read = true
+	}
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+// fsEntry is a doc in a directory listing. Its size is only known once its
+// content is fetched, so that's put off until Info is called.
+type fsEntry struct {
+	fsys *DocFS
+	doc  *fsDoc
+}
+
+func (e *fsEntry) Name() string      { return e.doc.name }
+func (e *fsEntry) IsDir() bool       { return false }
+func (e *fsEntry) Type() fs.FileMode { return 0 }
+
+func (e *fsEntry) Info() (fs.FileInfo, error) {
+	blob, err := e.fsys.content(e.doc)
+	if err != nil {
+		return nil, err
+	}
+	return &fsInfo{name: e.doc.name, size: int64(len(blob))}, nil
+}
+
+type fsInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i *fsInfo) Name() string       { return path.Base(i.name) }
+func (i *fsInfo) Size() int64        { return i.size }
+func (i *fsInfo) ModTime() time.Time { return time.Time{} }
+func (i *fsInfo) IsDir() bool        { return i.dir }
+func (i *fsInfo) Sys() interface{}   { return nil }
+
+func (i *fsInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
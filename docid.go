@@ -0,0 +1,60 @@
+package paper
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DocID identifies a Paper doc, e.g. "uaSvRuxvnkFa12PTkBv5q". Titles and
+// URLs aren't IDs; ParseDocID accepts either an ID or a doc URL.
+type DocID string
+
+var docIDPattern = regexp.MustCompile(`^[A-Za-z0-9]{8,64}$`)
+
+func (id DocID) String() string { return string(id) }
+
+// Valid reports whether id is shaped like a doc ID.
+func (id DocID) Valid() bool {
+	return docIDPattern.MatchString(string(id))
+}
+
+// ParseDocID returns the doc ID in s, which may be a bare ID or a
+// paper.dropbox.com doc URL.
+func ParseDocID(s string) (DocID, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "://") {
+		return DocIDFromURL(s)
+	}
+	if id := DocID(s); id.Valid() {
+		return id, nil
+	}
+	return "", fmt.Errorf("paper: invalid doc ID %q", s)
+}
+
+// DocIDFromURL extracts the doc ID from a Paper URL. Paper URLs take the
+// form /doc/<Title-Words>-<id> or /doc/<id>, optionally followed by a query
+// or fragment.
+func DocIDFromURL(link string) (DocID, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	if u.Host != "paper.dropbox.com" {
+		return "", fmt.Errorf("paper: %s is not a Paper URL", link)
+	}
+	rest := strings.TrimPrefix(u.Path, "/doc/")
+	if rest == u.Path || rest == "" {
+		return "", fmt.Errorf("paper: %s is not a doc URL", link)
+	}
+	rest = strings.TrimSuffix(rest, "/")
+	if i := strings.LastIndex(rest, "-"); i >= 0 {
+		rest = rest[i+1:]
+	}
+	id := DocID(rest)
+	if !id.Valid() {
+		return "", fmt.Errorf("paper: no doc ID in %s", link)
+	}
+	return id, nil
+}
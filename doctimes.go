@@ -0,0 +1,35 @@
+package paper
+
+import (
+	"context"
+	"time"
+)
+
+// DocTimes is when a doc was created and last modified in Paper.
+type DocTimes struct {
+	Created  time.Time
+	Modified time.Time
+}
+
+// FileTimes returns the times of a .paper file on filesystem-based Paper,
+// where path is a Dropbox path or "id:..." file ID. docs/list has no
+// timestamps, so this is the only place Paper reports them.
+//
+// Created is the oldest of the file's last 100 revisions, so it's later than
+// the real creation time for files edited more often than that.
+func (c *APIClient) FileTimes(ctx context.Context, path string) (*DocTimes, error) {
+	revs, err := c.ListRevisions(ctx, &ListRevisionsArgs{Path: path, Limit: 100})
+	if err != nil {
+		return nil, err
+	}
+	var t DocTimes
+	for _, e := range revs.Entries {
+		if t.Modified.IsZero() || e.ServerModified.After(t.Modified) {
+			t.Modified = e.ServerModified
+		}
+		if t.Created.IsZero() || e.ServerModified.Before(t.Created) {
+			t.Created = e.ServerModified
+		}
+	}
+	return &t, nil
+}
@@ -0,0 +1,108 @@
+package paper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+type ImportFormat string
+
+const (
+	ImportFormatMarkdown  ImportFormat = "markdown"
+	ImportFormatHTML      ImportFormat = "html"
+	ImportFormatPlainText ImportFormat = "plain_text"
+)
+
+type PaperDocCreateArgs struct {
+	ImportFormat   ImportFormat `json:"import_format"`
+	ParentFolderID string       `json:"parent_folder_id,omitempty"`
+}
+
+type PaperDocUpdatePolicy string
+
+const (
+	PaperDocUpdatePolicyAppend       PaperDocUpdatePolicy = "append"
+	PaperDocUpdatePolicyPrepend      PaperDocUpdatePolicy = "prepend"
+	PaperDocUpdatePolicyOverwriteAll PaperDocUpdatePolicy = "overwrite_all"
+)
+
+func (p PaperDocUpdatePolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Tag string `json:".tag"`
+	}{string(p)})
+}
+
+type PaperDocUpdateArgs struct {
+	DocID        DocID                `json:"doc_id"`
+	UpdatePolicy PaperDocUpdatePolicy `json:"doc_update_policy"`
+	Revision     int64                `json:"revision"`
+	ImportFormat ImportFormat         `json:"import_format"`
+}
+
+type PaperDocCreateUpdateResult struct {
+	DocID    DocID  `json:"doc_id"`
+	Revision int64  `json:"revision"`
+	Title    string `json:"title"`
+}
+
+// ArchiveDoc moves a doc to the owner's archive, where it can't be
+// restored through the API. Trash.Archive keeps a copy first.
+func (c *APIClient) ArchiveDoc(ctx context.Context, in *RefPaperDoc) error {
+	var out struct{}
+	return c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/archive", in, &out)
+}
+
+// PermanentlyDeleteDoc deletes a doc for good. Trash.Delete keeps a copy
+// first.
+func (c *APIClient) PermanentlyDeleteDoc(ctx context.Context, in *RefPaperDoc) error {
+	var out struct{}
+	return c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/permanently_delete", in, &out)
+}
+
+// DocWriter is implemented by clients that can create and update docs.
+// Client only reads, so implementations that never write needn't support it.
+type DocWriter interface {
+	CreateDoc(context.Context, *PaperDocCreateArgs, []byte) (*PaperDocCreateUpdateResult, error)
+	UpdateDoc(context.Context, *PaperDocUpdateArgs, []byte) (*PaperDocCreateUpdateResult, error)
+}
+
+func (c *APIClient) CreateDoc(ctx context.Context, in *PaperDocCreateArgs, content []byte) (*PaperDocCreateUpdateResult, error) {
+	var out PaperDocCreateUpdateResult
+	return &out, c.upload(ctx, "https://api.dropboxapi.com/2/paper/docs/create", in, content, &out)
+}
+
+// UpdateDoc fails with a "revision_mismatch" APIError when in.Revision isn't
+// the doc's current revision.
+func (c *APIClient) UpdateDoc(ctx context.Context, in *PaperDocUpdateArgs, content []byte) (*PaperDocCreateUpdateResult, error) {
+	var out PaperDocCreateUpdateResult
+	return &out, c.upload(ctx, "https://api.dropboxapi.com/2/paper/docs/update", in, content, &out)
+}
+
+func (c *APIClient) upload(ctx context.Context, url string, in interface{}, content []byte, out interface{}) error {
+	if err := c.checkAppType(url); err != nil {
+		return err
+	}
+	arg, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, _ := http.NewRequest("POST", c.endpoint(url, true), bytes.NewReader(content))
+	c.authorize(ctx, req)
+	req.Header.Set("Dropbox-API-Arg", string(arg))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.HTTP.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apierr APIError
+		if err := json.NewDecoder(resp.Body).Decode(&apierr); err != nil {
+			return err
+		}
+		return missingScope(url, apierr)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,23 @@
+package paper
+
+import (
+	"context"
+	"time"
+)
+
+// drainGrace is how long work in flight when a sync or bulk operation is
+// canceled gets to finish, so that a shutdown leaves consistent state.
+const drainGrace = 30 * time.Second
+
+// draining returns a context for work already started under ctx. It stays
+// live for grace after ctx is canceled, then is canceled too.
+func draining(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	drain, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(grace, cancel)
+	})
+	return drain, func() {
+		stop()
+		cancel()
+	}
+}
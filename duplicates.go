@@ -0,0 +1,100 @@
+package paper
+
+import (
+	"sort"
+	"strings"
+)
+
+// DuplicateTitle is a set of docs whose titles give the same slug, so all
+// but one were assigned a numbered slug like "notes-2".
+type DuplicateTitle struct {
+	Slug string           // the slug the titles share
+	Docs []*ManifestEntry // oldest first
+
+	// Suggested holds a distinct slug for each doc in Docs. The oldest doc
+	// keeps Slug; the rest are prefixed with their innermost folder or, if
+	// that doesn't tell them apart, suffixed with their creation date or
+	// finally their ID.
+	Suggested []string
+}
+
+// Duplicates finds docs with colliding titles, ordered by slug.
+func (m *Manifest) Duplicates() []*DuplicateTitle {
+	slugify := m.Slugify
+	if slugify == nil {
+		slugify = Slugify
+	}
+	groups := map[string][]*ManifestEntry{}
+	for _, e := range m.Entries() {
+		if slug := slugify(e.Title); slug != "" {
+			groups[slug] = append(groups[slug], e)
+		}
+	}
+	var dups []*DuplicateTitle
+	taken := map[string]bool{}
+	for _, e := range m.Docs {
+		taken[e.Slug] = true
+	}
+	for slug, docs := range groups {
+		if len(docs) < 2 {
+			continue
+		}
+		sort.SliceStable(docs, func(i, j int) bool { return docs[i].Created.Before(docs[j].Created) })
+		dups = append(dups, &DuplicateTitle{Slug: slug, Docs: docs})
+	}
+	sort.Slice(dups, func(i, j int) bool { return dups[i].Slug < dups[j].Slug })
+	for _, d := range dups {
+		// A group's own slugs are free to hand out again.
+		for _, e := range d.Docs {
+			delete(taken, e.Slug)
+		}
+		d.Suggested = make([]string, len(d.Docs))
+		for i, e := range d.Docs {
+			s := d.Slug
+			if i > 0 {
+				s = suggestSlug(d.Slug, e, slugify, taken)
+			}
+			d.Suggested[i] = s
+			taken[s] = true
+		}
+	}
+	return dups
+}
+
+func suggestSlug(base string, e *ManifestEntry, slugify func(string) string, taken map[string]bool) string {
+	var candidates []string
+	if n := len(e.Folders); n > 0 {
+		if folder := slugify(e.Folders[n-1]); folder != "" {
+			candidates = append(candidates, folder+"-"+base)
+		}
+	}
+	if !e.Created.IsZero() {
+		candidates = append(candidates, base+"-"+e.Created.Format("2006-01-02"))
+	}
+	for _, s := range candidates {
+		if !taken[s] {
+			return s
+		}
+	}
+	return base + "-" + strings.ToLower(string(e.ID))
+}
+
+// Disambiguate gives docs with colliding titles their suggested slugs and
+// returns the groups it changed. The next sync moves their files, and their
+// permalinks change, so it's best done before publishing.
+func (m *Manifest) Disambiguate() []*DuplicateTitle {
+	var changed []*DuplicateTitle
+	for _, d := range m.Duplicates() {
+		moved := false
+		for i, e := range d.Docs {
+			if e.Slug != d.Suggested[i] {
+				e.Slug = d.Suggested[i]
+				moved = true
+			}
+		}
+		if moved {
+			changed = append(changed, d)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,171 @@
+package paper
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Embeds expands shortcodes like {{youtube dQw4w9WgXcQ}} that authors type
+// into docs, for content Paper can't embed itself. Each is replaced by what
+// the handler registered for its name returns. Shortcodes in code are left
+// alone, as are ones without a handler unless Strict is set.
+type Embeds struct {
+	Handlers map[string]EmbedHandler
+	Strict   bool
+}
+
+// Embed is one shortcode in a doc.
+type Embed struct {
+	Name string
+	Args []string // split on spaces; quote an argument to keep its spaces
+	Doc  *Doc
+}
+
+// EmbedHandler returns the markdown or HTML, to suit e.Doc.Format, that
+// replaces a shortcode.
+type EmbedHandler func(e *Embed) (string, error)
+
+// Handle registers the handler for a shortcode name.
+func (e *Embeds) Handle(name string, h EmbedHandler) {
+	if e.Handlers == nil {
+		e.Handlers = map[string]EmbedHandler{}
+	}
+	e.Handlers[name] = h
+}
+
+var (
+	shortcodeTag   = regexp.MustCompile(`\{\{\s*([A-Za-z][\w-]*)([^{}]*)\}\}`)
+	markdownEscape = regexp.MustCompile(`\\([[:punct:]])`)
+	// Paper links URLs it sees, including ones given as arguments.
+	markdownAutolink = regexp.MustCompile(`<(https?://[^<>\s]*)>|\[[^\]]*\]\(([^()\s]*)\)`)
+)
+
+func (e *Embeds) Transform(doc *Doc) error {
+	var err error
+	expand := func(s string) string {
+		return shortcodeTag.ReplaceAllStringFunc(s, func(m string) string {
+			if err != nil {
+				return m
+			}
+			sub := shortcodeTag.FindStringSubmatch(m)
+			name, args := sub[1], sub[2]
+			h, ok := e.Handlers[name]
+			if !ok {
+				if e.Strict {
+					err = fmt.Errorf("%s: unknown shortcode %q", doc.ID, name)
+				}
+				return m
+			}
+			if doc.Format == ExportFormatHTML {
+				args = html.UnescapeString(args)
+			} else {
+				args = markdownAutolink.ReplaceAllString(args, "$1$2")
+				args = markdownEscape.ReplaceAllString(args, "$1")
+			}
+			out, herr := h(&Embed{Name: name, Args: splitArgs(args), Doc: doc})
+			if herr != nil {
+				err = fmt.Errorf("%s: shortcode %s: %w", doc.ID, name, herr)
+				return m
+			}
+			return out
+		})
+	}
+	var content string
+	if doc.Format == ExportFormatHTML {
+		content = convertHTMLText(string(doc.Content), expand)
+	} else {
+		content = convertMarkdownText(string(doc.Content), expand)
+	}
+	if err != nil {
+		return err
+	}
+	doc.Content = []byte(content)
+	return nil
+}
+
+// splitArgs splits a shortcode's arguments on spaces, keeping quoted ones
+// together. Curly quotes count, in case typography ran first. Quotes
+// within an argument are kept, as in {{quote it's}}.
+func splitArgs(s string) []string {
+	var args []string
+	var b strings.Builder
+	var quote rune
+	started := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			b.WriteRune(r)
+		case !started && (r == '"' || r == '\''):
+			quote, started = r, true
+		case !started && r == '“':
+			quote, started = '”', true
+		case !started && r == '‘':
+			quote, started = '’', true
+		case unicode.IsSpace(r):
+			if started {
+				args = append(args, b.String())
+				b.Reset()
+				started = false
+			}
+		default:
+			b.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, b.String())
+	}
+	return args
+}
+
+var youtubeID = regexp.MustCompile(`^[\w-]{6,}$`)
+
+// YouTubeEmbed embeds a YouTube video, given its ID or URL, e.g.
+// {{youtube dQw4w9WgXcQ}}.
+func YouTubeEmbed(e *Embed) (string, error) {
+	if len(e.Args) != 1 {
+		return "", fmt.Errorf("want a video ID or URL, got %d arguments", len(e.Args))
+	}
+	id := e.Args[0]
+	if u, err := url.Parse(id); err == nil && u.Host != "" {
+		switch {
+		case u.Query().Get("v") != "":
+			id = u.Query().Get("v")
+		default:
+			id = u.Path[strings.LastIndex(u.Path, "/")+1:]
+		}
+	}
+	if !youtubeID.MatchString(id) {
+		return "", fmt.Errorf("%q isn't a YouTube video", e.Args[0])
+	}
+	return `<iframe src="https://www.youtube-nocookie.com/embed/` + id + `" width="560" height="315" frameborder="0" allowfullscreen></iframe>`, nil
+}
+
+// CommandEmbed returns a handler that runs a command with the shortcode's
+// arguments appended, replacing the shortcode with what it prints. The
+// doc's ID and format are in PAPER_DOC_ID and PAPER_DOC_FORMAT.
+func CommandEmbed(name string, args ...string) EmbedHandler {
+	return func(e *Embed) (string, error) {
+		cmd := exec.Command(name, append(args[:len(args):len(args)], e.Args...)...)
+		cmd.Env = append(os.Environ(), "PAPER_DOC_ID="+string(e.Doc.ID), "PAPER_DOC_FORMAT="+string(e.Doc.Format))
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+			}
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		return strings.TrimRight(string(out), "\n"), nil
+	}
+}
@@ -0,0 +1,488 @@
+package paper
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Emoji converts Unicode emoji to GitHub-style :shortcodes:, or with
+// ToUnicode the other way, since some static site generators and feed
+// readers handle only one form well. Code is left alone, as are emoji
+// without a shortcode and unknown shortcodes.
+type Emoji struct {
+	ToUnicode bool
+
+	// Shortcodes maps names, without colons, to emoji. Entries override
+	// DefaultShortcodes.
+	Shortcodes map[string]string
+}
+
+func (e *Emoji) Transform(doc *Doc) error {
+	convert := e.toShortcodes()
+	if e.ToUnicode {
+		convert = e.toUnicode
+	}
+	if doc.Format == ExportFormatHTML {
+		doc.Content = []byte(convertHTMLText(string(doc.Content), convert))
+	} else {
+		doc.Content = []byte(convertMarkdownText(string(doc.Content), convert))
+	}
+	return nil
+}
+
+func (e *Emoji) lookup(name string) (string, bool) {
+	if s, ok := e.Shortcodes[name]; ok {
+		return s, true
+	}
+	s, ok := DefaultShortcodes[name]
+	return s, ok
+}
+
+var shortcode = regexp.MustCompile(`:([a-z0-9_+-]+):`)
+
+func (e *Emoji) toUnicode(s string) string {
+	return shortcode.ReplaceAllStringFunc(s, func(m string) string {
+		if emoji, ok := e.lookup(m[1 : len(m)-1]); ok {
+			return emoji
+		}
+		return m
+	})
+}
+
+// toShortcodes returns a function replacing emoji with shortcodes. Where
+// several names share an emoji, Shortcodes wins over DefaultShortcodes and
+// the alphabetically first name over the rest.
+func (e *Emoji) toShortcodes() func(string) string {
+	names := map[string]string{}
+	longest := 0
+	add := func(table map[string]string) {
+		keys := make([]string, 0, len(table))
+		for name := range table {
+			keys = append(keys, name)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+		for _, name := range keys {
+			emoji := table[name]
+			names[emoji] = name
+			// Emoji-style characters are often written with a
+			// redundant variation selector. Text-style ones, like ©,
+			// only count as emoji with one.
+			if !strings.HasSuffix(emoji, "\uFE0F") {
+				names[emoji+"\uFE0F"] = name
+			}
+			if n := len([]rune(emoji)) + 1; n > longest {
+				longest = n
+			}
+		}
+	}
+	add(DefaultShortcodes)
+	add(e.Shortcodes)
+	return func(s string) string {
+		var b strings.Builder
+		r := []rune(s)
+	next:
+		for i := 0; i < len(r); i++ {
+			if r[i] >= 0xa9 {
+				for n := min(longest, len(r)-i); n > 0; n-- {
+					if name, ok := names[string(r[i:i+n])]; ok {
+						b.WriteString(":" + name + ":")
+						i += n - 1
+						continue next
+					}
+				}
+			}
+			b.WriteRune(r[i])
+		}
+		return b.String()
+	}
+}
+
+// convertMarkdownText applies convert to markdown outside code and math.
+func convertMarkdownText(src string, convert func(string) string) string {
+	lines := strings.Split(src, "\n")
+	literal := literalLines(lines)
+	for i, line := range lines {
+		if !literal[i] {
+			lines[i] = outsideLiteralSpans(line, convert)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// outsideLiteralSpans applies convert to the parts of a line that aren't
+// inline code or math.
+func outsideLiteralSpans(line string, convert func(string) string) string {
+	var b strings.Builder
+	start := 0
+	for _, span := range literalSpans(line) {
+		b.WriteString(convert(line[start:span[0]]))
+		b.WriteString(line[span[0]:span[1]])
+		start = span[1]
+	}
+	b.WriteString(convert(line[start:]))
+	return b.String()
+}
+
+// codeSpans returns the byte ranges of a line's inline code spans,
+// backticks included.
+func codeSpans(line string) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		n := 1
+		for i+n < len(line) && line[i+n] == '`' {
+			n++
+		}
+		end := strings.Index(line[i+n:], line[i:i+n])
+		if end < 0 {
+			i += n
+			continue
+		}
+		stop := i + n + end + n
+		spans = append(spans, [2]int{i, stop})
+		i = stop
+	}
+	return spans
+}
+
+// convertHTMLText applies convert to HTML text outside code, leaving tags and
+// attributes alone.
+func convertHTMLText(src string, convert func(string) string) string {
+	var b strings.Builder
+	depth := 0
+	for _, tok := range tokenizeHTML(src) {
+		switch {
+		case tok.Type == htmlStartTag && (tok.Data == "code" || tok.Data == "pre" || htmlRawText[tok.Data]):
+			depth++
+		case tok.Type == htmlEndTag && (tok.Data == "code" || tok.Data == "pre" || htmlRawText[tok.Data]):
+			if depth > 0 {
+				depth--
+			}
+		case tok.Type == htmlText && depth == 0:
+			tok.Data = convert(tok.Data)
+		}
+		b.WriteString(tok.String())
+	}
+	return b.String()
+}
+
+// DefaultShortcodes maps GitHub's names for common emoji to the emoji.
+var DefaultShortcodes = map[string]string{
+	"+1":                           "👍",
+	"-1":                           "👎",
+	"100":                          "💯",
+	"1st_place_medal":              "🥇",
+	"airplane":                     "✈️",
+	"alarm_clock":                  "⏰",
+	"alien":                        "👽",
+	"angry":                        "😠",
+	"apple":                        "🍎",
+	"arrow_down":                   "⬇️",
+	"arrow_left":                   "⬅️",
+	"arrow_right":                  "➡️",
+	"arrow_up":                     "⬆️",
+	"arrows_counterclockwise":      "🔄",
+	"art":                          "🎨",
+	"astonished":                   "😲",
+	"avocado":                      "🥑",
+	"balloon":                      "🎈",
+	"ballot_box_with_check":        "☑",
+	"banana":                       "🍌",
+	"bangbang":                     "‼️",
+	"bar_chart":                    "📊",
+	"basketball":                   "🏀",
+	"battery":                      "🔋",
+	"bear":                         "🐻",
+	"bee":                          "🐝",
+	"beer":                         "🍺",
+	"beers":                        "🍻",
+	"bell":                         "🔔",
+	"bike":                         "🚲",
+	"birthday":                     "🎂",
+	"black_circle":                 "⚫",
+	"black_heart":                  "🖤",
+	"blue_heart":                   "💙",
+	"blush":                        "😊",
+	"book":                         "📖",
+	"books":                        "📚",
+	"boom":                         "💥",
+	"brain":                        "🧠",
+	"broken_heart":                 "💔",
+	"bug":                          "🐛",
+	"bulb":                         "💡",
+	"burrito":                      "🌯",
+	"butterfly":                    "🦋",
+	"cactus":                       "🌵",
+	"cake":                         "🍰",
+	"calendar":                     "📆",
+	"camera":                       "📷",
+	"car":                          "🚗",
+	"card_index":                   "📇",
+	"cat":                          "🐱",
+	"champagne":                    "🍾",
+	"chart_with_downwards_trend":   "📉",
+	"chart_with_upwards_trend":     "📈",
+	"checkered_flag":               "🏁",
+	"cherries":                     "🍒",
+	"cherry_blossom":               "🌸",
+	"chicken":                      "🐔",
+	"clap":                         "👏",
+	"clipboard":                    "📋",
+	"cloud":                        "☁️",
+	"clown_face":                   "🤡",
+	"coffee":                       "☕",
+	"cold_sweat":                   "😰",
+	"computer":                     "💻",
+	"confetti_ball":                "🎊",
+	"confounded":                   "😖",
+	"confused":                     "😕",
+	"construction":                 "🚧",
+	"cookie":                       "🍪",
+	"cool":                         "🆒",
+	"copyright":                    "©️",
+	"credit_card":                  "💳",
+	"crescent_moon":                "🌙",
+	"crossed_fingers":              "🤞",
+	"cry":                          "😢",
+	"dart":                         "🎯",
+	"date":                         "📅",
+	"deciduous_tree":               "🌳",
+	"disappointed":                 "😞",
+	"dizzy":                        "💫",
+	"dog":                          "🐶",
+	"dollar":                       "💵",
+	"dolphin":                      "🐬",
+	"doughnut":                     "🍩",
+	"droplet":                      "💧",
+	"earth_americas":               "🌎",
+	"electric_plug":                "🔌",
+	"email":                        "✉",
+	"envelope_with_arrow":          "📩",
+	"evergreen_tree":               "🌲",
+	"exclamation":                  "❗",
+	"expressionless":               "😑",
+	"eye":                          "👁️",
+	"eyes":                         "👀",
+	"fearful":                      "😨",
+	"file_folder":                  "📁",
+	"fire":                         "🔥",
+	"fish":                         "🐟",
+	"fist_oncoming":                "👊",
+	"fist_raised":                  "✊",
+	"flashlight":                   "🔦",
+	"flushed":                      "😳",
+	"football":                     "🏈",
+	"four_leaf_clover":             "🍀",
+	"fox_face":                     "🦊",
+	"free":                         "🆓",
+	"fries":                        "🍟",
+	"gear":                         "⚙️",
+	"gem":                          "💎",
+	"ghost":                        "👻",
+	"gift":                         "🎁",
+	"globe_with_meridians":         "🌐",
+	"grapes":                       "🍇",
+	"green_apple":                  "🍏",
+	"green_circle":                 "🟢",
+	"green_heart":                  "💚",
+	"green_square":                 "🟩",
+	"grey_exclamation":             "❕",
+	"grey_question":                "❔",
+	"grimacing":                    "😬",
+	"grin":                         "😁",
+	"grinning":                     "😀",
+	"guitar":                       "🎸",
+	"hamburger":                    "🍔",
+	"hammer":                       "🔨",
+	"handshake":                    "🤝",
+	"headphones":                   "🎧",
+	"hear_no_evil":                 "🙉",
+	"heart":                        "❤️",
+	"heart_eyes":                   "😍",
+	"heavy_check_mark":             "✔️",
+	"heavy_minus_sign":             "➖",
+	"heavy_multiplication_x":       "✖️",
+	"heavy_plus_sign":              "➕",
+	"hospital":                     "🏥",
+	"hotdog":                       "🌭",
+	"hourglass":                    "⌛",
+	"hourglass_flowing_sand":       "⏳",
+	"house":                        "🏠",
+	"hugs":                         "🤗",
+	"inbox_tray":                   "📥",
+	"information_source":           "ℹ️",
+	"innocent":                     "😇",
+	"interrobang":                  "⁉️",
+	"iphone":                       "📱",
+	"jigsaw":                       "🧩",
+	"joy":                          "😂",
+	"key":                          "🔑",
+	"keyboard":                     "⌨",
+	"kissing_heart":                "😘",
+	"large_blue_circle":            "🔵",
+	"laughing":                     "😆",
+	"lemon":                        "🍋",
+	"link":                         "🔗",
+	"lock":                         "🔒",
+	"loudspeaker":                  "📢",
+	"mag":                          "🔍",
+	"mag_right":                    "🔎",
+	"mailbox":                      "📫",
+	"mask":                         "😷",
+	"medal_sports":                 "🏅",
+	"mega":                         "📣",
+	"memo":                         "📝",
+	"microphone":                   "🎤",
+	"moneybag":                     "💰",
+	"mouse":                        "🐭",
+	"movie_camera":                 "🎥",
+	"muscle":                       "💪",
+	"musical_note":                 "🎵",
+	"nerd_face":                    "🤓",
+	"neutral_face":                 "😐",
+	"new":                          "🆕",
+	"no_bell":                      "🔕",
+	"no_entry":                     "⛔",
+	"no_entry_sign":                "🚫",
+	"no_mouth":                     "😶",
+	"notebook":                     "📓",
+	"notes":                        "🎶",
+	"o":                            "⭕",
+	"ocean":                        "🌊",
+	"octopus":                      "🐙",
+	"office":                       "🏢",
+	"ok_hand":                      "👌",
+	"open_file_folder":             "📂",
+	"open_hands":                   "👐",
+	"open_mouth":                   "😮",
+	"orange_circle":                "🟠",
+	"orange_heart":                 "🧡",
+	"outbox_tray":                  "📤",
+	"owl":                          "🦉",
+	"package":                      "📦",
+	"page_facing_up":               "📄",
+	"panda_face":                   "🐼",
+	"paperclip":                    "📎",
+	"peach":                        "🍑",
+	"pen":                          "🖊️",
+	"pencil2":                      "✏️",
+	"penguin":                      "🐧",
+	"pensive":                      "😔",
+	"phone":                        "☎️",
+	"pizza":                        "🍕",
+	"pleading_face":                "🥺",
+	"point_down":                   "👇",
+	"point_left":                   "👈",
+	"point_right":                  "👉",
+	"point_up":                     "☝️",
+	"point_up_2":                   "👆",
+	"poop":                         "💩",
+	"popcorn":                      "🍿",
+	"pray":                         "🙏",
+	"purple_circle":                "🟣",
+	"purple_heart":                 "💜",
+	"pushpin":                      "📌",
+	"question":                     "❓",
+	"rage":                         "😡",
+	"rainbow":                      "🌈",
+	"raised_hand":                  "✋",
+	"raised_hands":                 "🙌",
+	"recycle":                      "♻️",
+	"red_circle":                   "🔴",
+	"red_square":                   "🟥",
+	"registered":                   "®️",
+	"relieved":                     "😌",
+	"repeat":                       "🔁",
+	"robot":                        "🤖",
+	"rocket":                       "🚀",
+	"rofl":                         "🤣",
+	"roll_eyes":                    "🙄",
+	"rose":                         "🌹",
+	"rotating_light":               "🚨",
+	"round_pushpin":                "📍",
+	"school":                       "🏫",
+	"scissors":                     "✂️",
+	"scream":                       "😱",
+	"see_no_evil":                  "🙈",
+	"seedling":                     "🌱",
+	"ship":                         "🚢",
+	"skull":                        "💀",
+	"sleeping":                     "😴",
+	"sleepy":                       "😪",
+	"slightly_frowning_face":       "🙁",
+	"slightly_smiling_face":        "🙂",
+	"smile":                        "😄",
+	"smiley":                       "😃",
+	"smirk":                        "😏",
+	"snail":                        "🐌",
+	"snake":                        "🐍",
+	"snowflake":                    "❄️",
+	"sob":                          "😭",
+	"soccer":                       "⚽",
+	"sos":                          "🆘",
+	"sparkles":                     "✨",
+	"sparkling_heart":              "💖",
+	"speak_no_evil":                "🙊",
+	"speech_balloon":               "💬",
+	"star":                         "⭐",
+	"star2":                        "🌟",
+	"stopwatch":                    "⏱️",
+	"strawberry":                   "🍓",
+	"stuck_out_tongue":             "😛",
+	"stuck_out_tongue_winking_eye": "😜",
+	"sunflower":                    "🌻",
+	"sunglasses":                   "😎",
+	"sunny":                        "☀️",
+	"sweat":                        "😓",
+	"sweat_smile":                  "😅",
+	"taco":                         "🌮",
+	"tada":                         "🎉",
+	"tea":                          "🍵",
+	"tennis":                       "🎾",
+	"thinking":                     "🤔",
+	"thought_balloon":              "💭",
+	"tired_face":                   "😫",
+	"tm":                           "™️",
+	"train2":                       "🚆",
+	"triangular_flag_on_post":      "🚩",
+	"triumph":                      "😤",
+	"trophy":                       "🏆",
+	"turtle":                       "🐢",
+	"tv":                           "📺",
+	"umbrella":                     "☔",
+	"unamused":                     "😒",
+	"unicorn":                      "🦄",
+	"unlock":                       "🔓",
+	"up":                           "🆙",
+	"upside_down_face":             "🙃",
+	"v":                            "✌️",
+	"video_game":                   "🎮",
+	"warning":                      "⚠️",
+	"watch":                        "⌚",
+	"watermelon":                   "🍉",
+	"wave":                         "👋",
+	"weary":                        "😩",
+	"whale":                        "🐳",
+	"white_check_mark":             "✅",
+	"white_circle":                 "⚪",
+	"white_flag":                   "🏳️",
+	"wine_glass":                   "🍷",
+	"wink":                         "😉",
+	"world_map":                    "🗺️",
+	"worried":                      "😟",
+	"wrench":                       "🔧",
+	"writing_hand":                 "✍️",
+	"x":                            "❌",
+	"yawning_face":                 "🥱",
+	"yellow_circle":                "🟡",
+	"yellow_heart":                 "💛",
+	"yellow_square":                "🟨",
+	"yum":                          "😋",
+	"zap":                          "⚡",
+	"zipper_mouth_face":            "🤐",
+	"zzz":                          "💤",
+}
@@ -0,0 +1,183 @@
+package paper
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// EnumError reports a value outside an enum's known values.
+type EnumError struct {
+	Type  string
+	Value string
+}
+
+func (e *EnumError) Error() string {
+	return fmt.Sprintf("paper: unknown %s %q", e.Type, e.Value)
+}
+
+// unmarshalEnum decodes an enum from a plain string or the API's
+// {".tag": "..."} union form.
+func unmarshalEnum(b []byte) (string, error) {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		return s, nil
+	}
+	var tag struct {
+		Tag string `json:".tag"`
+	}
+	if err := json.Unmarshal(b, &tag); err != nil {
+		return "", err
+	}
+	return tag.Tag, nil
+}
+
+func (f ListPaperDocsFilterBy) String() string { return string(f) }
+
+func (f ListPaperDocsFilterBy) IsValid() bool {
+	switch f {
+	case ListPaperDocsFilterByAccessed, ListPaperDocsFilterByModified, ListPaperDocsFilterByCreated:
+		return true
+	}
+	return false
+}
+
+func (f *ListPaperDocsFilterBy) UnmarshalJSON(b []byte) error {
+	s, err := unmarshalEnum(b)
+	if err != nil {
+		return err
+	}
+	if v := ListPaperDocsFilterBy(s); v.IsValid() {
+		*f = v
+		return nil
+	}
+	return &EnumError{"ListPaperDocsFilterBy", s}
+}
+
+func (s ListPaperDocsSortBy) String() string { return string(s) }
+
+func (s ListPaperDocsSortBy) IsValid() bool {
+	switch s {
+	case ListPaperDocsSortByAccessed, ListPaperDocsSortByModified, ListPaperDocsSortByCreated:
+		return true
+	}
+	return false
+}
+
+func (s *ListPaperDocsSortBy) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalEnum(b)
+	if err != nil {
+		return err
+	}
+	if e := ListPaperDocsSortBy(v); e.IsValid() {
+		*s = e
+		return nil
+	}
+	return &EnumError{"ListPaperDocsSortBy", v}
+}
+
+func (o ListPaperDocsSortOrder) String() string { return string(o) }
+
+func (o ListPaperDocsSortOrder) IsValid() bool {
+	return o == ListPaperDocsSortOrderAsc || o == ListPaperDocsSortOrderDesc
+}
+
+func (o *ListPaperDocsSortOrder) UnmarshalJSON(b []byte) error {
+	s, err := unmarshalEnum(b)
+	if err != nil {
+		return err
+	}
+	if v := ListPaperDocsSortOrder(s); v.IsValid() {
+		*o = v
+		return nil
+	}
+	return &EnumError{"ListPaperDocsSortOrder", s}
+}
+
+func (f ExportFormat) String() string { return string(f) }
+
+func (f ExportFormat) IsValid() bool {
+	return f == ExportFormatMarkdown || f == ExportFormatHTML
+}
+
+func (f *ExportFormat) UnmarshalJSON(b []byte) error {
+	s, err := unmarshalEnum(b)
+	if err != nil {
+		return err
+	}
+	if v := ExportFormat(s); v.IsValid() {
+		*f = v
+		return nil
+	}
+	return &EnumError{"ExportFormat", s}
+}
+
+func (f ImportFormat) String() string { return string(f) }
+
+func (f ImportFormat) IsValid() bool {
+	switch f {
+	case ImportFormatMarkdown, ImportFormatHTML, ImportFormatPlainText:
+		return true
+	}
+	return false
+}
+
+func (f *ImportFormat) UnmarshalJSON(b []byte) error {
+	s, err := unmarshalEnum(b)
+	if err != nil {
+		return err
+	}
+	if v := ImportFormat(s); v.IsValid() {
+		*f = v
+		return nil
+	}
+	return &EnumError{"ImportFormat", s}
+}
+
+func (p PaperDocUpdatePolicy) String() string { return string(p) }
+
+func (p PaperDocUpdatePolicy) IsValid() bool {
+	switch p {
+	case PaperDocUpdatePolicyAppend, PaperDocUpdatePolicyPrepend, PaperDocUpdatePolicyOverwriteAll:
+		return true
+	}
+	return false
+}
+
+func (p *PaperDocUpdatePolicy) UnmarshalJSON(b []byte) error {
+	s, err := unmarshalEnum(b)
+	if err != nil {
+		return err
+	}
+	if v := PaperDocUpdatePolicy(s); v.IsValid() {
+		*p = v
+		return nil
+	}
+	return &EnumError{"PaperDocUpdatePolicy", s}
+}
+
+func (l PaperDocPermissionLevel) String() string { return string(l) }
+
+// IsValid reports whether l is a known level. Levels come from the API, so
+// like FolderSharingPolicyType, unknown ones are kept when unmarshaling
+// rather than rejected; this flags them.
+func (l PaperDocPermissionLevel) IsValid() bool {
+	return l == PaperDocPermissionLevelEdit || l == PaperDocPermissionLevelViewAndComment
+}
+
+func (l *PaperDocPermissionLevel) UnmarshalJSON(b []byte) error {
+	s, err := unmarshalEnum(b)
+	if err != nil {
+		return err
+	}
+	*l = PaperDocPermissionLevel(s)
+	return nil
+}
+
+func (t FolderSharingPolicyType) String() string { return string(t) }
+
+// IsValid reports whether t is a known policy. Policies come from the API,
+// so unknown ones are kept when unmarshaling rather than rejected; this
+// flags them.
+func (t FolderSharingPolicyType) IsValid() bool {
+	return t == FolderSharingPolicyTeam || t == FolderSharingPolicyInviteOnly
+}
@@ -0,0 +1,39 @@
+package paper
+
+import (
+	"mime"
+	"strings"
+)
+
+// Extensions maps MIME types to the extensions downloads are saved with.
+// Callers can override entries per Syncer with Syncer.Extensions.
+var Extensions = map[string]string{
+	"text/markdown":   ".md",
+	"text/x-markdown": ".md",
+	"text/html":       ".html",
+	"text/plain":      ".txt",
+}
+
+// ExtensionFor returns the extension for a MIME type, looking in overrides
+// before Extensions. Parameters such as charset are ignored. It reports
+// false for unknown types.
+func ExtensionFor(mimeType string, overrides map[string]string) (string, bool) {
+	t, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		t = strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	if ext, ok := overrides[t]; ok {
+		return ext, true
+	}
+	ext, ok := Extensions[t]
+	return ext, ok
+}
+
+// docExt returns the extension to save doc with: the one for its MIME type
+// if known, else the one for its format.
+func docExt(doc *Doc, overrides map[string]string) string {
+	if ext, ok := ExtensionFor(doc.MIME, overrides); ok {
+		return ext
+	}
+	return formatExt(doc.Format)
+}
@@ -0,0 +1,212 @@
+// Package feed renders RSS 2.0 and Atom 1.0 feeds.
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type Feed struct {
+	Title       string
+	Link        string // site URL
+	FeedLink    string // URL the feed itself is served from
+	Description string
+	Author      string
+	Updated     time.Time
+	Items       []Item
+}
+
+type Item struct {
+	ID        string // defaults to Link
+	Title     string
+	Link      string
+	Summary   string
+	Image     string // absolute URL of a cover image, as Media RSS
+	Author    string
+	Published time.Time
+	Updated   time.Time
+}
+
+func (i Item) id() string {
+	if i.ID != "" {
+		return i.ID
+	}
+	return i.Link
+}
+
+func (i Item) updated() time.Time {
+	if i.Updated.IsZero() {
+		return i.Published
+	}
+	return i.Updated
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Media   string     `xml:"xmlns:media,attr,omitempty"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Self          *atomLink `xml:"atom:link,omitempty"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Description string  `xml:"description,omitempty"`
+	Author      string  `xml:"author,omitempty"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+	Image       *media  `xml:"media:content,omitempty"`
+}
+
+// mediaNS is the Media RSS namespace, which both formats use for images.
+const mediaNS = "http://search.yahoo.com/mrss/"
+
+type media struct {
+	URL    string `xml:"url,attr"`
+	Medium string `xml:"medium,attr"`
+}
+
+func (i Item) image() *media {
+	if i.Image == "" {
+		return nil
+	}
+	return &media{URL: i.Image, Medium: "image"}
+}
+
+// mediaNamespace returns the Media RSS namespace if any item has an image.
+func (f *Feed) mediaNamespace() string {
+	for _, i := range f.Items {
+		if i.Image != "" {
+			return mediaNS
+		}
+	}
+	return ""
+}
+
+// RSS renders the feed as RSS 2.0.
+func (f *Feed) RSS() ([]byte, error) {
+	ch := rssChannel{
+		Title:       f.Title,
+		Link:        f.Link,
+		Description: f.Description,
+	}
+	if f.FeedLink != "" {
+		ch.Self = &atomLink{Href: f.FeedLink, Rel: "self", Type: "application/rss+xml"}
+	}
+	if !f.Updated.IsZero() {
+		ch.LastBuildDate = f.Updated.Format(time.RFC1123Z)
+	}
+	for _, i := range f.Items {
+		item := rssItem{
+			Title:       i.Title,
+			Link:        i.Link,
+			GUID:        rssGUID{IsPermaLink: i.ID == "" || i.ID == i.Link, Value: i.id()},
+			Description: i.Summary,
+			Author:      i.Author,
+			Image:       i.image(),
+		}
+		if !i.Published.IsZero() {
+			item.PubDate = i.Published.Format(time.RFC1123Z)
+		}
+		ch.Items = append(ch.Items, item)
+	}
+	return marshal(rss{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Media: f.mediaNamespace(), Channel: ch})
+}
+
+type atom struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Media   string      `xml:"xmlns:media,attr,omitempty"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published,omitempty"`
+	Updated   string      `xml:"updated"`
+	Author    *atomAuthor `xml:"author,omitempty"`
+	Summary   string      `xml:"summary,omitempty"`
+	Image     *media      `xml:"media:content,omitempty"`
+}
+
+// Atom renders the feed as Atom 1.0.
+func (f *Feed) Atom() ([]byte, error) {
+	updated := f.Updated
+	if updated.IsZero() {
+		for _, i := range f.Items {
+			if u := i.updated(); u.After(updated) {
+				updated = u
+			}
+		}
+	}
+	a := atom{
+		Media:   f.mediaNamespace(),
+		Title:   f.Title,
+		ID:      f.Link,
+		Updated: updated.Format(time.RFC3339),
+		Links:   []atomLink{{Href: f.Link, Rel: "alternate"}},
+	}
+	if f.FeedLink != "" {
+		a.Links = append(a.Links, atomLink{Href: f.FeedLink, Rel: "self", Type: "application/atom+xml"})
+	}
+	if f.Author != "" {
+		a.Author = &atomAuthor{Name: f.Author}
+	}
+	for _, i := range f.Items {
+		e := atomEntry{
+			Title:   i.Title,
+			ID:      i.id(),
+			Link:    atomLink{Href: i.Link, Rel: "alternate"},
+			Updated: i.updated().Format(time.RFC3339),
+			Summary: i.Summary,
+			Image:   i.image(),
+		}
+		if !i.Published.IsZero() {
+			e.Published = i.Published.Format(time.RFC3339)
+		}
+		if i.Author != "" {
+			e.Author = &atomAuthor{Name: i.Author}
+		}
+		a.Entries = append(a.Entries, e)
+	}
+	return marshal(a)
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	blob, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(blob, '\n')...), nil
+}
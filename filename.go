@@ -0,0 +1,97 @@
+package paper
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFilename is the longest stem SafeFilename returns, in bytes. Most
+// filesystems allow 255, which leaves room for an extension and a
+// disambiguating suffix.
+const maxFilename = 200
+
+// reservedNames can't be used as file names on Windows, with or without an
+// extension.
+var reservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true,
+	"com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true,
+	"lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// SafeFilename turns a title into a file name that's valid on Windows, macOS
+// and Linux: characters they reserve become hyphens, trailing dots and
+// spaces are dropped, reserved device names are prefixed with an underscore
+// and the result is cut to maxFilename bytes. Unlike Slugify it keeps the
+// title readable. It's normalized to NFC, so titles typed on macOS and Linux
+// give the same name. An empty result becomes "untitled".
+func SafeFilename(title string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			return '-'
+		}
+		return r
+	}, Normalize(title, NFC))
+	name = strings.TrimSpace(name)
+	name = strings.TrimRight(truncateName(name, maxFilename), ". ")
+	if name == "" {
+		return "untitled"
+	}
+	stem := name
+	if i := strings.IndexByte(stem, '.'); i >= 0 {
+		stem = stem[:i]
+	}
+	if reservedNames[strings.ToLower(strings.TrimSpace(stem))] {
+		name = "_" + name
+	}
+	return name
+}
+
+// docFilename returns the name of the file a doc with slug is synced to.
+// Slugs come from titles, so "CON" slugs to "con"; SafeFilename keeps that
+// from naming a reserved device.
+func docFilename(slug, ext string) string {
+	return SafeFilename(slug) + ext
+}
+
+// truncateName cuts s to at most n bytes without splitting a character.
+func truncateName(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// Filenames hands out file names within one directory that don't collide,
+// ignoring case and normalization so they stay distinct on case-insensitive
+// and normalizing filesystems.
+type Filenames map[string]bool
+
+// Unique returns stem+ext, or stem-2+ext, stem-3+ext and so on if that's
+// taken, and marks the result taken.
+func (f Filenames) Unique(stem, ext string) string {
+	name := stem + ext
+	for n := 2; f[filenameKey(name)]; n++ {
+		name = stem + "-" + strconv.Itoa(n) + ext
+	}
+	f.Add(name)
+	return name
+}
+
+// Add marks a name taken.
+func (f Filenames) Add(name string) {
+	f[filenameKey(name)] = true
+}
+
+func filenameKey(name string) string {
+	return strings.ToLower(Normalize(name, NFC))
+}
@@ -0,0 +1,134 @@
+package paper
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// The files endpoints below cover accounts on filesystem-based Paper, where
+// docs are .paper files in Dropbox rather than entries in docs/list. Unlike
+// the rest of the API they also work for app folder apps; paths are
+// normalized with APIClient.path.
+
+type ListFolderArgs struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive,omitempty"`
+}
+
+type ListFolderCursor struct {
+	Cursor string `json:"cursor"`
+}
+
+type ListFolderContinueArgs struct {
+	Cursor string `json:"cursor"`
+}
+
+type Metadata struct {
+	Tag         string `json:".tag"` // "file", "folder" or "deleted"
+	Name        string `json:"name"`
+	ID          string `json:"id,omitempty"`
+	PathLower   string `json:"path_lower"`
+	PathDisplay string `json:"path_display"`
+	Rev         string `json:"rev,omitempty"`
+
+	// Files only.
+	Size           int64     `json:"size,omitempty"`
+	ClientModified time.Time `json:"client_modified"`
+	ServerModified time.Time `json:"server_modified"`
+}
+
+type ListFolderResult struct {
+	Entries []Metadata `json:"entries"`
+	Cursor  string     `json:"cursor"`
+	HasMore bool       `json:"has_more"`
+}
+
+type ListFolderLongpollArgs struct {
+	Cursor  string `json:"cursor"`
+	Timeout int    `json:"timeout,omitempty"` // seconds, 30 to 480
+}
+
+type ListFolderLongpollResult struct {
+	Changes bool `json:"changes"`
+	Backoff int  `json:"backoff,omitempty"` // seconds to wait before polling again
+}
+
+func (c *APIClient) ListFolder(ctx context.Context, in *ListFolderArgs) (*ListFolderResult, error) {
+	var out ListFolderResult
+	args := *in
+	args.Path = c.path(in.Path)
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/files/list_folder", &args, &out)
+}
+
+func (c *APIClient) ListFolderGetLatestCursor(ctx context.Context, in *ListFolderArgs) (*ListFolderCursor, error) {
+	var out ListFolderCursor
+	args := *in
+	args.Path = c.path(in.Path)
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/files/list_folder/get_latest_cursor", &args, &out)
+}
+
+func (c *APIClient) ListFolderContinue(ctx context.Context, in *ListFolderContinueArgs) (*ListFolderResult, error) {
+	var out ListFolderResult
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/files/list_folder/continue", in, &out)
+}
+
+// ListFolderLongpoll blocks until files under the cursor change or the
+// timeout passes. It's called without authorization, as the endpoint
+// requires, so the client's HTTP timeout must exceed in.Timeout.
+func (c *APIClient) ListFolderLongpoll(ctx context.Context, in *ListFolderLongpollArgs) (*ListFolderLongpollResult, error) {
+	var out ListFolderLongpollResult
+	noauth := c.WithToken("")
+	return &out, noauth.rpc(ctx, "https://notify.dropboxapi.com/2/files/list_folder/longpoll", in, &out)
+}
+
+type GetMetadataArgs struct {
+	Path string `json:"path"` // a path or "id:..." file ID
+}
+
+type ListRevisionsArgs struct {
+	Path  string `json:"path"`
+	Limit int    `json:"limit,omitempty"` // 1 to 100, defaults to 10
+}
+
+type ListRevisionsResult struct {
+	IsDeleted bool       `json:"is_deleted"`
+	Entries   []Metadata `json:"entries"` // newest first
+}
+
+func (c *APIClient) GetMetadata(ctx context.Context, in *GetMetadataArgs) (*Metadata, error) {
+	var out Metadata
+	args := *in
+	args.Path = c.path(in.Path)
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/files/get_metadata", &args, &out)
+}
+
+func (c *APIClient) ListRevisions(ctx context.Context, in *ListRevisionsArgs) (*ListRevisionsResult, error) {
+	var out ListRevisionsResult
+	args := *in
+	args.Path = c.path(in.Path)
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/files/list_revisions", &args, &out)
+}
+
+// ListPaperFiles returns the .paper files in a Dropbox folder and its
+// subfolders, paging through files/list_folder.
+func (c *APIClient) ListPaperFiles(ctx context.Context, path string) ([]Metadata, error) {
+	list, err := c.ListFolder(ctx, &ListFolderArgs{Path: path, Recursive: true})
+	if err != nil {
+		return nil, err
+	}
+	var out []Metadata
+	for {
+		for _, e := range list.Entries {
+			if e.Tag == "file" && strings.HasSuffix(e.PathLower, ".paper") {
+				out = append(out, e)
+			}
+		}
+		if !list.HasMore {
+			return out, nil
+		}
+		if list, err = c.ListFolderContinue(ctx, &ListFolderContinueArgs{Cursor: list.Cursor}); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FolderTree assembles the folders GetDocFolderInfo reports docs are in,
+// outermost first, into a hierarchy, so docs can be filed by their full
+// folder path. Folders are kept by ID, so one renamed or moved in Paper is
+// updated everywhere the next time a doc in it is added. The zero value is
+// ready to use.
+type FolderTree struct {
+	// Client looks up the folders of docs Resolve hasn't seen.
+	Client Client
+
+	mu      sync.Mutex
+	roots   []*FolderNode
+	folders map[string]*FolderNode // by folder ID
+	docs    map[DocID]*FolderNode  // by doc ID; nil for docs in no folder
+}
+
+// FolderNode is a folder in a FolderTree.
+type FolderNode struct {
+	Folder
+	Parent   *FolderNode // nil at the top level
+	Children []*FolderNode
+	Docs     []DocID // IDs of the docs directly in the folder
+
+	tree *FolderTree
+}
+
+// Add records the folders a doc is in and returns the innermost one, or
+// nil if the doc isn't in a folder.
+func (t *FolderTree) Add(id DocID, info *FoldersContainingPaperDoc) *FolderNode {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.folders == nil {
+		t.folders = map[string]*FolderNode{}
+		t.docs = map[DocID]*FolderNode{}
+	}
+	var parent *FolderNode
+	for _, f := range info.Folders {
+		n, ok := t.folders[f.ID]
+		if !ok {
+			n = &FolderNode{Folder: Folder{ID: f.ID}, tree: t}
+			t.folders[f.ID] = n
+		}
+		n.Name = f.Name
+		if !ok || n.Parent != parent {
+			t.detach(n)
+			n.Parent = parent
+			if parent == nil {
+				t.roots = append(t.roots, n)
+			} else {
+				parent.Children = append(parent.Children, n)
+			}
+		}
+		parent = n
+	}
+	t.removeDoc(id)
+	t.docs[id] = parent
+	if parent != nil {
+		parent.Docs = append(parent.Docs, id)
+	}
+	return parent
+}
+
+// Resolve returns the innermost folder a doc is in, or nil if it isn't in
+// one, looking its folders up with Client the first time it's asked for.
+func (t *FolderTree) Resolve(ctx context.Context, id DocID) (*FolderNode, error) {
+	t.mu.Lock()
+	n, ok := t.docs[id]
+	t.mu.Unlock()
+	if ok {
+		return n, nil
+	}
+	info, err := t.Client.GetDocFolderInfo(ctx, &RefPaperDoc{DocID: id})
+	if err != nil {
+		return nil, err
+	}
+	return t.Add(id, info), nil
+}
+
+// Folder returns the innermost folder a doc was added in, and false if it
+// hasn't been added.
+func (t *FolderTree) Folder(id DocID) (*FolderNode, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, ok := t.docs[id]
+	return n, ok
+}
+
+// Remove forgets a doc. Folders left empty are kept.
+func (t *FolderTree) Remove(id DocID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.removeDoc(id)
+	delete(t.docs, id)
+}
+
+// Roots returns the top-level folders.
+func (t *FolderTree) Roots() []*FolderNode {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*FolderNode(nil), t.roots...)
+}
+
+func (t *FolderTree) removeDoc(id DocID) {
+	if n := t.docs[id]; n != nil {
+		for i, doc := range n.Docs {
+			if doc == id {
+				n.Docs = append(n.Docs[:i:i], n.Docs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// detach takes a folder out of its parent's children.
+func (t *FolderTree) detach(n *FolderNode) {
+	siblings := &t.roots
+	if n.Parent != nil {
+		siblings = &n.Parent.Children
+	}
+	for i, s := range *siblings {
+		if s == n {
+			*siblings = append((*siblings)[:i:i], (*siblings)[i+1:]...)
+			return
+		}
+	}
+}
+
+// Names returns the names of the folders from the top level down to n.
+func (n *FolderNode) Names() []string {
+	n.tree.mu.Lock()
+	defer n.tree.mu.Unlock()
+	var names []string
+	for ; n != nil; n = n.Parent {
+		names = append([]string{n.Name}, names...)
+	}
+	return names
+}
+
+// Path returns the folder's full path, its names joined by "/".
+func (n *FolderNode) Path() string {
+	return strings.Join(n.Names(), "/")
+}
+
+// Dir returns the "/"-separated directory mirroring the folder on disk.
+// Each name is made safe with SafeFilename, and folders whose names
+// collide with an earlier sibling's are suffixed -2, -3 and so on.
+func (n *FolderNode) Dir() string {
+	n.tree.mu.Lock()
+	defer n.tree.mu.Unlock()
+	var parts []string
+	for ; n != nil; n = n.Parent {
+		parts = append([]string{n.dirName()}, parts...)
+	}
+	return strings.Join(parts, "/")
+}
+
+func (n *FolderNode) dirName() string {
+	siblings := n.tree.roots
+	if n.Parent != nil {
+		siblings = n.Parent.Children
+	}
+	names := Filenames{}
+	for _, s := range siblings {
+		name := names.Unique(SafeFilename(s.Name), "")
+		if s == n {
+			return name
+		}
+	}
+	return SafeFilename(n.Name)
+}
+
+// removeEmptyDirs removes the directory name is in and its parents while
+// they're empty, stopping at root.
+func removeEmptyDirs(root, name string) {
+	root = filepath.Clean(root)
+	for dir := filepath.Dir(filepath.Join(root, name)); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// ListDocs returns the IDs of the docs in a folder or its subfolders, in
+// docs/list order. folder is a folder ID or a full path as FolderNode.Path
+// gives it, compared ignoring case. docs/list can't be limited to a
+// folder, so each doc's folders are looked up, once per tree.
+func (t *FolderTree) ListDocs(ctx context.Context, folder string) ([]DocID, error) {
+	ids, err := ListAllDocIDs(ctx, t.Client, &ListPaperDocsArgs{})
+	if err != nil {
+		return nil, err
+	}
+	path := strings.Trim(folder, "/")
+	var out []DocID
+	for _, id := range ids {
+		n, err := t.Resolve(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("folder info %s: %w", id, err)
+		}
+		for ; n != nil; n = n.Parent {
+			if n.ID == folder || strings.EqualFold(n.Path(), path) {
+				out = append(out, id)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// ListDocsInFolder returns the IDs of the docs in a folder or its
+// subfolders; see FolderTree.ListDocs. On filesystem-based Paper, where
+// folders are Dropbox folders, use APIClient.ListPaperFiles.
+func ListDocsInFolder(ctx context.Context, c Client, folder string) ([]DocID, error) {
+	return (&FolderTree{Client: c}).ListDocs(ctx, folder)
+}
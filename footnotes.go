@@ -0,0 +1,234 @@
+package paper
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Footnotes rebuilds the superscript numbers Paper exports for footnotes
+// into markdown footnotes: references become [^1] and the notes move to the
+// end of the doc as definitions. A number is only treated as a footnote when
+// the doc has both a reference to it and a note for it.
+//
+// Footnotes works on markdown; run MarkdownConverter first for HTML exports.
+type Footnotes struct {
+	// Inline replaces each reference with its note in parentheses instead
+	// of producing footnote definitions.
+	Inline bool
+}
+
+const superscripts = "⁰¹²³⁴⁵⁶⁷⁸⁹"
+
+// superscriptDigit maps a superscript digit to its value.
+var superscriptDigit = map[rune]int{}
+
+func init() {
+	for i, r := range []rune(superscripts) {
+		superscriptDigit[r] = i
+	}
+}
+
+var (
+	footnoteRef = regexp.MustCompile(`<sup>\s*(\d+)\s*</sup>|\^(\d+)|\\?\[(\d+)\\?\]|([` + superscripts + `]+)`)
+	footnoteDef = regexp.MustCompile(`^\s*(?:<sup>\s*(\d+)\s*</sup>|\^(\d+)|\\?\[(\d+)\\?\]|([` + superscripts + `]+))[:.]?\s+(.+)$`)
+	notesHead   = regexp.MustCompile(`(?i)^#{1,6}\s*(foot)?notes\s*#*\s*$`)
+
+	// linkDef matches a reference-style link definition, [1]: <url>.
+	linkDef = regexp.MustCompile(`(?i)^ {0,3}\[\d+\]:\s*<?(?:[a-z][a-z0-9+.-]*:|/|#|\./|\.\./)\S*(?:\s+["'(].*)?\s*$`)
+)
+
+// footnoteRefs returns the submatch indexes of the footnote references in
+// a line, leaving out those in code and math spans, reference-style links
+// such as [text][1] and exponents such as mc^2.
+func footnoteRefs(line string) [][]int {
+	skip := append(codeSpans(line), mathSpans(line)...)
+	var refs [][]int
+next:
+	for _, m := range footnoteRef.FindAllStringSubmatchIndex(line, -1) {
+		for _, s := range skip {
+			if m[0] < s[1] && m[1] > s[0] {
+				continue next
+			}
+		}
+		switch {
+		case m[6] >= 0: // [n]
+			if m[0] > 0 && line[m[0]-1] == ']' || m[1] < len(line) && strings.ContainsRune("([:", rune(line[m[1]])) {
+				continue
+			}
+		case m[4] >= 0: // ^n
+			if isExponent(line[:m[0]]) {
+				continue
+			}
+		}
+		refs = append(refs, m)
+	}
+	return refs
+}
+
+// isExponent reports whether a caret after before raises something to a
+// power rather than marking a footnote: it follows a number, a closing
+// parenthesis, a formula or a word of one or two letters, as in 10^6,
+// (a+b)^2, E=mc^2 and x^2.
+func isExponent(before string) bool {
+	word := before[strings.LastIndexAny(before, " \t")+1:]
+	return len(word) <= 2 || strings.ContainsAny(word, "0123456789()=+-*/")
+}
+
+func (f *Footnotes) Transform(doc *Doc) error {
+	if doc.Format == ExportFormatHTML {
+		return nil
+	}
+	lines := strings.Split(string(doc.Content), "\n")
+	code := literalLines(lines)
+	for i, line := range lines {
+		code[i] = code[i] || linkDef.MatchString(line)
+	}
+
+	type def struct {
+		n    int
+		note string
+	}
+	defs := map[int]def{}
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		if m := footnoteDef.FindStringSubmatch(line); m != nil {
+			if n := footnoteNumber(m[1:5]); n > 0 {
+				defs[i] = def{n, strings.TrimSpace(m[5])}
+			}
+		}
+	}
+	referenced := map[int]bool{}
+	for i, line := range lines {
+		if _, ok := defs[i]; ok || code[i] {
+			continue
+		}
+		for _, m := range footnoteRefs(line) {
+			referenced[footnoteNumber(submatches(line, m)[1:])] = true
+		}
+	}
+	notes := map[int]string{}
+	defLine := map[int]bool{}
+	for i, d := range defs {
+		if _, dup := notes[d.n]; referenced[d.n] && !dup {
+			notes[d.n] = d.note
+			defLine[i] = true
+		}
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+
+	var out []string
+	for i, line := range lines {
+		if defLine[i] {
+			continue
+		}
+		if _, ok := defs[i]; !ok && !code[i] {
+			var b strings.Builder
+			start := 0
+			for _, m := range footnoteRefs(line) {
+				n := footnoteNumber(submatches(line, m)[1:])
+				note, ok := notes[n]
+				if !ok {
+					continue
+				}
+				b.WriteString(line[start:m[0]])
+				if f.Inline {
+					b.WriteString(" (" + note + ")")
+				} else {
+					b.WriteString("[^" + strconv.Itoa(n) + "]")
+				}
+				start = m[1]
+			}
+			line = b.String() + line[start:]
+		}
+		out = append(out, line)
+	}
+
+	out = dropEmptyNotesHeading(out)
+	content := strings.TrimRight(strings.Join(out, "\n"), "\n")
+	if !f.Inline {
+		var nums []int
+		for n := range notes {
+			nums = append(nums, n)
+		}
+		sort.Ints(nums)
+		content += "\n"
+		for _, n := range nums {
+			content += "\n[^" + strconv.Itoa(n) + "]: " + notes[n]
+		}
+	}
+	doc.Content = []byte(content + "\n")
+	return nil
+}
+
+// submatches returns the text of the submatches at indexes m in s.
+func submatches(s string, m []int) []string {
+	out := make([]string, len(m)/2)
+	for i := range out {
+		if m[2*i] >= 0 {
+			out[i] = s[m[2*i]:m[2*i+1]]
+		}
+	}
+	return out
+}
+
+func footnoteNumber(groups []string) int {
+	for _, g := range groups {
+		if g == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(g); err == nil {
+			return n
+		}
+		n := 0
+		for _, r := range g {
+			n = n*10 + superscriptDigit[r]
+		}
+		return n
+	}
+	return 0
+}
+
+// dropEmptyNotesHeading removes a "Notes" or "Footnotes" heading left with
+// nothing under it once its notes have been moved.
+func dropEmptyNotesHeading(lines []string) []string {
+	for i, line := range lines {
+		if !notesHead.MatchString(line) {
+			continue
+		}
+		j := i + 1
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+			j++
+		}
+		if j == len(lines) || strings.HasPrefix(lines[j], "#") {
+			return append(lines[:i:i], lines[j:]...)
+		}
+	}
+	return lines
+}
+
+// codeLines marks the lines that are inside fenced code blocks, fences
+// included.
+func codeLines(lines []string) []bool {
+	code := make([]bool, len(lines))
+	fence := ""
+	for i, line := range lines {
+		if m := codeFence.FindStringSubmatch(line); m != nil {
+			code[i] = true
+			switch {
+			case fence == "":
+				fence = m[1]
+			case strings.HasPrefix(m[1], fence[:1]) && len(m[1]) >= len(fence):
+				fence = ""
+			}
+			continue
+		}
+		code[i] = fence != ""
+	}
+	return code
+}
@@ -0,0 +1,42 @@
+package paper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// frontMatter prepends YAML front matter describing a doc to its markdown,
+// for static site generators such as Hugo and Jekyll that read it there.
+func frontMatter(doc *Doc, e *ManifestEntry) []byte {
+	var b bytes.Buffer
+	title, _ := json.Marshal(doc.Title) // JSON strings are YAML strings
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", title)
+	fmt.Fprintf(&b, "id: %s\n", doc.ID)
+	if !e.Created.IsZero() {
+		fmt.Fprintf(&b, "date: %s\n", e.Created.Format(time.RFC3339))
+	}
+	if !e.Updated.IsZero() {
+		fmt.Fprintf(&b, "lastmod: %s\n", e.Updated.Format(time.RFC3339))
+	}
+	if e.ReadingTime > 0 {
+		fmt.Fprintf(&b, "reading_time: %d\n", e.ReadingTime)
+	}
+	b.WriteString("---\n\n")
+	b.Write(doc.Content)
+	return b.Bytes()
+}
+
+var frontMatterBlock = regexp.MustCompile(`\A---\n(?:[a-z_]+: [^\n]*\n)+---\n\n?`)
+
+// stripFrontMatter returns markdown without the front matter a Syncer
+// with FrontMatter set starts it with.
+func stripFrontMatter(content []byte) []byte {
+	if loc := frontMatterBlock.FindIndex(content); loc != nil {
+		return content[loc[1]:]
+	}
+	return content
+}
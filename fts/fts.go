@@ -0,0 +1,293 @@
+// Package fts is a small full-text index over doc titles and bodies, with
+// BM25 ranking. The index keeps the text it indexes and is saved as JSON;
+// postings are rebuilt in memory when it's opened.
+package fts
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// titleWeight counts each title term as this many body terms.
+const titleWeight = 3
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+type Doc struct {
+	ID       string `json:"id"`
+	Revision int64  `json:"revision"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+}
+
+type Hit struct {
+	ID      string
+	Title   string
+	Score   float64
+	Snippet string
+}
+
+type Index struct {
+	Path string // where Save writes the index
+
+	mu       sync.RWMutex
+	docs     map[string]*Doc
+	postings map[string]map[string]int // term to doc ID to term frequency
+	lengths  map[string]int            // weighted doc lengths
+	total    int
+}
+
+func New(path string) *Index {
+	return &Index{
+		Path:     path,
+		docs:     map[string]*Doc{},
+		postings: map[string]map[string]int{},
+		lengths:  map[string]int{},
+	}
+}
+
+// Open loads the index saved at path. A missing file yields an empty index.
+func Open(path string) (*Index, error) {
+	idx := New(path)
+	blob, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var docs []*Doc
+	if err := json.Unmarshal(blob, &docs); err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		idx.add(d)
+	}
+	return idx, nil
+}
+
+// Save writes the index to Path.
+func (idx *Index) Save() error {
+	idx.mu.RLock()
+	docs := make([]*Doc, 0, len(idx.docs))
+	for _, d := range idx.docs {
+		docs = append(docs, d)
+	}
+	idx.mu.RUnlock()
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	blob, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.Path), 0755); err != nil {
+		return err
+	}
+	tmp := idx.Path + ".tmp"
+	if err := os.WriteFile(tmp, blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, idx.Path)
+}
+
+// Revision returns the indexed revision of a doc, or zero.
+func (idx *Index) Revision(id string) int64 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if d, ok := idx.docs[id]; ok {
+		return d.Revision
+	}
+	return 0
+}
+
+// Update indexes d, replacing any earlier revision. It reports false, and
+// does nothing, when that revision is already indexed.
+func (idx *Index) Update(d Doc) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if old, ok := idx.docs[d.ID]; ok {
+		if old.Revision == d.Revision && d.Revision != 0 {
+			return false
+		}
+		idx.remove(d.ID)
+	}
+	idx.add(&d)
+	return true
+}
+
+func (idx *Index) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+// IDs returns the ID of every indexed doc.
+func (idx *Index) IDs() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	ids := make([]string, 0, len(idx.docs))
+	for id := range idx.docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (idx *Index) add(d *Doc) {
+	idx.docs[d.ID] = d
+	freq := map[string]int{}
+	for _, t := range Tokenize(d.Title) {
+		freq[t] += titleWeight
+	}
+	for _, t := range Tokenize(d.Body) {
+		freq[t]++
+	}
+	n := 0
+	for t, f := range freq {
+		if idx.postings[t] == nil {
+			idx.postings[t] = map[string]int{}
+		}
+		idx.postings[t][d.ID] = f
+		n += f
+	}
+	idx.lengths[d.ID] = n
+	idx.total += n
+}
+
+func (idx *Index) remove(id string) {
+	if _, ok := idx.docs[id]; !ok {
+		return
+	}
+	for t, docs := range idx.postings {
+		if _, ok := docs[id]; ok {
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(idx.postings, t)
+			}
+		}
+	}
+	idx.total -= idx.lengths[id]
+	delete(idx.lengths, id)
+	delete(idx.docs, id)
+}
+
+// Search returns the docs containing every term in query, best match first.
+// A limit of zero returns every match.
+func (idx *Index) Search(query string, limit int) []Hit {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	n := float64(len(idx.docs))
+	avg := float64(idx.total) / n
+	scores := map[string]float64{}
+	for i, t := range terms {
+		docs := idx.postings[t]
+		idf := math.Log(1 + (n-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+		next := map[string]float64{}
+		for id, f := range docs {
+			if _, ok := scores[id]; !ok && i > 0 {
+				continue
+			}
+			tf := float64(f)
+			norm := k1 * (1 - b + b*float64(idx.lengths[id])/avg)
+			next[id] = scores[id] + idf*tf*(k1+1)/(tf+norm)
+		}
+		scores = next
+	}
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		d := idx.docs[id]
+		hits = append(hits, Hit{ID: id, Title: d.Title, Score: score, Snippet: snippet(d.Body, terms)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Tokenize splits text into lowercase words.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// snippetWidth is roughly how many bytes of context a snippet has.
+const snippetWidth = 160
+
+// snippet returns the part of body around the first query term, or its
+// start when no term appears in it.
+func snippet(body string, terms []string) string {
+	lower := strings.ToLower(body)
+	at := -1
+	for _, t := range terms {
+		if i := indexWord(lower, t); i >= 0 && (at < 0 || i < at) {
+			at = i
+		}
+	}
+	if at > len(body) {
+		at = -1
+	}
+	start := 0
+	if at > snippetWidth/3 {
+		start = at - snippetWidth/3
+	}
+	end := start + snippetWidth
+	if end > len(body) {
+		end = len(body)
+	}
+	// Widen to whole words.
+	for start > 0 && !unicode.IsSpace(rune(body[start-1])) {
+		start--
+	}
+	for end < len(body) && !unicode.IsSpace(rune(body[end])) {
+		end++
+	}
+	s := strings.Join(strings.Fields(body[start:end]), " ")
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(body) {
+		s += "…"
+	}
+	return s
+}
+
+// indexWord finds term in s at a word boundary. Lowercasing can change byte
+// lengths in rare cases, so the result is only used as an approximate
+// position.
+func indexWord(s, term string) int {
+	for off := 0; ; {
+		i := strings.Index(s[off:], term)
+		if i < 0 {
+			return -1
+		}
+		i += off
+		if i == 0 || !isWordByte(s[i-1]) {
+			return i
+		}
+		off = i + len(term)
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c >= 0x80 || c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z'
+}
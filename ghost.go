@@ -0,0 +1,161 @@
+package paper
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Ghost's importer reads a JSON dump of its database tables. Only the
+// tables needed to recreate posts, tags and authors are written.
+
+type ghostExport struct {
+	DB []ghostDB `json:"db"`
+}
+
+type ghostDB struct {
+	Meta ghostMeta `json:"meta"`
+	Data ghostData `json:"data"`
+}
+
+type ghostMeta struct {
+	ExportedOn int64  `json:"exported_on"`
+	Version    string `json:"version"`
+}
+
+type ghostData struct {
+	Posts        []ghostPost     `json:"posts"`
+	Tags         []ghostTag      `json:"tags"`
+	PostsTags    []ghostPostTag  `json:"posts_tags"`
+	Users        []ghostUser     `json:"users"`
+	PostsAuthors []ghostPostUser `json:"posts_authors"`
+}
+
+type ghostPost struct {
+	ID            string    `json:"id"`
+	Title         string    `json:"title"`
+	Slug          string    `json:"slug"`
+	Featured      bool      `json:"featured"`
+	Mobiledoc     string    `json:"mobiledoc"`
+	HTML          string    `json:"html"`
+	FeatureImage  string    `json:"feature_image,omitempty"`
+	Status        string    `json:"status"`
+	Type          string    `json:"type"`
+	CustomExcerpt string    `json:"custom_excerpt,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	PublishedAt   time.Time `json:"published_at"`
+}
+
+type ghostTag struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type ghostPostTag struct {
+	PostID    string `json:"post_id"`
+	TagID     string `json:"tag_id"`
+	SortOrder int    `json:"sort_order"`
+}
+
+type ghostUser struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Email string `json:"email"`
+}
+
+type ghostPostUser struct {
+	PostID    string `json:"post_id"`
+	AuthorID  string `json:"author_id"`
+	SortOrder int    `json:"sort_order"`
+}
+
+// ghostMobiledoc wraps HTML in a mobiledoc with a single HTML card, which
+// Ghost's editor shows as an editable block.
+func ghostMobiledoc(html string) (string, error) {
+	blob, err := json.Marshal(map[string]interface{}{
+		"version":  "0.3.1",
+		"atoms":    []interface{}{},
+		"markups":  []interface{}{},
+		"cards":    []interface{}{[]interface{}{"html", map[string]string{"html": html}}},
+		"sections": []interface{}{[]interface{}{10, 0}},
+	})
+	return string(blob), err
+}
+
+// Ghost renders posts as a Ghost import file. Each post's category becomes
+// its primary tag, followed by its hashtags, and doc owners become authors.
+// Asset links are made absolute, so BaseURL should be set.
+func (s *Site) Ghost(posts []*Post) ([]byte, error) {
+	var data ghostData
+	users := map[string]string{} // email to user ID
+	tags := map[string]string{}  // slug to tag ID
+	addTag := func(name, slug string) string {
+		if id, ok := tags[slug]; ok {
+			return id
+		}
+		id := strconv.Itoa(len(tags) + 1)
+		tags[slug] = id
+		data.Tags = append(data.Tags, ghostTag{ID: id, Name: name, Slug: slug})
+		return id
+	}
+	for i, p := range posts {
+		body := assetRef.ReplaceAllStringFunc(string(RenderHTML(p.Format, p.Content)), s.absURL)
+		mobiledoc, err := ghostMobiledoc(body)
+		if err != nil {
+			return nil, err
+		}
+		id := strconv.Itoa(i + 1)
+		created := p.Created
+		if created.IsZero() {
+			created = p.Published
+		}
+		updated := p.Updated
+		if updated.IsZero() {
+			updated = p.Published
+		}
+		data.Posts = append(data.Posts, ghostPost{
+			ID:            id,
+			Title:         p.Title,
+			Slug:          p.Slug,
+			Featured:      p.Pinned,
+			Mobiledoc:     mobiledoc,
+			HTML:          body,
+			FeatureImage:  p.Image,
+			Status:        "published",
+			Type:          "post",
+			CustomExcerpt: p.Summary,
+			CreatedAt:     created.UTC(),
+			UpdatedAt:     updated.UTC(),
+			PublishedAt:   p.Published.UTC(),
+		})
+
+		var order int
+		if p.Category != nil {
+			data.PostsTags = append(data.PostsTags, ghostPostTag{PostID: id, TagID: addTag(p.Category.Name, p.Category.Slug)})
+			order++
+		}
+		for _, t := range p.Tags {
+			data.PostsTags = append(data.PostsTags, ghostPostTag{PostID: id, TagID: addTag(t, Slugify(t)), SortOrder: order})
+			order++
+		}
+
+		if p.Owner == "" {
+			continue
+		}
+		uid, ok := users[p.Owner]
+		if !ok {
+			uid = strconv.Itoa(len(users) + 1)
+			users[p.Owner] = uid
+			name := ownerLogin(p.Owner)
+			data.Users = append(data.Users, ghostUser{ID: uid, Name: name, Slug: Slugify(name), Email: p.Owner})
+		}
+		data.PostsAuthors = append(data.PostsAuthors, ghostPostUser{PostID: id, AuthorID: uid})
+	}
+	return json.MarshalIndent(ghostExport{DB: []ghostDB{{
+		Meta: ghostMeta{ExportedOn: time.Now().UnixNano() / int64(time.Millisecond), Version: "5.0.0"},
+		Data: data,
+	}}}, "", "  ")
+}
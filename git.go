@@ -0,0 +1,109 @@
+package paper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// GitHook commits the sync directory to the git repository containing it,
+// one commit per sync, so the history of the content can be reviewed with
+// the usual git tools.
+type GitHook struct {
+	Dir string // a directory inside the repository, usually Syncer.Dir
+
+	// Manifest, when set, is used to name docs by title in commit messages.
+	Manifest *Manifest
+
+	Author string // "Name <email>", defaults to git's configured identity
+
+	// Push pushes the commit to Remote, defaulting to "origin", after
+	// committing.
+	Push   bool
+	Remote string
+}
+
+func (g *GitHook) Run(ctx context.Context, res *SyncResult) error {
+	if _, err := runGit(ctx, g.Dir, "add", "-A", "."); err != nil {
+		return err
+	}
+	// Nothing staged means the changes were already committed.
+	if _, err := runGit(ctx, g.Dir, "diff", "--cached", "--quiet", "--", "."); err == nil {
+		return nil
+	}
+	args := []string{"commit", "-q", "-m", g.message(res)}
+	if g.Author != "" {
+		args = append(args, "--author", g.Author)
+	}
+	if _, err := runGit(ctx, g.Dir, append(args, "--", ".")...); err != nil {
+		return err
+	}
+	if !g.Push {
+		return nil
+	}
+	remote := g.Remote
+	if remote == "" {
+		remote = "origin"
+	}
+	_, err := runGit(ctx, g.Dir, "push", "-q", remote, "HEAD")
+	return err
+}
+
+// message summarizes res as a commit message: counts in the subject and the
+// affected docs in the body.
+func (g *GitHook) message(res *SyncResult) string {
+	var counts []string
+	for _, c := range []struct {
+		n    int
+		verb string
+	}{{len(res.Added), "added"}, {len(res.Modified), "modified"}, {len(res.Removed), "removed"}} {
+		if c.n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", c.n, c.verb))
+		}
+	}
+	var b strings.Builder
+	b.WriteString("Sync Paper docs: " + strings.Join(counts, ", ") + "\n")
+	list := func(verb string, ids []DocID) {
+		for _, id := range ids {
+			name := string(id)
+			if g.Manifest != nil {
+				if e, ok := g.Manifest.Get(id); ok && e.Title != "" {
+					name = e.Title + " (" + name + ")"
+				}
+			}
+			b.WriteString("\n" + verb + " " + name)
+		}
+	}
+	list("Add", res.Added)
+	list("Update", res.Modified)
+	list("Remove", res.Removed)
+	return b.String() + "\n"
+}
+
+// runGit runs git in dir, returning its standard output. Failures include
+// git's standard error.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	return runGitEnv(ctx, dir, nil, args...)
+}
+
+// runGitEnv is runGit with env added to the environment.
+func runGitEnv(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
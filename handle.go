@@ -0,0 +1,98 @@
+package paper
+
+import (
+	"context"
+	"sync"
+)
+
+// DocHandle is a doc bound to the client it's fetched with, for calling the
+// doc endpoints without passing its ID each time. The metadata from the
+// latest download or update is cached on the handle.
+type DocHandle struct {
+	ID     DocID
+	client *APIClient
+
+	mu   sync.Mutex
+	meta *PaperDocExportResult
+}
+
+// Doc returns a handle to the doc with id. Nothing is fetched until a method
+// is called.
+func (c *APIClient) Doc(id DocID) *DocHandle {
+	return &DocHandle{ID: id, client: c}
+}
+
+func (d *DocHandle) remember(res *PaperDocExportResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	meta := *res
+	d.meta = &meta
+}
+
+// Metadata returns the doc's cached metadata, fetching it if there is none.
+func (d *DocHandle) Metadata(ctx context.Context) (*PaperDocExportResult, error) {
+	d.mu.Lock()
+	meta := d.meta
+	d.mu.Unlock()
+	if meta != nil {
+		res := *meta
+		return &res, nil
+	}
+	return d.Refresh(ctx)
+}
+
+// Refresh fetches the doc's current metadata, without its content.
+func (d *DocHandle) Refresh(ctx context.Context) (*PaperDocExportResult, error) {
+	res, err := d.client.Metadata(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	d.remember(res)
+	return res, nil
+}
+
+func (d *DocHandle) Download(ctx context.Context, format ExportFormat) ([]byte, error) {
+	res, content, err := d.client.DownloadDoc(ctx, &PaperDocExport{DocID: d.ID, Format: format})
+	if err != nil {
+		return nil, err
+	}
+	d.remember(res)
+	return content, nil
+}
+
+func (d *DocHandle) FolderInfo(ctx context.Context) (*FoldersContainingPaperDoc, error) {
+	return d.client.GetDocFolderInfo(ctx, &RefPaperDoc{DocID: d.ID})
+}
+
+// Update applies content to the doc at its cached revision, so it fails
+// with "revision_mismatch" if the doc changed since it was last fetched.
+func (d *DocHandle) Update(ctx context.Context, policy PaperDocUpdatePolicy, format ImportFormat, content []byte) (*PaperDocCreateUpdateResult, error) {
+	meta, err := d.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := d.client.UpdateDoc(ctx, &PaperDocUpdateArgs{
+		DocID:        d.ID,
+		UpdatePolicy: policy,
+		Revision:     meta.Revision,
+		ImportFormat: format,
+	}, content)
+	if err != nil {
+		return nil, err
+	}
+	meta.Revision = res.Revision
+	if res.Title != "" {
+		meta.Title = res.Title
+	}
+	d.remember(meta)
+	return res, nil
+}
+
+// Share adds users to the doc by email.
+func (d *DocHandle) Share(ctx context.Context, level PaperDocPermissionLevel, emails ...string) ([]AddPaperDocUserMemberResult, error) {
+	in := &AddPaperDocUser{DocID: d.ID}
+	for _, e := range emails {
+		in.Members = append(in.Members, AddMember{Member: EmailMember(e), PermissionLevel: level})
+	}
+	return d.client.AddDocUsers(ctx, in)
+}
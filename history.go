@@ -0,0 +1,187 @@
+package paper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ErrRevisionNotFound is returned when History has no matching revision.
+var ErrRevisionNotFound = errors.New("paper: revision not found")
+
+// History keeps previous exports of docs on disk, since the API only serves
+// the latest revision. Exports are gzipped and stored by content hash under
+// Dir/objects, with a JSON index per doc.
+type History struct {
+	Dir  string
+	Keep int // revisions kept per doc, defaults to 10
+}
+
+type HistoryEntry struct {
+	Revision int64        `json:"revision"`
+	Time     time.Time    `json:"time"`
+	Format   ExportFormat `json:"format"`
+	Hash     string       `json:"hash"`
+}
+
+// Record stores doc's content unless its revision is already recorded.
+func (h *History) Record(doc *Doc) error {
+	entries, err := h.Revisions(doc.ID)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Revision == doc.Revision && e.Format == doc.Format {
+			return nil
+		}
+	}
+	sum := sha256.Sum256(doc.Content)
+	hash := hex.EncodeToString(sum[:])
+	if _, err := os.Stat(h.object(hash)); os.IsNotExist(err) {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		zw.Write(doc.Content)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(h.object(hash), b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	entries = append([]HistoryEntry{{
+		Revision: doc.Revision,
+		Time:     time.Now().UTC(),
+		Format:   doc.Format,
+		Hash:     hash,
+	}}, entries...)
+	keep := h.Keep
+	if keep <= 0 {
+		keep = 10
+	}
+	var pruned []HistoryEntry
+	if len(entries) > keep {
+		entries, pruned = entries[:keep], entries[keep:]
+	}
+	blob, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(h.index(doc.ID), blob, 0644); err != nil {
+		return err
+	}
+	return h.prune(pruned)
+}
+
+// prune removes the objects of pruned entries that no doc still refers to.
+func (h *History) prune(pruned []HistoryEntry) error {
+	if len(pruned) == 0 {
+		return nil
+	}
+	used := map[string]bool{}
+	indexes, err := filepath.Glob(filepath.Join(h.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, name := range indexes {
+		var entries []HistoryEntry
+		blob, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(blob, &entries); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			used[e.Hash] = true
+		}
+	}
+	for _, e := range pruned {
+		if used[e.Hash] {
+			continue
+		}
+		if err := os.Remove(h.object(e.Hash)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Revisions lists a doc's recorded revisions, newest first.
+func (h *History) Revisions(id DocID) ([]HistoryEntry, error) {
+	blob, err := os.ReadFile(h.index(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []HistoryEntry
+	if err := json.Unmarshal(blob, &entries); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	return entries, nil
+}
+
+// Get returns the content of a recorded revision.
+func (h *History) Get(id DocID, revision int64) (*HistoryEntry, []byte, error) {
+	entries, err := h.Revisions(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range entries {
+		if entries[i].Revision == revision {
+			return h.load(&entries[i])
+		}
+	}
+	return nil, nil, ErrRevisionNotFound
+}
+
+// At returns the revision that was current at t: the newest one recorded at
+// or before it.
+func (h *History) At(id DocID, t time.Time) (*HistoryEntry, []byte, error) {
+	entries, err := h.Revisions(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range entries {
+		if !entries[i].Time.After(t) {
+			return h.load(&entries[i])
+		}
+	}
+	return nil, nil, ErrRevisionNotFound
+}
+
+func (h *History) load(e *HistoryEntry) (*HistoryEntry, []byte, error) {
+	f, err := os.Open(h.object(e.Hash))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, content, nil
+}
+
+func (h *History) index(id DocID) string {
+	return filepath.Join(h.Dir, url.PathEscape(string(id))+".json")
+}
+
+func (h *History) object(hash string) string {
+	return filepath.Join(h.Dir, "objects", hash[:2], hash[2:]+".gz")
+}
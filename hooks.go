@@ -0,0 +1,119 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Hook runs after a sync that changed something, typically to deploy the
+// new content.
+type Hook interface {
+	Run(ctx context.Context, res *SyncResult) error
+}
+
+type HookFunc func(ctx context.Context, res *SyncResult) error
+
+func (f HookFunc) Run(ctx context.Context, res *SyncResult) error {
+	return f(ctx, res)
+}
+
+// HTTPHook requests a URL, such as a Netlify, Vercel or Cloudflare Pages
+// build hook.
+type HTTPHook struct {
+	URL    string
+	Method string // defaults to POST
+	Header http.Header
+	HTTP   *http.Client
+}
+
+func (h *HTTPHook) Run(ctx context.Context, res *SyncResult) error {
+	method := h.Method
+	if method == "" {
+		method = "POST"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.URL, nil)
+	if err != nil {
+		return redactURLError(err)
+	}
+	for k, v := range h.Header {
+		req.Header[k] = v
+	}
+	client := h.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return redactURLError(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s: %s", method, redactURL(h.URL), resp.Status)
+	}
+	return nil
+}
+
+// redactURL shortens a URL to its scheme and host for errors, since build
+// hook and webhook URLs carry their secret in the path or query.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "<redacted>"
+	}
+	return u.Scheme + "://" + u.Host + "/..."
+}
+
+// redactURLError redacts the URL in the errors net/http returns.
+func redactURLError(err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		ue.URL = redactURL(ue.URL)
+	}
+	return err
+}
+
+// CommandHook runs a command. The changed doc IDs are passed in the
+// PAPER_ADDED, PAPER_MODIFIED and PAPER_REMOVED environment variables,
+// space-separated.
+type CommandHook struct {
+	Name   string
+	Args   []string
+	Dir    string
+	Env    []string // added to the current environment
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+func (h *CommandHook) Run(ctx context.Context, res *SyncResult) error {
+	cmd := exec.CommandContext(ctx, h.Name, h.Args...)
+	cmd.Dir = h.Dir
+	cmd.Stdout = h.Stdout
+	cmd.Stderr = h.Stderr
+	cmd.Env = append(os.Environ(), h.Env...)
+	cmd.Env = append(cmd.Env,
+		"PAPER_ADDED="+joinIDs(res.Added),
+		"PAPER_MODIFIED="+joinIDs(res.Modified),
+		"PAPER_REMOVED="+joinIDs(res.Removed),
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", h.Name, err)
+	}
+	return nil
+}
+
+// joinIDs lists doc IDs separated by spaces.
+func joinIDs(ids []DocID) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = string(id)
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,383 @@
+package paper
+
+import (
+	"html"
+	"strings"
+)
+
+// A minimal HTML tokenizer. It's forgiving rather than spec compliant,
+// which is plenty for the markup Paper exports.
+
+type htmlTokenType int
+
+const (
+	htmlText htmlTokenType = iota
+	htmlStartTag
+	htmlEndTag
+	htmlSelfClosingTag
+	htmlComment
+	htmlDoctype
+)
+
+type htmlAttr struct {
+	Key string
+	Val string
+}
+
+type htmlToken struct {
+	Type  htmlTokenType
+	Data  string // lowercased tag name, or raw text for text and comments
+	Attrs []htmlAttr
+}
+
+func (t htmlToken) attr(key string) (string, bool) {
+	for _, a := range t.Attrs {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// String renders the token back to HTML, escaping attribute values.
+func (t htmlToken) String() string {
+	switch t.Type {
+	case htmlStartTag, htmlSelfClosingTag:
+		var b strings.Builder
+		b.WriteString("<" + t.Data)
+		for _, a := range t.Attrs {
+			b.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+		}
+		if t.Type == htmlSelfClosingTag {
+			b.WriteString(" /")
+		}
+		b.WriteString(">")
+		return b.String()
+	case htmlEndTag:
+		return "</" + t.Data + ">"
+	case htmlComment:
+		return "<!--" + t.Data + "-->"
+	case htmlDoctype:
+		return "<!" + t.Data + ">"
+	}
+	return t.Data
+}
+
+var htmlRawText = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"textarea": true,
+	"title":    true,
+}
+
+var htmlVoid = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+func tokenizeHTML(s string) []htmlToken {
+	var toks []htmlToken
+	text := func(t string) {
+		if t == "" {
+			return
+		}
+		if n := len(toks); n > 0 && toks[n-1].Type == htmlText {
+			toks[n-1].Data += t
+			return
+		}
+		toks = append(toks, htmlToken{Type: htmlText, Data: t})
+	}
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			text(s)
+			break
+		}
+		text(s[:i])
+		s = s[i:]
+		switch {
+		case strings.HasPrefix(s, "<!--"):
+			end := strings.Index(s[4:], "-->")
+			if end < 0 {
+				toks = append(toks, htmlToken{Type: htmlComment, Data: s[4:]})
+				s = ""
+				continue
+			}
+			toks = append(toks, htmlToken{Type: htmlComment, Data: s[4 : 4+end]})
+			s = s[4+end+3:]
+		case strings.HasPrefix(s, "<!"), strings.HasPrefix(s, "<?"):
+			data, rest := cutTag(s[2:])
+			toks = append(toks, htmlToken{Type: htmlDoctype, Data: data})
+			s = rest
+		case strings.HasPrefix(s, "</") && len(s) > 2 && isTagStart(s[2]):
+			data, rest := cutTag(s[2:])
+			name := strings.ToLower(strings.TrimSpace(data))
+			if sp := strings.IndexAny(name, " \t\n\r"); sp >= 0 {
+				name = name[:sp]
+			}
+			toks = append(toks, htmlToken{Type: htmlEndTag, Data: name})
+			s = rest
+		case len(s) > 1 && isTagStart(s[1]):
+			tok, rest := parseStartTag(s[1:])
+			toks = append(toks, tok)
+			s = rest
+			if tok.Type == htmlStartTag && htmlRawText[tok.Data] {
+				end := strings.Index(strings.ToLower(s), "</"+tok.Data)
+				if end < 0 {
+					end = len(s)
+				}
+				text(s[:end])
+				s = s[end:]
+			}
+		default:
+			text("<")
+			s = s[1:]
+		}
+	}
+	return toks
+}
+
+// cutTag splits s at the > that ends the tag it's in, returning what's
+// before it and after it. A tag left unterminated runs to the end of s.
+func cutTag(s string) (tag, rest string) {
+	tag, rest, _ = strings.Cut(s, ">")
+	return tag, rest
+}
+
+func isTagStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// parseStartTag parses a tag beginning just after its '<' and returns the
+// token and the input following the closing '>'.
+func parseStartTag(s string) (htmlToken, string) {
+	i := 0
+	for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	tok := htmlToken{Type: htmlStartTag, Data: strings.ToLower(s[:i])}
+	for i < len(s) {
+		for i < len(s) && isHTMLSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			i++
+			break
+		}
+		if s[i] == '/' {
+			i++
+			if i < len(s) && s[i] == '>' {
+				tok.Type = htmlSelfClosingTag
+				i++
+				break
+			}
+			continue
+		}
+		start := i
+		for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		key := strings.ToLower(s[start:i])
+		for i < len(s) && isHTMLSpace(s[i]) {
+			i++
+		}
+		var val string
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isHTMLSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				q := s[i]
+				end := strings.IndexByte(s[i+1:], q)
+				if end < 0 {
+					val = s[i+1:]
+					i = len(s)
+				} else {
+					val = s[i+1 : i+1+end]
+					i += end + 2
+				}
+			} else {
+				start := i
+				for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				val = s[start:i]
+			}
+		}
+		if key != "" {
+			tok.Attrs = append(tok.Attrs, htmlAttr{Key: key, Val: html.UnescapeString(val)})
+		}
+	}
+	if htmlVoid[tok.Data] {
+		tok.Type = htmlSelfClosingTag
+	}
+	return tok, s[i:]
+}
+
+type htmlNode struct {
+	Tag      string // empty for text nodes
+	Text     string // decoded text for text nodes
+	Attrs    []htmlAttr
+	Parent   *htmlNode
+	Children []*htmlNode
+}
+
+func (n *htmlNode) attr(key string) string {
+	for _, a := range n.Attrs {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func (n *htmlNode) hasClass(class string) bool {
+	return contains(strings.Fields(n.attr("class")), class)
+}
+
+// find returns the first element with the tag in or below n, or nil.
+func (n *htmlNode) find(tag string) *htmlNode {
+	if n.Tag == tag {
+		return n
+	}
+	for _, c := range n.Children {
+		if f := c.find(tag); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// text returns the concatenated text of n and its descendants.
+func (n *htmlNode) text() string {
+	if n.Tag == "" {
+		return n.Text
+	}
+	var b strings.Builder
+	for _, c := range n.Children {
+		if c.Tag == "br" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(c.text())
+	}
+	return b.String()
+}
+
+// htmlImplied maps a tag to the open tags it implicitly closes, and the tags
+// that bound that search.
+var htmlImplied = map[string]struct{ closes, stop []string }{
+	"li": {[]string{"li"}, []string{"ul", "ol"}},
+	"dt": {[]string{"dt", "dd"}, []string{"dl"}},
+	"dd": {[]string{"dt", "dd"}, []string{"dl"}},
+	"tr": {[]string{"tr", "td", "th"}, []string{"table", "thead", "tbody", "tfoot"}},
+	"td": {[]string{"td", "th"}, []string{"tr", "table"}},
+	"th": {[]string{"td", "th"}, []string{"tr", "table"}},
+}
+
+var htmlClosesP = map[string]bool{
+	"p": true, "div": true, "ul": true, "ol": true, "dl": true, "table": true, "pre": true,
+	"blockquote": true, "hr": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "figure": true, "section": true,
+}
+
+var htmlSkip = map[string]bool{
+	"head": true, "script": true, "style": true, "title": true, "template": true, "noscript": true,
+}
+
+// parseHTML builds a node tree from src, closing elements the way browsers
+// imply them and ignoring stray end tags.
+func parseHTML(src string) *htmlNode {
+	root := &htmlNode{Tag: "#root"}
+	cur := root
+	closeTo := func(tag string, stop []string) bool {
+		for n := cur; n != root; n = n.Parent {
+			if n.Tag == tag {
+				cur = n.Parent
+				return true
+			}
+			if contains(stop, n.Tag) {
+				return false
+			}
+		}
+		return false
+	}
+	skip := ""
+	for _, tok := range tokenizeHTML(src) {
+		if skip != "" {
+			if tok.Type == htmlEndTag && tok.Data == skip {
+				skip = ""
+			}
+			continue
+		}
+		switch tok.Type {
+		case htmlText:
+			cur.Children = append(cur.Children, &htmlNode{Text: html.UnescapeString(tok.Data), Parent: cur})
+		case htmlStartTag, htmlSelfClosingTag:
+			if htmlSkip[tok.Data] {
+				if tok.Type == htmlStartTag {
+					skip = tok.Data
+				}
+				continue
+			}
+			if imp, ok := htmlImplied[tok.Data]; ok {
+				for _, tag := range imp.closes {
+					if closeTo(tag, imp.stop) {
+						break
+					}
+				}
+			}
+			if htmlClosesP[tok.Data] && cur.Tag == "p" {
+				cur = cur.Parent
+			}
+			n := &htmlNode{Tag: tok.Data, Attrs: tok.Attrs, Parent: cur}
+			cur.Children = append(cur.Children, n)
+			if tok.Type == htmlStartTag {
+				cur = n
+			}
+		case htmlEndTag:
+			closeTo(tok.Data, nil)
+		}
+	}
+	return root
+}
+
+// renderHTML serializes n and its descendants.
+func renderHTML(n *htmlNode) string {
+	var b strings.Builder
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		if n.Tag == "" {
+			b.WriteString(html.EscapeString(n.Text))
+			return
+		}
+		tok := htmlToken{Type: htmlStartTag, Data: n.Tag, Attrs: n.Attrs}
+		if htmlVoid[n.Tag] {
+			tok.Type = htmlSelfClosingTag
+		}
+		b.WriteString(tok.String())
+		for _, c := range n.Children {
+			walk(c)
+		}
+		if !htmlVoid[n.Tag] {
+			b.WriteString("</" + n.Tag + ">")
+		}
+	}
+	if n.Tag == "#root" {
+		for _, c := range n.Children {
+			walk(c)
+		}
+	} else {
+		walk(n)
+	}
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package paper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeHTMLUnterminated(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []htmlToken
+	}{
+		{"hello <!", []htmlToken{{Type: htmlText, Data: "hello "}, {Type: htmlDoctype, Data: ""}}},
+		{"hello <?", []htmlToken{{Type: htmlText, Data: "hello "}, {Type: htmlDoctype, Data: ""}}},
+		{"<!doctype html", []htmlToken{{Type: htmlDoctype, Data: "doctype html"}}},
+		{"a</b", []htmlToken{{Type: htmlText, Data: "a"}, {Type: htmlEndTag, Data: "b"}}},
+		{"<!x>y", []htmlToken{{Type: htmlDoctype, Data: "x"}, {Type: htmlText, Data: "y"}}},
+	} {
+		if got := tokenizeHTML(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("tokenizeHTML(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSanitizeUnterminated(t *testing.T) {
+	// These used to panic.
+	for _, in := range []string{"hello <!", "hello <?", "<p>x</p><!"} {
+		NewSanitizer().Sanitize([]byte(in))
+		RenderHTML(ExportFormatHTML, []byte(in))
+	}
+}
@@ -0,0 +1,363 @@
+package paper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ImageOptimizer shrinks images before they're stored. It scales them down
+// to fit within MaxWidth and MaxHeight, re-encodes them and strips EXIF and
+// other metadata, turning them the way their EXIF orientation said to
+// first. JPEGs and PNGs are optimized; other files are left alone.
+type ImageOptimizer struct {
+	MaxWidth  int // 0 is unlimited
+	MaxHeight int // 0 is unlimited
+	Quality   int // JPEG and WebP quality, 1 to 100, defaulting to 85
+
+	// Format converts images to "jpeg", "png" or "webp". Empty keeps each
+	// image's format.
+	Format string
+
+	// WebP encodes images as WebP, which the standard library can't; see
+	// CWebP. It's needed when Format is "webp".
+	WebP func(img image.Image, quality int) ([]byte, error)
+
+	// MaxPixels is the most pixels an image may have to be decoded,
+	// defaulting to 50 million. Larger ones are stored as they are, since
+	// a small file can claim dimensions that take gigabytes to decode.
+	MaxPixels int64
+}
+
+const defaultMaxPixels = 50e6
+
+var imageExts = map[string]string{"image/jpeg": ".jpg", "image/png": ".png", "image/webp": ".webp"}
+
+// Optimize returns an image optimized and its MIME type, or blob and ""
+// if it isn't an image Optimize handles.
+func (o *ImageOptimizer) Optimize(blob []byte) ([]byte, string, error) {
+	ctype := http.DetectContentType(blob)
+	if ctype != "image/jpeg" && ctype != "image/png" {
+		return blob, "", nil
+	}
+	format := "image/" + o.Format
+	if o.Format == "" {
+		format = ctype
+	}
+	if _, ok := imageExts[format]; !ok {
+		return nil, "", fmt.Errorf("unknown image format %q; want jpeg, png or webp", o.Format)
+	}
+	if format == "image/webp" && o.WebP == nil {
+		return nil, "", fmt.Errorf("encoding WebP needs an encoder")
+	}
+	quality := o.Quality
+	if quality <= 0 || quality > 100 {
+		quality = 85
+	}
+	maxPixels := o.MaxPixels
+	if maxPixels <= 0 {
+		maxPixels = defaultMaxPixels
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(blob))
+	if err != nil || int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return blob, "", nil // stored as it is, as it would be without optimizing
+	}
+	img, _, err := image.Decode(bytes.NewReader(blob))
+	if err != nil {
+		return blob, "", nil
+	}
+	orientation := 1
+	if ctype == "image/jpeg" {
+		orientation = jpegOrientation(blob)
+	}
+	rgba := orient(toRGBA(img), orientation)
+	b := rgba.Bounds()
+	w, h := fit(b.Dx(), b.Dy(), o.MaxWidth, o.MaxHeight)
+	scaled := w != b.Dx() || h != b.Dy()
+	if scaled {
+		rgba = scaleDown(rgba, w, h)
+	}
+
+	var out []byte
+	switch format {
+	case "image/jpeg":
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, flatten(rgba), &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", err
+		}
+		out = buf.Bytes()
+	case "image/png":
+		var buf bytes.Buffer
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := enc.Encode(&buf, rgba); err != nil {
+			return nil, "", err
+		}
+		out = buf.Bytes()
+	case "image/webp":
+		if out, err = o.WebP(rgba, quality); err != nil {
+			return nil, "", err
+		}
+	}
+	// Re-encoding an already compact image can make it bigger; if nothing
+	// else changed, the original without its metadata is better.
+	if !scaled && format == ctype && orientation == 1 {
+		var stripped []byte
+		if ctype == "image/jpeg" {
+			stripped = stripJPEGMetadata(blob)
+		} else {
+			stripped = stripPNGMetadata(blob)
+		}
+		if stripped != nil && len(stripped) <= len(out) {
+			out = stripped
+		}
+	}
+	return out, format, nil
+}
+
+// fit returns the size of a w×h image scaled down, keeping its aspect
+// ratio, to fit within maxW×maxH, where 0 is unlimited.
+func fit(w, h, maxW, maxH int) (int, int) {
+	scale := 1.0
+	if maxW > 0 && w > maxW {
+		scale = float64(maxW) / float64(w)
+	}
+	if maxH > 0 && h > maxH {
+		scale = min(scale, float64(maxH)/float64(h))
+	}
+	if scale == 1 {
+		return w, h
+	}
+	return max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+// flatten puts an image with transparency on white, which is what JPEG,
+// lacking transparency, should show.
+func flatten(img *image.RGBA) image.Image {
+	if img.Opaque() {
+		return img
+	}
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Over)
+	return out
+}
+
+// scaleDown shrinks an image to w×h, averaging the pixels each of the new
+// ones covers.
+func scaleDown(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := y * sh / h
+		y1 := max((y+1)*sh/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := x * sw / w
+			x1 := max((x+1)*sw/w, x0+1)
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				off := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx, off = sx+1, off+4 {
+					for c := 0; c < 4; c++ {
+						sum[c] += uint64(src.Pix[off+c])
+					}
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			off := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[off+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+// orient turns an image the way an EXIF orientation, 1 to 8, says to.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
+
+// jpegSegments calls fn with each marker segment of a JPEG before its image
+// data, and its offset and length including the marker. It returns the
+// offset the image data starts at, or -1 if the JPEG is malformed.
+func jpegSegments(blob []byte, fn func(marker byte, start, end int)) int {
+	if len(blob) < 2 || blob[0] != 0xFF || blob[1] != 0xD8 {
+		return -1
+	}
+	i := 2
+	for i+4 <= len(blob) {
+		if blob[i] != 0xFF {
+			return -1
+		}
+		marker := blob[i+1]
+		if marker == 0xDA { // start of scan
+			return i
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(blob[i+2:]))
+		if end > len(blob) {
+			return -1
+		}
+		fn(marker, i, end)
+		i = end
+	}
+	return -1
+}
+
+// jpegOrientation returns the orientation in a JPEG's EXIF data, or 1.
+func jpegOrientation(blob []byte) int {
+	orientation := 1
+	jpegSegments(blob, func(marker byte, start, end int) {
+		seg := blob[start+4 : end]
+		if marker != 0xE1 || !bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return
+		}
+		tiff := seg[6:]
+		if len(tiff) < 8 {
+			return
+		}
+		var order binary.ByteOrder = binary.BigEndian
+		if string(tiff[:2]) == "II" {
+			order = binary.LittleEndian
+		}
+		ifd := int(order.Uint32(tiff[4:]))
+		if ifd+2 > len(tiff) {
+			return
+		}
+		n := int(order.Uint16(tiff[ifd:]))
+		for e := ifd + 2; e+12 <= len(tiff) && n > 0; e, n = e+12, n-1 {
+			if order.Uint16(tiff[e:]) == 0x0112 {
+				orientation = int(order.Uint16(tiff[e+8:]))
+				return
+			}
+		}
+	})
+	return orientation
+}
+
+// stripJPEGMetadata removes a JPEG's EXIF, XMP, comment and other
+// application segments, keeping its JFIF header and color profile. It
+// returns nil if the JPEG is malformed.
+func stripJPEGMetadata(blob []byte) []byte {
+	out := []byte{0xFF, 0xD8}
+	data := jpegSegments(blob, func(marker byte, start, end int) {
+		seg := blob[start:end]
+		switch {
+		case marker == 0xE0 && bytes.HasPrefix(seg[4:], []byte("JFIF")):
+		case marker == 0xE2 && bytes.HasPrefix(seg[4:], []byte("ICC_PROFILE")):
+		case marker >= 0xE0 && marker <= 0xEF, marker == 0xFE:
+			return
+		}
+		out = append(out, seg...)
+	})
+	if data < 0 {
+		return nil
+	}
+	return append(out, blob[data:]...)
+}
+
+// pngKeep lists the ancillary PNG chunks that affect how an image looks,
+// which stripPNGMetadata keeps.
+var pngKeep = map[string]bool{"tRNS": true, "gAMA": true, "cHRM": true, "sRGB": true, "iCCP": true, "sBIT": true}
+
+// stripPNGMetadata removes a PNG's text, EXIF, time and other chunks that
+// don't affect how it looks. It returns nil if the PNG is malformed.
+func stripPNGMetadata(blob []byte) []byte {
+	const sig = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(blob, []byte(sig)) {
+		return nil
+	}
+	out := []byte(sig)
+	for i := len(sig); i < len(blob); {
+		if i+12 > len(blob) {
+			return nil
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(blob[i:]))
+		if end > len(blob) || end < i {
+			return nil
+		}
+		typ := string(blob[i+4 : i+8])
+		// Critical chunks start with an uppercase letter.
+		if typ[0] >= 'A' && typ[0] <= 'Z' || pngKeep[typ] {
+			out = append(out, blob[i:end]...)
+		}
+		i = end
+	}
+	return out
+}
+
+// CWebP returns a WebP encoder that runs Google's cwebp command, found on
+// PATH when name is "cwebp", with args added.
+func CWebP(name string, args ...string) func(image.Image, int) ([]byte, error) {
+	return func(img image.Image, quality int) ([]byte, error) {
+		dir, err := os.MkdirTemp("", "paper-webp-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		in, out := filepath.Join(dir, "in.png"), filepath.Join(dir, "out.webp")
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(in, buf.Bytes(), 0600); err != nil {
+			return nil, err
+		}
+		cmd := exec.Command(name, append(append([]string{"-quiet", "-q", strconv.Itoa(quality)}, args...), in, "-o", out)...)
+		if msg, err := cmd.CombinedOutput(); err != nil {
+			if s := strings.TrimSpace(string(msg)); s != "" {
+				return nil, fmt.Errorf("%s: %w: %s", name, err, s)
+			}
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return os.ReadFile(out)
+	}
+}
@@ -0,0 +1,106 @@
+package paper
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Pagination is one page of the index.
+type Pagination struct {
+	Number int // 1-based
+	Total  int
+	Posts  []*Post
+	Path   string // permalink of this page
+	Prev   string // permalink of the previous page, empty on the first
+	Next   string // permalink of the next page, empty on the last
+}
+
+// Paginate splits posts into index pages of PageSize posts. The first page
+// is "/" and later ones are "/page/N/". There is always at least one page.
+func (s *Site) Paginate(posts []*Post) []*Pagination {
+	size := s.PageSize
+	if size <= 0 || size > len(posts) {
+		size = len(posts)
+	}
+	total := 1
+	if size > 0 {
+		total = (len(posts) + size - 1) / size
+	}
+	pages := make([]*Pagination, total)
+	for i := range pages {
+		p := &Pagination{Number: i + 1, Total: total, Path: pagePath(i + 1)}
+		if size > 0 {
+			end := (i + 1) * size
+			if end > len(posts) {
+				end = len(posts)
+			}
+			p.Posts = posts[i*size : end]
+		}
+		if i > 0 {
+			p.Prev = pagePath(i)
+		}
+		if i+1 < total {
+			p.Next = pagePath(i + 2)
+		}
+		pages[i] = p
+	}
+	return pages
+}
+
+func pagePath(n int) string {
+	if n <= 1 {
+		return "/"
+	}
+	return "/page/" + strconv.Itoa(n) + "/"
+}
+
+// writeIndex writes the home page listing every post, as HTML rendered with
+// the theme and as index.md for tools that consume markdown. With a PageSize
+// the HTML index is split across /page/N/ pages.
+func (s *Site) writeIndex(theme *Theme, posts []*Post) error {
+	var b bytes.Buffer
+	for _, page := range s.Paginate(posts) {
+		b.Reset()
+		if err := theme.Execute(&b, "index", &PageData{Site: s, Posts: page.Posts, Page: page}); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(pageFile(s.out(), page.Path), b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(filepath.Join(s.out(), "index.md"), s.IndexMarkdown(posts), 0644)
+}
+
+// IndexMarkdown renders a markdown listing of posts with their dates,
+// summaries and tags.
+func (s *Site) IndexMarkdown(posts []*Post) []byte {
+	var b strings.Builder
+	if s.Title != "" {
+		b.WriteString("# " + escapeMarkdown(s.Title) + "\n\n")
+	}
+	if s.Description != "" {
+		b.WriteString(escapeLineStart(escapeMarkdown(s.Description)) + "\n\n")
+	}
+	for _, p := range posts {
+		b.WriteString("## [" + escapeMarkdown(p.Title) + "](" + linkDest(p.Permalink) + ")\n\n")
+		var meta []string
+		if !p.Published.IsZero() {
+			meta = append(meta, "*"+p.Published.Format("January 2, 2006")+"*")
+		}
+		for _, tag := range p.Tags {
+			meta = append(meta, "`#"+tag+"`")
+		}
+		if len(meta) > 0 {
+			b.WriteString(strings.Join(meta, " · ") + "\n\n")
+		}
+		if p.Summary != "" {
+			b.WriteString(escapeLineStart(escapeMarkdown(p.Summary)) + "\n\n")
+		}
+	}
+	if len(posts) == 0 {
+		b.WriteString("Nothing published yet.\n")
+	}
+	return []byte(strings.TrimRight(b.String(), "\n") + "\n")
+}
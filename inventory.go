@@ -0,0 +1,118 @@
+package paper
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type InventoryFormat string
+
+const (
+	InventoryCSV  InventoryFormat = "csv"
+	InventoryJSON InventoryFormat = "json"
+)
+
+type InventoryRow struct {
+	ID       DocID    `json:"id"`
+	Title    string   `json:"title"`
+	Owner    string   `json:"owner"`
+	Revision int64    `json:"revision"`
+	Folders  []string `json:"folders"`
+
+	// FolderSharingPolicy is the policy of the folder the doc is in, not
+	// the doc's own; see APIClient.GetSharingPolicy for that.
+	FolderSharingPolicy FolderSharingPolicyType `json:"folder_sharing_policy"`
+}
+
+var inventoryHeader = []string{"id", "title", "owner", "revision", "folders", "folder_sharing_policy"}
+
+// Inventory writes a row per doc, for audits and spreadsheets. Rows are
+// written as each doc is fetched rather than all at the end.
+func (c *APIClient) Inventory(ctx context.Context, w io.Writer, format InventoryFormat) error {
+	return inventory(ctx, c, w, format)
+}
+
+func inventory(ctx context.Context, c Client, w io.Writer, format InventoryFormat) error {
+	write, done, err := rowWriter(w, format, inventoryHeader)
+	if err != nil {
+		return err
+	}
+	ids, err := ListAllDocIDs(ctx, c, &ListPaperDocsArgs{})
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		res, _, err := c.DownloadDoc(ctx, &PaperDocExport{DocID: id, Format: ExportFormatMarkdown})
+		if err != nil {
+			return fmt.Errorf("download %s: %w", id, err)
+		}
+		info, err := c.GetDocFolderInfo(ctx, &RefPaperDoc{DocID: id})
+		if err != nil {
+			return fmt.Errorf("folder info %s: %w", id, err)
+		}
+		row := &InventoryRow{
+			ID:                  id,
+			Title:               res.Title,
+			Owner:               res.Owner,
+			Revision:            res.Revision,
+			Folders:             []string{},
+			FolderSharingPolicy: info.FolderSharingPolicyType,
+		}
+		for _, f := range info.Folders {
+			row.Folders = append(row.Folders, f.Name)
+		}
+		err = write(row, []string{
+			string(row.ID), row.Title, row.Owner, strconv.FormatInt(row.Revision, 10),
+			strings.Join(row.Folders, "/"), string(row.FolderSharingPolicy),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return done()
+}
+
+// rowWriter writes rows as they arrive, either as CSV records under header
+// or as elements of a JSON array. done finishes the output.
+func rowWriter(w io.Writer, format InventoryFormat, header []string) (write func(row interface{}, record []string) error, done func() error, err error) {
+	switch format {
+	case InventoryCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return nil, nil, err
+		}
+		write = func(_ interface{}, record []string) error {
+			cw.Write(record)
+			cw.Flush()
+			return cw.Error()
+		}
+		done = func() error { return nil }
+	case InventoryJSON:
+		sep := "[\n"
+		write = func(row interface{}, _ []string) error {
+			blob, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, sep+string(blob))
+			sep = ",\n"
+			return err
+		}
+		done = func() error {
+			if sep == "[\n" {
+				_, err := io.WriteString(w, "[]\n")
+				return err
+			}
+			_, err := io.WriteString(w, "\n]\n")
+			return err
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown inventory format %q", format)
+	}
+	return write, done, nil
+}
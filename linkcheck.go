@@ -0,0 +1,271 @@
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LinkReportName is the file in the sync directory a LinkChecker run as a
+// hook saves its report to by default.
+const LinkReportName = ".link-report.json"
+
+// LinkChecker finds broken links in synced docs: links to Paper docs that
+// aren't synced, site-relative links that match no doc, alias or file in
+// Dir, and, when External is set, URLs that don't respond successfully.
+//
+// As a Hook, it checks after each sync and saves the report to Report.
+// Broken links don't fail the sync.
+type LinkChecker struct {
+	Dir      string
+	Manifest *Manifest // loaded from Dir when nil
+
+	// Permalink builds a doc's site-relative link, as in LinkRewriter. The
+	// default is "/<slug>/".
+	Permalink func(*ManifestEntry) string
+
+	// Aliases, when set, makes docs' old permalinks count as working.
+	Aliases *Aliases
+
+	// Ignore matches links not to check, such as the site's own pages.
+	Ignore []*regexp.Regexp
+
+	External    bool
+	Concurrency int           // external URLs checked at once, defaulting to 8
+	Timeout     time.Duration // per external URL, defaulting to 10s
+	HTTP        *http.Client
+
+	Report string // defaults to LinkReportName in Dir
+}
+
+type BrokenLink struct {
+	DocID  DocID  `json:"doc_id"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+	Reason string `json:"reason"` // e.g. "doc isn't synced" or "404 Not Found"
+}
+
+type LinkReport struct {
+	Time    time.Time     `json:"time"`
+	Checked int           `json:"checked"` // links, counting each doc's once
+	Broken  []*BrokenLink `json:"broken"`  // by doc, then URL
+}
+
+// docLink matches markdown link and image targets, HTML href and src
+// attributes, and bare Paper doc URLs, which Paper links itself.
+var docLink = regexp.MustCompile(`(?:\]\(\s*<?|(?:href|src)=["'])([^\s"'<>()]+)|` + paperDocURL.String())
+
+// docLinks returns the links in a doc, once each, outside code.
+func docLinks(format ExportFormat, content []byte) []string {
+	var text []string
+	if format == ExportFormatHTML {
+		text = []string{string(content)}
+	} else {
+		lines := strings.Split(string(content), "\n")
+		code := codeLines(lines)
+		for i, line := range lines {
+			if !code[i] {
+				text = append(text, stripCodeSpans(line))
+			}
+		}
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, t := range text {
+		for _, m := range docLink.FindAllStringSubmatch(t, -1) {
+			link := m[1]
+			if link == "" {
+				link = m[0]
+			}
+			if format == ExportFormatHTML {
+				link = strings.ReplaceAll(link, "&amp;", "&")
+			}
+			if !seen[link] {
+				seen[link] = true
+				out = append(out, link)
+			}
+		}
+	}
+	return out
+}
+
+// Check checks every synced doc's links.
+func (c *LinkChecker) Check(ctx context.Context) (*LinkReport, error) {
+	m := c.Manifest
+	if m == nil {
+		var err error
+		if m, err = LoadManifest(filepath.Join(c.Dir, ManifestName)); err != nil {
+			return nil, err
+		}
+	}
+	permalink := c.Permalink
+	if permalink == nil {
+		permalink = func(e *ManifestEntry) string { return "/" + e.Slug + "/" }
+	}
+	paths := map[string]bool{"/": true}
+	for _, e := range m.Entries() {
+		paths[permalink(e)] = true
+	}
+	if c.Aliases != nil {
+		for old := range aliasPermalinks(c.Aliases, m, permalink) {
+			paths[old] = true
+		}
+	}
+
+	report := &LinkReport{Time: time.Now().UTC(), Broken: []*BrokenLink{}}
+	external := map[string][]*ManifestEntry{} // by URL
+	for _, e := range m.Entries() {
+		if e.Path == "" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(c.Dir, e.Path))
+		if err != nil {
+			return nil, err
+		}
+		format := ExportFormatMarkdown
+		if filepath.Ext(e.Path) == ".html" {
+			format = ExportFormatHTML
+		}
+		for _, link := range docLinks(format, content) {
+			if matchesAny(c.Ignore, link) {
+				continue
+			}
+			broken := func(reason string) {
+				report.Broken = append(report.Broken, &BrokenLink{DocID: e.ID, Title: e.Title, URL: link, Reason: reason})
+			}
+			switch {
+			case paperDocURL.MatchString(link):
+				report.Checked++
+				if id := docIDFromURL(link); id != "" {
+					if _, ok := m.Get(id); !ok {
+						broken("doc isn't synced")
+					}
+				}
+			case strings.HasPrefix(link, "/") && !strings.HasPrefix(link, "//"):
+				report.Checked++
+				path := link
+				if i := strings.IndexAny(path, "?#"); i >= 0 {
+					path = path[:i]
+				}
+				if !paths[path] && !fileExists(filepath.Join(c.Dir, filepath.FromSlash(path))) {
+					broken("no doc or file at this path")
+				}
+			case c.External && (strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://")):
+				report.Checked++
+				external[link] = append(external[link], e)
+			}
+		}
+	}
+
+	for link, reason := range c.probe(ctx, external) {
+		for _, e := range external[link] {
+			report.Broken = append(report.Broken, &BrokenLink{DocID: e.ID, Title: e.Title, URL: link, Reason: reason})
+		}
+	}
+	sort.Slice(report.Broken, func(i, j int) bool {
+		a, b := report.Broken[i], report.Broken[j]
+		if a.DocID != b.DocID {
+			return a.DocID < b.DocID
+		}
+		return a.URL < b.URL
+	})
+	return report, ctx.Err()
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// probe requests each URL, returning why the ones that failed did.
+func (c *LinkChecker) probe(ctx context.Context, urls map[string][]*ManifestEntry) map[string]string {
+	n := c.Concurrency
+	if n <= 0 {
+		n = 8
+	}
+	var mu sync.Mutex
+	failed := map[string]string{}
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for link := range urls {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(link string) {
+			defer func() { <-sem; wg.Done() }()
+			if reason := c.request(ctx, link); reason != "" {
+				mu.Lock()
+				failed[link] = reason
+				mu.Unlock()
+			}
+		}(link)
+	}
+	wg.Wait()
+	return failed
+}
+
+// request checks a URL with HEAD, falling back to GET for servers that
+// don't answer HEAD properly. It returns why the URL is broken, or "".
+func (c *LinkChecker) request(ctx context.Context, link string) string {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var reason string
+	for _, method := range []string{"HEAD", "GET"} {
+		rctx, cancel := context.WithTimeout(ctx, timeout)
+		req, err := http.NewRequestWithContext(rctx, method, link, nil)
+		if err != nil {
+			cancel()
+			return err.Error()
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			cancel()
+			reason = err.Error()
+			continue
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		cancel()
+		if resp.StatusCode < 400 {
+			return ""
+		}
+		reason = resp.Status
+	}
+	return reason
+}
+
+func (c *LinkChecker) Run(ctx context.Context, res *SyncResult) error {
+	report, err := c.Check(ctx)
+	if err != nil {
+		return fmt.Errorf("check links: %w", err)
+	}
+	name := c.Report
+	if name == "" {
+		name = filepath.Join(c.Dir, LinkReportName)
+	}
+	blob, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(name, append(blob, '\n'), 0644)
+}
@@ -0,0 +1,64 @@
+package paper
+
+import (
+	"regexp"
+)
+
+// LinkRewriter replaces links to other Paper docs with the local permalink of
+// the target doc, so internal links keep working once docs are published.
+// Links to docs missing from the manifest are left untouched.
+type LinkRewriter struct {
+	Manifest *Manifest
+
+	// Permalink builds the link for a doc. The default is "/<slug>/".
+	Permalink func(*ManifestEntry) string
+
+	// Aliases, when set, also updates links to docs' old permalinks, from
+	// before they were renamed.
+	Aliases *Aliases
+}
+
+var paperDocURL = regexp.MustCompile(`https?://paper\.dropbox\.com/doc/[^\s"'<>()\[\]]+`)
+
+// siteLink matches site-relative markdown link and HTML href targets,
+// capturing what precedes the path and the path without any query or
+// fragment.
+var siteLink = regexp.MustCompile(`(\]\(\s*<?|href=["'])(/[^\s"'<>()#?]*)`)
+
+func (l *LinkRewriter) Rewrite(doc *Doc) error {
+	permalink := l.Permalink
+	if permalink == nil {
+		permalink = func(e *ManifestEntry) string { return "/" + e.Slug + "/" }
+	}
+	doc.Content = paperDocURL.ReplaceAllFunc(doc.Content, func(link []byte) []byte {
+		id := docIDFromURL(string(link))
+		if id == "" {
+			return link
+		}
+		e, ok := l.Manifest.Get(id)
+		if !ok {
+			return link
+		}
+		return []byte(permalink(e))
+	})
+	if l.Aliases != nil {
+		moved := aliasPermalinks(l.Aliases, l.Manifest, permalink)
+		doc.Content = siteLink.ReplaceAllFunc(doc.Content, func(link []byte) []byte {
+			m := siteLink.FindSubmatch(link)
+			if to, ok := moved[string(m[2])]; ok {
+				return append(append([]byte(nil), m[1]...), to...)
+			}
+			return link
+		})
+	}
+	return nil
+}
+
+// docIDFromURL extracts the doc ID from a Paper URL, or returns "".
+func docIDFromURL(link string) DocID {
+	id, err := DocIDFromURL(link)
+	if err != nil {
+		return ""
+	}
+	return id
+}
@@ -0,0 +1,223 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// LongPoller blocks until docs change, for deployments that can't receive
+// webhooks.
+//
+// By default it watches docs/list, which has no long-poll endpoint: it
+// lists docs sorted by last modification and compares the order with the
+// previous listing, checking more often right after a change and backing off
+// while nothing happens. With Path set it instead watches a Dropbox folder of
+// .paper files using files/list_folder/longpoll.
+type LongPoller struct {
+	Client Client
+
+	// Path is the Dropbox folder to watch on filesystem-based Paper. Client
+	// must then be an *APIClient.
+	Path    string
+	Timeout time.Duration // longpoll timeout, defaults to 5m
+
+	// MinInterval and MaxInterval bound how often docs/list is checked,
+	// defaulting to 5s and 2m.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	cursor   string
+	order    []string
+	headRev  int64
+	interval time.Duration
+}
+
+// PollResult reports what changed. Changed holds doc IDs when watching
+// docs/list and file paths when watching a folder.
+type PollResult struct {
+	Changed []string
+	Removed []string
+}
+
+// Wait blocks until something changes, relative to the previous call. The
+// first call takes a baseline and then waits too.
+func (p *LongPoller) Wait(ctx context.Context) (*PollResult, error) {
+	if p.Path != "" {
+		return p.waitFolder(ctx)
+	}
+	return p.waitList(ctx)
+}
+
+func (p *LongPoller) waitList(ctx context.Context) (*PollResult, error) {
+	min, max := p.MinInterval, p.MaxInterval
+	if min == 0 {
+		min = 5 * time.Second
+	}
+	if max == 0 {
+		max = 2 * time.Minute
+	}
+	if p.order == nil {
+		order, rev, err := p.listOrder(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.order, p.headRev = order, rev
+	}
+	if p.interval < min {
+		p.interval = min
+	}
+	for {
+		if err := sleep(ctx, p.interval); err != nil {
+			return nil, err
+		}
+		order, rev, err := p.listOrder(ctx)
+		if err != nil {
+			return nil, err
+		}
+		res := diffOrder(p.order, order)
+		if len(res.Changed) == 0 && len(order) > 0 && rev != p.headRev {
+			res.Changed = order[:1]
+		}
+		p.order, p.headRev = order, rev
+		if len(res.Changed) > 0 || len(res.Removed) > 0 {
+			p.interval = min
+			return res, nil
+		}
+		if p.interval *= 2; p.interval > max {
+			p.interval = max
+		}
+	}
+}
+
+// listOrder lists docs, most recently modified first, along with the
+// revision of the first one. Repeated edits to the same doc don't change the
+// order, so its revision is what reveals them.
+func (p *LongPoller) listOrder(ctx context.Context) ([]string, int64, error) {
+	ids, err := ListAllDocIDs(ctx, p.Client, &ListPaperDocsArgs{
+		SortBy:    ListPaperDocsSortByModified,
+		SortOrder: ListPaperDocsSortOrderDesc,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	order := make([]string, len(ids))
+	for i, id := range ids {
+		order[i] = string(id)
+	}
+	if len(ids) == 0 {
+		return order, 0, nil
+	}
+	head, _, err := p.Client.DownloadDoc(ctx, &PaperDocExport{DocID: ids[0], Format: ExportFormatMarkdown})
+	if err != nil {
+		return nil, 0, err
+	}
+	return order, head.Revision, nil
+}
+
+// diffOrder compares two listings sorted by modification time, newest first.
+// Edited docs jump to the front while the rest keep their relative order, so
+// walking from the oldest doc, any doc that is out of order relative to the
+// ones after it must have been modified.
+func diffOrder(old, cur []string) *PollResult {
+	var res PollResult
+	index := map[string]int{}
+	for i, id := range old {
+		index[id] = i
+	}
+	present := map[string]bool{}
+	for _, id := range cur {
+		present[id] = true
+	}
+	for _, id := range old {
+		if !present[id] {
+			res.Removed = append(res.Removed, id)
+		}
+	}
+	min := len(old)
+	for i := len(cur) - 1; i >= 0; i-- {
+		if j, ok := index[cur[i]]; ok && j < min {
+			min = j
+			continue
+		}
+		res.Changed = append(res.Changed, cur[i])
+	}
+	for i, j := 0, len(res.Changed)-1; i < j; i, j = i+1, j-1 {
+		res.Changed[i], res.Changed[j] = res.Changed[j], res.Changed[i]
+	}
+	return &res
+}
+
+func (p *LongPoller) waitFolder(ctx context.Context) (*PollResult, error) {
+	api, ok := p.Client.(*APIClient)
+	if !ok {
+		return nil, errors.New("paper: watching a folder requires an *APIClient")
+	}
+	if p.cursor == "" {
+		c, err := api.ListFolderGetLatestCursor(ctx, &ListFolderArgs{Path: p.Path, Recursive: true})
+		if err != nil {
+			return nil, err
+		}
+		p.cursor = c.Cursor
+	}
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+	for {
+		poll, err := api.ListFolderLongpoll(ctx, &ListFolderLongpollArgs{Cursor: p.cursor, Timeout: int(timeout / time.Second)})
+		if err != nil {
+			return nil, err
+		}
+		if poll.Changes {
+			res, err := p.folderChanges(ctx, api)
+			if err != nil || len(res.Changed) > 0 || len(res.Removed) > 0 {
+				return res, err
+			}
+		}
+		if poll.Backoff > 0 {
+			if err := sleep(ctx, time.Duration(poll.Backoff)*time.Second); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// folderChanges reads the entries behind the cursor, keeping only Paper
+// docs.
+func (p *LongPoller) folderChanges(ctx context.Context, api *APIClient) (*PollResult, error) {
+	var res PollResult
+	for {
+		list, err := api.ListFolderContinue(ctx, &ListFolderContinueArgs{Cursor: p.cursor})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range list.Entries {
+			if !strings.HasSuffix(e.PathLower, ".paper") {
+				continue
+			}
+			switch e.Tag {
+			case "file":
+				res.Changed = append(res.Changed, e.PathDisplay)
+			case "deleted":
+				res.Removed = append(res.Removed, e.PathDisplay)
+			}
+		}
+		p.cursor = list.Cursor
+		if !list.HasMore {
+			return &res, nil
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,222 @@
+//go:build ignore
+
+// Maketables generates normtables.go, the tables Normalize uses, from the
+// Unicode Character Database. Run it with go generate.
+//
+// By default it downloads UnicodeData.txt and CompositionExclusions.txt
+// from unicode.org; -ucd reads them from a directory instead.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	version = flag.String("version", "14.0.0", "Unicode version")
+	ucd     = flag.String("ucd", "", "directory holding the UCD files, instead of unicode.org")
+	output  = flag.String("output", "normtables.go", "file to write")
+)
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("maketables: ")
+
+	canonical := map[rune][]rune{}
+	compatibility := map[rune][]rune{}
+	classes := map[rune]uint8{}
+	err := readLines("UnicodeData.txt", func(fields []string) error {
+		r, err := parseRune(fields[0])
+		if err != nil {
+			return err
+		}
+		ccc, err := strconv.ParseUint(fields[3], 10, 8)
+		if err != nil {
+			return fmt.Errorf("%U: combining class: %w", r, err)
+		}
+		if ccc != 0 {
+			classes[r] = uint8(ccc)
+		}
+		if fields[5] == "" {
+			return nil
+		}
+		decomp := strings.Fields(fields[5])
+		table := canonical
+		if strings.HasPrefix(decomp[0], "<") {
+			table, decomp = compatibility, decomp[1:]
+		}
+		for _, f := range decomp {
+			d, err := parseRune(f)
+			if err != nil {
+				return err
+			}
+			table[r] = append(table[r], d)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	excluded := map[rune]bool{}
+	err = readLines("CompositionExclusions.txt", func(fields []string) error {
+		r, err := parseRune(fields[0])
+		excluded[r] = true
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Primary composites are the pairs canonical decompositions split runes
+	// into, less the full composition exclusions: those listed, singletons
+	// and decompositions that are or start with a non-starter.
+	compositions := map[[2]rune]rune{}
+	for r, d := range canonical {
+		if len(d) == 2 && !excluded[r] && classes[r] == 0 && classes[d[0]] == 0 {
+			compositions[[2]rune{d[0], d[1]}] = r
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by maketables.go from Unicode %s character data. DO NOT EDIT.\n\n", *version)
+	b.WriteString("package paper\n\n")
+	b.WriteString("// canonical maps runes to their canonical decomposition, one level deep.\n")
+	writeDecompositions(&b, "canonical", canonical)
+	b.WriteString("\n// compatibility maps runes to their compatibility decomposition, one level\n// deep.\n")
+	writeDecompositions(&b, "compatibility", compatibility)
+	b.WriteString("\n// combiningClass holds the canonical combining class of combining marks.\n")
+	b.WriteString("var combiningClass = map[rune]uint8{\n")
+	for _, r := range sortedKeys(classes) {
+		fmt.Fprintf(&b, "%s: %d,\n", quoteRune(r), classes[r])
+	}
+	b.WriteString("}\n")
+	b.WriteString("\n// compositions maps pairs of runes to the primary composite they make.\n")
+	b.WriteString("var compositions = map[[2]rune]rune{\n")
+	pairs := make([][2]rune, 0, len(compositions))
+	for p := range compositions {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	for _, p := range pairs {
+		fmt.Fprintf(&b, "{%s, %s}: %s,\n", quoteRune(p[0]), quoteRune(p[1]), quoteRune(compositions[p]))
+	}
+	b.WriteString("}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readLines calls fn with the ";"-separated fields of each line of a UCD
+// file, less comments and blank lines.
+func readLines(name string, fn func(fields []string) error) error {
+	var r io.Reader
+	if *ucd != "" {
+		f, err := os.Open(filepath.Join(*ucd, name))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	} else {
+		url := "https://www.unicode.org/Public/" + *version + "/ucd/" + name
+		resp, err := http.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+		r = resp.Body
+	}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if err := fn(fields); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return s.Err()
+}
+
+func parseRune(s string) (rune, error) {
+	n, err := strconv.ParseUint(s, 16, 32)
+	return rune(n), err
+}
+
+func writeDecompositions(b *bytes.Buffer, name string, table map[rune][]rune) {
+	fmt.Fprintf(b, "var %s = map[rune]string{\n", name)
+	for _, r := range sortedKeys(table) {
+		fmt.Fprintf(b, "%s: %s,\n", quoteRune(r), quoteString(table[r]))
+	}
+	b.WriteString("}\n")
+}
+
+func sortedKeys[V any](m map[rune]V) []rune {
+	keys := make([]rune, 0, len(m))
+	for r := range m {
+		keys = append(keys, r)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func quoteRune(r rune) string {
+	return "'" + escape(r) + "'"
+}
+
+// quoteString quotes runes as a string literal, escaping all but printable
+// ASCII.
+func quoteString(runes []rune) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range runes {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteString(`\` + string(r))
+		case r >= ' ' && r <= '~':
+			b.WriteRune(r)
+		default:
+			b.WriteString(escape(r))
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func escape(r rune) string {
+	if r > 0xFFFF {
+		return fmt.Sprintf(`\U%08x`, r)
+	}
+	return fmt.Sprintf(`\u%04x`, r)
+}
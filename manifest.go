@@ -0,0 +1,177 @@
+package paper
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Manifest records every doc that has been synced locally, keyed by doc ID.
+type Manifest struct {
+	Docs map[DocID]*ManifestEntry `json:"docs"`
+
+	// Slugify turns new docs' titles into slugs in AssignSlug, defaulting to
+	// the package's Slugify. SlugifyASCII gives ASCII-only slugs.
+	Slugify func(title string) string `json:"-"`
+}
+
+type ManifestEntry struct {
+	ID       DocID     `json:"id"`
+	Title    string    `json:"title"`
+	Slug     string    `json:"slug"`
+	Owner    string    `json:"owner,omitempty"` // owner's email
+	Revision int64     `json:"revision"`
+	Path     string    `json:"path,omitempty"`
+	Size     int64     `json:"size,omitempty"`    // bytes written to Path
+	Folders  []string  `json:"folders,omitempty"` // outermost first
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+
+	// ReadingTime is the estimated minutes to read the doc, when the
+	// Syncer has a ReadingTime.
+	ReadingTime int `json:"reading_time,omitempty"`
+}
+
+func NewManifest() *Manifest {
+	return &Manifest{Docs: map[DocID]*ManifestEntry{}}
+}
+
+// LoadManifest reads a manifest from disk. A missing file yields an empty
+// manifest.
+func LoadManifest(name string) (*Manifest, error) {
+	blob, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return NewManifest(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := NewManifest()
+	if err := json.Unmarshal(blob, m); err != nil {
+		return nil, err
+	}
+	if m.Docs == nil {
+		m.Docs = map[DocID]*ManifestEntry{}
+	}
+	return m, nil
+}
+
+func (m *Manifest) Save(name string) error {
+	blob, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(name, append(blob, '\n'), 0644)
+}
+
+// dropUnwritten removes the entries of docs that were never written, as
+// when a sync is interrupted before it gets to them.
+func (m *Manifest) dropUnwritten() {
+	for id, e := range m.Docs {
+		if e.Path == "" && e.Revision == 0 {
+			delete(m.Docs, id)
+		}
+	}
+}
+
+// Clone returns a copy of m that later changes to m, as a sync makes,
+// don't affect.
+func (m *Manifest) Clone() *Manifest {
+	c := &Manifest{Docs: make(map[DocID]*ManifestEntry, len(m.Docs)), Slugify: m.Slugify}
+	for id, e := range m.Docs {
+		ce := *e
+		ce.Folders = append([]string(nil), e.Folders...)
+		c.Docs[id] = &ce
+	}
+	return c
+}
+
+func (m *Manifest) Get(id DocID) (*ManifestEntry, bool) {
+	e, ok := m.Docs[id]
+	return e, ok
+}
+
+// Size returns the bytes taken by every synced doc, not counting assets.
+func (m *Manifest) Size() int64 {
+	var n int64
+	for _, e := range m.Docs {
+		n += e.Size
+	}
+	return n
+}
+
+// Entries returns all entries sorted by ID.
+func (m *Manifest) Entries() []*ManifestEntry {
+	entries := make([]*ManifestEntry, 0, len(m.Docs))
+	for _, e := range m.Docs {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// AssignSlug returns the slug for a doc. Docs keep the slug they were first
+// assigned so permalinks stay stable; new docs get a slug derived from the
+// title that doesn't collide with any other doc.
+func (m *Manifest) AssignSlug(id DocID, title string) string {
+	if e, ok := m.Docs[id]; ok && e.Slug != "" {
+		return e.Slug
+	}
+	// Slugs that name the same file count as taken too.
+	taken := Filenames{}
+	for _, e := range m.Docs {
+		if e.ID != id {
+			taken.Add(docFilename(e.Slug, ""))
+		}
+	}
+	slugify := m.Slugify
+	if slugify == nil {
+		slugify = Slugify
+	}
+	base := strings.TrimRight(truncateName(slugify(title), maxFilename), "-")
+	if base == "" {
+		base = strings.ToLower(string(id))
+	}
+	slug := base
+	for n := 2; taken[filenameKey(docFilename(slug, ""))]; n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug
+}
+
+// Slugify lowercases a title and replaces every run of characters other than
+// letters and digits with a single hyphen. The title is normalized to NFC
+// first, so it gives the same slug however its accents were typed.
+func Slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(Normalize(title, NFC)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// SlugifyASCII is Slugify with Latin letters transliterated to ASCII, e.g.
+// "Crème Brûlée" becomes "creme-brulee". Letters from other scripts are
+// dropped, so titles without any Latin letters give an empty slug and
+// AssignSlug falls back to the doc ID.
+func SlugifyASCII(title string) string {
+	return Slugify(strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			return ' '
+		}
+		return r
+	}, Transliterate(title)))
+}
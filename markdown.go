@@ -0,0 +1,301 @@
+package paper
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MarkdownConverter turns Paper's HTML export into CommonMark. Some content,
+// such as tables and embeds, survives Paper's HTML export better than its
+// markdown export, so pipelines can download HTML and convert it locally.
+type MarkdownConverter struct {
+	Tables TableMode
+}
+
+// Convert returns src rendered as markdown.
+func (c *MarkdownConverter) Convert(src []byte) []byte {
+	root := parseHTML(string(src))
+	out := strings.Join(c.blocks(root.Children), "\n\n")
+	if out == "" {
+		return nil
+	}
+	return []byte(out + "\n")
+}
+
+// Transform converts HTML docs to markdown and leaves other formats alone.
+func (c *MarkdownConverter) Transform(doc *Doc) error {
+	if doc.Format != ExportFormatHTML {
+		return nil
+	}
+	doc.Content = c.Convert(doc.Content)
+	doc.Format = ExportFormatMarkdown
+	doc.MIME = "text/markdown"
+	return nil
+}
+
+var mdBlock = map[string]bool{
+	"#root": true, "html": true, "body": true, "main": true, "article": true, "section": true,
+	"header": true, "footer": true, "nav": true, "aside": true, "div": true, "p": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "ul": true,
+	"ol": true, "li": true, "pre": true, "blockquote": true, "table": true, "hr": true,
+	"figure": true, "figcaption": true, "dl": true, "dt": true, "dd": true,
+}
+
+// blocks renders a list of sibling nodes as markdown blocks. Runs of inline
+// nodes between block elements become paragraphs.
+func (c *MarkdownConverter) blocks(nodes []*htmlNode) []string {
+	var out []string
+	var inline []*htmlNode
+	flush := func() {
+		if p := c.paragraph(inline); p != "" {
+			out = append(out, p)
+		}
+		inline = nil
+	}
+	for _, n := range nodes {
+		if n.Tag == "" || !mdBlock[n.Tag] {
+			inline = append(inline, n)
+			continue
+		}
+		flush()
+		if b := c.block(n); b != "" {
+			out = append(out, b)
+		}
+	}
+	flush()
+	return out
+}
+
+func (c *MarkdownConverter) block(n *htmlNode) string {
+	switch n.Tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.Tag[1:])
+		text := strings.TrimSpace(c.inline(n.Children))
+		if text == "" {
+			return ""
+		}
+		return strings.Repeat("#", level) + " " + text
+	case "hr":
+		return "---"
+	case "pre":
+		return c.codeBlock(n)
+	case "blockquote":
+		return prefixLines(strings.Join(c.blocks(n.Children), "\n\n"), "> ", "> ")
+	case "ul", "ol":
+		return c.list(n)
+	case "table":
+		return c.table(n)
+	}
+	return strings.Join(c.blocks(n.Children), "\n\n")
+}
+
+func (c *MarkdownConverter) paragraph(nodes []*htmlNode) string {
+	text := strings.TrimSpace(c.inline(nodes))
+	if text == "" {
+		return ""
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = mdSpaces.ReplaceAllString(strings.TrimSpace(line), " ")
+		lines[i] = escapeLineStart(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *MarkdownConverter) list(n *htmlNode) string {
+	start := 1
+	if s, err := strconv.Atoi(n.attr("start")); err == nil {
+		start = s
+	}
+	var items []string
+	for _, li := range n.Children {
+		if li.Tag != "li" {
+			continue
+		}
+		marker := "- "
+		if n.Tag == "ol" {
+			marker = strconv.Itoa(start+len(items)) + ". "
+		}
+		indent := strings.Repeat(" ", len(marker))
+		task, done := taskState(n, li)
+		var meta []string
+		if task {
+			meta = taskMeta(li)
+			if done {
+				marker += "[x] "
+			} else {
+				marker += "[ ] "
+			}
+		}
+		body := strings.Join(c.blocks(li.Children), "\n")
+		if len(meta) > 0 {
+			lines := strings.SplitN(body, "\n", 2)
+			lines[0] = strings.TrimSpace(lines[0] + " " + strings.Join(meta, " "))
+			body = strings.Join(lines, "\n")
+		}
+		items = append(items, prefixLines(body, marker, indent))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (c *MarkdownConverter) codeBlock(n *htmlNode) string {
+	lang := codeLanguage(n)
+	for _, child := range n.Children {
+		if child.Tag == "code" && lang == "" {
+			lang = codeLanguage(child)
+		}
+	}
+	code := strings.TrimRight(n.text(), "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+func codeLanguage(n *htmlNode) string {
+	for _, class := range strings.Fields(n.attr("class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(class, prefix) {
+				return strings.TrimPrefix(class, prefix)
+			}
+		}
+	}
+	return n.attr("data-language")
+}
+
+func (c *MarkdownConverter) inline(nodes []*htmlNode) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(c.inlineNode(n))
+	}
+	return b.String()
+}
+
+var (
+	mdWhitespace = regexp.MustCompile(`\s+`)
+	mdSpaces     = regexp.MustCompile(` {2,}`)
+)
+
+func (c *MarkdownConverter) inlineNode(n *htmlNode) string {
+	if n.Tag == "" {
+		return escapeMarkdown(mdWhitespace.ReplaceAllString(n.Text, " "))
+	}
+	switch n.Tag {
+	case "br":
+		return "\\\n"
+	case "input":
+		// Checkboxes are rendered as part of the task list marker.
+		return ""
+	case "img":
+		src := n.attr("src")
+		if src == "" {
+			return ""
+		}
+		return "![" + escapeMarkdown(n.attr("alt")) + "](" + linkDest(src) + linkTitle(n.attr("title")) + ")"
+	case "a":
+		text := c.inline(n.Children)
+		href := n.attr("href")
+		if href == "" {
+			return text
+		}
+		if strings.TrimSpace(text) == "" {
+			return ""
+		}
+		if text == escapeMarkdown(href) && strings.Contains(href, "://") {
+			return "<" + href + ">"
+		}
+		return wrapInline("[", text, "]("+linkDest(href)+linkTitle(n.attr("title"))+")")
+	case "strong", "b":
+		return wrapInline("**", c.inline(n.Children), "**")
+	case "em", "i":
+		return wrapInline("*", c.inline(n.Children), "*")
+	case "del", "s", "strike":
+		return wrapInline("~~", c.inline(n.Children), "~~")
+	case "sup", "sub":
+		// CommonMark has no syntax for these, so keep them as inline HTML.
+		return "<" + n.Tag + ">" + c.inline(n.Children) + "</" + n.Tag + ">"
+	case "code", "kbd", "samp", "tt":
+		return codeSpan(n.text())
+	}
+	return c.inline(n.Children)
+}
+
+// wrapInline surrounds text with delimiters, keeping leading and trailing
+// whitespace outside them so the emphasis still parses.
+func wrapInline(open, text, close string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	lead := text[:strings.Index(text, trimmed)]
+	trail := text[len(lead)+len(trimmed):]
+	return lead + open + trimmed + close + trail
+}
+
+func codeSpan(code string) string {
+	code = strings.Replace(code, "\n", " ", -1)
+	fence := "`"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		code = " " + code + " "
+	}
+	return fence + code + fence
+}
+
+func linkDest(url string) string {
+	if strings.ContainsAny(url, " ()<>") {
+		return "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(url) + ">"
+	}
+	return url
+}
+
+func linkTitle(title string) string {
+	if title == "" {
+		return ""
+	}
+	return ` "` + strings.Replace(title, `"`, `\"`, -1) + `"`
+}
+
+var mdEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`,
+)
+
+func escapeMarkdown(s string) string {
+	return mdEscaper.Replace(s)
+}
+
+var mdLineStart = regexp.MustCompile(`^(#|>|[-+=] |[-+=]$|\d+[.)])`)
+
+// escapeLineStart escapes characters at the start of a paragraph line that
+// would otherwise begin a heading, quote, list or thematic break.
+func escapeLineStart(line string) string {
+	loc := mdLineStart.FindStringIndex(line)
+	if loc == nil {
+		return line
+	}
+	if c := line[loc[1]-1]; c == '.' || c == ')' {
+		return line[:loc[1]-1] + `\` + line[loc[1]-1:]
+	}
+	return `\` + line
+}
+
+// prefixLines prefixes the first line of s with first and every following
+// non-empty line with rest.
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + line
+		case line == "":
+			lines[i] = strings.TrimRight(rest, " ")
+		default:
+			lines[i] = rest + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
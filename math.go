@@ -0,0 +1,194 @@
+package paper
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Math keeps LaTeX math intact through export. Math is written $...$
+// inline and $$...$$ for display, either on one line or with the $$ on
+// lines of their own. In markdown, the escapes Paper adds inside math,
+// like x\_1, are removed; in HTML, math is wrapped in the spans MathJax
+// and KaTeX look for:
+//
+//	<span class="math inline">\(...\)</span>
+//	<span class="math display">\[...\]</span>
+//
+// The other transforms leave math alone, as they do code.
+type Math struct{}
+
+func (m *Math) Transform(doc *Doc) error {
+	if doc.Format == ExportFormatHTML {
+		doc.Content = []byte(convertHTMLText(string(doc.Content), mathHTML))
+		return nil
+	}
+	lines := strings.Split(string(doc.Content), "\n")
+	code, math := codeLines(lines), mathLines(lines)
+	for i, line := range lines {
+		switch {
+		case code[i]:
+		case math[i]:
+			lines[i] = unescapeMath(line)
+		default:
+			var b strings.Builder
+			start := 0
+			for _, span := range mathSpans(line) {
+				b.WriteString(line[start:span[0]])
+				b.WriteString(unescapeMath(line[span[0]:span[1]]))
+				start = span[1]
+			}
+			lines[i] = b.String() + line[start:]
+		}
+	}
+	doc.Content = []byte(strings.Join(lines, "\n"))
+	return nil
+}
+
+// mathEscape matches the markdown escapes that don't belong in LaTeX: an
+// escaped _, *, `, [ or ], and an escaped backslash before a command, as
+// in \\alpha. A \\ elsewhere is LaTeX's line break.
+var mathEscape = regexp.MustCompile(`\\([_*` + "`" + `\[\]])|\\(\\[A-Za-z])`)
+
+func unescapeMath(s string) string {
+	return mathEscape.ReplaceAllString(s, "$1$2")
+}
+
+// mathHTML wraps the math in HTML text.
+func mathHTML(s string) string {
+	var b strings.Builder
+	start := 0
+	for _, span := range mathSpans(s) {
+		b.WriteString(s[start:span[0]])
+		tex := s[span[0]:span[1]]
+		if strings.HasPrefix(tex, "$$") {
+			b.WriteString(`<span class="math display">\[` + tex[2:len(tex)-2] + `\]</span>`)
+		} else {
+			b.WriteString(`<span class="math inline">\(` + tex[1:len(tex)-1] + `\)</span>`)
+		}
+		start = span[1]
+	}
+	return b.String() + s[start:]
+}
+
+// mathSpans returns the byte ranges of the math in a line, delimiters
+// included, outside inline code. Following Pandoc, inline math's opening
+// $ can't be followed by a space, and its closing $ can't follow one or be
+// followed by a digit, so prices like $5 and $10 aren't math. Nor is
+// anything with an unescaped $ inside.
+func mathSpans(line string) [][2]int {
+	var spans [][2]int
+	code := codeSpans(line)
+	for i := 0; i < len(line); i++ {
+		for len(code) > 0 && code[0][1] <= i {
+			code = code[1:]
+		}
+		if len(code) > 0 && i >= code[0][0] {
+			i = code[0][1] - 1
+			code = code[1:]
+			continue
+		}
+		switch {
+		case line[i] == '\\':
+			i++
+		case strings.HasPrefix(line[i:], "$$"):
+			end := strings.Index(line[i+2:], "$$")
+			if end < 0 {
+				return spans
+			}
+			spans = append(spans, [2]int{i, i + 2 + end + 2})
+			i += 2 + end + 1
+		case line[i] == '$' && i+1 < len(line) && line[i+1] != ' ':
+			for j := i + 1; j < len(line); j++ {
+				if line[j] == '\\' {
+					j++
+					continue
+				}
+				if line[j] != '$' {
+					continue
+				}
+				if line[j-1] != ' ' && (j+1 == len(line) || !isDigit(line[j+1])) {
+					spans = append(spans, [2]int{i, j + 1})
+					i = j
+				}
+				break
+			}
+		}
+	}
+	return spans
+}
+
+// mathLines marks the lines of display math blocks, whose $$ are on lines
+// of their own, outside fenced code.
+func mathLines(lines []string) []bool {
+	math := make([]bool, len(lines))
+	code := codeLines(lines)
+	open := false
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		t := strings.TrimSpace(line)
+		switch {
+		case open:
+			math[i] = true
+			open = !strings.HasSuffix(t, "$$")
+		case opensMath(line):
+			math[i], open = true, true
+		}
+	}
+	return math
+}
+
+// opensMath reports whether line starts a display math block that the $$
+// on a later line closes.
+func opensMath(line string) bool {
+	t := strings.TrimSpace(line)
+	return strings.HasPrefix(t, "$$") && (t == "$$" || !strings.Contains(t[2:], "$$"))
+}
+
+// displayMath renders a display math block that starts at lines[i] and
+// returns the index of the line after it.
+func displayMath(b *strings.Builder, lines []string, i int) int {
+	var tex []string
+	for first := true; i < len(lines); i, first = i+1, false {
+		t := strings.TrimSpace(lines[i])
+		if first {
+			t = strings.TrimPrefix(t, "$$")
+		}
+		if !first && strings.HasSuffix(t, "$$") {
+			tex = append(tex, strings.TrimSuffix(t, "$$"))
+			i++
+			break
+		}
+		tex = append(tex, t)
+	}
+	b.WriteString(`<div class="math display">\[` + html.EscapeString(strings.TrimSpace(strings.Join(tex, "\n"))) + `\]</div>` + "\n")
+	return i
+}
+
+// literalSpans returns the byte ranges of a line's inline code and math,
+// in order, merging any that overlap.
+func literalSpans(line string) [][2]int {
+	spans := append(codeSpans(line), mathSpans(line)...)
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	var merged [][2]int
+	for _, span := range spans {
+		if n := len(merged); n > 0 && span[0] < merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], span[1])
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// literalLines marks the lines of fenced code and display math.
+func literalLines(lines []string) []bool {
+	literal := codeLines(lines)
+	for i, math := range mathLines(lines) {
+		literal[i] = literal[i] || math
+	}
+	return literal
+}
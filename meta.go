@@ -0,0 +1,156 @@
+package paper
+
+import (
+	"regexp"
+	"strings"
+)
+
+var mdImage = regexp.MustCompile(`!\[([^\]]*)\]\(\s*<?([^)\s>]+)>?`)
+
+// DocMeta is what a doc says about itself, for feeds and sharing.
+type DocMeta struct {
+	Summary string // plain text
+	Image   string // the cover image's source, as written
+}
+
+// ExtractMeta returns a doc's summary and cover image, and its content
+// without the paragraphs that set them. A paragraph starting "Summary:"
+// gives the summary, which is otherwise the first paragraph cut to roughly
+// a tweet's length. One starting "Cover:", followed by an image or its URL,
+// gives the cover image, which is otherwise the first image with the alt
+// text "cover", or else the first image.
+func ExtractMeta(format ExportFormat, content []byte) (*DocMeta, []byte) {
+	m := &DocMeta{}
+	if format == ExportFormatHTML {
+		content = m.extractHTML(content)
+	} else {
+		content = m.extractMarkdown(content)
+	}
+	if m.Summary == "" {
+		m.Summary = summarize(format, content)
+	}
+	if m.Image == "" {
+		m.Image = findImage(format, content, func(alt string) bool { return strings.EqualFold(alt, "cover") })
+	}
+	if m.Image == "" {
+		m.Image = firstImage(format, content)
+	}
+	return m, content
+}
+
+var metaLine = regexp.MustCompile(`(?i)^[*_]*(summary|cover)[*_]*\s*:[*_]*\s*(.*?)\s*$`)
+
+// set records a Summary: or Cover: value, reporting whether it was used.
+func (m *DocMeta) set(key, summary, image string) bool {
+	switch {
+	case strings.EqualFold(key, "summary") && m.Summary == "" && summary != "":
+		m.Summary = summary
+	case strings.EqualFold(key, "cover") && m.Image == "" && image != "":
+		m.Image = image
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *DocMeta) extractMarkdown(content []byte) []byte {
+	lines := strings.Split(string(content), "\n")
+	literal := literalLines(lines)
+	var kept []string
+	for i := 0; i < len(lines); i++ {
+		sub := metaLine.FindStringSubmatch(strings.TrimSpace(lines[i]))
+		if literal[i] || sub == nil || !m.set(sub[1], strings.Join(strings.Fields(plainMarkdown(sub[2])), " "), markdownSource(sub[2])) {
+			kept = append(kept, lines[i])
+			continue
+		}
+		// Don't leave two blank lines where the paragraph was.
+		if i+1 < len(lines) && strings.TrimSpace(lines[i+1]) == "" && (len(kept) == 0 || strings.TrimSpace(kept[len(kept)-1]) == "") {
+			i++
+		}
+	}
+	return []byte(strings.Join(kept, "\n"))
+}
+
+// markdownSource returns the URL in a Cover: value: an image, a link or a
+// bare URL.
+func markdownSource(s string) string {
+	if m := mdImage.FindStringSubmatch(s); m != nil {
+		return m[2]
+	}
+	if m := markdownAutolink.FindStringSubmatch(s); m != nil {
+		return m[1] + m[2]
+	}
+	return markdownEscape.ReplaceAllString(s, "$1")
+}
+
+var htmlParagraph = regexp.MustCompile(`(?is)<p\b[^>]*>.*?</p>[ \t]*\n?`)
+
+func (m *DocMeta) extractHTML(content []byte) []byte {
+	return htmlParagraph.ReplaceAllFunc(content, func(p []byte) []byte {
+		n := parseHTML(string(p))
+		sub := metaLine.FindStringSubmatch(strings.TrimSpace(n.text()))
+		if sub == nil {
+			return p
+		}
+		image := sub[2]
+		if img := n.find("img"); img != nil && img.attr("src") != "" {
+			image = img.attr("src")
+		} else if a := n.find("a"); a != nil && a.attr("href") != "" {
+			image = a.attr("href")
+		}
+		if !m.set(sub[1], strings.Join(strings.Fields(sub[2]), " "), image) {
+			return p
+		}
+		return nil
+	})
+}
+
+// firstImage returns the source of the first image in a doc, for use as its
+// cover image when shared.
+func firstImage(format ExportFormat, content []byte) string {
+	return findImage(format, content, nil)
+}
+
+// findImage returns the source of the first image whose alt text matches,
+// or of the first image when match is nil.
+func findImage(format ExportFormat, content []byte, match func(alt string) bool) string {
+	if format != ExportFormatHTML {
+		lines := strings.Split(string(content), "\n")
+		code := codeLines(lines)
+		for i, line := range lines {
+			if code[i] {
+				continue
+			}
+			for _, m := range mdImage.FindAllStringSubmatch(stripCodeSpans(line), -1) {
+				if match == nil || match(m[1]) {
+					return m[2]
+				}
+			}
+		}
+		return ""
+	}
+	var src string
+	var walk func(*htmlNode) bool
+	walk = func(n *htmlNode) bool {
+		if n.Tag == "img" && n.attr("src") != "" && (match == nil || match(n.attr("alt"))) {
+			src = n.attr("src")
+			return true
+		}
+		for _, c := range n.Children {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(parseHTML(string(content)))
+	return src
+}
+
+// absURL makes site-relative links absolute so they work off-site.
+func (s *Site) absURL(link string) string {
+	if strings.HasPrefix(link, "/") && !strings.HasPrefix(link, "//") {
+		return s.url(link)
+	}
+	return link
+}
@@ -0,0 +1,234 @@
+package paper
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Metrics counts what syncs and API calls do, for graphing and alerting.
+// Set it as Syncer.Metrics and Instrument the client, then serve it: it
+// implements http.Handler in the Prometheus text format, and Snapshot suits
+// expvar.
+type Metrics struct {
+	mu          sync.Mutex
+	docs        map[string]int64 // by change: added, modified, removed
+	written     int64
+	downloaded  int64
+	requests    int64
+	errors      map[string]int64 // by class, see errorClass
+	syncs       map[string]int64 // by result: ok, failed
+	durationSum float64
+	lastRun     float64
+	lastSuccess time.Time
+	queue       int64
+}
+
+// MetricsSnapshot is the state of a Metrics at one point.
+type MetricsSnapshot struct {
+	DocsSynced      map[string]int64 `json:"docs_synced"`
+	BytesWritten    int64            `json:"bytes_written"`
+	BytesDownloaded int64            `json:"bytes_downloaded"`
+	APIRequests     int64            `json:"api_requests"`
+	APIErrors       map[string]int64 `json:"api_errors"`
+	Syncs           map[string]int64 `json:"syncs"`
+	SyncSeconds     float64          `json:"sync_seconds"`      // total
+	LastSyncSeconds float64          `json:"last_sync_seconds"` // of the last sync
+	LastSuccess     time.Time        `json:"last_success,omitzero"`
+	QueueDepth      int64            `json:"queue_depth"` // docs the current sync has left
+}
+
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return MetricsSnapshot{
+		DocsSynced:      copyCounts(m.docs),
+		BytesWritten:    m.written,
+		BytesDownloaded: m.downloaded,
+		APIRequests:     m.requests,
+		APIErrors:       copyCounts(m.errors),
+		Syncs:           copyCounts(m.syncs),
+		SyncSeconds:     m.durationSum,
+		LastSyncSeconds: m.lastRun,
+		LastSuccess:     m.lastSuccess,
+		QueueDepth:      m.queue,
+	}
+}
+
+func copyCounts(m map[string]int64) map[string]int64 {
+	out := make(map[string]int64, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func count(m *map[string]int64, key string, n int64) {
+	if *m == nil {
+		*m = map[string]int64{}
+	}
+	(*m)[key] += n
+}
+
+// observeSync records a finished sync. It's a no-op on a nil Metrics, as
+// are the other recording methods.
+func (m *Metrics) observeSync(res *SyncResult, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if res != nil {
+		count(&m.docs, "added", int64(len(res.Added)))
+		count(&m.docs, "modified", int64(len(res.Modified)))
+		count(&m.docs, "removed", int64(len(res.Removed)))
+		m.written += res.Written
+	}
+	m.durationSum += d.Seconds()
+	m.lastRun = d.Seconds()
+	m.queue = 0
+	if err != nil {
+		count(&m.syncs, "failed", 1)
+		return
+	}
+	count(&m.syncs, "ok", 1)
+	m.lastSuccess = time.Now()
+}
+
+func (m *Metrics) setQueue(n int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.queue = int64(n)
+	m.mu.Unlock()
+}
+
+// Instrument makes the client's requests count towards m.
+func (m *Metrics) Instrument(c *APIClient) {
+	base := c.HTTP.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.HTTP.Transport = &metricsTransport{base: base, m: m}
+}
+
+type metricsTransport struct {
+	base http.RoundTripper
+	m    *Metrics
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	t.m.mu.Lock()
+	defer t.m.mu.Unlock()
+	t.m.requests++
+	if class := errorClass(resp, err); class != "" {
+		count(&t.m.errors, class, 1)
+	}
+	if err == nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, m: t.m}
+	}
+	return resp, err
+}
+
+// errorClass sorts failed API calls into a few classes worth alerting on
+// separately, or returns "" for a success.
+func errorClass(resp *http.Response, err error) string {
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+	switch code := resp.StatusCode; {
+	case code < 400:
+		return ""
+	case code == http.StatusBadRequest:
+		return "bad_request"
+	case code == http.StatusUnauthorized:
+		return "auth"
+	case code == http.StatusForbidden:
+		return "access"
+	case code == http.StatusConflict:
+		return "endpoint" // e.g. doc_not_found
+	case code == http.StatusTooManyRequests:
+		return "rate_limit"
+	case code >= 500:
+		return "server"
+	default:
+		return "other"
+	}
+}
+
+type countingBody struct {
+	io.ReadCloser
+	m *Metrics
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.m.mu.Lock()
+	b.m.downloaded += int64(n)
+	b.m.mu.Unlock()
+	return n, err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	s := m.Snapshot()
+	var err error
+	metric := func(name, typ, help string) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		}
+	}
+	value := func(name string, v interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, "%s %v\n", name, v)
+		}
+	}
+	labeled := func(name, label string, counts map[string]int64) {
+		keys := make([]string, 0, len(counts))
+		for k := range counts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			value(fmt.Sprintf("%s{%s=%q}", name, label, k), counts[k])
+		}
+	}
+	metric("paper_docs_synced_total", "counter", "Docs added, modified or removed by syncs.")
+	labeled("paper_docs_synced_total", "change", s.DocsSynced)
+	metric("paper_written_bytes_total", "counter", "Bytes of docs written by syncs.")
+	value("paper_written_bytes_total", s.BytesWritten)
+	metric("paper_downloaded_bytes_total", "counter", "Bytes of API responses read.")
+	value("paper_downloaded_bytes_total", s.BytesDownloaded)
+	metric("paper_api_requests_total", "counter", "API requests made.")
+	value("paper_api_requests_total", s.APIRequests)
+	metric("paper_api_errors_total", "counter", "API requests that failed, by class.")
+	labeled("paper_api_errors_total", "class", s.APIErrors)
+	metric("paper_syncs_total", "counter", "Syncs run, by result.")
+	labeled("paper_syncs_total", "result", s.Syncs)
+	metric("paper_sync_duration_seconds_total", "counter", "Time spent syncing.")
+	value("paper_sync_duration_seconds_total", s.SyncSeconds)
+	metric("paper_last_sync_duration_seconds", "gauge", "How long the last sync took.")
+	value("paper_last_sync_duration_seconds", s.LastSyncSeconds)
+	if !s.LastSuccess.IsZero() {
+		metric("paper_last_sync_success_timestamp_seconds", "gauge", "When the last successful sync finished.")
+		value("paper_last_sync_success_timestamp_seconds", s.LastSuccess.Unix())
+	}
+	metric("paper_sync_queue_depth", "gauge", "Docs the sync in progress has yet to write.")
+	value("paper_sync_queue_depth", s.QueueDepth)
+	return err
+}
@@ -0,0 +1,139 @@
+package paper
+
+//go:generate go run maketables.go
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalization is a Unicode normalization form. Titles typed on macOS often
+// arrive decomposed, with accents as separate combining marks, while Linux
+// keeps them composed; normalizing makes the same title produce the same
+// slug and file name on both.
+type Normalization int
+
+const (
+	NFC  Normalization = iota // canonical composition
+	NFD                       // canonical decomposition
+	NFKC                      // compatibility composition
+	NFKD                      // compatibility decomposition
+)
+
+// Normalize converts s to the given form, using the Unicode 14.0.0 tables
+// in normtables.go.
+func Normalize(s string, form Normalization) string {
+	runes := decompose(s, form == NFKC || form == NFKD)
+	if form == NFC || form == NFKC {
+		runes = compose(runes)
+	}
+	return string(runes)
+}
+
+const (
+	hangulBase = 0xAC00
+	hangulL    = 0x1100
+	hangulV    = 0x1161
+	hangulT    = 0x11A7
+	hangulVN   = 21
+	hangulTN   = 28
+	hangulN    = 19 * hangulVN * hangulTN
+)
+
+func decompose(s string, compat bool) []rune {
+	out := make([]rune, 0, len(s))
+	var add func(r rune)
+	add = func(r rune) {
+		if r >= hangulBase && r < hangulBase+hangulN {
+			i := r - hangulBase
+			out = append(out, hangulL+i/(hangulVN*hangulTN), hangulV+i%(hangulVN*hangulTN)/hangulTN)
+			if t := i % hangulTN; t != 0 {
+				out = append(out, hangulT+t)
+			}
+			return
+		}
+		d, ok := canonical[r]
+		if !ok && compat {
+			d, ok = compatibility[r]
+		}
+		if !ok {
+			out = append(out, r)
+			return
+		}
+		for _, c := range d {
+			add(c)
+		}
+	}
+	for _, r := range s {
+		add(r)
+	}
+	// Put each run of combining marks in canonical order.
+	for i := 1; i < len(out); i++ {
+		for j := i; j > 0; j-- {
+			a, b := combiningClass[out[j-1]], combiningClass[out[j]]
+			if b == 0 || a <= b {
+				break
+			}
+			out[j-1], out[j] = out[j], out[j-1]
+		}
+	}
+	return out
+}
+
+func compose(in []rune) []rune {
+	out := make([]rune, 0, len(in))
+	starter := -1
+	var last uint8
+	for _, r := range in {
+		cc := combiningClass[r]
+		if starter >= 0 && (len(out)-1 == starter || last != 0 && last < cc) {
+			if c, ok := composePair(out[starter], r); ok {
+				out[starter] = c
+				continue
+			}
+		}
+		if cc == 0 {
+			starter = len(out)
+		}
+		last = cc
+		out = append(out, r)
+	}
+	return out
+}
+
+func composePair(a, b rune) (rune, bool) {
+	switch {
+	case a >= hangulL && a < hangulL+19 && b >= hangulV && b < hangulV+hangulVN:
+		return hangulBase + ((a-hangulL)*hangulVN+b-hangulV)*hangulTN, true
+	case a >= hangulBase && a < hangulBase+hangulN && (a-hangulBase)%hangulTN == 0 && b > hangulT && b < hangulT+hangulTN:
+		return a + b - hangulT, true
+	}
+	c, ok := compositions[[2]rune{a, b}]
+	return c, ok
+}
+
+// transliterations spell letters that have no decomposition in ASCII.
+var transliterations = map[rune]string{
+	'ß': "ss", 'ẞ': "SS", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O", 'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D",
+	'ł': "l", 'Ł': "L", 'þ': "th", 'Þ': "Th", 'ħ': "h", 'Ħ': "H",
+	'ı': "i", 'ŋ': "ng", 'Ŋ': "Ng", 'ſ': "s",
+}
+
+// Transliterate folds Latin letters to ASCII, e.g. "Crème Brûlée" to "Creme
+// Brulee" and "Straße" to "Strasse", and drops combining marks. Letters
+// from other scripts, such as CJK, are left for the caller to keep or drop.
+func Transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range decompose(s, true) {
+		if t, ok := transliterations[r]; ok {
+			b.WriteString(t)
+			continue
+		}
+		if combiningClass[r] != 0 || unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,7668 @@
+// Code generated by maketables.go from Unicode 14.0.0 character data. DO NOT EDIT.
+
+package paper
+
+// canonical maps runes to their canonical decomposition, one level deep.
+var canonical = map[rune]string{
+	'\u00c0':     "A\u0300",
+	'\u00c1':     "A\u0301",
+	'\u00c2':     "A\u0302",
+	'\u00c3':     "A\u0303",
+	'\u00c4':     "A\u0308",
+	'\u00c5':     "A\u030a",
+	'\u00c7':     "C\u0327",
+	'\u00c8':     "E\u0300",
+	'\u00c9':     "E\u0301",
+	'\u00ca':     "E\u0302",
+	'\u00cb':     "E\u0308",
+	'\u00cc':     "I\u0300",
+	'\u00cd':     "I\u0301",
+	'\u00ce':     "I\u0302",
+	'\u00cf':     "I\u0308",
+	'\u00d1':     "N\u0303",
+	'\u00d2':     "O\u0300",
+	'\u00d3':     "O\u0301",
+	'\u00d4':     "O\u0302",
+	'\u00d5':     "O\u0303",
+	'\u00d6':     "O\u0308",
+	'\u00d9':     "U\u0300",
+	'\u00da':     "U\u0301",
+	'\u00db':     "U\u0302",
+	'\u00dc':     "U\u0308",
+	'\u00dd':     "Y\u0301",
+	'\u00e0':     "a\u0300",
+	'\u00e1':     "a\u0301",
+	'\u00e2':     "a\u0302",
+	'\u00e3':     "a\u0303",
+	'\u00e4':     "a\u0308",
+	'\u00e5':     "a\u030a",
+	'\u00e7':     "c\u0327",
+	'\u00e8':     "e\u0300",
+	'\u00e9':     "e\u0301",
+	'\u00ea':     "e\u0302",
+	'\u00eb':     "e\u0308",
+	'\u00ec':     "i\u0300",
+	'\u00ed':     "i\u0301",
+	'\u00ee':     "i\u0302",
+	'\u00ef':     "i\u0308",
+	'\u00f1':     "n\u0303",
+	'\u00f2':     "o\u0300",
+	'\u00f3':     "o\u0301",
+	'\u00f4':     "o\u0302",
+	'\u00f5':     "o\u0303",
+	'\u00f6':     "o\u0308",
+	'\u00f9':     "u\u0300",
+	'\u00fa':     "u\u0301",
+	'\u00fb':     "u\u0302",
+	'\u00fc':     "u\u0308",
+	'\u00fd':     "y\u0301",
+	'\u00ff':     "y\u0308",
+	'\u0100':     "A\u0304",
+	'\u0101':     "a\u0304",
+	'\u0102':     "A\u0306",
+	'\u0103':     "a\u0306",
+	'\u0104':     "A\u0328",
+	'\u0105':     "a\u0328",
+	'\u0106':     "C\u0301",
+	'\u0107':     "c\u0301",
+	'\u0108':     "C\u0302",
+	'\u0109':     "c\u0302",
+	'\u010a':     "C\u0307",
+	'\u010b':     "c\u0307",
+	'\u010c':     "C\u030c",
+	'\u010d':     "c\u030c",
+	'\u010e':     "D\u030c",
+	'\u010f':     "d\u030c",
+	'\u0112':     "E\u0304",
+	'\u0113':     "e\u0304",
+	'\u0114':     "E\u0306",
+	'\u0115':     "e\u0306",
+	'\u0116':     "E\u0307",
+	'\u0117':     "e\u0307",
+	'\u0118':     "E\u0328",
+	'\u0119':     "e\u0328",
+	'\u011a':     "E\u030c",
+	'\u011b':     "e\u030c",
+	'\u011c':     "G\u0302",
+	'\u011d':     "g\u0302",
+	'\u011e':     "G\u0306",
+	'\u011f':     "g\u0306",
+	'\u0120':     "G\u0307",
+	'\u0121':     "g\u0307",
+	'\u0122':     "G\u0327",
+	'\u0123':     "g\u0327",
+	'\u0124':     "H\u0302",
+	'\u0125':     "h\u0302",
+	'\u0128':     "I\u0303",
+	'\u0129':     "i\u0303",
+	'\u012a':     "I\u0304",
+	'\u012b':     "i\u0304",
+	'\u012c':     "I\u0306",
+	'\u012d':     "i\u0306",
+	'\u012e':     "I\u0328",
+	'\u012f':     "i\u0328",
+	'\u0130':     "I\u0307",
+	'\u0134':     "J\u0302",
+	'\u0135':     "j\u0302",
+	'\u0136':     "K\u0327",
+	'\u0137':     "k\u0327",
+	'\u0139':     "L\u0301",
+	'\u013a':     "l\u0301",
+	'\u013b':     "L\u0327",
+	'\u013c':     "l\u0327",
+	'\u013d':     "L\u030c",
+	'\u013e':     "l\u030c",
+	'\u0143':     "N\u0301",
+	'\u0144':     "n\u0301",
+	'\u0145':     "N\u0327",
+	'\u0146':     "n\u0327",
+	'\u0147':     "N\u030c",
+	'\u0148':     "n\u030c",
+	'\u014c':     "O\u0304",
+	'\u014d':     "o\u0304",
+	'\u014e':     "O\u0306",
+	'\u014f':     "o\u0306",
+	'\u0150':     "O\u030b",
+	'\u0151':     "o\u030b",
+	'\u0154':     "R\u0301",
+	'\u0155':     "r\u0301",
+	'\u0156':     "R\u0327",
+	'\u0157':     "r\u0327",
+	'\u0158':     "R\u030c",
+	'\u0159':     "r\u030c",
+	'\u015a':     "S\u0301",
+	'\u015b':     "s\u0301",
+	'\u015c':     "S\u0302",
+	'\u015d':     "s\u0302",
+	'\u015e':     "S\u0327",
+	'\u015f':     "s\u0327",
+	'\u0160':     "S\u030c",
+	'\u0161':     "s\u030c",
+	'\u0162':     "T\u0327",
+	'\u0163':     "t\u0327",
+	'\u0164':     "T\u030c",
+	'\u0165':     "t\u030c",
+	'\u0168':     "U\u0303",
+	'\u0169':     "u\u0303",
+	'\u016a':     "U\u0304",
+	'\u016b':     "u\u0304",
+	'\u016c':     "U\u0306",
+	'\u016d':     "u\u0306",
+	'\u016e':     "U\u030a",
+	'\u016f':     "u\u030a",
+	'\u0170':     "U\u030b",
+	'\u0171':     "u\u030b",
+	'\u0172':     "U\u0328",
+	'\u0173':     "u\u0328",
+	'\u0174':     "W\u0302",
+	'\u0175':     "w\u0302",
+	'\u0176':     "Y\u0302",
+	'\u0177':     "y\u0302",
+	'\u0178':     "Y\u0308",
+	'\u0179':     "Z\u0301",
+	'\u017a':     "z\u0301",
+	'\u017b':     "Z\u0307",
+	'\u017c':     "z\u0307",
+	'\u017d':     "Z\u030c",
+	'\u017e':     "z\u030c",
+	'\u01a0':     "O\u031b",
+	'\u01a1':     "o\u031b",
+	'\u01af':     "U\u031b",
+	'\u01b0':     "u\u031b",
+	'\u01cd':     "A\u030c",
+	'\u01ce':     "a\u030c",
+	'\u01cf':     "I\u030c",
+	'\u01d0':     "i\u030c",
+	'\u01d1':     "O\u030c",
+	'\u01d2':     "o\u030c",
+	'\u01d3':     "U\u030c",
+	'\u01d4':     "u\u030c",
+	'\u01d5':     "\u00dc\u0304",
+	'\u01d6':     "\u00fc\u0304",
+	'\u01d7':     "\u00dc\u0301",
+	'\u01d8':     "\u00fc\u0301",
+	'\u01d9':     "\u00dc\u030c",
+	'\u01da':     "\u00fc\u030c",
+	'\u01db':     "\u00dc\u0300",
+	'\u01dc':     "\u00fc\u0300",
+	'\u01de':     "\u00c4\u0304",
+	'\u01df':     "\u00e4\u0304",
+	'\u01e0':     "\u0226\u0304",
+	'\u01e1':     "\u0227\u0304",
+	'\u01e2':     "\u00c6\u0304",
+	'\u01e3':     "\u00e6\u0304",
+	'\u01e6':     "G\u030c",
+	'\u01e7':     "g\u030c",
+	'\u01e8':     "K\u030c",
+	'\u01e9':     "k\u030c",
+	'\u01ea':     "O\u0328",
+	'\u01eb':     "o\u0328",
+	'\u01ec':     "\u01ea\u0304",
+	'\u01ed':     "\u01eb\u0304",
+	'\u01ee':     "\u01b7\u030c",
+	'\u01ef':     "\u0292\u030c",
+	'\u01f0':     "j\u030c",
+	'\u01f4':     "G\u0301",
+	'\u01f5':     "g\u0301",
+	'\u01f8':     "N\u0300",
+	'\u01f9':     "n\u0300",
+	'\u01fa':     "\u00c5\u0301",
+	'\u01fb':     "\u00e5\u0301",
+	'\u01fc':     "\u00c6\u0301",
+	'\u01fd':     "\u00e6\u0301",
+	'\u01fe':     "\u00d8\u0301",
+	'\u01ff':     "\u00f8\u0301",
+	'\u0200':     "A\u030f",
+	'\u0201':     "a\u030f",
+	'\u0202':     "A\u0311",
+	'\u0203':     "a\u0311",
+	'\u0204':     "E\u030f",
+	'\u0205':     "e\u030f",
+	'\u0206':     "E\u0311",
+	'\u0207':     "e\u0311",
+	'\u0208':     "I\u030f",
+	'\u0209':     "i\u030f",
+	'\u020a':     "I\u0311",
+	'\u020b':     "i\u0311",
+	'\u020c':     "O\u030f",
+	'\u020d':     "o\u030f",
+	'\u020e':     "O\u0311",
+	'\u020f':     "o\u0311",
+	'\u0210':     "R\u030f",
+	'\u0211':     "r\u030f",
+	'\u0212':     "R\u0311",
+	'\u0213':     "r\u0311",
+	'\u0214':     "U\u030f",
+	'\u0215':     "u\u030f",
+	'\u0216':     "U\u0311",
+	'\u0217':     "u\u0311",
+	'\u0218':     "S\u0326",
+	'\u0219':     "s\u0326",
+	'\u021a':     "T\u0326",
+	'\u021b':     "t\u0326",
+	'\u021e':     "H\u030c",
+	'\u021f':     "h\u030c",
+	'\u0226':     "A\u0307",
+	'\u0227':     "a\u0307",
+	'\u0228':     "E\u0327",
+	'\u0229':     "e\u0327",
+	'\u022a':     "\u00d6\u0304",
+	'\u022b':     "\u00f6\u0304",
+	'\u022c':     "\u00d5\u0304",
+	'\u022d':     "\u00f5\u0304",
+	'\u022e':     "O\u0307",
+	'\u022f':     "o\u0307",
+	'\u0230':     "\u022e\u0304",
+	'\u0231':     "\u022f\u0304",
+	'\u0232':     "Y\u0304",
+	'\u0233':     "y\u0304",
+	'\u0340':     "\u0300",
+	'\u0341':     "\u0301",
+	'\u0343':     "\u0313",
+	'\u0344':     "\u0308\u0301",
+	'\u0374':     "\u02b9",
+	'\u037e':     ";",
+	'\u0385':     "\u00a8\u0301",
+	'\u0386':     "\u0391\u0301",
+	'\u0387':     "\u00b7",
+	'\u0388':     "\u0395\u0301",
+	'\u0389':     "\u0397\u0301",
+	'\u038a':     "\u0399\u0301",
+	'\u038c':     "\u039f\u0301",
+	'\u038e':     "\u03a5\u0301",
+	'\u038f':     "\u03a9\u0301",
+	'\u0390':     "\u03ca\u0301",
+	'\u03aa':     "\u0399\u0308",
+	'\u03ab':     "\u03a5\u0308",
+	'\u03ac':     "\u03b1\u0301",
+	'\u03ad':     "\u03b5\u0301",
+	'\u03ae':     "\u03b7\u0301",
+	'\u03af':     "\u03b9\u0301",
+	'\u03b0':     "\u03cb\u0301",
+	'\u03ca':     "\u03b9\u0308",
+	'\u03cb':     "\u03c5\u0308",
+	'\u03cc':     "\u03bf\u0301",
+	'\u03cd':     "\u03c5\u0301",
+	'\u03ce':     "\u03c9\u0301",
+	'\u03d3':     "\u03d2\u0301",
+	'\u03d4':     "\u03d2\u0308",
+	'\u0400':     "\u0415\u0300",
+	'\u0401':     "\u0415\u0308",
+	'\u0403':     "\u0413\u0301",
+	'\u0407':     "\u0406\u0308",
+	'\u040c':     "\u041a\u0301",
+	'\u040d':     "\u0418\u0300",
+	'\u040e':     "\u0423\u0306",
+	'\u0419':     "\u0418\u0306",
+	'\u0439':     "\u0438\u0306",
+	'\u0450':     "\u0435\u0300",
+	'\u0451':     "\u0435\u0308",
+	'\u0453':     "\u0433\u0301",
+	'\u0457':     "\u0456\u0308",
+	'\u045c':     "\u043a\u0301",
+	'\u045d':     "\u0438\u0300",
+	'\u045e':     "\u0443\u0306",
+	'\u0476':     "\u0474\u030f",
+	'\u0477':     "\u0475\u030f",
+	'\u04c1':     "\u0416\u0306",
+	'\u04c2':     "\u0436\u0306",
+	'\u04d0':     "\u0410\u0306",
+	'\u04d1':     "\u0430\u0306",
+	'\u04d2':     "\u0410\u0308",
+	'\u04d3':     "\u0430\u0308",
+	'\u04d6':     "\u0415\u0306",
+	'\u04d7':     "\u0435\u0306",
+	'\u04da':     "\u04d8\u0308",
+	'\u04db':     "\u04d9\u0308",
+	'\u04dc':     "\u0416\u0308",
+	'\u04dd':     "\u0436\u0308",
+	'\u04de':     "\u0417\u0308",
+	'\u04df':     "\u0437\u0308",
+	'\u04e2':     "\u0418\u0304",
+	'\u04e3':     "\u0438\u0304",
+	'\u04e4':     "\u0418\u0308",
+	'\u04e5':     "\u0438\u0308",
+	'\u04e6':     "\u041e\u0308",
+	'\u04e7':     "\u043e\u0308",
+	'\u04ea':     "\u04e8\u0308",
+	'\u04eb':     "\u04e9\u0308",
+	'\u04ec':     "\u042d\u0308",
+	'\u04ed':     "\u044d\u0308",
+	'\u04ee':     "\u0423\u0304",
+	'\u04ef':     "\u0443\u0304",
+	'\u04f0':     "\u0423\u0308",
+	'\u04f1':     "\u0443\u0308",
+	'\u04f2':     "\u0423\u030b",
+	'\u04f3':     "\u0443\u030b",
+	'\u04f4':     "\u0427\u0308",
+	'\u04f5':     "\u0447\u0308",
+	'\u04f8':     "\u042b\u0308",
+	'\u04f9':     "\u044b\u0308",
+	'\u0622':     "\u0627\u0653",
+	'\u0623':     "\u0627\u0654",
+	'\u0624':     "\u0648\u0654",
+	'\u0625':     "\u0627\u0655",
+	'\u0626':     "\u064a\u0654",
+	'\u06c0':     "\u06d5\u0654",
+	'\u06c2':     "\u06c1\u0654",
+	'\u06d3':     "\u06d2\u0654",
+	'\u0929':     "\u0928\u093c",
+	'\u0931':     "\u0930\u093c",
+	'\u0934':     "\u0933\u093c",
+	'\u0958':     "\u0915\u093c",
+	'\u0959':     "\u0916\u093c",
+	'\u095a':     "\u0917\u093c",
+	'\u095b':     "\u091c\u093c",
+	'\u095c':     "\u0921\u093c",
+	'\u095d':     "\u0922\u093c",
+	'\u095e':     "\u092b\u093c",
+	'\u095f':     "\u092f\u093c",
+	'\u09cb':     "\u09c7\u09be",
+	'\u09cc':     "\u09c7\u09d7",
+	'\u09dc':     "\u09a1\u09bc",
+	'\u09dd':     "\u09a2\u09bc",
+	'\u09df':     "\u09af\u09bc",
+	'\u0a33':     "\u0a32\u0a3c",
+	'\u0a36':     "\u0a38\u0a3c",
+	'\u0a59':     "\u0a16\u0a3c",
+	'\u0a5a':     "\u0a17\u0a3c",
+	'\u0a5b':     "\u0a1c\u0a3c",
+	'\u0a5e':     "\u0a2b\u0a3c",
+	'\u0b48':     "\u0b47\u0b56",
+	'\u0b4b':     "\u0b47\u0b3e",
+	'\u0b4c':     "\u0b47\u0b57",
+	'\u0b5c':     "\u0b21\u0b3c",
+	'\u0b5d':     "\u0b22\u0b3c",
+	'\u0b94':     "\u0b92\u0bd7",
+	'\u0bca':     "\u0bc6\u0bbe",
+	'\u0bcb':     "\u0bc7\u0bbe",
+	'\u0bcc':     "\u0bc6\u0bd7",
+	'\u0c48':     "\u0c46\u0c56",
+	'\u0cc0':     "\u0cbf\u0cd5",
+	'\u0cc7':     "\u0cc6\u0cd5",
+	'\u0cc8':     "\u0cc6\u0cd6",
+	'\u0cca':     "\u0cc6\u0cc2",
+	'\u0ccb':     "\u0cca\u0cd5",
+	'\u0d4a':     "\u0d46\u0d3e",
+	'\u0d4b':     "\u0d47\u0d3e",
+	'\u0d4c':     "\u0d46\u0d57",
+	'\u0dda':     "\u0dd9\u0dca",
+	'\u0ddc':     "\u0dd9\u0dcf",
+	'\u0ddd':     "\u0ddc\u0dca",
+	'\u0dde':     "\u0dd9\u0ddf",
+	'\u0f43':     "\u0f42\u0fb7",
+	'\u0f4d':     "\u0f4c\u0fb7",
+	'\u0f52':     "\u0f51\u0fb7",
+	'\u0f57':     "\u0f56\u0fb7",
+	'\u0f5c':     "\u0f5b\u0fb7",
+	'\u0f69':     "\u0f40\u0fb5",
+	'\u0f73':     "\u0f71\u0f72",
+	'\u0f75':     "\u0f71\u0f74",
+	'\u0f76':     "\u0fb2\u0f80",
+	'\u0f78':     "\u0fb3\u0f80",
+	'\u0f81':     "\u0f71\u0f80",
+	'\u0f93':     "\u0f92\u0fb7",
+	'\u0f9d':     "\u0f9c\u0fb7",
+	'\u0fa2':     "\u0fa1\u0fb7",
+	'\u0fa7':     "\u0fa6\u0fb7",
+	'\u0fac':     "\u0fab\u0fb7",
+	'\u0fb9':     "\u0f90\u0fb5",
+	'\u1026':     "\u1025\u102e",
+	'\u1b06':     "\u1b05\u1b35",
+	'\u1b08':     "\u1b07\u1b35",
+	'\u1b0a':     "\u1b09\u1b35",
+	'\u1b0c':     "\u1b0b\u1b35",
+	'\u1b0e':     "\u1b0d\u1b35",
+	'\u1b12':     "\u1b11\u1b35",
+	'\u1b3b':     "\u1b3a\u1b35",
+	'\u1b3d':     "\u1b3c\u1b35",
+	'\u1b40':     "\u1b3e\u1b35",
+	'\u1b41':     "\u1b3f\u1b35",
+	'\u1b43':     "\u1b42\u1b35",
+	'\u1e00':     "A\u0325",
+	'\u1e01':     "a\u0325",
+	'\u1e02':     "B\u0307",
+	'\u1e03':     "b\u0307",
+	'\u1e04':     "B\u0323",
+	'\u1e05':     "b\u0323",
+	'\u1e06':     "B\u0331",
+	'\u1e07':     "b\u0331",
+	'\u1e08':     "\u00c7\u0301",
+	'\u1e09':     "\u00e7\u0301",
+	'\u1e0a':     "D\u0307",
+	'\u1e0b':     "d\u0307",
+	'\u1e0c':     "D\u0323",
+	'\u1e0d':     "d\u0323",
+	'\u1e0e':     "D\u0331",
+	'\u1e0f':     "d\u0331",
+	'\u1e10':     "D\u0327",
+	'\u1e11':     "d\u0327",
+	'\u1e12':     "D\u032d",
+	'\u1e13':     "d\u032d",
+	'\u1e14':     "\u0112\u0300",
+	'\u1e15':     "\u0113\u0300",
+	'\u1e16':     "\u0112\u0301",
+	'\u1e17':     "\u0113\u0301",
+	'\u1e18':     "E\u032d",
+	'\u1e19':     "e\u032d",
+	'\u1e1a':     "E\u0330",
+	'\u1e1b':     "e\u0330",
+	'\u1e1c':     "\u0228\u0306",
+	'\u1e1d':     "\u0229\u0306",
+	'\u1e1e':     "F\u0307",
+	'\u1e1f':     "f\u0307",
+	'\u1e20':     "G\u0304",
+	'\u1e21':     "g\u0304",
+	'\u1e22':     "H\u0307",
+	'\u1e23':     "h\u0307",
+	'\u1e24':     "H\u0323",
+	'\u1e25':     "h\u0323",
+	'\u1e26':     "H\u0308",
+	'\u1e27':     "h\u0308",
+	'\u1e28':     "H\u0327",
+	'\u1e29':     "h\u0327",
+	'\u1e2a':     "H\u032e",
+	'\u1e2b':     "h\u032e",
+	'\u1e2c':     "I\u0330",
+	'\u1e2d':     "i\u0330",
+	'\u1e2e':     "\u00cf\u0301",
+	'\u1e2f':     "\u00ef\u0301",
+	'\u1e30':     "K\u0301",
+	'\u1e31':     "k\u0301",
+	'\u1e32':     "K\u0323",
+	'\u1e33':     "k\u0323",
+	'\u1e34':     "K\u0331",
+	'\u1e35':     "k\u0331",
+	'\u1e36':     "L\u0323",
+	'\u1e37':     "l\u0323",
+	'\u1e38':     "\u1e36\u0304",
+	'\u1e39':     "\u1e37\u0304",
+	'\u1e3a':     "L\u0331",
+	'\u1e3b':     "l\u0331",
+	'\u1e3c':     "L\u032d",
+	'\u1e3d':     "l\u032d",
+	'\u1e3e':     "M\u0301",
+	'\u1e3f':     "m\u0301",
+	'\u1e40':     "M\u0307",
+	'\u1e41':     "m\u0307",
+	'\u1e42':     "M\u0323",
+	'\u1e43':     "m\u0323",
+	'\u1e44':     "N\u0307",
+	'\u1e45':     "n\u0307",
+	'\u1e46':     "N\u0323",
+	'\u1e47':     "n\u0323",
+	'\u1e48':     "N\u0331",
+	'\u1e49':     "n\u0331",
+	'\u1e4a':     "N\u032d",
+	'\u1e4b':     "n\u032d",
+	'\u1e4c':     "\u00d5\u0301",
+	'\u1e4d':     "\u00f5\u0301",
+	'\u1e4e':     "\u00d5\u0308",
+	'\u1e4f':     "\u00f5\u0308",
+	'\u1e50':     "\u014c\u0300",
+	'\u1e51':     "\u014d\u0300",
+	'\u1e52':     "\u014c\u0301",
+	'\u1e53':     "\u014d\u0301",
+	'\u1e54':     "P\u0301",
+	'\u1e55':     "p\u0301",
+	'\u1e56':     "P\u0307",
+	'\u1e57':     "p\u0307",
+	'\u1e58':     "R\u0307",
+	'\u1e59':     "r\u0307",
+	'\u1e5a':     "R\u0323",
+	'\u1e5b':     "r\u0323",
+	'\u1e5c':     "\u1e5a\u0304",
+	'\u1e5d':     "\u1e5b\u0304",
+	'\u1e5e':     "R\u0331",
+	'\u1e5f':     "r\u0331",
+	'\u1e60':     "S\u0307",
+	'\u1e61':     "s\u0307",
+	'\u1e62':     "S\u0323",
+	'\u1e63':     "s\u0323",
+	'\u1e64':     "\u015a\u0307",
+	'\u1e65':     "\u015b\u0307",
+	'\u1e66':     "\u0160\u0307",
+	'\u1e67':     "\u0161\u0307",
+	'\u1e68':     "\u1e62\u0307",
+	'\u1e69':     "\u1e63\u0307",
+	'\u1e6a':     "T\u0307",
+	'\u1e6b':     "t\u0307",
+	'\u1e6c':     "T\u0323",
+	'\u1e6d':     "t\u0323",
+	'\u1e6e':     "T\u0331",
+	'\u1e6f':     "t\u0331",
+	'\u1e70':     "T\u032d",
+	'\u1e71':     "t\u032d",
+	'\u1e72':     "U\u0324",
+	'\u1e73':     "u\u0324",
+	'\u1e74':     "U\u0330",
+	'\u1e75':     "u\u0330",
+	'\u1e76':     "U\u032d",
+	'\u1e77':     "u\u032d",
+	'\u1e78':     "\u0168\u0301",
+	'\u1e79':     "\u0169\u0301",
+	'\u1e7a':     "\u016a\u0308",
+	'\u1e7b':     "\u016b\u0308",
+	'\u1e7c':     "V\u0303",
+	'\u1e7d':     "v\u0303",
+	'\u1e7e':     "V\u0323",
+	'\u1e7f':     "v\u0323",
+	'\u1e80':     "W\u0300",
+	'\u1e81':     "w\u0300",
+	'\u1e82':     "W\u0301",
+	'\u1e83':     "w\u0301",
+	'\u1e84':     "W\u0308",
+	'\u1e85':     "w\u0308",
+	'\u1e86':     "W\u0307",
+	'\u1e87':     "w\u0307",
+	'\u1e88':     "W\u0323",
+	'\u1e89':     "w\u0323",
+	'\u1e8a':     "X\u0307",
+	'\u1e8b':     "x\u0307",
+	'\u1e8c':     "X\u0308",
+	'\u1e8d':     "x\u0308",
+	'\u1e8e':     "Y\u0307",
+	'\u1e8f':     "y\u0307",
+	'\u1e90':     "Z\u0302",
+	'\u1e91':     "z\u0302",
+	'\u1e92':     "Z\u0323",
+	'\u1e93':     "z\u0323",
+	'\u1e94':     "Z\u0331",
+	'\u1e95':     "z\u0331",
+	'\u1e96':     "h\u0331",
+	'\u1e97':     "t\u0308",
+	'\u1e98':     "w\u030a",
+	'\u1e99':     "y\u030a",
+	'\u1e9b':     "\u017f\u0307",
+	'\u1ea0':     "A\u0323",
+	'\u1ea1':     "a\u0323",
+	'\u1ea2':     "A\u0309",
+	'\u1ea3':     "a\u0309",
+	'\u1ea4':     "\u00c2\u0301",
+	'\u1ea5':     "\u00e2\u0301",
+	'\u1ea6':     "\u00c2\u0300",
+	'\u1ea7':     "\u00e2\u0300",
+	'\u1ea8':     "\u00c2\u0309",
+	'\u1ea9':     "\u00e2\u0309",
+	'\u1eaa':     "\u00c2\u0303",
+	'\u1eab':     "\u00e2\u0303",
+	'\u1eac':     "\u1ea0\u0302",
+	'\u1ead':     "\u1ea1\u0302",
+	'\u1eae':     "\u0102\u0301",
+	'\u1eaf':     "\u0103\u0301",
+	'\u1eb0':     "\u0102\u0300",
+	'\u1eb1':     "\u0103\u0300",
+	'\u1eb2':     "\u0102\u0309",
+	'\u1eb3':     "\u0103\u0309",
+	'\u1eb4':     "\u0102\u0303",
+	'\u1eb5':     "\u0103\u0303",
+	'\u1eb6':     "\u1ea0\u0306",
+	'\u1eb7':     "\u1ea1\u0306",
+	'\u1eb8':     "E\u0323",
+	'\u1eb9':     "e\u0323",
+	'\u1eba':     "E\u0309",
+	'\u1ebb':     "e\u0309",
+	'\u1ebc':     "E\u0303",
+	'\u1ebd':     "e\u0303",
+	'\u1ebe':     "\u00ca\u0301",
+	'\u1ebf':     "\u00ea\u0301",
+	'\u1ec0':     "\u00ca\u0300",
+	'\u1ec1':     "\u00ea\u0300",
+	'\u1ec2':     "\u00ca\u0309",
+	'\u1ec3':     "\u00ea\u0309",
+	'\u1ec4':     "\u00ca\u0303",
+	'\u1ec5':     "\u00ea\u0303",
+	'\u1ec6':     "\u1eb8\u0302",
+	'\u1ec7':     "\u1eb9\u0302",
+	'\u1ec8':     "I\u0309",
+	'\u1ec9':     "i\u0309",
+	'\u1eca':     "I\u0323",
+	'\u1ecb':     "i\u0323",
+	'\u1ecc':     "O\u0323",
+	'\u1ecd':     "o\u0323",
+	'\u1ece':     "O\u0309",
+	'\u1ecf':     "o\u0309",
+	'\u1ed0':     "\u00d4\u0301",
+	'\u1ed1':     "\u00f4\u0301",
+	'\u1ed2':     "\u00d4\u0300",
+	'\u1ed3':     "\u00f4\u0300",
+	'\u1ed4':     "\u00d4\u0309",
+	'\u1ed5':     "\u00f4\u0309",
+	'\u1ed6':     "\u00d4\u0303",
+	'\u1ed7':     "\u00f4\u0303",
+	'\u1ed8':     "\u1ecc\u0302",
+	'\u1ed9':     "\u1ecd\u0302",
+	'\u1eda':     "\u01a0\u0301",
+	'\u1edb':     "\u01a1\u0301",
+	'\u1edc':     "\u01a0\u0300",
+	'\u1edd':     "\u01a1\u0300",
+	'\u1ede':     "\u01a0\u0309",
+	'\u1edf':     "\u01a1\u0309",
+	'\u1ee0':     "\u01a0\u0303",
+	'\u1ee1':     "\u01a1\u0303",
+	'\u1ee2':     "\u01a0\u0323",
+	'\u1ee3':     "\u01a1\u0323",
+	'\u1ee4':     "U\u0323",
+	'\u1ee5':     "u\u0323",
+	'\u1ee6':     "U\u0309",
+	'\u1ee7':     "u\u0309",
+	'\u1ee8':     "\u01af\u0301",
+	'\u1ee9':     "\u01b0\u0301",
+	'\u1eea':     "\u01af\u0300",
+	'\u1eeb':     "\u01b0\u0300",
+	'\u1eec':     "\u01af\u0309",
+	'\u1eed':     "\u01b0\u0309",
+	'\u1eee':     "\u01af\u0303",
+	'\u1eef':     "\u01b0\u0303",
+	'\u1ef0':     "\u01af\u0323",
+	'\u1ef1':     "\u01b0\u0323",
+	'\u1ef2':     "Y\u0300",
+	'\u1ef3':     "y\u0300",
+	'\u1ef4':     "Y\u0323",
+	'\u1ef5':     "y\u0323",
+	'\u1ef6':     "Y\u0309",
+	'\u1ef7':     "y\u0309",
+	'\u1ef8':     "Y\u0303",
+	'\u1ef9':     "y\u0303",
+	'\u1f00':     "\u03b1\u0313",
+	'\u1f01':     "\u03b1\u0314",
+	'\u1f02':     "\u1f00\u0300",
+	'\u1f03':     "\u1f01\u0300",
+	'\u1f04':     "\u1f00\u0301",
+	'\u1f05':     "\u1f01\u0301",
+	'\u1f06':     "\u1f00\u0342",
+	'\u1f07':     "\u1f01\u0342",
+	'\u1f08':     "\u0391\u0313",
+	'\u1f09':     "\u0391\u0314",
+	'\u1f0a':     "\u1f08\u0300",
+	'\u1f0b':     "\u1f09\u0300",
+	'\u1f0c':     "\u1f08\u0301",
+	'\u1f0d':     "\u1f09\u0301",
+	'\u1f0e':     "\u1f08\u0342",
+	'\u1f0f':     "\u1f09\u0342",
+	'\u1f10':     "\u03b5\u0313",
+	'\u1f11':     "\u03b5\u0314",
+	'\u1f12':     "\u1f10\u0300",
+	'\u1f13':     "\u1f11\u0300",
+	'\u1f14':     "\u1f10\u0301",
+	'\u1f15':     "\u1f11\u0301",
+	'\u1f18':     "\u0395\u0313",
+	'\u1f19':     "\u0395\u0314",
+	'\u1f1a':     "\u1f18\u0300",
+	'\u1f1b':     "\u1f19\u0300",
+	'\u1f1c':     "\u1f18\u0301",
+	'\u1f1d':     "\u1f19\u0301",
+	'\u1f20':     "\u03b7\u0313",
+	'\u1f21':     "\u03b7\u0314",
+	'\u1f22':     "\u1f20\u0300",
+	'\u1f23':     "\u1f21\u0300",
+	'\u1f24':     "\u1f20\u0301",
+	'\u1f25':     "\u1f21\u0301",
+	'\u1f26':     "\u1f20\u0342",
+	'\u1f27':     "\u1f21\u0342",
+	'\u1f28':     "\u0397\u0313",
+	'\u1f29':     "\u0397\u0314",
+	'\u1f2a':     "\u1f28\u0300",
+	'\u1f2b':     "\u1f29\u0300",
+	'\u1f2c':     "\u1f28\u0301",
+	'\u1f2d':     "\u1f29\u0301",
+	'\u1f2e':     "\u1f28\u0342",
+	'\u1f2f':     "\u1f29\u0342",
+	'\u1f30':     "\u03b9\u0313",
+	'\u1f31':     "\u03b9\u0314",
+	'\u1f32':     "\u1f30\u0300",
+	'\u1f33':     "\u1f31\u0300",
+	'\u1f34':     "\u1f30\u0301",
+	'\u1f35':     "\u1f31\u0301",
+	'\u1f36':     "\u1f30\u0342",
+	'\u1f37':     "\u1f31\u0342",
+	'\u1f38':     "\u0399\u0313",
+	'\u1f39':     "\u0399\u0314",
+	'\u1f3a':     "\u1f38\u0300",
+	'\u1f3b':     "\u1f39\u0300",
+	'\u1f3c':     "\u1f38\u0301",
+	'\u1f3d':     "\u1f39\u0301",
+	'\u1f3e':     "\u1f38\u0342",
+	'\u1f3f':     "\u1f39\u0342",
+	'\u1f40':     "\u03bf\u0313",
+	'\u1f41':     "\u03bf\u0314",
+	'\u1f42':     "\u1f40\u0300",
+	'\u1f43':     "\u1f41\u0300",
+	'\u1f44':     "\u1f40\u0301",
+	'\u1f45':     "\u1f41\u0301",
+	'\u1f48':     "\u039f\u0313",
+	'\u1f49':     "\u039f\u0314",
+	'\u1f4a':     "\u1f48\u0300",
+	'\u1f4b':     "\u1f49\u0300",
+	'\u1f4c':     "\u1f48\u0301",
+	'\u1f4d':     "\u1f49\u0301",
+	'\u1f50':     "\u03c5\u0313",
+	'\u1f51':     "\u03c5\u0314",
+	'\u1f52':     "\u1f50\u0300",
+	'\u1f53':     "\u1f51\u0300",
+	'\u1f54':     "\u1f50\u0301",
+	'\u1f55':     "\u1f51\u0301",
+	'\u1f56':     "\u1f50\u0342",
+	'\u1f57':     "\u1f51\u0342",
+	'\u1f59':     "\u03a5\u0314",
+	'\u1f5b':     "\u1f59\u0300",
+	'\u1f5d':     "\u1f59\u0301",
+	'\u1f5f':     "\u1f59\u0342",
+	'\u1f60':     "\u03c9\u0313",
+	'\u1f61':     "\u03c9\u0314",
+	'\u1f62':     "\u1f60\u0300",
+	'\u1f63':     "\u1f61\u0300",
+	'\u1f64':     "\u1f60\u0301",
+	'\u1f65':     "\u1f61\u0301",
+	'\u1f66':     "\u1f60\u0342",
+	'\u1f67':     "\u1f61\u0342",
+	'\u1f68':     "\u03a9\u0313",
+	'\u1f69':     "\u03a9\u0314",
+	'\u1f6a':     "\u1f68\u0300",
+	'\u1f6b':     "\u1f69\u0300",
+	'\u1f6c':     "\u1f68\u0301",
+	'\u1f6d':     "\u1f69\u0301",
+	'\u1f6e':     "\u1f68\u0342",
+	'\u1f6f':     "\u1f69\u0342",
+	'\u1f70':     "\u03b1\u0300",
+	'\u1f71':     "\u03ac",
+	'\u1f72':     "\u03b5\u0300",
+	'\u1f73':     "\u03ad",
+	'\u1f74':     "\u03b7\u0300",
+	'\u1f75':     "\u03ae",
+	'\u1f76':     "\u03b9\u0300",
+	'\u1f77':     "\u03af",
+	'\u1f78':     "\u03bf\u0300",
+	'\u1f79':     "\u03cc",
+	'\u1f7a':     "\u03c5\u0300",
+	'\u1f7b':     "\u03cd",
+	'\u1f7c':     "\u03c9\u0300",
+	'\u1f7d':     "\u03ce",
+	'\u1f80':     "\u1f00\u0345",
+	'\u1f81':     "\u1f01\u0345",
+	'\u1f82':     "\u1f02\u0345",
+	'\u1f83':     "\u1f03\u0345",
+	'\u1f84':     "\u1f04\u0345",
+	'\u1f85':     "\u1f05\u0345",
+	'\u1f86':     "\u1f06\u0345",
+	'\u1f87':     "\u1f07\u0345",
+	'\u1f88':     "\u1f08\u0345",
+	'\u1f89':     "\u1f09\u0345",
+	'\u1f8a':     "\u1f0a\u0345",
+	'\u1f8b':     "\u1f0b\u0345",
+	'\u1f8c':     "\u1f0c\u0345",
+	'\u1f8d':     "\u1f0d\u0345",
+	'\u1f8e':     "\u1f0e\u0345",
+	'\u1f8f':     "\u1f0f\u0345",
+	'\u1f90':     "\u1f20\u0345",
+	'\u1f91':     "\u1f21\u0345",
+	'\u1f92':     "\u1f22\u0345",
+	'\u1f93':     "\u1f23\u0345",
+	'\u1f94':     "\u1f24\u0345",
+	'\u1f95':     "\u1f25\u0345",
+	'\u1f96':     "\u1f26\u0345",
+	'\u1f97':     "\u1f27\u0345",
+	'\u1f98':     "\u1f28\u0345",
+	'\u1f99':     "\u1f29\u0345",
+	'\u1f9a':     "\u1f2a\u0345",
+	'\u1f9b':     "\u1f2b\u0345",
+	'\u1f9c':     "\u1f2c\u0345",
+	'\u1f9d':     "\u1f2d\u0345",
+	'\u1f9e':     "\u1f2e\u0345",
+	'\u1f9f':     "\u1f2f\u0345",
+	'\u1fa0':     "\u1f60\u0345",
+	'\u1fa1':     "\u1f61\u0345",
+	'\u1fa2':     "\u1f62\u0345",
+	'\u1fa3':     "\u1f63\u0345",
+	'\u1fa4':     "\u1f64\u0345",
+	'\u1fa5':     "\u1f65\u0345",
+	'\u1fa6':     "\u1f66\u0345",
+	'\u1fa7':     "\u1f67\u0345",
+	'\u1fa8':     "\u1f68\u0345",
+	'\u1fa9':     "\u1f69\u0345",
+	'\u1faa':     "\u1f6a\u0345",
+	'\u1fab':     "\u1f6b\u0345",
+	'\u1fac':     "\u1f6c\u0345",
+	'\u1fad':     "\u1f6d\u0345",
+	'\u1fae':     "\u1f6e\u0345",
+	'\u1faf':     "\u1f6f\u0345",
+	'\u1fb0':     "\u03b1\u0306",
+	'\u1fb1':     "\u03b1\u0304",
+	'\u1fb2':     "\u1f70\u0345",
+	'\u1fb3':     "\u03b1\u0345",
+	'\u1fb4':     "\u03ac\u0345",
+	'\u1fb6':     "\u03b1\u0342",
+	'\u1fb7':     "\u1fb6\u0345",
+	'\u1fb8':     "\u0391\u0306",
+	'\u1fb9':     "\u0391\u0304",
+	'\u1fba':     "\u0391\u0300",
+	'\u1fbb':     "\u0386",
+	'\u1fbc':     "\u0391\u0345",
+	'\u1fbe':     "\u03b9",
+	'\u1fc1':     "\u00a8\u0342",
+	'\u1fc2':     "\u1f74\u0345",
+	'\u1fc3':     "\u03b7\u0345",
+	'\u1fc4':     "\u03ae\u0345",
+	'\u1fc6':     "\u03b7\u0342",
+	'\u1fc7':     "\u1fc6\u0345",
+	'\u1fc8':     "\u0395\u0300",
+	'\u1fc9':     "\u0388",
+	'\u1fca':     "\u0397\u0300",
+	'\u1fcb':     "\u0389",
+	'\u1fcc':     "\u0397\u0345",
+	'\u1fcd':     "\u1fbf\u0300",
+	'\u1fce':     "\u1fbf\u0301",
+	'\u1fcf':     "\u1fbf\u0342",
+	'\u1fd0':     "\u03b9\u0306",
+	'\u1fd1':     "\u03b9\u0304",
+	'\u1fd2':     "\u03ca\u0300",
+	'\u1fd3':     "\u0390",
+	'\u1fd6':     "\u03b9\u0342",
+	'\u1fd7':     "\u03ca\u0342",
+	'\u1fd8':     "\u0399\u0306",
+	'\u1fd9':     "\u0399\u0304",
+	'\u1fda':     "\u0399\u0300",
+	'\u1fdb':     "\u038a",
+	'\u1fdd':     "\u1ffe\u0300",
+	'\u1fde':     "\u1ffe\u0301",
+	'\u1fdf':     "\u1ffe\u0342",
+	'\u1fe0':     "\u03c5\u0306",
+	'\u1fe1':     "\u03c5\u0304",
+	'\u1fe2':     "\u03cb\u0300",
+	'\u1fe3':     "\u03b0",
+	'\u1fe4':     "\u03c1\u0313",
+	'\u1fe5':     "\u03c1\u0314",
+	'\u1fe6':     "\u03c5\u0342",
+	'\u1fe7':     "\u03cb\u0342",
+	'\u1fe8':     "\u03a5\u0306",
+	'\u1fe9':     "\u03a5\u0304",
+	'\u1fea':     "\u03a5\u0300",
+	'\u1feb':     "\u038e",
+	'\u1fec':     "\u03a1\u0314",
+	'\u1fed':     "\u00a8\u0300",
+	'\u1fee':     "\u0385",
+	'\u1fef':     "`",
+	'\u1ff2':     "\u1f7c\u0345",
+	'\u1ff3':     "\u03c9\u0345",
+	'\u1ff4':     "\u03ce\u0345",
+	'\u1ff6':     "\u03c9\u0342",
+	'\u1ff7':     "\u1ff6\u0345",
+	'\u1ff8':     "\u039f\u0300",
+	'\u1ff9':     "\u038c",
+	'\u1ffa':     "\u03a9\u0300",
+	'\u1ffb':     "\u038f",
+	'\u1ffc':     "\u03a9\u0345",
+	'\u1ffd':     "\u00b4",
+	'\u2000':     "\u2002",
+	'\u2001':     "\u2003",
+	'\u2126':     "\u03a9",
+	'\u212a':     "K",
+	'\u212b':     "\u00c5",
+	'\u219a':     "\u2190\u0338",
+	'\u219b':     "\u2192\u0338",
+	'\u21ae':     "\u2194\u0338",
+	'\u21cd':     "\u21d0\u0338",
+	'\u21ce':     "\u21d4\u0338",
+	'\u21cf':     "\u21d2\u0338",
+	'\u2204':     "\u2203\u0338",
+	'\u2209':     "\u2208\u0338",
+	'\u220c':     "\u220b\u0338",
+	'\u2224':     "\u2223\u0338",
+	'\u2226':     "\u2225\u0338",
+	'\u2241':     "\u223c\u0338",
+	'\u2244':     "\u2243\u0338",
+	'\u2247':     "\u2245\u0338",
+	'\u2249':     "\u2248\u0338",
+	'\u2260':     "=\u0338",
+	'\u2262':     "\u2261\u0338",
+	'\u226d':     "\u224d\u0338",
+	'\u226e':     "<\u0338",
+	'\u226f':     ">\u0338",
+	'\u2270':     "\u2264\u0338",
+	'\u2271':     "\u2265\u0338",
+	'\u2274':     "\u2272\u0338",
+	'\u2275':     "\u2273\u0338",
+	'\u2278':     "\u2276\u0338",
+	'\u2279':     "\u2277\u0338",
+	'\u2280':     "\u227a\u0338",
+	'\u2281':     "\u227b\u0338",
+	'\u2284':     "\u2282\u0338",
+	'\u2285':     "\u2283\u0338",
+	'\u2288':     "\u2286\u0338",
+	'\u2289':     "\u2287\u0338",
+	'\u22ac':     "\u22a2\u0338",
+	'\u22ad':     "\u22a8\u0338",
+	'\u22ae':     "\u22a9\u0338",
+	'\u22af':     "\u22ab\u0338",
+	'\u22e0':     "\u227c\u0338",
+	'\u22e1':     "\u227d\u0338",
+	'\u22e2':     "\u2291\u0338",
+	'\u22e3':     "\u2292\u0338",
+	'\u22ea':     "\u22b2\u0338",
+	'\u22eb':     "\u22b3\u0338",
+	'\u22ec':     "\u22b4\u0338",
+	'\u22ed':     "\u22b5\u0338",
+	'\u2329':     "\u3008",
+	'\u232a':     "\u3009",
+	'\u2adc':     "\u2add\u0338",
+	'\u304c':     "\u304b\u3099",
+	'\u304e':     "\u304d\u3099",
+	'\u3050':     "\u304f\u3099",
+	'\u3052':     "\u3051\u3099",
+	'\u3054':     "\u3053\u3099",
+	'\u3056':     "\u3055\u3099",
+	'\u3058':     "\u3057\u3099",
+	'\u305a':     "\u3059\u3099",
+	'\u305c':     "\u305b\u3099",
+	'\u305e':     "\u305d\u3099",
+	'\u3060':     "\u305f\u3099",
+	'\u3062':     "\u3061\u3099",
+	'\u3065':     "\u3064\u3099",
+	'\u3067':     "\u3066\u3099",
+	'\u3069':     "\u3068\u3099",
+	'\u3070':     "\u306f\u3099",
+	'\u3071':     "\u306f\u309a",
+	'\u3073':     "\u3072\u3099",
+	'\u3074':     "\u3072\u309a",
+	'\u3076':     "\u3075\u3099",
+	'\u3077':     "\u3075\u309a",
+	'\u3079':     "\u3078\u3099",
+	'\u307a':     "\u3078\u309a",
+	'\u307c':     "\u307b\u3099",
+	'\u307d':     "\u307b\u309a",
+	'\u3094':     "\u3046\u3099",
+	'\u309e':     "\u309d\u3099",
+	'\u30ac':     "\u30ab\u3099",
+	'\u30ae':     "\u30ad\u3099",
+	'\u30b0':     "\u30af\u3099",
+	'\u30b2':     "\u30b1\u3099",
+	'\u30b4':     "\u30b3\u3099",
+	'\u30b6':     "\u30b5\u3099",
+	'\u30b8':     "\u30b7\u3099",
+	'\u30ba':     "\u30b9\u3099",
+	'\u30bc':     "\u30bb\u3099",
+	'\u30be':     "\u30bd\u3099",
+	'\u30c0':     "\u30bf\u3099",
+	'\u30c2':     "\u30c1\u3099",
+	'\u30c5':     "\u30c4\u3099",
+	'\u30c7':     "\u30c6\u3099",
+	'\u30c9':     "\u30c8\u3099",
+	'\u30d0':     "\u30cf\u3099",
+	'\u30d1':     "\u30cf\u309a",
+	'\u30d3':     "\u30d2\u3099",
+	'\u30d4':     "\u30d2\u309a",
+	'\u30d6':     "\u30d5\u3099",
+	'\u30d7':     "\u30d5\u309a",
+	'\u30d9':     "\u30d8\u3099",
+	'\u30da':     "\u30d8\u309a",
+	'\u30dc':     "\u30db\u3099",
+	'\u30dd':     "\u30db\u309a",
+	'\u30f4':     "\u30a6\u3099",
+	'\u30f7':     "\u30ef\u3099",
+	'\u30f8':     "\u30f0\u3099",
+	'\u30f9':     "\u30f1\u3099",
+	'\u30fa':     "\u30f2\u3099",
+	'\u30fe':     "\u30fd\u3099",
+	'\uf900':     "\u8c48",
+	'\uf901':     "\u66f4",
+	'\uf902':     "\u8eca",
+	'\uf903':     "\u8cc8",
+	'\uf904':     "\u6ed1",
+	'\uf905':     "\u4e32",
+	'\uf906':     "\u53e5",
+	'\uf907':     "\u9f9c",
+	'\uf908':     "\u9f9c",
+	'\uf909':     "\u5951",
+	'\uf90a':     "\u91d1",
+	'\uf90b':     "\u5587",
+	'\uf90c':     "\u5948",
+	'\uf90d':     "\u61f6",
+	'\uf90e':     "\u7669",
+	'\uf90f':     "\u7f85",
+	'\uf910':     "\u863f",
+	'\uf911':     "\u87ba",
+	'\uf912':     "\u88f8",
+	'\uf913':     "\u908f",
+	'\uf914':     "\u6a02",
+	'\uf915':     "\u6d1b",
+	'\uf916':     "\u70d9",
+	'\uf917':     "\u73de",
+	'\uf918':     "\u843d",
+	'\uf919':     "\u916a",
+	'\uf91a':     "\u99f1",
+	'\uf91b':     "\u4e82",
+	'\uf91c':     "\u5375",
+	'\uf91d':     "\u6b04",
+	'\uf91e':     "\u721b",
+	'\uf91f':     "\u862d",
+	'\uf920':     "\u9e1e",
+	'\uf921':     "\u5d50",
+	'\uf922':     "\u6feb",
+	'\uf923':     "\u85cd",
+	'\uf924':     "\u8964",
+	'\uf925':     "\u62c9",
+	'\uf926':     "\u81d8",
+	'\uf927':     "\u881f",
+	'\uf928':     "\u5eca",
+	'\uf929':     "\u6717",
+	'\uf92a':     "\u6d6a",
+	'\uf92b':     "\u72fc",
+	'\uf92c':     "\u90ce",
+	'\uf92d':     "\u4f86",
+	'\uf92e':     "\u51b7",
+	'\uf92f':     "\u52de",
+	'\uf930':     "\u64c4",
+	'\uf931':     "\u6ad3",
+	'\uf932':     "\u7210",
+	'\uf933':     "\u76e7",
+	'\uf934':     "\u8001",
+	'\uf935':     "\u8606",
+	'\uf936':     "\u865c",
+	'\uf937':     "\u8def",
+	'\uf938':     "\u9732",
+	'\uf939':     "\u9b6f",
+	'\uf93a':     "\u9dfa",
+	'\uf93b':     "\u788c",
+	'\uf93c':     "\u797f",
+	'\uf93d':     "\u7da0",
+	'\uf93e':     "\u83c9",
+	'\uf93f':     "\u9304",
+	'\uf940':     "\u9e7f",
+	'\uf941':     "\u8ad6",
+	'\uf942':     "\u58df",
+	'\uf943':     "\u5f04",
+	'\uf944':     "\u7c60",
+	'\uf945':     "\u807e",
+	'\uf946':     "\u7262",
+	'\uf947':     "\u78ca",
+	'\uf948':     "\u8cc2",
+	'\uf949':     "\u96f7",
+	'\uf94a':     "\u58d8",
+	'\uf94b':     "\u5c62",
+	'\uf94c':     "\u6a13",
+	'\uf94d':     "\u6dda",
+	'\uf94e':     "\u6f0f",
+	'\uf94f':     "\u7d2f",
+	'\uf950':     "\u7e37",
+	'\uf951':     "\u964b",
+	'\uf952':     "\u52d2",
+	'\uf953':     "\u808b",
+	'\uf954':     "\u51dc",
+	'\uf955':     "\u51cc",
+	'\uf956':     "\u7a1c",
+	'\uf957':     "\u7dbe",
+	'\uf958':     "\u83f1",
+	'\uf959':     "\u9675",
+	'\uf95a':     "\u8b80",
+	'\uf95b':     "\u62cf",
+	'\uf95c':     "\u6a02",
+	'\uf95d':     "\u8afe",
+	'\uf95e':     "\u4e39",
+	'\uf95f':     "\u5be7",
+	'\uf960':     "\u6012",
+	'\uf961':     "\u7387",
+	'\uf962':     "\u7570",
+	'\uf963':     "\u5317",
+	'\uf964':     "\u78fb",
+	'\uf965':     "\u4fbf",
+	'\uf966':     "\u5fa9",
+	'\uf967':     "\u4e0d",
+	'\uf968':     "\u6ccc",
+	'\uf969':     "\u6578",
+	'\uf96a':     "\u7d22",
+	'\uf96b':     "\u53c3",
+	'\uf96c':     "\u585e",
+	'\uf96d':     "\u7701",
+	'\uf96e':     "\u8449",
+	'\uf96f':     "\u8aaa",
+	'\uf970':     "\u6bba",
+	'\uf971':     "\u8fb0",
+	'\uf972':     "\u6c88",
+	'\uf973':     "\u62fe",
+	'\uf974':     "\u82e5",
+	'\uf975':     "\u63a0",
+	'\uf976':     "\u7565",
+	'\uf977':     "\u4eae",
+	'\uf978':     "\u5169",
+	'\uf979':     "\u51c9",
+	'\uf97a':     "\u6881",
+	'\uf97b':     "\u7ce7",
+	'\uf97c':     "\u826f",
+	'\uf97d':     "\u8ad2",
+	'\uf97e':     "\u91cf",
+	'\uf97f':     "\u52f5",
+	'\uf980':     "\u5442",
+	'\uf981':     "\u5973",
+	'\uf982':     "\u5eec",
+	'\uf983':     "\u65c5",
+	'\uf984':     "\u6ffe",
+	'\uf985':     "\u792a",
+	'\uf986':     "\u95ad",
+	'\uf987':     "\u9a6a",
+	'\uf988':     "\u9e97",
+	'\uf989':     "\u9ece",
+	'\uf98a':     "\u529b",
+	'\uf98b':     "\u66c6",
+	'\uf98c':     "\u6b77",
+	'\uf98d':     "\u8f62",
+	'\uf98e':     "\u5e74",
+	'\uf98f':     "\u6190",
+	'\uf990':     "\u6200",
+	'\uf991':     "\u649a",
+	'\uf992':     "\u6f23",
+	'\uf993':     "\u7149",
+	'\uf994':     "\u7489",
+	'\uf995':     "\u79ca",
+	'\uf996':     "\u7df4",
+	'\uf997':     "\u806f",
+	'\uf998':     "\u8f26",
+	'\uf999':     "\u84ee",
+	'\uf99a':     "\u9023",
+	'\uf99b':     "\u934a",
+	'\uf99c':     "\u5217",
+	'\uf99d':     "\u52a3",
+	'\uf99e':     "\u54bd",
+	'\uf99f':     "\u70c8",
+	'\uf9a0':     "\u88c2",
+	'\uf9a1':     "\u8aaa",
+	'\uf9a2':     "\u5ec9",
+	'\uf9a3':     "\u5ff5",
+	'\uf9a4':     "\u637b",
+	'\uf9a5':     "\u6bae",
+	'\uf9a6':     "\u7c3e",
+	'\uf9a7':     "\u7375",
+	'\uf9a8':     "\u4ee4",
+	'\uf9a9':     "\u56f9",
+	'\uf9aa':     "\u5be7",
+	'\uf9ab':     "\u5dba",
+	'\uf9ac':     "\u601c",
+	'\uf9ad':     "\u73b2",
+	'\uf9ae':     "\u7469",
+	'\uf9af':     "\u7f9a",
+	'\uf9b0':     "\u8046",
+	'\uf9b1':     "\u9234",
+	'\uf9b2':     "\u96f6",
+	'\uf9b3':     "\u9748",
+	'\uf9b4':     "\u9818",
+	'\uf9b5':     "\u4f8b",
+	'\uf9b6':     "\u79ae",
+	'\uf9b7':     "\u91b4",
+	'\uf9b8':     "\u96b8",
+	'\uf9b9':     "\u60e1",
+	'\uf9ba':     "\u4e86",
+	'\uf9bb':     "\u50da",
+	'\uf9bc':     "\u5bee",
+	'\uf9bd':     "\u5c3f",
+	'\uf9be':     "\u6599",
+	'\uf9bf':     "\u6a02",
+	'\uf9c0':     "\u71ce",
+	'\uf9c1':     "\u7642",
+	'\uf9c2':     "\u84fc",
+	'\uf9c3':     "\u907c",
+	'\uf9c4':     "\u9f8d",
+	'\uf9c5':     "\u6688",
+	'\uf9c6':     "\u962e",
+	'\uf9c7':     "\u5289",
+	'\uf9c8':     "\u677b",
+	'\uf9c9':     "\u67f3",
+	'\uf9ca':     "\u6d41",
+	'\uf9cb':     "\u6e9c",
+	'\uf9cc':     "\u7409",
+	'\uf9cd':     "\u7559",
+	'\uf9ce':     "\u786b",
+	'\uf9cf':     "\u7d10",
+	'\uf9d0':     "\u985e",
+	'\uf9d1':     "\u516d",
+	'\uf9d2':     "\u622e",
+	'\uf9d3':     "\u9678",
+	'\uf9d4':     "\u502b",
+	'\uf9d5':     "\u5d19",
+	'\uf9d6':     "\u6dea",
+	'\uf9d7':     "\u8f2a",
+	'\uf9d8':     "\u5f8b",
+	'\uf9d9':     "\u6144",
+	'\uf9da':     "\u6817",
+	'\uf9db':     "\u7387",
+	'\uf9dc':     "\u9686",
+	'\uf9dd':     "\u5229",
+	'\uf9de':     "\u540f",
+	'\uf9df':     "\u5c65",
+	'\uf9e0':     "\u6613",
+	'\uf9e1':     "\u674e",
+	'\uf9e2':     "\u68a8",
+	'\uf9e3':     "\u6ce5",
+	'\uf9e4':     "\u7406",
+	'\uf9e5':     "\u75e2",
+	'\uf9e6':     "\u7f79",
+	'\uf9e7':     "\u88cf",
+	'\uf9e8':     "\u88e1",
+	'\uf9e9':     "\u91cc",
+	'\uf9ea':     "\u96e2",
+	'\uf9eb':     "\u533f",
+	'\uf9ec':     "\u6eba",
+	'\uf9ed':     "\u541d",
+	'\uf9ee':     "\u71d0",
+	'\uf9ef':     "\u7498",
+	'\uf9f0':     "\u85fa",
+	'\uf9f1':     "\u96a3",
+	'\uf9f2':     "\u9c57",
+	'\uf9f3':     "\u9e9f",
+	'\uf9f4':     "\u6797",
+	'\uf9f5':     "\u6dcb",
+	'\uf9f6':     "\u81e8",
+	'\uf9f7':     "\u7acb",
+	'\uf9f8':     "\u7b20",
+	'\uf9f9':     "\u7c92",
+	'\uf9fa':     "\u72c0",
+	'\uf9fb':     "\u7099",
+	'\uf9fc':     "\u8b58",
+	'\uf9fd':     "\u4ec0",
+	'\uf9fe':     "\u8336",
+	'\uf9ff':     "\u523a",
+	'\ufa00':     "\u5207",
+	'\ufa01':     "\u5ea6",
+	'\ufa02':     "\u62d3",
+	'\ufa03':     "\u7cd6",
+	'\ufa04':     "\u5b85",
+	'\ufa05':     "\u6d1e",
+	'\ufa06':     "\u66b4",
+	'\ufa07':     "\u8f3b",
+	'\ufa08':     "\u884c",
+	'\ufa09':     "\u964d",
+	'\ufa0a':     "\u898b",
+	'\ufa0b':     "\u5ed3",
+	'\ufa0c':     "\u5140",
+	'\ufa0d':     "\u55c0",
+	'\ufa10':     "\u585a",
+	'\ufa12':     "\u6674",
+	'\ufa15':     "\u51de",
+	'\ufa16':     "\u732a",
+	'\ufa17':     "\u76ca",
+	'\ufa18':     "\u793c",
+	'\ufa19':     "\u795e",
+	'\ufa1a':     "\u7965",
+	'\ufa1b':     "\u798f",
+	'\ufa1c':     "\u9756",
+	'\ufa1d':     "\u7cbe",
+	'\ufa1e':     "\u7fbd",
+	'\ufa20':     "\u8612",
+	'\ufa22':     "\u8af8",
+	'\ufa25':     "\u9038",
+	'\ufa26':     "\u90fd",
+	'\ufa2a':     "\u98ef",
+	'\ufa2b':     "\u98fc",
+	'\ufa2c':     "\u9928",
+	'\ufa2d':     "\u9db4",
+	'\ufa2e':     "\u90de",
+	'\ufa2f':     "\u96b7",
+	'\ufa30':     "\u4fae",
+	'\ufa31':     "\u50e7",
+	'\ufa32':     "\u514d",
+	'\ufa33':     "\u52c9",
+	'\ufa34':     "\u52e4",
+	'\ufa35':     "\u5351",
+	'\ufa36':     "\u559d",
+	'\ufa37':     "\u5606",
+	'\ufa38':     "\u5668",
+	'\ufa39':     "\u5840",
+	'\ufa3a':     "\u58a8",
+	'\ufa3b':     "\u5c64",
+	'\ufa3c':     "\u5c6e",
+	'\ufa3d':     "\u6094",
+	'\ufa3e':     "\u6168",
+	'\ufa3f':     "\u618e",
+	'\ufa40':     "\u61f2",
+	'\ufa41':     "\u654f",
+	'\ufa42':     "\u65e2",
+	'\ufa43':     "\u6691",
+	'\ufa44':     "\u6885",
+	'\ufa45':     "\u6d77",
+	'\ufa46':     "\u6e1a",
+	'\ufa47':     "\u6f22",
+	'\ufa48':     "\u716e",
+	'\ufa49':     "\u722b",
+	'\ufa4a':     "\u7422",
+	'\ufa4b':     "\u7891",
+	'\ufa4c':     "\u793e",
+	'\ufa4d':     "\u7949",
+	'\ufa4e':     "\u7948",
+	'\ufa4f':     "\u7950",
+	'\ufa50':     "\u7956",
+	'\ufa51':     "\u795d",
+	'\ufa52':     "\u798d",
+	'\ufa53':     "\u798e",
+	'\ufa54':     "\u7a40",
+	'\ufa55':     "\u7a81",
+	'\ufa56':     "\u7bc0",
+	'\ufa57':     "\u7df4",
+	'\ufa58':     "\u7e09",
+	'\ufa59':     "\u7e41",
+	'\ufa5a':     "\u7f72",
+	'\ufa5b':     "\u8005",
+	'\ufa5c':     "\u81ed",
+	'\ufa5d':     "\u8279",
+	'\ufa5e':     "\u8279",
+	'\ufa5f':     "\u8457",
+	'\ufa60':     "\u8910",
+	'\ufa61':     "\u8996",
+	'\ufa62':     "\u8b01",
+	'\ufa63':     "\u8b39",
+	'\ufa64':     "\u8cd3",
+	'\ufa65':     "\u8d08",
+	'\ufa66':     "\u8fb6",
+	'\ufa67':     "\u9038",
+	'\ufa68':     "\u96e3",
+	'\ufa69':     "\u97ff",
+	'\ufa6a':     "\u983b",
+	'\ufa6b':     "\u6075",
+	'\ufa6c':     "\U000242ee",
+	'\ufa6d':     "\u8218",
+	'\ufa70':     "\u4e26",
+	'\ufa71':     "\u51b5",
+	'\ufa72':     "\u5168",
+	'\ufa73':     "\u4f80",
+	'\ufa74':     "\u5145",
+	'\ufa75':     "\u5180",
+	'\ufa76':     "\u52c7",
+	'\ufa77':     "\u52fa",
+	'\ufa78':     "\u559d",
+	'\ufa79':     "\u5555",
+	'\ufa7a':     "\u5599",
+	'\ufa7b':     "\u55e2",
+	'\ufa7c':     "\u585a",
+	'\ufa7d':     "\u58b3",
+	'\ufa7e':     "\u5944",
+	'\ufa7f':     "\u5954",
+	'\ufa80':     "\u5a62",
+	'\ufa81':     "\u5b28",
+	'\ufa82':     "\u5ed2",
+	'\ufa83':     "\u5ed9",
+	'\ufa84':     "\u5f69",
+	'\ufa85':     "\u5fad",
+	'\ufa86':     "\u60d8",
+	'\ufa87':     "\u614e",
+	'\ufa88':     "\u6108",
+	'\ufa89':     "\u618e",
+	'\ufa8a':     "\u6160",
+	'\ufa8b':     "\u61f2",
+	'\ufa8c':     "\u6234",
+	'\ufa8d':     "\u63c4",
+	'\ufa8e':     "\u641c",
+	'\ufa8f':     "\u6452",
+	'\ufa90':     "\u6556",
+	'\ufa91':     "\u6674",
+	'\ufa92':     "\u6717",
+	'\ufa93':     "\u671b",
+	'\ufa94':     "\u6756",
+	'\ufa95':     "\u6b79",
+	'\ufa96':     "\u6bba",
+	'\ufa97':     "\u6d41",
+	'\ufa98':     "\u6edb",
+	'\ufa99':     "\u6ecb",
+	'\ufa9a':     "\u6f22",
+	'\ufa9b':     "\u701e",
+	'\ufa9c':     "\u716e",
+	'\ufa9d':     "\u77a7",
+	'\ufa9e':     "\u7235",
+	'\ufa9f':     "\u72af",
+	'\ufaa0':     "\u732a",
+	'\ufaa1':     "\u7471",
+	'\ufaa2':     "\u7506",
+	'\ufaa3':     "\u753b",
+	'\ufaa4':     "\u761d",
+	'\ufaa5':     "\u761f",
+	'\ufaa6':     "\u76ca",
+	'\ufaa7':     "\u76db",
+	'\ufaa8':     "\u76f4",
+	'\ufaa9':     "\u774a",
+	'\ufaaa':     "\u7740",
+	'\ufaab':     "\u78cc",
+	'\ufaac':     "\u7ab1",
+	'\ufaad':     "\u7bc0",
+	'\ufaae':     "\u7c7b",
+	'\ufaaf':     "\u7d5b",
+	'\ufab0':     "\u7df4",
+	'\ufab1':     "\u7f3e",
+	'\ufab2':     "\u8005",
+	'\ufab3':     "\u8352",
+	'\ufab4':     "\u83ef",
+	'\ufab5':     "\u8779",
+	'\ufab6':     "\u8941",
+	'\ufab7':     "\u8986",
+	'\ufab8':     "\u8996",
+	'\ufab9':     "\u8abf",
+	'\ufaba':     "\u8af8",
+	'\ufabb':     "\u8acb",
+	'\ufabc':     "\u8b01",
+	'\ufabd':     "\u8afe",
+	'\ufabe':     "\u8aed",
+	'\ufabf':     "\u8b39",
+	'\ufac0':     "\u8b8a",
+	'\ufac1':     "\u8d08",
+	'\ufac2':     "\u8f38",
+	'\ufac3':     "\u9072",
+	'\ufac4':     "\u9199",
+	'\ufac5':     "\u9276",
+	'\ufac6':     "\u967c",
+	'\ufac7':     "\u96e3",
+	'\ufac8':     "\u9756",
+	'\ufac9':     "\u97db",
+	'\ufaca':     "\u97ff",
+	'\ufacb':     "\u980b",
+	'\ufacc':     "\u983b",
+	'\ufacd':     "\u9b12",
+	'\uface':     "\u9f9c",
+	'\ufacf':     "\U0002284a",
+	'\ufad0':     "\U00022844",
+	'\ufad1':     "\U000233d5",
+	'\ufad2':     "\u3b9d",
+	'\ufad3':     "\u4018",
+	'\ufad4':     "\u4039",
+	'\ufad5':     "\U00025249",
+	'\ufad6':     "\U00025cd0",
+	'\ufad7':     "\U00027ed3",
+	'\ufad8':     "\u9f43",
+	'\ufad9':     "\u9f8e",
+	'\ufb1d':     "\u05d9\u05b4",
+	'\ufb1f':     "\u05f2\u05b7",
+	'\ufb2a':     "\u05e9\u05c1",
+	'\ufb2b':     "\u05e9\u05c2",
+	'\ufb2c':     "\ufb49\u05c1",
+	'\ufb2d':     "\ufb49\u05c2",
+	'\ufb2e':     "\u05d0\u05b7",
+	'\ufb2f':     "\u05d0\u05b8",
+	'\ufb30':     "\u05d0\u05bc",
+	'\ufb31':     "\u05d1\u05bc",
+	'\ufb32':     "\u05d2\u05bc",
+	'\ufb33':     "\u05d3\u05bc",
+	'\ufb34':     "\u05d4\u05bc",
+	'\ufb35':     "\u05d5\u05bc",
+	'\ufb36':     "\u05d6\u05bc",
+	'\ufb38':     "\u05d8\u05bc",
+	'\ufb39':     "\u05d9\u05bc",
+	'\ufb3a':     "\u05da\u05bc",
+	'\ufb3b':     "\u05db\u05bc",
+	'\ufb3c':     "\u05dc\u05bc",
+	'\ufb3e':     "\u05de\u05bc",
+	'\ufb40':     "\u05e0\u05bc",
+	'\ufb41':     "\u05e1\u05bc",
+	'\ufb43':     "\u05e3\u05bc",
+	'\ufb44':     "\u05e4\u05bc",
+	'\ufb46':     "\u05e6\u05bc",
+	'\ufb47':     "\u05e7\u05bc",
+	'\ufb48':     "\u05e8\u05bc",
+	'\ufb49':     "\u05e9\u05bc",
+	'\ufb4a':     "\u05ea\u05bc",
+	'\ufb4b':     "\u05d5\u05b9",
+	'\ufb4c':     "\u05d1\u05bf",
+	'\ufb4d':     "\u05db\u05bf",
+	'\ufb4e':     "\u05e4\u05bf",
+	'\U0001109a': "\U00011099\U000110ba",
+	'\U0001109c': "\U0001109b\U000110ba",
+	'\U000110ab': "\U000110a5\U000110ba",
+	'\U0001112e': "\U00011131\U00011127",
+	'\U0001112f': "\U00011132\U00011127",
+	'\U0001134b': "\U00011347\U0001133e",
+	'\U0001134c': "\U00011347\U00011357",
+	'\U000114bb': "\U000114b9\U000114ba",
+	'\U000114bc': "\U000114b9\U000114b0",
+	'\U000114be': "\U000114b9\U000114bd",
+	'\U000115ba': "\U000115b8\U000115af",
+	'\U000115bb': "\U000115b9\U000115af",
+	'\U00011938': "\U00011935\U00011930",
+	'\U0001d15e': "\U0001d157\U0001d165",
+	'\U0001d15f': "\U0001d158\U0001d165",
+	'\U0001d160': "\U0001d15f\U0001d16e",
+	'\U0001d161': "\U0001d15f\U0001d16f",
+	'\U0001d162': "\U0001d15f\U0001d170",
+	'\U0001d163': "\U0001d15f\U0001d171",
+	'\U0001d164': "\U0001d15f\U0001d172",
+	'\U0001d1bb': "\U0001d1b9\U0001d165",
+	'\U0001d1bc': "\U0001d1ba\U0001d165",
+	'\U0001d1bd': "\U0001d1bb\U0001d16e",
+	'\U0001d1be': "\U0001d1bc\U0001d16e",
+	'\U0001d1bf': "\U0001d1bb\U0001d16f",
+	'\U0001d1c0': "\U0001d1bc\U0001d16f",
+	'\U0002f800': "\u4e3d",
+	'\U0002f801': "\u4e38",
+	'\U0002f802': "\u4e41",
+	'\U0002f803': "\U00020122",
+	'\U0002f804': "\u4f60",
+	'\U0002f805': "\u4fae",
+	'\U0002f806': "\u4fbb",
+	'\U0002f807': "\u5002",
+	'\U0002f808': "\u507a",
+	'\U0002f809': "\u5099",
+	'\U0002f80a': "\u50e7",
+	'\U0002f80b': "\u50cf",
+	'\U0002f80c': "\u349e",
+	'\U0002f80d': "\U0002063a",
+	'\U0002f80e': "\u514d",
+	'\U0002f80f': "\u5154",
+	'\U0002f810': "\u5164",
+	'\U0002f811': "\u5177",
+	'\U0002f812': "\U0002051c",
+	'\U0002f813': "\u34b9",
+	'\U0002f814': "\u5167",
+	'\U0002f815': "\u518d",
+	'\U0002f816': "\U0002054b",
+	'\U0002f817': "\u5197",
+	'\U0002f818': "\u51a4",
+	'\U0002f819': "\u4ecc",
+	'\U0002f81a': "\u51ac",
+	'\U0002f81b': "\u51b5",
+	'\U0002f81c': "\U000291df",
+	'\U0002f81d': "\u51f5",
+	'\U0002f81e': "\u5203",
+	'\U0002f81f': "\u34df",
+	'\U0002f820': "\u523b",
+	'\U0002f821': "\u5246",
+	'\U0002f822': "\u5272",
+	'\U0002f823': "\u5277",
+	'\U0002f824': "\u3515",
+	'\U0002f825': "\u52c7",
+	'\U0002f826': "\u52c9",
+	'\U0002f827': "\u52e4",
+	'\U0002f828': "\u52fa",
+	'\U0002f829': "\u5305",
+	'\U0002f82a': "\u5306",
+	'\U0002f82b': "\u5317",
+	'\U0002f82c': "\u5349",
+	'\U0002f82d': "\u5351",
+	'\U0002f82e': "\u535a",
+	'\U0002f82f': "\u5373",
+	'\U0002f830': "\u537d",
+	'\U0002f831': "\u537f",
+	'\U0002f832': "\u537f",
+	'\U0002f833': "\u537f",
+	'\U0002f834': "\U00020a2c",
+	'\U0002f835': "\u7070",
+	'\U0002f836': "\u53ca",
+	'\U0002f837': "\u53df",
+	'\U0002f838': "\U00020b63",
+	'\U0002f839': "\u53eb",
+	'\U0002f83a': "\u53f1",
+	'\U0002f83b': "\u5406",
+	'\U0002f83c': "\u549e",
+	'\U0002f83d': "\u5438",
+	'\U0002f83e': "\u5448",
+	'\U0002f83f': "\u5468",
+	'\U0002f840': "\u54a2",
+	'\U0002f841': "\u54f6",
+	'\U0002f842': "\u5510",
+	'\U0002f843': "\u5553",
+	'\U0002f844': "\u5563",
+	'\U0002f845': "\u5584",
+	'\U0002f846': "\u5584",
+	'\U0002f847': "\u5599",
+	'\U0002f848': "\u55ab",
+	'\U0002f849': "\u55b3",
+	'\U0002f84a': "\u55c2",
+	'\U0002f84b': "\u5716",
+	'\U0002f84c': "\u5606",
+	'\U0002f84d': "\u5717",
+	'\U0002f84e': "\u5651",
+	'\U0002f84f': "\u5674",
+	'\U0002f850': "\u5207",
+	'\U0002f851': "\u58ee",
+	'\U0002f852': "\u57ce",
+	'\U0002f853': "\u57f4",
+	'\U0002f854': "\u580d",
+	'\U0002f855': "\u578b",
+	'\U0002f856': "\u5832",
+	'\U0002f857': "\u5831",
+	'\U0002f858': "\u58ac",
+	'\U0002f859': "\U000214e4",
+	'\U0002f85a': "\u58f2",
+	'\U0002f85b': "\u58f7",
+	'\U0002f85c': "\u5906",
+	'\U0002f85d': "\u591a",
+	'\U0002f85e': "\u5922",
+	'\U0002f85f': "\u5962",
+	'\U0002f860': "\U000216a8",
+	'\U0002f861': "\U000216ea",
+	'\U0002f862': "\u59ec",
+	'\U0002f863': "\u5a1b",
+	'\U0002f864': "\u5a27",
+	'\U0002f865': "\u59d8",
+	'\U0002f866': "\u5a66",
+	'\U0002f867': "\u36ee",
+	'\U0002f868': "\u36fc",
+	'\U0002f869': "\u5b08",
+	'\U0002f86a': "\u5b3e",
+	'\U0002f86b': "\u5b3e",
+	'\U0002f86c': "\U000219c8",
+	'\U0002f86d': "\u5bc3",
+	'\U0002f86e': "\u5bd8",
+	'\U0002f86f': "\u5be7",
+	'\U0002f870': "\u5bf3",
+	'\U0002f871': "\U00021b18",
+	'\U0002f872': "\u5bff",
+	'\U0002f873': "\u5c06",
+	'\U0002f874': "\u5f53",
+	'\U0002f875': "\u5c22",
+	'\U0002f876': "\u3781",
+	'\U0002f877': "\u5c60",
+	'\U0002f878': "\u5c6e",
+	'\U0002f879': "\u5cc0",
+	'\U0002f87a': "\u5c8d",
+	'\U0002f87b': "\U00021de4",
+	'\U0002f87c': "\u5d43",
+	'\U0002f87d': "\U00021de6",
+	'\U0002f87e': "\u5d6e",
+	'\U0002f87f': "\u5d6b",
+	'\U0002f880': "\u5d7c",
+	'\U0002f881': "\u5de1",
+	'\U0002f882': "\u5de2",
+	'\U0002f883': "\u382f",
+	'\U0002f884': "\u5dfd",
+	'\U0002f885': "\u5e28",
+	'\U0002f886': "\u5e3d",
+	'\U0002f887': "\u5e69",
+	'\U0002f888': "\u3862",
+	'\U0002f889': "\U00022183",
+	'\U0002f88a': "\u387c",
+	'\U0002f88b': "\u5eb0",
+	'\U0002f88c': "\u5eb3",
+	'\U0002f88d': "\u5eb6",
+	'\U0002f88e': "\u5eca",
+	'\U0002f88f': "\U0002a392",
+	'\U0002f890': "\u5efe",
+	'\U0002f891': "\U00022331",
+	'\U0002f892': "\U00022331",
+	'\U0002f893': "\u8201",
+	'\U0002f894': "\u5f22",
+	'\U0002f895': "\u5f22",
+	'\U0002f896': "\u38c7",
+	'\U0002f897': "\U000232b8",
+	'\U0002f898': "\U000261da",
+	'\U0002f899': "\u5f62",
+	'\U0002f89a': "\u5f6b",
+	'\U0002f89b': "\u38e3",
+	'\U0002f89c': "\u5f9a",
+	'\U0002f89d': "\u5fcd",
+	'\U0002f89e': "\u5fd7",
+	'\U0002f89f': "\u5ff9",
+	'\U0002f8a0': "\u6081",
+	'\U0002f8a1': "\u393a",
+	'\U0002f8a2': "\u391c",
+	'\U0002f8a3': "\u6094",
+	'\U0002f8a4': "\U000226d4",
+	'\U0002f8a5': "\u60c7",
+	'\U0002f8a6': "\u6148",
+	'\U0002f8a7': "\u614c",
+	'\U0002f8a8': "\u614e",
+	'\U0002f8a9': "\u614c",
+	'\U0002f8aa': "\u617a",
+	'\U0002f8ab': "\u618e",
+	'\U0002f8ac': "\u61b2",
+	'\U0002f8ad': "\u61a4",
+	'\U0002f8ae': "\u61af",
+	'\U0002f8af': "\u61de",
+	'\U0002f8b0': "\u61f2",
+	'\U0002f8b1': "\u61f6",
+	'\U0002f8b2': "\u6210",
+	'\U0002f8b3': "\u621b",
+	'\U0002f8b4': "\u625d",
+	'\U0002f8b5': "\u62b1",
+	'\U0002f8b6': "\u62d4",
+	'\U0002f8b7': "\u6350",
+	'\U0002f8b8': "\U00022b0c",
+	'\U0002f8b9': "\u633d",
+	'\U0002f8ba': "\u62fc",
+	'\U0002f8bb': "\u6368",
+	'\U0002f8bc': "\u6383",
+	'\U0002f8bd': "\u63e4",
+	'\U0002f8be': "\U00022bf1",
+	'\U0002f8bf': "\u6422",
+	'\U0002f8c0': "\u63c5",
+	'\U0002f8c1': "\u63a9",
+	'\U0002f8c2': "\u3a2e",
+	'\U0002f8c3': "\u6469",
+	'\U0002f8c4': "\u647e",
+	'\U0002f8c5': "\u649d",
+	'\U0002f8c6': "\u6477",
+	'\U0002f8c7': "\u3a6c",
+	'\U0002f8c8': "\u654f",
+	'\U0002f8c9': "\u656c",
+	'\U0002f8ca': "\U0002300a",
+	'\U0002f8cb': "\u65e3",
+	'\U0002f8cc': "\u66f8",
+	'\U0002f8cd': "\u6649",
+	'\U0002f8ce': "\u3b19",
+	'\U0002f8cf': "\u6691",
+	'\U0002f8d0': "\u3b08",
+	'\U0002f8d1': "\u3ae4",
+	'\U0002f8d2': "\u5192",
+	'\U0002f8d3': "\u5195",
+	'\U0002f8d4': "\u6700",
+	'\U0002f8d5': "\u669c",
+	'\U0002f8d6': "\u80ad",
+	'\U0002f8d7': "\u43d9",
+	'\U0002f8d8': "\u6717",
+	'\U0002f8d9': "\u671b",
+	'\U0002f8da': "\u6721",
+	'\U0002f8db': "\u675e",
+	'\U0002f8dc': "\u6753",
+	'\U0002f8dd': "\U000233c3",
+	'\U0002f8de': "\u3b49",
+	'\U0002f8df': "\u67fa",
+	'\U0002f8e0': "\u6785",
+	'\U0002f8e1': "\u6852",
+	'\U0002f8e2': "\u6885",
+	'\U0002f8e3': "\U0002346d",
+	'\U0002f8e4': "\u688e",
+	'\U0002f8e5': "\u681f",
+	'\U0002f8e6': "\u6914",
+	'\U0002f8e7': "\u3b9d",
+	'\U0002f8e8': "\u6942",
+	'\U0002f8e9': "\u69a3",
+	'\U0002f8ea': "\u69ea",
+	'\U0002f8eb': "\u6aa8",
+	'\U0002f8ec': "\U000236a3",
+	'\U0002f8ed': "\u6adb",
+	'\U0002f8ee': "\u3c18",
+	'\U0002f8ef': "\u6b21",
+	'\U0002f8f0': "\U000238a7",
+	'\U0002f8f1': "\u6b54",
+	'\U0002f8f2': "\u3c4e",
+	'\U0002f8f3': "\u6b72",
+	'\U0002f8f4': "\u6b9f",
+	'\U0002f8f5': "\u6bba",
+	'\U0002f8f6': "\u6bbb",
+	'\U0002f8f7': "\U00023a8d",
+	'\U0002f8f8': "\U00021d0b",
+	'\U0002f8f9': "\U00023afa",
+	'\U0002f8fa': "\u6c4e",
+	'\U0002f8fb': "\U00023cbc",
+	'\U0002f8fc': "\u6cbf",
+	'\U0002f8fd': "\u6ccd",
+	'\U0002f8fe': "\u6c67",
+	'\U0002f8ff': "\u6d16",
+	'\U0002f900': "\u6d3e",
+	'\U0002f901': "\u6d77",
+	'\U0002f902': "\u6d41",
+	'\U0002f903': "\u6d69",
+	'\U0002f904': "\u6d78",
+	'\U0002f905': "\u6d85",
+	'\U0002f906': "\U00023d1e",
+	'\U0002f907': "\u6d34",
+	'\U0002f908': "\u6e2f",
+	'\U0002f909': "\u6e6e",
+	'\U0002f90a': "\u3d33",
+	'\U0002f90b': "\u6ecb",
+	'\U0002f90c': "\u6ec7",
+	'\U0002f90d': "\U00023ed1",
+	'\U0002f90e': "\u6df9",
+	'\U0002f90f': "\u6f6e",
+	'\U0002f910': "\U00023f5e",
+	'\U0002f911': "\U00023f8e",
+	'\U0002f912': "\u6fc6",
+	'\U0002f913': "\u7039",
+	'\U0002f914': "\u701e",
+	'\U0002f915': "\u701b",
+	'\U0002f916': "\u3d96",
+	'\U0002f917': "\u704a",
+	'\U0002f918': "\u707d",
+	'\U0002f919': "\u7077",
+	'\U0002f91a': "\u70ad",
+	'\U0002f91b': "\U00020525",
+	'\U0002f91c': "\u7145",
+	'\U0002f91d': "\U00024263",
+	'\U0002f91e': "\u719c",
+	'\U0002f91f': "\U000243ab",
+	'\U0002f920': "\u7228",
+	'\U0002f921': "\u7235",
+	'\U0002f922': "\u7250",
+	'\U0002f923': "\U00024608",
+	'\U0002f924': "\u7280",
+	'\U0002f925': "\u7295",
+	'\U0002f926': "\U00024735",
+	'\U0002f927': "\U00024814",
+	'\U0002f928': "\u737a",
+	'\U0002f929': "\u738b",
+	'\U0002f92a': "\u3eac",
+	'\U0002f92b': "\u73a5",
+	'\U0002f92c': "\u3eb8",
+	'\U0002f92d': "\u3eb8",
+	'\U0002f92e': "\u7447",
+	'\U0002f92f': "\u745c",
+	'\U0002f930': "\u7471",
+	'\U0002f931': "\u7485",
+	'\U0002f932': "\u74ca",
+	'\U0002f933': "\u3f1b",
+	'\U0002f934': "\u7524",
+	'\U0002f935': "\U00024c36",
+	'\U0002f936': "\u753e",
+	'\U0002f937': "\U00024c92",
+	'\U0002f938': "\u7570",
+	'\U0002f939': "\U0002219f",
+	'\U0002f93a': "\u7610",
+	'\U0002f93b': "\U00024fa1",
+	'\U0002f93c': "\U00024fb8",
+	'\U0002f93d': "\U00025044",
+	'\U0002f93e': "\u3ffc",
+	'\U0002f93f': "\u4008",
+	'\U0002f940': "\u76f4",
+	'\U0002f941': "\U000250f3",
+	'\U0002f942': "\U000250f2",
+	'\U0002f943': "\U00025119",
+	'\U0002f944': "\U00025133",
+	'\U0002f945': "\u771e",
+	'\U0002f946': "\u771f",
+	'\U0002f947': "\u771f",
+	'\U0002f948': "\u774a",
+	'\U0002f949': "\u4039",
+	'\U0002f94a': "\u778b",
+	'\U0002f94b': "\u4046",
+	'\U0002f94c': "\u4096",
+	'\U0002f94d': "\U0002541d",
+	'\U0002f94e': "\u784e",
+	'\U0002f94f': "\u788c",
+	'\U0002f950': "\u78cc",
+	'\U0002f951': "\u40e3",
+	'\U0002f952': "\U00025626",
+	'\U0002f953': "\u7956",
+	'\U0002f954': "\U0002569a",
+	'\U0002f955': "\U000256c5",
+	'\U0002f956': "\u798f",
+	'\U0002f957': "\u79eb",
+	'\U0002f958': "\u412f",
+	'\U0002f959': "\u7a40",
+	'\U0002f95a': "\u7a4a",
+	'\U0002f95b': "\u7a4f",
+	'\U0002f95c': "\U0002597c",
+	'\U0002f95d': "\U00025aa7",
+	'\U0002f95e': "\U00025aa7",
+	'\U0002f95f': "\u7aee",
+	'\U0002f960': "\u4202",
+	'\U0002f961': "\U00025bab",
+	'\U0002f962': "\u7bc6",
+	'\U0002f963': "\u7bc9",
+	'\U0002f964': "\u4227",
+	'\U0002f965': "\U00025c80",
+	'\U0002f966': "\u7cd2",
+	'\U0002f967': "\u42a0",
+	'\U0002f968': "\u7ce8",
+	'\U0002f969': "\u7ce3",
+	'\U0002f96a': "\u7d00",
+	'\U0002f96b': "\U00025f86",
+	'\U0002f96c': "\u7d63",
+	'\U0002f96d': "\u4301",
+	'\U0002f96e': "\u7dc7",
+	'\U0002f96f': "\u7e02",
+	'\U0002f970': "\u7e45",
+	'\U0002f971': "\u4334",
+	'\U0002f972': "\U00026228",
+	'\U0002f973': "\U00026247",
+	'\U0002f974': "\u4359",
+	'\U0002f975': "\U000262d9",
+	'\U0002f976': "\u7f7a",
+	'\U0002f977': "\U0002633e",
+	'\U0002f978': "\u7f95",
+	'\U0002f979': "\u7ffa",
+	'\U0002f97a': "\u8005",
+	'\U0002f97b': "\U000264da",
+	'\U0002f97c': "\U00026523",
+	'\U0002f97d': "\u8060",
+	'\U0002f97e': "\U000265a8",
+	'\U0002f97f': "\u8070",
+	'\U0002f980': "\U0002335f",
+	'\U0002f981': "\u43d5",
+	'\U0002f982': "\u80b2",
+	'\U0002f983': "\u8103",
+	'\U0002f984': "\u440b",
+	'\U0002f985': "\u813e",
+	'\U0002f986': "\u5ab5",
+	'\U0002f987': "\U000267a7",
+	'\U0002f988': "\U000267b5",
+	'\U0002f989': "\U00023393",
+	'\U0002f98a': "\U0002339c",
+	'\U0002f98b': "\u8201",
+	'\U0002f98c': "\u8204",
+	'\U0002f98d': "\u8f9e",
+	'\U0002f98e': "\u446b",
+	'\U0002f98f': "\u8291",
+	'\U0002f990': "\u828b",
+	'\U0002f991': "\u829d",
+	'\U0002f992': "\u52b3",
+	'\U0002f993': "\u82b1",
+	'\U0002f994': "\u82b3",
+	'\U0002f995': "\u82bd",
+	'\U0002f996': "\u82e6",
+	'\U0002f997': "\U00026b3c",
+	'\U0002f998': "\u82e5",
+	'\U0002f999': "\u831d",
+	'\U0002f99a': "\u8363",
+	'\U0002f99b': "\u83ad",
+	'\U0002f99c': "\u8323",
+	'\U0002f99d': "\u83bd",
+	'\U0002f99e': "\u83e7",
+	'\U0002f99f': "\u8457",
+	'\U0002f9a0': "\u8353",
+	'\U0002f9a1': "\u83ca",
+	'\U0002f9a2': "\u83cc",
+	'\U0002f9a3': "\u83dc",
+	'\U0002f9a4': "\U00026c36",
+	'\U0002f9a5': "\U00026d6b",
+	'\U0002f9a6': "\U00026cd5",
+	'\U0002f9a7': "\u452b",
+	'\U0002f9a8': "\u84f1",
+	'\U0002f9a9': "\u84f3",
+	'\U0002f9aa': "\u8516",
+	'\U0002f9ab': "\U000273ca",
+	'\U0002f9ac': "\u8564",
+	'\U0002f9ad': "\U00026f2c",
+	'\U0002f9ae': "\u455d",
+	'\U0002f9af': "\u4561",
+	'\U0002f9b0': "\U00026fb1",
+	'\U0002f9b1': "\U000270d2",
+	'\U0002f9b2': "\u456b",
+	'\U0002f9b3': "\u8650",
+	'\U0002f9b4': "\u865c",
+	'\U0002f9b5': "\u8667",
+	'\U0002f9b6': "\u8669",
+	'\U0002f9b7': "\u86a9",
+	'\U0002f9b8': "\u8688",
+	'\U0002f9b9': "\u870e",
+	'\U0002f9ba': "\u86e2",
+	'\U0002f9bb': "\u8779",
+	'\U0002f9bc': "\u8728",
+	'\U0002f9bd': "\u876b",
+	'\U0002f9be': "\u8786",
+	'\U0002f9bf': "\u45d7",
+	'\U0002f9c0': "\u87e1",
+	'\U0002f9c1': "\u8801",
+	'\U0002f9c2': "\u45f9",
+	'\U0002f9c3': "\u8860",
+	'\U0002f9c4': "\u8863",
+	'\U0002f9c5': "\U00027667",
+	'\U0002f9c6': "\u88d7",
+	'\U0002f9c7': "\u88de",
+	'\U0002f9c8': "\u4635",
+	'\U0002f9c9': "\u88fa",
+	'\U0002f9ca': "\u34bb",
+	'\U0002f9cb': "\U000278ae",
+	'\U0002f9cc': "\U00027966",
+	'\U0002f9cd': "\u46be",
+	'\U0002f9ce': "\u46c7",
+	'\U0002f9cf': "\u8aa0",
+	'\U0002f9d0': "\u8aed",
+	'\U0002f9d1': "\u8b8a",
+	'\U0002f9d2': "\u8c55",
+	'\U0002f9d3': "\U00027ca8",
+	'\U0002f9d4': "\u8cab",
+	'\U0002f9d5': "\u8cc1",
+	'\U0002f9d6': "\u8d1b",
+	'\U0002f9d7': "\u8d77",
+	'\U0002f9d8': "\U00027f2f",
+	'\U0002f9d9': "\U00020804",
+	'\U0002f9da': "\u8dcb",
+	'\U0002f9db': "\u8dbc",
+	'\U0002f9dc': "\u8df0",
+	'\U0002f9dd': "\U000208de",
+	'\U0002f9de': "\u8ed4",
+	'\U0002f9df': "\u8f38",
+	'\U0002f9e0': "\U000285d2",
+	'\U0002f9e1': "\U000285ed",
+	'\U0002f9e2': "\u9094",
+	'\U0002f9e3': "\u90f1",
+	'\U0002f9e4': "\u9111",
+	'\U0002f9e5': "\U0002872e",
+	'\U0002f9e6': "\u911b",
+	'\U0002f9e7': "\u9238",
+	'\U0002f9e8': "\u92d7",
+	'\U0002f9e9': "\u92d8",
+	'\U0002f9ea': "\u927c",
+	'\U0002f9eb': "\u93f9",
+	'\U0002f9ec': "\u9415",
+	'\U0002f9ed': "\U00028bfa",
+	'\U0002f9ee': "\u958b",
+	'\U0002f9ef': "\u4995",
+	'\U0002f9f0': "\u95b7",
+	'\U0002f9f1': "\U00028d77",
+	'\U0002f9f2': "\u49e6",
+	'\U0002f9f3': "\u96c3",
+	'\U0002f9f4': "\u5db2",
+	'\U0002f9f5': "\u9723",
+	'\U0002f9f6': "\U00029145",
+	'\U0002f9f7': "\U0002921a",
+	'\U0002f9f8': "\u4a6e",
+	'\U0002f9f9': "\u4a76",
+	'\U0002f9fa': "\u97e0",
+	'\U0002f9fb': "\U0002940a",
+	'\U0002f9fc': "\u4ab2",
+	'\U0002f9fd': "\U00029496",
+	'\U0002f9fe': "\u980b",
+	'\U0002f9ff': "\u980b",
+	'\U0002fa00': "\u9829",
+	'\U0002fa01': "\U000295b6",
+	'\U0002fa02': "\u98e2",
+	'\U0002fa03': "\u4b33",
+	'\U0002fa04': "\u9929",
+	'\U0002fa05': "\u99a7",
+	'\U0002fa06': "\u99c2",
+	'\U0002fa07': "\u99fe",
+	'\U0002fa08': "\u4bce",
+	'\U0002fa09': "\U00029b30",
+	'\U0002fa0a': "\u9b12",
+	'\U0002fa0b': "\u9c40",
+	'\U0002fa0c': "\u9cfd",
+	'\U0002fa0d': "\u4cce",
+	'\U0002fa0e': "\u4ced",
+	'\U0002fa0f': "\u9d67",
+	'\U0002fa10': "\U0002a0ce",
+	'\U0002fa11': "\u4cf8",
+	'\U0002fa12': "\U0002a105",
+	'\U0002fa13': "\U0002a20e",
+	'\U0002fa14': "\U0002a291",
+	'\U0002fa15': "\u9ebb",
+	'\U0002fa16': "\u4d56",
+	'\U0002fa17': "\u9ef9",
+	'\U0002fa18': "\u9efe",
+	'\U0002fa19': "\u9f05",
+	'\U0002fa1a': "\u9f0f",
+	'\U0002fa1b': "\u9f16",
+	'\U0002fa1c': "\u9f3b",
+	'\U0002fa1d': "\U0002a600",
+}
+
+// compatibility maps runes to their compatibility decomposition, one level
+// deep.
+var compatibility = map[rune]string{
+	'\u00a0':     " ",
+	'\u00a8':     " \u0308",
+	'\u00aa':     "a",
+	'\u00af':     " \u0304",
+	'\u00b2':     "2",
+	'\u00b3':     "3",
+	'\u00b4':     " \u0301",
+	'\u00b5':     "\u03bc",
+	'\u00b8':     " \u0327",
+	'\u00b9':     "1",
+	'\u00ba':     "o",
+	'\u00bc':     "1\u20444",
+	'\u00bd':     "1\u20442",
+	'\u00be':     "3\u20444",
+	'\u0132':     "IJ",
+	'\u0133':     "ij",
+	'\u013f':     "L\u00b7",
+	'\u0140':     "l\u00b7",
+	'\u0149':     "\u02bcn",
+	'\u017f':     "s",
+	'\u01c4':     "D\u017d",
+	'\u01c5':     "D\u017e",
+	'\u01c6':     "d\u017e",
+	'\u01c7':     "LJ",
+	'\u01c8':     "Lj",
+	'\u01c9':     "lj",
+	'\u01ca':     "NJ",
+	'\u01cb':     "Nj",
+	'\u01cc':     "nj",
+	'\u01f1':     "DZ",
+	'\u01f2':     "Dz",
+	'\u01f3':     "dz",
+	'\u02b0':     "h",
+	'\u02b1':     "\u0266",
+	'\u02b2':     "j",
+	'\u02b3':     "r",
+	'\u02b4':     "\u0279",
+	'\u02b5':     "\u027b",
+	'\u02b6':     "\u0281",
+	'\u02b7':     "w",
+	'\u02b8':     "y",
+	'\u02d8':     " \u0306",
+	'\u02d9':     " \u0307",
+	'\u02da':     " \u030a",
+	'\u02db':     " \u0328",
+	'\u02dc':     " \u0303",
+	'\u02dd':     " \u030b",
+	'\u02e0':     "\u0263",
+	'\u02e1':     "l",
+	'\u02e2':     "s",
+	'\u02e3':     "x",
+	'\u02e4':     "\u0295",
+	'\u037a':     " \u0345",
+	'\u0384':     " \u0301",
+	'\u03d0':     "\u03b2",
+	'\u03d1':     "\u03b8",
+	'\u03d2':     "\u03a5",
+	'\u03d5':     "\u03c6",
+	'\u03d6':     "\u03c0",
+	'\u03f0':     "\u03ba",
+	'\u03f1':     "\u03c1",
+	'\u03f2':     "\u03c2",
+	'\u03f4':     "\u0398",
+	'\u03f5':     "\u03b5",
+	'\u03f9':     "\u03a3",
+	'\u0587':     "\u0565\u0582",
+	'\u0675':     "\u0627\u0674",
+	'\u0676':     "\u0648\u0674",
+	'\u0677':     "\u06c7\u0674",
+	'\u0678':     "\u064a\u0674",
+	'\u0e33':     "\u0e4d\u0e32",
+	'\u0eb3':     "\u0ecd\u0eb2",
+	'\u0edc':     "\u0eab\u0e99",
+	'\u0edd':     "\u0eab\u0ea1",
+	'\u0f0c':     "\u0f0b",
+	'\u0f77':     "\u0fb2\u0f81",
+	'\u0f79':     "\u0fb3\u0f81",
+	'\u10fc':     "\u10dc",
+	'\u1d2c':     "A",
+	'\u1d2d':     "\u00c6",
+	'\u1d2e':     "B",
+	'\u1d30':     "D",
+	'\u1d31':     "E",
+	'\u1d32':     "\u018e",
+	'\u1d33':     "G",
+	'\u1d34':     "H",
+	'\u1d35':     "I",
+	'\u1d36':     "J",
+	'\u1d37':     "K",
+	'\u1d38':     "L",
+	'\u1d39':     "M",
+	'\u1d3a':     "N",
+	'\u1d3c':     "O",
+	'\u1d3d':     "\u0222",
+	'\u1d3e':     "P",
+	'\u1d3f':     "R",
+	'\u1d40':     "T",
+	'\u1d41':     "U",
+	'\u1d42':     "W",
+	'\u1d43':     "a",
+	'\u1d44':     "\u0250",
+	'\u1d45':     "\u0251",
+	'\u1d46':     "\u1d02",
+	'\u1d47':     "b",
+	'\u1d48':     "d",
+	'\u1d49':     "e",
+	'\u1d4a':     "\u0259",
+	'\u1d4b':     "\u025b",
+	'\u1d4c':     "\u025c",
+	'\u1d4d':     "g",
+	'\u1d4f':     "k",
+	'\u1d50':     "m",
+	'\u1d51':     "\u014b",
+	'\u1d52':     "o",
+	'\u1d53':     "\u0254",
+	'\u1d54':     "\u1d16",
+	'\u1d55':     "\u1d17",
+	'\u1d56':     "p",
+	'\u1d57':     "t",
+	'\u1d58':     "u",
+	'\u1d59':     "\u1d1d",
+	'\u1d5a':     "\u026f",
+	'\u1d5b':     "v",
+	'\u1d5c':     "\u1d25",
+	'\u1d5d':     "\u03b2",
+	'\u1d5e':     "\u03b3",
+	'\u1d5f':     "\u03b4",
+	'\u1d60':     "\u03c6",
+	'\u1d61':     "\u03c7",
+	'\u1d62':     "i",
+	'\u1d63':     "r",
+	'\u1d64':     "u",
+	'\u1d65':     "v",
+	'\u1d66':     "\u03b2",
+	'\u1d67':     "\u03b3",
+	'\u1d68':     "\u03c1",
+	'\u1d69':     "\u03c6",
+	'\u1d6a':     "\u03c7",
+	'\u1d78':     "\u043d",
+	'\u1d9b':     "\u0252",
+	'\u1d9c':     "c",
+	'\u1d9d':     "\u0255",
+	'\u1d9e':     "\u00f0",
+	'\u1d9f':     "\u025c",
+	'\u1da0':     "f",
+	'\u1da1':     "\u025f",
+	'\u1da2':     "\u0261",
+	'\u1da3':     "\u0265",
+	'\u1da4':     "\u0268",
+	'\u1da5':     "\u0269",
+	'\u1da6':     "\u026a",
+	'\u1da7':     "\u1d7b",
+	'\u1da8':     "\u029d",
+	'\u1da9':     "\u026d",
+	'\u1daa':     "\u1d85",
+	'\u1dab':     "\u029f",
+	'\u1dac':     "\u0271",
+	'\u1dad':     "\u0270",
+	'\u1dae':     "\u0272",
+	'\u1daf':     "\u0273",
+	'\u1db0':     "\u0274",
+	'\u1db1':     "\u0275",
+	'\u1db2':     "\u0278",
+	'\u1db3':     "\u0282",
+	'\u1db4':     "\u0283",
+	'\u1db5':     "\u01ab",
+	'\u1db6':     "\u0289",
+	'\u1db7':     "\u028a",
+	'\u1db8':     "\u1d1c",
+	'\u1db9':     "\u028b",
+	'\u1dba':     "\u028c",
+	'\u1dbb':     "z",
+	'\u1dbc':     "\u0290",
+	'\u1dbd':     "\u0291",
+	'\u1dbe':     "\u0292",
+	'\u1dbf':     "\u03b8",
+	'\u1e9a':     "a\u02be",
+	'\u1fbd':     " \u0313",
+	'\u1fbf':     " \u0313",
+	'\u1fc0':     " \u0342",
+	'\u1ffe':     " \u0314",
+	'\u2002':     " ",
+	'\u2003':     " ",
+	'\u2004':     " ",
+	'\u2005':     " ",
+	'\u2006':     " ",
+	'\u2007':     " ",
+	'\u2008':     " ",
+	'\u2009':     " ",
+	'\u200a':     " ",
+	'\u2011':     "\u2010",
+	'\u2017':     " \u0333",
+	'\u2024':     ".",
+	'\u2025':     "..",
+	'\u2026':     "...",
+	'\u202f':     " ",
+	'\u2033':     "\u2032\u2032",
+	'\u2034':     "\u2032\u2032\u2032",
+	'\u2036':     "\u2035\u2035",
+	'\u2037':     "\u2035\u2035\u2035",
+	'\u203c':     "!!",
+	'\u203e':     " \u0305",
+	'\u2047':     "??",
+	'\u2048':     "?!",
+	'\u2049':     "!?",
+	'\u2057':     "\u2032\u2032\u2032\u2032",
+	'\u205f':     " ",
+	'\u2070':     "0",
+	'\u2071':     "i",
+	'\u2074':     "4",
+	'\u2075':     "5",
+	'\u2076':     "6",
+	'\u2077':     "7",
+	'\u2078':     "8",
+	'\u2079':     "9",
+	'\u207a':     "+",
+	'\u207b':     "\u2212",
+	'\u207c':     "=",
+	'\u207d':     "(",
+	'\u207e':     ")",
+	'\u207f':     "n",
+	'\u2080':     "0",
+	'\u2081':     "1",
+	'\u2082':     "2",
+	'\u2083':     "3",
+	'\u2084':     "4",
+	'\u2085':     "5",
+	'\u2086':     "6",
+	'\u2087':     "7",
+	'\u2088':     "8",
+	'\u2089':     "9",
+	'\u208a':     "+",
+	'\u208b':     "\u2212",
+	'\u208c':     "=",
+	'\u208d':     "(",
+	'\u208e':     ")",
+	'\u2090':     "a",
+	'\u2091':     "e",
+	'\u2092':     "o",
+	'\u2093':     "x",
+	'\u2094':     "\u0259",
+	'\u2095':     "h",
+	'\u2096':     "k",
+	'\u2097':     "l",
+	'\u2098':     "m",
+	'\u2099':     "n",
+	'\u209a':     "p",
+	'\u209b':     "s",
+	'\u209c':     "t",
+	'\u20a8':     "Rs",
+	'\u2100':     "a/c",
+	'\u2101':     "a/s",
+	'\u2102':     "C",
+	'\u2103':     "\u00b0C",
+	'\u2105':     "c/o",
+	'\u2106':     "c/u",
+	'\u2107':     "\u0190",
+	'\u2109':     "\u00b0F",
+	'\u210a':     "g",
+	'\u210b':     "H",
+	'\u210c':     "H",
+	'\u210d':     "H",
+	'\u210e':     "h",
+	'\u210f':     "\u0127",
+	'\u2110':     "I",
+	'\u2111':     "I",
+	'\u2112':     "L",
+	'\u2113':     "l",
+	'\u2115':     "N",
+	'\u2116':     "No",
+	'\u2119':     "P",
+	'\u211a':     "Q",
+	'\u211b':     "R",
+	'\u211c':     "R",
+	'\u211d':     "R",
+	'\u2120':     "SM",
+	'\u2121':     "TEL",
+	'\u2122':     "TM",
+	'\u2124':     "Z",
+	'\u2128':     "Z",
+	'\u212c':     "B",
+	'\u212d':     "C",
+	'\u212f':     "e",
+	'\u2130':     "E",
+	'\u2131':     "F",
+	'\u2133':     "M",
+	'\u2134':     "o",
+	'\u2135':     "\u05d0",
+	'\u2136':     "\u05d1",
+	'\u2137':     "\u05d2",
+	'\u2138':     "\u05d3",
+	'\u2139':     "i",
+	'\u213b':     "FAX",
+	'\u213c':     "\u03c0",
+	'\u213d':     "\u03b3",
+	'\u213e':     "\u0393",
+	'\u213f':     "\u03a0",
+	'\u2140':     "\u2211",
+	'\u2145':     "D",
+	'\u2146':     "d",
+	'\u2147':     "e",
+	'\u2148':     "i",
+	'\u2149':     "j",
+	'\u2150':     "1\u20447",
+	'\u2151':     "1\u20449",
+	'\u2152':     "1\u204410",
+	'\u2153':     "1\u20443",
+	'\u2154':     "2\u20443",
+	'\u2155':     "1\u20445",
+	'\u2156':     "2\u20445",
+	'\u2157':     "3\u20445",
+	'\u2158':     "4\u20445",
+	'\u2159':     "1\u20446",
+	'\u215a':     "5\u20446",
+	'\u215b':     "1\u20448",
+	'\u215c':     "3\u20448",
+	'\u215d':     "5\u20448",
+	'\u215e':     "7\u20448",
+	'\u215f':     "1\u2044",
+	'\u2160':     "I",
+	'\u2161':     "II",
+	'\u2162':     "III",
+	'\u2163':     "IV",
+	'\u2164':     "V",
+	'\u2165':     "VI",
+	'\u2166':     "VII",
+	'\u2167':     "VIII",
+	'\u2168':     "IX",
+	'\u2169':     "X",
+	'\u216a':     "XI",
+	'\u216b':     "XII",
+	'\u216c':     "L",
+	'\u216d':     "C",
+	'\u216e':     "D",
+	'\u216f':     "M",
+	'\u2170':     "i",
+	'\u2171':     "ii",
+	'\u2172':     "iii",
+	'\u2173':     "iv",
+	'\u2174':     "v",
+	'\u2175':     "vi",
+	'\u2176':     "vii",
+	'\u2177':     "viii",
+	'\u2178':     "ix",
+	'\u2179':     "x",
+	'\u217a':     "xi",
+	'\u217b':     "xii",
+	'\u217c':     "l",
+	'\u217d':     "c",
+	'\u217e':     "d",
+	'\u217f':     "m",
+	'\u2189':     "0\u20443",
+	'\u222c':     "\u222b\u222b",
+	'\u222d':     "\u222b\u222b\u222b",
+	'\u222f':     "\u222e\u222e",
+	'\u2230':     "\u222e\u222e\u222e",
+	'\u2460':     "1",
+	'\u2461':     "2",
+	'\u2462':     "3",
+	'\u2463':     "4",
+	'\u2464':     "5",
+	'\u2465':     "6",
+	'\u2466':     "7",
+	'\u2467':     "8",
+	'\u2468':     "9",
+	'\u2469':     "10",
+	'\u246a':     "11",
+	'\u246b':     "12",
+	'\u246c':     "13",
+	'\u246d':     "14",
+	'\u246e':     "15",
+	'\u246f':     "16",
+	'\u2470':     "17",
+	'\u2471':     "18",
+	'\u2472':     "19",
+	'\u2473':     "20",
+	'\u2474':     "(1)",
+	'\u2475':     "(2)",
+	'\u2476':     "(3)",
+	'\u2477':     "(4)",
+	'\u2478':     "(5)",
+	'\u2479':     "(6)",
+	'\u247a':     "(7)",
+	'\u247b':     "(8)",
+	'\u247c':     "(9)",
+	'\u247d':     "(10)",
+	'\u247e':     "(11)",
+	'\u247f':     "(12)",
+	'\u2480':     "(13)",
+	'\u2481':     "(14)",
+	'\u2482':     "(15)",
+	'\u2483':     "(16)",
+	'\u2484':     "(17)",
+	'\u2485':     "(18)",
+	'\u2486':     "(19)",
+	'\u2487':     "(20)",
+	'\u2488':     "1.",
+	'\u2489':     "2.",
+	'\u248a':     "3.",
+	'\u248b':     "4.",
+	'\u248c':     "5.",
+	'\u248d':     "6.",
+	'\u248e':     "7.",
+	'\u248f':     "8.",
+	'\u2490':     "9.",
+	'\u2491':     "10.",
+	'\u2492':     "11.",
+	'\u2493':     "12.",
+	'\u2494':     "13.",
+	'\u2495':     "14.",
+	'\u2496':     "15.",
+	'\u2497':     "16.",
+	'\u2498':     "17.",
+	'\u2499':     "18.",
+	'\u249a':     "19.",
+	'\u249b':     "20.",
+	'\u249c':     "(a)",
+	'\u249d':     "(b)",
+	'\u249e':     "(c)",
+	'\u249f':     "(d)",
+	'\u24a0':     "(e)",
+	'\u24a1':     "(f)",
+	'\u24a2':     "(g)",
+	'\u24a3':     "(h)",
+	'\u24a4':     "(i)",
+	'\u24a5':     "(j)",
+	'\u24a6':     "(k)",
+	'\u24a7':     "(l)",
+	'\u24a8':     "(m)",
+	'\u24a9':     "(n)",
+	'\u24aa':     "(o)",
+	'\u24ab':     "(p)",
+	'\u24ac':     "(q)",
+	'\u24ad':     "(r)",
+	'\u24ae':     "(s)",
+	'\u24af':     "(t)",
+	'\u24b0':     "(u)",
+	'\u24b1':     "(v)",
+	'\u24b2':     "(w)",
+	'\u24b3':     "(x)",
+	'\u24b4':     "(y)",
+	'\u24b5':     "(z)",
+	'\u24b6':     "A",
+	'\u24b7':     "B",
+	'\u24b8':     "C",
+	'\u24b9':     "D",
+	'\u24ba':     "E",
+	'\u24bb':     "F",
+	'\u24bc':     "G",
+	'\u24bd':     "H",
+	'\u24be':     "I",
+	'\u24bf':     "J",
+	'\u24c0':     "K",
+	'\u24c1':     "L",
+	'\u24c2':     "M",
+	'\u24c3':     "N",
+	'\u24c4':     "O",
+	'\u24c5':     "P",
+	'\u24c6':     "Q",
+	'\u24c7':     "R",
+	'\u24c8':     "S",
+	'\u24c9':     "T",
+	'\u24ca':     "U",
+	'\u24cb':     "V",
+	'\u24cc':     "W",
+	'\u24cd':     "X",
+	'\u24ce':     "Y",
+	'\u24cf':     "Z",
+	'\u24d0':     "a",
+	'\u24d1':     "b",
+	'\u24d2':     "c",
+	'\u24d3':     "d",
+	'\u24d4':     "e",
+	'\u24d5':     "f",
+	'\u24d6':     "g",
+	'\u24d7':     "h",
+	'\u24d8':     "i",
+	'\u24d9':     "j",
+	'\u24da':     "k",
+	'\u24db':     "l",
+	'\u24dc':     "m",
+	'\u24dd':     "n",
+	'\u24de':     "o",
+	'\u24df':     "p",
+	'\u24e0':     "q",
+	'\u24e1':     "r",
+	'\u24e2':     "s",
+	'\u24e3':     "t",
+	'\u24e4':     "u",
+	'\u24e5':     "v",
+	'\u24e6':     "w",
+	'\u24e7':     "x",
+	'\u24e8':     "y",
+	'\u24e9':     "z",
+	'\u24ea':     "0",
+	'\u2a0c':     "\u222b\u222b\u222b\u222b",
+	'\u2a74':     "::=",
+	'\u2a75':     "==",
+	'\u2a76':     "===",
+	'\u2c7c':     "j",
+	'\u2c7d':     "V",
+	'\u2d6f':     "\u2d61",
+	'\u2e9f':     "\u6bcd",
+	'\u2ef3':     "\u9f9f",
+	'\u2f00':     "\u4e00",
+	'\u2f01':     "\u4e28",
+	'\u2f02':     "\u4e36",
+	'\u2f03':     "\u4e3f",
+	'\u2f04':     "\u4e59",
+	'\u2f05':     "\u4e85",
+	'\u2f06':     "\u4e8c",
+	'\u2f07':     "\u4ea0",
+	'\u2f08':     "\u4eba",
+	'\u2f09':     "\u513f",
+	'\u2f0a':     "\u5165",
+	'\u2f0b':     "\u516b",
+	'\u2f0c':     "\u5182",
+	'\u2f0d':     "\u5196",
+	'\u2f0e':     "\u51ab",
+	'\u2f0f':     "\u51e0",
+	'\u2f10':     "\u51f5",
+	'\u2f11':     "\u5200",
+	'\u2f12':     "\u529b",
+	'\u2f13':     "\u52f9",
+	'\u2f14':     "\u5315",
+	'\u2f15':     "\u531a",
+	'\u2f16':     "\u5338",
+	'\u2f17':     "\u5341",
+	'\u2f18':     "\u535c",
+	'\u2f19':     "\u5369",
+	'\u2f1a':     "\u5382",
+	'\u2f1b':     "\u53b6",
+	'\u2f1c':     "\u53c8",
+	'\u2f1d':     "\u53e3",
+	'\u2f1e':     "\u56d7",
+	'\u2f1f':     "\u571f",
+	'\u2f20':     "\u58eb",
+	'\u2f21':     "\u5902",
+	'\u2f22':     "\u590a",
+	'\u2f23':     "\u5915",
+	'\u2f24':     "\u5927",
+	'\u2f25':     "\u5973",
+	'\u2f26':     "\u5b50",
+	'\u2f27':     "\u5b80",
+	'\u2f28':     "\u5bf8",
+	'\u2f29':     "\u5c0f",
+	'\u2f2a':     "\u5c22",
+	'\u2f2b':     "\u5c38",
+	'\u2f2c':     "\u5c6e",
+	'\u2f2d':     "\u5c71",
+	'\u2f2e':     "\u5ddb",
+	'\u2f2f':     "\u5de5",
+	'\u2f30':     "\u5df1",
+	'\u2f31':     "\u5dfe",
+	'\u2f32':     "\u5e72",
+	'\u2f33':     "\u5e7a",
+	'\u2f34':     "\u5e7f",
+	'\u2f35':     "\u5ef4",
+	'\u2f36':     "\u5efe",
+	'\u2f37':     "\u5f0b",
+	'\u2f38':     "\u5f13",
+	'\u2f39':     "\u5f50",
+	'\u2f3a':     "\u5f61",
+	'\u2f3b':     "\u5f73",
+	'\u2f3c':     "\u5fc3",
+	'\u2f3d':     "\u6208",
+	'\u2f3e':     "\u6236",
+	'\u2f3f':     "\u624b",
+	'\u2f40':     "\u652f",
+	'\u2f41':     "\u6534",
+	'\u2f42':     "\u6587",
+	'\u2f43':     "\u6597",
+	'\u2f44':     "\u65a4",
+	'\u2f45':     "\u65b9",
+	'\u2f46':     "\u65e0",
+	'\u2f47':     "\u65e5",
+	'\u2f48':     "\u66f0",
+	'\u2f49':     "\u6708",
+	'\u2f4a':     "\u6728",
+	'\u2f4b':     "\u6b20",
+	'\u2f4c':     "\u6b62",
+	'\u2f4d':     "\u6b79",
+	'\u2f4e':     "\u6bb3",
+	'\u2f4f':     "\u6bcb",
+	'\u2f50':     "\u6bd4",
+	'\u2f51':     "\u6bdb",
+	'\u2f52':     "\u6c0f",
+	'\u2f53':     "\u6c14",
+	'\u2f54':     "\u6c34",
+	'\u2f55':     "\u706b",
+	'\u2f56':     "\u722a",
+	'\u2f57':     "\u7236",
+	'\u2f58':     "\u723b",
+	'\u2f59':     "\u723f",
+	'\u2f5a':     "\u7247",
+	'\u2f5b':     "\u7259",
+	'\u2f5c':     "\u725b",
+	'\u2f5d':     "\u72ac",
+	'\u2f5e':     "\u7384",
+	'\u2f5f':     "\u7389",
+	'\u2f60':     "\u74dc",
+	'\u2f61':     "\u74e6",
+	'\u2f62':     "\u7518",
+	'\u2f63':     "\u751f",
+	'\u2f64':     "\u7528",
+	'\u2f65':     "\u7530",
+	'\u2f66':     "\u758b",
+	'\u2f67':     "\u7592",
+	'\u2f68':     "\u7676",
+	'\u2f69':     "\u767d",
+	'\u2f6a':     "\u76ae",
+	'\u2f6b':     "\u76bf",
+	'\u2f6c':     "\u76ee",
+	'\u2f6d':     "\u77db",
+	'\u2f6e':     "\u77e2",
+	'\u2f6f':     "\u77f3",
+	'\u2f70':     "\u793a",
+	'\u2f71':     "\u79b8",
+	'\u2f72':     "\u79be",
+	'\u2f73':     "\u7a74",
+	'\u2f74':     "\u7acb",
+	'\u2f75':     "\u7af9",
+	'\u2f76':     "\u7c73",
+	'\u2f77':     "\u7cf8",
+	'\u2f78':     "\u7f36",
+	'\u2f79':     "\u7f51",
+	'\u2f7a':     "\u7f8a",
+	'\u2f7b':     "\u7fbd",
+	'\u2f7c':     "\u8001",
+	'\u2f7d':     "\u800c",
+	'\u2f7e':     "\u8012",
+	'\u2f7f':     "\u8033",
+	'\u2f80':     "\u807f",
+	'\u2f81':     "\u8089",
+	'\u2f82':     "\u81e3",
+	'\u2f83':     "\u81ea",
+	'\u2f84':     "\u81f3",
+	'\u2f85':     "\u81fc",
+	'\u2f86':     "\u820c",
+	'\u2f87':     "\u821b",
+	'\u2f88':     "\u821f",
+	'\u2f89':     "\u826e",
+	'\u2f8a':     "\u8272",
+	'\u2f8b':     "\u8278",
+	'\u2f8c':     "\u864d",
+	'\u2f8d':     "\u866b",
+	'\u2f8e':     "\u8840",
+	'\u2f8f':     "\u884c",
+	'\u2f90':     "\u8863",
+	'\u2f91':     "\u897e",
+	'\u2f92':     "\u898b",
+	'\u2f93':     "\u89d2",
+	'\u2f94':     "\u8a00",
+	'\u2f95':     "\u8c37",
+	'\u2f96':     "\u8c46",
+	'\u2f97':     "\u8c55",
+	'\u2f98':     "\u8c78",
+	'\u2f99':     "\u8c9d",
+	'\u2f9a':     "\u8d64",
+	'\u2f9b':     "\u8d70",
+	'\u2f9c':     "\u8db3",
+	'\u2f9d':     "\u8eab",
+	'\u2f9e':     "\u8eca",
+	'\u2f9f':     "\u8f9b",
+	'\u2fa0':     "\u8fb0",
+	'\u2fa1':     "\u8fb5",
+	'\u2fa2':     "\u9091",
+	'\u2fa3':     "\u9149",
+	'\u2fa4':     "\u91c6",
+	'\u2fa5':     "\u91cc",
+	'\u2fa6':     "\u91d1",
+	'\u2fa7':     "\u9577",
+	'\u2fa8':     "\u9580",
+	'\u2fa9':     "\u961c",
+	'\u2faa':     "\u96b6",
+	'\u2fab':     "\u96b9",
+	'\u2fac':     "\u96e8",
+	'\u2fad':     "\u9751",
+	'\u2fae':     "\u975e",
+	'\u2faf':     "\u9762",
+	'\u2fb0':     "\u9769",
+	'\u2fb1':     "\u97cb",
+	'\u2fb2':     "\u97ed",
+	'\u2fb3':     "\u97f3",
+	'\u2fb4':     "\u9801",
+	'\u2fb5':     "\u98a8",
+	'\u2fb6':     "\u98db",
+	'\u2fb7':     "\u98df",
+	'\u2fb8':     "\u9996",
+	'\u2fb9':     "\u9999",
+	'\u2fba':     "\u99ac",
+	'\u2fbb':     "\u9aa8",
+	'\u2fbc':     "\u9ad8",
+	'\u2fbd':     "\u9adf",
+	'\u2fbe':     "\u9b25",
+	'\u2fbf':     "\u9b2f",
+	'\u2fc0':     "\u9b32",
+	'\u2fc1':     "\u9b3c",
+	'\u2fc2':     "\u9b5a",
+	'\u2fc3':     "\u9ce5",
+	'\u2fc4':     "\u9e75",
+	'\u2fc5':     "\u9e7f",
+	'\u2fc6':     "\u9ea5",
+	'\u2fc7':     "\u9ebb",
+	'\u2fc8':     "\u9ec3",
+	'\u2fc9':     "\u9ecd",
+	'\u2fca':     "\u9ed1",
+	'\u2fcb':     "\u9ef9",
+	'\u2fcc':     "\u9efd",
+	'\u2fcd':     "\u9f0e",
+	'\u2fce':     "\u9f13",
+	'\u2fcf':     "\u9f20",
+	'\u2fd0':     "\u9f3b",
+	'\u2fd1':     "\u9f4a",
+	'\u2fd2':     "\u9f52",
+	'\u2fd3':     "\u9f8d",
+	'\u2fd4':     "\u9f9c",
+	'\u2fd5':     "\u9fa0",
+	'\u3000':     " ",
+	'\u3036':     "\u3012",
+	'\u3038':     "\u5341",
+	'\u3039':     "\u5344",
+	'\u303a':     "\u5345",
+	'\u309b':     " \u3099",
+	'\u309c':     " \u309a",
+	'\u309f':     "\u3088\u308a",
+	'\u30ff':     "\u30b3\u30c8",
+	'\u3131':     "\u1100",
+	'\u3132':     "\u1101",
+	'\u3133':     "\u11aa",
+	'\u3134':     "\u1102",
+	'\u3135':     "\u11ac",
+	'\u3136':     "\u11ad",
+	'\u3137':     "\u1103",
+	'\u3138':     "\u1104",
+	'\u3139':     "\u1105",
+	'\u313a':     "\u11b0",
+	'\u313b':     "\u11b1",
+	'\u313c':     "\u11b2",
+	'\u313d':     "\u11b3",
+	'\u313e':     "\u11b4",
+	'\u313f':     "\u11b5",
+	'\u3140':     "\u111a",
+	'\u3141':     "\u1106",
+	'\u3142':     "\u1107",
+	'\u3143':     "\u1108",
+	'\u3144':     "\u1121",
+	'\u3145':     "\u1109",
+	'\u3146':     "\u110a",
+	'\u3147':     "\u110b",
+	'\u3148':     "\u110c",
+	'\u3149':     "\u110d",
+	'\u314a':     "\u110e",
+	'\u314b':     "\u110f",
+	'\u314c':     "\u1110",
+	'\u314d':     "\u1111",
+	'\u314e':     "\u1112",
+	'\u314f':     "\u1161",
+	'\u3150':     "\u1162",
+	'\u3151':     "\u1163",
+	'\u3152':     "\u1164",
+	'\u3153':     "\u1165",
+	'\u3154':     "\u1166",
+	'\u3155':     "\u1167",
+	'\u3156':     "\u1168",
+	'\u3157':     "\u1169",
+	'\u3158':     "\u116a",
+	'\u3159':     "\u116b",
+	'\u315a':     "\u116c",
+	'\u315b':     "\u116d",
+	'\u315c':     "\u116e",
+	'\u315d':     "\u116f",
+	'\u315e':     "\u1170",
+	'\u315f':     "\u1171",
+	'\u3160':     "\u1172",
+	'\u3161':     "\u1173",
+	'\u3162':     "\u1174",
+	'\u3163':     "\u1175",
+	'\u3164':     "\u1160",
+	'\u3165':     "\u1114",
+	'\u3166':     "\u1115",
+	'\u3167':     "\u11c7",
+	'\u3168':     "\u11c8",
+	'\u3169':     "\u11cc",
+	'\u316a':     "\u11ce",
+	'\u316b':     "\u11d3",
+	'\u316c':     "\u11d7",
+	'\u316d':     "\u11d9",
+	'\u316e':     "\u111c",
+	'\u316f':     "\u11dd",
+	'\u3170':     "\u11df",
+	'\u3171':     "\u111d",
+	'\u3172':     "\u111e",
+	'\u3173':     "\u1120",
+	'\u3174':     "\u1122",
+	'\u3175':     "\u1123",
+	'\u3176':     "\u1127",
+	'\u3177':     "\u1129",
+	'\u3178':     "\u112b",
+	'\u3179':     "\u112c",
+	'\u317a':     "\u112d",
+	'\u317b':     "\u112e",
+	'\u317c':     "\u112f",
+	'\u317d':     "\u1132",
+	'\u317e':     "\u1136",
+	'\u317f':     "\u1140",
+	'\u3180':     "\u1147",
+	'\u3181':     "\u114c",
+	'\u3182':     "\u11f1",
+	'\u3183':     "\u11f2",
+	'\u3184':     "\u1157",
+	'\u3185':     "\u1158",
+	'\u3186':     "\u1159",
+	'\u3187':     "\u1184",
+	'\u3188':     "\u1185",
+	'\u3189':     "\u1188",
+	'\u318a':     "\u1191",
+	'\u318b':     "\u1192",
+	'\u318c':     "\u1194",
+	'\u318d':     "\u119e",
+	'\u318e':     "\u11a1",
+	'\u3192':     "\u4e00",
+	'\u3193':     "\u4e8c",
+	'\u3194':     "\u4e09",
+	'\u3195':     "\u56db",
+	'\u3196':     "\u4e0a",
+	'\u3197':     "\u4e2d",
+	'\u3198':     "\u4e0b",
+	'\u3199':     "\u7532",
+	'\u319a':     "\u4e59",
+	'\u319b':     "\u4e19",
+	'\u319c':     "\u4e01",
+	'\u319d':     "\u5929",
+	'\u319e':     "\u5730",
+	'\u319f':     "\u4eba",
+	'\u3200':     "(\u1100)",
+	'\u3201':     "(\u1102)",
+	'\u3202':     "(\u1103)",
+	'\u3203':     "(\u1105)",
+	'\u3204':     "(\u1106)",
+	'\u3205':     "(\u1107)",
+	'\u3206':     "(\u1109)",
+	'\u3207':     "(\u110b)",
+	'\u3208':     "(\u110c)",
+	'\u3209':     "(\u110e)",
+	'\u320a':     "(\u110f)",
+	'\u320b':     "(\u1110)",
+	'\u320c':     "(\u1111)",
+	'\u320d':     "(\u1112)",
+	'\u320e':     "(\u1100\u1161)",
+	'\u320f':     "(\u1102\u1161)",
+	'\u3210':     "(\u1103\u1161)",
+	'\u3211':     "(\u1105\u1161)",
+	'\u3212':     "(\u1106\u1161)",
+	'\u3213':     "(\u1107\u1161)",
+	'\u3214':     "(\u1109\u1161)",
+	'\u3215':     "(\u110b\u1161)",
+	'\u3216':     "(\u110c\u1161)",
+	'\u3217':     "(\u110e\u1161)",
+	'\u3218':     "(\u110f\u1161)",
+	'\u3219':     "(\u1110\u1161)",
+	'\u321a':     "(\u1111\u1161)",
+	'\u321b':     "(\u1112\u1161)",
+	'\u321c':     "(\u110c\u116e)",
+	'\u321d':     "(\u110b\u1169\u110c\u1165\u11ab)",
+	'\u321e':     "(\u110b\u1169\u1112\u116e)",
+	'\u3220':     "(\u4e00)",
+	'\u3221':     "(\u4e8c)",
+	'\u3222':     "(\u4e09)",
+	'\u3223':     "(\u56db)",
+	'\u3224':     "(\u4e94)",
+	'\u3225':     "(\u516d)",
+	'\u3226':     "(\u4e03)",
+	'\u3227':     "(\u516b)",
+	'\u3228':     "(\u4e5d)",
+	'\u3229':     "(\u5341)",
+	'\u322a':     "(\u6708)",
+	'\u322b':     "(\u706b)",
+	'\u322c':     "(\u6c34)",
+	'\u322d':     "(\u6728)",
+	'\u322e':     "(\u91d1)",
+	'\u322f':     "(\u571f)",
+	'\u3230':     "(\u65e5)",
+	'\u3231':     "(\u682a)",
+	'\u3232':     "(\u6709)",
+	'\u3233':     "(\u793e)",
+	'\u3234':     "(\u540d)",
+	'\u3235':     "(\u7279)",
+	'\u3236':     "(\u8ca1)",
+	'\u3237':     "(\u795d)",
+	'\u3238':     "(\u52b4)",
+	'\u3239':     "(\u4ee3)",
+	'\u323a':     "(\u547c)",
+	'\u323b':     "(\u5b66)",
+	'\u323c':     "(\u76e3)",
+	'\u323d':     "(\u4f01)",
+	'\u323e':     "(\u8cc7)",
+	'\u323f':     "(\u5354)",
+	'\u3240':     "(\u796d)",
+	'\u3241':     "(\u4f11)",
+	'\u3242':     "(\u81ea)",
+	'\u3243':     "(\u81f3)",
+	'\u3244':     "\u554f",
+	'\u3245':     "\u5e7c",
+	'\u3246':     "\u6587",
+	'\u3247':     "\u7b8f",
+	'\u3250':     "PTE",
+	'\u3251':     "21",
+	'\u3252':     "22",
+	'\u3253':     "23",
+	'\u3254':     "24",
+	'\u3255':     "25",
+	'\u3256':     "26",
+	'\u3257':     "27",
+	'\u3258':     "28",
+	'\u3259':     "29",
+	'\u325a':     "30",
+	'\u325b':     "31",
+	'\u325c':     "32",
+	'\u325d':     "33",
+	'\u325e':     "34",
+	'\u325f':     "35",
+	'\u3260':     "\u1100",
+	'\u3261':     "\u1102",
+	'\u3262':     "\u1103",
+	'\u3263':     "\u1105",
+	'\u3264':     "\u1106",
+	'\u3265':     "\u1107",
+	'\u3266':     "\u1109",
+	'\u3267':     "\u110b",
+	'\u3268':     "\u110c",
+	'\u3269':     "\u110e",
+	'\u326a':     "\u110f",
+	'\u326b':     "\u1110",
+	'\u326c':     "\u1111",
+	'\u326d':     "\u1112",
+	'\u326e':     "\u1100\u1161",
+	'\u326f':     "\u1102\u1161",
+	'\u3270':     "\u1103\u1161",
+	'\u3271':     "\u1105\u1161",
+	'\u3272':     "\u1106\u1161",
+	'\u3273':     "\u1107\u1161",
+	'\u3274':     "\u1109\u1161",
+	'\u3275':     "\u110b\u1161",
+	'\u3276':     "\u110c\u1161",
+	'\u3277':     "\u110e\u1161",
+	'\u3278':     "\u110f\u1161",
+	'\u3279':     "\u1110\u1161",
+	'\u327a':     "\u1111\u1161",
+	'\u327b':     "\u1112\u1161",
+	'\u327c':     "\u110e\u1161\u11b7\u1100\u1169",
+	'\u327d':     "\u110c\u116e\u110b\u1174",
+	'\u327e':     "\u110b\u116e",
+	'\u3280':     "\u4e00",
+	'\u3281':     "\u4e8c",
+	'\u3282':     "\u4e09",
+	'\u3283':     "\u56db",
+	'\u3284':     "\u4e94",
+	'\u3285':     "\u516d",
+	'\u3286':     "\u4e03",
+	'\u3287':     "\u516b",
+	'\u3288':     "\u4e5d",
+	'\u3289':     "\u5341",
+	'\u328a':     "\u6708",
+	'\u328b':     "\u706b",
+	'\u328c':     "\u6c34",
+	'\u328d':     "\u6728",
+	'\u328e':     "\u91d1",
+	'\u328f':     "\u571f",
+	'\u3290':     "\u65e5",
+	'\u3291':     "\u682a",
+	'\u3292':     "\u6709",
+	'\u3293':     "\u793e",
+	'\u3294':     "\u540d",
+	'\u3295':     "\u7279",
+	'\u3296':     "\u8ca1",
+	'\u3297':     "\u795d",
+	'\u3298':     "\u52b4",
+	'\u3299':     "\u79d8",
+	'\u329a':     "\u7537",
+	'\u329b':     "\u5973",
+	'\u329c':     "\u9069",
+	'\u329d':     "\u512a",
+	'\u329e':     "\u5370",
+	'\u329f':     "\u6ce8",
+	'\u32a0':     "\u9805",
+	'\u32a1':     "\u4f11",
+	'\u32a2':     "\u5199",
+	'\u32a3':     "\u6b63",
+	'\u32a4':     "\u4e0a",
+	'\u32a5':     "\u4e2d",
+	'\u32a6':     "\u4e0b",
+	'\u32a7':     "\u5de6",
+	'\u32a8':     "\u53f3",
+	'\u32a9':     "\u533b",
+	'\u32aa':     "\u5b97",
+	'\u32ab':     "\u5b66",
+	'\u32ac':     "\u76e3",
+	'\u32ad':     "\u4f01",
+	'\u32ae':     "\u8cc7",
+	'\u32af':     "\u5354",
+	'\u32b0':     "\u591c",
+	'\u32b1':     "36",
+	'\u32b2':     "37",
+	'\u32b3':     "38",
+	'\u32b4':     "39",
+	'\u32b5':     "40",
+	'\u32b6':     "41",
+	'\u32b7':     "42",
+	'\u32b8':     "43",
+	'\u32b9':     "44",
+	'\u32ba':     "45",
+	'\u32bb':     "46",
+	'\u32bc':     "47",
+	'\u32bd':     "48",
+	'\u32be':     "49",
+	'\u32bf':     "50",
+	'\u32c0':     "1\u6708",
+	'\u32c1':     "2\u6708",
+	'\u32c2':     "3\u6708",
+	'\u32c3':     "4\u6708",
+	'\u32c4':     "5\u6708",
+	'\u32c5':     "6\u6708",
+	'\u32c6':     "7\u6708",
+	'\u32c7':     "8\u6708",
+	'\u32c8':     "9\u6708",
+	'\u32c9':     "10\u6708",
+	'\u32ca':     "11\u6708",
+	'\u32cb':     "12\u6708",
+	'\u32cc':     "Hg",
+	'\u32cd':     "erg",
+	'\u32ce':     "eV",
+	'\u32cf':     "LTD",
+	'\u32d0':     "\u30a2",
+	'\u32d1':     "\u30a4",
+	'\u32d2':     "\u30a6",
+	'\u32d3':     "\u30a8",
+	'\u32d4':     "\u30aa",
+	'\u32d5':     "\u30ab",
+	'\u32d6':     "\u30ad",
+	'\u32d7':     "\u30af",
+	'\u32d8':     "\u30b1",
+	'\u32d9':     "\u30b3",
+	'\u32da':     "\u30b5",
+	'\u32db':     "\u30b7",
+	'\u32dc':     "\u30b9",
+	'\u32dd':     "\u30bb",
+	'\u32de':     "\u30bd",
+	'\u32df':     "\u30bf",
+	'\u32e0':     "\u30c1",
+	'\u32e1':     "\u30c4",
+	'\u32e2':     "\u30c6",
+	'\u32e3':     "\u30c8",
+	'\u32e4':     "\u30ca",
+	'\u32e5':     "\u30cb",
+	'\u32e6':     "\u30cc",
+	'\u32e7':     "\u30cd",
+	'\u32e8':     "\u30ce",
+	'\u32e9':     "\u30cf",
+	'\u32ea':     "\u30d2",
+	'\u32eb':     "\u30d5",
+	'\u32ec':     "\u30d8",
+	'\u32ed':     "\u30db",
+	'\u32ee':     "\u30de",
+	'\u32ef':     "\u30df",
+	'\u32f0':     "\u30e0",
+	'\u32f1':     "\u30e1",
+	'\u32f2':     "\u30e2",
+	'\u32f3':     "\u30e4",
+	'\u32f4':     "\u30e6",
+	'\u32f5':     "\u30e8",
+	'\u32f6':     "\u30e9",
+	'\u32f7':     "\u30ea",
+	'\u32f8':     "\u30eb",
+	'\u32f9':     "\u30ec",
+	'\u32fa':     "\u30ed",
+	'\u32fb':     "\u30ef",
+	'\u32fc':     "\u30f0",
+	'\u32fd':     "\u30f1",
+	'\u32fe':     "\u30f2",
+	'\u32ff':     "\u4ee4\u548c",
+	'\u3300':     "\u30a2\u30d1\u30fc\u30c8",
+	'\u3301':     "\u30a2\u30eb\u30d5\u30a1",
+	'\u3302':     "\u30a2\u30f3\u30da\u30a2",
+	'\u3303':     "\u30a2\u30fc\u30eb",
+	'\u3304':     "\u30a4\u30cb\u30f3\u30b0",
+	'\u3305':     "\u30a4\u30f3\u30c1",
+	'\u3306':     "\u30a6\u30a9\u30f3",
+	'\u3307':     "\u30a8\u30b9\u30af\u30fc\u30c9",
+	'\u3308':     "\u30a8\u30fc\u30ab\u30fc",
+	'\u3309':     "\u30aa\u30f3\u30b9",
+	'\u330a':     "\u30aa\u30fc\u30e0",
+	'\u330b':     "\u30ab\u30a4\u30ea",
+	'\u330c':     "\u30ab\u30e9\u30c3\u30c8",
+	'\u330d':     "\u30ab\u30ed\u30ea\u30fc",
+	'\u330e':     "\u30ac\u30ed\u30f3",
+	'\u330f':     "\u30ac\u30f3\u30de",
+	'\u3310':     "\u30ae\u30ac",
+	'\u3311':     "\u30ae\u30cb\u30fc",
+	'\u3312':     "\u30ad\u30e5\u30ea\u30fc",
+	'\u3313':     "\u30ae\u30eb\u30c0\u30fc",
+	'\u3314':     "\u30ad\u30ed",
+	'\u3315':     "\u30ad\u30ed\u30b0\u30e9\u30e0",
+	'\u3316':     "\u30ad\u30ed\u30e1\u30fc\u30c8\u30eb",
+	'\u3317':     "\u30ad\u30ed\u30ef\u30c3\u30c8",
+	'\u3318':     "\u30b0\u30e9\u30e0",
+	'\u3319':     "\u30b0\u30e9\u30e0\u30c8\u30f3",
+	'\u331a':     "\u30af\u30eb\u30bc\u30a4\u30ed",
+	'\u331b':     "\u30af\u30ed\u30fc\u30cd",
+	'\u331c':     "\u30b1\u30fc\u30b9",
+	'\u331d':     "\u30b3\u30eb\u30ca",
+	'\u331e':     "\u30b3\u30fc\u30dd",
+	'\u331f':     "\u30b5\u30a4\u30af\u30eb",
+	'\u3320':     "\u30b5\u30f3\u30c1\u30fc\u30e0",
+	'\u3321':     "\u30b7\u30ea\u30f3\u30b0",
+	'\u3322':     "\u30bb\u30f3\u30c1",
+	'\u3323':     "\u30bb\u30f3\u30c8",
+	'\u3324':     "\u30c0\u30fc\u30b9",
+	'\u3325':     "\u30c7\u30b7",
+	'\u3326':     "\u30c9\u30eb",
+	'\u3327':     "\u30c8\u30f3",
+	'\u3328':     "\u30ca\u30ce",
+	'\u3329':     "\u30ce\u30c3\u30c8",
+	'\u332a':     "\u30cf\u30a4\u30c4",
+	'\u332b':     "\u30d1\u30fc\u30bb\u30f3\u30c8",
+	'\u332c':     "\u30d1\u30fc\u30c4",
+	'\u332d':     "\u30d0\u30fc\u30ec\u30eb",
+	'\u332e':     "\u30d4\u30a2\u30b9\u30c8\u30eb",
+	'\u332f':     "\u30d4\u30af\u30eb",
+	'\u3330':     "\u30d4\u30b3",
+	'\u3331':     "\u30d3\u30eb",
+	'\u3332':     "\u30d5\u30a1\u30e9\u30c3\u30c9",
+	'\u3333':     "\u30d5\u30a3\u30fc\u30c8",
+	'\u3334':     "\u30d6\u30c3\u30b7\u30a7\u30eb",
+	'\u3335':     "\u30d5\u30e9\u30f3",
+	'\u3336':     "\u30d8\u30af\u30bf\u30fc\u30eb",
+	'\u3337':     "\u30da\u30bd",
+	'\u3338':     "\u30da\u30cb\u30d2",
+	'\u3339':     "\u30d8\u30eb\u30c4",
+	'\u333a':     "\u30da\u30f3\u30b9",
+	'\u333b':     "\u30da\u30fc\u30b8",
+	'\u333c':     "\u30d9\u30fc\u30bf",
+	'\u333d':     "\u30dd\u30a4\u30f3\u30c8",
+	'\u333e':     "\u30dc\u30eb\u30c8",
+	'\u333f':     "\u30db\u30f3",
+	'\u3340':     "\u30dd\u30f3\u30c9",
+	'\u3341':     "\u30db\u30fc\u30eb",
+	'\u3342':     "\u30db\u30fc\u30f3",
+	'\u3343':     "\u30de\u30a4\u30af\u30ed",
+	'\u3344':     "\u30de\u30a4\u30eb",
+	'\u3345':     "\u30de\u30c3\u30cf",
+	'\u3346':     "\u30de\u30eb\u30af",
+	'\u3347':     "\u30de\u30f3\u30b7\u30e7\u30f3",
+	'\u3348':     "\u30df\u30af\u30ed\u30f3",
+	'\u3349':     "\u30df\u30ea",
+	'\u334a':     "\u30df\u30ea\u30d0\u30fc\u30eb",
+	'\u334b':     "\u30e1\u30ac",
+	'\u334c':     "\u30e1\u30ac\u30c8\u30f3",
+	'\u334d':     "\u30e1\u30fc\u30c8\u30eb",
+	'\u334e':     "\u30e4\u30fc\u30c9",
+	'\u334f':     "\u30e4\u30fc\u30eb",
+	'\u3350':     "\u30e6\u30a2\u30f3",
+	'\u3351':     "\u30ea\u30c3\u30c8\u30eb",
+	'\u3352':     "\u30ea\u30e9",
+	'\u3353':     "\u30eb\u30d4\u30fc",
+	'\u3354':     "\u30eb\u30fc\u30d6\u30eb",
+	'\u3355':     "\u30ec\u30e0",
+	'\u3356':     "\u30ec\u30f3\u30c8\u30b2\u30f3",
+	'\u3357':     "\u30ef\u30c3\u30c8",
+	'\u3358':     "0\u70b9",
+	'\u3359':     "1\u70b9",
+	'\u335a':     "2\u70b9",
+	'\u335b':     "3\u70b9",
+	'\u335c':     "4\u70b9",
+	'\u335d':     "5\u70b9",
+	'\u335e':     "6\u70b9",
+	'\u335f':     "7\u70b9",
+	'\u3360':     "8\u70b9",
+	'\u3361':     "9\u70b9",
+	'\u3362':     "10\u70b9",
+	'\u3363':     "11\u70b9",
+	'\u3364':     "12\u70b9",
+	'\u3365':     "13\u70b9",
+	'\u3366':     "14\u70b9",
+	'\u3367':     "15\u70b9",
+	'\u3368':     "16\u70b9",
+	'\u3369':     "17\u70b9",
+	'\u336a':     "18\u70b9",
+	'\u336b':     "19\u70b9",
+	'\u336c':     "20\u70b9",
+	'\u336d':     "21\u70b9",
+	'\u336e':     "22\u70b9",
+	'\u336f':     "23\u70b9",
+	'\u3370':     "24\u70b9",
+	'\u3371':     "hPa",
+	'\u3372':     "da",
+	'\u3373':     "AU",
+	'\u3374':     "bar",
+	'\u3375':     "oV",
+	'\u3376':     "pc",
+	'\u3377':     "dm",
+	'\u3378':     "dm\u00b2",
+	'\u3379':     "dm\u00b3",
+	'\u337a':     "IU",
+	'\u337b':     "\u5e73\u6210",
+	'\u337c':     "\u662d\u548c",
+	'\u337d':     "\u5927\u6b63",
+	'\u337e':     "\u660e\u6cbb",
+	'\u337f':     "\u682a\u5f0f\u4f1a\u793e",
+	'\u3380':     "pA",
+	'\u3381':     "nA",
+	'\u3382':     "\u03bcA",
+	'\u3383':     "mA",
+	'\u3384':     "kA",
+	'\u3385':     "KB",
+	'\u3386':     "MB",
+	'\u3387':     "GB",
+	'\u3388':     "cal",
+	'\u3389':     "kcal",
+	'\u338a':     "pF",
+	'\u338b':     "nF",
+	'\u338c':     "\u03bcF",
+	'\u338d':     "\u03bcg",
+	'\u338e':     "mg",
+	'\u338f':     "kg",
+	'\u3390':     "Hz",
+	'\u3391':     "kHz",
+	'\u3392':     "MHz",
+	'\u3393':     "GHz",
+	'\u3394':     "THz",
+	'\u3395':     "\u03bc\u2113",
+	'\u3396':     "m\u2113",
+	'\u3397':     "d\u2113",
+	'\u3398':     "k\u2113",
+	'\u3399':     "fm",
+	'\u339a':     "nm",
+	'\u339b':     "\u03bcm",
+	'\u339c':     "mm",
+	'\u339d':     "cm",
+	'\u339e':     "km",
+	'\u339f':     "mm\u00b2",
+	'\u33a0':     "cm\u00b2",
+	'\u33a1':     "m\u00b2",
+	'\u33a2':     "km\u00b2",
+	'\u33a3':     "mm\u00b3",
+	'\u33a4':     "cm\u00b3",
+	'\u33a5':     "m\u00b3",
+	'\u33a6':     "km\u00b3",
+	'\u33a7':     "m\u2215s",
+	'\u33a8':     "m\u2215s\u00b2",
+	'\u33a9':     "Pa",
+	'\u33aa':     "kPa",
+	'\u33ab':     "MPa",
+	'\u33ac':     "GPa",
+	'\u33ad':     "rad",
+	'\u33ae':     "rad\u2215s",
+	'\u33af':     "rad\u2215s\u00b2",
+	'\u33b0':     "ps",
+	'\u33b1':     "ns",
+	'\u33b2':     "\u03bcs",
+	'\u33b3':     "ms",
+	'\u33b4':     "pV",
+	'\u33b5':     "nV",
+	'\u33b6':     "\u03bcV",
+	'\u33b7':     "mV",
+	'\u33b8':     "kV",
+	'\u33b9':     "MV",
+	'\u33ba':     "pW",
+	'\u33bb':     "nW",
+	'\u33bc':     "\u03bcW",
+	'\u33bd':     "mW",
+	'\u33be':     "kW",
+	'\u33bf':     "MW",
+	'\u33c0':     "k\u03a9",
+	'\u33c1':     "M\u03a9",
+	'\u33c2':     "a.m.",
+	'\u33c3':     "Bq",
+	'\u33c4':     "cc",
+	'\u33c5':     "cd",
+	'\u33c6':     "C\u2215kg",
+	'\u33c7':     "Co.",
+	'\u33c8':     "dB",
+	'\u33c9':     "Gy",
+	'\u33ca':     "ha",
+	'\u33cb':     "HP",
+	'\u33cc':     "in",
+	'\u33cd':     "KK",
+	'\u33ce':     "KM",
+	'\u33cf':     "kt",
+	'\u33d0':     "lm",
+	'\u33d1':     "ln",
+	'\u33d2':     "log",
+	'\u33d3':     "lx",
+	'\u33d4':     "mb",
+	'\u33d5':     "mil",
+	'\u33d6':     "mol",
+	'\u33d7':     "PH",
+	'\u33d8':     "p.m.",
+	'\u33d9':     "PPM",
+	'\u33da':     "PR",
+	'\u33db':     "sr",
+	'\u33dc':     "Sv",
+	'\u33dd':     "Wb",
+	'\u33de':     "V\u2215m",
+	'\u33df':     "A\u2215m",
+	'\u33e0':     "1\u65e5",
+	'\u33e1':     "2\u65e5",
+	'\u33e2':     "3\u65e5",
+	'\u33e3':     "4\u65e5",
+	'\u33e4':     "5\u65e5",
+	'\u33e5':     "6\u65e5",
+	'\u33e6':     "7\u65e5",
+	'\u33e7':     "8\u65e5",
+	'\u33e8':     "9\u65e5",
+	'\u33e9':     "10\u65e5",
+	'\u33ea':     "11\u65e5",
+	'\u33eb':     "12\u65e5",
+	'\u33ec':     "13\u65e5",
+	'\u33ed':     "14\u65e5",
+	'\u33ee':     "15\u65e5",
+	'\u33ef':     "16\u65e5",
+	'\u33f0':     "17\u65e5",
+	'\u33f1':     "18\u65e5",
+	'\u33f2':     "19\u65e5",
+	'\u33f3':     "20\u65e5",
+	'\u33f4':     "21\u65e5",
+	'\u33f5':     "22\u65e5",
+	'\u33f6':     "23\u65e5",
+	'\u33f7':     "24\u65e5",
+	'\u33f8':     "25\u65e5",
+	'\u33f9':     "26\u65e5",
+	'\u33fa':     "27\u65e5",
+	'\u33fb':     "28\u65e5",
+	'\u33fc':     "29\u65e5",
+	'\u33fd':     "30\u65e5",
+	'\u33fe':     "31\u65e5",
+	'\u33ff':     "gal",
+	'\ua69c':     "\u044a",
+	'\ua69d':     "\u044c",
+	'\ua770':     "\ua76f",
+	'\ua7f2':     "C",
+	'\ua7f3':     "F",
+	'\ua7f4':     "Q",
+	'\ua7f8':     "\u0126",
+	'\ua7f9':     "\u0153",
+	'\uab5c':     "\ua727",
+	'\uab5d':     "\uab37",
+	'\uab5e':     "\u026b",
+	'\uab5f':     "\uab52",
+	'\uab69':     "\u028d",
+	'\ufb00':     "ff",
+	'\ufb01':     "fi",
+	'\ufb02':     "fl",
+	'\ufb03':     "ffi",
+	'\ufb04':     "ffl",
+	'\ufb05':     "\u017ft",
+	'\ufb06':     "st",
+	'\ufb13':     "\u0574\u0576",
+	'\ufb14':     "\u0574\u0565",
+	'\ufb15':     "\u0574\u056b",
+	'\ufb16':     "\u057e\u0576",
+	'\ufb17':     "\u0574\u056d",
+	'\ufb20':     "\u05e2",
+	'\ufb21':     "\u05d0",
+	'\ufb22':     "\u05d3",
+	'\ufb23':     "\u05d4",
+	'\ufb24':     "\u05db",
+	'\ufb25':     "\u05dc",
+	'\ufb26':     "\u05dd",
+	'\ufb27':     "\u05e8",
+	'\ufb28':     "\u05ea",
+	'\ufb29':     "+",
+	'\ufb4f':     "\u05d0\u05dc",
+	'\ufb50':     "\u0671",
+	'\ufb51':     "\u0671",
+	'\ufb52':     "\u067b",
+	'\ufb53':     "\u067b",
+	'\ufb54':     "\u067b",
+	'\ufb55':     "\u067b",
+	'\ufb56':     "\u067e",
+	'\ufb57':     "\u067e",
+	'\ufb58':     "\u067e",
+	'\ufb59':     "\u067e",
+	'\ufb5a':     "\u0680",
+	'\ufb5b':     "\u0680",
+	'\ufb5c':     "\u0680",
+	'\ufb5d':     "\u0680",
+	'\ufb5e':     "\u067a",
+	'\ufb5f':     "\u067a",
+	'\ufb60':     "\u067a",
+	'\ufb61':     "\u067a",
+	'\ufb62':     "\u067f",
+	'\ufb63':     "\u067f",
+	'\ufb64':     "\u067f",
+	'\ufb65':     "\u067f",
+	'\ufb66':     "\u0679",
+	'\ufb67':     "\u0679",
+	'\ufb68':     "\u0679",
+	'\ufb69':     "\u0679",
+	'\ufb6a':     "\u06a4",
+	'\ufb6b':     "\u06a4",
+	'\ufb6c':     "\u06a4",
+	'\ufb6d':     "\u06a4",
+	'\ufb6e':     "\u06a6",
+	'\ufb6f':     "\u06a6",
+	'\ufb70':     "\u06a6",
+	'\ufb71':     "\u06a6",
+	'\ufb72':     "\u0684",
+	'\ufb73':     "\u0684",
+	'\ufb74':     "\u0684",
+	'\ufb75':     "\u0684",
+	'\ufb76':     "\u0683",
+	'\ufb77':     "\u0683",
+	'\ufb78':     "\u0683",
+	'\ufb79':     "\u0683",
+	'\ufb7a':     "\u0686",
+	'\ufb7b':     "\u0686",
+	'\ufb7c':     "\u0686",
+	'\ufb7d':     "\u0686",
+	'\ufb7e':     "\u0687",
+	'\ufb7f':     "\u0687",
+	'\ufb80':     "\u0687",
+	'\ufb81':     "\u0687",
+	'\ufb82':     "\u068d",
+	'\ufb83':     "\u068d",
+	'\ufb84':     "\u068c",
+	'\ufb85':     "\u068c",
+	'\ufb86':     "\u068e",
+	'\ufb87':     "\u068e",
+	'\ufb88':     "\u0688",
+	'\ufb89':     "\u0688",
+	'\ufb8a':     "\u0698",
+	'\ufb8b':     "\u0698",
+	'\ufb8c':     "\u0691",
+	'\ufb8d':     "\u0691",
+	'\ufb8e':     "\u06a9",
+	'\ufb8f':     "\u06a9",
+	'\ufb90':     "\u06a9",
+	'\ufb91':     "\u06a9",
+	'\ufb92':     "\u06af",
+	'\ufb93':     "\u06af",
+	'\ufb94':     "\u06af",
+	'\ufb95':     "\u06af",
+	'\ufb96':     "\u06b3",
+	'\ufb97':     "\u06b3",
+	'\ufb98':     "\u06b3",
+	'\ufb99':     "\u06b3",
+	'\ufb9a':     "\u06b1",
+	'\ufb9b':     "\u06b1",
+	'\ufb9c':     "\u06b1",
+	'\ufb9d':     "\u06b1",
+	'\ufb9e':     "\u06ba",
+	'\ufb9f':     "\u06ba",
+	'\ufba0':     "\u06bb",
+	'\ufba1':     "\u06bb",
+	'\ufba2':     "\u06bb",
+	'\ufba3':     "\u06bb",
+	'\ufba4':     "\u06c0",
+	'\ufba5':     "\u06c0",
+	'\ufba6':     "\u06c1",
+	'\ufba7':     "\u06c1",
+	'\ufba8':     "\u06c1",
+	'\ufba9':     "\u06c1",
+	'\ufbaa':     "\u06be",
+	'\ufbab':     "\u06be",
+	'\ufbac':     "\u06be",
+	'\ufbad':     "\u06be",
+	'\ufbae':     "\u06d2",
+	'\ufbaf':     "\u06d2",
+	'\ufbb0':     "\u06d3",
+	'\ufbb1':     "\u06d3",
+	'\ufbd3':     "\u06ad",
+	'\ufbd4':     "\u06ad",
+	'\ufbd5':     "\u06ad",
+	'\ufbd6':     "\u06ad",
+	'\ufbd7':     "\u06c7",
+	'\ufbd8':     "\u06c7",
+	'\ufbd9':     "\u06c6",
+	'\ufbda':     "\u06c6",
+	'\ufbdb':     "\u06c8",
+	'\ufbdc':     "\u06c8",
+	'\ufbdd':     "\u0677",
+	'\ufbde':     "\u06cb",
+	'\ufbdf':     "\u06cb",
+	'\ufbe0':     "\u06c5",
+	'\ufbe1':     "\u06c5",
+	'\ufbe2':     "\u06c9",
+	'\ufbe3':     "\u06c9",
+	'\ufbe4':     "\u06d0",
+	'\ufbe5':     "\u06d0",
+	'\ufbe6':     "\u06d0",
+	'\ufbe7':     "\u06d0",
+	'\ufbe8':     "\u0649",
+	'\ufbe9':     "\u0649",
+	'\ufbea':     "\u0626\u0627",
+	'\ufbeb':     "\u0626\u0627",
+	'\ufbec':     "\u0626\u06d5",
+	'\ufbed':     "\u0626\u06d5",
+	'\ufbee':     "\u0626\u0648",
+	'\ufbef':     "\u0626\u0648",
+	'\ufbf0':     "\u0626\u06c7",
+	'\ufbf1':     "\u0626\u06c7",
+	'\ufbf2':     "\u0626\u06c6",
+	'\ufbf3':     "\u0626\u06c6",
+	'\ufbf4':     "\u0626\u06c8",
+	'\ufbf5':     "\u0626\u06c8",
+	'\ufbf6':     "\u0626\u06d0",
+	'\ufbf7':     "\u0626\u06d0",
+	'\ufbf8':     "\u0626\u06d0",
+	'\ufbf9':     "\u0626\u0649",
+	'\ufbfa':     "\u0626\u0649",
+	'\ufbfb':     "\u0626\u0649",
+	'\ufbfc':     "\u06cc",
+	'\ufbfd':     "\u06cc",
+	'\ufbfe':     "\u06cc",
+	'\ufbff':     "\u06cc",
+	'\ufc00':     "\u0626\u062c",
+	'\ufc01':     "\u0626\u062d",
+	'\ufc02':     "\u0626\u0645",
+	'\ufc03':     "\u0626\u0649",
+	'\ufc04':     "\u0626\u064a",
+	'\ufc05':     "\u0628\u062c",
+	'\ufc06':     "\u0628\u062d",
+	'\ufc07':     "\u0628\u062e",
+	'\ufc08':     "\u0628\u0645",
+	'\ufc09':     "\u0628\u0649",
+	'\ufc0a':     "\u0628\u064a",
+	'\ufc0b':     "\u062a\u062c",
+	'\ufc0c':     "\u062a\u062d",
+	'\ufc0d':     "\u062a\u062e",
+	'\ufc0e':     "\u062a\u0645",
+	'\ufc0f':     "\u062a\u0649",
+	'\ufc10':     "\u062a\u064a",
+	'\ufc11':     "\u062b\u062c",
+	'\ufc12':     "\u062b\u0645",
+	'\ufc13':     "\u062b\u0649",
+	'\ufc14':     "\u062b\u064a",
+	'\ufc15':     "\u062c\u062d",
+	'\ufc16':     "\u062c\u0645",
+	'\ufc17':     "\u062d\u062c",
+	'\ufc18':     "\u062d\u0645",
+	'\ufc19':     "\u062e\u062c",
+	'\ufc1a':     "\u062e\u062d",
+	'\ufc1b':     "\u062e\u0645",
+	'\ufc1c':     "\u0633\u062c",
+	'\ufc1d':     "\u0633\u062d",
+	'\ufc1e':     "\u0633\u062e",
+	'\ufc1f':     "\u0633\u0645",
+	'\ufc20':     "\u0635\u062d",
+	'\ufc21':     "\u0635\u0645",
+	'\ufc22':     "\u0636\u062c",
+	'\ufc23':     "\u0636\u062d",
+	'\ufc24':     "\u0636\u062e",
+	'\ufc25':     "\u0636\u0645",
+	'\ufc26':     "\u0637\u062d",
+	'\ufc27':     "\u0637\u0645",
+	'\ufc28':     "\u0638\u0645",
+	'\ufc29':     "\u0639\u062c",
+	'\ufc2a':     "\u0639\u0645",
+	'\ufc2b':     "\u063a\u062c",
+	'\ufc2c':     "\u063a\u0645",
+	'\ufc2d':     "\u0641\u062c",
+	'\ufc2e':     "\u0641\u062d",
+	'\ufc2f':     "\u0641\u062e",
+	'\ufc30':     "\u0641\u0645",
+	'\ufc31':     "\u0641\u0649",
+	'\ufc32':     "\u0641\u064a",
+	'\ufc33':     "\u0642\u062d",
+	'\ufc34':     "\u0642\u0645",
+	'\ufc35':     "\u0642\u0649",
+	'\ufc36':     "\u0642\u064a",
+	'\ufc37':     "\u0643\u0627",
+	'\ufc38':     "\u0643\u062c",
+	'\ufc39':     "\u0643\u062d",
+	'\ufc3a':     "\u0643\u062e",
+	'\ufc3b':     "\u0643\u0644",
+	'\ufc3c':     "\u0643\u0645",
+	'\ufc3d':     "\u0643\u0649",
+	'\ufc3e':     "\u0643\u064a",
+	'\ufc3f':     "\u0644\u062c",
+	'\ufc40':     "\u0644\u062d",
+	'\ufc41':     "\u0644\u062e",
+	'\ufc42':     "\u0644\u0645",
+	'\ufc43':     "\u0644\u0649",
+	'\ufc44':     "\u0644\u064a",
+	'\ufc45':     "\u0645\u062c",
+	'\ufc46':     "\u0645\u062d",
+	'\ufc47':     "\u0645\u062e",
+	'\ufc48':     "\u0645\u0645",
+	'\ufc49':     "\u0645\u0649",
+	'\ufc4a':     "\u0645\u064a",
+	'\ufc4b':     "\u0646\u062c",
+	'\ufc4c':     "\u0646\u062d",
+	'\ufc4d':     "\u0646\u062e",
+	'\ufc4e':     "\u0646\u0645",
+	'\ufc4f':     "\u0646\u0649",
+	'\ufc50':     "\u0646\u064a",
+	'\ufc51':     "\u0647\u062c",
+	'\ufc52':     "\u0647\u0645",
+	'\ufc53':     "\u0647\u0649",
+	'\ufc54':     "\u0647\u064a",
+	'\ufc55':     "\u064a\u062c",
+	'\ufc56':     "\u064a\u062d",
+	'\ufc57':     "\u064a\u062e",
+	'\ufc58':     "\u064a\u0645",
+	'\ufc59':     "\u064a\u0649",
+	'\ufc5a':     "\u064a\u064a",
+	'\ufc5b':     "\u0630\u0670",
+	'\ufc5c':     "\u0631\u0670",
+	'\ufc5d':     "\u0649\u0670",
+	'\ufc5e':     " \u064c\u0651",
+	'\ufc5f':     " \u064d\u0651",
+	'\ufc60':     " \u064e\u0651",
+	'\ufc61':     " \u064f\u0651",
+	'\ufc62':     " \u0650\u0651",
+	'\ufc63':     " \u0651\u0670",
+	'\ufc64':     "\u0626\u0631",
+	'\ufc65':     "\u0626\u0632",
+	'\ufc66':     "\u0626\u0645",
+	'\ufc67':     "\u0626\u0646",
+	'\ufc68':     "\u0626\u0649",
+	'\ufc69':     "\u0626\u064a",
+	'\ufc6a':     "\u0628\u0631",
+	'\ufc6b':     "\u0628\u0632",
+	'\ufc6c':     "\u0628\u0645",
+	'\ufc6d':     "\u0628\u0646",
+	'\ufc6e':     "\u0628\u0649",
+	'\ufc6f':     "\u0628\u064a",
+	'\ufc70':     "\u062a\u0631",
+	'\ufc71':     "\u062a\u0632",
+	'\ufc72':     "\u062a\u0645",
+	'\ufc73':     "\u062a\u0646",
+	'\ufc74':     "\u062a\u0649",
+	'\ufc75':     "\u062a\u064a",
+	'\ufc76':     "\u062b\u0631",
+	'\ufc77':     "\u062b\u0632",
+	'\ufc78':     "\u062b\u0645",
+	'\ufc79':     "\u062b\u0646",
+	'\ufc7a':     "\u062b\u0649",
+	'\ufc7b':     "\u062b\u064a",
+	'\ufc7c':     "\u0641\u0649",
+	'\ufc7d':     "\u0641\u064a",
+	'\ufc7e':     "\u0642\u0649",
+	'\ufc7f':     "\u0642\u064a",
+	'\ufc80':     "\u0643\u0627",
+	'\ufc81':     "\u0643\u0644",
+	'\ufc82':     "\u0643\u0645",
+	'\ufc83':     "\u0643\u0649",
+	'\ufc84':     "\u0643\u064a",
+	'\ufc85':     "\u0644\u0645",
+	'\ufc86':     "\u0644\u0649",
+	'\ufc87':     "\u0644\u064a",
+	'\ufc88':     "\u0645\u0627",
+	'\ufc89':     "\u0645\u0645",
+	'\ufc8a':     "\u0646\u0631",
+	'\ufc8b':     "\u0646\u0632",
+	'\ufc8c':     "\u0646\u0645",
+	'\ufc8d':     "\u0646\u0646",
+	'\ufc8e':     "\u0646\u0649",
+	'\ufc8f':     "\u0646\u064a",
+	'\ufc90':     "\u0649\u0670",
+	'\ufc91':     "\u064a\u0631",
+	'\ufc92':     "\u064a\u0632",
+	'\ufc93':     "\u064a\u0645",
+	'\ufc94':     "\u064a\u0646",
+	'\ufc95':     "\u064a\u0649",
+	'\ufc96':     "\u064a\u064a",
+	'\ufc97':     "\u0626\u062c",
+	'\ufc98':     "\u0626\u062d",
+	'\ufc99':     "\u0626\u062e",
+	'\ufc9a':     "\u0626\u0645",
+	'\ufc9b':     "\u0626\u0647",
+	'\ufc9c':     "\u0628\u062c",
+	'\ufc9d':     "\u0628\u062d",
+	'\ufc9e':     "\u0628\u062e",
+	'\ufc9f':     "\u0628\u0645",
+	'\ufca0':     "\u0628\u0647",
+	'\ufca1':     "\u062a\u062c",
+	'\ufca2':     "\u062a\u062d",
+	'\ufca3':     "\u062a\u062e",
+	'\ufca4':     "\u062a\u0645",
+	'\ufca5':     "\u062a\u0647",
+	'\ufca6':     "\u062b\u0645",
+	'\ufca7':     "\u062c\u062d",
+	'\ufca8':     "\u062c\u0645",
+	'\ufca9':     "\u062d\u062c",
+	'\ufcaa':     "\u062d\u0645",
+	'\ufcab':     "\u062e\u062c",
+	'\ufcac':     "\u062e\u0645",
+	'\ufcad':     "\u0633\u062c",
+	'\ufcae':     "\u0633\u062d",
+	'\ufcaf':     "\u0633\u062e",
+	'\ufcb0':     "\u0633\u0645",
+	'\ufcb1':     "\u0635\u062d",
+	'\ufcb2':     "\u0635\u062e",
+	'\ufcb3':     "\u0635\u0645",
+	'\ufcb4':     "\u0636\u062c",
+	'\ufcb5':     "\u0636\u062d",
+	'\ufcb6':     "\u0636\u062e",
+	'\ufcb7':     "\u0636\u0645",
+	'\ufcb8':     "\u0637\u062d",
+	'\ufcb9':     "\u0638\u0645",
+	'\ufcba':     "\u0639\u062c",
+	'\ufcbb':     "\u0639\u0645",
+	'\ufcbc':     "\u063a\u062c",
+	'\ufcbd':     "\u063a\u0645",
+	'\ufcbe':     "\u0641\u062c",
+	'\ufcbf':     "\u0641\u062d",
+	'\ufcc0':     "\u0641\u062e",
+	'\ufcc1':     "\u0641\u0645",
+	'\ufcc2':     "\u0642\u062d",
+	'\ufcc3':     "\u0642\u0645",
+	'\ufcc4':     "\u0643\u062c",
+	'\ufcc5':     "\u0643\u062d",
+	'\ufcc6':     "\u0643\u062e",
+	'\ufcc7':     "\u0643\u0644",
+	'\ufcc8':     "\u0643\u0645",
+	'\ufcc9':     "\u0644\u062c",
+	'\ufcca':     "\u0644\u062d",
+	'\ufccb':     "\u0644\u062e",
+	'\ufccc':     "\u0644\u0645",
+	'\ufccd':     "\u0644\u0647",
+	'\ufcce':     "\u0645\u062c",
+	'\ufccf':     "\u0645\u062d",
+	'\ufcd0':     "\u0645\u062e",
+	'\ufcd1':     "\u0645\u0645",
+	'\ufcd2':     "\u0646\u062c",
+	'\ufcd3':     "\u0646\u062d",
+	'\ufcd4':     "\u0646\u062e",
+	'\ufcd5':     "\u0646\u0645",
+	'\ufcd6':     "\u0646\u0647",
+	'\ufcd7':     "\u0647\u062c",
+	'\ufcd8':     "\u0647\u0645",
+	'\ufcd9':     "\u0647\u0670",
+	'\ufcda':     "\u064a\u062c",
+	'\ufcdb':     "\u064a\u062d",
+	'\ufcdc':     "\u064a\u062e",
+	'\ufcdd':     "\u064a\u0645",
+	'\ufcde':     "\u064a\u0647",
+	'\ufcdf':     "\u0626\u0645",
+	'\ufce0':     "\u0626\u0647",
+	'\ufce1':     "\u0628\u0645",
+	'\ufce2':     "\u0628\u0647",
+	'\ufce3':     "\u062a\u0645",
+	'\ufce4':     "\u062a\u0647",
+	'\ufce5':     "\u062b\u0645",
+	'\ufce6':     "\u062b\u0647",
+	'\ufce7':     "\u0633\u0645",
+	'\ufce8':     "\u0633\u0647",
+	'\ufce9':     "\u0634\u0645",
+	'\ufcea':     "\u0634\u0647",
+	'\ufceb':     "\u0643\u0644",
+	'\ufcec':     "\u0643\u0645",
+	'\ufced':     "\u0644\u0645",
+	'\ufcee':     "\u0646\u0645",
+	'\ufcef':     "\u0646\u0647",
+	'\ufcf0':     "\u064a\u0645",
+	'\ufcf1':     "\u064a\u0647",
+	'\ufcf2':     "\u0640\u064e\u0651",
+	'\ufcf3':     "\u0640\u064f\u0651",
+	'\ufcf4':     "\u0640\u0650\u0651",
+	'\ufcf5':     "\u0637\u0649",
+	'\ufcf6':     "\u0637\u064a",
+	'\ufcf7':     "\u0639\u0649",
+	'\ufcf8':     "\u0639\u064a",
+	'\ufcf9':     "\u063a\u0649",
+	'\ufcfa':     "\u063a\u064a",
+	'\ufcfb':     "\u0633\u0649",
+	'\ufcfc':     "\u0633\u064a",
+	'\ufcfd':     "\u0634\u0649",
+	'\ufcfe':     "\u0634\u064a",
+	'\ufcff':     "\u062d\u0649",
+	'\ufd00':     "\u062d\u064a",
+	'\ufd01':     "\u062c\u0649",
+	'\ufd02':     "\u062c\u064a",
+	'\ufd03':     "\u062e\u0649",
+	'\ufd04':     "\u062e\u064a",
+	'\ufd05':     "\u0635\u0649",
+	'\ufd06':     "\u0635\u064a",
+	'\ufd07':     "\u0636\u0649",
+	'\ufd08':     "\u0636\u064a",
+	'\ufd09':     "\u0634\u062c",
+	'\ufd0a':     "\u0634\u062d",
+	'\ufd0b':     "\u0634\u062e",
+	'\ufd0c':     "\u0634\u0645",
+	'\ufd0d':     "\u0634\u0631",
+	'\ufd0e':     "\u0633\u0631",
+	'\ufd0f':     "\u0635\u0631",
+	'\ufd10':     "\u0636\u0631",
+	'\ufd11':     "\u0637\u0649",
+	'\ufd12':     "\u0637\u064a",
+	'\ufd13':     "\u0639\u0649",
+	'\ufd14':     "\u0639\u064a",
+	'\ufd15':     "\u063a\u0649",
+	'\ufd16':     "\u063a\u064a",
+	'\ufd17':     "\u0633\u0649",
+	'\ufd18':     "\u0633\u064a",
+	'\ufd19':     "\u0634\u0649",
+	'\ufd1a':     "\u0634\u064a",
+	'\ufd1b':     "\u062d\u0649",
+	'\ufd1c':     "\u062d\u064a",
+	'\ufd1d':     "\u062c\u0649",
+	'\ufd1e':     "\u062c\u064a",
+	'\ufd1f':     "\u062e\u0649",
+	'\ufd20':     "\u062e\u064a",
+	'\ufd21':     "\u0635\u0649",
+	'\ufd22':     "\u0635\u064a",
+	'\ufd23':     "\u0636\u0649",
+	'\ufd24':     "\u0636\u064a",
+	'\ufd25':     "\u0634\u062c",
+	'\ufd26':     "\u0634\u062d",
+	'\ufd27':     "\u0634\u062e",
+	'\ufd28':     "\u0634\u0645",
+	'\ufd29':     "\u0634\u0631",
+	'\ufd2a':     "\u0633\u0631",
+	'\ufd2b':     "\u0635\u0631",
+	'\ufd2c':     "\u0636\u0631",
+	'\ufd2d':     "\u0634\u062c",
+	'\ufd2e':     "\u0634\u062d",
+	'\ufd2f':     "\u0634\u062e",
+	'\ufd30':     "\u0634\u0645",
+	'\ufd31':     "\u0633\u0647",
+	'\ufd32':     "\u0634\u0647",
+	'\ufd33':     "\u0637\u0645",
+	'\ufd34':     "\u0633\u062c",
+	'\ufd35':     "\u0633\u062d",
+	'\ufd36':     "\u0633\u062e",
+	'\ufd37':     "\u0634\u062c",
+	'\ufd38':     "\u0634\u062d",
+	'\ufd39':     "\u0634\u062e",
+	'\ufd3a':     "\u0637\u0645",
+	'\ufd3b':     "\u0638\u0645",
+	'\ufd3c':     "\u0627\u064b",
+	'\ufd3d':     "\u0627\u064b",
+	'\ufd50':     "\u062a\u062c\u0645",
+	'\ufd51':     "\u062a\u062d\u062c",
+	'\ufd52':     "\u062a\u062d\u062c",
+	'\ufd53':     "\u062a\u062d\u0645",
+	'\ufd54':     "\u062a\u062e\u0645",
+	'\ufd55':     "\u062a\u0645\u062c",
+	'\ufd56':     "\u062a\u0645\u062d",
+	'\ufd57':     "\u062a\u0645\u062e",
+	'\ufd58':     "\u062c\u0645\u062d",
+	'\ufd59':     "\u062c\u0645\u062d",
+	'\ufd5a':     "\u062d\u0645\u064a",
+	'\ufd5b':     "\u062d\u0645\u0649",
+	'\ufd5c':     "\u0633\u062d\u062c",
+	'\ufd5d':     "\u0633\u062c\u062d",
+	'\ufd5e':     "\u0633\u062c\u0649",
+	'\ufd5f':     "\u0633\u0645\u062d",
+	'\ufd60':     "\u0633\u0645\u062d",
+	'\ufd61':     "\u0633\u0645\u062c",
+	'\ufd62':     "\u0633\u0645\u0645",
+	'\ufd63':     "\u0633\u0645\u0645",
+	'\ufd64':     "\u0635\u062d\u062d",
+	'\ufd65':     "\u0635\u062d\u062d",
+	'\ufd66':     "\u0635\u0645\u0645",
+	'\ufd67':     "\u0634\u062d\u0645",
+	'\ufd68':     "\u0634\u062d\u0645",
+	'\ufd69':     "\u0634\u062c\u064a",
+	'\ufd6a':     "\u0634\u0645\u062e",
+	'\ufd6b':     "\u0634\u0645\u062e",
+	'\ufd6c':     "\u0634\u0645\u0645",
+	'\ufd6d':     "\u0634\u0645\u0645",
+	'\ufd6e':     "\u0636\u062d\u0649",
+	'\ufd6f':     "\u0636\u062e\u0645",
+	'\ufd70':     "\u0636\u062e\u0645",
+	'\ufd71':     "\u0637\u0645\u062d",
+	'\ufd72':     "\u0637\u0645\u062d",
+	'\ufd73':     "\u0637\u0645\u0645",
+	'\ufd74':     "\u0637\u0645\u064a",
+	'\ufd75':     "\u0639\u062c\u0645",
+	'\ufd76':     "\u0639\u0645\u0645",
+	'\ufd77':     "\u0639\u0645\u0645",
+	'\ufd78':     "\u0639\u0645\u0649",
+	'\ufd79':     "\u063a\u0645\u0645",
+	'\ufd7a':     "\u063a\u0645\u064a",
+	'\ufd7b':     "\u063a\u0645\u0649",
+	'\ufd7c':     "\u0641\u062e\u0645",
+	'\ufd7d':     "\u0641\u062e\u0645",
+	'\ufd7e':     "\u0642\u0645\u062d",
+	'\ufd7f':     "\u0642\u0645\u0645",
+	'\ufd80':     "\u0644\u062d\u0645",
+	'\ufd81':     "\u0644\u062d\u064a",
+	'\ufd82':     "\u0644\u062d\u0649",
+	'\ufd83':     "\u0644\u062c\u062c",
+	'\ufd84':     "\u0644\u062c\u062c",
+	'\ufd85':     "\u0644\u062e\u0645",
+	'\ufd86':     "\u0644\u062e\u0645",
+	'\ufd87':     "\u0644\u0645\u062d",
+	'\ufd88':     "\u0644\u0645\u062d",
+	'\ufd89':     "\u0645\u062d\u062c",
+	'\ufd8a':     "\u0645\u062d\u0645",
+	'\ufd8b':     "\u0645\u062d\u064a",
+	'\ufd8c':     "\u0645\u062c\u062d",
+	'\ufd8d':     "\u0645\u062c\u0645",
+	'\ufd8e':     "\u0645\u062e\u062c",
+	'\ufd8f':     "\u0645\u062e\u0645",
+	'\ufd92':     "\u0645\u062c\u062e",
+	'\ufd93':     "\u0647\u0645\u062c",
+	'\ufd94':     "\u0647\u0645\u0645",
+	'\ufd95':     "\u0646\u062d\u0645",
+	'\ufd96':     "\u0646\u062d\u0649",
+	'\ufd97':     "\u0646\u062c\u0645",
+	'\ufd98':     "\u0646\u062c\u0645",
+	'\ufd99':     "\u0646\u062c\u0649",
+	'\ufd9a':     "\u0646\u0645\u064a",
+	'\ufd9b':     "\u0646\u0645\u0649",
+	'\ufd9c':     "\u064a\u0645\u0645",
+	'\ufd9d':     "\u064a\u0645\u0645",
+	'\ufd9e':     "\u0628\u062e\u064a",
+	'\ufd9f':     "\u062a\u062c\u064a",
+	'\ufda0':     "\u062a\u062c\u0649",
+	'\ufda1':     "\u062a\u062e\u064a",
+	'\ufda2':     "\u062a\u062e\u0649",
+	'\ufda3':     "\u062a\u0645\u064a",
+	'\ufda4':     "\u062a\u0645\u0649",
+	'\ufda5':     "\u062c\u0645\u064a",
+	'\ufda6':     "\u062c\u062d\u0649",
+	'\ufda7':     "\u062c\u0645\u0649",
+	'\ufda8':     "\u0633\u062e\u0649",
+	'\ufda9':     "\u0635\u062d\u064a",
+	'\ufdaa':     "\u0634\u062d\u064a",
+	'\ufdab':     "\u0636\u062d\u064a",
+	'\ufdac':     "\u0644\u062c\u064a",
+	'\ufdad':     "\u0644\u0645\u064a",
+	'\ufdae':     "\u064a\u062d\u064a",
+	'\ufdaf':     "\u064a\u062c\u064a",
+	'\ufdb0':     "\u064a\u0645\u064a",
+	'\ufdb1':     "\u0645\u0645\u064a",
+	'\ufdb2':     "\u0642\u0645\u064a",
+	'\ufdb3':     "\u0646\u062d\u064a",
+	'\ufdb4':     "\u0642\u0645\u062d",
+	'\ufdb5':     "\u0644\u062d\u0645",
+	'\ufdb6':     "\u0639\u0645\u064a",
+	'\ufdb7':     "\u0643\u0645\u064a",
+	'\ufdb8':     "\u0646\u062c\u062d",
+	'\ufdb9':     "\u0645\u062e\u064a",
+	'\ufdba':     "\u0644\u062c\u0645",
+	'\ufdbb':     "\u0643\u0645\u0645",
+	'\ufdbc':     "\u0644\u062c\u0645",
+	'\ufdbd':     "\u0646\u062c\u062d",
+	'\ufdbe':     "\u062c\u062d\u064a",
+	'\ufdbf':     "\u062d\u062c\u064a",
+	'\ufdc0':     "\u0645\u062c\u064a",
+	'\ufdc1':     "\u0641\u0645\u064a",
+	'\ufdc2':     "\u0628\u062d\u064a",
+	'\ufdc3':     "\u0643\u0645\u0645",
+	'\ufdc4':     "\u0639\u062c\u0645",
+	'\ufdc5':     "\u0635\u0645\u0645",
+	'\ufdc6':     "\u0633\u062e\u064a",
+	'\ufdc7':     "\u0646\u062c\u064a",
+	'\ufdf0':     "\u0635\u0644\u06d2",
+	'\ufdf1':     "\u0642\u0644\u06d2",
+	'\ufdf2':     "\u0627\u0644\u0644\u0647",
+	'\ufdf3':     "\u0627\u0643\u0628\u0631",
+	'\ufdf4':     "\u0645\u062d\u0645\u062f",
+	'\ufdf5':     "\u0635\u0644\u0639\u0645",
+	'\ufdf6':     "\u0631\u0633\u0648\u0644",
+	'\ufdf7':     "\u0639\u0644\u064a\u0647",
+	'\ufdf8':     "\u0648\u0633\u0644\u0645",
+	'\ufdf9':     "\u0635\u0644\u0649",
+	'\ufdfa':     "\u0635\u0644\u0649 \u0627\u0644\u0644\u0647 \u0639\u0644\u064a\u0647 \u0648\u0633\u0644\u0645",
+	'\ufdfb':     "\u062c\u0644 \u062c\u0644\u0627\u0644\u0647",
+	'\ufdfc':     "\u0631\u06cc\u0627\u0644",
+	'\ufe10':     ",",
+	'\ufe11':     "\u3001",
+	'\ufe12':     "\u3002",
+	'\ufe13':     ":",
+	'\ufe14':     ";",
+	'\ufe15':     "!",
+	'\ufe16':     "?",
+	'\ufe17':     "\u3016",
+	'\ufe18':     "\u3017",
+	'\ufe19':     "\u2026",
+	'\ufe30':     "\u2025",
+	'\ufe31':     "\u2014",
+	'\ufe32':     "\u2013",
+	'\ufe33':     "_",
+	'\ufe34':     "_",
+	'\ufe35':     "(",
+	'\ufe36':     ")",
+	'\ufe37':     "{",
+	'\ufe38':     "}",
+	'\ufe39':     "\u3014",
+	'\ufe3a':     "\u3015",
+	'\ufe3b':     "\u3010",
+	'\ufe3c':     "\u3011",
+	'\ufe3d':     "\u300a",
+	'\ufe3e':     "\u300b",
+	'\ufe3f':     "\u3008",
+	'\ufe40':     "\u3009",
+	'\ufe41':     "\u300c",
+	'\ufe42':     "\u300d",
+	'\ufe43':     "\u300e",
+	'\ufe44':     "\u300f",
+	'\ufe47':     "[",
+	'\ufe48':     "]",
+	'\ufe49':     "\u203e",
+	'\ufe4a':     "\u203e",
+	'\ufe4b':     "\u203e",
+	'\ufe4c':     "\u203e",
+	'\ufe4d':     "_",
+	'\ufe4e':     "_",
+	'\ufe4f':     "_",
+	'\ufe50':     ",",
+	'\ufe51':     "\u3001",
+	'\ufe52':     ".",
+	'\ufe54':     ";",
+	'\ufe55':     ":",
+	'\ufe56':     "?",
+	'\ufe57':     "!",
+	'\ufe58':     "\u2014",
+	'\ufe59':     "(",
+	'\ufe5a':     ")",
+	'\ufe5b':     "{",
+	'\ufe5c':     "}",
+	'\ufe5d':     "\u3014",
+	'\ufe5e':     "\u3015",
+	'\ufe5f':     "#",
+	'\ufe60':     "&",
+	'\ufe61':     "*",
+	'\ufe62':     "+",
+	'\ufe63':     "-",
+	'\ufe64':     "<",
+	'\ufe65':     ">",
+	'\ufe66':     "=",
+	'\ufe68':     "\\",
+	'\ufe69':     "$",
+	'\ufe6a':     "%",
+	'\ufe6b':     "@",
+	'\ufe70':     " \u064b",
+	'\ufe71':     "\u0640\u064b",
+	'\ufe72':     " \u064c",
+	'\ufe74':     " \u064d",
+	'\ufe76':     " \u064e",
+	'\ufe77':     "\u0640\u064e",
+	'\ufe78':     " \u064f",
+	'\ufe79':     "\u0640\u064f",
+	'\ufe7a':     " \u0650",
+	'\ufe7b':     "\u0640\u0650",
+	'\ufe7c':     " \u0651",
+	'\ufe7d':     "\u0640\u0651",
+	'\ufe7e':     " \u0652",
+	'\ufe7f':     "\u0640\u0652",
+	'\ufe80':     "\u0621",
+	'\ufe81':     "\u0622",
+	'\ufe82':     "\u0622",
+	'\ufe83':     "\u0623",
+	'\ufe84':     "\u0623",
+	'\ufe85':     "\u0624",
+	'\ufe86':     "\u0624",
+	'\ufe87':     "\u0625",
+	'\ufe88':     "\u0625",
+	'\ufe89':     "\u0626",
+	'\ufe8a':     "\u0626",
+	'\ufe8b':     "\u0626",
+	'\ufe8c':     "\u0626",
+	'\ufe8d':     "\u0627",
+	'\ufe8e':     "\u0627",
+	'\ufe8f':     "\u0628",
+	'\ufe90':     "\u0628",
+	'\ufe91':     "\u0628",
+	'\ufe92':     "\u0628",
+	'\ufe93':     "\u0629",
+	'\ufe94':     "\u0629",
+	'\ufe95':     "\u062a",
+	'\ufe96':     "\u062a",
+	'\ufe97':     "\u062a",
+	'\ufe98':     "\u062a",
+	'\ufe99':     "\u062b",
+	'\ufe9a':     "\u062b",
+	'\ufe9b':     "\u062b",
+	'\ufe9c':     "\u062b",
+	'\ufe9d':     "\u062c",
+	'\ufe9e':     "\u062c",
+	'\ufe9f':     "\u062c",
+	'\ufea0':     "\u062c",
+	'\ufea1':     "\u062d",
+	'\ufea2':     "\u062d",
+	'\ufea3':     "\u062d",
+	'\ufea4':     "\u062d",
+	'\ufea5':     "\u062e",
+	'\ufea6':     "\u062e",
+	'\ufea7':     "\u062e",
+	'\ufea8':     "\u062e",
+	'\ufea9':     "\u062f",
+	'\ufeaa':     "\u062f",
+	'\ufeab':     "\u0630",
+	'\ufeac':     "\u0630",
+	'\ufead':     "\u0631",
+	'\ufeae':     "\u0631",
+	'\ufeaf':     "\u0632",
+	'\ufeb0':     "\u0632",
+	'\ufeb1':     "\u0633",
+	'\ufeb2':     "\u0633",
+	'\ufeb3':     "\u0633",
+	'\ufeb4':     "\u0633",
+	'\ufeb5':     "\u0634",
+	'\ufeb6':     "\u0634",
+	'\ufeb7':     "\u0634",
+	'\ufeb8':     "\u0634",
+	'\ufeb9':     "\u0635",
+	'\ufeba':     "\u0635",
+	'\ufebb':     "\u0635",
+	'\ufebc':     "\u0635",
+	'\ufebd':     "\u0636",
+	'\ufebe':     "\u0636",
+	'\ufebf':     "\u0636",
+	'\ufec0':     "\u0636",
+	'\ufec1':     "\u0637",
+	'\ufec2':     "\u0637",
+	'\ufec3':     "\u0637",
+	'\ufec4':     "\u0637",
+	'\ufec5':     "\u0638",
+	'\ufec6':     "\u0638",
+	'\ufec7':     "\u0638",
+	'\ufec8':     "\u0638",
+	'\ufec9':     "\u0639",
+	'\ufeca':     "\u0639",
+	'\ufecb':     "\u0639",
+	'\ufecc':     "\u0639",
+	'\ufecd':     "\u063a",
+	'\ufece':     "\u063a",
+	'\ufecf':     "\u063a",
+	'\ufed0':     "\u063a",
+	'\ufed1':     "\u0641",
+	'\ufed2':     "\u0641",
+	'\ufed3':     "\u0641",
+	'\ufed4':     "\u0641",
+	'\ufed5':     "\u0642",
+	'\ufed6':     "\u0642",
+	'\ufed7':     "\u0642",
+	'\ufed8':     "\u0642",
+	'\ufed9':     "\u0643",
+	'\ufeda':     "\u0643",
+	'\ufedb':     "\u0643",
+	'\ufedc':     "\u0643",
+	'\ufedd':     "\u0644",
+	'\ufede':     "\u0644",
+	'\ufedf':     "\u0644",
+	'\ufee0':     "\u0644",
+	'\ufee1':     "\u0645",
+	'\ufee2':     "\u0645",
+	'\ufee3':     "\u0645",
+	'\ufee4':     "\u0645",
+	'\ufee5':     "\u0646",
+	'\ufee6':     "\u0646",
+	'\ufee7':     "\u0646",
+	'\ufee8':     "\u0646",
+	'\ufee9':     "\u0647",
+	'\ufeea':     "\u0647",
+	'\ufeeb':     "\u0647",
+	'\ufeec':     "\u0647",
+	'\ufeed':     "\u0648",
+	'\ufeee':     "\u0648",
+	'\ufeef':     "\u0649",
+	'\ufef0':     "\u0649",
+	'\ufef1':     "\u064a",
+	'\ufef2':     "\u064a",
+	'\ufef3':     "\u064a",
+	'\ufef4':     "\u064a",
+	'\ufef5':     "\u0644\u0622",
+	'\ufef6':     "\u0644\u0622",
+	'\ufef7':     "\u0644\u0623",
+	'\ufef8':     "\u0644\u0623",
+	'\ufef9':     "\u0644\u0625",
+	'\ufefa':     "\u0644\u0625",
+	'\ufefb':     "\u0644\u0627",
+	'\ufefc':     "\u0644\u0627",
+	'\uff01':     "!",
+	'\uff02':     "\"",
+	'\uff03':     "#",
+	'\uff04':     "$",
+	'\uff05':     "%",
+	'\uff06':     "&",
+	'\uff07':     "'",
+	'\uff08':     "(",
+	'\uff09':     ")",
+	'\uff0a':     "*",
+	'\uff0b':     "+",
+	'\uff0c':     ",",
+	'\uff0d':     "-",
+	'\uff0e':     ".",
+	'\uff0f':     "/",
+	'\uff10':     "0",
+	'\uff11':     "1",
+	'\uff12':     "2",
+	'\uff13':     "3",
+	'\uff14':     "4",
+	'\uff15':     "5",
+	'\uff16':     "6",
+	'\uff17':     "7",
+	'\uff18':     "8",
+	'\uff19':     "9",
+	'\uff1a':     ":",
+	'\uff1b':     ";",
+	'\uff1c':     "<",
+	'\uff1d':     "=",
+	'\uff1e':     ">",
+	'\uff1f':     "?",
+	'\uff20':     "@",
+	'\uff21':     "A",
+	'\uff22':     "B",
+	'\uff23':     "C",
+	'\uff24':     "D",
+	'\uff25':     "E",
+	'\uff26':     "F",
+	'\uff27':     "G",
+	'\uff28':     "H",
+	'\uff29':     "I",
+	'\uff2a':     "J",
+	'\uff2b':     "K",
+	'\uff2c':     "L",
+	'\uff2d':     "M",
+	'\uff2e':     "N",
+	'\uff2f':     "O",
+	'\uff30':     "P",
+	'\uff31':     "Q",
+	'\uff32':     "R",
+	'\uff33':     "S",
+	'\uff34':     "T",
+	'\uff35':     "U",
+	'\uff36':     "V",
+	'\uff37':     "W",
+	'\uff38':     "X",
+	'\uff39':     "Y",
+	'\uff3a':     "Z",
+	'\uff3b':     "[",
+	'\uff3c':     "\\",
+	'\uff3d':     "]",
+	'\uff3e':     "^",
+	'\uff3f':     "_",
+	'\uff40':     "`",
+	'\uff41':     "a",
+	'\uff42':     "b",
+	'\uff43':     "c",
+	'\uff44':     "d",
+	'\uff45':     "e",
+	'\uff46':     "f",
+	'\uff47':     "g",
+	'\uff48':     "h",
+	'\uff49':     "i",
+	'\uff4a':     "j",
+	'\uff4b':     "k",
+	'\uff4c':     "l",
+	'\uff4d':     "m",
+	'\uff4e':     "n",
+	'\uff4f':     "o",
+	'\uff50':     "p",
+	'\uff51':     "q",
+	'\uff52':     "r",
+	'\uff53':     "s",
+	'\uff54':     "t",
+	'\uff55':     "u",
+	'\uff56':     "v",
+	'\uff57':     "w",
+	'\uff58':     "x",
+	'\uff59':     "y",
+	'\uff5a':     "z",
+	'\uff5b':     "{",
+	'\uff5c':     "|",
+	'\uff5d':     "}",
+	'\uff5e':     "~",
+	'\uff5f':     "\u2985",
+	'\uff60':     "\u2986",
+	'\uff61':     "\u3002",
+	'\uff62':     "\u300c",
+	'\uff63':     "\u300d",
+	'\uff64':     "\u3001",
+	'\uff65':     "\u30fb",
+	'\uff66':     "\u30f2",
+	'\uff67':     "\u30a1",
+	'\uff68':     "\u30a3",
+	'\uff69':     "\u30a5",
+	'\uff6a':     "\u30a7",
+	'\uff6b':     "\u30a9",
+	'\uff6c':     "\u30e3",
+	'\uff6d':     "\u30e5",
+	'\uff6e':     "\u30e7",
+	'\uff6f':     "\u30c3",
+	'\uff70':     "\u30fc",
+	'\uff71':     "\u30a2",
+	'\uff72':     "\u30a4",
+	'\uff73':     "\u30a6",
+	'\uff74':     "\u30a8",
+	'\uff75':     "\u30aa",
+	'\uff76':     "\u30ab",
+	'\uff77':     "\u30ad",
+	'\uff78':     "\u30af",
+	'\uff79':     "\u30b1",
+	'\uff7a':     "\u30b3",
+	'\uff7b':     "\u30b5",
+	'\uff7c':     "\u30b7",
+	'\uff7d':     "\u30b9",
+	'\uff7e':     "\u30bb",
+	'\uff7f':     "\u30bd",
+	'\uff80':     "\u30bf",
+	'\uff81':     "\u30c1",
+	'\uff82':     "\u30c4",
+	'\uff83':     "\u30c6",
+	'\uff84':     "\u30c8",
+	'\uff85':     "\u30ca",
+	'\uff86':     "\u30cb",
+	'\uff87':     "\u30cc",
+	'\uff88':     "\u30cd",
+	'\uff89':     "\u30ce",
+	'\uff8a':     "\u30cf",
+	'\uff8b':     "\u30d2",
+	'\uff8c':     "\u30d5",
+	'\uff8d':     "\u30d8",
+	'\uff8e':     "\u30db",
+	'\uff8f':     "\u30de",
+	'\uff90':     "\u30df",
+	'\uff91':     "\u30e0",
+	'\uff92':     "\u30e1",
+	'\uff93':     "\u30e2",
+	'\uff94':     "\u30e4",
+	'\uff95':     "\u30e6",
+	'\uff96':     "\u30e8",
+	'\uff97':     "\u30e9",
+	'\uff98':     "\u30ea",
+	'\uff99':     "\u30eb",
+	'\uff9a':     "\u30ec",
+	'\uff9b':     "\u30ed",
+	'\uff9c':     "\u30ef",
+	'\uff9d':     "\u30f3",
+	'\uff9e':     "\u3099",
+	'\uff9f':     "\u309a",
+	'\uffa0':     "\u3164",
+	'\uffa1':     "\u3131",
+	'\uffa2':     "\u3132",
+	'\uffa3':     "\u3133",
+	'\uffa4':     "\u3134",
+	'\uffa5':     "\u3135",
+	'\uffa6':     "\u3136",
+	'\uffa7':     "\u3137",
+	'\uffa8':     "\u3138",
+	'\uffa9':     "\u3139",
+	'\uffaa':     "\u313a",
+	'\uffab':     "\u313b",
+	'\uffac':     "\u313c",
+	'\uffad':     "\u313d",
+	'\uffae':     "\u313e",
+	'\uffaf':     "\u313f",
+	'\uffb0':     "\u3140",
+	'\uffb1':     "\u3141",
+	'\uffb2':     "\u3142",
+	'\uffb3':     "\u3143",
+	'\uffb4':     "\u3144",
+	'\uffb5':     "\u3145",
+	'\uffb6':     "\u3146",
+	'\uffb7':     "\u3147",
+	'\uffb8':     "\u3148",
+	'\uffb9':     "\u3149",
+	'\uffba':     "\u314a",
+	'\uffbb':     "\u314b",
+	'\uffbc':     "\u314c",
+	'\uffbd':     "\u314d",
+	'\uffbe':     "\u314e",
+	'\uffc2':     "\u314f",
+	'\uffc3':     "\u3150",
+	'\uffc4':     "\u3151",
+	'\uffc5':     "\u3152",
+	'\uffc6':     "\u3153",
+	'\uffc7':     "\u3154",
+	'\uffca':     "\u3155",
+	'\uffcb':     "\u3156",
+	'\uffcc':     "\u3157",
+	'\uffcd':     "\u3158",
+	'\uffce':     "\u3159",
+	'\uffcf':     "\u315a",
+	'\uffd2':     "\u315b",
+	'\uffd3':     "\u315c",
+	'\uffd4':     "\u315d",
+	'\uffd5':     "\u315e",
+	'\uffd6':     "\u315f",
+	'\uffd7':     "\u3160",
+	'\uffda':     "\u3161",
+	'\uffdb':     "\u3162",
+	'\uffdc':     "\u3163",
+	'\uffe0':     "\u00a2",
+	'\uffe1':     "\u00a3",
+	'\uffe2':     "\u00ac",
+	'\uffe3':     "\u00af",
+	'\uffe4':     "\u00a6",
+	'\uffe5':     "\u00a5",
+	'\uffe6':     "\u20a9",
+	'\uffe8':     "\u2502",
+	'\uffe9':     "\u2190",
+	'\uffea':     "\u2191",
+	'\uffeb':     "\u2192",
+	'\uffec':     "\u2193",
+	'\uffed':     "\u25a0",
+	'\uffee':     "\u25cb",
+	'\U00010781': "\u02d0",
+	'\U00010782': "\u02d1",
+	'\U00010783': "\u00e6",
+	'\U00010784': "\u0299",
+	'\U00010785': "\u0253",
+	'\U00010787': "\u02a3",
+	'\U00010788': "\uab66",
+	'\U00010789': "\u02a5",
+	'\U0001078a': "\u02a4",
+	'\U0001078b': "\u0256",
+	'\U0001078c': "\u0257",
+	'\U0001078d': "\u1d91",
+	'\U0001078e': "\u0258",
+	'\U0001078f': "\u025e",
+	'\U00010790': "\u02a9",
+	'\U00010791': "\u0264",
+	'\U00010792': "\u0262",
+	'\U00010793': "\u0260",
+	'\U00010794': "\u029b",
+	'\U00010795': "\u0127",
+	'\U00010796': "\u029c",
+	'\U00010797': "\u0267",
+	'\U00010798': "\u0284",
+	'\U00010799': "\u02aa",
+	'\U0001079a': "\u02ab",
+	'\U0001079b': "\u026c",
+	'\U0001079c': "\U0001df04",
+	'\U0001079d': "\ua78e",
+	'\U0001079e': "\u026e",
+	'\U0001079f': "\U0001df05",
+	'\U000107a0': "\u028e",
+	'\U000107a1': "\U0001df06",
+	'\U000107a2': "\u00f8",
+	'\U000107a3': "\u0276",
+	'\U000107a4': "\u0277",
+	'\U000107a5': "q",
+	'\U000107a6': "\u027a",
+	'\U000107a7': "\U0001df08",
+	'\U000107a8': "\u027d",
+	'\U000107a9': "\u027e",
+	'\U000107aa': "\u0280",
+	'\U000107ab': "\u02a8",
+	'\U000107ac': "\u02a6",
+	'\U000107ad': "\uab67",
+	'\U000107ae': "\u02a7",
+	'\U000107af': "\u0288",
+	'\U000107b0': "\u2c71",
+	'\U000107b2': "\u028f",
+	'\U000107b3': "\u02a1",
+	'\U000107b4': "\u02a2",
+	'\U000107b5': "\u0298",
+	'\U000107b6': "\u01c0",
+	'\U000107b7': "\u01c1",
+	'\U000107b8': "\u01c2",
+	'\U000107b9': "\U0001df0a",
+	'\U000107ba': "\U0001df1e",
+	'\U0001d400': "A",
+	'\U0001d401': "B",
+	'\U0001d402': "C",
+	'\U0001d403': "D",
+	'\U0001d404': "E",
+	'\U0001d405': "F",
+	'\U0001d406': "G",
+	'\U0001d407': "H",
+	'\U0001d408': "I",
+	'\U0001d409': "J",
+	'\U0001d40a': "K",
+	'\U0001d40b': "L",
+	'\U0001d40c': "M",
+	'\U0001d40d': "N",
+	'\U0001d40e': "O",
+	'\U0001d40f': "P",
+	'\U0001d410': "Q",
+	'\U0001d411': "R",
+	'\U0001d412': "S",
+	'\U0001d413': "T",
+	'\U0001d414': "U",
+	'\U0001d415': "V",
+	'\U0001d416': "W",
+	'\U0001d417': "X",
+	'\U0001d418': "Y",
+	'\U0001d419': "Z",
+	'\U0001d41a': "a",
+	'\U0001d41b': "b",
+	'\U0001d41c': "c",
+	'\U0001d41d': "d",
+	'\U0001d41e': "e",
+	'\U0001d41f': "f",
+	'\U0001d420': "g",
+	'\U0001d421': "h",
+	'\U0001d422': "i",
+	'\U0001d423': "j",
+	'\U0001d424': "k",
+	'\U0001d425': "l",
+	'\U0001d426': "m",
+	'\U0001d427': "n",
+	'\U0001d428': "o",
+	'\U0001d429': "p",
+	'\U0001d42a': "q",
+	'\U0001d42b': "r",
+	'\U0001d42c': "s",
+	'\U0001d42d': "t",
+	'\U0001d42e': "u",
+	'\U0001d42f': "v",
+	'\U0001d430': "w",
+	'\U0001d431': "x",
+	'\U0001d432': "y",
+	'\U0001d433': "z",
+	'\U0001d434': "A",
+	'\U0001d435': "B",
+	'\U0001d436': "C",
+	'\U0001d437': "D",
+	'\U0001d438': "E",
+	'\U0001d439': "F",
+	'\U0001d43a': "G",
+	'\U0001d43b': "H",
+	'\U0001d43c': "I",
+	'\U0001d43d': "J",
+	'\U0001d43e': "K",
+	'\U0001d43f': "L",
+	'\U0001d440': "M",
+	'\U0001d441': "N",
+	'\U0001d442': "O",
+	'\U0001d443': "P",
+	'\U0001d444': "Q",
+	'\U0001d445': "R",
+	'\U0001d446': "S",
+	'\U0001d447': "T",
+	'\U0001d448': "U",
+	'\U0001d449': "V",
+	'\U0001d44a': "W",
+	'\U0001d44b': "X",
+	'\U0001d44c': "Y",
+	'\U0001d44d': "Z",
+	'\U0001d44e': "a",
+	'\U0001d44f': "b",
+	'\U0001d450': "c",
+	'\U0001d451': "d",
+	'\U0001d452': "e",
+	'\U0001d453': "f",
+	'\U0001d454': "g",
+	'\U0001d456': "i",
+	'\U0001d457': "j",
+	'\U0001d458': "k",
+	'\U0001d459': "l",
+	'\U0001d45a': "m",
+	'\U0001d45b': "n",
+	'\U0001d45c': "o",
+	'\U0001d45d': "p",
+	'\U0001d45e': "q",
+	'\U0001d45f': "r",
+	'\U0001d460': "s",
+	'\U0001d461': "t",
+	'\U0001d462': "u",
+	'\U0001d463': "v",
+	'\U0001d464': "w",
+	'\U0001d465': "x",
+	'\U0001d466': "y",
+	'\U0001d467': "z",
+	'\U0001d468': "A",
+	'\U0001d469': "B",
+	'\U0001d46a': "C",
+	'\U0001d46b': "D",
+	'\U0001d46c': "E",
+	'\U0001d46d': "F",
+	'\U0001d46e': "G",
+	'\U0001d46f': "H",
+	'\U0001d470': "I",
+	'\U0001d471': "J",
+	'\U0001d472': "K",
+	'\U0001d473': "L",
+	'\U0001d474': "M",
+	'\U0001d475': "N",
+	'\U0001d476': "O",
+	'\U0001d477': "P",
+	'\U0001d478': "Q",
+	'\U0001d479': "R",
+	'\U0001d47a': "S",
+	'\U0001d47b': "T",
+	'\U0001d47c': "U",
+	'\U0001d47d': "V",
+	'\U0001d47e': "W",
+	'\U0001d47f': "X",
+	'\U0001d480': "Y",
+	'\U0001d481': "Z",
+	'\U0001d482': "a",
+	'\U0001d483': "b",
+	'\U0001d484': "c",
+	'\U0001d485': "d",
+	'\U0001d486': "e",
+	'\U0001d487': "f",
+	'\U0001d488': "g",
+	'\U0001d489': "h",
+	'\U0001d48a': "i",
+	'\U0001d48b': "j",
+	'\U0001d48c': "k",
+	'\U0001d48d': "l",
+	'\U0001d48e': "m",
+	'\U0001d48f': "n",
+	'\U0001d490': "o",
+	'\U0001d491': "p",
+	'\U0001d492': "q",
+	'\U0001d493': "r",
+	'\U0001d494': "s",
+	'\U0001d495': "t",
+	'\U0001d496': "u",
+	'\U0001d497': "v",
+	'\U0001d498': "w",
+	'\U0001d499': "x",
+	'\U0001d49a': "y",
+	'\U0001d49b': "z",
+	'\U0001d49c': "A",
+	'\U0001d49e': "C",
+	'\U0001d49f': "D",
+	'\U0001d4a2': "G",
+	'\U0001d4a5': "J",
+	'\U0001d4a6': "K",
+	'\U0001d4a9': "N",
+	'\U0001d4aa': "O",
+	'\U0001d4ab': "P",
+	'\U0001d4ac': "Q",
+	'\U0001d4ae': "S",
+	'\U0001d4af': "T",
+	'\U0001d4b0': "U",
+	'\U0001d4b1': "V",
+	'\U0001d4b2': "W",
+	'\U0001d4b3': "X",
+	'\U0001d4b4': "Y",
+	'\U0001d4b5': "Z",
+	'\U0001d4b6': "a",
+	'\U0001d4b7': "b",
+	'\U0001d4b8': "c",
+	'\U0001d4b9': "d",
+	'\U0001d4bb': "f",
+	'\U0001d4bd': "h",
+	'\U0001d4be': "i",
+	'\U0001d4bf': "j",
+	'\U0001d4c0': "k",
+	'\U0001d4c1': "l",
+	'\U0001d4c2': "m",
+	'\U0001d4c3': "n",
+	'\U0001d4c5': "p",
+	'\U0001d4c6': "q",
+	'\U0001d4c7': "r",
+	'\U0001d4c8': "s",
+	'\U0001d4c9': "t",
+	'\U0001d4ca': "u",
+	'\U0001d4cb': "v",
+	'\U0001d4cc': "w",
+	'\U0001d4cd': "x",
+	'\U0001d4ce': "y",
+	'\U0001d4cf': "z",
+	'\U0001d4d0': "A",
+	'\U0001d4d1': "B",
+	'\U0001d4d2': "C",
+	'\U0001d4d3': "D",
+	'\U0001d4d4': "E",
+	'\U0001d4d5': "F",
+	'\U0001d4d6': "G",
+	'\U0001d4d7': "H",
+	'\U0001d4d8': "I",
+	'\U0001d4d9': "J",
+	'\U0001d4da': "K",
+	'\U0001d4db': "L",
+	'\U0001d4dc': "M",
+	'\U0001d4dd': "N",
+	'\U0001d4de': "O",
+	'\U0001d4df': "P",
+	'\U0001d4e0': "Q",
+	'\U0001d4e1': "R",
+	'\U0001d4e2': "S",
+	'\U0001d4e3': "T",
+	'\U0001d4e4': "U",
+	'\U0001d4e5': "V",
+	'\U0001d4e6': "W",
+	'\U0001d4e7': "X",
+	'\U0001d4e8': "Y",
+	'\U0001d4e9': "Z",
+	'\U0001d4ea': "a",
+	'\U0001d4eb': "b",
+	'\U0001d4ec': "c",
+	'\U0001d4ed': "d",
+	'\U0001d4ee': "e",
+	'\U0001d4ef': "f",
+	'\U0001d4f0': "g",
+	'\U0001d4f1': "h",
+	'\U0001d4f2': "i",
+	'\U0001d4f3': "j",
+	'\U0001d4f4': "k",
+	'\U0001d4f5': "l",
+	'\U0001d4f6': "m",
+	'\U0001d4f7': "n",
+	'\U0001d4f8': "o",
+	'\U0001d4f9': "p",
+	'\U0001d4fa': "q",
+	'\U0001d4fb': "r",
+	'\U0001d4fc': "s",
+	'\U0001d4fd': "t",
+	'\U0001d4fe': "u",
+	'\U0001d4ff': "v",
+	'\U0001d500': "w",
+	'\U0001d501': "x",
+	'\U0001d502': "y",
+	'\U0001d503': "z",
+	'\U0001d504': "A",
+	'\U0001d505': "B",
+	'\U0001d507': "D",
+	'\U0001d508': "E",
+	'\U0001d509': "F",
+	'\U0001d50a': "G",
+	'\U0001d50d': "J",
+	'\U0001d50e': "K",
+	'\U0001d50f': "L",
+	'\U0001d510': "M",
+	'\U0001d511': "N",
+	'\U0001d512': "O",
+	'\U0001d513': "P",
+	'\U0001d514': "Q",
+	'\U0001d516': "S",
+	'\U0001d517': "T",
+	'\U0001d518': "U",
+	'\U0001d519': "V",
+	'\U0001d51a': "W",
+	'\U0001d51b': "X",
+	'\U0001d51c': "Y",
+	'\U0001d51e': "a",
+	'\U0001d51f': "b",
+	'\U0001d520': "c",
+	'\U0001d521': "d",
+	'\U0001d522': "e",
+	'\U0001d523': "f",
+	'\U0001d524': "g",
+	'\U0001d525': "h",
+	'\U0001d526': "i",
+	'\U0001d527': "j",
+	'\U0001d528': "k",
+	'\U0001d529': "l",
+	'\U0001d52a': "m",
+	'\U0001d52b': "n",
+	'\U0001d52c': "o",
+	'\U0001d52d': "p",
+	'\U0001d52e': "q",
+	'\U0001d52f': "r",
+	'\U0001d530': "s",
+	'\U0001d531': "t",
+	'\U0001d532': "u",
+	'\U0001d533': "v",
+	'\U0001d534': "w",
+	'\U0001d535': "x",
+	'\U0001d536': "y",
+	'\U0001d537': "z",
+	'\U0001d538': "A",
+	'\U0001d539': "B",
+	'\U0001d53b': "D",
+	'\U0001d53c': "E",
+	'\U0001d53d': "F",
+	'\U0001d53e': "G",
+	'\U0001d540': "I",
+	'\U0001d541': "J",
+	'\U0001d542': "K",
+	'\U0001d543': "L",
+	'\U0001d544': "M",
+	'\U0001d546': "O",
+	'\U0001d54a': "S",
+	'\U0001d54b': "T",
+	'\U0001d54c': "U",
+	'\U0001d54d': "V",
+	'\U0001d54e': "W",
+	'\U0001d54f': "X",
+	'\U0001d550': "Y",
+	'\U0001d552': "a",
+	'\U0001d553': "b",
+	'\U0001d554': "c",
+	'\U0001d555': "d",
+	'\U0001d556': "e",
+	'\U0001d557': "f",
+	'\U0001d558': "g",
+	'\U0001d559': "h",
+	'\U0001d55a': "i",
+	'\U0001d55b': "j",
+	'\U0001d55c': "k",
+	'\U0001d55d': "l",
+	'\U0001d55e': "m",
+	'\U0001d55f': "n",
+	'\U0001d560': "o",
+	'\U0001d561': "p",
+	'\U0001d562': "q",
+	'\U0001d563': "r",
+	'\U0001d564': "s",
+	'\U0001d565': "t",
+	'\U0001d566': "u",
+	'\U0001d567': "v",
+	'\U0001d568': "w",
+	'\U0001d569': "x",
+	'\U0001d56a': "y",
+	'\U0001d56b': "z",
+	'\U0001d56c': "A",
+	'\U0001d56d': "B",
+	'\U0001d56e': "C",
+	'\U0001d56f': "D",
+	'\U0001d570': "E",
+	'\U0001d571': "F",
+	'\U0001d572': "G",
+	'\U0001d573': "H",
+	'\U0001d574': "I",
+	'\U0001d575': "J",
+	'\U0001d576': "K",
+	'\U0001d577': "L",
+	'\U0001d578': "M",
+	'\U0001d579': "N",
+	'\U0001d57a': "O",
+	'\U0001d57b': "P",
+	'\U0001d57c': "Q",
+	'\U0001d57d': "R",
+	'\U0001d57e': "S",
+	'\U0001d57f': "T",
+	'\U0001d580': "U",
+	'\U0001d581': "V",
+	'\U0001d582': "W",
+	'\U0001d583': "X",
+	'\U0001d584': "Y",
+	'\U0001d585': "Z",
+	'\U0001d586': "a",
+	'\U0001d587': "b",
+	'\U0001d588': "c",
+	'\U0001d589': "d",
+	'\U0001d58a': "e",
+	'\U0001d58b': "f",
+	'\U0001d58c': "g",
+	'\U0001d58d': "h",
+	'\U0001d58e': "i",
+	'\U0001d58f': "j",
+	'\U0001d590': "k",
+	'\U0001d591': "l",
+	'\U0001d592': "m",
+	'\U0001d593': "n",
+	'\U0001d594': "o",
+	'\U0001d595': "p",
+	'\U0001d596': "q",
+	'\U0001d597': "r",
+	'\U0001d598': "s",
+	'\U0001d599': "t",
+	'\U0001d59a': "u",
+	'\U0001d59b': "v",
+	'\U0001d59c': "w",
+	'\U0001d59d': "x",
+	'\U0001d59e': "y",
+	'\U0001d59f': "z",
+	'\U0001d5a0': "A",
+	'\U0001d5a1': "B",
+	'\U0001d5a2': "C",
+	'\U0001d5a3': "D",
+	'\U0001d5a4': "E",
+	'\U0001d5a5': "F",
+	'\U0001d5a6': "G",
+	'\U0001d5a7': "H",
+	'\U0001d5a8': "I",
+	'\U0001d5a9': "J",
+	'\U0001d5aa': "K",
+	'\U0001d5ab': "L",
+	'\U0001d5ac': "M",
+	'\U0001d5ad': "N",
+	'\U0001d5ae': "O",
+	'\U0001d5af': "P",
+	'\U0001d5b0': "Q",
+	'\U0001d5b1': "R",
+	'\U0001d5b2': "S",
+	'\U0001d5b3': "T",
+	'\U0001d5b4': "U",
+	'\U0001d5b5': "V",
+	'\U0001d5b6': "W",
+	'\U0001d5b7': "X",
+	'\U0001d5b8': "Y",
+	'\U0001d5b9': "Z",
+	'\U0001d5ba': "a",
+	'\U0001d5bb': "b",
+	'\U0001d5bc': "c",
+	'\U0001d5bd': "d",
+	'\U0001d5be': "e",
+	'\U0001d5bf': "f",
+	'\U0001d5c0': "g",
+	'\U0001d5c1': "h",
+	'\U0001d5c2': "i",
+	'\U0001d5c3': "j",
+	'\U0001d5c4': "k",
+	'\U0001d5c5': "l",
+	'\U0001d5c6': "m",
+	'\U0001d5c7': "n",
+	'\U0001d5c8': "o",
+	'\U0001d5c9': "p",
+	'\U0001d5ca': "q",
+	'\U0001d5cb': "r",
+	'\U0001d5cc': "s",
+	'\U0001d5cd': "t",
+	'\U0001d5ce': "u",
+	'\U0001d5cf': "v",
+	'\U0001d5d0': "w",
+	'\U0001d5d1': "x",
+	'\U0001d5d2': "y",
+	'\U0001d5d3': "z",
+	'\U0001d5d4': "A",
+	'\U0001d5d5': "B",
+	'\U0001d5d6': "C",
+	'\U0001d5d7': "D",
+	'\U0001d5d8': "E",
+	'\U0001d5d9': "F",
+	'\U0001d5da': "G",
+	'\U0001d5db': "H",
+	'\U0001d5dc': "I",
+	'\U0001d5dd': "J",
+	'\U0001d5de': "K",
+	'\U0001d5df': "L",
+	'\U0001d5e0': "M",
+	'\U0001d5e1': "N",
+	'\U0001d5e2': "O",
+	'\U0001d5e3': "P",
+	'\U0001d5e4': "Q",
+	'\U0001d5e5': "R",
+	'\U0001d5e6': "S",
+	'\U0001d5e7': "T",
+	'\U0001d5e8': "U",
+	'\U0001d5e9': "V",
+	'\U0001d5ea': "W",
+	'\U0001d5eb': "X",
+	'\U0001d5ec': "Y",
+	'\U0001d5ed': "Z",
+	'\U0001d5ee': "a",
+	'\U0001d5ef': "b",
+	'\U0001d5f0': "c",
+	'\U0001d5f1': "d",
+	'\U0001d5f2': "e",
+	'\U0001d5f3': "f",
+	'\U0001d5f4': "g",
+	'\U0001d5f5': "h",
+	'\U0001d5f6': "i",
+	'\U0001d5f7': "j",
+	'\U0001d5f8': "k",
+	'\U0001d5f9': "l",
+	'\U0001d5fa': "m",
+	'\U0001d5fb': "n",
+	'\U0001d5fc': "o",
+	'\U0001d5fd': "p",
+	'\U0001d5fe': "q",
+	'\U0001d5ff': "r",
+	'\U0001d600': "s",
+	'\U0001d601': "t",
+	'\U0001d602': "u",
+	'\U0001d603': "v",
+	'\U0001d604': "w",
+	'\U0001d605': "x",
+	'\U0001d606': "y",
+	'\U0001d607': "z",
+	'\U0001d608': "A",
+	'\U0001d609': "B",
+	'\U0001d60a': "C",
+	'\U0001d60b': "D",
+	'\U0001d60c': "E",
+	'\U0001d60d': "F",
+	'\U0001d60e': "G",
+	'\U0001d60f': "H",
+	'\U0001d610': "I",
+	'\U0001d611': "J",
+	'\U0001d612': "K",
+	'\U0001d613': "L",
+	'\U0001d614': "M",
+	'\U0001d615': "N",
+	'\U0001d616': "O",
+	'\U0001d617': "P",
+	'\U0001d618': "Q",
+	'\U0001d619': "R",
+	'\U0001d61a': "S",
+	'\U0001d61b': "T",
+	'\U0001d61c': "U",
+	'\U0001d61d': "V",
+	'\U0001d61e': "W",
+	'\U0001d61f': "X",
+	'\U0001d620': "Y",
+	'\U0001d621': "Z",
+	'\U0001d622': "a",
+	'\U0001d623': "b",
+	'\U0001d624': "c",
+	'\U0001d625': "d",
+	'\U0001d626': "e",
+	'\U0001d627': "f",
+	'\U0001d628': "g",
+	'\U0001d629': "h",
+	'\U0001d62a': "i",
+	'\U0001d62b': "j",
+	'\U0001d62c': "k",
+	'\U0001d62d': "l",
+	'\U0001d62e': "m",
+	'\U0001d62f': "n",
+	'\U0001d630': "o",
+	'\U0001d631': "p",
+	'\U0001d632': "q",
+	'\U0001d633': "r",
+	'\U0001d634': "s",
+	'\U0001d635': "t",
+	'\U0001d636': "u",
+	'\U0001d637': "v",
+	'\U0001d638': "w",
+	'\U0001d639': "x",
+	'\U0001d63a': "y",
+	'\U0001d63b': "z",
+	'\U0001d63c': "A",
+	'\U0001d63d': "B",
+	'\U0001d63e': "C",
+	'\U0001d63f': "D",
+	'\U0001d640': "E",
+	'\U0001d641': "F",
+	'\U0001d642': "G",
+	'\U0001d643': "H",
+	'\U0001d644': "I",
+	'\U0001d645': "J",
+	'\U0001d646': "K",
+	'\U0001d647': "L",
+	'\U0001d648': "M",
+	'\U0001d649': "N",
+	'\U0001d64a': "O",
+	'\U0001d64b': "P",
+	'\U0001d64c': "Q",
+	'\U0001d64d': "R",
+	'\U0001d64e': "S",
+	'\U0001d64f': "T",
+	'\U0001d650': "U",
+	'\U0001d651': "V",
+	'\U0001d652': "W",
+	'\U0001d653': "X",
+	'\U0001d654': "Y",
+	'\U0001d655': "Z",
+	'\U0001d656': "a",
+	'\U0001d657': "b",
+	'\U0001d658': "c",
+	'\U0001d659': "d",
+	'\U0001d65a': "e",
+	'\U0001d65b': "f",
+	'\U0001d65c': "g",
+	'\U0001d65d': "h",
+	'\U0001d65e': "i",
+	'\U0001d65f': "j",
+	'\U0001d660': "k",
+	'\U0001d661': "l",
+	'\U0001d662': "m",
+	'\U0001d663': "n",
+	'\U0001d664': "o",
+	'\U0001d665': "p",
+	'\U0001d666': "q",
+	'\U0001d667': "r",
+	'\U0001d668': "s",
+	'\U0001d669': "t",
+	'\U0001d66a': "u",
+	'\U0001d66b': "v",
+	'\U0001d66c': "w",
+	'\U0001d66d': "x",
+	'\U0001d66e': "y",
+	'\U0001d66f': "z",
+	'\U0001d670': "A",
+	'\U0001d671': "B",
+	'\U0001d672': "C",
+	'\U0001d673': "D",
+	'\U0001d674': "E",
+	'\U0001d675': "F",
+	'\U0001d676': "G",
+	'\U0001d677': "H",
+	'\U0001d678': "I",
+	'\U0001d679': "J",
+	'\U0001d67a': "K",
+	'\U0001d67b': "L",
+	'\U0001d67c': "M",
+	'\U0001d67d': "N",
+	'\U0001d67e': "O",
+	'\U0001d67f': "P",
+	'\U0001d680': "Q",
+	'\U0001d681': "R",
+	'\U0001d682': "S",
+	'\U0001d683': "T",
+	'\U0001d684': "U",
+	'\U0001d685': "V",
+	'\U0001d686': "W",
+	'\U0001d687': "X",
+	'\U0001d688': "Y",
+	'\U0001d689': "Z",
+	'\U0001d68a': "a",
+	'\U0001d68b': "b",
+	'\U0001d68c': "c",
+	'\U0001d68d': "d",
+	'\U0001d68e': "e",
+	'\U0001d68f': "f",
+	'\U0001d690': "g",
+	'\U0001d691': "h",
+	'\U0001d692': "i",
+	'\U0001d693': "j",
+	'\U0001d694': "k",
+	'\U0001d695': "l",
+	'\U0001d696': "m",
+	'\U0001d697': "n",
+	'\U0001d698': "o",
+	'\U0001d699': "p",
+	'\U0001d69a': "q",
+	'\U0001d69b': "r",
+	'\U0001d69c': "s",
+	'\U0001d69d': "t",
+	'\U0001d69e': "u",
+	'\U0001d69f': "v",
+	'\U0001d6a0': "w",
+	'\U0001d6a1': "x",
+	'\U0001d6a2': "y",
+	'\U0001d6a3': "z",
+	'\U0001d6a4': "\u0131",
+	'\U0001d6a5': "\u0237",
+	'\U0001d6a8': "\u0391",
+	'\U0001d6a9': "\u0392",
+	'\U0001d6aa': "\u0393",
+	'\U0001d6ab': "\u0394",
+	'\U0001d6ac': "\u0395",
+	'\U0001d6ad': "\u0396",
+	'\U0001d6ae': "\u0397",
+	'\U0001d6af': "\u0398",
+	'\U0001d6b0': "\u0399",
+	'\U0001d6b1': "\u039a",
+	'\U0001d6b2': "\u039b",
+	'\U0001d6b3': "\u039c",
+	'\U0001d6b4': "\u039d",
+	'\U0001d6b5': "\u039e",
+	'\U0001d6b6': "\u039f",
+	'\U0001d6b7': "\u03a0",
+	'\U0001d6b8': "\u03a1",
+	'\U0001d6b9': "\u03f4",
+	'\U0001d6ba': "\u03a3",
+	'\U0001d6bb': "\u03a4",
+	'\U0001d6bc': "\u03a5",
+	'\U0001d6bd': "\u03a6",
+	'\U0001d6be': "\u03a7",
+	'\U0001d6bf': "\u03a8",
+	'\U0001d6c0': "\u03a9",
+	'\U0001d6c1': "\u2207",
+	'\U0001d6c2': "\u03b1",
+	'\U0001d6c3': "\u03b2",
+	'\U0001d6c4': "\u03b3",
+	'\U0001d6c5': "\u03b4",
+	'\U0001d6c6': "\u03b5",
+	'\U0001d6c7': "\u03b6",
+	'\U0001d6c8': "\u03b7",
+	'\U0001d6c9': "\u03b8",
+	'\U0001d6ca': "\u03b9",
+	'\U0001d6cb': "\u03ba",
+	'\U0001d6cc': "\u03bb",
+	'\U0001d6cd': "\u03bc",
+	'\U0001d6ce': "\u03bd",
+	'\U0001d6cf': "\u03be",
+	'\U0001d6d0': "\u03bf",
+	'\U0001d6d1': "\u03c0",
+	'\U0001d6d2': "\u03c1",
+	'\U0001d6d3': "\u03c2",
+	'\U0001d6d4': "\u03c3",
+	'\U0001d6d5': "\u03c4",
+	'\U0001d6d6': "\u03c5",
+	'\U0001d6d7': "\u03c6",
+	'\U0001d6d8': "\u03c7",
+	'\U0001d6d9': "\u03c8",
+	'\U0001d6da': "\u03c9",
+	'\U0001d6db': "\u2202",
+	'\U0001d6dc': "\u03f5",
+	'\U0001d6dd': "\u03d1",
+	'\U0001d6de': "\u03f0",
+	'\U0001d6df': "\u03d5",
+	'\U0001d6e0': "\u03f1",
+	'\U0001d6e1': "\u03d6",
+	'\U0001d6e2': "\u0391",
+	'\U0001d6e3': "\u0392",
+	'\U0001d6e4': "\u0393",
+	'\U0001d6e5': "\u0394",
+	'\U0001d6e6': "\u0395",
+	'\U0001d6e7': "\u0396",
+	'\U0001d6e8': "\u0397",
+	'\U0001d6e9': "\u0398",
+	'\U0001d6ea': "\u0399",
+	'\U0001d6eb': "\u039a",
+	'\U0001d6ec': "\u039b",
+	'\U0001d6ed': "\u039c",
+	'\U0001d6ee': "\u039d",
+	'\U0001d6ef': "\u039e",
+	'\U0001d6f0': "\u039f",
+	'\U0001d6f1': "\u03a0",
+	'\U0001d6f2': "\u03a1",
+	'\U0001d6f3': "\u03f4",
+	'\U0001d6f4': "\u03a3",
+	'\U0001d6f5': "\u03a4",
+	'\U0001d6f6': "\u03a5",
+	'\U0001d6f7': "\u03a6",
+	'\U0001d6f8': "\u03a7",
+	'\U0001d6f9': "\u03a8",
+	'\U0001d6fa': "\u03a9",
+	'\U0001d6fb': "\u2207",
+	'\U0001d6fc': "\u03b1",
+	'\U0001d6fd': "\u03b2",
+	'\U0001d6fe': "\u03b3",
+	'\U0001d6ff': "\u03b4",
+	'\U0001d700': "\u03b5",
+	'\U0001d701': "\u03b6",
+	'\U0001d702': "\u03b7",
+	'\U0001d703': "\u03b8",
+	'\U0001d704': "\u03b9",
+	'\U0001d705': "\u03ba",
+	'\U0001d706': "\u03bb",
+	'\U0001d707': "\u03bc",
+	'\U0001d708': "\u03bd",
+	'\U0001d709': "\u03be",
+	'\U0001d70a': "\u03bf",
+	'\U0001d70b': "\u03c0",
+	'\U0001d70c': "\u03c1",
+	'\U0001d70d': "\u03c2",
+	'\U0001d70e': "\u03c3",
+	'\U0001d70f': "\u03c4",
+	'\U0001d710': "\u03c5",
+	'\U0001d711': "\u03c6",
+	'\U0001d712': "\u03c7",
+	'\U0001d713': "\u03c8",
+	'\U0001d714': "\u03c9",
+	'\U0001d715': "\u2202",
+	'\U0001d716': "\u03f5",
+	'\U0001d717': "\u03d1",
+	'\U0001d718': "\u03f0",
+	'\U0001d719': "\u03d5",
+	'\U0001d71a': "\u03f1",
+	'\U0001d71b': "\u03d6",
+	'\U0001d71c': "\u0391",
+	'\U0001d71d': "\u0392",
+	'\U0001d71e': "\u0393",
+	'\U0001d71f': "\u0394",
+	'\U0001d720': "\u0395",
+	'\U0001d721': "\u0396",
+	'\U0001d722': "\u0397",
+	'\U0001d723': "\u0398",
+	'\U0001d724': "\u0399",
+	'\U0001d725': "\u039a",
+	'\U0001d726': "\u039b",
+	'\U0001d727': "\u039c",
+	'\U0001d728': "\u039d",
+	'\U0001d729': "\u039e",
+	'\U0001d72a': "\u039f",
+	'\U0001d72b': "\u03a0",
+	'\U0001d72c': "\u03a1",
+	'\U0001d72d': "\u03f4",
+	'\U0001d72e': "\u03a3",
+	'\U0001d72f': "\u03a4",
+	'\U0001d730': "\u03a5",
+	'\U0001d731': "\u03a6",
+	'\U0001d732': "\u03a7",
+	'\U0001d733': "\u03a8",
+	'\U0001d734': "\u03a9",
+	'\U0001d735': "\u2207",
+	'\U0001d736': "\u03b1",
+	'\U0001d737': "\u03b2",
+	'\U0001d738': "\u03b3",
+	'\U0001d739': "\u03b4",
+	'\U0001d73a': "\u03b5",
+	'\U0001d73b': "\u03b6",
+	'\U0001d73c': "\u03b7",
+	'\U0001d73d': "\u03b8",
+	'\U0001d73e': "\u03b9",
+	'\U0001d73f': "\u03ba",
+	'\U0001d740': "\u03bb",
+	'\U0001d741': "\u03bc",
+	'\U0001d742': "\u03bd",
+	'\U0001d743': "\u03be",
+	'\U0001d744': "\u03bf",
+	'\U0001d745': "\u03c0",
+	'\U0001d746': "\u03c1",
+	'\U0001d747': "\u03c2",
+	'\U0001d748': "\u03c3",
+	'\U0001d749': "\u03c4",
+	'\U0001d74a': "\u03c5",
+	'\U0001d74b': "\u03c6",
+	'\U0001d74c': "\u03c7",
+	'\U0001d74d': "\u03c8",
+	'\U0001d74e': "\u03c9",
+	'\U0001d74f': "\u2202",
+	'\U0001d750': "\u03f5",
+	'\U0001d751': "\u03d1",
+	'\U0001d752': "\u03f0",
+	'\U0001d753': "\u03d5",
+	'\U0001d754': "\u03f1",
+	'\U0001d755': "\u03d6",
+	'\U0001d756': "\u0391",
+	'\U0001d757': "\u0392",
+	'\U0001d758': "\u0393",
+	'\U0001d759': "\u0394",
+	'\U0001d75a': "\u0395",
+	'\U0001d75b': "\u0396",
+	'\U0001d75c': "\u0397",
+	'\U0001d75d': "\u0398",
+	'\U0001d75e': "\u0399",
+	'\U0001d75f': "\u039a",
+	'\U0001d760': "\u039b",
+	'\U0001d761': "\u039c",
+	'\U0001d762': "\u039d",
+	'\U0001d763': "\u039e",
+	'\U0001d764': "\u039f",
+	'\U0001d765': "\u03a0",
+	'\U0001d766': "\u03a1",
+	'\U0001d767': "\u03f4",
+	'\U0001d768': "\u03a3",
+	'\U0001d769': "\u03a4",
+	'\U0001d76a': "\u03a5",
+	'\U0001d76b': "\u03a6",
+	'\U0001d76c': "\u03a7",
+	'\U0001d76d': "\u03a8",
+	'\U0001d76e': "\u03a9",
+	'\U0001d76f': "\u2207",
+	'\U0001d770': "\u03b1",
+	'\U0001d771': "\u03b2",
+	'\U0001d772': "\u03b3",
+	'\U0001d773': "\u03b4",
+	'\U0001d774': "\u03b5",
+	'\U0001d775': "\u03b6",
+	'\U0001d776': "\u03b7",
+	'\U0001d777': "\u03b8",
+	'\U0001d778': "\u03b9",
+	'\U0001d779': "\u03ba",
+	'\U0001d77a': "\u03bb",
+	'\U0001d77b': "\u03bc",
+	'\U0001d77c': "\u03bd",
+	'\U0001d77d': "\u03be",
+	'\U0001d77e': "\u03bf",
+	'\U0001d77f': "\u03c0",
+	'\U0001d780': "\u03c1",
+	'\U0001d781': "\u03c2",
+	'\U0001d782': "\u03c3",
+	'\U0001d783': "\u03c4",
+	'\U0001d784': "\u03c5",
+	'\U0001d785': "\u03c6",
+	'\U0001d786': "\u03c7",
+	'\U0001d787': "\u03c8",
+	'\U0001d788': "\u03c9",
+	'\U0001d789': "\u2202",
+	'\U0001d78a': "\u03f5",
+	'\U0001d78b': "\u03d1",
+	'\U0001d78c': "\u03f0",
+	'\U0001d78d': "\u03d5",
+	'\U0001d78e': "\u03f1",
+	'\U0001d78f': "\u03d6",
+	'\U0001d790': "\u0391",
+	'\U0001d791': "\u0392",
+	'\U0001d792': "\u0393",
+	'\U0001d793': "\u0394",
+	'\U0001d794': "\u0395",
+	'\U0001d795': "\u0396",
+	'\U0001d796': "\u0397",
+	'\U0001d797': "\u0398",
+	'\U0001d798': "\u0399",
+	'\U0001d799': "\u039a",
+	'\U0001d79a': "\u039b",
+	'\U0001d79b': "\u039c",
+	'\U0001d79c': "\u039d",
+	'\U0001d79d': "\u039e",
+	'\U0001d79e': "\u039f",
+	'\U0001d79f': "\u03a0",
+	'\U0001d7a0': "\u03a1",
+	'\U0001d7a1': "\u03f4",
+	'\U0001d7a2': "\u03a3",
+	'\U0001d7a3': "\u03a4",
+	'\U0001d7a4': "\u03a5",
+	'\U0001d7a5': "\u03a6",
+	'\U0001d7a6': "\u03a7",
+	'\U0001d7a7': "\u03a8",
+	'\U0001d7a8': "\u03a9",
+	'\U0001d7a9': "\u2207",
+	'\U0001d7aa': "\u03b1",
+	'\U0001d7ab': "\u03b2",
+	'\U0001d7ac': "\u03b3",
+	'\U0001d7ad': "\u03b4",
+	'\U0001d7ae': "\u03b5",
+	'\U0001d7af': "\u03b6",
+	'\U0001d7b0': "\u03b7",
+	'\U0001d7b1': "\u03b8",
+	'\U0001d7b2': "\u03b9",
+	'\U0001d7b3': "\u03ba",
+	'\U0001d7b4': "\u03bb",
+	'\U0001d7b5': "\u03bc",
+	'\U0001d7b6': "\u03bd",
+	'\U0001d7b7': "\u03be",
+	'\U0001d7b8': "\u03bf",
+	'\U0001d7b9': "\u03c0",
+	'\U0001d7ba': "\u03c1",
+	'\U0001d7bb': "\u03c2",
+	'\U0001d7bc': "\u03c3",
+	'\U0001d7bd': "\u03c4",
+	'\U0001d7be': "\u03c5",
+	'\U0001d7bf': "\u03c6",
+	'\U0001d7c0': "\u03c7",
+	'\U0001d7c1': "\u03c8",
+	'\U0001d7c2': "\u03c9",
+	'\U0001d7c3': "\u2202",
+	'\U0001d7c4': "\u03f5",
+	'\U0001d7c5': "\u03d1",
+	'\U0001d7c6': "\u03f0",
+	'\U0001d7c7': "\u03d5",
+	'\U0001d7c8': "\u03f1",
+	'\U0001d7c9': "\u03d6",
+	'\U0001d7ca': "\u03dc",
+	'\U0001d7cb': "\u03dd",
+	'\U0001d7ce': "0",
+	'\U0001d7cf': "1",
+	'\U0001d7d0': "2",
+	'\U0001d7d1': "3",
+	'\U0001d7d2': "4",
+	'\U0001d7d3': "5",
+	'\U0001d7d4': "6",
+	'\U0001d7d5': "7",
+	'\U0001d7d6': "8",
+	'\U0001d7d7': "9",
+	'\U0001d7d8': "0",
+	'\U0001d7d9': "1",
+	'\U0001d7da': "2",
+	'\U0001d7db': "3",
+	'\U0001d7dc': "4",
+	'\U0001d7dd': "5",
+	'\U0001d7de': "6",
+	'\U0001d7df': "7",
+	'\U0001d7e0': "8",
+	'\U0001d7e1': "9",
+	'\U0001d7e2': "0",
+	'\U0001d7e3': "1",
+	'\U0001d7e4': "2",
+	'\U0001d7e5': "3",
+	'\U0001d7e6': "4",
+	'\U0001d7e7': "5",
+	'\U0001d7e8': "6",
+	'\U0001d7e9': "7",
+	'\U0001d7ea': "8",
+	'\U0001d7eb': "9",
+	'\U0001d7ec': "0",
+	'\U0001d7ed': "1",
+	'\U0001d7ee': "2",
+	'\U0001d7ef': "3",
+	'\U0001d7f0': "4",
+	'\U0001d7f1': "5",
+	'\U0001d7f2': "6",
+	'\U0001d7f3': "7",
+	'\U0001d7f4': "8",
+	'\U0001d7f5': "9",
+	'\U0001d7f6': "0",
+	'\U0001d7f7': "1",
+	'\U0001d7f8': "2",
+	'\U0001d7f9': "3",
+	'\U0001d7fa': "4",
+	'\U0001d7fb': "5",
+	'\U0001d7fc': "6",
+	'\U0001d7fd': "7",
+	'\U0001d7fe': "8",
+	'\U0001d7ff': "9",
+	'\U0001ee00': "\u0627",
+	'\U0001ee01': "\u0628",
+	'\U0001ee02': "\u062c",
+	'\U0001ee03': "\u062f",
+	'\U0001ee05': "\u0648",
+	'\U0001ee06': "\u0632",
+	'\U0001ee07': "\u062d",
+	'\U0001ee08': "\u0637",
+	'\U0001ee09': "\u064a",
+	'\U0001ee0a': "\u0643",
+	'\U0001ee0b': "\u0644",
+	'\U0001ee0c': "\u0645",
+	'\U0001ee0d': "\u0646",
+	'\U0001ee0e': "\u0633",
+	'\U0001ee0f': "\u0639",
+	'\U0001ee10': "\u0641",
+	'\U0001ee11': "\u0635",
+	'\U0001ee12': "\u0642",
+	'\U0001ee13': "\u0631",
+	'\U0001ee14': "\u0634",
+	'\U0001ee15': "\u062a",
+	'\U0001ee16': "\u062b",
+	'\U0001ee17': "\u062e",
+	'\U0001ee18': "\u0630",
+	'\U0001ee19': "\u0636",
+	'\U0001ee1a': "\u0638",
+	'\U0001ee1b': "\u063a",
+	'\U0001ee1c': "\u066e",
+	'\U0001ee1d': "\u06ba",
+	'\U0001ee1e': "\u06a1",
+	'\U0001ee1f': "\u066f",
+	'\U0001ee21': "\u0628",
+	'\U0001ee22': "\u062c",
+	'\U0001ee24': "\u0647",
+	'\U0001ee27': "\u062d",
+	'\U0001ee29': "\u064a",
+	'\U0001ee2a': "\u0643",
+	'\U0001ee2b': "\u0644",
+	'\U0001ee2c': "\u0645",
+	'\U0001ee2d': "\u0646",
+	'\U0001ee2e': "\u0633",
+	'\U0001ee2f': "\u0639",
+	'\U0001ee30': "\u0641",
+	'\U0001ee31': "\u0635",
+	'\U0001ee32': "\u0642",
+	'\U0001ee34': "\u0634",
+	'\U0001ee35': "\u062a",
+	'\U0001ee36': "\u062b",
+	'\U0001ee37': "\u062e",
+	'\U0001ee39': "\u0636",
+	'\U0001ee3b': "\u063a",
+	'\U0001ee42': "\u062c",
+	'\U0001ee47': "\u062d",
+	'\U0001ee49': "\u064a",
+	'\U0001ee4b': "\u0644",
+	'\U0001ee4d': "\u0646",
+	'\U0001ee4e': "\u0633",
+	'\U0001ee4f': "\u0639",
+	'\U0001ee51': "\u0635",
+	'\U0001ee52': "\u0642",
+	'\U0001ee54': "\u0634",
+	'\U0001ee57': "\u062e",
+	'\U0001ee59': "\u0636",
+	'\U0001ee5b': "\u063a",
+	'\U0001ee5d': "\u06ba",
+	'\U0001ee5f': "\u066f",
+	'\U0001ee61': "\u0628",
+	'\U0001ee62': "\u062c",
+	'\U0001ee64': "\u0647",
+	'\U0001ee67': "\u062d",
+	'\U0001ee68': "\u0637",
+	'\U0001ee69': "\u064a",
+	'\U0001ee6a': "\u0643",
+	'\U0001ee6c': "\u0645",
+	'\U0001ee6d': "\u0646",
+	'\U0001ee6e': "\u0633",
+	'\U0001ee6f': "\u0639",
+	'\U0001ee70': "\u0641",
+	'\U0001ee71': "\u0635",
+	'\U0001ee72': "\u0642",
+	'\U0001ee74': "\u0634",
+	'\U0001ee75': "\u062a",
+	'\U0001ee76': "\u062b",
+	'\U0001ee77': "\u062e",
+	'\U0001ee79': "\u0636",
+	'\U0001ee7a': "\u0638",
+	'\U0001ee7b': "\u063a",
+	'\U0001ee7c': "\u066e",
+	'\U0001ee7e': "\u06a1",
+	'\U0001ee80': "\u0627",
+	'\U0001ee81': "\u0628",
+	'\U0001ee82': "\u062c",
+	'\U0001ee83': "\u062f",
+	'\U0001ee84': "\u0647",
+	'\U0001ee85': "\u0648",
+	'\U0001ee86': "\u0632",
+	'\U0001ee87': "\u062d",
+	'\U0001ee88': "\u0637",
+	'\U0001ee89': "\u064a",
+	'\U0001ee8b': "\u0644",
+	'\U0001ee8c': "\u0645",
+	'\U0001ee8d': "\u0646",
+	'\U0001ee8e': "\u0633",
+	'\U0001ee8f': "\u0639",
+	'\U0001ee90': "\u0641",
+	'\U0001ee91': "\u0635",
+	'\U0001ee92': "\u0642",
+	'\U0001ee93': "\u0631",
+	'\U0001ee94': "\u0634",
+	'\U0001ee95': "\u062a",
+	'\U0001ee96': "\u062b",
+	'\U0001ee97': "\u062e",
+	'\U0001ee98': "\u0630",
+	'\U0001ee99': "\u0636",
+	'\U0001ee9a': "\u0638",
+	'\U0001ee9b': "\u063a",
+	'\U0001eea1': "\u0628",
+	'\U0001eea2': "\u062c",
+	'\U0001eea3': "\u062f",
+	'\U0001eea5': "\u0648",
+	'\U0001eea6': "\u0632",
+	'\U0001eea7': "\u062d",
+	'\U0001eea8': "\u0637",
+	'\U0001eea9': "\u064a",
+	'\U0001eeab': "\u0644",
+	'\U0001eeac': "\u0645",
+	'\U0001eead': "\u0646",
+	'\U0001eeae': "\u0633",
+	'\U0001eeaf': "\u0639",
+	'\U0001eeb0': "\u0641",
+	'\U0001eeb1': "\u0635",
+	'\U0001eeb2': "\u0642",
+	'\U0001eeb3': "\u0631",
+	'\U0001eeb4': "\u0634",
+	'\U0001eeb5': "\u062a",
+	'\U0001eeb6': "\u062b",
+	'\U0001eeb7': "\u062e",
+	'\U0001eeb8': "\u0630",
+	'\U0001eeb9': "\u0636",
+	'\U0001eeba': "\u0638",
+	'\U0001eebb': "\u063a",
+	'\U0001f100': "0.",
+	'\U0001f101': "0,",
+	'\U0001f102': "1,",
+	'\U0001f103': "2,",
+	'\U0001f104': "3,",
+	'\U0001f105': "4,",
+	'\U0001f106': "5,",
+	'\U0001f107': "6,",
+	'\U0001f108': "7,",
+	'\U0001f109': "8,",
+	'\U0001f10a': "9,",
+	'\U0001f110': "(A)",
+	'\U0001f111': "(B)",
+	'\U0001f112': "(C)",
+	'\U0001f113': "(D)",
+	'\U0001f114': "(E)",
+	'\U0001f115': "(F)",
+	'\U0001f116': "(G)",
+	'\U0001f117': "(H)",
+	'\U0001f118': "(I)",
+	'\U0001f119': "(J)",
+	'\U0001f11a': "(K)",
+	'\U0001f11b': "(L)",
+	'\U0001f11c': "(M)",
+	'\U0001f11d': "(N)",
+	'\U0001f11e': "(O)",
+	'\U0001f11f': "(P)",
+	'\U0001f120': "(Q)",
+	'\U0001f121': "(R)",
+	'\U0001f122': "(S)",
+	'\U0001f123': "(T)",
+	'\U0001f124': "(U)",
+	'\U0001f125': "(V)",
+	'\U0001f126': "(W)",
+	'\U0001f127': "(X)",
+	'\U0001f128': "(Y)",
+	'\U0001f129': "(Z)",
+	'\U0001f12a': "\u3014S\u3015",
+	'\U0001f12b': "C",
+	'\U0001f12c': "R",
+	'\U0001f12d': "CD",
+	'\U0001f12e': "WZ",
+	'\U0001f130': "A",
+	'\U0001f131': "B",
+	'\U0001f132': "C",
+	'\U0001f133': "D",
+	'\U0001f134': "E",
+	'\U0001f135': "F",
+	'\U0001f136': "G",
+	'\U0001f137': "H",
+	'\U0001f138': "I",
+	'\U0001f139': "J",
+	'\U0001f13a': "K",
+	'\U0001f13b': "L",
+	'\U0001f13c': "M",
+	'\U0001f13d': "N",
+	'\U0001f13e': "O",
+	'\U0001f13f': "P",
+	'\U0001f140': "Q",
+	'\U0001f141': "R",
+	'\U0001f142': "S",
+	'\U0001f143': "T",
+	'\U0001f144': "U",
+	'\U0001f145': "V",
+	'\U0001f146': "W",
+	'\U0001f147': "X",
+	'\U0001f148': "Y",
+	'\U0001f149': "Z",
+	'\U0001f14a': "HV",
+	'\U0001f14b': "MV",
+	'\U0001f14c': "SD",
+	'\U0001f14d': "SS",
+	'\U0001f14e': "PPV",
+	'\U0001f14f': "WC",
+	'\U0001f16a': "MC",
+	'\U0001f16b': "MD",
+	'\U0001f16c': "MR",
+	'\U0001f190': "DJ",
+	'\U0001f200': "\u307b\u304b",
+	'\U0001f201': "\u30b3\u30b3",
+	'\U0001f202': "\u30b5",
+	'\U0001f210': "\u624b",
+	'\U0001f211': "\u5b57",
+	'\U0001f212': "\u53cc",
+	'\U0001f213': "\u30c7",
+	'\U0001f214': "\u4e8c",
+	'\U0001f215': "\u591a",
+	'\U0001f216': "\u89e3",
+	'\U0001f217': "\u5929",
+	'\U0001f218': "\u4ea4",
+	'\U0001f219': "\u6620",
+	'\U0001f21a': "\u7121",
+	'\U0001f21b': "\u6599",
+	'\U0001f21c': "\u524d",
+	'\U0001f21d': "\u5f8c",
+	'\U0001f21e': "\u518d",
+	'\U0001f21f': "\u65b0",
+	'\U0001f220': "\u521d",
+	'\U0001f221': "\u7d42",
+	'\U0001f222': "\u751f",
+	'\U0001f223': "\u8ca9",
+	'\U0001f224': "\u58f0",
+	'\U0001f225': "\u5439",
+	'\U0001f226': "\u6f14",
+	'\U0001f227': "\u6295",
+	'\U0001f228': "\u6355",
+	'\U0001f229': "\u4e00",
+	'\U0001f22a': "\u4e09",
+	'\U0001f22b': "\u904a",
+	'\U0001f22c': "\u5de6",
+	'\U0001f22d': "\u4e2d",
+	'\U0001f22e': "\u53f3",
+	'\U0001f22f': "\u6307",
+	'\U0001f230': "\u8d70",
+	'\U0001f231': "\u6253",
+	'\U0001f232': "\u7981",
+	'\U0001f233': "\u7a7a",
+	'\U0001f234': "\u5408",
+	'\U0001f235': "\u6e80",
+	'\U0001f236': "\u6709",
+	'\U0001f237': "\u6708",
+	'\U0001f238': "\u7533",
+	'\U0001f239': "\u5272",
+	'\U0001f23a': "\u55b6",
+	'\U0001f23b': "\u914d",
+	'\U0001f240': "\u3014\u672c\u3015",
+	'\U0001f241': "\u3014\u4e09\u3015",
+	'\U0001f242': "\u3014\u4e8c\u3015",
+	'\U0001f243': "\u3014\u5b89\u3015",
+	'\U0001f244': "\u3014\u70b9\u3015",
+	'\U0001f245': "\u3014\u6253\u3015",
+	'\U0001f246': "\u3014\u76d7\u3015",
+	'\U0001f247': "\u3014\u52dd\u3015",
+	'\U0001f248': "\u3014\u6557\u3015",
+	'\U0001f250': "\u5f97",
+	'\U0001f251': "\u53ef",
+	'\U0001fbf0': "0",
+	'\U0001fbf1': "1",
+	'\U0001fbf2': "2",
+	'\U0001fbf3': "3",
+	'\U0001fbf4': "4",
+	'\U0001fbf5': "5",
+	'\U0001fbf6': "6",
+	'\U0001fbf7': "7",
+	'\U0001fbf8': "8",
+	'\U0001fbf9': "9",
+}
+
+// combiningClass holds the canonical combining class of combining marks.
+var combiningClass = map[rune]uint8{
+	'\u0300':     230,
+	'\u0301':     230,
+	'\u0302':     230,
+	'\u0303':     230,
+	'\u0304':     230,
+	'\u0305':     230,
+	'\u0306':     230,
+	'\u0307':     230,
+	'\u0308':     230,
+	'\u0309':     230,
+	'\u030a':     230,
+	'\u030b':     230,
+	'\u030c':     230,
+	'\u030d':     230,
+	'\u030e':     230,
+	'\u030f':     230,
+	'\u0310':     230,
+	'\u0311':     230,
+	'\u0312':     230,
+	'\u0313':     230,
+	'\u0314':     230,
+	'\u0315':     232,
+	'\u0316':     220,
+	'\u0317':     220,
+	'\u0318':     220,
+	'\u0319':     220,
+	'\u031a':     232,
+	'\u031b':     216,
+	'\u031c':     220,
+	'\u031d':     220,
+	'\u031e':     220,
+	'\u031f':     220,
+	'\u0320':     220,
+	'\u0321':     202,
+	'\u0322':     202,
+	'\u0323':     220,
+	'\u0324':     220,
+	'\u0325':     220,
+	'\u0326':     220,
+	'\u0327':     202,
+	'\u0328':     202,
+	'\u0329':     220,
+	'\u032a':     220,
+	'\u032b':     220,
+	'\u032c':     220,
+	'\u032d':     220,
+	'\u032e':     220,
+	'\u032f':     220,
+	'\u0330':     220,
+	'\u0331':     220,
+	'\u0332':     220,
+	'\u0333':     220,
+	'\u0334':     1,
+	'\u0335':     1,
+	'\u0336':     1,
+	'\u0337':     1,
+	'\u0338':     1,
+	'\u0339':     220,
+	'\u033a':     220,
+	'\u033b':     220,
+	'\u033c':     220,
+	'\u033d':     230,
+	'\u033e':     230,
+	'\u033f':     230,
+	'\u0340':     230,
+	'\u0341':     230,
+	'\u0342':     230,
+	'\u0343':     230,
+	'\u0344':     230,
+	'\u0345':     240,
+	'\u0346':     230,
+	'\u0347':     220,
+	'\u0348':     220,
+	'\u0349':     220,
+	'\u034a':     230,
+	'\u034b':     230,
+	'\u034c':     230,
+	'\u034d':     220,
+	'\u034e':     220,
+	'\u0350':     230,
+	'\u0351':     230,
+	'\u0352':     230,
+	'\u0353':     220,
+	'\u0354':     220,
+	'\u0355':     220,
+	'\u0356':     220,
+	'\u0357':     230,
+	'\u0358':     232,
+	'\u0359':     220,
+	'\u035a':     220,
+	'\u035b':     230,
+	'\u035c':     233,
+	'\u035d':     234,
+	'\u035e':     234,
+	'\u035f':     233,
+	'\u0360':     234,
+	'\u0361':     234,
+	'\u0362':     233,
+	'\u0363':     230,
+	'\u0364':     230,
+	'\u0365':     230,
+	'\u0366':     230,
+	'\u0367':     230,
+	'\u0368':     230,
+	'\u0369':     230,
+	'\u036a':     230,
+	'\u036b':     230,
+	'\u036c':     230,
+	'\u036d':     230,
+	'\u036e':     230,
+	'\u036f':     230,
+	'\u0483':     230,
+	'\u0484':     230,
+	'\u0485':     230,
+	'\u0486':     230,
+	'\u0487':     230,
+	'\u0591':     220,
+	'\u0592':     230,
+	'\u0593':     230,
+	'\u0594':     230,
+	'\u0595':     230,
+	'\u0596':     220,
+	'\u0597':     230,
+	'\u0598':     230,
+	'\u0599':     230,
+	'\u059a':     222,
+	'\u059b':     220,
+	'\u059c':     230,
+	'\u059d':     230,
+	'\u059e':     230,
+	'\u059f':     230,
+	'\u05a0':     230,
+	'\u05a1':     230,
+	'\u05a2':     220,
+	'\u05a3':     220,
+	'\u05a4':     220,
+	'\u05a5':     220,
+	'\u05a6':     220,
+	'\u05a7':     220,
+	'\u05a8':     230,
+	'\u05a9':     230,
+	'\u05aa':     220,
+	'\u05ab':     230,
+	'\u05ac':     230,
+	'\u05ad':     222,
+	'\u05ae':     228,
+	'\u05af':     230,
+	'\u05b0':     10,
+	'\u05b1':     11,
+	'\u05b2':     12,
+	'\u05b3':     13,
+	'\u05b4':     14,
+	'\u05b5':     15,
+	'\u05b6':     16,
+	'\u05b7':     17,
+	'\u05b8':     18,
+	'\u05b9':     19,
+	'\u05ba':     19,
+	'\u05bb':     20,
+	'\u05bc':     21,
+	'\u05bd':     22,
+	'\u05bf':     23,
+	'\u05c1':     24,
+	'\u05c2':     25,
+	'\u05c4':     230,
+	'\u05c5':     220,
+	'\u05c7':     18,
+	'\u0610':     230,
+	'\u0611':     230,
+	'\u0612':     230,
+	'\u0613':     230,
+	'\u0614':     230,
+	'\u0615':     230,
+	'\u0616':     230,
+	'\u0617':     230,
+	'\u0618':     30,
+	'\u0619':     31,
+	'\u061a':     32,
+	'\u064b':     27,
+	'\u064c':     28,
+	'\u064d':     29,
+	'\u064e':     30,
+	'\u064f':     31,
+	'\u0650':     32,
+	'\u0651':     33,
+	'\u0652':     34,
+	'\u0653':     230,
+	'\u0654':     230,
+	'\u0655':     220,
+	'\u0656':     220,
+	'\u0657':     230,
+	'\u0658':     230,
+	'\u0659':     230,
+	'\u065a':     230,
+	'\u065b':     230,
+	'\u065c':     220,
+	'\u065d':     230,
+	'\u065e':     230,
+	'\u065f':     220,
+	'\u0670':     35,
+	'\u06d6':     230,
+	'\u06d7':     230,
+	'\u06d8':     230,
+	'\u06d9':     230,
+	'\u06da':     230,
+	'\u06db':     230,
+	'\u06dc':     230,
+	'\u06df':     230,
+	'\u06e0':     230,
+	'\u06e1':     230,
+	'\u06e2':     230,
+	'\u06e3':     220,
+	'\u06e4':     230,
+	'\u06e7':     230,
+	'\u06e8':     230,
+	'\u06ea':     220,
+	'\u06eb':     230,
+	'\u06ec':     230,
+	'\u06ed':     220,
+	'\u0711':     36,
+	'\u0730':     230,
+	'\u0731':     220,
+	'\u0732':     230,
+	'\u0733':     230,
+	'\u0734':     220,
+	'\u0735':     230,
+	'\u0736':     230,
+	'\u0737':     220,
+	'\u0738':     220,
+	'\u0739':     220,
+	'\u073a':     230,
+	'\u073b':     220,
+	'\u073c':     220,
+	'\u073d':     230,
+	'\u073e':     220,
+	'\u073f':     230,
+	'\u0740':     230,
+	'\u0741':     230,
+	'\u0742':     220,
+	'\u0743':     230,
+	'\u0744':     220,
+	'\u0745':     230,
+	'\u0746':     220,
+	'\u0747':     230,
+	'\u0748':     220,
+	'\u0749':     230,
+	'\u074a':     230,
+	'\u07eb':     230,
+	'\u07ec':     230,
+	'\u07ed':     230,
+	'\u07ee':     230,
+	'\u07ef':     230,
+	'\u07f0':     230,
+	'\u07f1':     230,
+	'\u07f2':     220,
+	'\u07f3':     230,
+	'\u07fd':     220,
+	'\u0816':     230,
+	'\u0817':     230,
+	'\u0818':     230,
+	'\u0819':     230,
+	'\u081b':     230,
+	'\u081c':     230,
+	'\u081d':     230,
+	'\u081e':     230,
+	'\u081f':     230,
+	'\u0820':     230,
+	'\u0821':     230,
+	'\u0822':     230,
+	'\u0823':     230,
+	'\u0825':     230,
+	'\u0826':     230,
+	'\u0827':     230,
+	'\u0829':     230,
+	'\u082a':     230,
+	'\u082b':     230,
+	'\u082c':     230,
+	'\u082d':     230,
+	'\u0859':     220,
+	'\u085a':     220,
+	'\u085b':     220,
+	'\u0898':     230,
+	'\u0899':     220,
+	'\u089a':     220,
+	'\u089b':     220,
+	'\u089c':     230,
+	'\u089d':     230,
+	'\u089e':     230,
+	'\u089f':     230,
+	'\u08ca':     230,
+	'\u08cb':     230,
+	'\u08cc':     230,
+	'\u08cd':     230,
+	'\u08ce':     230,
+	'\u08cf':     220,
+	'\u08d0':     220,
+	'\u08d1':     220,
+	'\u08d2':     220,
+	'\u08d3':     220,
+	'\u08d4':     230,
+	'\u08d5':     230,
+	'\u08d6':     230,
+	'\u08d7':     230,
+	'\u08d8':     230,
+	'\u08d9':     230,
+	'\u08da':     230,
+	'\u08db':     230,
+	'\u08dc':     230,
+	'\u08dd':     230,
+	'\u08de':     230,
+	'\u08df':     230,
+	'\u08e0':     230,
+	'\u08e1':     230,
+	'\u08e3':     220,
+	'\u08e4':     230,
+	'\u08e5':     230,
+	'\u08e6':     220,
+	'\u08e7':     230,
+	'\u08e8':     230,
+	'\u08e9':     220,
+	'\u08ea':     230,
+	'\u08eb':     230,
+	'\u08ec':     230,
+	'\u08ed':     220,
+	'\u08ee':     220,
+	'\u08ef':     220,
+	'\u08f0':     27,
+	'\u08f1':     28,
+	'\u08f2':     29,
+	'\u08f3':     230,
+	'\u08f4':     230,
+	'\u08f5':     230,
+	'\u08f6':     220,
+	'\u08f7':     230,
+	'\u08f8':     230,
+	'\u08f9':     220,
+	'\u08fa':     220,
+	'\u08fb':     230,
+	'\u08fc':     230,
+	'\u08fd':     230,
+	'\u08fe':     230,
+	'\u08ff':     230,
+	'\u093c':     7,
+	'\u094d':     9,
+	'\u0951':     230,
+	'\u0952':     220,
+	'\u0953':     230,
+	'\u0954':     230,
+	'\u09bc':     7,
+	'\u09cd':     9,
+	'\u09fe':     230,
+	'\u0a3c':     7,
+	'\u0a4d':     9,
+	'\u0abc':     7,
+	'\u0acd':     9,
+	'\u0b3c':     7,
+	'\u0b4d':     9,
+	'\u0bcd':     9,
+	'\u0c3c':     7,
+	'\u0c4d':     9,
+	'\u0c55':     84,
+	'\u0c56':     91,
+	'\u0cbc':     7,
+	'\u0ccd':     9,
+	'\u0d3b':     9,
+	'\u0d3c':     9,
+	'\u0d4d':     9,
+	'\u0dca':     9,
+	'\u0e38':     103,
+	'\u0e39':     103,
+	'\u0e3a':     9,
+	'\u0e48':     107,
+	'\u0e49':     107,
+	'\u0e4a':     107,
+	'\u0e4b':     107,
+	'\u0eb8':     118,
+	'\u0eb9':     118,
+	'\u0eba':     9,
+	'\u0ec8':     122,
+	'\u0ec9':     122,
+	'\u0eca':     122,
+	'\u0ecb':     122,
+	'\u0f18':     220,
+	'\u0f19':     220,
+	'\u0f35':     220,
+	'\u0f37':     220,
+	'\u0f39':     216,
+	'\u0f71':     129,
+	'\u0f72':     130,
+	'\u0f74':     132,
+	'\u0f7a':     130,
+	'\u0f7b':     130,
+	'\u0f7c':     130,
+	'\u0f7d':     130,
+	'\u0f80':     130,
+	'\u0f82':     230,
+	'\u0f83':     230,
+	'\u0f84':     9,
+	'\u0f86':     230,
+	'\u0f87':     230,
+	'\u0fc6':     220,
+	'\u1037':     7,
+	'\u1039':     9,
+	'\u103a':     9,
+	'\u108d':     220,
+	'\u135d':     230,
+	'\u135e':     230,
+	'\u135f':     230,
+	'\u1714':     9,
+	'\u1715':     9,
+	'\u1734':     9,
+	'\u17d2':     9,
+	'\u17dd':     230,
+	'\u18a9':     228,
+	'\u1939':     222,
+	'\u193a':     230,
+	'\u193b':     220,
+	'\u1a17':     230,
+	'\u1a18':     220,
+	'\u1a60':     9,
+	'\u1a75':     230,
+	'\u1a76':     230,
+	'\u1a77':     230,
+	'\u1a78':     230,
+	'\u1a79':     230,
+	'\u1a7a':     230,
+	'\u1a7b':     230,
+	'\u1a7c':     230,
+	'\u1a7f':     220,
+	'\u1ab0':     230,
+	'\u1ab1':     230,
+	'\u1ab2':     230,
+	'\u1ab3':     230,
+	'\u1ab4':     230,
+	'\u1ab5':     220,
+	'\u1ab6':     220,
+	'\u1ab7':     220,
+	'\u1ab8':     220,
+	'\u1ab9':     220,
+	'\u1aba':     220,
+	'\u1abb':     230,
+	'\u1abc':     230,
+	'\u1abd':     220,
+	'\u1abf':     220,
+	'\u1ac0':     220,
+	'\u1ac1':     230,
+	'\u1ac2':     230,
+	'\u1ac3':     220,
+	'\u1ac4':     220,
+	'\u1ac5':     230,
+	'\u1ac6':     230,
+	'\u1ac7':     230,
+	'\u1ac8':     230,
+	'\u1ac9':     230,
+	'\u1aca':     220,
+	'\u1acb':     230,
+	'\u1acc':     230,
+	'\u1acd':     230,
+	'\u1ace':     230,
+	'\u1b34':     7,
+	'\u1b44':     9,
+	'\u1b6b':     230,
+	'\u1b6c':     220,
+	'\u1b6d':     230,
+	'\u1b6e':     230,
+	'\u1b6f':     230,
+	'\u1b70':     230,
+	'\u1b71':     230,
+	'\u1b72':     230,
+	'\u1b73':     230,
+	'\u1baa':     9,
+	'\u1bab':     9,
+	'\u1be6':     7,
+	'\u1bf2':     9,
+	'\u1bf3':     9,
+	'\u1c37':     7,
+	'\u1cd0':     230,
+	'\u1cd1':     230,
+	'\u1cd2':     230,
+	'\u1cd4':     1,
+	'\u1cd5':     220,
+	'\u1cd6':     220,
+	'\u1cd7':     220,
+	'\u1cd8':     220,
+	'\u1cd9':     220,
+	'\u1cda':     230,
+	'\u1cdb':     230,
+	'\u1cdc':     220,
+	'\u1cdd':     220,
+	'\u1cde':     220,
+	'\u1cdf':     220,
+	'\u1ce0':     230,
+	'\u1ce2':     1,
+	'\u1ce3':     1,
+	'\u1ce4':     1,
+	'\u1ce5':     1,
+	'\u1ce6':     1,
+	'\u1ce7':     1,
+	'\u1ce8':     1,
+	'\u1ced':     220,
+	'\u1cf4':     230,
+	'\u1cf8':     230,
+	'\u1cf9':     230,
+	'\u1dc0':     230,
+	'\u1dc1':     230,
+	'\u1dc2':     220,
+	'\u1dc3':     230,
+	'\u1dc4':     230,
+	'\u1dc5':     230,
+	'\u1dc6':     230,
+	'\u1dc7':     230,
+	'\u1dc8':     230,
+	'\u1dc9':     230,
+	'\u1dca':     220,
+	'\u1dcb':     230,
+	'\u1dcc':     230,
+	'\u1dcd':     234,
+	'\u1dce':     214,
+	'\u1dcf':     220,
+	'\u1dd0':     202,
+	'\u1dd1':     230,
+	'\u1dd2':     230,
+	'\u1dd3':     230,
+	'\u1dd4':     230,
+	'\u1dd5':     230,
+	'\u1dd6':     230,
+	'\u1dd7':     230,
+	'\u1dd8':     230,
+	'\u1dd9':     230,
+	'\u1dda':     230,
+	'\u1ddb':     230,
+	'\u1ddc':     230,
+	'\u1ddd':     230,
+	'\u1dde':     230,
+	'\u1ddf':     230,
+	'\u1de0':     230,
+	'\u1de1':     230,
+	'\u1de2':     230,
+	'\u1de3':     230,
+	'\u1de4':     230,
+	'\u1de5':     230,
+	'\u1de6':     230,
+	'\u1de7':     230,
+	'\u1de8':     230,
+	'\u1de9':     230,
+	'\u1dea':     230,
+	'\u1deb':     230,
+	'\u1dec':     230,
+	'\u1ded':     230,
+	'\u1dee':     230,
+	'\u1def':     230,
+	'\u1df0':     230,
+	'\u1df1':     230,
+	'\u1df2':     230,
+	'\u1df3':     230,
+	'\u1df4':     230,
+	'\u1df5':     230,
+	'\u1df6':     232,
+	'\u1df7':     228,
+	'\u1df8':     228,
+	'\u1df9':     220,
+	'\u1dfa':     218,
+	'\u1dfb':     230,
+	'\u1dfc':     233,
+	'\u1dfd':     220,
+	'\u1dfe':     230,
+	'\u1dff':     220,
+	'\u20d0':     230,
+	'\u20d1':     230,
+	'\u20d2':     1,
+	'\u20d3':     1,
+	'\u20d4':     230,
+	'\u20d5':     230,
+	'\u20d6':     230,
+	'\u20d7':     230,
+	'\u20d8':     1,
+	'\u20d9':     1,
+	'\u20da':     1,
+	'\u20db':     230,
+	'\u20dc':     230,
+	'\u20e1':     230,
+	'\u20e5':     1,
+	'\u20e6':     1,
+	'\u20e7':     230,
+	'\u20e8':     220,
+	'\u20e9':     230,
+	'\u20ea':     1,
+	'\u20eb':     1,
+	'\u20ec':     220,
+	'\u20ed':     220,
+	'\u20ee':     220,
+	'\u20ef':     220,
+	'\u20f0':     230,
+	'\u2cef':     230,
+	'\u2cf0':     230,
+	'\u2cf1':     230,
+	'\u2d7f':     9,
+	'\u2de0':     230,
+	'\u2de1':     230,
+	'\u2de2':     230,
+	'\u2de3':     230,
+	'\u2de4':     230,
+	'\u2de5':     230,
+	'\u2de6':     230,
+	'\u2de7':     230,
+	'\u2de8':     230,
+	'\u2de9':     230,
+	'\u2dea':     230,
+	'\u2deb':     230,
+	'\u2dec':     230,
+	'\u2ded':     230,
+	'\u2dee':     230,
+	'\u2def':     230,
+	'\u2df0':     230,
+	'\u2df1':     230,
+	'\u2df2':     230,
+	'\u2df3':     230,
+	'\u2df4':     230,
+	'\u2df5':     230,
+	'\u2df6':     230,
+	'\u2df7':     230,
+	'\u2df8':     230,
+	'\u2df9':     230,
+	'\u2dfa':     230,
+	'\u2dfb':     230,
+	'\u2dfc':     230,
+	'\u2dfd':     230,
+	'\u2dfe':     230,
+	'\u2dff':     230,
+	'\u302a':     218,
+	'\u302b':     228,
+	'\u302c':     232,
+	'\u302d':     222,
+	'\u302e':     224,
+	'\u302f':     224,
+	'\u3099':     8,
+	'\u309a':     8,
+	'\ua66f':     230,
+	'\ua674':     230,
+	'\ua675':     230,
+	'\ua676':     230,
+	'\ua677':     230,
+	'\ua678':     230,
+	'\ua679':     230,
+	'\ua67a':     230,
+	'\ua67b':     230,
+	'\ua67c':     230,
+	'\ua67d':     230,
+	'\ua69e':     230,
+	'\ua69f':     230,
+	'\ua6f0':     230,
+	'\ua6f1':     230,
+	'\ua806':     9,
+	'\ua82c':     9,
+	'\ua8c4':     9,
+	'\ua8e0':     230,
+	'\ua8e1':     230,
+	'\ua8e2':     230,
+	'\ua8e3':     230,
+	'\ua8e4':     230,
+	'\ua8e5':     230,
+	'\ua8e6':     230,
+	'\ua8e7':     230,
+	'\ua8e8':     230,
+	'\ua8e9':     230,
+	'\ua8ea':     230,
+	'\ua8eb':     230,
+	'\ua8ec':     230,
+	'\ua8ed':     230,
+	'\ua8ee':     230,
+	'\ua8ef':     230,
+	'\ua8f0':     230,
+	'\ua8f1':     230,
+	'\ua92b':     220,
+	'\ua92c':     220,
+	'\ua92d':     220,
+	'\ua953':     9,
+	'\ua9b3':     7,
+	'\ua9c0':     9,
+	'\uaab0':     230,
+	'\uaab2':     230,
+	'\uaab3':     230,
+	'\uaab4':     220,
+	'\uaab7':     230,
+	'\uaab8':     230,
+	'\uaabe':     230,
+	'\uaabf':     230,
+	'\uaac1':     230,
+	'\uaaf6':     9,
+	'\uabed':     9,
+	'\ufb1e':     26,
+	'\ufe20':     230,
+	'\ufe21':     230,
+	'\ufe22':     230,
+	'\ufe23':     230,
+	'\ufe24':     230,
+	'\ufe25':     230,
+	'\ufe26':     230,
+	'\ufe27':     220,
+	'\ufe28':     220,
+	'\ufe29':     220,
+	'\ufe2a':     220,
+	'\ufe2b':     220,
+	'\ufe2c':     220,
+	'\ufe2d':     220,
+	'\ufe2e':     230,
+	'\ufe2f':     230,
+	'\U000101fd': 220,
+	'\U000102e0': 220,
+	'\U00010376': 230,
+	'\U00010377': 230,
+	'\U00010378': 230,
+	'\U00010379': 230,
+	'\U0001037a': 230,
+	'\U00010a0d': 220,
+	'\U00010a0f': 230,
+	'\U00010a38': 230,
+	'\U00010a39': 1,
+	'\U00010a3a': 220,
+	'\U00010a3f': 9,
+	'\U00010ae5': 230,
+	'\U00010ae6': 220,
+	'\U00010d24': 230,
+	'\U00010d25': 230,
+	'\U00010d26': 230,
+	'\U00010d27': 230,
+	'\U00010eab': 230,
+	'\U00010eac': 230,
+	'\U00010f46': 220,
+	'\U00010f47': 220,
+	'\U00010f48': 230,
+	'\U00010f49': 230,
+	'\U00010f4a': 230,
+	'\U00010f4b': 220,
+	'\U00010f4c': 230,
+	'\U00010f4d': 220,
+	'\U00010f4e': 220,
+	'\U00010f4f': 220,
+	'\U00010f50': 220,
+	'\U00010f82': 230,
+	'\U00010f83': 220,
+	'\U00010f84': 230,
+	'\U00010f85': 220,
+	'\U00011046': 9,
+	'\U00011070': 9,
+	'\U0001107f': 9,
+	'\U000110b9': 9,
+	'\U000110ba': 7,
+	'\U00011100': 230,
+	'\U00011101': 230,
+	'\U00011102': 230,
+	'\U00011133': 9,
+	'\U00011134': 9,
+	'\U00011173': 7,
+	'\U000111c0': 9,
+	'\U000111ca': 7,
+	'\U00011235': 9,
+	'\U00011236': 7,
+	'\U000112e9': 7,
+	'\U000112ea': 9,
+	'\U0001133b': 7,
+	'\U0001133c': 7,
+	'\U0001134d': 9,
+	'\U00011366': 230,
+	'\U00011367': 230,
+	'\U00011368': 230,
+	'\U00011369': 230,
+	'\U0001136a': 230,
+	'\U0001136b': 230,
+	'\U0001136c': 230,
+	'\U00011370': 230,
+	'\U00011371': 230,
+	'\U00011372': 230,
+	'\U00011373': 230,
+	'\U00011374': 230,
+	'\U00011442': 9,
+	'\U00011446': 7,
+	'\U0001145e': 230,
+	'\U000114c2': 9,
+	'\U000114c3': 7,
+	'\U000115bf': 9,
+	'\U000115c0': 7,
+	'\U0001163f': 9,
+	'\U000116b6': 9,
+	'\U000116b7': 7,
+	'\U0001172b': 9,
+	'\U00011839': 9,
+	'\U0001183a': 7,
+	'\U0001193d': 9,
+	'\U0001193e': 9,
+	'\U00011943': 7,
+	'\U000119e0': 9,
+	'\U00011a34': 9,
+	'\U00011a47': 9,
+	'\U00011a99': 9,
+	'\U00011c3f': 9,
+	'\U00011d42': 7,
+	'\U00011d44': 9,
+	'\U00011d45': 9,
+	'\U00011d97': 9,
+	'\U00016af0': 1,
+	'\U00016af1': 1,
+	'\U00016af2': 1,
+	'\U00016af3': 1,
+	'\U00016af4': 1,
+	'\U00016b30': 230,
+	'\U00016b31': 230,
+	'\U00016b32': 230,
+	'\U00016b33': 230,
+	'\U00016b34': 230,
+	'\U00016b35': 230,
+	'\U00016b36': 230,
+	'\U00016ff0': 6,
+	'\U00016ff1': 6,
+	'\U0001bc9e': 1,
+	'\U0001d165': 216,
+	'\U0001d166': 216,
+	'\U0001d167': 1,
+	'\U0001d168': 1,
+	'\U0001d169': 1,
+	'\U0001d16d': 226,
+	'\U0001d16e': 216,
+	'\U0001d16f': 216,
+	'\U0001d170': 216,
+	'\U0001d171': 216,
+	'\U0001d172': 216,
+	'\U0001d17b': 220,
+	'\U0001d17c': 220,
+	'\U0001d17d': 220,
+	'\U0001d17e': 220,
+	'\U0001d17f': 220,
+	'\U0001d180': 220,
+	'\U0001d181': 220,
+	'\U0001d182': 220,
+	'\U0001d185': 230,
+	'\U0001d186': 230,
+	'\U0001d187': 230,
+	'\U0001d188': 230,
+	'\U0001d189': 230,
+	'\U0001d18a': 220,
+	'\U0001d18b': 220,
+	'\U0001d1aa': 230,
+	'\U0001d1ab': 230,
+	'\U0001d1ac': 230,
+	'\U0001d1ad': 230,
+	'\U0001d242': 230,
+	'\U0001d243': 230,
+	'\U0001d244': 230,
+	'\U0001e000': 230,
+	'\U0001e001': 230,
+	'\U0001e002': 230,
+	'\U0001e003': 230,
+	'\U0001e004': 230,
+	'\U0001e005': 230,
+	'\U0001e006': 230,
+	'\U0001e008': 230,
+	'\U0001e009': 230,
+	'\U0001e00a': 230,
+	'\U0001e00b': 230,
+	'\U0001e00c': 230,
+	'\U0001e00d': 230,
+	'\U0001e00e': 230,
+	'\U0001e00f': 230,
+	'\U0001e010': 230,
+	'\U0001e011': 230,
+	'\U0001e012': 230,
+	'\U0001e013': 230,
+	'\U0001e014': 230,
+	'\U0001e015': 230,
+	'\U0001e016': 230,
+	'\U0001e017': 230,
+	'\U0001e018': 230,
+	'\U0001e01b': 230,
+	'\U0001e01c': 230,
+	'\U0001e01d': 230,
+	'\U0001e01e': 230,
+	'\U0001e01f': 230,
+	'\U0001e020': 230,
+	'\U0001e021': 230,
+	'\U0001e023': 230,
+	'\U0001e024': 230,
+	'\U0001e026': 230,
+	'\U0001e027': 230,
+	'\U0001e028': 230,
+	'\U0001e029': 230,
+	'\U0001e02a': 230,
+	'\U0001e130': 230,
+	'\U0001e131': 230,
+	'\U0001e132': 230,
+	'\U0001e133': 230,
+	'\U0001e134': 230,
+	'\U0001e135': 230,
+	'\U0001e136': 230,
+	'\U0001e2ae': 230,
+	'\U0001e2ec': 230,
+	'\U0001e2ed': 230,
+	'\U0001e2ee': 230,
+	'\U0001e2ef': 230,
+	'\U0001e8d0': 220,
+	'\U0001e8d1': 220,
+	'\U0001e8d2': 220,
+	'\U0001e8d3': 220,
+	'\U0001e8d4': 220,
+	'\U0001e8d5': 220,
+	'\U0001e8d6': 220,
+	'\U0001e944': 230,
+	'\U0001e945': 230,
+	'\U0001e946': 230,
+	'\U0001e947': 230,
+	'\U0001e948': 230,
+	'\U0001e949': 230,
+	'\U0001e94a': 7,
+}
+
+// compositions maps pairs of runes to the primary composite they make.
+var compositions = map[[2]rune]rune{
+	{'\u003c', '\u0338'}:         '\u226e',
+	{'\u003d', '\u0338'}:         '\u2260',
+	{'\u003e', '\u0338'}:         '\u226f',
+	{'\u0041', '\u0300'}:         '\u00c0',
+	{'\u0041', '\u0301'}:         '\u00c1',
+	{'\u0041', '\u0302'}:         '\u00c2',
+	{'\u0041', '\u0303'}:         '\u00c3',
+	{'\u0041', '\u0304'}:         '\u0100',
+	{'\u0041', '\u0306'}:         '\u0102',
+	{'\u0041', '\u0307'}:         '\u0226',
+	{'\u0041', '\u0308'}:         '\u00c4',
+	{'\u0041', '\u0309'}:         '\u1ea2',
+	{'\u0041', '\u030a'}:         '\u00c5',
+	{'\u0041', '\u030c'}:         '\u01cd',
+	{'\u0041', '\u030f'}:         '\u0200',
+	{'\u0041', '\u0311'}:         '\u0202',
+	{'\u0041', '\u0323'}:         '\u1ea0',
+	{'\u0041', '\u0325'}:         '\u1e00',
+	{'\u0041', '\u0328'}:         '\u0104',
+	{'\u0042', '\u0307'}:         '\u1e02',
+	{'\u0042', '\u0323'}:         '\u1e04',
+	{'\u0042', '\u0331'}:         '\u1e06',
+	{'\u0043', '\u0301'}:         '\u0106',
+	{'\u0043', '\u0302'}:         '\u0108',
+	{'\u0043', '\u0307'}:         '\u010a',
+	{'\u0043', '\u030c'}:         '\u010c',
+	{'\u0043', '\u0327'}:         '\u00c7',
+	{'\u0044', '\u0307'}:         '\u1e0a',
+	{'\u0044', '\u030c'}:         '\u010e',
+	{'\u0044', '\u0323'}:         '\u1e0c',
+	{'\u0044', '\u0327'}:         '\u1e10',
+	{'\u0044', '\u032d'}:         '\u1e12',
+	{'\u0044', '\u0331'}:         '\u1e0e',
+	{'\u0045', '\u0300'}:         '\u00c8',
+	{'\u0045', '\u0301'}:         '\u00c9',
+	{'\u0045', '\u0302'}:         '\u00ca',
+	{'\u0045', '\u0303'}:         '\u1ebc',
+	{'\u0045', '\u0304'}:         '\u0112',
+	{'\u0045', '\u0306'}:         '\u0114',
+	{'\u0045', '\u0307'}:         '\u0116',
+	{'\u0045', '\u0308'}:         '\u00cb',
+	{'\u0045', '\u0309'}:         '\u1eba',
+	{'\u0045', '\u030c'}:         '\u011a',
+	{'\u0045', '\u030f'}:         '\u0204',
+	{'\u0045', '\u0311'}:         '\u0206',
+	{'\u0045', '\u0323'}:         '\u1eb8',
+	{'\u0045', '\u0327'}:         '\u0228',
+	{'\u0045', '\u0328'}:         '\u0118',
+	{'\u0045', '\u032d'}:         '\u1e18',
+	{'\u0045', '\u0330'}:         '\u1e1a',
+	{'\u0046', '\u0307'}:         '\u1e1e',
+	{'\u0047', '\u0301'}:         '\u01f4',
+	{'\u0047', '\u0302'}:         '\u011c',
+	{'\u0047', '\u0304'}:         '\u1e20',
+	{'\u0047', '\u0306'}:         '\u011e',
+	{'\u0047', '\u0307'}:         '\u0120',
+	{'\u0047', '\u030c'}:         '\u01e6',
+	{'\u0047', '\u0327'}:         '\u0122',
+	{'\u0048', '\u0302'}:         '\u0124',
+	{'\u0048', '\u0307'}:         '\u1e22',
+	{'\u0048', '\u0308'}:         '\u1e26',
+	{'\u0048', '\u030c'}:         '\u021e',
+	{'\u0048', '\u0323'}:         '\u1e24',
+	{'\u0048', '\u0327'}:         '\u1e28',
+	{'\u0048', '\u032e'}:         '\u1e2a',
+	{'\u0049', '\u0300'}:         '\u00cc',
+	{'\u0049', '\u0301'}:         '\u00cd',
+	{'\u0049', '\u0302'}:         '\u00ce',
+	{'\u0049', '\u0303'}:         '\u0128',
+	{'\u0049', '\u0304'}:         '\u012a',
+	{'\u0049', '\u0306'}:         '\u012c',
+	{'\u0049', '\u0307'}:         '\u0130',
+	{'\u0049', '\u0308'}:         '\u00cf',
+	{'\u0049', '\u0309'}:         '\u1ec8',
+	{'\u0049', '\u030c'}:         '\u01cf',
+	{'\u0049', '\u030f'}:         '\u0208',
+	{'\u0049', '\u0311'}:         '\u020a',
+	{'\u0049', '\u0323'}:         '\u1eca',
+	{'\u0049', '\u0328'}:         '\u012e',
+	{'\u0049', '\u0330'}:         '\u1e2c',
+	{'\u004a', '\u0302'}:         '\u0134',
+	{'\u004b', '\u0301'}:         '\u1e30',
+	{'\u004b', '\u030c'}:         '\u01e8',
+	{'\u004b', '\u0323'}:         '\u1e32',
+	{'\u004b', '\u0327'}:         '\u0136',
+	{'\u004b', '\u0331'}:         '\u1e34',
+	{'\u004c', '\u0301'}:         '\u0139',
+	{'\u004c', '\u030c'}:         '\u013d',
+	{'\u004c', '\u0323'}:         '\u1e36',
+	{'\u004c', '\u0327'}:         '\u013b',
+	{'\u004c', '\u032d'}:         '\u1e3c',
+	{'\u004c', '\u0331'}:         '\u1e3a',
+	{'\u004d', '\u0301'}:         '\u1e3e',
+	{'\u004d', '\u0307'}:         '\u1e40',
+	{'\u004d', '\u0323'}:         '\u1e42',
+	{'\u004e', '\u0300'}:         '\u01f8',
+	{'\u004e', '\u0301'}:         '\u0143',
+	{'\u004e', '\u0303'}:         '\u00d1',
+	{'\u004e', '\u0307'}:         '\u1e44',
+	{'\u004e', '\u030c'}:         '\u0147',
+	{'\u004e', '\u0323'}:         '\u1e46',
+	{'\u004e', '\u0327'}:         '\u0145',
+	{'\u004e', '\u032d'}:         '\u1e4a',
+	{'\u004e', '\u0331'}:         '\u1e48',
+	{'\u004f', '\u0300'}:         '\u00d2',
+	{'\u004f', '\u0301'}:         '\u00d3',
+	{'\u004f', '\u0302'}:         '\u00d4',
+	{'\u004f', '\u0303'}:         '\u00d5',
+	{'\u004f', '\u0304'}:         '\u014c',
+	{'\u004f', '\u0306'}:         '\u014e',
+	{'\u004f', '\u0307'}:         '\u022e',
+	{'\u004f', '\u0308'}:         '\u00d6',
+	{'\u004f', '\u0309'}:         '\u1ece',
+	{'\u004f', '\u030b'}:         '\u0150',
+	{'\u004f', '\u030c'}:         '\u01d1',
+	{'\u004f', '\u030f'}:         '\u020c',
+	{'\u004f', '\u0311'}:         '\u020e',
+	{'\u004f', '\u031b'}:         '\u01a0',
+	{'\u004f', '\u0323'}:         '\u1ecc',
+	{'\u004f', '\u0328'}:         '\u01ea',
+	{'\u0050', '\u0301'}:         '\u1e54',
+	{'\u0050', '\u0307'}:         '\u1e56',
+	{'\u0052', '\u0301'}:         '\u0154',
+	{'\u0052', '\u0307'}:         '\u1e58',
+	{'\u0052', '\u030c'}:         '\u0158',
+	{'\u0052', '\u030f'}:         '\u0210',
+	{'\u0052', '\u0311'}:         '\u0212',
+	{'\u0052', '\u0323'}:         '\u1e5a',
+	{'\u0052', '\u0327'}:         '\u0156',
+	{'\u0052', '\u0331'}:         '\u1e5e',
+	{'\u0053', '\u0301'}:         '\u015a',
+	{'\u0053', '\u0302'}:         '\u015c',
+	{'\u0053', '\u0307'}:         '\u1e60',
+	{'\u0053', '\u030c'}:         '\u0160',
+	{'\u0053', '\u0323'}:         '\u1e62',
+	{'\u0053', '\u0326'}:         '\u0218',
+	{'\u0053', '\u0327'}:         '\u015e',
+	{'\u0054', '\u0307'}:         '\u1e6a',
+	{'\u0054', '\u030c'}:         '\u0164',
+	{'\u0054', '\u0323'}:         '\u1e6c',
+	{'\u0054', '\u0326'}:         '\u021a',
+	{'\u0054', '\u0327'}:         '\u0162',
+	{'\u0054', '\u032d'}:         '\u1e70',
+	{'\u0054', '\u0331'}:         '\u1e6e',
+	{'\u0055', '\u0300'}:         '\u00d9',
+	{'\u0055', '\u0301'}:         '\u00da',
+	{'\u0055', '\u0302'}:         '\u00db',
+	{'\u0055', '\u0303'}:         '\u0168',
+	{'\u0055', '\u0304'}:         '\u016a',
+	{'\u0055', '\u0306'}:         '\u016c',
+	{'\u0055', '\u0308'}:         '\u00dc',
+	{'\u0055', '\u0309'}:         '\u1ee6',
+	{'\u0055', '\u030a'}:         '\u016e',
+	{'\u0055', '\u030b'}:         '\u0170',
+	{'\u0055', '\u030c'}:         '\u01d3',
+	{'\u0055', '\u030f'}:         '\u0214',
+	{'\u0055', '\u0311'}:         '\u0216',
+	{'\u0055', '\u031b'}:         '\u01af',
+	{'\u0055', '\u0323'}:         '\u1ee4',
+	{'\u0055', '\u0324'}:         '\u1e72',
+	{'\u0055', '\u0328'}:         '\u0172',
+	{'\u0055', '\u032d'}:         '\u1e76',
+	{'\u0055', '\u0330'}:         '\u1e74',
+	{'\u0056', '\u0303'}:         '\u1e7c',
+	{'\u0056', '\u0323'}:         '\u1e7e',
+	{'\u0057', '\u0300'}:         '\u1e80',
+	{'\u0057', '\u0301'}:         '\u1e82',
+	{'\u0057', '\u0302'}:         '\u0174',
+	{'\u0057', '\u0307'}:         '\u1e86',
+	{'\u0057', '\u0308'}:         '\u1e84',
+	{'\u0057', '\u0323'}:         '\u1e88',
+	{'\u0058', '\u0307'}:         '\u1e8a',
+	{'\u0058', '\u0308'}:         '\u1e8c',
+	{'\u0059', '\u0300'}:         '\u1ef2',
+	{'\u0059', '\u0301'}:         '\u00dd',
+	{'\u0059', '\u0302'}:         '\u0176',
+	{'\u0059', '\u0303'}:         '\u1ef8',
+	{'\u0059', '\u0304'}:         '\u0232',
+	{'\u0059', '\u0307'}:         '\u1e8e',
+	{'\u0059', '\u0308'}:         '\u0178',
+	{'\u0059', '\u0309'}:         '\u1ef6',
+	{'\u0059', '\u0323'}:         '\u1ef4',
+	{'\u005a', '\u0301'}:         '\u0179',
+	{'\u005a', '\u0302'}:         '\u1e90',
+	{'\u005a', '\u0307'}:         '\u017b',
+	{'\u005a', '\u030c'}:         '\u017d',
+	{'\u005a', '\u0323'}:         '\u1e92',
+	{'\u005a', '\u0331'}:         '\u1e94',
+	{'\u0061', '\u0300'}:         '\u00e0',
+	{'\u0061', '\u0301'}:         '\u00e1',
+	{'\u0061', '\u0302'}:         '\u00e2',
+	{'\u0061', '\u0303'}:         '\u00e3',
+	{'\u0061', '\u0304'}:         '\u0101',
+	{'\u0061', '\u0306'}:         '\u0103',
+	{'\u0061', '\u0307'}:         '\u0227',
+	{'\u0061', '\u0308'}:         '\u00e4',
+	{'\u0061', '\u0309'}:         '\u1ea3',
+	{'\u0061', '\u030a'}:         '\u00e5',
+	{'\u0061', '\u030c'}:         '\u01ce',
+	{'\u0061', '\u030f'}:         '\u0201',
+	{'\u0061', '\u0311'}:         '\u0203',
+	{'\u0061', '\u0323'}:         '\u1ea1',
+	{'\u0061', '\u0325'}:         '\u1e01',
+	{'\u0061', '\u0328'}:         '\u0105',
+	{'\u0062', '\u0307'}:         '\u1e03',
+	{'\u0062', '\u0323'}:         '\u1e05',
+	{'\u0062', '\u0331'}:         '\u1e07',
+	{'\u0063', '\u0301'}:         '\u0107',
+	{'\u0063', '\u0302'}:         '\u0109',
+	{'\u0063', '\u0307'}:         '\u010b',
+	{'\u0063', '\u030c'}:         '\u010d',
+	{'\u0063', '\u0327'}:         '\u00e7',
+	{'\u0064', '\u0307'}:         '\u1e0b',
+	{'\u0064', '\u030c'}:         '\u010f',
+	{'\u0064', '\u0323'}:         '\u1e0d',
+	{'\u0064', '\u0327'}:         '\u1e11',
+	{'\u0064', '\u032d'}:         '\u1e13',
+	{'\u0064', '\u0331'}:         '\u1e0f',
+	{'\u0065', '\u0300'}:         '\u00e8',
+	{'\u0065', '\u0301'}:         '\u00e9',
+	{'\u0065', '\u0302'}:         '\u00ea',
+	{'\u0065', '\u0303'}:         '\u1ebd',
+	{'\u0065', '\u0304'}:         '\u0113',
+	{'\u0065', '\u0306'}:         '\u0115',
+	{'\u0065', '\u0307'}:         '\u0117',
+	{'\u0065', '\u0308'}:         '\u00eb',
+	{'\u0065', '\u0309'}:         '\u1ebb',
+	{'\u0065', '\u030c'}:         '\u011b',
+	{'\u0065', '\u030f'}:         '\u0205',
+	{'\u0065', '\u0311'}:         '\u0207',
+	{'\u0065', '\u0323'}:         '\u1eb9',
+	{'\u0065', '\u0327'}:         '\u0229',
+	{'\u0065', '\u0328'}:         '\u0119',
+	{'\u0065', '\u032d'}:         '\u1e19',
+	{'\u0065', '\u0330'}:         '\u1e1b',
+	{'\u0066', '\u0307'}:         '\u1e1f',
+	{'\u0067', '\u0301'}:         '\u01f5',
+	{'\u0067', '\u0302'}:         '\u011d',
+	{'\u0067', '\u0304'}:         '\u1e21',
+	{'\u0067', '\u0306'}:         '\u011f',
+	{'\u0067', '\u0307'}:         '\u0121',
+	{'\u0067', '\u030c'}:         '\u01e7',
+	{'\u0067', '\u0327'}:         '\u0123',
+	{'\u0068', '\u0302'}:         '\u0125',
+	{'\u0068', '\u0307'}:         '\u1e23',
+	{'\u0068', '\u0308'}:         '\u1e27',
+	{'\u0068', '\u030c'}:         '\u021f',
+	{'\u0068', '\u0323'}:         '\u1e25',
+	{'\u0068', '\u0327'}:         '\u1e29',
+	{'\u0068', '\u032e'}:         '\u1e2b',
+	{'\u0068', '\u0331'}:         '\u1e96',
+	{'\u0069', '\u0300'}:         '\u00ec',
+	{'\u0069', '\u0301'}:         '\u00ed',
+	{'\u0069', '\u0302'}:         '\u00ee',
+	{'\u0069', '\u0303'}:         '\u0129',
+	{'\u0069', '\u0304'}:         '\u012b',
+	{'\u0069', '\u0306'}:         '\u012d',
+	{'\u0069', '\u0308'}:         '\u00ef',
+	{'\u0069', '\u0309'}:         '\u1ec9',
+	{'\u0069', '\u030c'}:         '\u01d0',
+	{'\u0069', '\u030f'}:         '\u0209',
+	{'\u0069', '\u0311'}:         '\u020b',
+	{'\u0069', '\u0323'}:         '\u1ecb',
+	{'\u0069', '\u0328'}:         '\u012f',
+	{'\u0069', '\u0330'}:         '\u1e2d',
+	{'\u006a', '\u0302'}:         '\u0135',
+	{'\u006a', '\u030c'}:         '\u01f0',
+	{'\u006b', '\u0301'}:         '\u1e31',
+	{'\u006b', '\u030c'}:         '\u01e9',
+	{'\u006b', '\u0323'}:         '\u1e33',
+	{'\u006b', '\u0327'}:         '\u0137',
+	{'\u006b', '\u0331'}:         '\u1e35',
+	{'\u006c', '\u0301'}:         '\u013a',
+	{'\u006c', '\u030c'}:         '\u013e',
+	{'\u006c', '\u0323'}:         '\u1e37',
+	{'\u006c', '\u0327'}:         '\u013c',
+	{'\u006c', '\u032d'}:         '\u1e3d',
+	{'\u006c', '\u0331'}:         '\u1e3b',
+	{'\u006d', '\u0301'}:         '\u1e3f',
+	{'\u006d', '\u0307'}:         '\u1e41',
+	{'\u006d', '\u0323'}:         '\u1e43',
+	{'\u006e', '\u0300'}:         '\u01f9',
+	{'\u006e', '\u0301'}:         '\u0144',
+	{'\u006e', '\u0303'}:         '\u00f1',
+	{'\u006e', '\u0307'}:         '\u1e45',
+	{'\u006e', '\u030c'}:         '\u0148',
+	{'\u006e', '\u0323'}:         '\u1e47',
+	{'\u006e', '\u0327'}:         '\u0146',
+	{'\u006e', '\u032d'}:         '\u1e4b',
+	{'\u006e', '\u0331'}:         '\u1e49',
+	{'\u006f', '\u0300'}:         '\u00f2',
+	{'\u006f', '\u0301'}:         '\u00f3',
+	{'\u006f', '\u0302'}:         '\u00f4',
+	{'\u006f', '\u0303'}:         '\u00f5',
+	{'\u006f', '\u0304'}:         '\u014d',
+	{'\u006f', '\u0306'}:         '\u014f',
+	{'\u006f', '\u0307'}:         '\u022f',
+	{'\u006f', '\u0308'}:         '\u00f6',
+	{'\u006f', '\u0309'}:         '\u1ecf',
+	{'\u006f', '\u030b'}:         '\u0151',
+	{'\u006f', '\u030c'}:         '\u01d2',
+	{'\u006f', '\u030f'}:         '\u020d',
+	{'\u006f', '\u0311'}:         '\u020f',
+	{'\u006f', '\u031b'}:         '\u01a1',
+	{'\u006f', '\u0323'}:         '\u1ecd',
+	{'\u006f', '\u0328'}:         '\u01eb',
+	{'\u0070', '\u0301'}:         '\u1e55',
+	{'\u0070', '\u0307'}:         '\u1e57',
+	{'\u0072', '\u0301'}:         '\u0155',
+	{'\u0072', '\u0307'}:         '\u1e59',
+	{'\u0072', '\u030c'}:         '\u0159',
+	{'\u0072', '\u030f'}:         '\u0211',
+	{'\u0072', '\u0311'}:         '\u0213',
+	{'\u0072', '\u0323'}:         '\u1e5b',
+	{'\u0072', '\u0327'}:         '\u0157',
+	{'\u0072', '\u0331'}:         '\u1e5f',
+	{'\u0073', '\u0301'}:         '\u015b',
+	{'\u0073', '\u0302'}:         '\u015d',
+	{'\u0073', '\u0307'}:         '\u1e61',
+	{'\u0073', '\u030c'}:         '\u0161',
+	{'\u0073', '\u0323'}:         '\u1e63',
+	{'\u0073', '\u0326'}:         '\u0219',
+	{'\u0073', '\u0327'}:         '\u015f',
+	{'\u0074', '\u0307'}:         '\u1e6b',
+	{'\u0074', '\u0308'}:         '\u1e97',
+	{'\u0074', '\u030c'}:         '\u0165',
+	{'\u0074', '\u0323'}:         '\u1e6d',
+	{'\u0074', '\u0326'}:         '\u021b',
+	{'\u0074', '\u0327'}:         '\u0163',
+	{'\u0074', '\u032d'}:         '\u1e71',
+	{'\u0074', '\u0331'}:         '\u1e6f',
+	{'\u0075', '\u0300'}:         '\u00f9',
+	{'\u0075', '\u0301'}:         '\u00fa',
+	{'\u0075', '\u0302'}:         '\u00fb',
+	{'\u0075', '\u0303'}:         '\u0169',
+	{'\u0075', '\u0304'}:         '\u016b',
+	{'\u0075', '\u0306'}:         '\u016d',
+	{'\u0075', '\u0308'}:         '\u00fc',
+	{'\u0075', '\u0309'}:         '\u1ee7',
+	{'\u0075', '\u030a'}:         '\u016f',
+	{'\u0075', '\u030b'}:         '\u0171',
+	{'\u0075', '\u030c'}:         '\u01d4',
+	{'\u0075', '\u030f'}:         '\u0215',
+	{'\u0075', '\u0311'}:         '\u0217',
+	{'\u0075', '\u031b'}:         '\u01b0',
+	{'\u0075', '\u0323'}:         '\u1ee5',
+	{'\u0075', '\u0324'}:         '\u1e73',
+	{'\u0075', '\u0328'}:         '\u0173',
+	{'\u0075', '\u032d'}:         '\u1e77',
+	{'\u0075', '\u0330'}:         '\u1e75',
+	{'\u0076', '\u0303'}:         '\u1e7d',
+	{'\u0076', '\u0323'}:         '\u1e7f',
+	{'\u0077', '\u0300'}:         '\u1e81',
+	{'\u0077', '\u0301'}:         '\u1e83',
+	{'\u0077', '\u0302'}:         '\u0175',
+	{'\u0077', '\u0307'}:         '\u1e87',
+	{'\u0077', '\u0308'}:         '\u1e85',
+	{'\u0077', '\u030a'}:         '\u1e98',
+	{'\u0077', '\u0323'}:         '\u1e89',
+	{'\u0078', '\u0307'}:         '\u1e8b',
+	{'\u0078', '\u0308'}:         '\u1e8d',
+	{'\u0079', '\u0300'}:         '\u1ef3',
+	{'\u0079', '\u0301'}:         '\u00fd',
+	{'\u0079', '\u0302'}:         '\u0177',
+	{'\u0079', '\u0303'}:         '\u1ef9',
+	{'\u0079', '\u0304'}:         '\u0233',
+	{'\u0079', '\u0307'}:         '\u1e8f',
+	{'\u0079', '\u0308'}:         '\u00ff',
+	{'\u0079', '\u0309'}:         '\u1ef7',
+	{'\u0079', '\u030a'}:         '\u1e99',
+	{'\u0079', '\u0323'}:         '\u1ef5',
+	{'\u007a', '\u0301'}:         '\u017a',
+	{'\u007a', '\u0302'}:         '\u1e91',
+	{'\u007a', '\u0307'}:         '\u017c',
+	{'\u007a', '\u030c'}:         '\u017e',
+	{'\u007a', '\u0323'}:         '\u1e93',
+	{'\u007a', '\u0331'}:         '\u1e95',
+	{'\u00a8', '\u0300'}:         '\u1fed',
+	{'\u00a8', '\u0301'}:         '\u0385',
+	{'\u00a8', '\u0342'}:         '\u1fc1',
+	{'\u00c2', '\u0300'}:         '\u1ea6',
+	{'\u00c2', '\u0301'}:         '\u1ea4',
+	{'\u00c2', '\u0303'}:         '\u1eaa',
+	{'\u00c2', '\u0309'}:         '\u1ea8',
+	{'\u00c4', '\u0304'}:         '\u01de',
+	{'\u00c5', '\u0301'}:         '\u01fa',
+	{'\u00c6', '\u0301'}:         '\u01fc',
+	{'\u00c6', '\u0304'}:         '\u01e2',
+	{'\u00c7', '\u0301'}:         '\u1e08',
+	{'\u00ca', '\u0300'}:         '\u1ec0',
+	{'\u00ca', '\u0301'}:         '\u1ebe',
+	{'\u00ca', '\u0303'}:         '\u1ec4',
+	{'\u00ca', '\u0309'}:         '\u1ec2',
+	{'\u00cf', '\u0301'}:         '\u1e2e',
+	{'\u00d4', '\u0300'}:         '\u1ed2',
+	{'\u00d4', '\u0301'}:         '\u1ed0',
+	{'\u00d4', '\u0303'}:         '\u1ed6',
+	{'\u00d4', '\u0309'}:         '\u1ed4',
+	{'\u00d5', '\u0301'}:         '\u1e4c',
+	{'\u00d5', '\u0304'}:         '\u022c',
+	{'\u00d5', '\u0308'}:         '\u1e4e',
+	{'\u00d6', '\u0304'}:         '\u022a',
+	{'\u00d8', '\u0301'}:         '\u01fe',
+	{'\u00dc', '\u0300'}:         '\u01db',
+	{'\u00dc', '\u0301'}:         '\u01d7',
+	{'\u00dc', '\u0304'}:         '\u01d5',
+	{'\u00dc', '\u030c'}:         '\u01d9',
+	{'\u00e2', '\u0300'}:         '\u1ea7',
+	{'\u00e2', '\u0301'}:         '\u1ea5',
+	{'\u00e2', '\u0303'}:         '\u1eab',
+	{'\u00e2', '\u0309'}:         '\u1ea9',
+	{'\u00e4', '\u0304'}:         '\u01df',
+	{'\u00e5', '\u0301'}:         '\u01fb',
+	{'\u00e6', '\u0301'}:         '\u01fd',
+	{'\u00e6', '\u0304'}:         '\u01e3',
+	{'\u00e7', '\u0301'}:         '\u1e09',
+	{'\u00ea', '\u0300'}:         '\u1ec1',
+	{'\u00ea', '\u0301'}:         '\u1ebf',
+	{'\u00ea', '\u0303'}:         '\u1ec5',
+	{'\u00ea', '\u0309'}:         '\u1ec3',
+	{'\u00ef', '\u0301'}:         '\u1e2f',
+	{'\u00f4', '\u0300'}:         '\u1ed3',
+	{'\u00f4', '\u0301'}:         '\u1ed1',
+	{'\u00f4', '\u0303'}:         '\u1ed7',
+	{'\u00f4', '\u0309'}:         '\u1ed5',
+	{'\u00f5', '\u0301'}:         '\u1e4d',
+	{'\u00f5', '\u0304'}:         '\u022d',
+	{'\u00f5', '\u0308'}:         '\u1e4f',
+	{'\u00f6', '\u0304'}:         '\u022b',
+	{'\u00f8', '\u0301'}:         '\u01ff',
+	{'\u00fc', '\u0300'}:         '\u01dc',
+	{'\u00fc', '\u0301'}:         '\u01d8',
+	{'\u00fc', '\u0304'}:         '\u01d6',
+	{'\u00fc', '\u030c'}:         '\u01da',
+	{'\u0102', '\u0300'}:         '\u1eb0',
+	{'\u0102', '\u0301'}:         '\u1eae',
+	{'\u0102', '\u0303'}:         '\u1eb4',
+	{'\u0102', '\u0309'}:         '\u1eb2',
+	{'\u0103', '\u0300'}:         '\u1eb1',
+	{'\u0103', '\u0301'}:         '\u1eaf',
+	{'\u0103', '\u0303'}:         '\u1eb5',
+	{'\u0103', '\u0309'}:         '\u1eb3',
+	{'\u0112', '\u0300'}:         '\u1e14',
+	{'\u0112', '\u0301'}:         '\u1e16',
+	{'\u0113', '\u0300'}:         '\u1e15',
+	{'\u0113', '\u0301'}:         '\u1e17',
+	{'\u014c', '\u0300'}:         '\u1e50',
+	{'\u014c', '\u0301'}:         '\u1e52',
+	{'\u014d', '\u0300'}:         '\u1e51',
+	{'\u014d', '\u0301'}:         '\u1e53',
+	{'\u015a', '\u0307'}:         '\u1e64',
+	{'\u015b', '\u0307'}:         '\u1e65',
+	{'\u0160', '\u0307'}:         '\u1e66',
+	{'\u0161', '\u0307'}:         '\u1e67',
+	{'\u0168', '\u0301'}:         '\u1e78',
+	{'\u0169', '\u0301'}:         '\u1e79',
+	{'\u016a', '\u0308'}:         '\u1e7a',
+	{'\u016b', '\u0308'}:         '\u1e7b',
+	{'\u017f', '\u0307'}:         '\u1e9b',
+	{'\u01a0', '\u0300'}:         '\u1edc',
+	{'\u01a0', '\u0301'}:         '\u1eda',
+	{'\u01a0', '\u0303'}:         '\u1ee0',
+	{'\u01a0', '\u0309'}:         '\u1ede',
+	{'\u01a0', '\u0323'}:         '\u1ee2',
+	{'\u01a1', '\u0300'}:         '\u1edd',
+	{'\u01a1', '\u0301'}:         '\u1edb',
+	{'\u01a1', '\u0303'}:         '\u1ee1',
+	{'\u01a1', '\u0309'}:         '\u1edf',
+	{'\u01a1', '\u0323'}:         '\u1ee3',
+	{'\u01af', '\u0300'}:         '\u1eea',
+	{'\u01af', '\u0301'}:         '\u1ee8',
+	{'\u01af', '\u0303'}:         '\u1eee',
+	{'\u01af', '\u0309'}:         '\u1eec',
+	{'\u01af', '\u0323'}:         '\u1ef0',
+	{'\u01b0', '\u0300'}:         '\u1eeb',
+	{'\u01b0', '\u0301'}:         '\u1ee9',
+	{'\u01b0', '\u0303'}:         '\u1eef',
+	{'\u01b0', '\u0309'}:         '\u1eed',
+	{'\u01b0', '\u0323'}:         '\u1ef1',
+	{'\u01b7', '\u030c'}:         '\u01ee',
+	{'\u01ea', '\u0304'}:         '\u01ec',
+	{'\u01eb', '\u0304'}:         '\u01ed',
+	{'\u0226', '\u0304'}:         '\u01e0',
+	{'\u0227', '\u0304'}:         '\u01e1',
+	{'\u0228', '\u0306'}:         '\u1e1c',
+	{'\u0229', '\u0306'}:         '\u1e1d',
+	{'\u022e', '\u0304'}:         '\u0230',
+	{'\u022f', '\u0304'}:         '\u0231',
+	{'\u0292', '\u030c'}:         '\u01ef',
+	{'\u0391', '\u0300'}:         '\u1fba',
+	{'\u0391', '\u0301'}:         '\u0386',
+	{'\u0391', '\u0304'}:         '\u1fb9',
+	{'\u0391', '\u0306'}:         '\u1fb8',
+	{'\u0391', '\u0313'}:         '\u1f08',
+	{'\u0391', '\u0314'}:         '\u1f09',
+	{'\u0391', '\u0345'}:         '\u1fbc',
+	{'\u0395', '\u0300'}:         '\u1fc8',
+	{'\u0395', '\u0301'}:         '\u0388',
+	{'\u0395', '\u0313'}:         '\u1f18',
+	{'\u0395', '\u0314'}:         '\u1f19',
+	{'\u0397', '\u0300'}:         '\u1fca',
+	{'\u0397', '\u0301'}:         '\u0389',
+	{'\u0397', '\u0313'}:         '\u1f28',
+	{'\u0397', '\u0314'}:         '\u1f29',
+	{'\u0397', '\u0345'}:         '\u1fcc',
+	{'\u0399', '\u0300'}:         '\u1fda',
+	{'\u0399', '\u0301'}:         '\u038a',
+	{'\u0399', '\u0304'}:         '\u1fd9',
+	{'\u0399', '\u0306'}:         '\u1fd8',
+	{'\u0399', '\u0308'}:         '\u03aa',
+	{'\u0399', '\u0313'}:         '\u1f38',
+	{'\u0399', '\u0314'}:         '\u1f39',
+	{'\u039f', '\u0300'}:         '\u1ff8',
+	{'\u039f', '\u0301'}:         '\u038c',
+	{'\u039f', '\u0313'}:         '\u1f48',
+	{'\u039f', '\u0314'}:         '\u1f49',
+	{'\u03a1', '\u0314'}:         '\u1fec',
+	{'\u03a5', '\u0300'}:         '\u1fea',
+	{'\u03a5', '\u0301'}:         '\u038e',
+	{'\u03a5', '\u0304'}:         '\u1fe9',
+	{'\u03a5', '\u0306'}:         '\u1fe8',
+	{'\u03a5', '\u0308'}:         '\u03ab',
+	{'\u03a5', '\u0314'}:         '\u1f59',
+	{'\u03a9', '\u0300'}:         '\u1ffa',
+	{'\u03a9', '\u0301'}:         '\u038f',
+	{'\u03a9', '\u0313'}:         '\u1f68',
+	{'\u03a9', '\u0314'}:         '\u1f69',
+	{'\u03a9', '\u0345'}:         '\u1ffc',
+	{'\u03ac', '\u0345'}:         '\u1fb4',
+	{'\u03ae', '\u0345'}:         '\u1fc4',
+	{'\u03b1', '\u0300'}:         '\u1f70',
+	{'\u03b1', '\u0301'}:         '\u03ac',
+	{'\u03b1', '\u0304'}:         '\u1fb1',
+	{'\u03b1', '\u0306'}:         '\u1fb0',
+	{'\u03b1', '\u0313'}:         '\u1f00',
+	{'\u03b1', '\u0314'}:         '\u1f01',
+	{'\u03b1', '\u0342'}:         '\u1fb6',
+	{'\u03b1', '\u0345'}:         '\u1fb3',
+	{'\u03b5', '\u0300'}:         '\u1f72',
+	{'\u03b5', '\u0301'}:         '\u03ad',
+	{'\u03b5', '\u0313'}:         '\u1f10',
+	{'\u03b5', '\u0314'}:         '\u1f11',
+	{'\u03b7', '\u0300'}:         '\u1f74',
+	{'\u03b7', '\u0301'}:         '\u03ae',
+	{'\u03b7', '\u0313'}:         '\u1f20',
+	{'\u03b7', '\u0314'}:         '\u1f21',
+	{'\u03b7', '\u0342'}:         '\u1fc6',
+	{'\u03b7', '\u0345'}:         '\u1fc3',
+	{'\u03b9', '\u0300'}:         '\u1f76',
+	{'\u03b9', '\u0301'}:         '\u03af',
+	{'\u03b9', '\u0304'}:         '\u1fd1',
+	{'\u03b9', '\u0306'}:         '\u1fd0',
+	{'\u03b9', '\u0308'}:         '\u03ca',
+	{'\u03b9', '\u0313'}:         '\u1f30',
+	{'\u03b9', '\u0314'}:         '\u1f31',
+	{'\u03b9', '\u0342'}:         '\u1fd6',
+	{'\u03bf', '\u0300'}:         '\u1f78',
+	{'\u03bf', '\u0301'}:         '\u03cc',
+	{'\u03bf', '\u0313'}:         '\u1f40',
+	{'\u03bf', '\u0314'}:         '\u1f41',
+	{'\u03c1', '\u0313'}:         '\u1fe4',
+	{'\u03c1', '\u0314'}:         '\u1fe5',
+	{'\u03c5', '\u0300'}:         '\u1f7a',
+	{'\u03c5', '\u0301'}:         '\u03cd',
+	{'\u03c5', '\u0304'}:         '\u1fe1',
+	{'\u03c5', '\u0306'}:         '\u1fe0',
+	{'\u03c5', '\u0308'}:         '\u03cb',
+	{'\u03c5', '\u0313'}:         '\u1f50',
+	{'\u03c5', '\u0314'}:         '\u1f51',
+	{'\u03c5', '\u0342'}:         '\u1fe6',
+	{'\u03c9', '\u0300'}:         '\u1f7c',
+	{'\u03c9', '\u0301'}:         '\u03ce',
+	{'\u03c9', '\u0313'}:         '\u1f60',
+	{'\u03c9', '\u0314'}:         '\u1f61',
+	{'\u03c9', '\u0342'}:         '\u1ff6',
+	{'\u03c9', '\u0345'}:         '\u1ff3',
+	{'\u03ca', '\u0300'}:         '\u1fd2',
+	{'\u03ca', '\u0301'}:         '\u0390',
+	{'\u03ca', '\u0342'}:         '\u1fd7',
+	{'\u03cb', '\u0300'}:         '\u1fe2',
+	{'\u03cb', '\u0301'}:         '\u03b0',
+	{'\u03cb', '\u0342'}:         '\u1fe7',
+	{'\u03ce', '\u0345'}:         '\u1ff4',
+	{'\u03d2', '\u0301'}:         '\u03d3',
+	{'\u03d2', '\u0308'}:         '\u03d4',
+	{'\u0406', '\u0308'}:         '\u0407',
+	{'\u0410', '\u0306'}:         '\u04d0',
+	{'\u0410', '\u0308'}:         '\u04d2',
+	{'\u0413', '\u0301'}:         '\u0403',
+	{'\u0415', '\u0300'}:         '\u0400',
+	{'\u0415', '\u0306'}:         '\u04d6',
+	{'\u0415', '\u0308'}:         '\u0401',
+	{'\u0416', '\u0306'}:         '\u04c1',
+	{'\u0416', '\u0308'}:         '\u04dc',
+	{'\u0417', '\u0308'}:         '\u04de',
+	{'\u0418', '\u0300'}:         '\u040d',
+	{'\u0418', '\u0304'}:         '\u04e2',
+	{'\u0418', '\u0306'}:         '\u0419',
+	{'\u0418', '\u0308'}:         '\u04e4',
+	{'\u041a', '\u0301'}:         '\u040c',
+	{'\u041e', '\u0308'}:         '\u04e6',
+	{'\u0423', '\u0304'}:         '\u04ee',
+	{'\u0423', '\u0306'}:         '\u040e',
+	{'\u0423', '\u0308'}:         '\u04f0',
+	{'\u0423', '\u030b'}:         '\u04f2',
+	{'\u0427', '\u0308'}:         '\u04f4',
+	{'\u042b', '\u0308'}:         '\u04f8',
+	{'\u042d', '\u0308'}:         '\u04ec',
+	{'\u0430', '\u0306'}:         '\u04d1',
+	{'\u0430', '\u0308'}:         '\u04d3',
+	{'\u0433', '\u0301'}:         '\u0453',
+	{'\u0435', '\u0300'}:         '\u0450',
+	{'\u0435', '\u0306'}:         '\u04d7',
+	{'\u0435', '\u0308'}:         '\u0451',
+	{'\u0436', '\u0306'}:         '\u04c2',
+	{'\u0436', '\u0308'}:         '\u04dd',
+	{'\u0437', '\u0308'}:         '\u04df',
+	{'\u0438', '\u0300'}:         '\u045d',
+	{'\u0438', '\u0304'}:         '\u04e3',
+	{'\u0438', '\u0306'}:         '\u0439',
+	{'\u0438', '\u0308'}:         '\u04e5',
+	{'\u043a', '\u0301'}:         '\u045c',
+	{'\u043e', '\u0308'}:         '\u04e7',
+	{'\u0443', '\u0304'}:         '\u04ef',
+	{'\u0443', '\u0306'}:         '\u045e',
+	{'\u0443', '\u0308'}:         '\u04f1',
+	{'\u0443', '\u030b'}:         '\u04f3',
+	{'\u0447', '\u0308'}:         '\u04f5',
+	{'\u044b', '\u0308'}:         '\u04f9',
+	{'\u044d', '\u0308'}:         '\u04ed',
+	{'\u0456', '\u0308'}:         '\u0457',
+	{'\u0474', '\u030f'}:         '\u0476',
+	{'\u0475', '\u030f'}:         '\u0477',
+	{'\u04d8', '\u0308'}:         '\u04da',
+	{'\u04d9', '\u0308'}:         '\u04db',
+	{'\u04e8', '\u0308'}:         '\u04ea',
+	{'\u04e9', '\u0308'}:         '\u04eb',
+	{'\u0627', '\u0653'}:         '\u0622',
+	{'\u0627', '\u0654'}:         '\u0623',
+	{'\u0627', '\u0655'}:         '\u0625',
+	{'\u0648', '\u0654'}:         '\u0624',
+	{'\u064a', '\u0654'}:         '\u0626',
+	{'\u06c1', '\u0654'}:         '\u06c2',
+	{'\u06d2', '\u0654'}:         '\u06d3',
+	{'\u06d5', '\u0654'}:         '\u06c0',
+	{'\u0928', '\u093c'}:         '\u0929',
+	{'\u0930', '\u093c'}:         '\u0931',
+	{'\u0933', '\u093c'}:         '\u0934',
+	{'\u09c7', '\u09be'}:         '\u09cb',
+	{'\u09c7', '\u09d7'}:         '\u09cc',
+	{'\u0b47', '\u0b3e'}:         '\u0b4b',
+	{'\u0b47', '\u0b56'}:         '\u0b48',
+	{'\u0b47', '\u0b57'}:         '\u0b4c',
+	{'\u0b92', '\u0bd7'}:         '\u0b94',
+	{'\u0bc6', '\u0bbe'}:         '\u0bca',
+	{'\u0bc6', '\u0bd7'}:         '\u0bcc',
+	{'\u0bc7', '\u0bbe'}:         '\u0bcb',
+	{'\u0c46', '\u0c56'}:         '\u0c48',
+	{'\u0cbf', '\u0cd5'}:         '\u0cc0',
+	{'\u0cc6', '\u0cc2'}:         '\u0cca',
+	{'\u0cc6', '\u0cd5'}:         '\u0cc7',
+	{'\u0cc6', '\u0cd6'}:         '\u0cc8',
+	{'\u0cca', '\u0cd5'}:         '\u0ccb',
+	{'\u0d46', '\u0d3e'}:         '\u0d4a',
+	{'\u0d46', '\u0d57'}:         '\u0d4c',
+	{'\u0d47', '\u0d3e'}:         '\u0d4b',
+	{'\u0dd9', '\u0dca'}:         '\u0dda',
+	{'\u0dd9', '\u0dcf'}:         '\u0ddc',
+	{'\u0dd9', '\u0ddf'}:         '\u0dde',
+	{'\u0ddc', '\u0dca'}:         '\u0ddd',
+	{'\u1025', '\u102e'}:         '\u1026',
+	{'\u1b05', '\u1b35'}:         '\u1b06',
+	{'\u1b07', '\u1b35'}:         '\u1b08',
+	{'\u1b09', '\u1b35'}:         '\u1b0a',
+	{'\u1b0b', '\u1b35'}:         '\u1b0c',
+	{'\u1b0d', '\u1b35'}:         '\u1b0e',
+	{'\u1b11', '\u1b35'}:         '\u1b12',
+	{'\u1b3a', '\u1b35'}:         '\u1b3b',
+	{'\u1b3c', '\u1b35'}:         '\u1b3d',
+	{'\u1b3e', '\u1b35'}:         '\u1b40',
+	{'\u1b3f', '\u1b35'}:         '\u1b41',
+	{'\u1b42', '\u1b35'}:         '\u1b43',
+	{'\u1e36', '\u0304'}:         '\u1e38',
+	{'\u1e37', '\u0304'}:         '\u1e39',
+	{'\u1e5a', '\u0304'}:         '\u1e5c',
+	{'\u1e5b', '\u0304'}:         '\u1e5d',
+	{'\u1e62', '\u0307'}:         '\u1e68',
+	{'\u1e63', '\u0307'}:         '\u1e69',
+	{'\u1ea0', '\u0302'}:         '\u1eac',
+	{'\u1ea0', '\u0306'}:         '\u1eb6',
+	{'\u1ea1', '\u0302'}:         '\u1ead',
+	{'\u1ea1', '\u0306'}:         '\u1eb7',
+	{'\u1eb8', '\u0302'}:         '\u1ec6',
+	{'\u1eb9', '\u0302'}:         '\u1ec7',
+	{'\u1ecc', '\u0302'}:         '\u1ed8',
+	{'\u1ecd', '\u0302'}:         '\u1ed9',
+	{'\u1f00', '\u0300'}:         '\u1f02',
+	{'\u1f00', '\u0301'}:         '\u1f04',
+	{'\u1f00', '\u0342'}:         '\u1f06',
+	{'\u1f00', '\u0345'}:         '\u1f80',
+	{'\u1f01', '\u0300'}:         '\u1f03',
+	{'\u1f01', '\u0301'}:         '\u1f05',
+	{'\u1f01', '\u0342'}:         '\u1f07',
+	{'\u1f01', '\u0345'}:         '\u1f81',
+	{'\u1f02', '\u0345'}:         '\u1f82',
+	{'\u1f03', '\u0345'}:         '\u1f83',
+	{'\u1f04', '\u0345'}:         '\u1f84',
+	{'\u1f05', '\u0345'}:         '\u1f85',
+	{'\u1f06', '\u0345'}:         '\u1f86',
+	{'\u1f07', '\u0345'}:         '\u1f87',
+	{'\u1f08', '\u0300'}:         '\u1f0a',
+	{'\u1f08', '\u0301'}:         '\u1f0c',
+	{'\u1f08', '\u0342'}:         '\u1f0e',
+	{'\u1f08', '\u0345'}:         '\u1f88',
+	{'\u1f09', '\u0300'}:         '\u1f0b',
+	{'\u1f09', '\u0301'}:         '\u1f0d',
+	{'\u1f09', '\u0342'}:         '\u1f0f',
+	{'\u1f09', '\u0345'}:         '\u1f89',
+	{'\u1f0a', '\u0345'}:         '\u1f8a',
+	{'\u1f0b', '\u0345'}:         '\u1f8b',
+	{'\u1f0c', '\u0345'}:         '\u1f8c',
+	{'\u1f0d', '\u0345'}:         '\u1f8d',
+	{'\u1f0e', '\u0345'}:         '\u1f8e',
+	{'\u1f0f', '\u0345'}:         '\u1f8f',
+	{'\u1f10', '\u0300'}:         '\u1f12',
+	{'\u1f10', '\u0301'}:         '\u1f14',
+	{'\u1f11', '\u0300'}:         '\u1f13',
+	{'\u1f11', '\u0301'}:         '\u1f15',
+	{'\u1f18', '\u0300'}:         '\u1f1a',
+	{'\u1f18', '\u0301'}:         '\u1f1c',
+	{'\u1f19', '\u0300'}:         '\u1f1b',
+	{'\u1f19', '\u0301'}:         '\u1f1d',
+	{'\u1f20', '\u0300'}:         '\u1f22',
+	{'\u1f20', '\u0301'}:         '\u1f24',
+	{'\u1f20', '\u0342'}:         '\u1f26',
+	{'\u1f20', '\u0345'}:         '\u1f90',
+	{'\u1f21', '\u0300'}:         '\u1f23',
+	{'\u1f21', '\u0301'}:         '\u1f25',
+	{'\u1f21', '\u0342'}:         '\u1f27',
+	{'\u1f21', '\u0345'}:         '\u1f91',
+	{'\u1f22', '\u0345'}:         '\u1f92',
+	{'\u1f23', '\u0345'}:         '\u1f93',
+	{'\u1f24', '\u0345'}:         '\u1f94',
+	{'\u1f25', '\u0345'}:         '\u1f95',
+	{'\u1f26', '\u0345'}:         '\u1f96',
+	{'\u1f27', '\u0345'}:         '\u1f97',
+	{'\u1f28', '\u0300'}:         '\u1f2a',
+	{'\u1f28', '\u0301'}:         '\u1f2c',
+	{'\u1f28', '\u0342'}:         '\u1f2e',
+	{'\u1f28', '\u0345'}:         '\u1f98',
+	{'\u1f29', '\u0300'}:         '\u1f2b',
+	{'\u1f29', '\u0301'}:         '\u1f2d',
+	{'\u1f29', '\u0342'}:         '\u1f2f',
+	{'\u1f29', '\u0345'}:         '\u1f99',
+	{'\u1f2a', '\u0345'}:         '\u1f9a',
+	{'\u1f2b', '\u0345'}:         '\u1f9b',
+	{'\u1f2c', '\u0345'}:         '\u1f9c',
+	{'\u1f2d', '\u0345'}:         '\u1f9d',
+	{'\u1f2e', '\u0345'}:         '\u1f9e',
+	{'\u1f2f', '\u0345'}:         '\u1f9f',
+	{'\u1f30', '\u0300'}:         '\u1f32',
+	{'\u1f30', '\u0301'}:         '\u1f34',
+	{'\u1f30', '\u0342'}:         '\u1f36',
+	{'\u1f31', '\u0300'}:         '\u1f33',
+	{'\u1f31', '\u0301'}:         '\u1f35',
+	{'\u1f31', '\u0342'}:         '\u1f37',
+	{'\u1f38', '\u0300'}:         '\u1f3a',
+	{'\u1f38', '\u0301'}:         '\u1f3c',
+	{'\u1f38', '\u0342'}:         '\u1f3e',
+	{'\u1f39', '\u0300'}:         '\u1f3b',
+	{'\u1f39', '\u0301'}:         '\u1f3d',
+	{'\u1f39', '\u0342'}:         '\u1f3f',
+	{'\u1f40', '\u0300'}:         '\u1f42',
+	{'\u1f40', '\u0301'}:         '\u1f44',
+	{'\u1f41', '\u0300'}:         '\u1f43',
+	{'\u1f41', '\u0301'}:         '\u1f45',
+	{'\u1f48', '\u0300'}:         '\u1f4a',
+	{'\u1f48', '\u0301'}:         '\u1f4c',
+	{'\u1f49', '\u0300'}:         '\u1f4b',
+	{'\u1f49', '\u0301'}:         '\u1f4d',
+	{'\u1f50', '\u0300'}:         '\u1f52',
+	{'\u1f50', '\u0301'}:         '\u1f54',
+	{'\u1f50', '\u0342'}:         '\u1f56',
+	{'\u1f51', '\u0300'}:         '\u1f53',
+	{'\u1f51', '\u0301'}:         '\u1f55',
+	{'\u1f51', '\u0342'}:         '\u1f57',
+	{'\u1f59', '\u0300'}:         '\u1f5b',
+	{'\u1f59', '\u0301'}:         '\u1f5d',
+	{'\u1f59', '\u0342'}:         '\u1f5f',
+	{'\u1f60', '\u0300'}:         '\u1f62',
+	{'\u1f60', '\u0301'}:         '\u1f64',
+	{'\u1f60', '\u0342'}:         '\u1f66',
+	{'\u1f60', '\u0345'}:         '\u1fa0',
+	{'\u1f61', '\u0300'}:         '\u1f63',
+	{'\u1f61', '\u0301'}:         '\u1f65',
+	{'\u1f61', '\u0342'}:         '\u1f67',
+	{'\u1f61', '\u0345'}:         '\u1fa1',
+	{'\u1f62', '\u0345'}:         '\u1fa2',
+	{'\u1f63', '\u0345'}:         '\u1fa3',
+	{'\u1f64', '\u0345'}:         '\u1fa4',
+	{'\u1f65', '\u0345'}:         '\u1fa5',
+	{'\u1f66', '\u0345'}:         '\u1fa6',
+	{'\u1f67', '\u0345'}:         '\u1fa7',
+	{'\u1f68', '\u0300'}:         '\u1f6a',
+	{'\u1f68', '\u0301'}:         '\u1f6c',
+	{'\u1f68', '\u0342'}:         '\u1f6e',
+	{'\u1f68', '\u0345'}:         '\u1fa8',
+	{'\u1f69', '\u0300'}:         '\u1f6b',
+	{'\u1f69', '\u0301'}:         '\u1f6d',
+	{'\u1f69', '\u0342'}:         '\u1f6f',
+	{'\u1f69', '\u0345'}:         '\u1fa9',
+	{'\u1f6a', '\u0345'}:         '\u1faa',
+	{'\u1f6b', '\u0345'}:         '\u1fab',
+	{'\u1f6c', '\u0345'}:         '\u1fac',
+	{'\u1f6d', '\u0345'}:         '\u1fad',
+	{'\u1f6e', '\u0345'}:         '\u1fae',
+	{'\u1f6f', '\u0345'}:         '\u1faf',
+	{'\u1f70', '\u0345'}:         '\u1fb2',
+	{'\u1f74', '\u0345'}:         '\u1fc2',
+	{'\u1f7c', '\u0345'}:         '\u1ff2',
+	{'\u1fb6', '\u0345'}:         '\u1fb7',
+	{'\u1fbf', '\u0300'}:         '\u1fcd',
+	{'\u1fbf', '\u0301'}:         '\u1fce',
+	{'\u1fbf', '\u0342'}:         '\u1fcf',
+	{'\u1fc6', '\u0345'}:         '\u1fc7',
+	{'\u1ff6', '\u0345'}:         '\u1ff7',
+	{'\u1ffe', '\u0300'}:         '\u1fdd',
+	{'\u1ffe', '\u0301'}:         '\u1fde',
+	{'\u1ffe', '\u0342'}:         '\u1fdf',
+	{'\u2190', '\u0338'}:         '\u219a',
+	{'\u2192', '\u0338'}:         '\u219b',
+	{'\u2194', '\u0338'}:         '\u21ae',
+	{'\u21d0', '\u0338'}:         '\u21cd',
+	{'\u21d2', '\u0338'}:         '\u21cf',
+	{'\u21d4', '\u0338'}:         '\u21ce',
+	{'\u2203', '\u0338'}:         '\u2204',
+	{'\u2208', '\u0338'}:         '\u2209',
+	{'\u220b', '\u0338'}:         '\u220c',
+	{'\u2223', '\u0338'}:         '\u2224',
+	{'\u2225', '\u0338'}:         '\u2226',
+	{'\u223c', '\u0338'}:         '\u2241',
+	{'\u2243', '\u0338'}:         '\u2244',
+	{'\u2245', '\u0338'}:         '\u2247',
+	{'\u2248', '\u0338'}:         '\u2249',
+	{'\u224d', '\u0338'}:         '\u226d',
+	{'\u2261', '\u0338'}:         '\u2262',
+	{'\u2264', '\u0338'}:         '\u2270',
+	{'\u2265', '\u0338'}:         '\u2271',
+	{'\u2272', '\u0338'}:         '\u2274',
+	{'\u2273', '\u0338'}:         '\u2275',
+	{'\u2276', '\u0338'}:         '\u2278',
+	{'\u2277', '\u0338'}:         '\u2279',
+	{'\u227a', '\u0338'}:         '\u2280',
+	{'\u227b', '\u0338'}:         '\u2281',
+	{'\u227c', '\u0338'}:         '\u22e0',
+	{'\u227d', '\u0338'}:         '\u22e1',
+	{'\u2282', '\u0338'}:         '\u2284',
+	{'\u2283', '\u0338'}:         '\u2285',
+	{'\u2286', '\u0338'}:         '\u2288',
+	{'\u2287', '\u0338'}:         '\u2289',
+	{'\u2291', '\u0338'}:         '\u22e2',
+	{'\u2292', '\u0338'}:         '\u22e3',
+	{'\u22a2', '\u0338'}:         '\u22ac',
+	{'\u22a8', '\u0338'}:         '\u22ad',
+	{'\u22a9', '\u0338'}:         '\u22ae',
+	{'\u22ab', '\u0338'}:         '\u22af',
+	{'\u22b2', '\u0338'}:         '\u22ea',
+	{'\u22b3', '\u0338'}:         '\u22eb',
+	{'\u22b4', '\u0338'}:         '\u22ec',
+	{'\u22b5', '\u0338'}:         '\u22ed',
+	{'\u3046', '\u3099'}:         '\u3094',
+	{'\u304b', '\u3099'}:         '\u304c',
+	{'\u304d', '\u3099'}:         '\u304e',
+	{'\u304f', '\u3099'}:         '\u3050',
+	{'\u3051', '\u3099'}:         '\u3052',
+	{'\u3053', '\u3099'}:         '\u3054',
+	{'\u3055', '\u3099'}:         '\u3056',
+	{'\u3057', '\u3099'}:         '\u3058',
+	{'\u3059', '\u3099'}:         '\u305a',
+	{'\u305b', '\u3099'}:         '\u305c',
+	{'\u305d', '\u3099'}:         '\u305e',
+	{'\u305f', '\u3099'}:         '\u3060',
+	{'\u3061', '\u3099'}:         '\u3062',
+	{'\u3064', '\u3099'}:         '\u3065',
+	{'\u3066', '\u3099'}:         '\u3067',
+	{'\u3068', '\u3099'}:         '\u3069',
+	{'\u306f', '\u3099'}:         '\u3070',
+	{'\u306f', '\u309a'}:         '\u3071',
+	{'\u3072', '\u3099'}:         '\u3073',
+	{'\u3072', '\u309a'}:         '\u3074',
+	{'\u3075', '\u3099'}:         '\u3076',
+	{'\u3075', '\u309a'}:         '\u3077',
+	{'\u3078', '\u3099'}:         '\u3079',
+	{'\u3078', '\u309a'}:         '\u307a',
+	{'\u307b', '\u3099'}:         '\u307c',
+	{'\u307b', '\u309a'}:         '\u307d',
+	{'\u309d', '\u3099'}:         '\u309e',
+	{'\u30a6', '\u3099'}:         '\u30f4',
+	{'\u30ab', '\u3099'}:         '\u30ac',
+	{'\u30ad', '\u3099'}:         '\u30ae',
+	{'\u30af', '\u3099'}:         '\u30b0',
+	{'\u30b1', '\u3099'}:         '\u30b2',
+	{'\u30b3', '\u3099'}:         '\u30b4',
+	{'\u30b5', '\u3099'}:         '\u30b6',
+	{'\u30b7', '\u3099'}:         '\u30b8',
+	{'\u30b9', '\u3099'}:         '\u30ba',
+	{'\u30bb', '\u3099'}:         '\u30bc',
+	{'\u30bd', '\u3099'}:         '\u30be',
+	{'\u30bf', '\u3099'}:         '\u30c0',
+	{'\u30c1', '\u3099'}:         '\u30c2',
+	{'\u30c4', '\u3099'}:         '\u30c5',
+	{'\u30c6', '\u3099'}:         '\u30c7',
+	{'\u30c8', '\u3099'}:         '\u30c9',
+	{'\u30cf', '\u3099'}:         '\u30d0',
+	{'\u30cf', '\u309a'}:         '\u30d1',
+	{'\u30d2', '\u3099'}:         '\u30d3',
+	{'\u30d2', '\u309a'}:         '\u30d4',
+	{'\u30d5', '\u3099'}:         '\u30d6',
+	{'\u30d5', '\u309a'}:         '\u30d7',
+	{'\u30d8', '\u3099'}:         '\u30d9',
+	{'\u30d8', '\u309a'}:         '\u30da',
+	{'\u30db', '\u3099'}:         '\u30dc',
+	{'\u30db', '\u309a'}:         '\u30dd',
+	{'\u30ef', '\u3099'}:         '\u30f7',
+	{'\u30f0', '\u3099'}:         '\u30f8',
+	{'\u30f1', '\u3099'}:         '\u30f9',
+	{'\u30f2', '\u3099'}:         '\u30fa',
+	{'\u30fd', '\u3099'}:         '\u30fe',
+	{'\U00011099', '\U000110ba'}: '\U0001109a',
+	{'\U0001109b', '\U000110ba'}: '\U0001109c',
+	{'\U000110a5', '\U000110ba'}: '\U000110ab',
+	{'\U00011131', '\U00011127'}: '\U0001112e',
+	{'\U00011132', '\U00011127'}: '\U0001112f',
+	{'\U00011347', '\U0001133e'}: '\U0001134b',
+	{'\U00011347', '\U00011357'}: '\U0001134c',
+	{'\U000114b9', '\U000114b0'}: '\U000114bc',
+	{'\U000114b9', '\U000114ba'}: '\U000114bb',
+	{'\U000114b9', '\U000114bd'}: '\U000114be',
+	{'\U000115b8', '\U000115af'}: '\U000115ba',
+	{'\U000115b9', '\U000115af'}: '\U000115bb',
+	{'\U00011935', '\U00011930'}: '\U00011938',
+}
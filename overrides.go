@@ -0,0 +1,118 @@
+package paper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OverridesDir is the directory the paper command reads override files
+// from by default.
+const OverridesDir = "overrides"
+
+// Override changes what's published about a doc without editing it in
+// Paper. Every field is optional; set ones replace what's derived from the
+// doc, except Tags, which are added to its hashtags.
+type Override struct {
+	Slug       string              `yaml:"slug"`
+	Title      string              `yaml:"title"`
+	Summary    string              `yaml:"summary"`
+	Image      string              `yaml:"image"`     // cover image URL, site-relative or absolute
+	Canonical  string              `yaml:"canonical"` // the post's canonical URL, when it's published elsewhere first
+	Category   string              `yaml:"category"`  // category name
+	Tags       []string            `yaml:"tags"`
+	Taxonomies map[string][]string `yaml:"taxonomies"` // others, for themes, e.g. "series"
+}
+
+// Overrides reads each doc's Override from <doc ID>.yaml in Dir. Docs
+// without a file have no override.
+type Overrides struct {
+	Dir string
+
+	// Unmarshal decodes an override file. The paper command passes
+	// config.Unmarshal, so they're written like its config file. It
+	// defaults to json.Unmarshal, JSON being YAML too.
+	Unmarshal func(blob []byte, v interface{}) error
+}
+
+// Get returns a doc's override, or nil if it has none.
+func (o *Overrides) Get(id DocID) (*Override, error) {
+	if o == nil || strings.ContainsAny(string(id), `/\`) {
+		return nil, nil
+	}
+	name := filepath.Join(o.Dir, string(id)+".yaml")
+	blob, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	unmarshal := o.Unmarshal
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	var ov Override
+	if err := unmarshal(blob, &ov); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if ov.Slug != "" && ov.Slug != Slugify(ov.Slug) {
+		return nil, fmt.Errorf("%s: slug %q isn't a slug; try %q", name, ov.Slug, Slugify(ov.Slug))
+	}
+	return &ov, nil
+}
+
+// overrideSlug gives a doc the slug its override sets, unless another doc
+// has it.
+func (m *Manifest) overrideSlug(e *ManifestEntry, ov *Override) error {
+	if ov == nil || ov.Slug == "" || ov.Slug == e.Slug {
+		return nil
+	}
+	for _, other := range m.Docs {
+		if other.ID != e.ID && other.Slug == ov.Slug {
+			return fmt.Errorf("override slug %q is %s's", ov.Slug, other.ID)
+		}
+	}
+	e.Slug = ov.Slug
+	return nil
+}
+
+// applyOverride merges an override over a post.
+func (s *Site) applyOverride(p *Post, ov *Override) {
+	if ov.Slug != "" && ov.Slug != p.Slug {
+		e := *p.ManifestEntry
+		e.Slug = ov.Slug
+		p.ManifestEntry = &e
+		p.Permalink = s.permalink(&e)
+		p.URL = s.url(p.Permalink)
+	}
+	if ov.Title != "" {
+		e := *p.ManifestEntry
+		e.Title = ov.Title
+		p.ManifestEntry = &e
+	}
+	if ov.Summary != "" {
+		p.Summary = ov.Summary
+	}
+	if ov.Image != "" {
+		p.Image = s.absURL(ov.Image)
+	}
+	if ov.Canonical != "" {
+		p.Canonical = ov.Canonical
+	}
+	if ov.Category != "" {
+		slug := Slugify(ov.Category)
+		p.Category = &Category{Name: ov.Category, Slug: slug, Permalink: categoryPath(slug)}
+	}
+	for _, t := range ov.Tags {
+		if !contains(p.Tags, t) {
+			p.Tags = append(p.Tags, t)
+		}
+	}
+	if len(ov.Taxonomies) > 0 {
+		p.Taxonomies = ov.Taxonomies
+	}
+}
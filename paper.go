@@ -5,8 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
 func NewClient(token string) *APIClient {
@@ -22,9 +24,134 @@ type Client interface {
 	GetDocFolderInfo(context.Context, *RefPaperDoc) (*FoldersContainingPaperDoc, error)
 }
 
+// PagingClient is implemented by clients whose listings can span several
+// pages. Listing a client without it fails if ListDocs reports more.
+type PagingClient interface {
+	ListDocsContinue(context.Context, *ListPaperDocsContinueArgs) (*ListPaperDocsResponse, error)
+}
+
 type APIClient struct {
 	Token string
 	HTTP  http.Client
+
+	// SelectUser is the team member ID to act as when Token is a team
+	// token. See AsMember, and WithMember to choose one per call.
+	SelectUser string
+
+	// AppFolder is the name of the app's folder for app folder apps, ""
+	// for full Dropbox apps. See AppFolderError.
+	AppFolder string
+
+	// APIHost and ContentHost, when set, replace the host of RPC calls and
+	// of content calls, which download or upload a file, such as to send
+	// them through different proxies. Either may include a scheme, as in
+	// http://localhost:8080. Otherwise calls go to the host Dropbox
+	// documents for them: api.dropboxapi.com for RPC and Paper's content
+	// calls, content.dropboxapi.com for the files content calls.
+	APIHost     string
+	ContentHost string
+}
+
+const (
+	apiHost     = "https://api.dropboxapi.com"
+	contentHost = "https://content.dropboxapi.com"
+)
+
+// endpoint returns the URL to call for an endpoint's documented URL,
+// applying APIHost or ContentHost. Other hosts, like the longpoll
+// endpoint's, are left alone.
+func (c *APIClient) endpoint(url string, content bool) string {
+	host := c.APIHost
+	if content {
+		host = c.ContentHost
+	}
+	if host == "" {
+		return url
+	}
+	path := strings.TrimPrefix(url, apiHost)
+	if content && path == url {
+		path = strings.TrimPrefix(url, contentHost)
+	}
+	if path == url {
+		return url
+	}
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	return strings.TrimSuffix(host, "/") + path
+}
+
+// Clone returns a copy of the client. Copies share the HTTP client's
+// transport, and so its connections, so cloning is cheap.
+func (c *APIClient) Clone() *APIClient {
+	clone := *c
+	return &clone
+}
+
+// WithToken returns a copy of the client that authenticates with token,
+// for services that act for a different user on each request. The copy
+// doesn't act as a team member, since SelectUser belongs to the old token.
+func (c *APIClient) WithToken(token string) *APIClient {
+	clone := c.Clone()
+	clone.Token, clone.SelectUser = token, ""
+	return clone
+}
+
+// AsMember returns a copy of a team client that acts as the given team
+// member.
+func (c *APIClient) AsMember(teamMemberID string) *APIClient {
+	clone := c.Clone()
+	clone.SelectUser = teamMemberID
+	return clone
+}
+
+type memberKey struct{}
+
+// WithMember returns a context that makes calls with a team token act as
+// the given team member, overriding the client's SelectUser; an empty ID
+// acts as the team itself. It lets one client serve many members.
+func WithMember(ctx context.Context, teamMemberID string) context.Context {
+	return context.WithValue(ctx, memberKey{}, teamMemberID)
+}
+
+// MemberFromContext returns the team member set by WithMember, if any.
+func MemberFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(memberKey{}).(string)
+	return id, ok
+}
+
+// ResponseInfo is what a content call's response carried besides the
+// result the package decodes, for debugging and for fields it doesn't
+// model yet.
+type ResponseInfo struct {
+	Result        json.RawMessage // the Dropbox-API-Result header, as sent
+	RequestID     string          // X-Dropbox-Request-Id, for Dropbox support
+	ContentLength int64           // -1 when unknown
+	Header        http.Header
+}
+
+type responseInfoKey struct{}
+
+// WithResponseInfo returns a context that makes content calls, such as
+// DownloadDoc, fill in info from their response, including an error
+// response. Calls that don't reach the API, like ones a CachingClient
+// answers, leave it alone.
+func WithResponseInfo(ctx context.Context, info *ResponseInfo) context.Context {
+	return context.WithValue(ctx, responseInfoKey{}, info)
+}
+
+// authorize sets the headers identifying the caller.
+func (c *APIClient) authorize(ctx context.Context, req *http.Request) {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	member := c.SelectUser
+	if id, ok := MemberFromContext(ctx); ok {
+		member = id
+	}
+	if member != "" {
+		req.Header.Set("Dropbox-API-Select-User", member)
+	}
 }
 
 type APIError struct {
@@ -37,82 +164,117 @@ func (e APIError) Error() string {
 }
 
 func (c *APIClient) rpc(ctx context.Context, url string, in interface{}, out interface{}) error {
+	return c.call(ctx, url, in, func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(out)
+	})
+}
+
+// call makes an RPC call and hands a successful response's body to decode.
+func (c *APIClient) call(ctx context.Context, url string, in interface{}, decode func(io.Reader) error) error {
+	if err := c.checkAppType(url); err != nil {
+		return err
+	}
 	body, err := json.Marshal(in)
 	if err != nil {
 		return err
 	}
-	req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
-	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req, _ := http.NewRequest("POST", c.endpoint(url, false), bytes.NewReader(body))
+	c.authorize(ctx, req)
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := c.HTTP.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
 	}
+	resp.Body = cancelableBody(ctx, resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		var apierr APIError
 		if err := json.NewDecoder(resp.Body).Decode(&apierr); err != nil {
 			return err
 		}
-		return apierr
+		return missingScope(url, apierr)
 	}
-	return json.NewDecoder(resp.Body).Decode(out)
+	return decode(resp.Body)
 }
 
 func (c *APIClient) content(ctx context.Context, url string, in interface{}, out interface{}) ([]byte, error) {
-	var contents []byte
+	resp, err := c.open(ctx, url, in, out)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readBody(resp)
+}
+
+// open makes a content-download call and decodes its result header into
+// out, leaving the content unread.
+func (c *APIClient) open(ctx context.Context, url string, in interface{}, out interface{}) (*http.Response, error) {
+	if err := c.checkAppType(url); err != nil {
+		return nil, err
+	}
 	body, err := json.Marshal(in)
 	if err != nil {
-		return contents, err
+		return nil, err
 	}
-	req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
-	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req, _ := http.NewRequest("POST", c.endpoint(url, true), bytes.NewReader(body))
+	c.authorize(ctx, req)
 	req.Header.Set("Dropbox-API-Arg", string(body))
 	resp, err := c.HTTP.Do(req.WithContext(ctx))
 	if err != nil {
-		return contents, err
+		return nil, err
+	}
+	resp.Body = cancelableBody(ctx, resp.Body)
+	if info, ok := ctx.Value(responseInfoKey{}).(*ResponseInfo); ok {
+		*info = ResponseInfo{
+			RequestID:     resp.Header.Get("X-Dropbox-Request-Id"),
+			ContentLength: resp.ContentLength,
+			Header:        resp.Header,
+		}
+		if result := resp.Header.Get("Dropbox-API-Result"); result != "" {
+			info.Result = json.RawMessage(result)
+		}
 	}
-
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		var apierr APIError
 		if err := json.NewDecoder(resp.Body).Decode(&apierr); err != nil {
-			return contents, err
+			return nil, err
 		}
-		return contents, apierr
+		return nil, missingScope(url, apierr)
 	}
 
 	if result := resp.Header.Get("Dropbox-API-Result"); result != "" {
 		if err := json.Unmarshal([]byte(result), out); err != nil {
-			return contents, err
+			resp.Body.Close()
+			return nil, err
 		}
 	}
 
-	return ioutil.ReadAll(resp.Body)
+	return resp, nil
 }
 
 type ListPaperDocsFilterBy string
 
 const (
 	ListPaperDocsFilterByAccessed ListPaperDocsFilterBy = "accessed"
-	ListPaperDocsFilterByModified                       = "modified"
-	ListPaperDocsFilterByCreated                        = "created"
+	ListPaperDocsFilterByModified ListPaperDocsFilterBy = "modified"
+	ListPaperDocsFilterByCreated  ListPaperDocsFilterBy = "created"
 )
 
 type ListPaperDocsSortBy string
 
 const (
 	ListPaperDocsSortByAccessed ListPaperDocsSortBy = "accessed"
-	ListPaperDocsSortByModified                     = "modified"
-	ListPaperDocsSortByCreated                      = "created"
+	ListPaperDocsSortByModified ListPaperDocsSortBy = "modified"
+	ListPaperDocsSortByCreated  ListPaperDocsSortBy = "created"
 )
 
 type ListPaperDocsSortOrder string
 
 const (
 	ListPaperDocsSortOrderAsc  ListPaperDocsSortOrder = "ascending"
-	ListPaperDocsSortOrderDesc                        = "descending"
+	ListPaperDocsSortOrderDesc ListPaperDocsSortOrder = "descending"
 )
 
 type ListPaperDocsArgs struct {
@@ -123,14 +285,52 @@ type ListPaperDocsArgs struct {
 }
 
 type Cursor struct {
+	Value      string    `json:"value"`
+	Expiration time.Time `json:"expiration"` // zero if the cursor doesn't expire
+}
+
+// Expired reports whether the cursor has expired and a new listing is
+// needed.
+func (c Cursor) Expired() bool {
+	return !c.Expiration.IsZero() && time.Now().After(c.Expiration)
+}
+
+type cursorJSON struct {
 	Value      string `json:"value"`
-	Expiration string `json:"expiration"` // TODO: Make a time.Time
+	Expiration string `json:"expiration,omitempty"`
+}
+
+func (c Cursor) MarshalJSON() ([]byte, error) {
+	out := cursorJSON{Value: c.Value}
+	if !c.Expiration.IsZero() {
+		out.Expiration = c.Expiration.Format(time.RFC3339)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON parses the expiration as RFC 3339, allowing it to be
+// missing or empty.
+func (c *Cursor) UnmarshalJSON(b []byte) error {
+	var in cursorJSON
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+	c.Value, c.Expiration = in.Value, time.Time{}
+	if in.Expiration == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, in.Expiration)
+	if err != nil {
+		return fmt.Errorf("cursor expiration: %w", err)
+	}
+	c.Expiration = t
+	return nil
 }
 
 type ListPaperDocsResponse struct {
-	DocIDs  []string `json:"doc_ids"`
-	Cursor  Cursor   `json:"cursor"`
-	HasMore bool     `json"has_more"`
+	DocIDs  []DocID `json:"doc_ids"`
+	Cursor  Cursor  `json:"cursor"`
+	HasMore bool    `json:"has_more"`
 }
 
 func (c *APIClient) ListDocs(ctx context.Context, in *ListPaperDocsArgs) (*ListPaperDocsResponse, error) {
@@ -138,6 +338,25 @@ func (c *APIClient) ListDocs(ctx context.Context, in *ListPaperDocsArgs) (*ListP
 	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/list", in, &out)
 }
 
+type ListPaperDocsContinueArgs struct {
+	Cursor string `json:"cursor"`
+}
+
+func (c *APIClient) ListDocsContinue(ctx context.Context, in *ListPaperDocsContinueArgs) (*ListPaperDocsResponse, error) {
+	var out ListPaperDocsResponse
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/list/continue", in, &out)
+}
+
+// listDocsContinue continues a listing with c, which must be a
+// PagingClient.
+func listDocsContinue(ctx context.Context, c Client, in *ListPaperDocsContinueArgs) (*ListPaperDocsResponse, error) {
+	p, ok := c.(PagingClient)
+	if !ok {
+		return nil, fmt.Errorf("paper: %T can't continue a listing", c)
+	}
+	return p.ListDocsContinue(ctx, in)
+}
+
 type ExportFormat string
 
 const (
@@ -146,7 +365,7 @@ const (
 )
 
 type PaperDocExport struct {
-	DocID  string       `json:"doc_id,omitempty"`
+	DocID  DocID        `json:"doc_id,omitempty"`
 	Format ExportFormat `json:"export_format,omitempty"`
 }
 
@@ -159,12 +378,12 @@ type PaperDocExportResult struct {
 
 func (c *APIClient) DownloadDoc(ctx context.Context, in *PaperDocExport) (*PaperDocExportResult, []byte, error) {
 	var out PaperDocExportResult
-	blob, err := c.content(ctx, "https://api.dropboxapi.com/2/paper/docs/download", in, &out)
+	blob, err := c.content(ctx, downloadURL, in, &out)
 	return &out, blob, err
 }
 
 type RefPaperDoc struct {
-	DocID string `json:"doc_id"`
+	DocID DocID `json:"doc_id"`
 }
 
 type Folder struct {
@@ -176,12 +395,30 @@ type FolderSharingPolicyType string
 
 const (
 	FolderSharingPolicyTeam       FolderSharingPolicyType = "team"
-	FolderSharingPolicyInviteOnly                         = "invite_only"
+	FolderSharingPolicyInviteOnly FolderSharingPolicyType = "invite_only"
 )
 
+// UnmarshalJSON accepts the API's {".tag": "team"} union form as well as a
+// plain string.
+func (t *FolderSharingPolicyType) UnmarshalJSON(b []byte) error {
+	var tag struct {
+		Tag string `json:".tag"`
+	}
+	if err := json.Unmarshal(b, &tag); err == nil {
+		*t = FolderSharingPolicyType(tag.Tag)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*t = FolderSharingPolicyType(s)
+	return nil
+}
+
 type FoldersContainingPaperDoc struct {
-	FolderSharingPolicyType FolderSharingPolicyType
-	Folders                 []Folder
+	FolderSharingPolicyType FolderSharingPolicyType `json:"folder_sharing_policy_type"`
+	Folders                 []Folder                `json:"folders"`
 }
 
 func (c *APIClient) GetDocFolderInfo(ctx context.Context, in *RefPaperDoc) (*FoldersContainingPaperDoc, error) {
@@ -189,4 +426,7 @@ func (c *APIClient) GetDocFolderInfo(ctx context.Context, in *RefPaperDoc) (*Fol
 	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/get_folder_info", in, &out)
 }
 
-var _ Client = &APIClient{}
+var (
+	_ Client            = &APIClient{}
+	_ ConditionalClient = &APIClient{}
+)
@@ -0,0 +1,721 @@
+package paperfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
+	"time"
+)
+
+// Mount mounts f at dir and serves it until ctx is done, when it unmounts
+// it, or until it's unmounted with umount or fusermount -u. Mounting needs
+// root or the fusermount helper from libfuse.
+func Mount(ctx context.Context, dir string, f *FS) error {
+	dev, err := mount(dir, f.Writer == nil)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			unmount(dir)
+			// Unblocks serve even if the unmount is put off while the
+			// mount is busy.
+			dev.Close()
+		case <-done:
+		}
+	}()
+	s := &server{
+		fs:      f,
+		dev:     dev,
+		paths:   map[uint64]string{rootID: "."},
+		nodes:   map[string]uint64{".": rootID},
+		handles: map[uint64]*File{},
+		next:    rootID,
+		uid:     uint32(os.Getuid()),
+		gid:     uint32(os.Getgid()),
+	}
+	err = s.serve(ctx)
+	dev.Close()
+	return err
+}
+
+// mount mounts a FUSE filesystem at dir, returning the device its requests
+// are read from.
+func mount(dir string, readOnly bool) (*os.File, error) {
+	if os.Geteuid() != 0 {
+		return fusermount(dir, readOnly)
+	}
+	// Opened non-blocking so that closing the file interrupts a read.
+	fd, err := syscall.Open("/dev/fuse", syscall.O_RDWR|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("paperfs: open /dev/fuse: %w", err)
+	}
+	flags := uintptr(syscall.MS_NOSUID | syscall.MS_NODEV)
+	if readOnly {
+		flags |= syscall.MS_RDONLY
+	}
+	opts := fmt.Sprintf("fd=%d,rootmode=40000,user_id=%d,group_id=%d,default_permissions", fd, os.Getuid(), os.Getgid())
+	if err := syscall.Mount("paper", dir, "fuse.paperfs", flags, opts); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("paperfs: mount %s: %w", dir, err)
+	}
+	return os.NewFile(uintptr(fd), "/dev/fuse"), nil
+}
+
+// fusermount mounts dir with the setuid fusermount helper, which passes
+// back the device over a socket.
+func fusermount(dir string, readOnly bool) (*os.File, error) {
+	bin, err := fusermountPath()
+	if err != nil {
+		return nil, err
+	}
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("paperfs: socketpair: %w", err)
+	}
+	local := os.NewFile(uintptr(fds[0]), "fusermount")
+	remote := os.NewFile(uintptr(fds[1]), "fusermount")
+	defer local.Close()
+	defer remote.Close()
+
+	opts := "fsname=paper,subtype=paperfs,default_permissions"
+	if readOnly {
+		opts += ",ro"
+	}
+	cmd := exec.Command(bin, "-o", opts, "--", dir)
+	cmd.Env = append(os.Environ(), "_FUSE_COMMFD=3")
+	cmd.ExtraFiles = []*os.File{remote}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("paperfs: %s: %v: %s", bin, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	buf := make([]byte, 4)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := syscall.Recvmsg(int(local.Fd()), buf, oob, 0)
+	if err != nil {
+		return nil, fmt.Errorf("paperfs: read from fusermount: %w", err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		return nil, fmt.Errorf("paperfs: fusermount didn't pass a device")
+	}
+	rights, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(rights) != 1 {
+		return nil, fmt.Errorf("paperfs: fusermount didn't pass a device")
+	}
+	fd := rights[0]
+	syscall.CloseOnExec(fd)
+	// Non-blocking so that closing the file interrupts a read.
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), "/dev/fuse"), nil
+}
+
+func fusermountPath() (string, error) {
+	for _, name := range []string{"fusermount3", "fusermount"} {
+		if bin, err := exec.LookPath(name); err == nil {
+			return bin, nil
+		}
+	}
+	return "", errors.New("paperfs: mounting as a user needs fusermount, from libfuse")
+}
+
+// unmount detaches dir, leaving it to be cleaned up once it isn't busy.
+func unmount(dir string) error {
+	if os.Geteuid() == 0 {
+		return syscall.Unmount(dir, syscall.MNT_DETACH)
+	}
+	bin, err := fusermountPath()
+	if err != nil {
+		return err
+	}
+	return exec.Command(bin, "-u", "-z", "--", dir).Run()
+}
+
+// The FUSE protocol, version 7.31, as in the kernel's
+// include/uapi/linux/fuse.h. Only what's needed to read and write existing
+// docs is implemented; other requests fail with ENOSYS.
+
+const (
+	opLookup      = 1
+	opForget      = 2
+	opGetattr     = 3
+	opSetattr     = 4
+	opMknod       = 8
+	opMkdir       = 9
+	opUnlink      = 10
+	opRmdir       = 11
+	opRename      = 12
+	opOpen        = 14
+	opRead        = 15
+	opWrite       = 16
+	opStatfs      = 17
+	opRelease     = 18
+	opFsync       = 20
+	opFlush       = 25
+	opInit        = 26
+	opOpendir     = 27
+	opReaddir     = 28
+	opReleasedir  = 29
+	opFsyncdir    = 30
+	opAccess      = 34
+	opCreate      = 35
+	opInterrupt   = 36
+	opDestroy     = 38
+	opBatchForget = 42
+	opRename2     = 45
+)
+
+const (
+	protoMajor = 7
+	protoMinor = 31
+
+	initAsyncRead = 1 << 0
+	initBigWrites = 1 << 5
+
+	setattrSize = 1 << 3
+	setattrFh   = 1 << 6
+
+	rootID   = 1
+	maxWrite = 128 << 10
+	valid    = time.Second // how long the kernel may cache names and attributes
+)
+
+type inHeader struct {
+	Len    uint32
+	Opcode uint32
+	Unique uint64
+	NodeID uint64
+	UID    uint32
+	GID    uint32
+	PID    uint32
+	_      uint32
+}
+
+type outHeader struct {
+	Len    uint32
+	Error  int32
+	Unique uint64
+}
+
+type initIn struct {
+	Major        uint32
+	Minor        uint32
+	MaxReadahead uint32
+	Flags        uint32
+}
+
+type initOut struct {
+	Major               uint32
+	Minor               uint32
+	MaxReadahead        uint32
+	Flags               uint32
+	MaxBackground       uint16
+	CongestionThreshold uint16
+	MaxWrite            uint32
+	TimeGran            uint32
+	MaxPages            uint16
+	MapAlignment        uint16
+	_                   [8]uint32
+}
+
+type attr struct {
+	Ino       uint64
+	Size      uint64
+	Blocks    uint64
+	Atime     uint64
+	Mtime     uint64
+	Ctime     uint64
+	Atimensec uint32
+	Mtimensec uint32
+	Ctimensec uint32
+	Mode      uint32
+	Nlink     uint32
+	UID       uint32
+	GID       uint32
+	Rdev      uint32
+	Blksize   uint32
+	_         uint32
+}
+
+type entryOut struct {
+	NodeID         uint64
+	Generation     uint64
+	EntryValid     uint64
+	AttrValid      uint64
+	EntryValidNsec uint32
+	AttrValidNsec  uint32
+	Attr           attr
+}
+
+type attrOut struct {
+	AttrValid     uint64
+	AttrValidNsec uint32
+	_             uint32
+	Attr          attr
+}
+
+type setattrIn struct {
+	Valid     uint32
+	_         uint32
+	Fh        uint64
+	Size      uint64
+	LockOwner uint64
+	Atime     uint64
+	Mtime     uint64
+	Ctime     uint64
+	Atimensec uint32
+	Mtimensec uint32
+	Ctimensec uint32
+	Mode      uint32
+	_         uint32
+	UID       uint32
+	GID       uint32
+	_         uint32
+}
+
+type openIn struct {
+	Flags     uint32
+	OpenFlags uint32
+}
+
+type openOut struct {
+	Fh        uint64
+	OpenFlags uint32
+	_         uint32
+}
+
+// ioIn is both fuse_read_in and fuse_write_in, which share a layout.
+type ioIn struct {
+	Fh        uint64
+	Offset    uint64
+	Size      uint32
+	IOFlags   uint32
+	LockOwner uint64
+	Flags     uint32
+	_         uint32
+}
+
+type writeOut struct {
+	Size uint32
+	_    uint32
+}
+
+// fhIn is the start of fuse_release_in, fuse_flush_in and fuse_fsync_in.
+type fhIn struct {
+	Fh uint64
+}
+
+type kstatfs struct {
+	Blocks  uint64
+	Bfree   uint64
+	Bavail  uint64
+	Files   uint64
+	Ffree   uint64
+	Bsize   uint32
+	Namelen uint32
+	Frsize  uint32
+	_       uint32
+	_       [6]uint32
+}
+
+type direntHeader struct {
+	Ino     uint64
+	Off     uint64
+	Namelen uint32
+	Type    uint32
+}
+
+// server answers a mount's requests one at a time.
+type server struct {
+	fs  *FS
+	dev *os.File
+
+	// Node IDs are handed out per path and never reused, so an ID whose
+	// path has gone from Paper just fails with ENOENT.
+	paths   map[uint64]string
+	nodes   map[string]uint64
+	handles map[uint64]*File
+	next    uint64 // the last node or handle ID handed out
+
+	uid, gid uint32
+}
+
+func (s *server) serve(ctx context.Context) error {
+	buf := make([]byte, maxWrite+4096)
+	for {
+		n, err := s.dev.Read(buf)
+		switch {
+		case errors.Is(err, syscall.ENODEV), errors.Is(err, os.ErrClosed):
+			return nil // unmounted
+		case errors.Is(err, syscall.EINTR), errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.ENOENT):
+			continue
+		case err != nil:
+			return fmt.Errorf("paperfs: read request: %w", err)
+		}
+		var h inHeader
+		if n < binary.Size(h) {
+			return fmt.Errorf("paperfs: short request")
+		}
+		binary.Read(bytes.NewReader(buf), binary.NativeEndian, &h)
+		body := buf[binary.Size(h):n]
+		reply, err := s.handle(ctx, &h, body)
+		switch {
+		case h.Opcode == opForget || h.Opcode == opBatchForget || h.Opcode == opInterrupt:
+			// These are never answered.
+		case err != nil:
+			s.reply(h.Unique, errno(err), nil)
+		default:
+			s.reply(h.Unique, 0, reply)
+		}
+	}
+}
+
+func (s *server) reply(unique uint64, errno syscall.Errno, body []byte) {
+	var b bytes.Buffer
+	hdr := outHeader{Error: -int32(errno), Unique: unique}
+	hdr.Len = uint32(binary.Size(hdr) + len(body))
+	binary.Write(&b, binary.NativeEndian, &hdr)
+	b.Write(body)
+	// A failed write means the request was interrupted, which needs no
+	// more done about it.
+	s.dev.Write(b.Bytes())
+}
+
+// errno maps an error to what the kernel is told.
+func errno(err error) syscall.Errno {
+	var en syscall.Errno
+	switch {
+	case errors.As(err, &en):
+		return en
+	case errors.Is(err, fs.ErrNotExist):
+		return syscall.ENOENT
+	case errors.Is(err, fs.ErrPermission):
+		return syscall.EACCES
+	case errors.Is(err, fs.ErrInvalid):
+		return syscall.EINVAL
+	case errors.Is(err, ErrConflict):
+		return syscall.ESTALE
+	}
+	return syscall.EIO
+}
+
+func encode(v interface{}) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.NativeEndian, v)
+	return b.Bytes()
+}
+
+func decode(body []byte, v interface{}) error {
+	if len(body) < binary.Size(v) {
+		return syscall.EINVAL
+	}
+	return binary.Read(bytes.NewReader(body), binary.NativeEndian, v)
+}
+
+func (s *server) handle(ctx context.Context, h *inHeader, body []byte) ([]byte, error) {
+	switch h.Opcode {
+	case opInit:
+		var in initIn
+		if err := decode(body, &in); err != nil {
+			return nil, err
+		}
+		if in.Major != protoMajor {
+			return nil, syscall.EPROTO
+		}
+		return encode(&initOut{
+			Major:               protoMajor,
+			Minor:               protoMinor,
+			MaxReadahead:        in.MaxReadahead,
+			Flags:               in.Flags & (initAsyncRead | initBigWrites),
+			MaxBackground:       12,
+			CongestionThreshold: 9,
+			MaxWrite:            maxWrite,
+			TimeGran:            1,
+		}), nil
+	case opForget, opBatchForget, opInterrupt, opDestroy:
+		return nil, nil
+	case opLookup:
+		dir, err := s.path(h.NodeID)
+		if err != nil {
+			return nil, err
+		}
+		name := path.Join(dir, string(bytes.TrimRight(body, "\x00")))
+		e, err := s.fs.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		id := s.node(name)
+		secs, nsecs := split(valid)
+		return encode(&entryOut{
+			NodeID:         id,
+			EntryValid:     secs,
+			AttrValid:      secs,
+			EntryValidNsec: nsecs,
+			AttrValidNsec:  nsecs,
+			Attr:           s.attr(id, name, e.Dir, e.Size),
+		}), nil
+	case opGetattr:
+		return s.getattr(h.NodeID)
+	case opSetattr:
+		var in setattrIn
+		if err := decode(body, &in); err != nil {
+			return nil, err
+		}
+		if in.Valid&setattrSize != 0 {
+			if err := s.truncate(ctx, h.NodeID, &in); err != nil {
+				return nil, err
+			}
+		}
+		// Modes, owners and times can't be changed, but failing would
+		// break tools such as touch, so they're left as they are.
+		return s.getattr(h.NodeID)
+	case opOpen:
+		var in openIn
+		if err := decode(body, &in); err != nil {
+			return nil, err
+		}
+		name, err := s.path(h.NodeID)
+		if err != nil {
+			return nil, err
+		}
+		if in.Flags&syscall.O_ACCMODE != syscall.O_RDONLY && s.fs.Writer == nil {
+			return nil, syscall.EROFS
+		}
+		f, err := s.fs.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		s.next++
+		s.handles[s.next] = f
+		return encode(&openOut{Fh: s.next}), nil
+	case opRead:
+		var in ioIn
+		if err := decode(body, &in); err != nil {
+			return nil, err
+		}
+		f, err := s.file(in.Fh)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, in.Size)
+		n, _ := f.ReadAt(buf, int64(in.Offset))
+		return buf[:n], nil
+	case opWrite:
+		var in ioIn
+		if err := decode(body, &in); err != nil {
+			return nil, err
+		}
+		f, err := s.file(in.Fh)
+		if err != nil {
+			return nil, err
+		}
+		data := body[binary.Size(in):]
+		if len(data) < int(in.Size) {
+			return nil, syscall.EINVAL
+		}
+		n, err := f.WriteAt(data[:in.Size], int64(in.Offset))
+		if err != nil {
+			return nil, err
+		}
+		return encode(&writeOut{Size: uint32(n)}), nil
+	case opFlush, opFsync:
+		var in fhIn
+		if err := decode(body, &in); err != nil {
+			return nil, err
+		}
+		f, err := s.file(in.Fh)
+		if err != nil {
+			return nil, err
+		}
+		return nil, f.Flush(ctx)
+	case opRelease:
+		var in fhIn
+		if err := decode(body, &in); err != nil {
+			return nil, err
+		}
+		delete(s.handles, in.Fh)
+		return nil, nil
+	case opOpendir:
+		name, err := s.path(h.NodeID)
+		if err != nil {
+			return nil, err
+		}
+		e, err := s.fs.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if !e.Dir {
+			return nil, syscall.ENOTDIR
+		}
+		return encode(&openOut{}), nil
+	case opReaddir:
+		var in ioIn
+		if err := decode(body, &in); err != nil {
+			return nil, err
+		}
+		return s.readdir(h.NodeID, in.Offset, int(in.Size))
+	case opReleasedir, opFsyncdir, opAccess:
+		return nil, nil
+	case opStatfs:
+		return encode(&kstatfs{Bsize: 4096, Frsize: 4096, Namelen: 255}), nil
+	case opMknod, opMkdir, opUnlink, opRmdir, opRename, opCreate, opRename2:
+		return nil, syscall.EPERM
+	}
+	return nil, syscall.ENOSYS
+}
+
+// path returns the name of a node.
+func (s *server) path(id uint64) (string, error) {
+	name, ok := s.paths[id]
+	if !ok {
+		return "", syscall.ENOENT
+	}
+	return name, nil
+}
+
+// node returns the ID of a name, handing out a new one the first time.
+func (s *server) node(name string) uint64 {
+	if id, ok := s.nodes[name]; ok {
+		return id
+	}
+	s.next++
+	s.nodes[name] = s.next
+	s.paths[s.next] = name
+	return s.next
+}
+
+// file returns an open doc by its handle.
+func (s *server) file(id uint64) (*File, error) {
+	f, ok := s.handles[id]
+	if !ok {
+		return nil, syscall.EBADF
+	}
+	return f, nil
+}
+
+func (s *server) getattr(id uint64) ([]byte, error) {
+	name, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	e, err := s.fs.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	secs, nsecs := split(valid)
+	return encode(&attrOut{
+		AttrValid:     secs,
+		AttrValidNsec: nsecs,
+		Attr:          s.attr(id, name, e.Dir, e.Size),
+	}), nil
+}
+
+// attr describes a node. A doc with unsaved writes has the size of its
+// local copy.
+func (s *server) attr(id uint64, name string, dir bool, size int64) attr {
+	a := attr{Ino: id, UID: s.uid, GID: s.gid, Blksize: 4096}
+	if dir {
+		a.Mode = syscall.S_IFDIR | 0555
+		a.Nlink = 2
+		return a
+	}
+	for _, f := range s.handles {
+		if f.name == name && f.dirty {
+			size = f.Size()
+		}
+	}
+	a.Mode = syscall.S_IFREG | 0444
+	if s.fs.Writer != nil {
+		a.Mode |= 0200
+	}
+	a.Nlink = 1
+	a.Size = uint64(size)
+	a.Blocks = (a.Size + 511) / 512
+	return a
+}
+
+// truncate resizes a doc through the handle it's open with. Opening with
+// O_TRUNC may not say which that is, so an open handle for the doc is used
+// if there is one; otherwise, as for truncate(2), it's opened just for
+// that.
+func (s *server) truncate(ctx context.Context, id uint64, in *setattrIn) error {
+	if in.Valid&setattrFh != 0 {
+		f, err := s.file(in.Fh)
+		if err != nil {
+			return err
+		}
+		return f.Truncate(int64(in.Size))
+	}
+	name, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	for _, f := range s.handles {
+		if f.name == name {
+			return f.Truncate(int64(in.Size))
+		}
+	}
+	f, err := s.fs.Open(name)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(int64(in.Size)); err != nil {
+		return err
+	}
+	return f.Flush(ctx)
+}
+
+// readdir lists a directory from the entry at offset, as many entries as
+// fit in size bytes. Each entry's offset is that of the one after it.
+func (s *server) readdir(id uint64, offset uint64, size int) ([]byte, error) {
+	dir, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for i := offset; i < uint64(len(entries)); i++ {
+		name := entries[i].Name()
+		typ := uint32(syscall.S_IFREG >> 12)
+		if entries[i].IsDir() {
+			typ = syscall.S_IFDIR >> 12
+		}
+		hdr := direntHeader{
+			Ino:     s.node(path.Join(dir, name)),
+			Off:     i + 1,
+			Namelen: uint32(len(name)),
+			Type:    typ,
+		}
+		n := binary.Size(hdr) + len(name)
+		padded := (n + 7) &^ 7
+		if b.Len()+padded > size {
+			break
+		}
+		binary.Write(&b, binary.NativeEndian, &hdr)
+		b.WriteString(name)
+		b.Write(make([]byte, padded-n))
+	}
+	return b.Bytes(), nil
+}
+
+func split(d time.Duration) (uint64, uint32) {
+	return uint64(d / time.Second), uint32(d % time.Second)
+}
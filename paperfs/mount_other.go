@@ -0,0 +1,13 @@
+//go:build !linux
+
+package paperfs
+
+import (
+	"context"
+	"errors"
+)
+
+// Mount mounts f at dir. It's only implemented on Linux.
+func Mount(ctx context.Context, dir string, f *FS) error {
+	return errors.New("paperfs: mounting is only supported on Linux")
+}
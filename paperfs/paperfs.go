@@ -0,0 +1,174 @@
+// Package paperfs mounts a Paper workspace as a FUSE filesystem: reads come
+// from exports, writes go through docs/update. Folders are directories and
+// each doc is a file per format, as in paper.DocFS.
+//
+// Consistency is eventual. The directory tree is listed again once it's
+// older than TTL, so docs created, renamed or moved in Paper appear within
+// TTL. Exports are cached by revision, so listing again only transfers the
+// docs that changed when the client supports conditional downloads, and a
+// file's contents never go stale once the tree is refreshed. Writes are
+// buffered until the file is flushed, usually when it's closed, and
+// rejected with ErrConflict if the doc was edited in Paper since it was
+// read. Docs can't be created, renamed or removed through the mount.
+package paperfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kyleconroy/paper"
+)
+
+// ErrConflict is returned by Flush when the doc changed in Paper after it
+// was opened. The local edit isn't applied; reopen the file to get the new
+// revision.
+var ErrConflict = errors.New("paperfs: doc changed in Paper since it was opened")
+
+// FS is the filesystem Mount serves.
+type FS struct {
+	Client paper.Client
+	Writer paper.DocWriter // nil makes the filesystem read-only
+	Cache  paper.Cache     // exports by revision, defaults to a MemoryCache
+	TTL    time.Duration   // how long the tree is cached, defaults to 30s
+
+	mu      sync.Mutex
+	docs    *paper.DocFS
+	fetched time.Time
+}
+
+// refresh returns the tree, discarding it first if it's older than TTL.
+func (f *FS) refresh() *paper.DocFS {
+	ttl := f.TTL
+	if ttl == 0 {
+		ttl = 30 * time.Second
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.docs == nil {
+		cache := f.Cache
+		if cache == nil {
+			cache = &paper.MemoryCache{}
+		}
+		f.docs = &paper.DocFS{Client: &paper.CachingClient{Client: f.Client, Cache: cache}}
+		f.fetched = time.Now()
+	} else if time.Since(f.fetched) > ttl {
+		f.docs.Refresh()
+		f.fetched = time.Now()
+	}
+	return f.docs
+}
+
+// Lookup describes name, refreshing the tree if it's older than TTL. Unlike
+// paper.DocFS.Lookup, it fetches a doc whose size isn't known yet.
+func (f *FS) Lookup(name string) (*paper.DocFSEntry, error) {
+	docs := f.refresh()
+	e, err := docs.Lookup(name)
+	if err != nil || e.Dir || e.Size >= 0 {
+		return e, err
+	}
+	info, err := fs.Stat(docs, name)
+	if err != nil {
+		return nil, err
+	}
+	e.Size = info.Size()
+	return e, nil
+}
+
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(f.refresh(), name)
+}
+
+// Open returns a handle for reading and, when the FS is writable, writing a
+// doc.
+func (f *FS) Open(name string) (*File, error) {
+	e, err := f.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.Dir {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+	blob, err := fs.ReadFile(f.refresh(), name)
+	if err != nil {
+		return nil, err
+	}
+	return &File{fs: f, name: name, entry: e, data: blob}, nil
+}
+
+// File is an open doc. Writes change a local copy, which Flush uploads.
+type File struct {
+	fs    *FS
+	name  string
+	entry *paper.DocFSEntry
+	data  []byte
+	dirty bool
+}
+
+func (h *File) Size() int64 { return int64(len(h.data)) }
+
+func (h *File) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(h.data)) {
+		return 0, nil
+	}
+	return copy(p, h.data[off:]), nil
+}
+
+func (h *File) WriteAt(p []byte, off int64) (int, error) {
+	if h.fs.Writer == nil {
+		return 0, fs.ErrPermission
+	}
+	if end := off + int64(len(p)); end > int64(len(h.data)) {
+		h.data = append(h.data, make([]byte, end-int64(len(h.data)))...)
+	}
+	copy(h.data[off:], p)
+	h.dirty = true
+	return len(p), nil
+}
+
+func (h *File) Truncate(size int64) error {
+	if h.fs.Writer == nil {
+		return fs.ErrPermission
+	}
+	if size < int64(len(h.data)) {
+		h.data = h.data[:size]
+	} else {
+		h.data = append(h.data, make([]byte, size-int64(len(h.data)))...)
+	}
+	h.dirty = true
+	return nil
+}
+
+// Flush uploads local changes, overwriting the doc in Paper.
+func (h *File) Flush(ctx context.Context) error {
+	if !h.dirty {
+		return nil
+	}
+	format := paper.ImportFormatMarkdown
+	if path.Ext(h.name) == ".html" {
+		format = paper.ImportFormatHTML
+	}
+	res, err := h.fs.Writer.UpdateDoc(ctx, &paper.PaperDocUpdateArgs{
+		DocID:        h.entry.ID,
+		UpdatePolicy: paper.PaperDocUpdatePolicyOverwriteAll,
+		Revision:     h.entry.Revision,
+		ImportFormat: format,
+	}, h.data)
+	if err != nil {
+		var apierr paper.APIError
+		if errors.As(err, &apierr) && strings.HasPrefix(apierr.Summary, "revision_mismatch") {
+			return ErrConflict
+		}
+		return err
+	}
+	h.entry.Revision = res.Revision
+	h.dirty = false
+	// The doc's been written either way; if this fails, the tree shows the
+	// new revision once it's refreshed.
+	h.fs.refresh().RefreshDoc(path.Dir(h.name), h.entry.ID)
+	return nil
+}
@@ -0,0 +1,15 @@
+package paper
+
+import "context"
+
+// Ping checks that the API is reachable and accepts the client's token,
+// for health checks.
+func (c *APIClient) Ping(ctx context.Context) error {
+	in := struct {
+		Query string `json:"query"`
+	}{"ping"}
+	var out struct {
+		Result string `json:"result"`
+	}
+	return c.rpc(ctx, "https://api.dropboxapi.com/2/check/user", in, &out)
+}
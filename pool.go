@@ -0,0 +1,224 @@
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Account is a Dropbox account managed by a ClientPool.
+type Account struct {
+	Name  string `json:"name"` // e.g. the workspace it belongs to
+	Token string `json:"token"`
+
+	// RequestsPerSecond limits the calls made for the account, so one busy
+	// workspace can't exhaust its own rate limit. Zero is unlimited.
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+}
+
+// TokenStore persists a pool's accounts.
+type TokenStore interface {
+	Load() ([]Account, error)
+	Save([]Account) error
+}
+
+// FileTokenStore keeps accounts in a JSON file readable only by its owner.
+type FileTokenStore struct {
+	Path string
+}
+
+// Load returns no accounts if the file doesn't exist.
+func (s *FileTokenStore) Load() ([]Account, error) {
+	blob, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var accounts []Account
+	return accounts, json.Unmarshal(blob, &accounts)
+}
+
+func (s *FileTokenStore) Save(accounts []Account) error {
+	blob, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The tokens are secrets, so only the owner may read them.
+	return writeFileAtomic(s.Path, append(blob, '\n'), 0600)
+}
+
+// ClientPool holds a client per Dropbox account, for services that back up
+// several workspaces. Clients are rate limited per account.
+type ClientPool struct {
+	// Store, when set, is loaded by NewClientPool and saved on every Add
+	// and Remove.
+	Store TokenStore
+
+	// Transport tunes every client's connections.
+	Transport TransportOptions
+
+	mu       sync.Mutex
+	accounts []*poolAccount
+	next     int
+}
+
+type poolAccount struct {
+	Account
+	client *APIClient
+}
+
+// NewClientPool creates a pool with the accounts in store, which may be
+// nil.
+func NewClientPool(store TokenStore) (*ClientPool, error) {
+	p := &ClientPool{Store: store}
+	if store == nil {
+		return p, nil
+	}
+	accounts, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		p.accounts = append(p.accounts, p.newAccount(a))
+	}
+	return p, nil
+}
+
+func (p *ClientPool) newAccount(a Account) *poolAccount {
+	c := NewClient(a.Token)
+	var rt http.RoundTripper = p.Transport.Transport()
+	if a.RequestsPerSecond > 0 {
+		rt = &limitedTransport{base: rt, lim: newLimiter(a.RequestsPerSecond)}
+	}
+	c.HTTP.Transport = rt
+	return &poolAccount{Account: a, client: c}
+}
+
+// Add adds an account, replacing any with the same name.
+func (p *ClientPool) Add(a Account) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pa := p.newAccount(a)
+	for i, old := range p.accounts {
+		if old.Name == a.Name {
+			p.accounts[i] = pa
+			return p.save()
+		}
+	}
+	p.accounts = append(p.accounts, pa)
+	return p.save()
+}
+
+func (p *ClientPool) Remove(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, a := range p.accounts {
+		if a.Name == name {
+			p.accounts = append(p.accounts[:i], p.accounts[i+1:]...)
+			return p.save()
+		}
+	}
+	return nil
+}
+
+func (p *ClientPool) save() error {
+	if p.Store == nil {
+		return nil
+	}
+	accounts := make([]Account, len(p.accounts))
+	for i, a := range p.accounts {
+		accounts[i] = a.Account
+	}
+	return p.Store.Save(accounts)
+}
+
+// Client returns the named account's client.
+func (p *ClientPool) Client(name string) (*APIClient, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, a := range p.accounts {
+		if a.Name == name {
+			return a.client, true
+		}
+	}
+	return nil, false
+}
+
+// Names returns the accounts' names in the order they were added.
+func (p *ClientPool) Names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, len(p.accounts))
+	for i, a := range p.accounts {
+		names[i] = a.Name
+	}
+	return names
+}
+
+// Next returns the accounts in turn, round robin, for spreading work across
+// them. ok is false if the pool is empty.
+func (p *ClientPool) Next() (name string, c *APIClient, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.accounts) == 0 {
+		return "", nil, false
+	}
+	a := p.accounts[p.next%len(p.accounts)]
+	p.next++
+	return a.Name, a.client, true
+}
+
+// Each calls fn for every account in order, stopping at the first error.
+func (p *ClientPool) Each(fn func(name string, c *APIClient) error) error {
+	p.mu.Lock()
+	accounts := append([]*poolAccount(nil), p.accounts...)
+	p.mu.Unlock()
+	for _, a := range accounts {
+		if err := fn(a.Name, a.client); err != nil {
+			return fmt.Errorf("%s: %w", a.Name, err)
+		}
+	}
+	return nil
+}
+
+// limiter spaces calls evenly at a fixed rate.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimiter(perSecond float64) *limiter {
+	return &limiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the caller's turn.
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+	return sleep(ctx, time.Until(at))
+}
+
+type limitedTransport struct {
+	base http.RoundTripper
+	lim  *limiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.lim.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,159 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Publisher syncs docs, builds the site and pushes it to a branch, such as
+// GitHub Pages' gh-pages. Run from cron with a token, it keeps a Paper-backed
+// blog up to date.
+type Publisher struct {
+	Syncer *Syncer
+	Site   *Site // Site.Out defaults to a temporary directory
+
+	// Remote is a git URL or the name of a remote of the repository in Repo.
+	// It defaults to "origin".
+	Remote string
+	Repo   string // defaults to the working directory
+	Branch string // defaults to "gh-pages"
+
+	// Token authenticates pushes to HTTPS remotes, e.g. a GitHub token.
+	Token string
+
+	Author string // "Name <email>", defaults to "paper <paper@localhost>"
+	CNAME  string // custom domain written to CNAME
+}
+
+// Publish syncs, builds and pushes. The branch gets a commit only when the
+// built site differs from what it already holds.
+func (p *Publisher) Publish(ctx context.Context) error {
+	if p.Syncer != nil {
+		if _, err := p.Syncer.Sync(ctx); err != nil {
+			return err
+		}
+	}
+	site := *p.Site
+	if site.Out == "" {
+		out, err := os.MkdirTemp("", "paper-site")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(out)
+		site.Out = out
+	}
+	if err := site.Build(); err != nil {
+		return err
+	}
+	// GitHub Pages would otherwise run the site through Jekyll.
+	if err := writeFileAtomic(filepath.Join(site.Out, ".nojekyll"), nil, 0644); err != nil {
+		return err
+	}
+	if p.CNAME != "" {
+		if err := writeFileAtomic(filepath.Join(site.Out, "CNAME"), []byte(p.CNAME+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+	return p.push(ctx, site.Out)
+}
+
+// push commits dir to the branch in a scratch repository, on top of the
+// branch's current tip when it exists, and pushes it.
+func (p *Publisher) push(ctx context.Context, dir string) error {
+	remote, err := p.remoteURL(ctx)
+	if err != nil {
+		return err
+	}
+	branch := p.Branch
+	if branch == "" {
+		branch = "gh-pages"
+	}
+	gitDir, err := os.MkdirTemp("", "paper-git")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(gitDir)
+
+	name, email := "paper", "paper@localhost"
+	if p.Author != "" {
+		name, email = splitAuthor(p.Author)
+	}
+	env := []string{
+		"GIT_DIR=" + gitDir, "GIT_WORK_TREE=" + dir,
+		"GIT_AUTHOR_NAME=" + name, "GIT_AUTHOR_EMAIL=" + email,
+		"GIT_COMMITTER_NAME=" + name, "GIT_COMMITTER_EMAIL=" + email,
+	}
+	git := func(args ...string) error {
+		_, err := runGitEnv(ctx, dir, env, args...)
+		if err != nil && p.Token != "" {
+			err = redact(err, p.Token)
+		}
+		return err
+	}
+	if err := git("init", "-q"); err != nil {
+		return err
+	}
+	// A missing branch is fine; the first publish creates it.
+	if git("fetch", "-q", "--depth", "1", remote, branch) == nil {
+		if err := git("reset", "-q", "FETCH_HEAD"); err != nil {
+			return err
+		}
+	}
+	if err := git("add", "-A", "."); err != nil {
+		return err
+	}
+	if git("diff", "--cached", "--quiet") == nil {
+		return nil
+	}
+	msg := "Publish site " + time.Now().UTC().Format(time.RFC3339)
+	if err := git("commit", "-q", "-m", msg); err != nil {
+		return err
+	}
+	return git("push", "-q", remote, "HEAD:refs/heads/"+branch)
+}
+
+// remoteURL resolves Remote to a URL, adding Token to HTTPS URLs.
+func (p *Publisher) remoteURL(ctx context.Context) (string, error) {
+	remote := p.Remote
+	if remote == "" {
+		remote = "origin"
+	}
+	if !strings.Contains(remote, "/") && !strings.Contains(remote, ":") {
+		repo := p.Repo
+		if repo == "" {
+			repo = "."
+		}
+		out, err := runGit(ctx, repo, "remote", "get-url", remote)
+		if err != nil {
+			return "", err
+		}
+		remote = strings.TrimSpace(out)
+	}
+	if p.Token == "" || !strings.HasPrefix(remote, "https://") {
+		return remote, nil
+	}
+	u, err := url.Parse(remote)
+	if err != nil {
+		return "", err
+	}
+	u.User = url.UserPassword("x-access-token", p.Token)
+	return u.String(), nil
+}
+
+func splitAuthor(author string) (name, email string) {
+	i := strings.Index(author, "<")
+	if i < 0 {
+		return strings.TrimSpace(author), ""
+	}
+	return strings.TrimSpace(author[:i]), strings.Trim(author[i:], "<> ")
+}
+
+// redact removes secret from err's message, since git echoes remote URLs.
+func redact(err error, secret string) error {
+	return errors.New(strings.ReplaceAll(err.Error(), secret, "***"))
+}
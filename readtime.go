@@ -0,0 +1,71 @@
+package paper
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// ReadingTime estimates how long a doc takes to read: its words at
+// WordsPerMinute, plus ImageSeconds for the first image and a second less
+// for each one after it, down to 3 seconds an image.
+type ReadingTime struct {
+	WordsPerMinute int // defaults to 230
+	ImageSeconds   int // defaults to 12; negative ignores images
+}
+
+// Estimate returns the time it takes to read a doc.
+func (r *ReadingTime) Estimate(format ExportFormat, content []byte) time.Duration {
+	wpm := r.WordsPerMinute
+	if wpm <= 0 {
+		wpm = 230
+	}
+	d := time.Duration(countWords(format, content)) * time.Minute / time.Duration(wpm)
+	secs := r.ImageSeconds
+	if secs == 0 {
+		secs = 12
+	}
+	if secs > 0 {
+		for i := 0; i < countImages(format, content); i++ {
+			d += time.Duration(max(secs-i, min(secs, 3))) * time.Second
+		}
+	}
+	return d
+}
+
+// Minutes returns the estimate in whole minutes, rounded up, as themes
+// show it. Anything with content takes at least a minute.
+func (r *ReadingTime) Minutes(format ExportFormat, content []byte) int {
+	d := r.Estimate(format, content)
+	if d == 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Minutes()))
+}
+
+// countImages counts the images in a doc outside code.
+func countImages(format ExportFormat, content []byte) int {
+	if format != ExportFormatHTML {
+		n := 0
+		lines := strings.Split(string(content), "\n")
+		code := codeLines(lines)
+		for i, line := range lines {
+			if !code[i] {
+				n += len(mdImage.FindAllString(stripCodeSpans(line), -1))
+			}
+		}
+		return n
+	}
+	var walk func(*htmlNode) int
+	walk = func(n *htmlNode) int {
+		if n.Tag == "img" {
+			return 1
+		}
+		count := 0
+		for _, c := range n.Children {
+			count += walk(c)
+		}
+		return count
+	}
+	return walk(parseHTML(string(content)))
+}
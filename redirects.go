@@ -0,0 +1,57 @@
+package paper
+
+import (
+	"bytes"
+	"html/template"
+	"path/filepath"
+)
+
+var redirectPage = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Redirecting…</title>
+<link rel="canonical" href="{{.}}">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url={{.}}">
+</head>
+<body><a href="{{.}}">{{.}}</a></body>
+</html>
+`))
+
+// writeRedirects writes a page at each renamed post's old permalinks that
+// sends visitors and crawlers on to the current one. Old slugs come from the
+// aliases the Syncer keeps in Dir.
+func (s *Site) writeRedirects(posts []*Post) error {
+	aliases, err := LoadAliases(filepath.Join(s.Dir, AliasesName))
+	if err != nil {
+		return err
+	}
+	byID := map[DocID]*Post{}
+	taken := map[string]bool{}
+	for _, p := range posts {
+		byID[p.ID] = p
+		taken[p.Permalink] = true
+	}
+	var b bytes.Buffer
+	for slug, id := range aliases.Redirects() {
+		p, ok := byID[id]
+		if !ok {
+			continue
+		}
+		old := *p.ManifestEntry
+		old.Slug = slug
+		from := s.permalink(&old)
+		if taken[from] {
+			continue
+		}
+		b.Reset()
+		if err := redirectPage.Execute(&b, p.URL); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(pageFile(s.out(), from), b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
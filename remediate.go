@@ -0,0 +1,133 @@
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// PolicyChange is a doc's sharing policy before and after a remediation.
+type PolicyChange struct {
+	DocID  DocID         `json:"doc_id"`
+	Before SharingPolicy `json:"before"`
+	After  SharingPolicy `json:"after"`
+	Error  string        `json:"error,omitempty"` // why the change failed
+}
+
+// Remediation applies a sharing policy to many docs, e.g. to disable public
+// links on everything an Audit flagged.
+type Remediation struct {
+	Client *APIClient
+
+	// Concurrency is how many docs are updated at once, defaulting to 4.
+	Concurrency int
+
+	// DryRun reports the changes that would be made without making them.
+	DryRun bool
+}
+
+// Apply sets policy on each doc it differs from, leaving fields of the
+// doc's policy that policy leaves empty alone. It returns a change per doc
+// that needed one, in the order given; a doc that fails is recorded with
+// its error rather than stopping the rest. Save the changes with
+// SaveRollback to be able to undo them. If ctx is canceled, docs not yet
+// started are skipped and the changes made so far are returned with ctx's
+// error.
+func (r *Remediation) Apply(ctx context.Context, ids []DocID, policy SharingPolicy) ([]*PolicyChange, error) {
+	return r.run(ctx, ids, func(DocID) SharingPolicy { return policy })
+}
+
+// Rollback restores the policies docs had before the successful changes.
+func (r *Remediation) Rollback(ctx context.Context, changes []*PolicyChange) ([]*PolicyChange, error) {
+	before := map[DocID]SharingPolicy{}
+	var ids []DocID
+	for _, c := range changes {
+		if c.Error == "" {
+			before[c.DocID] = c.Before
+			ids = append(ids, c.DocID)
+		}
+	}
+	return r.run(ctx, ids, func(id DocID) SharingPolicy { return before[id] })
+}
+
+func (r *Remediation) run(ctx context.Context, ids []DocID, target func(DocID) SharingPolicy) ([]*PolicyChange, error) {
+	n := r.Concurrency
+	if n <= 0 {
+		n = 4
+	}
+	// Changes already started finish even if ctx is canceled, so none is
+	// left half made and missing from the rollback.
+	work, cancel := draining(ctx, drainGrace)
+	defer cancel()
+	results := make([]*PolicyChange, len(ids))
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, id DocID) {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = r.change(work, id, target(id))
+		}(i, id)
+	}
+	wg.Wait()
+	var changes []*PolicyChange
+	for _, c := range results {
+		if c != nil {
+			changes = append(changes, c)
+		}
+	}
+	return changes, ctx.Err()
+}
+
+// change moves one doc to policy, returning nil if it's already there.
+func (r *Remediation) change(ctx context.Context, id DocID, policy SharingPolicy) *PolicyChange {
+	before, err := r.Client.GetSharingPolicy(ctx, &RefPaperDoc{DocID: id})
+	if err != nil {
+		return &PolicyChange{DocID: id, Error: fmt.Sprintf("get sharing policy: %v", err)}
+	}
+	after := *before
+	if policy.PublicSharingPolicy != "" {
+		after.PublicSharingPolicy = policy.PublicSharingPolicy
+	}
+	if policy.TeamSharingPolicy != "" {
+		after.TeamSharingPolicy = policy.TeamSharingPolicy
+	}
+	if after == *before {
+		return nil
+	}
+	c := &PolicyChange{DocID: id, Before: *before, After: after}
+	if r.DryRun {
+		return c
+	}
+	if err := r.Client.SetSharingPolicy(ctx, &PaperDocSharingPolicy{DocID: id, SharingPolicy: after}); err != nil {
+		c.Error = err.Error()
+	}
+	return c
+}
+
+// SaveRollback writes changes to a file for LoadRollback and Rollback.
+func SaveRollback(name string, changes []*PolicyChange) error {
+	blob, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(name, append(blob, '\n'), 0644)
+}
+
+func LoadRollback(name string) ([]*PolicyChange, error) {
+	blob, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var changes []*PolicyChange
+	return changes, json.Unmarshal(blob, &changes)
+}
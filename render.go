@@ -0,0 +1,626 @@
+package paper
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RenderHTML returns the HTML body for a doc's content. Markdown is
+// rendered; HTML exports are reduced to the contents of their <body>.
+func RenderHTML(format ExportFormat, content []byte) []byte {
+	if format != ExportFormatHTML {
+		return MarkdownToHTML(content)
+	}
+	root := parseHTML(string(content))
+	var find func(*htmlNode) *htmlNode
+	find = func(n *htmlNode) *htmlNode {
+		if n.Tag == "body" {
+			return n
+		}
+		for _, c := range n.Children {
+			if b := find(c); b != nil {
+				return b
+			}
+		}
+		return nil
+	}
+	body := find(root)
+	if body == nil {
+		return content
+	}
+	var b strings.Builder
+	for _, c := range body.Children {
+		b.WriteString(renderHTML(c))
+	}
+	return []byte(b.String())
+}
+
+// MarkdownToHTML renders the CommonMark and GFM constructs Paper docs and
+// this package's transforms produce: headings, paragraphs, lists and task
+// lists, quotes, fenced code, tables, footnotes, math and the usual inline
+// markup. Raw HTML is passed through, so sanitize untrusted input
+// afterwards.
+func MarkdownToHTML(src []byte) []byte {
+	r := &mdRenderer{ids: map[string]int{}, notes: map[string]string{}}
+	lines := strings.Split(strings.Replace(string(src), "\r\n", "\n", -1), "\n")
+	lines = r.collectNotes(lines)
+	var b strings.Builder
+	r.blocks(&b, lines)
+	if len(r.noteOrder) > 0 {
+		b.WriteString("<section class=\"footnotes\">\n<ol>\n")
+		for _, id := range r.noteOrder {
+			b.WriteString(`<li id="fn-` + html.EscapeString(id) + `">` + r.inline(r.notes[id]) +
+				` <a href="#fnref-` + html.EscapeString(id) + `">↩</a></li>` + "\n")
+		}
+		b.WriteString("</ol>\n</section>\n")
+	}
+	return []byte(b.String())
+}
+
+type mdRenderer struct {
+	ids       map[string]int
+	notes     map[string]string
+	noteOrder []string
+}
+
+var (
+	mdFootnoteDef = regexp.MustCompile(`^\[\^([^\]]+)\]:\s*(.*)$`)
+	mdHR          = regexp.MustCompile(`^ {0,3}((\* *){3,}|(- *){3,}|(_ *){3,})$`)
+	mdListItem    = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])( +|$)`)
+	mdTableSep    = regexp.MustCompile(`^ *\|? *:?-+:? *(\| *:?-+:? *)*\|? *$`)
+	mdHTMLBlock   = regexp.MustCompile(`(?i)^ {0,3}<(/?(address|article|aside|blockquote|details|div|dl|fieldset|figcaption|figure|footer|form|h[1-6]|header|hr|iframe|li|main|nav|ol|p|pre|section|summary|table|tbody|td|tfoot|th|thead|tr|ul|video|audio|script|style)[\s/>]|!--|$)`)
+	mdSetext      = regexp.MustCompile(`^ {0,3}(=+|-+) *$`)
+	mdTask        = regexp.MustCompile(`^\[([ xX])\] +`)
+)
+
+func (r *mdRenderer) collectNotes(lines []string) []string {
+	code := codeLines(lines)
+	var out []string
+	for i, line := range lines {
+		if !code[i] {
+			if m := mdFootnoteDef.FindStringSubmatch(line); m != nil {
+				r.notes[m[1]] = m[2]
+				continue
+			}
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// startsBlock reports whether line begins a block that interrupts a
+// paragraph.
+func startsBlock(line string) bool {
+	return atxHeading.MatchString(line) || codeFence.MatchString(line) || opensMath(line) || mdHR.MatchString(line) ||
+		strings.HasPrefix(strings.TrimLeft(line, " "), ">") || mdListItem.MatchString(line) ||
+		mdHTMLBlock.MatchString(line)
+}
+
+func (r *mdRenderer) blocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case codeFence.MatchString(line):
+			i = r.fenced(b, lines, i)
+		case opensMath(line):
+			i = displayMath(b, lines, i)
+		case atxHeading.MatchString(line):
+			m := atxHeading.FindStringSubmatch(line)
+			r.heading(b, len(m[1]), m[2])
+			i++
+		case mdHR.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(strings.TrimLeft(line, " "), ">"):
+			i = r.quote(b, lines, i)
+		case mdListItem.MatchString(line):
+			i = r.list(b, lines, i)
+		case strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t"):
+			i = r.indented(b, lines, i)
+		case mdHTMLBlock.MatchString(line):
+			for ; i < len(lines) && !isBlank(lines[i]); i++ {
+				b.WriteString(lines[i] + "\n")
+			}
+		case strings.Contains(line, "|") && i+1 < len(lines) && mdTableSep.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-"):
+			i = r.table(b, lines, i)
+		default:
+			i = r.paragraph(b, lines, i)
+		}
+	}
+}
+
+func (r *mdRenderer) heading(b *strings.Builder, level int, text string) {
+	id := uniqueAnchor(r.ids, plainMarkdown(text))
+	tag := "h" + strconv.Itoa(level)
+	b.WriteString("<" + tag + ` id="` + html.EscapeString(id) + `">` + r.inline(strings.TrimSpace(text)) + "</" + tag + ">\n")
+}
+
+func (r *mdRenderer) fenced(b *strings.Builder, lines []string, i int) int {
+	m := fenceOpen.FindStringSubmatch(lines[i])
+	indent, fence := len(m[1]), m[2]
+	lang := strings.Fields(m[3])
+	i++
+	var code []string
+	for ; i < len(lines) && !isFenceClose(lines[i], fence); i++ {
+		line := lines[i]
+		for n := 0; n < indent && strings.HasPrefix(line, " "); n++ {
+			line = line[1:]
+		}
+		code = append(code, line)
+	}
+	b.WriteString("<pre><code")
+	if len(lang) > 0 {
+		b.WriteString(` class="language-` + html.EscapeString(lang[0]) + `"`)
+	}
+	b.WriteString(">")
+	for _, line := range code {
+		b.WriteString(html.EscapeString(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i + 1
+}
+
+func (r *mdRenderer) indented(b *strings.Builder, lines []string, i int) int {
+	var code []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "    "):
+			code = append(code, line[4:])
+		case strings.HasPrefix(line, "\t"):
+			code = append(code, line[1:])
+		case isBlank(line):
+			code = append(code, "")
+		default:
+			goto done
+		}
+	}
+done:
+	for len(code) > 0 && code[len(code)-1] == "" {
+		code = code[:len(code)-1]
+	}
+	b.WriteString("<pre><code>")
+	for _, line := range code {
+		b.WriteString(html.EscapeString(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+func (r *mdRenderer) quote(b *strings.Builder, lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " ")
+		if strings.HasPrefix(line, ">") {
+			line = strings.TrimPrefix(line[1:], " ")
+			inner = append(inner, line)
+			continue
+		}
+		// Lazy continuation of a quoted paragraph.
+		if isBlank(line) || startsBlock(lines[i]) || len(inner) == 0 || isBlank(inner[len(inner)-1]) {
+			break
+		}
+		inner = append(inner, line)
+	}
+	b.WriteString("<blockquote>\n")
+	r.blocks(b, inner)
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+func (r *mdRenderer) list(b *strings.Builder, lines []string, i int) int {
+	first := mdListItem.FindStringSubmatch(lines[i])
+	ordered := first[2][0] >= '0' && first[2][0] <= '9'
+	delim := first[2][len(first[2])-1]
+	var items [][]string
+	loose := false
+	blank := false
+	for i < len(lines) {
+		m := mdListItem.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		isOrdered := m[2][0] >= '0' && m[2][0] <= '9'
+		if isOrdered != ordered || m[2][len(m[2])-1] != delim {
+			break
+		}
+		if blank {
+			loose = true
+		}
+		marker := len(m[1]) + len(m[2])
+		width := marker + len(m[3])
+		if len(m[3]) == 0 || len(m[3]) > 4 {
+			width = marker + 1
+		}
+		item := []string{lines[i][min(width, len(lines[i])):]}
+		i++
+		blank = false
+		for ; i < len(lines); i++ {
+			line := lines[i]
+			if isBlank(line) {
+				blank = true
+				item = append(item, "")
+				continue
+			}
+			indent := len(line) - len(strings.TrimLeft(line, " "))
+			if indent >= width {
+				if blank {
+					// A blank line inside an item makes the whole list
+					// loose unless it only precedes a nested list.
+					if !mdListItem.MatchString(line[width:]) {
+						loose = true
+					}
+				}
+				blank = false
+				item = append(item, line[width:])
+				continue
+			}
+			if !blank && !startsBlock(line) {
+				item = append(item, line)
+				continue
+			}
+			break
+		}
+		for len(item) > 0 && item[len(item)-1] == "" {
+			item = item[:len(item)-1]
+		}
+		items = append(items, item)
+		if blank && (i >= len(lines) || !mdListItem.MatchString(lines[i])) {
+			break
+		}
+	}
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if ordered {
+		if n, _ := strconv.Atoi(first[2][:len(first[2])-1]); n != 1 {
+			b.WriteString(` start="` + strconv.Itoa(n) + `"`)
+		}
+	}
+	b.WriteString(">\n")
+	for _, item := range items {
+		b.WriteString("<li>")
+		if len(item) > 0 {
+			if m := mdTask.FindStringSubmatch(item[0]); m != nil {
+				checked := ""
+				if m[1] != " " {
+					checked = " checked"
+				}
+				b.WriteString(`<input type="checkbox" disabled` + checked + "> ")
+				item[0] = item[0][len(m[0]):]
+			}
+		}
+		var inner strings.Builder
+		r.blocks(&inner, item)
+		out := inner.String()
+		if !loose {
+			out = strings.Replace(out, "<p>", "", -1)
+			out = strings.Replace(out, "</p>\n", "\n", -1)
+		}
+		b.WriteString(strings.TrimSuffix(out, "\n"))
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cur.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+func (r *mdRenderer) table(b *strings.Builder, lines []string, i int) int {
+	header := splitRow(lines[i])
+	var aligns []string
+	for _, sep := range splitRow(lines[i+1]) {
+		left, right := strings.HasPrefix(sep, ":"), strings.HasSuffix(sep, ":")
+		switch {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	row := func(tag string, cells []string) {
+		b.WriteString("<tr>")
+		for j := range aligns {
+			cell := ""
+			if j < len(cells) {
+				cell = cells[j]
+			}
+			b.WriteString("<" + tag)
+			if aligns[j] != "" {
+				b.WriteString(` align="` + aligns[j] + `"`)
+			}
+			b.WriteString(">" + r.inline(cell) + "</" + tag + ">")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("<table>\n<thead>\n")
+	row("th", header)
+	b.WriteString("</thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|"); i++ {
+		row("td", splitRow(lines[i]))
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+func (r *mdRenderer) paragraph(b *strings.Builder, lines []string, i int) int {
+	var para []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) {
+			break
+		}
+		if len(para) > 0 {
+			if m := mdSetext.FindStringSubmatch(line); m != nil {
+				level := 1
+				if m[1][0] == '-' {
+					level = 2
+				}
+				r.heading(b, level, strings.Join(para, " "))
+				return i + 1
+			}
+			if startsBlock(line) {
+				break
+			}
+		}
+		para = append(para, strings.TrimLeft(line, " "))
+	}
+	b.WriteString("<p>" + r.inline(strings.Join(para, "\n")) + "</p>\n")
+	return i
+}
+
+var (
+	mdInlineHTML = regexp.MustCompile(`^(<[A-Za-z][A-Za-z0-9-]*(\s+[A-Za-z_:][\w.:-]*(\s*=\s*("[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>|<!--[\s\S]*?-->)`)
+	mdAutolink   = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*|[^\s<>@]+@[^\s<>@]+\.[^\s<>@]+)>`)
+	mdLinkTail   = regexp.MustCompile(`^\(\s*(<[^>]*>|[^\s()]*(?:\([^\s()]*\)[^\s()]*)*)(?:\s+("[^"]*"|'[^']*'))?\s*\)`)
+)
+
+// inline renders inline markdown to HTML.
+func (r *mdRenderer) inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+			continue
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '\n':
+			if strings.HasSuffix(b.String(), "  ") {
+				out := strings.TrimRight(b.String(), " ")
+				b.Reset()
+				b.WriteString(out + "<br>")
+			}
+			b.WriteString("\n")
+			i++
+			continue
+		case c == '`':
+			n := 0
+			for i+n < len(s) && s[i+n] == '`' {
+				n++
+			}
+			fence := s[i : i+n]
+			if end := strings.Index(s[i+n:], fence); end >= 0 {
+				code := strings.Replace(s[i+n:i+n+end], "\n", " ", -1)
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+					code = code[1 : len(code)-1]
+				}
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += n + end + n
+				continue
+			}
+			b.WriteString(fence)
+			i += n
+			continue
+		case c == '$':
+			if spans := mathSpans(s[i:]); len(spans) > 0 && spans[0][0] == 0 {
+				tex := s[i : i+spans[0][1]]
+				class, open, close, n := "inline", `\(`, `\)`, 1
+				if strings.HasPrefix(tex, "$$") {
+					class, open, close, n = "display", `\[`, `\]`, 2
+				}
+				b.WriteString(`<span class="math ` + class + `">` + open + html.EscapeString(tex[n:len(tex)-n]) + close + "</span>")
+				i += len(tex)
+				continue
+			}
+		case c == '<':
+			if m := mdAutolink.FindStringSubmatch(s[i:]); m != nil {
+				href := m[1]
+				if !strings.Contains(href, ":") || strings.Contains(href, "@") && !strings.Contains(href, "://") {
+					href = "mailto:" + strings.TrimPrefix(href, "mailto:")
+				}
+				b.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(m[1]) + "</a>")
+				i += len(m[0])
+				continue
+			}
+			if m := mdInlineHTML.FindString(s[i:]); m != "" {
+				b.WriteString(m)
+				i += len(m)
+				continue
+			}
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if text, dest, title, n, ok := parseLink(s[i+1:]); ok {
+				b.WriteString(`<img src="` + html.EscapeString(dest) + `" alt="` + html.EscapeString(plainMarkdown(text)) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">")
+				i += 1 + n
+				continue
+			}
+		case c == '[':
+			if strings.HasPrefix(s[i:], "[^") {
+				if end := strings.IndexByte(s[i:], ']'); end > 2 {
+					id := s[i+2 : i+end]
+					if _, ok := r.notes[id]; ok {
+						num := r.noteNumber(id)
+						b.WriteString(`<sup id="fnref-` + html.EscapeString(id) + `"><a href="#fn-` + html.EscapeString(id) + `">` + strconv.Itoa(num) + "</a></sup>")
+						i += end + 1
+						continue
+					}
+				}
+			}
+			if text, dest, title, n, ok := parseLink(s[i:]); ok {
+				b.WriteString(`<a href="` + html.EscapeString(dest) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">" + r.inline(text) + "</a>")
+				i += n
+				continue
+			}
+		case c == '*' || c == '_' || c == '~':
+			if out, n, ok := r.emphasis(s, i); ok {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+		}
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+func (r *mdRenderer) noteNumber(id string) int {
+	for i, seen := range r.noteOrder {
+		if seen == id {
+			return i + 1
+		}
+	}
+	r.noteOrder = append(r.noteOrder, id)
+	return len(r.noteOrder)
+}
+
+// parseLink parses "[text](dest "title")" at the start of s.
+func parseLink(s string) (text, dest, title string, n int, ok bool) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				m := mdLinkTail.FindStringSubmatch(s[i+1:])
+				if m == nil {
+					return "", "", "", 0, false
+				}
+				dest = strings.TrimSuffix(strings.TrimPrefix(m[1], "<"), ">")
+				if len(m[2]) >= 2 {
+					title = m[2][1 : len(m[2])-1]
+				}
+				return s[1:i], dest, title, i + 1 + len(m[0]), true
+			}
+		case '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				i += end + 1
+			}
+		}
+	}
+	return "", "", "", 0, false
+}
+
+// emphasis renders a run of *, _ or ~ delimiters at s[i] if it has a
+// matching closer.
+func (r *mdRenderer) emphasis(s string, i int) (string, int, bool) {
+	c := s[i]
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	if i+n >= len(s) || s[i+n] == ' ' || s[i+n] == '\n' {
+		return "", 0, false
+	}
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", 0, false
+	}
+	var tag string
+	switch {
+	case c == '~' && n == 2:
+		tag = "del"
+	case c == '~':
+		return "", 0, false
+	case n >= 3:
+		n = 3
+	case n == 2:
+		tag = "strong"
+	default:
+		tag = "em"
+	}
+	delim := s[i : i+n]
+	for j := i + n; j < len(s); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] == '`' {
+			if end := strings.IndexByte(s[j+1:], '`'); end >= 0 {
+				j += end + 1
+			}
+			continue
+		}
+		if !strings.HasPrefix(s[j:], delim) || s[j-1] == ' ' || s[j-1] == '\n' {
+			continue
+		}
+		after := j + n
+		if after < len(s) && s[after] == c {
+			continue
+		}
+		if c == '_' && after < len(s) && isWordByte(s[after]) {
+			continue
+		}
+		inner := r.inline(s[i+n : j])
+		if n == 3 {
+			return "<em><strong>" + inner + "</strong></em>", after - i, true
+		}
+		return "<" + tag + ">" + inner + "</" + tag + ">", after - i, true
+	}
+	return "", 0, false
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,42 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kyleconroy/paper/changes"
+)
+
+// Snapshot returns the revision of every synced doc.
+func (m *Manifest) Snapshot() changes.Snapshot {
+	snap := changes.Snapshot{}
+	for id, e := range m.Docs {
+		if e.Revision != 0 {
+			snap[string(id)] = e.Revision
+		}
+	}
+	return snap
+}
+
+// RevisionLister implements changes.Lister for the docs visible to Client.
+// The API only reports a doc's revision alongside its export, so this
+// downloads every doc.
+type RevisionLister struct {
+	Client Client
+}
+
+func (l *RevisionLister) Revisions(ctx context.Context) (changes.Snapshot, error) {
+	ids, err := ListAllDocIDs(ctx, l.Client, &ListPaperDocsArgs{})
+	if err != nil {
+		return nil, err
+	}
+	snap := changes.Snapshot{}
+	for _, id := range ids {
+		res, _, err := l.Client.DownloadDoc(ctx, &PaperDocExport{DocID: id, Format: ExportFormatMarkdown})
+		if err != nil {
+			return nil, fmt.Errorf("download %s: %w", id, err)
+		}
+		snap[string(id)] = res.Revision
+	}
+	return snap, nil
+}
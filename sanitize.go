@@ -0,0 +1,149 @@
+package paper
+
+import (
+	"strings"
+)
+
+// Sanitizer strips everything from HTML that isn't explicitly allowed:
+// scripts, event handlers, inline styles and Paper's internal attributes.
+// Use it before serving exported HTML directly from a site.
+type Sanitizer struct {
+	// Elements lists the allowed tags. Disallowed tags are removed but
+	// their contents are kept, except for tags in Drop.
+	Elements map[string]bool
+
+	// Attributes lists the allowed attributes per tag. Attributes under
+	// "*" are allowed on every tag.
+	Attributes map[string][]string
+
+	// Drop lists tags that are removed along with their contents.
+	Drop map[string]bool
+
+	// Schemes lists the URL schemes allowed in href and src attributes.
+	// Relative URLs are always allowed.
+	Schemes []string
+}
+
+func NewSanitizer() *Sanitizer {
+	elements := map[string]bool{}
+	for _, tag := range strings.Fields(`a abbr b blockquote br caption code col colgroup dd del div dl dt em
+		figcaption figure h1 h2 h3 h4 h5 h6 hr i img ins kbd li mark ol p pre q s small span strong sub sup
+		table tbody td tfoot th thead tr u ul`) {
+		elements[tag] = true
+	}
+	return &Sanitizer{
+		Elements: elements,
+		Attributes: map[string][]string{
+			"*":   {"id", "title", "lang", "dir"},
+			"a":   {"href", "name"},
+			"img": {"src", "alt", "width", "height"},
+			"td":  {"colspan", "rowspan", "align"},
+			"th":  {"colspan", "rowspan", "align", "scope"},
+			"col": {"span"},
+			"ol":  {"start"},
+			"li":  {"value"},
+		},
+		Drop: map[string]bool{
+			"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+			"noscript": true, "template": true, "head": true, "title": true, "textarea": true,
+			"select": true, "frame": true, "frameset": true, "applet": true,
+		},
+		Schemes: []string{"http", "https", "mailto"},
+	}
+}
+
+// Sanitize returns the allowed subset of src.
+func (s *Sanitizer) Sanitize(src []byte) []byte {
+	var b strings.Builder
+	dropping := ""
+	depth := 0
+	for _, tok := range tokenizeHTML(string(src)) {
+		if dropping != "" {
+			switch {
+			case tok.Type == htmlStartTag && tok.Data == dropping:
+				depth++
+			case tok.Type == htmlEndTag && tok.Data == dropping:
+				depth--
+				if depth == 0 {
+					dropping = ""
+				}
+			}
+			continue
+		}
+		switch tok.Type {
+		case htmlText:
+			b.WriteString(escapeText(tok.Data))
+		case htmlStartTag, htmlSelfClosingTag:
+			if s.Drop[tok.Data] {
+				if tok.Type == htmlStartTag {
+					dropping, depth = tok.Data, 1
+				}
+				continue
+			}
+			if !s.Elements[tok.Data] {
+				continue
+			}
+			tok.Attrs = s.attrs(tok)
+			b.WriteString(tok.String())
+		case htmlEndTag:
+			if s.Elements[tok.Data] {
+				b.WriteString(tok.String())
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+// Transform sanitizes HTML docs and leaves other formats alone.
+func (s *Sanitizer) Transform(doc *Doc) error {
+	if doc.Format == ExportFormatHTML {
+		doc.Content = s.Sanitize(doc.Content)
+	}
+	return nil
+}
+
+func (s *Sanitizer) attrs(tok htmlToken) []htmlAttr {
+	var kept []htmlAttr
+	for _, a := range tok.Attrs {
+		if !contains(s.Attributes["*"], a.Key) && !contains(s.Attributes[tok.Data], a.Key) {
+			continue
+		}
+		if (a.Key == "href" || a.Key == "src") && !s.allowedURL(a.Val) {
+			continue
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
+
+func (s *Sanitizer) allowedURL(raw string) bool {
+	// Browsers ignore control characters and whitespace inside schemes, so
+	// strip them before looking for one.
+	clean := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, raw)
+	colon := strings.IndexByte(clean, ':')
+	if colon < 0 || strings.ContainsAny(clean[:colon], "/?#") {
+		return true
+	}
+	scheme := strings.ToLower(clean[:colon])
+	return contains(s.Schemes, scheme)
+}
+
+// escapeText escapes the angle brackets left in raw text while keeping any
+// entities already present.
+func escapeText(s string) string {
+	return strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
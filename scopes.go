@@ -0,0 +1,102 @@
+package paper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// endpointScopes maps each endpoint the client calls, as its route such as
+// "paper/docs/list", to the OAuth scopes it needs.
+var endpointScopes = map[string][]string{
+	"paper/docs/list":                       {"files.metadata.read"},
+	"paper/docs/list/continue":              {"files.metadata.read"},
+	"paper/docs/download":                   {"files.content.read"},
+	"paper/docs/get_folder_info":            {"sharing.read"},
+	"paper/docs/create":                     {"files.content.write"},
+	"paper/docs/update":                     {"files.content.write"},
+	"paper/docs/archive":                    {"files.content.write"},
+	"paper/docs/permanently_delete":         {"files.permanent_delete"},
+	"paper/docs/users/add":                  {"sharing.write"},
+	"paper/docs/users/list":                 {"sharing.read"},
+	"paper/docs/users/list/continue":        {"sharing.read"},
+	"paper/docs/folder_users/list":          {"sharing.read"},
+	"paper/docs/folder_users/list/continue": {"sharing.read"},
+	"paper/docs/sharing_policy/get":         {"sharing.read"},
+	"paper/docs/sharing_policy/set":         {"sharing.write"},
+	"files/get_metadata":                    {"files.metadata.read"},
+	"files/list_folder":                     {"files.metadata.read"},
+	"files/list_folder/continue":            {"files.metadata.read"},
+	"files/list_folder/get_latest_cursor":   {"files.metadata.read"},
+	"files/list_folder/longpoll":            nil,
+	"files/list_revisions":                  {"files.metadata.read"},
+	"users/get_space_usage":                 {"account_info.read"},
+	"check/user":                            {"account_info.read"},
+	"team/members/list_v2":                  {"members.read"},
+	"team/members/list/continue_v2":         {"members.read"},
+}
+
+// RequiredScopes returns the OAuth scopes an endpoint needs, given as its
+// route, e.g. RequiredScopes("paper/docs/download"). It returns nil for
+// endpoints that need none or that the client doesn't call. Acting as a
+// team member with WithMember or SelectUser also needs team_data.member.
+func RequiredScopes(op string) []string {
+	return endpointScopes[strings.Trim(op, "/")]
+}
+
+// ParseScopes splits the space-separated scope list of an OAuth token
+// response.
+func ParseScopes(s string) []string {
+	return strings.Fields(s)
+}
+
+// MissingScopeError reports an endpoint the token lacks a scope for.
+type MissingScopeError struct {
+	Op    string // endpoint route
+	Scope string
+}
+
+func (e *MissingScopeError) Error() string {
+	return fmt.Sprintf("paper: %s needs the %q scope, which the token wasn't granted; enable it for the app and reauthorize", e.Op, e.Scope)
+}
+
+// Preflight checks that granted, the scopes a token was issued with,
+// cover every op, so a job can fail before it starts rather than partway
+// through. It returns a *MissingScopeError for the first scope missing.
+func Preflight(granted []string, ops ...string) error {
+	have := map[string]bool{}
+	for _, s := range granted {
+		have[s] = true
+	}
+	for _, op := range ops {
+		for _, s := range RequiredScopes(op) {
+			if !have[s] {
+				return &MissingScopeError{Op: op, Scope: s}
+			}
+		}
+	}
+	return nil
+}
+
+// Operations lists the routes RequiredScopes knows, sorted.
+func Operations() []string {
+	ops := make([]string, 0, len(endpointScopes))
+	for op := range endpointScopes {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// missingScope turns the API's missing_scope error into a
+// *MissingScopeError, naming the endpoint that was called.
+func missingScope(url string, err APIError) error {
+	if err.Metadata[".tag"] != "missing_scope" {
+		return err
+	}
+	op := url
+	if i := strings.Index(url, "/2/"); i >= 0 {
+		op = url[i+len("/2/"):]
+	}
+	return &MissingScopeError{Op: op, Scope: err.Metadata["required_scope"]}
+}
@@ -0,0 +1,73 @@
+package paper
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
+
+// SearchEntry is one doc in search.json. The field names work as-is with
+// client-side search libraries such as lunr and Fuse.
+type SearchEntry struct {
+	ID        DocID    `json:"id"`
+	Title     string   `json:"title"`
+	Slug      string   `json:"slug"`
+	URL       string   `json:"url"`
+	Tags      []string `json:"tags"`
+	Category  string   `json:"category,omitempty"`
+	Published string   `json:"published,omitempty"`
+	Text      string   `json:"text"`
+}
+
+// SearchIndex returns search.json's contents for posts.
+func (s *Site) SearchIndex(posts []*Post) ([]byte, error) {
+	entries := make([]SearchEntry, 0, len(posts))
+	for _, p := range posts {
+		e := SearchEntry{
+			ID:    p.ID,
+			Title: p.Title,
+			Slug:  p.Slug,
+			URL:   p.Permalink,
+			Tags:  p.Tags,
+			Text:  plainText(p.Format, p.Content),
+		}
+		if e.Tags == nil {
+			e.Tags = []string{}
+		}
+		if p.Category != nil {
+			e.Category = p.Category.Name
+		}
+		if !p.Published.IsZero() {
+			e.Published = p.Published.Format("2006-01-02")
+		}
+		entries = append(entries, e)
+	}
+	return json.Marshal(entries)
+}
+
+// plainText returns a doc's visible text with whitespace collapsed.
+func plainText(format ExportFormat, content []byte) string {
+	text := parseHTML(string(RenderHTML(format, content))).text()
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// writeSearch writes search.json and, when SearchPage is set, the theme's
+// search page at /search/.
+func (s *Site) writeSearch(theme *Theme, posts []*Post) error {
+	blob, err := s.SearchIndex(posts)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.out(), "search.json"), blob, 0644); err != nil {
+		return err
+	}
+	if !s.SearchPage {
+		return nil
+	}
+	var b bytes.Buffer
+	if err := theme.Execute(&b, "search", &PageData{Site: s}); err != nil {
+		return err
+	}
+	return writeFileAtomic(pageFile(s.out(), "/search/"), b.Bytes(), 0644)
+}
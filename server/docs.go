@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kyleconroy/paper"
+)
+
+// DocHandler serves docs straight from Paper, for embedding Paper content
+// in an existing web app. It handles
+//
+//	/doc/{id}    a doc by ID
+//	/p/{slug}    a doc by slug, given a Manifest
+//
+// as HTML by default, or as markdown or JSON metadata with a .md or .json
+// suffix, e.g. /p/launch-plan.md. Docs are cached for TTL; a Refresher
+// keeps hot docs cached indefinitely.
+type DocHandler struct {
+	Client paper.Client
+	TTL    time.Duration // defaults to 1m
+
+	// Manifest returns the manifest that resolves slugs. A Syncer changes
+	// its Manifest while syncing, so return a clone taken between syncs,
+	// e.g. by a Hook, rather than the Syncer's own. Without one, docs are
+	// only served by ID.
+	Manifest func() *paper.Manifest
+
+	// Aliases, when set, redirects a renamed doc's old slugs to its
+	// current one.
+	Aliases *paper.Aliases
+
+	// Sanitizer cleans the HTML served, defaulting to paper.NewSanitizer.
+	Sanitizer *paper.Sanitizer
+
+	once      sync.Once
+	coalesced *paper.CoalescingClient
+	sanitizer *paper.Sanitizer
+
+	mu    sync.Mutex
+	docs  map[docKey]*cachedDoc
+	swept time.Time // when expired docs were last dropped
+}
+
+type docKey struct {
+	id     paper.DocID
+	format paper.ExportFormat
+}
+
+type cachedDoc struct {
+	doc     *paper.Doc
+	fetched time.Time
+}
+
+type docMeta struct {
+	ID       paper.DocID `json:"id"`
+	Title    string      `json:"title"`
+	Owner    string      `json:"owner"`
+	Revision int64       `json:"revision"`
+	Slug     string      `json:"slug,omitempty"`
+}
+
+func (h *DocHandler) ttl() time.Duration {
+	if h.TTL == 0 {
+		return time.Minute
+	}
+	return h.TTL
+}
+
+func (h *DocHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var kind, key string
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/doc/"):
+		kind, key = "id", strings.TrimPrefix(r.URL.Path, "/doc/")
+	case strings.HasPrefix(r.URL.Path, "/p/"):
+		kind, key = "slug", strings.TrimPrefix(r.URL.Path, "/p/")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	ext := path.Ext(key)
+	key = strings.TrimSuffix(key, ext)
+	if key == "" || strings.Contains(key, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	ctx := r.Context()
+	id, slug := paper.DocID(key), ""
+	if kind == "slug" {
+		if id = h.resolve(key); id == "" {
+			if cur, ok := h.renamed(key); ok {
+				// Relative, so it works wherever the handler is mounted.
+				w.Header().Set("Location", cur+ext)
+				w.WriteHeader(http.StatusMovedPermanently)
+				return
+			}
+			http.NotFound(w, r)
+			return
+		}
+		slug = key
+	}
+
+	format := paper.ExportFormatHTML
+	if ext == ".md" {
+		format = paper.ExportFormatMarkdown
+	}
+	doc, err := h.fetch(ctx, id, format)
+	if err != nil {
+		var apierr paper.APIError
+		if errors.As(err, &apierr) && strings.HasPrefix(apierr.Summary, "doc_not_found") {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("ETag", `"`+strconv.FormatInt(doc.Revision, 10)+`"`)
+	switch ext {
+	case ".json":
+		blob, _ := json.Marshal(docMeta{ID: doc.ID, Title: doc.Title, Owner: doc.Owner, Revision: doc.Revision, Slug: slug})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(blob)
+	case ".md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write(doc.Content)
+	case "", ".html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(h.sanitize(paper.RenderHTML(doc.Format, doc.Content)))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *DocHandler) init() {
+	h.once.Do(func() {
+		h.coalesced = &paper.CoalescingClient{Client: h.Client}
+		h.sanitizer = h.Sanitizer
+		if h.sanitizer == nil {
+			h.sanitizer = paper.NewSanitizer()
+		}
+	})
+}
+
+// sanitize strips whatever the Sanitizer doesn't allow from rendered HTML.
+func (h *DocHandler) sanitize(html []byte) []byte {
+	h.init()
+	return h.sanitizer.Sanitize(html)
+}
+
+func (h *DocHandler) fetch(ctx context.Context, id paper.DocID, format paper.ExportFormat) (*paper.Doc, error) {
+	k := docKey{id, format}
+	h.mu.Lock()
+	c, ok := h.docs[k]
+	h.mu.Unlock()
+	if ok && time.Since(c.fetched) < h.ttl() {
+		return c.doc, nil
+	}
+	return h.download(ctx, id, format)
+}
+
+// download fetches a doc and caches it, whether or not it's cached already.
+// Concurrent requests for a doc that isn't cached share one download.
+func (h *DocHandler) download(ctx context.Context, id paper.DocID, format paper.ExportFormat) (*paper.Doc, error) {
+	h.init()
+	res, blob, err := h.coalesced.DownloadDoc(ctx, &paper.PaperDocExport{DocID: id, Format: format})
+	if err != nil {
+		return nil, err
+	}
+	doc := &paper.Doc{
+		ID:       id,
+		Title:    res.Title,
+		Owner:    res.Owner,
+		Revision: res.Revision,
+		MIME:     res.MIME,
+		Format:   format,
+		Content:  blob,
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.docs == nil {
+		h.docs = map[docKey]*cachedDoc{}
+	}
+	// Docs nobody asks for again would otherwise be kept forever.
+	if now := time.Now(); now.Sub(h.swept) >= h.ttl() {
+		for k, c := range h.docs {
+			if now.Sub(c.fetched) >= h.ttl() {
+				delete(h.docs, k)
+			}
+		}
+		h.swept = now
+	}
+	h.docs[docKey{id, format}] = &cachedDoc{doc: doc, fetched: time.Now()}
+	return doc, nil
+}
+
+// forget drops a doc from the cache.
+func (h *DocHandler) forget(id paper.DocID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for k := range h.docs {
+		if k.id == id {
+			delete(h.docs, k)
+		}
+	}
+}
+
+// renamed returns the current slug of a doc that used to have slug.
+func (h *DocHandler) renamed(slug string) (string, bool) {
+	if h.Aliases == nil {
+		return "", false
+	}
+	_, current, ok := h.Aliases.Resolve(slug)
+	return current, ok && current != slug
+}
+
+// resolve returns the ID of the doc with slug, or "" if there's none.
+func (h *DocHandler) resolve(slug string) paper.DocID {
+	if h.Manifest == nil {
+		return ""
+	}
+	m := h.Manifest()
+	if m == nil {
+		return ""
+	}
+	for _, e := range m.Entries() {
+		if e.Slug == slug {
+			return e.ID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kyleconroy/paper"
+)
+
+// Preview serves the docs in a sync directory and reloads open browser tabs
+// whenever a doc changes in Paper or a watched local file changes, so authors
+// see their edits rendered soon after making them.
+type Preview struct {
+	Syncer *paper.Syncer
+
+	// Interval is the least time between checks for changes in Paper,
+	// defaulting to 30s. Each check lists docs with a paper.LongPoller,
+	// backing off while nothing changes, and Syncer only runs once one is
+	// seen.
+	Interval time.Duration
+
+	// Watch lists local files and directories, such as templates, whose
+	// changes should trigger a reload.
+	Watch []string
+
+	// Theme is a theme directory to render with. It's reloaded on every
+	// request and watched for changes.
+	Theme string
+
+	// Handler serves the pages. It defaults to NewDir(Syncer.Dir) using
+	// Theme.
+	Handler http.Handler
+
+	// Logf reports sync errors. Defaults to discarding them.
+	Logf func(format string, args ...interface{})
+
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+const reloadPath = "/_preview/events"
+
+const reloadScript = `<script>new EventSource("` + reloadPath + `").addEventListener("reload", function() { location.reload(); });</script>`
+
+// ListenAndServe syncs once, then serves on addr until ctx is canceled.
+func (p *Preview) ListenAndServe(ctx context.Context, addr string) error {
+	if _, err := p.Syncer.Sync(ctx); err != nil {
+		return err
+	}
+	srv := &http.Server{Addr: addr, Handler: p}
+	go p.Run(ctx)
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return ctx.Err()
+}
+
+// localInterval is how often watched local files are checked.
+const localInterval = time.Second
+
+// Run watches for remote and local changes until ctx is canceled.
+func (p *Preview) Run(ctx context.Context) {
+	interval := p.Interval
+	if interval == 0 {
+		interval = 30 * time.Second
+	}
+	remote := make(chan struct{}, 1)
+	go func() {
+		poller := &paper.LongPoller{Client: p.Syncer.Client, MinInterval: interval, MaxInterval: 4 * interval}
+		for {
+			_, err := poller.Wait(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				p.logf("preview: checking for changes failed: %s", err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(interval):
+				}
+				continue
+			}
+			select {
+			case remote <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	local := p.localState()
+	t := time.NewTicker(localInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-remote:
+			res, err := p.Syncer.Sync(ctx)
+			switch {
+			case err != nil:
+				p.logf("preview: sync failed: %s", err)
+			case !res.Empty():
+				p.reload()
+			}
+		case <-t.C:
+			if state := p.localState(); state != local {
+				local = state
+				p.reload()
+			}
+		}
+	}
+}
+
+// localState summarizes the watched paths so changes can be detected by
+// comparison.
+func (p *Preview) localState() string {
+	var n int
+	var latest time.Time
+	roots := p.Watch
+	if p.Theme != "" {
+		roots = append([]string{p.Theme}, roots...)
+	}
+	for _, root := range roots {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			n++
+			if info.ModTime().After(latest) {
+				latest = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return strconv.Itoa(n) + "@" + latest.String()
+}
+
+func (p *Preview) reload() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for c := range p.clients {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *Preview) logf(format string, args ...interface{}) {
+	if p.Logf != nil {
+		p.Logf(format, args...)
+	}
+}
+
+func (p *Preview) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == reloadPath {
+		p.events(w, r)
+		return
+	}
+	h := p.Handler
+	if h == nil {
+		dir := NewDir(p.Syncer.Dir)
+		if p.Theme != "" {
+			theme, err := paper.LoadTheme(p.Theme)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			dir.Theme = theme
+		}
+		h = dir
+	}
+	rec := &bufferedWriter{header: http.Header{}}
+	h.ServeHTTP(rec, r)
+	body := rec.buf.Bytes()
+	if ct := rec.header.Get("Content-Type"); len(ct) >= 9 && ct[:9] == "text/html" {
+		if i := bytes.LastIndex(body, []byte("</body>")); i >= 0 {
+			body = append(body[:i:i], append([]byte(reloadScript), body[i:]...)...)
+		}
+		rec.header.Del("Content-Length")
+	}
+	for k, v := range rec.header {
+		w.Header()[k] = v
+	}
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	w.WriteHeader(rec.status)
+	w.Write(body)
+}
+
+// events streams a server-sent "reload" event whenever something changes.
+func (p *Preview) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	c := make(chan struct{}, 1)
+	p.mu.Lock()
+	if p.clients == nil {
+		p.clients = map[chan struct{}]bool{}
+	}
+	p.clients[c] = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.clients, c)
+		p.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+type bufferedWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (b *bufferedWriter) Header() http.Header { return b.header }
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.buf.Write(p)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/kyleconroy/paper"
+	"github.com/kyleconroy/paper/changes"
+)
+
+// Refresher keeps a set of hot docs warm in a DocHandler's cache, so
+// requests for them are answered from memory instead of waiting on the API.
+type Refresher struct {
+	Handler *DocHandler
+	IDs     []paper.DocID
+
+	// Formats are the exports kept warm. The default is HTML, which backs
+	// the HTML and JSON responses, and markdown.
+	Formats []paper.ExportFormat
+
+	// Interval is how often every doc is refreshed. It defaults to half the
+	// handler's TTL, so cached copies never expire.
+	Interval time.Duration
+
+	// Events, when set, refreshes docs as soon as a Watcher reports them
+	// changed, and drops removed docs from the cache.
+	Events <-chan changes.Change
+
+	// Logf reports failed refreshes. Defaults to discarding them.
+	Logf func(format string, args ...interface{})
+}
+
+// Run refreshes every doc, then keeps them fresh until ctx is canceled.
+func (r *Refresher) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval == 0 {
+		interval = r.Handler.ttl() / 2
+	}
+	hot := map[paper.DocID]bool{}
+	for _, id := range r.IDs {
+		hot[id] = true
+	}
+	r.refreshAll(ctx)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			r.refreshAll(ctx)
+		case c, ok := <-r.Events:
+			if !ok {
+				r.Events = nil
+				continue
+			}
+			switch {
+			case !hot[paper.DocID(c.ID)]:
+			case c.Kind == changes.Removed:
+				r.Handler.forget(paper.DocID(c.ID))
+			default:
+				r.refresh(ctx, paper.DocID(c.ID))
+			}
+		}
+	}
+}
+
+func (r *Refresher) refreshAll(ctx context.Context) {
+	for _, id := range r.IDs {
+		if ctx.Err() != nil {
+			return
+		}
+		r.refresh(ctx, id)
+	}
+}
+
+// refresh re-downloads a doc. On failure the cached copy is kept, to be
+// served until it expires.
+func (r *Refresher) refresh(ctx context.Context, id paper.DocID) {
+	formats := r.Formats
+	if formats == nil {
+		formats = []paper.ExportFormat{paper.ExportFormatHTML, paper.ExportFormatMarkdown}
+	}
+	for _, f := range formats {
+		if _, err := r.Handler.download(ctx, id, f); err != nil && ctx.Err() == nil && r.Logf != nil {
+			r.Logf("refresh %s: %s", id, err)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Package server serves a Paper-backed blog straight from synced docs,
+// rendering them to HTML on each request.
+package server
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kyleconroy/paper"
+)
+
+// Store provides the posts a Handler serves. *paper.Site is a Store backed
+// by a sync directory.
+type Store interface {
+	Posts() ([]*paper.Post, error)
+}
+
+type Handler struct {
+	Store Store
+
+	// Site holds the settings passed to templates. It may be nil.
+	Site *paper.Site
+
+	// Theme renders pages. It defaults to paper.DefaultTheme.
+	Theme *paper.Theme
+
+	// Static serves requests that don't match a post or a theme file, such
+	// as assets and feeds. Nil means those requests get a 404.
+	Static http.Handler
+}
+
+func New(store Store) *Handler {
+	return &Handler{Store: store}
+}
+
+// NewDir serves the docs synced into dir, along with the other files in
+// it, such as assets and a site built there. The sync's own files aren't
+// served: the manifest, alias file and sidecars, docs' raw exports and
+// dotfiles.
+func NewDir(dir string) *Handler {
+	site := &paper.Site{Dir: dir}
+	d := &syncDir{site: site}
+	return &Handler{
+		Store:  d,
+		Site:   site,
+		Static: http.FileServer(d),
+	}
+}
+
+// syncDir serves a sync directory's posts, and is an http.FileSystem over
+// it that hides the files the sync keeps for itself. Reading every doc for
+// each request would be slow, so what it loads is kept until the manifest
+// changes.
+type syncDir struct {
+	site *paper.Site
+
+	mu    sync.Mutex
+	mod   time.Time // of the manifest the fields below were loaded from
+	size  int64
+	docs  map[string]bool // "/"-rooted paths of docs' exports, or nil
+	posts []*paper.Post   // or nil
+}
+
+// current drops what was loaded from an older manifest. d.mu must be held.
+func (d *syncDir) current() error {
+	fi, err := os.Stat(filepath.Join(d.site.Dir, paper.ManifestName))
+	mod, size := time.Time{}, int64(-1)
+	switch {
+	case err == nil:
+		mod, size = fi.ModTime(), fi.Size()
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	if !mod.Equal(d.mod) || size != d.size {
+		d.mod, d.size, d.docs, d.posts = mod, size, nil, nil
+	}
+	return nil
+}
+
+// Posts returns copies of the posts, so callers can change them, as
+// Handler does when pinning.
+func (d *syncDir) Posts() ([]*paper.Post, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.current(); err != nil {
+		return nil, err
+	}
+	if d.posts == nil {
+		posts, err := d.site.Posts()
+		if err != nil {
+			return nil, err
+		}
+		d.posts = posts
+	}
+	posts := make([]*paper.Post, len(d.posts))
+	for i, p := range d.posts {
+		cp := *p
+		posts[i] = &cp
+	}
+	return posts, nil
+}
+
+// exports returns the paths of docs' exports.
+func (d *syncDir) exports() (map[string]bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.current(); err != nil {
+		return nil, err
+	}
+	if d.docs == nil {
+		m, err := paper.LoadManifest(filepath.Join(d.site.Dir, paper.ManifestName))
+		if err != nil {
+			return nil, err
+		}
+		d.docs = map[string]bool{}
+		for _, e := range m.Entries() {
+			if e.Path != "" {
+				d.docs["/"+filepath.ToSlash(e.Path)] = true
+			}
+		}
+	}
+	return d.docs, nil
+}
+
+func (d *syncDir) Open(name string) (http.File, error) {
+	name = path.Clean("/" + name)
+	docs, err := d.exports()
+	if err != nil {
+		return nil, err
+	}
+	if private(name, docs) {
+		return nil, fs.ErrNotExist
+	}
+	f, err := http.Dir(d.site.Dir).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &siteFile{File: f, dir: d, name: name}, nil
+}
+
+// private reports whether name is one of the sync's own files.
+func private(name string, docs map[string]bool) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	base := path.Base(name)
+	if base == paper.ManifestName || base == paper.AliasesName || strings.HasSuffix(base, paper.SidecarExt) {
+		return true
+	}
+	// Docs are served rendered, not as exported.
+	return docs[name]
+}
+
+// siteFile leaves private files out of directory listings.
+type siteFile struct {
+	http.File
+	dir  *syncDir
+	name string
+}
+
+func (f *siteFile) Readdir(count int) ([]fs.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	docs, derr := f.dir.exports()
+	if derr != nil {
+		return nil, derr
+	}
+	shown := infos[:0]
+	for _, fi := range infos {
+		if !private(path.Join(f.name, fi.Name()), docs) {
+			shown = append(shown, fi)
+		}
+	}
+	return shown, err
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	theme := h.Theme
+	if theme == nil {
+		theme = paper.DefaultTheme()
+	}
+	site := h.Site
+	if site == nil {
+		site = &paper.Site{}
+	}
+	if name := strings.TrimPrefix(r.URL.Path, "/static/"); name != r.URL.Path {
+		if blob, ok := theme.Static(name); ok {
+			http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(blob))
+			return
+		}
+	}
+	posts, err := h.Store.Posts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	posts = site.Pin(posts)
+	for _, page := range site.Paginate(posts) {
+		if page.Path == r.URL.Path {
+			h.render(w, theme, "index", &paper.PageData{Site: site, Posts: page.Posts, Page: page})
+			return
+		}
+	}
+	switch {
+	case r.URL.Path == "/search.json":
+		blob, err := site.SearchIndex(posts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(blob)
+		return
+	case r.URL.Path == "/search/" && site.SearchPage:
+		h.render(w, theme, "search", &paper.PageData{Site: site})
+		return
+	}
+	if cats := site.CategoryList(posts); len(cats) > 0 {
+		if r.URL.Path == "/category/" {
+			h.render(w, theme, "categories", &paper.PageData{Site: site, Posts: posts, Categories: cats})
+			return
+		}
+		for _, c := range cats {
+			if c.Permalink == r.URL.Path {
+				h.render(w, theme, "category", &paper.PageData{Site: site, Posts: c.Posts, Category: c, Categories: cats})
+				return
+			}
+		}
+	}
+	for _, p := range posts {
+		if p.Permalink == r.URL.Path {
+			h.render(w, theme, "post", &paper.PageData{
+				Site:  site,
+				Post:  p,
+				Body:  template.HTML(paper.RenderHTML(p.Format, p.Content)),
+				Posts: posts,
+			})
+			return
+		}
+	}
+	if h.Static != nil {
+		h.Static.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (h *Handler) render(w http.ResponseWriter, theme *paper.Theme, page string, data *paper.PageData) {
+	var b bytes.Buffer
+	if err := theme.Execute(&b, page, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// MemoryStore is a Store that holds posts in memory, for serving docs
+// fetched directly from the API without writing them to disk.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	posts map[paper.DocID]*paper.Post
+}
+
+// Put adds or replaces a post. Posts without a permalink are served at
+// "/<slug>/".
+func (m *MemoryStore) Put(p *paper.Post) {
+	if p.Permalink == "" {
+		p.Permalink = "/" + p.Slug + "/"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.posts == nil {
+		m.posts = map[paper.DocID]*paper.Post{}
+	}
+	m.posts[p.ID] = p
+}
+
+// PutDoc adds a downloaded doc, slugged from its title.
+func (m *MemoryStore) PutDoc(doc *paper.Doc) {
+	m.Put(&paper.Post{
+		ManifestEntry: &paper.ManifestEntry{
+			ID:       doc.ID,
+			Title:    doc.Title,
+			Slug:     paper.Slugify(doc.Title),
+			Revision: doc.Revision,
+		},
+		Format:  doc.Format,
+		Content: doc.Content,
+	})
+}
+
+func (m *MemoryStore) Delete(id paper.DocID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.posts, id)
+}
+
+func (m *MemoryStore) Posts() ([]*paper.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	posts := make([]*paper.Post, 0, len(m.posts))
+	for _, p := range m.posts {
+		posts = append(posts, p)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].Published.Equal(posts[j].Published) {
+			return posts[i].Published.After(posts[j].Published)
+		}
+		return posts[i].Title < posts[j].Title
+	})
+	return posts, nil
+}
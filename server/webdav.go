@@ -0,0 +1,340 @@
+package server
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kyleconroy/paper"
+)
+
+// WebDAV serves a DocFS over WebDAV so Paper docs can be mounted in Finder or
+// Explorer. It's read-only unless Writer is set, in which case PUT updates
+// docs and creates new ones. Docs carry their revision as an ETag; a PUT
+// whose If-Match doesn't match, or that loses a race with another edit, gets
+// a 412. New docs show up under their title's slug, as Paper names them, not
+// the name they were PUT as.
+type WebDAV struct {
+	FS      *paper.DocFS
+	Writer  paper.DocWriter
+	Prefix  string // URL path the handler is mounted at, e.g. "/dav"
+	MaxSize int64  // largest doc a PUT may send, defaults to 32 MiB
+}
+
+const defaultMaxSize = 32 << 20
+
+func (d *WebDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := d.name(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case "OPTIONS":
+		methods := "OPTIONS, GET, HEAD, PROPFIND"
+		dav := "1"
+		if d.Writer != nil {
+			methods += ", PUT, LOCK, UNLOCK"
+			dav = "1, 2"
+		}
+		w.Header().Set("Allow", methods)
+		w.Header().Set("DAV", dav)
+		w.Header().Set("MS-Author-Via", "DAV")
+	case "GET", "HEAD":
+		d.get(w, r, name)
+	case "PROPFIND":
+		d.propfind(w, r, name)
+	case "PUT":
+		if d.Writer == nil {
+			http.Error(w, "read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		d.put(w, r, name)
+	case "LOCK":
+		// Clients such as Finder won't write without locking, but Paper has
+		// no locks to take. Revisions catch conflicting edits instead.
+		if d.Writer == nil {
+			http.Error(w, "read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		d.lock(w, r)
+	case "UNLOCK":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// name maps a URL path to a DocFS name.
+func (d *WebDAV) name(p string) (string, bool) {
+	p = path.Clean("/" + p)
+	prefix := path.Clean("/" + d.Prefix)
+	if prefix != "/" {
+		if p != prefix && !strings.HasPrefix(p, prefix+"/") {
+			return "", false
+		}
+		p = strings.TrimPrefix(p, prefix)
+	}
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ".", true
+	}
+	return p, true
+}
+
+func (d *WebDAV) href(name string, dir bool) string {
+	p := strings.TrimRight(d.Prefix, "/") + "/"
+	if name != "." {
+		p += name
+		if dir {
+			p += "/"
+		}
+	}
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+func etag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+func contentType(name string) string {
+	switch path.Ext(name) {
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	case ".html":
+		return "text/html; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+func (d *WebDAV) get(w http.ResponseWriter, r *http.Request, name string) {
+	e, err := d.FS.Lookup(name)
+	if err != nil {
+		fsError(w, err)
+		return
+	}
+	if e.Dir {
+		http.Error(w, "is a collection", http.StatusMethodNotAllowed)
+		return
+	}
+	blob, err := fs.ReadFile(d.FS, name)
+	if err != nil {
+		fsError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(e.Revision))
+	w.Header().Set("Content-Type", contentType(name))
+	w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+	if r.Method == "GET" {
+		w.Write(blob)
+	}
+}
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"D:multistatus"`
+	XMLNS     string        `xml:"xmlns:D,attr"`
+	Responses []davResponse `xml:"D:response"`
+}
+
+type davResponse struct {
+	Href     string      `xml:"D:href"`
+	Propstat davPropstat `xml:"D:propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"D:prop"`
+	Status string  `xml:"D:status"`
+}
+
+type davProp struct {
+	DisplayName   string           `xml:"D:displayname"`
+	ResourceType  *davResourceType `xml:"D:resourcetype"`
+	ContentLength string           `xml:"D:getcontentlength,omitempty"`
+	ContentType   string           `xml:"D:getcontenttype,omitempty"`
+	ETag          string           `xml:"D:getetag,omitempty"`
+	LastModified  string           `xml:"D:getlastmodified"`
+}
+
+type davResourceType struct {
+	Collection *struct{} `xml:"D:collection"`
+}
+
+// propfind answers with a fixed set of properties, whatever was asked for,
+// which every client handles. Depth infinity is treated as 1.
+func (d *WebDAV) propfind(w http.ResponseWriter, r *http.Request, name string) {
+	io.Copy(io.Discard, r.Body)
+	e, err := d.FS.Lookup(name)
+	if err != nil {
+		fsError(w, err)
+		return
+	}
+	ms := davMultistatus{XMLNS: "DAV:"}
+	add := func(name string, e *paper.DocFSEntry) error {
+		display := path.Base(name)
+		if name == "." {
+			display = ""
+		}
+		prop := davProp{
+			DisplayName:  display,
+			ResourceType: &davResourceType{},
+			LastModified: time.Time{}.UTC().Format(http.TimeFormat),
+		}
+		if e.Dir {
+			prop.ResourceType.Collection = &struct{}{}
+		} else {
+			// Formats that haven't been downloaded yet have no known
+			// size, and fetching every doc to find out would be slow.
+			if e.Size >= 0 {
+				prop.ContentLength = strconv.FormatInt(e.Size, 10)
+			}
+			prop.ContentType = contentType(name)
+			prop.ETag = etag(e.Revision)
+		}
+		ms.Responses = append(ms.Responses, davResponse{
+			Href:     d.href(name, e.Dir),
+			Propstat: davPropstat{Prop: prop, Status: "HTTP/1.1 200 OK"},
+		})
+		return nil
+	}
+	if err := add(name, e); err != nil {
+		fsError(w, err)
+		return
+	}
+	if e.Dir && r.Header.Get("Depth") != "0" {
+		entries, err := fs.ReadDir(d.FS, name)
+		if err != nil {
+			fsError(w, err)
+			return
+		}
+		for _, entry := range entries {
+			child := path.Join(name, entry.Name())
+			ce, err := d.FS.Lookup(child)
+			if err == nil {
+				err = add(child, ce)
+			}
+			if err != nil {
+				fsError(w, err)
+				return
+			}
+		}
+	}
+	blob, err := xml.Marshal(ms)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(207)
+	io.WriteString(w, xml.Header)
+	w.Write(blob)
+}
+
+func (d *WebDAV) put(w http.ResponseWriter, r *http.Request, name string) {
+	ctx := r.Context()
+	var format paper.ImportFormat
+	switch path.Ext(name) {
+	case ".md":
+		format = paper.ImportFormatMarkdown
+	case ".html":
+		format = paper.ImportFormatHTML
+	default:
+		http.Error(w, "only .md and .html files can be written", http.StatusForbidden)
+		return
+	}
+	max := d.MaxSize
+	if max == 0 {
+		max = defaultMaxSize
+	}
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, max))
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			http.Error(w, "doc too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var res *paper.PaperDocCreateUpdateResult
+	e, err := d.FS.Lookup(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		parent, perr := d.FS.Lookup(path.Dir(name))
+		if perr != nil || !parent.Dir {
+			http.Error(w, "parent collection not found", http.StatusConflict)
+			return
+		}
+		res, err = d.Writer.CreateDoc(ctx, &paper.PaperDocCreateArgs{ImportFormat: format, ParentFolderID: parent.FolderID}, content)
+	case err != nil:
+		fsError(w, err)
+		return
+	case e.Dir:
+		http.Error(w, "is a collection", http.StatusMethodNotAllowed)
+		return
+	default:
+		if m := r.Header.Get("If-Match"); m != "" && m != "*" && m != etag(e.Revision) {
+			http.Error(w, "doc has changed since it was read", http.StatusPreconditionFailed)
+			return
+		}
+		res, err = d.Writer.UpdateDoc(ctx, &paper.PaperDocUpdateArgs{
+			DocID:        e.ID,
+			UpdatePolicy: paper.PaperDocUpdatePolicyOverwriteAll,
+			Revision:     e.Revision,
+			ImportFormat: format,
+		}, content)
+	}
+	if err != nil {
+		var apierr paper.APIError
+		if errors.As(err, &apierr) && strings.HasPrefix(apierr.Summary, "revision_mismatch") {
+			// Pick up the newer revision, so the client can read it and
+			// try again.
+			if e != nil {
+				d.FS.RefreshDoc(path.Dir(name), e.ID)
+			}
+			http.Error(w, "doc has changed since it was read", http.StatusPreconditionFailed)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	// The write succeeded even if this fails; the doc then shows up once the
+	// listing is refreshed.
+	d.FS.RefreshDoc(path.Dir(name), res.DocID)
+	w.Header().Set("ETag", etag(res.Revision))
+	if e == nil {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (d *WebDAV) lock(w http.ResponseWriter, r *http.Request) {
+	io.Copy(io.Discard, r.Body)
+	token := fmt.Sprintf("opaquelocktoken:%d", time.Now().UnixNano())
+	w.Header().Set("Lock-Token", "<"+token+">")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	fmt.Fprintf(w, `%s<D:prop xmlns:D="DAV:"><D:lockdiscovery><D:activelock>`+
+		`<D:locktype><D:write/></D:locktype><D:lockscope><D:exclusive/></D:lockscope>`+
+		`<D:depth>0</D:depth><D:timeout>Second-3600</D:timeout>`+
+		`<D:locktoken><D:href>%s</D:href></D:locktoken>`+
+		`</D:activelock></D:lockdiscovery></D:prop>`, xml.Header, token)
+}
+
+func fsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, fs.ErrInvalid):
+		http.Error(w, "invalid path", http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,187 @@
+package paper
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type PaperDocPermissionLevel string
+
+const (
+	PaperDocPermissionLevelEdit           PaperDocPermissionLevel = "edit"
+	PaperDocPermissionLevelViewAndComment PaperDocPermissionLevel = "view_and_comment"
+)
+
+func (l PaperDocPermissionLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Tag string `json:".tag"`
+	}{string(l)})
+}
+
+// MemberSelector picks a user by email or Dropbox ID. Tag is "email" or
+// "dropbox_id".
+type MemberSelector struct {
+	Tag       string `json:".tag"`
+	Email     string `json:"email,omitempty"`
+	DropboxID string `json:"dropbox_id,omitempty"`
+}
+
+func EmailMember(email string) MemberSelector {
+	return MemberSelector{Tag: "email", Email: email}
+}
+
+type AddMember struct {
+	Member          MemberSelector          `json:"member"`
+	PermissionLevel PaperDocPermissionLevel `json:"permission_level,omitempty"`
+}
+
+type AddPaperDocUser struct {
+	DocID         DocID       `json:"doc_id"`
+	Members       []AddMember `json:"members"`
+	CustomMessage string      `json:"custom_message,omitempty"`
+	Quiet         bool        `json:"quiet,omitempty"`
+}
+
+type AddPaperDocUserResult struct {
+	Tag string `json:".tag"` // "success", "user_not_found", "permission_already_granted", ...
+}
+
+type AddPaperDocUserMemberResult struct {
+	Member MemberSelector        `json:"member"`
+	Result AddPaperDocUserResult `json:"result"`
+}
+
+// AddDocUsers shares a doc with up to 20 members. Members that couldn't be
+// added are reported in the results rather than as an error.
+func (c *APIClient) AddDocUsers(ctx context.Context, in *AddPaperDocUser) ([]AddPaperDocUserMemberResult, error) {
+	var out []AddPaperDocUserMemberResult
+	return out, c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/users/add", in, &out)
+}
+
+type UserInfo struct {
+	AccountID    string `json:"account_id"`
+	Email        string `json:"email"`
+	DisplayName  string `json:"display_name"`
+	SameTeam     bool   `json:"same_team"`
+	TeamMemberID string `json:"team_member_id,omitempty"`
+}
+
+// InviteeInfo is someone invited by email who hasn't joined yet.
+type InviteeInfo struct {
+	Tag   string `json:".tag"` // "email"
+	Email string `json:"email,omitempty"`
+}
+
+type UserInfoWithPermissionLevel struct {
+	User            UserInfo                `json:"user"`
+	PermissionLevel PaperDocPermissionLevel `json:"permission_level"`
+}
+
+type InviteeInfoWithPermissionLevel struct {
+	Invitee         InviteeInfo             `json:"invitee"`
+	PermissionLevel PaperDocPermissionLevel `json:"permission_level"`
+}
+
+type ListUsersOnPaperDocArgs struct {
+	DocID    DocID  `json:"doc_id"`
+	Limit    int32  `json:"limit,omitempty"`
+	FilterBy string `json:"filter_by,omitempty"` // "visited" or "shared"
+}
+
+type ListUsersOnPaperDocContinueArgs struct {
+	DocID  DocID  `json:"doc_id"`
+	Cursor string `json:"cursor"`
+}
+
+type ListUsersOnPaperDocResponse struct {
+	Invitees []InviteeInfoWithPermissionLevel `json:"invitees"`
+	Users    []UserInfoWithPermissionLevel    `json:"users"`
+	DocOwner UserInfo                         `json:"doc_owner"`
+	Cursor   Cursor                           `json:"cursor"`
+	HasMore  bool                             `json:"has_more"`
+}
+
+// ListDocUsers lists the users and invitees a doc is shared with.
+func (c *APIClient) ListDocUsers(ctx context.Context, in *ListUsersOnPaperDocArgs) (*ListUsersOnPaperDocResponse, error) {
+	var out ListUsersOnPaperDocResponse
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/users/list", in, &out)
+}
+
+func (c *APIClient) ListDocUsersContinue(ctx context.Context, in *ListUsersOnPaperDocContinueArgs) (*ListUsersOnPaperDocResponse, error) {
+	var out ListUsersOnPaperDocResponse
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/users/list/continue", in, &out)
+}
+
+type ListUsersOnFolderArgs struct {
+	DocID DocID `json:"doc_id"`
+	Limit int32 `json:"limit,omitempty"`
+}
+
+type ListUsersOnFolderContinueArgs struct {
+	DocID  DocID  `json:"doc_id"`
+	Cursor string `json:"cursor"`
+}
+
+type ListUsersOnFolderResponse struct {
+	Invitees []InviteeInfo `json:"invitees"`
+	Users    []UserInfo    `json:"users"`
+	Cursor   Cursor        `json:"cursor"`
+	HasMore  bool          `json:"has_more"`
+}
+
+// ListFolderUsers lists the users and invitees of the folder a doc is in.
+func (c *APIClient) ListFolderUsers(ctx context.Context, in *ListUsersOnFolderArgs) (*ListUsersOnFolderResponse, error) {
+	var out ListUsersOnFolderResponse
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/folder_users/list", in, &out)
+}
+
+func (c *APIClient) ListFolderUsersContinue(ctx context.Context, in *ListUsersOnFolderContinueArgs) (*ListUsersOnFolderResponse, error) {
+	var out ListUsersOnFolderResponse
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/folder_users/list/continue", in, &out)
+}
+
+type SharingPublicPolicyType string
+
+const (
+	SharingPublicPolicyEdit           SharingPublicPolicyType = "people_with_link_can_edit"
+	SharingPublicPolicyViewAndComment SharingPublicPolicyType = "people_with_link_can_view_and_comment"
+	SharingPublicPolicyInviteOnly     SharingPublicPolicyType = "invite_only"
+	SharingPublicPolicyDisabled       SharingPublicPolicyType = "disabled"
+)
+
+func (t SharingPublicPolicyType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Tag string `json:".tag"`
+	}{string(t)})
+}
+
+// UnmarshalJSON accepts the API's {".tag": ...} union form as well as a
+// plain string.
+func (t *SharingPublicPolicyType) UnmarshalJSON(b []byte) error {
+	s, err := unmarshalEnum(b)
+	*t = SharingPublicPolicyType(s)
+	return err
+}
+
+// SharingPolicy says who can open a doc through its link. Fields left
+// empty are unchanged when setting a policy.
+type SharingPolicy struct {
+	PublicSharingPolicy SharingPublicPolicyType `json:"public_sharing_policy,omitempty"`
+	TeamSharingPolicy   SharingPublicPolicyType `json:"team_sharing_policy,omitempty"`
+}
+
+type PaperDocSharingPolicy struct {
+	DocID         DocID         `json:"doc_id"`
+	SharingPolicy SharingPolicy `json:"sharing_policy"`
+}
+
+// GetSharingPolicy returns who can open a doc through its link.
+func (c *APIClient) GetSharingPolicy(ctx context.Context, in *RefPaperDoc) (*SharingPolicy, error) {
+	var out SharingPolicy
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/sharing_policy/get", in, &out)
+}
+
+func (c *APIClient) SetSharingPolicy(ctx context.Context, in *PaperDocSharingPolicy) error {
+	var out struct{}
+	return c.rpc(ctx, "https://api.dropboxapi.com/2/paper/docs/sharing_policy/set", in, &out)
+}
@@ -0,0 +1,53 @@
+package paper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SidecarExt replaces a doc file's extension in the name of its sidecar,
+// e.g. notes.meta.json for notes.md.
+const SidecarExt = ".meta.json"
+
+// Sidecar is the metadata a Syncer with Sidecars set writes next to each
+// doc, so other tools needn't ask the API for it.
+type Sidecar struct {
+	ID      DocID                      `json:"id"`
+	Export  PaperDocExportResult       `json:"export"`
+	Format  ExportFormat               `json:"format"`
+	Folders *FoldersContainingPaperDoc `json:"folders,omitempty"` // when the Syncer records them
+	SHA256  string                     `json:"sha256"`            // of the doc's file
+	Size    int64                      `json:"size"`
+	Created time.Time                  `json:"created"`
+	Updated time.Time                  `json:"updated"`
+	Synced  time.Time                  `json:"synced"`
+}
+
+// sidecarPath returns the sidecar's path for a doc's file.
+func sidecarPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + SidecarExt
+}
+
+// writeSidecar writes the sidecar for doc, whose file holds content.
+func writeSidecar(dir string, doc *Doc, content []byte, e *ManifestEntry, synced time.Time) error {
+	sum := sha256.Sum256(content)
+	blob, err := json.MarshalIndent(&Sidecar{
+		ID:      doc.ID,
+		Export:  PaperDocExportResult{Owner: doc.Owner, Title: doc.Title, Revision: doc.Revision, MIME: doc.MIME},
+		Format:  doc.Format,
+		Folders: doc.Folders,
+		SHA256:  hex.EncodeToString(sum[:]),
+		Size:    int64(len(content)),
+		Created: e.Created,
+		Updated: e.Updated,
+		Synced:  synced,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, sidecarPath(e.Path)), append(blob, '\n'), 0644)
+}
@@ -0,0 +1,376 @@
+package paper
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kyleconroy/paper/feed"
+)
+
+// Site builds a publishable site from a directory written by Syncer.
+type Site struct {
+	Dir         string // sync directory containing the manifest
+	Out         string // output directory, defaults to Dir
+	BaseURL     string // e.g. "https://blog.example.com"
+	Title       string
+	Description string
+	Author      string
+
+	// Robots writes a robots.txt pointing crawlers at the sitemap.
+	Robots bool
+
+	// PageSize is the number of posts per index page. Zero puts every post
+	// on the home page.
+	PageSize int
+
+	// SearchPage adds the theme's search page at /search/. search.json is
+	// written either way.
+	SearchPage bool
+
+	// Categories maps Paper folder names to categories. Posts are filed
+	// under their innermost folder, named after it unless mapped here.
+	Categories map[string]CategoryName
+
+	// Overrides, when set, are merged over what's derived from each doc.
+	Overrides *Overrides
+
+	// Pinned lists docs, by ID or slug, that come before the rest in the
+	// index and feeds regardless of date, in the order listed.
+	Pinned []string
+
+	// Theme is a theme directory layered over the default theme.
+	Theme string
+
+	// Permalink builds the path a doc is published at. The default is
+	// "/<slug>/", matching LinkRewriter.
+	Permalink func(*ManifestEntry) string
+}
+
+// Post is a synced doc as the site sees it.
+type Post struct {
+	*ManifestEntry
+	Format     ExportFormat
+	Content    []byte
+	Summary    string
+	Tags       []string
+	Category   *Category           // nil when uncategorized; its Posts is unset
+	Image      string              // absolute URL of the first image, if any
+	Pinned     bool                // listed in Site.Pinned
+	Taxonomies map[string][]string // from the doc's Override
+	Published  time.Time
+	Permalink  string // path on the site
+	URL        string // absolute URL on the site
+
+	// Canonical is the post's canonical URL when it was published
+	// elsewhere first, from its Override. Otherwise URL is canonical.
+	Canonical string
+}
+
+// Posts loads every doc in the manifest, pinned posts first and then
+// newest first.
+func (s *Site) Posts() ([]*Post, error) {
+	m, err := LoadManifest(filepath.Join(s.Dir, ManifestName))
+	if err != nil {
+		return nil, err
+	}
+	var posts []*Post
+	for _, e := range m.Entries() {
+		if e.Path == "" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(s.Dir, e.Path))
+		if err != nil {
+			return nil, err
+		}
+		format := ExportFormatMarkdown
+		if filepath.Ext(e.Path) == ".html" {
+			format = ExportFormatHTML
+		} else {
+			content = stripFrontMatter(content)
+		}
+		meta, content := ExtractMeta(format, content)
+		published := e.Created
+		if published.IsZero() {
+			published = e.Updated
+		}
+		p := &Post{
+			ManifestEntry: e,
+			Format:        format,
+			Content:       content,
+			Summary:       meta.Summary,
+			Tags:          extractTags(format, content),
+			Image:         s.absURL(meta.Image),
+			Published:     published,
+			Permalink:     s.permalink(e),
+		}
+		p.URL = s.url(p.Permalink)
+		if name, slug := s.category(e); name != "" {
+			p.Category = &Category{Name: name, Slug: slug, Permalink: categoryPath(slug)}
+		}
+		ov, err := s.Overrides.Get(e.ID)
+		if err != nil {
+			return nil, err
+		}
+		if ov != nil {
+			s.applyOverride(p, ov)
+		}
+		posts = append(posts, p)
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].Published.After(posts[j].Published)
+	})
+	return s.Pin(posts), nil
+}
+
+// Pin marks the posts listed in Pinned and moves them to the front, in
+// the order listed, keeping the rest in order.
+func (s *Site) Pin(posts []*Post) []*Post {
+	if len(s.Pinned) == 0 {
+		return posts
+	}
+	rank := map[string]int{}
+	for i, key := range s.Pinned {
+		if _, ok := rank[key]; !ok {
+			rank[key] = i
+		}
+	}
+	position := func(p *Post) int {
+		if r, ok := rank[string(p.ID)]; ok {
+			return r
+		}
+		if r, ok := rank[p.Slug]; ok {
+			return r
+		}
+		return len(s.Pinned)
+	}
+	for _, p := range posts {
+		p.Pinned = position(p) < len(s.Pinned)
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		return position(posts[i]) < position(posts[j])
+	})
+	return posts
+}
+
+func (s *Site) permalink(e *ManifestEntry) string {
+	if s.Permalink != nil {
+		return s.Permalink(e)
+	}
+	return "/" + e.Slug + "/"
+}
+
+// Build writes the site's generated files to Out.
+func (s *Site) Build() error {
+	posts, err := s.Posts()
+	if err != nil {
+		return err
+	}
+	theme, err := s.LoadTheme()
+	if err != nil {
+		return err
+	}
+	if err := s.writeIndex(theme, posts); err != nil {
+		return err
+	}
+	if err := s.writeCategories(theme, posts); err != nil {
+		return err
+	}
+	if err := s.writeSearch(theme, posts); err != nil {
+		return err
+	}
+	if err := s.writePages(theme, posts); err != nil {
+		return err
+	}
+	if err := s.writeRedirects(posts); err != nil {
+		return err
+	}
+	if err := s.writeFeeds(posts); err != nil {
+		return err
+	}
+	return s.writeSitemap(posts)
+}
+
+// LoadTheme loads the site's theme, or the default theme if it has none.
+func (s *Site) LoadTheme() (*Theme, error) {
+	if s.Theme == "" {
+		return DefaultTheme(), nil
+	}
+	return LoadTheme(s.Theme)
+}
+
+// writePages renders every post with the theme, and copies the theme's
+// static files and the synced assets alongside them.
+func (s *Site) writePages(theme *Theme, posts []*Post) error {
+	out := s.out()
+	var b bytes.Buffer
+	for _, p := range posts {
+		b.Reset()
+		data := &PageData{Site: s, Post: p, Body: template.HTML(RenderHTML(p.Format, p.Content)), Posts: posts}
+		if err := theme.Execute(&b, "post", data); err != nil {
+			return fmt.Errorf("render %s: %w", p.ID, err)
+		}
+		if err := writeFileAtomic(pageFile(out, p.Permalink), b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	if err := theme.CopyStatic(out); err != nil {
+		return err
+	}
+	if filepath.Clean(out) == filepath.Clean(s.Dir) {
+		return nil
+	}
+	return copyDir(filepath.Join(s.Dir, "assets"), filepath.Join(out, "assets"))
+}
+
+// pageFile maps a permalink to the file that serves it: "/a/b/" is written
+// to a/b/index.html and "/a/b.html" to a/b.html.
+func pageFile(out, permalink string) string {
+	name := filepath.FromSlash(strings.TrimPrefix(permalink, "/"))
+	if name == "" || strings.HasSuffix(permalink, "/") {
+		name = filepath.Join(name, "index.html")
+	}
+	return filepath.Join(out, name)
+}
+
+// copyDir copies the files under src into dst. A missing src is not an
+// error.
+func copyDir(src, dst string) error {
+	err := filepath.Walk(src, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		blob, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(filepath.Join(dst, rel), blob, 0644)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *Site) out() string {
+	if s.Out == "" {
+		return s.Dir
+	}
+	return s.Out
+}
+
+func (s *Site) url(path string) string {
+	return strings.TrimRight(s.BaseURL, "/") + path
+}
+
+func (s *Site) writeFeeds(posts []*Post) error {
+	f := &feed.Feed{
+		Title:       s.Title,
+		Link:        s.url("/"),
+		Description: s.Description,
+		Author:      s.Author,
+	}
+	for _, p := range posts {
+		if p.Updated.After(f.Updated) {
+			f.Updated = p.Updated
+		}
+		f.Items = append(f.Items, feed.Item{
+			ID:        s.url(p.Permalink),
+			Title:     p.Title,
+			Link:      p.URL,
+			Summary:   p.Summary,
+			Image:     p.Image,
+			Author:    s.Author,
+			Published: p.Published,
+			Updated:   p.Updated,
+		})
+	}
+	f.FeedLink = s.url("/feed.xml")
+	rss, err := f.RSS()
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.out(), "feed.xml"), rss, 0644); err != nil {
+		return err
+	}
+	f.FeedLink = s.url("/atom.xml")
+	atom, err := f.Atom()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.out(), "atom.xml"), atom, 0644)
+}
+
+// summarize returns the first paragraph of a doc as plain text, cut to
+// roughly a tweet's length.
+func summarize(format ExportFormat, content []byte) string {
+	var text string
+	if format == ExportFormatHTML {
+		var walk func(*htmlNode) bool
+		walk = func(n *htmlNode) bool {
+			if n.Tag == "p" {
+				if t := strings.TrimSpace(n.text()); t != "" {
+					text = t
+					return true
+				}
+			}
+			for _, c := range n.Children {
+				if walk(c) {
+					return true
+				}
+			}
+			return false
+		}
+		walk(parseHTML(string(content)))
+	} else {
+		text = firstParagraph(content)
+	}
+	return truncateWords(strings.Join(strings.Fields(text), " "), 280)
+}
+
+// firstParagraph returns the text of the first markdown paragraph, skipping
+// headings, code, images, lists and quotes.
+func firstParagraph(content []byte) string {
+	lines := strings.Split(string(content), "\n")
+	code := codeLines(lines)
+	var para []string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if code[i] || trimmed == "" {
+			if len(para) > 0 {
+				break
+			}
+			continue
+		}
+		if len(para) == 0 && (strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "![") ||
+			strings.HasPrefix(trimmed, ">") || strings.HasPrefix(trimmed, "|") ||
+			strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") ||
+			strings.HasPrefix(trimmed, "<") || trimmed == "---") {
+			continue
+		}
+		para = append(para, plainMarkdown(trimmed))
+	}
+	return strings.Join(para, " ")
+}
+
+func truncateWords(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := strings.LastIndex(s[:max], " ")
+	if cut <= 0 {
+		for cut = max; cut > 0 && !utf8.RuneStart(s[cut]); cut-- {
+		}
+	}
+	return strings.TrimRight(s[:cut], " ,.;:") + "…"
+}
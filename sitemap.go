@@ -0,0 +1,57 @@
+package paper
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"time"
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// writeSitemap writes sitemap.xml listing the home page and every post. A
+// post's lastmod is when the syncer last saw its revision change.
+func (s *Site) writeSitemap(posts []*Post) error {
+	var latest time.Time
+	set := sitemapURLSet{}
+	for _, p := range posts {
+		mod := p.Updated
+		if mod.IsZero() {
+			mod = p.Published
+		}
+		if mod.After(latest) {
+			latest = mod
+		}
+		set.URLs = append(set.URLs, sitemapURL{Loc: p.URL, LastMod: sitemapTime(mod)})
+	}
+	home := sitemapURL{Loc: s.url("/"), LastMod: sitemapTime(latest)}
+	set.URLs = append([]sitemapURL{home}, set.URLs...)
+
+	blob, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+	blob = append([]byte(xml.Header), append(blob, '\n')...)
+	if err := writeFileAtomic(filepath.Join(s.out(), "sitemap.xml"), blob, 0644); err != nil {
+		return err
+	}
+	if !s.Robots {
+		return nil
+	}
+	robots := "User-agent: *\nAllow: /\n\nSitemap: " + s.url("/sitemap.xml") + "\n"
+	return writeFileAtomic(filepath.Join(s.out(), "robots.txt"), []byte(robots), 0644)
+}
+
+func sitemapTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
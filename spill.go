@@ -0,0 +1,133 @@
+package paper
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+)
+
+// DownloadDocTo streams a doc's content to w instead of returning it, so
+// memory use doesn't grow with the doc.
+func (c *APIClient) DownloadDocTo(ctx context.Context, in *PaperDocExport, w io.Writer) (*PaperDocExportResult, int64, error) {
+	var out PaperDocExportResult
+	resp, err := c.open(ctx, downloadURL, in, &out)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(w, resp.Body)
+	return &out, n, err
+}
+
+// Spill is downloaded content that's held in memory when small and
+// written to a temporary file when not. Close removes the file.
+type Spill struct {
+	buf  []byte
+	file *os.File
+	size int64
+}
+
+// Size returns the content's length in bytes.
+func (s *Spill) Size() int64 { return s.size }
+
+// Path returns the temporary file holding the content, or "" when it's in
+// memory.
+func (s *Spill) Path() string {
+	if s.file == nil {
+		return ""
+	}
+	return s.file.Name()
+}
+
+// Reader returns a reader over the content from the start.
+func (s *Spill) Reader() io.Reader {
+	if s.file == nil {
+		return bytes.NewReader(s.buf)
+	}
+	return io.NewSectionReader(s.file, 0, s.size)
+}
+
+// Bytes returns the content, reading it into memory if it was spilled.
+func (s *Spill) Bytes() ([]byte, error) {
+	if s.file == nil {
+		return s.buf, nil
+	}
+	blob := make([]byte, s.size)
+	_, err := s.file.ReadAt(blob, 0)
+	return blob, err
+}
+
+func (s *Spill) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// spiller is implemented by clients that can spill downloads to disk.
+type spiller interface {
+	DownloadDocSpill(ctx context.Context, in *PaperDocExport, threshold int64, dir string) (*PaperDocExportResult, *Spill, error)
+}
+
+// unspill reads back content a Syncer spilled to a temporary file.
+func (d *Doc) unspill() error {
+	if d.spill == nil {
+		return nil
+	}
+	blob, err := d.spill.Bytes()
+	d.spill.Close()
+	d.spill = nil
+	if err != nil {
+		return err
+	}
+	d.Content = blob
+	return nil
+}
+
+// spillWriter buffers writes until they pass threshold, then moves them to
+// a temporary file in dir.
+type spillWriter struct {
+	Spill
+	threshold int64
+	dir       string
+}
+
+func (w *spillWriter) Write(p []byte) (int, error) {
+	if w.file == nil && w.size+int64(len(p)) > w.threshold {
+		f, err := os.CreateTemp(w.dir, "paper-download-*")
+		if err != nil {
+			return 0, err
+		}
+		w.file = f
+		if _, err := f.Write(w.buf); err != nil {
+			return 0, err
+		}
+		w.buf = nil
+	}
+	var n int
+	var err error
+	if w.file != nil {
+		n, err = w.file.Write(p)
+	} else {
+		w.buf = append(w.buf, p...)
+		n = len(p)
+	}
+	w.size += int64(n)
+	return n, err
+}
+
+// DownloadDocSpill downloads a doc, keeping its content in memory up to
+// threshold bytes and spilling anything larger to a temporary file in dir,
+// or the default temporary directory when dir is "". The caller must Close
+// the returned Spill.
+func (c *APIClient) DownloadDocSpill(ctx context.Context, in *PaperDocExport, threshold int64, dir string) (*PaperDocExportResult, *Spill, error) {
+	w := &spillWriter{threshold: threshold, dir: dir}
+	res, _, err := c.DownloadDocTo(ctx, in, w)
+	if err != nil {
+		w.Close()
+		return nil, nil, err
+	}
+	return res, &w.Spill, nil
+}
@@ -0,0 +1,149 @@
+package paper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats describes a corpus for reporting: how many docs and words there
+// are, by folder and owner, and how they've grown month by month.
+type Stats struct {
+	Docs    int                    `json:"docs"`
+	Words   int                    `json:"words"`
+	Folders map[string]*GroupStats `json:"folders"` // by folder path, "/"-separated; "" for none
+	Owners  map[string]*GroupStats `json:"owners"`  // by email; "" when unknown
+	Growth  []*MonthStats          `json:"growth"`  // oldest first, for docs with a creation time
+	PerDoc  []*DocStats            `json:"per_doc"` // by title
+}
+
+type GroupStats struct {
+	Docs  int `json:"docs"`
+	Words int `json:"words"`
+}
+
+// MonthStats counts the docs created in a month, and the running totals
+// at its end.
+type MonthStats struct {
+	Month      string `json:"month"` // e.g. 2024-03
+	Added      int    `json:"added"`
+	AddedWords int    `json:"added_words"`
+	Docs       int    `json:"docs"`
+	Words      int    `json:"words"`
+}
+
+type DocStats struct {
+	ID      DocID     `json:"id"`
+	Title   string    `json:"title"`
+	Owner   string    `json:"owner,omitempty"`
+	Folder  string    `json:"folder,omitempty"`
+	Words   int       `json:"words"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// NewStats totals per-doc stats.
+func NewStats(docs []*DocStats) *Stats {
+	s := &Stats{Folders: map[string]*GroupStats{}, Owners: map[string]*GroupStats{}, PerDoc: docs}
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].Title < docs[j].Title })
+	months := map[string]*MonthStats{}
+	add := func(groups map[string]*GroupStats, key string, words int) {
+		g := groups[key]
+		if g == nil {
+			g = &GroupStats{}
+			groups[key] = g
+		}
+		g.Docs++
+		g.Words += words
+	}
+	for _, d := range docs {
+		s.Docs++
+		s.Words += d.Words
+		add(s.Folders, d.Folder, d.Words)
+		add(s.Owners, d.Owner, d.Words)
+		if d.Created.IsZero() {
+			continue
+		}
+		key := d.Created.UTC().Format("2006-01")
+		m := months[key]
+		if m == nil {
+			m = &MonthStats{Month: key}
+			months[key] = m
+			s.Growth = append(s.Growth, m)
+		}
+		m.Added++
+		m.AddedWords += d.Words
+	}
+	sort.Slice(s.Growth, func(i, j int) bool { return s.Growth[i].Month < s.Growth[j].Month })
+	var total, words int
+	for _, m := range s.Growth {
+		total += m.Added
+		words += m.AddedWords
+		m.Docs, m.Words = total, words
+	}
+	return s
+}
+
+// Stats reports on the synced docs in the catalog.
+func (c *Catalog) Stats(ctx context.Context) (*Stats, error) {
+	entries, err := c.Query(ctx, "status = 'synced'")
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]*DocStats, len(entries))
+	for i, e := range entries {
+		docs[i] = &DocStats{
+			ID:      e.ID,
+			Title:   e.Title,
+			Owner:   e.Owner,
+			Folder:  e.Folder,
+			Words:   e.Words,
+			Created: e.Created,
+			Updated: e.Updated,
+		}
+	}
+	return NewStats(docs), nil
+}
+
+// DirStats reports on the docs synced into dir, counting the words in
+// their files.
+func DirStats(dir string) (*Stats, error) {
+	m, err := LoadManifest(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return nil, err
+	}
+	var docs []*DocStats
+	for _, e := range m.Entries() {
+		if e.Path == "" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, e.Path))
+		if err != nil {
+			return nil, err
+		}
+		format := ExportFormatMarkdown
+		if filepath.Ext(e.Path) == ".html" {
+			format = ExportFormatHTML
+		} else {
+			content = stripFrontMatter(content)
+		}
+		docs = append(docs, &DocStats{
+			ID:      e.ID,
+			Title:   e.Title,
+			Owner:   e.Owner,
+			Folder:  strings.Join(e.Folders, "/"),
+			Words:   countWords(format, content),
+			Created: e.Created,
+			Updated: e.Updated,
+		})
+	}
+	return NewStats(docs), nil
+}
+
+// countWords counts the words in a doc's text.
+func countWords(format ExportFormat, content []byte) int {
+	return len(strings.Fields(plainText(format, content)))
+}
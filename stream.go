@@ -0,0 +1,165 @@
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DocIDStreamer is implemented by clients that can report doc IDs as a
+// listing is decoded, rather than after each page is read in full.
+type DocIDStreamer interface {
+	EachDocID(ctx context.Context, in *ListPaperDocsArgs, fn func(id DocID) error) error
+}
+
+// EachDocID pages through docs/list and docs/list/continue, calling fn with
+// each doc ID as it's decoded from the response. Listing runs ahead of fn,
+// so later pages are fetched while earlier IDs are processed. An error from
+// fn stops the listing and is returned.
+func (c *APIClient) EachDocID(ctx context.Context, in *ListPaperDocsArgs, fn func(id DocID) error) error {
+	return prefetch(ctx, fn, func(ctx context.Context, fn func(DocID) error) error {
+		return c.eachDocID(ctx, in, fn)
+	})
+}
+
+func (c *APIClient) eachDocID(ctx context.Context, in *ListPaperDocsArgs, fn func(id DocID) error) error {
+	var page listPage
+	err := c.call(ctx, "https://api.dropboxapi.com/2/paper/docs/list", in, func(body io.Reader) error {
+		return page.decode(body, fn)
+	})
+	for err == nil && page.HasMore {
+		cursor := page.Cursor.Value
+		page = listPage{}
+		err = c.call(ctx, "https://api.dropboxapi.com/2/paper/docs/list/continue", &ListPaperDocsContinueArgs{Cursor: cursor}, func(body io.Reader) error {
+			return page.decode(body, fn)
+		})
+	}
+	return err
+}
+
+// EachDocID lists every doc visible to c, streaming IDs when c supports it.
+func EachDocID(ctx context.Context, c Client, in *ListPaperDocsArgs, fn func(id DocID) error) error {
+	if s, ok := c.(DocIDStreamer); ok {
+		return s.EachDocID(ctx, in, fn)
+	}
+	return prefetch(ctx, fn, func(ctx context.Context, fn func(DocID) error) error {
+		return eachDocID(ctx, c, in, fn)
+	})
+}
+
+func eachDocID(ctx context.Context, c Client, in *ListPaperDocsArgs, fn func(id DocID) error) error {
+	resp, err := c.ListDocs(ctx, in)
+	for {
+		if err != nil {
+			return err
+		}
+		for _, id := range resp.DocIDs {
+			if err := fn(id); err != nil {
+				return err
+			}
+		}
+		if !resp.HasMore {
+			return nil
+		}
+		resp, err = listDocsContinue(ctx, c, &ListPaperDocsContinueArgs{Cursor: resp.Cursor.Value})
+	}
+}
+
+// prefetchIDs is how far listing may run ahead of the caller: a page at
+// the API's default page size.
+const prefetchIDs = 1000
+
+// prefetch runs list in the background, handing the IDs it produces to fn
+// on the calling goroutine.
+func prefetch(ctx context.Context, fn func(DocID) error, list func(context.Context, func(DocID) error) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ids := make(chan DocID, prefetchIDs)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(ids)
+		errc <- list(ctx, func(id DocID) error {
+			select {
+			case ids <- id:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	for id := range ids {
+		if err := fn(id); err != nil {
+			cancel()
+			for range ids {
+			}
+			<-errc
+			return err
+		}
+	}
+	return <-errc
+}
+
+// listPage is a ListPaperDocsResponse whose doc IDs are handed off as
+// they're decoded instead of being kept.
+type listPage struct {
+	Cursor  Cursor
+	HasMore bool
+}
+
+func (p *listPage) decode(r io.Reader, fn func(DocID) error) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case "doc_ids":
+			if err := expectDelim(dec, '['); err != nil {
+				return err
+			}
+			for dec.More() {
+				var id DocID
+				if err := dec.Decode(&id); err != nil {
+					return err
+				}
+				if err := fn(id); err != nil {
+					return err
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+		case "cursor":
+			if err := dec.Decode(&p.Cursor); err != nil {
+				return err
+			}
+		case "has_more":
+			if err := dec.Decode(&p.HasMore); err != nil {
+				return err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("paper: expected %v in list response, got %v", want, tok)
+	}
+	return nil
+}
@@ -0,0 +1,575 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kyleconroy/paper/changes"
+	"github.com/kyleconroy/paper/fts"
+)
+
+// ManifestName is the file the Syncer stores its manifest in, relative to
+// the sync directory.
+const ManifestName = "manifest.json"
+
+// Syncer mirrors every doc visible to the client into a local directory.
+// Downloaded docs run through Pipeline before being written, and a manifest
+// of what was written is kept alongside them.
+type Syncer struct {
+	Client   Client
+	Dir      string
+	Format   ExportFormat // defaults to markdown
+	Pipeline Pipeline
+
+	// Folders records the Paper folders each doc is in, at the cost of an
+	// extra API call per doc.
+	Folders bool
+
+	// FolderTree, when set, files docs in directories under Dir that
+	// mirror their Paper folders, as FolderNode.Dir names them. It implies
+	// Folders.
+	FolderTree *FolderTree
+
+	// Hooks run in order after a sync that changed something. The first
+	// failing hook stops the rest.
+	Hooks []Hook
+
+	// Index, when set, is kept up to date with the text of every doc and
+	// saved after each sync.
+	Index *fts.Index
+
+	// Catalog, when set, records every doc's metadata after each sync.
+	Catalog *Catalog
+
+	// History, when set, records every new revision as downloaded, before
+	// the pipeline runs.
+	History *History
+
+	// Times, when set, looks up when each doc was created and last modified
+	// in Paper, e.g. with APIClient.FileTimes. It's called for added and
+	// modified docs. Without it, docs are dated by when sync first saw them
+	// and last saw them change.
+	Times func(ctx context.Context, id DocID) (*DocTimes, error)
+
+	// Extensions overrides the package's Extensions, mapping the MIME type
+	// of a transformed doc to the extension it's saved with.
+	Extensions map[string]string
+
+	// Aliases, when set, records every slug each doc has had and is saved
+	// to AliasesName in Dir after each sync.
+	Aliases *Aliases
+
+	// Overrides, when set, can give docs their slugs.
+	Overrides *Overrides
+
+	// Sidecars writes a Sidecar next to each doc.
+	Sidecars bool
+
+	// Trash, when set, keeps a copy of each doc's file before it's removed
+	// because the doc was no longer listed.
+	Trash *Trash
+
+	// ReadingTime, when set, estimates each doc's reading time for its
+	// manifest and catalog entries, and front matter.
+	ReadingTime *ReadingTime
+
+	// FrontMatter starts each markdown doc with YAML front matter giving
+	// its title, ID, dates and reading time. The site generator skips it.
+	FrontMatter bool
+
+	// Metrics, when set, counts what each sync does.
+	Metrics *Metrics
+
+	// SpillThreshold, when positive and Client is an *APIClient, keeps
+	// downloaded docs larger than it in temporary files until they're
+	// written, so a sync doesn't hold every doc in memory at once.
+	SpillThreshold int64
+
+	// Force downloads and rewrites every doc, even those already written at
+	// their current revision, e.g. after the Pipeline changes.
+	Force bool
+
+	// Manifest is loaded from Dir when nil. Transformers that need to
+	// resolve other docs, such as LinkRewriter, should share it.
+	Manifest *Manifest
+}
+
+type SyncResult struct {
+	Added     []DocID
+	Modified  []DocID
+	Removed   []DocID
+	Unchanged []DocID
+
+	// Pending lists the docs an interrupted sync didn't get to.
+	Pending []DocID
+
+	Written int64 // bytes of docs written by this sync
+	Size    int64 // bytes of every synced doc, as in Manifest.Size
+}
+
+// Empty reports whether the sync changed nothing on disk.
+func (r *SyncResult) Empty() bool {
+	return len(r.Added) == 0 && len(r.Modified) == 0 && len(r.Removed) == 0
+}
+
+// Sync brings Dir up to date. If ctx is canceled while docs are being
+// written, it finishes the doc in progress, saves the manifest and returns
+// the partial result, with Pending set, along with ctx's error.
+func (s *Syncer) Sync(ctx context.Context) (*SyncResult, error) {
+	start := time.Now()
+	res, err := s.sync(ctx)
+	s.Metrics.observeSync(res, time.Since(start), err)
+	return res, err
+}
+
+func (s *Syncer) sync(ctx context.Context) (*SyncResult, error) {
+	if s.Manifest == nil {
+		m, err := LoadManifest(filepath.Join(s.Dir, ManifestName))
+		if err != nil {
+			return nil, err
+		}
+		s.Manifest = m
+	}
+	format := s.Format
+	if format == "" {
+		format = ExportFormatMarkdown
+	}
+
+	ids, err := ListAllDocIDs(ctx, s.Client, &ListPaperDocsArgs{})
+	if err != nil {
+		return nil, err
+	}
+	prev := s.Manifest.Snapshot()
+	s.Metrics.setQueue(len(ids))
+
+	// Every doc is downloaded and assigned a slug before any transform
+	// runs, so links between docs resolve no matter the order they're in.
+	// Nothing is written until then, so an interrupted download leaves the
+	// directory as it was.
+	docs, unchanged, err := s.download(ctx, ids, format)
+	if err != nil {
+		s.Manifest.dropUnwritten()
+		return nil, err
+	}
+
+	// Slugs are final now, so transformers can rewrite links to old ones.
+	if s.Aliases != nil {
+		s.Aliases.Update(s.Manifest)
+	}
+
+	cur := changes.Snapshot{}
+	for _, doc := range docs {
+		cur[string(doc.ID)] = doc.Revision
+	}
+	for _, id := range unchanged {
+		cur[string(id)] = s.Manifest.Docs[id].Revision
+	}
+	kinds := map[string]changes.Kind{}
+	for _, c := range changes.Compare(prev, cur) {
+		kinds[c.ID] = c.Kind
+	}
+
+	// Once writing starts, the manifest is saved however the sync ends, so
+	// it always describes what's on disk. A doc being written when ctx is
+	// canceled is finished, and the rest are left for the next sync.
+	work, cancel := draining(ctx, drainGrace)
+	defer cancel()
+	defer func() {
+		// Docs left unwritten may still be spilled.
+		for _, doc := range docs {
+			if doc != nil && doc.spill != nil {
+				doc.spill.Close()
+			}
+		}
+	}()
+	res := SyncResult{Unchanged: unchanged}
+	now := time.Now().UTC()
+	err = s.write(ctx, work, docs, kinds, now, &res)
+	if err == nil {
+		err = s.removeUnlisted(work, ids, now, &res)
+	}
+	if err != nil {
+		s.Manifest.dropUnwritten()
+	}
+	res.Size = s.Manifest.Size()
+	if serr := s.save(); serr != nil {
+		return nil, serr
+	}
+	if err != nil {
+		return &res, err
+	}
+	if res.Empty() {
+		return &res, nil
+	}
+	for _, h := range s.Hooks {
+		if err := h.Run(ctx, &res); err != nil {
+			return &res, fmt.Errorf("hook: %w", err)
+		}
+	}
+	return &res, nil
+}
+
+// download fetches every doc and fills in its manifest entry, except for
+// what's only known once it's written. Docs already written at their
+// current revision aren't transferred again when Client is a
+// ConditionalClient; they're returned as unchanged instead, unless
+// they've since been given a new slug or folder and must be rewritten.
+func (s *Syncer) download(ctx context.Context, ids []DocID, format ExportFormat) (docs []*Doc, unchanged []DocID, err error) {
+	cond, _ := s.Client.(ConditionalClient)
+	if s.Force {
+		cond = nil
+	}
+	docs = make([]*Doc, 0, len(ids))
+	var spills []*Spill
+	defer func() {
+		if err != nil {
+			for _, sp := range spills {
+				sp.Close()
+			}
+		}
+	}()
+	folders := map[DocID]*FoldersContainingPaperDoc{} // of unchanged docs
+	for _, id := range ids {
+		in := &PaperDocExport{DocID: id, Format: format}
+		var doc *Doc
+		e, ok := s.Manifest.Get(id)
+		if ok && cond != nil && e.Path != "" && e.Revision != 0 && s.written(e) {
+			res, blob, err := cond.DownloadDocIfChanged(ctx, in, e.Revision)
+			switch {
+			case err == ErrNotModified:
+				unchanged = append(unchanged, id)
+			case err != nil:
+				return nil, nil, fmt.Errorf("download %s: %w", id, err)
+			default:
+				doc = &Doc{ID: id, Title: res.Title, Owner: res.Owner, Revision: res.Revision, MIME: res.MIME, Format: format, Content: blob}
+			}
+		} else {
+			if doc, err = s.fetch(ctx, in); err != nil {
+				return nil, nil, fmt.Errorf("download %s: %w", id, err)
+			}
+			if doc.spill != nil {
+				spills = append(spills, doc.spill)
+			}
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+			if s.History != nil {
+				if err := s.record(doc); err != nil {
+					return nil, nil, fmt.Errorf("record %s: %w", id, err)
+				}
+			}
+		}
+		if !ok {
+			e = &ManifestEntry{ID: id, Slug: s.Manifest.AssignSlug(id, doc.Title)}
+			s.Manifest.Docs[id] = e
+		}
+		if doc != nil {
+			e.Owner = doc.Owner
+		}
+		ov, err := s.Overrides.Get(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.Manifest.overrideSlug(e, ov); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", id, err)
+		}
+		if s.Folders || s.FolderTree != nil {
+			info, err := s.Client.GetDocFolderInfo(ctx, &RefPaperDoc{DocID: id})
+			if err != nil {
+				return nil, nil, fmt.Errorf("folder info %s: %w", id, err)
+			}
+			if s.FolderTree != nil {
+				s.FolderTree.Add(id, info)
+			}
+			if doc != nil {
+				doc.Folders = info
+			} else {
+				folders[id] = info
+			}
+			e.Folders = nil
+			for _, f := range info.Folders {
+				e.Folders = append(e.Folders, f.Name)
+			}
+		}
+	}
+
+	// Folder names depend on their siblings, so whether a doc moved is only
+	// known once every doc is in the tree.
+	kept := unchanged[:0]
+	for _, id := range unchanged {
+		if !s.moved(s.Manifest.Docs[id]) {
+			kept = append(kept, id)
+			continue
+		}
+		doc, err := s.fetch(ctx, &PaperDocExport{DocID: id, Format: format})
+		if err != nil {
+			return nil, nil, fmt.Errorf("download %s: %w", id, err)
+		}
+		if doc.spill != nil {
+			spills = append(spills, doc.spill)
+		}
+		doc.Folders = folders[id]
+		docs = append(docs, doc)
+	}
+	return docs, kept, nil
+}
+
+// fetch downloads a doc, spilling it to a temporary file when it's larger
+// than SpillThreshold.
+func (s *Syncer) fetch(ctx context.Context, in *PaperDocExport) (*Doc, error) {
+	sp, ok := s.Client.(spiller)
+	if !ok || s.SpillThreshold <= 0 {
+		return fetchDoc(ctx, s.Client, in)
+	}
+	res, spill, err := sp.DownloadDocSpill(ctx, in, s.SpillThreshold, "")
+	if err != nil {
+		return nil, err
+	}
+	doc := &Doc{ID: in.DocID, Title: res.Title, Owner: res.Owner, Revision: res.Revision, MIME: res.MIME, Format: in.Format}
+	if spill.Path() == "" {
+		doc.Content, _ = spill.Bytes()
+	} else {
+		doc.spill = spill
+	}
+	return doc, nil
+}
+
+// record adds a doc to History, reading a spilled doc back only for as
+// long as that takes.
+func (s *Syncer) record(doc *Doc) error {
+	if doc.spill == nil {
+		return s.History.Record(doc)
+	}
+	blob, err := doc.spill.Bytes()
+	if err != nil {
+		return err
+	}
+	d := *doc
+	d.Content = blob
+	return s.History.Record(&d)
+}
+
+// written reports whether a doc's file is still in Dir.
+func (s *Syncer) written(e *ManifestEntry) bool {
+	_, err := os.Stat(filepath.Join(s.Dir, e.Path))
+	return err == nil
+}
+
+// moved reports whether a doc's file is no longer where its slug and
+// folders put it.
+func (s *Syncer) moved(e *ManifestEntry) bool {
+	dir, base := path.Split(e.Path)
+	if base != docFilename(e.Slug, path.Ext(base)) {
+		return true
+	}
+	if s.FolderTree == nil {
+		return dir != ""
+	}
+	want := ""
+	if n, _ := s.FolderTree.Folder(e.ID); n != nil {
+		want = n.Dir() + "/"
+	}
+	return dir != want
+}
+
+// write transforms and writes docs until they're done or ctx is canceled,
+// using work for the calls made for each doc.
+func (s *Syncer) write(ctx, work context.Context, docs []*Doc, kinds map[string]changes.Kind, now time.Time, res *SyncResult) error {
+	for i, doc := range docs {
+		if ctx.Err() != nil {
+			for _, doc := range docs[i:] {
+				res.Pending = append(res.Pending, doc.ID)
+			}
+			return fmt.Errorf("sync interrupted with %d of %d docs left: %w", len(docs)-i, len(docs), ctx.Err())
+		}
+		if err := doc.unspill(); err != nil {
+			return fmt.Errorf("read %s: %w", doc.ID, err)
+		}
+		if err := s.Pipeline.TransformContext(work, doc); err != nil {
+			return fmt.Errorf("transform %s: %w", doc.ID, err)
+		}
+		e := s.Manifest.Docs[doc.ID]
+		ext := docExt(doc, s.Extensions)
+		name := docFilename(e.Slug, ext)
+		if s.FolderTree != nil {
+			if n, _ := s.FolderTree.Folder(doc.ID); n != nil {
+				name = n.Dir() + "/" + name
+			}
+		}
+
+		// Dates and reading time come first, for the front matter.
+		switch kinds[string(doc.ID)] {
+		case changes.Added:
+			e.Created = now
+			e.Updated = now
+		case changes.Modified:
+			e.Updated = now
+		}
+		if s.ReadingTime != nil {
+			e.ReadingTime = s.ReadingTime.Minutes(doc.Format, doc.Content)
+		}
+		if s.Times != nil && kinds[string(doc.ID)] != 0 {
+			t, err := s.Times(work, doc.ID)
+			if err != nil {
+				return fmt.Errorf("times %s: %w", doc.ID, err)
+			}
+			if !t.Created.IsZero() {
+				e.Created = t.Created
+			}
+			if !t.Modified.IsZero() {
+				e.Updated = t.Modified
+			}
+		}
+		content := doc.Content
+		if s.FrontMatter && ext == ".md" {
+			content = frontMatter(doc, e)
+		}
+
+		if err := writeFileAtomic(filepath.Join(s.Dir, name), content, 0644); err != nil {
+			return err
+		}
+		if e.Path != "" && e.Path != name {
+			if err := os.Remove(filepath.Join(s.Dir, e.Path)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if s.Sidecars && sidecarPath(e.Path) != sidecarPath(name) {
+				if err := os.Remove(filepath.Join(s.Dir, sidecarPath(e.Path))); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			removeEmptyDirs(s.Dir, e.Path)
+		}
+		switch kinds[string(doc.ID)] {
+		case changes.Added:
+			res.Added = append(res.Added, doc.ID)
+		case changes.Modified:
+			res.Modified = append(res.Modified, doc.ID)
+		default:
+			res.Unchanged = append(res.Unchanged, doc.ID)
+		}
+		e.Title = doc.Title
+		e.Revision = doc.Revision
+		e.Path = name
+		e.Size = int64(len(content))
+		res.Written += e.Size
+		if s.Sidecars {
+			if err := writeSidecar(s.Dir, doc, content, e, now); err != nil {
+				return fmt.Errorf("sidecar %s: %w", doc.ID, err)
+			}
+		}
+		if s.Catalog != nil {
+			err := s.Catalog.Put(work, &CatalogEntry{
+				ID:       doc.ID,
+				Title:    doc.Title,
+				Owner:    doc.Owner,
+				Revision: doc.Revision,
+				Folder:   strings.Join(e.Folders, "/"),
+				Status:   "synced",
+				Created:  e.Created,
+				Updated:  e.Updated,
+				Synced:   now,
+
+				Words:       countWords(doc.Format, doc.Content),
+				ReadingTime: e.ReadingTime,
+			})
+			if err != nil {
+				return fmt.Errorf("catalog %s: %w", doc.ID, err)
+			}
+		}
+		if s.Index != nil && s.Index.Revision(string(doc.ID)) != doc.Revision {
+			s.Index.Update(fts.Doc{ID: string(doc.ID), Revision: doc.Revision, Title: doc.Title, Body: plainText(doc.Format, doc.Content)})
+		}
+		s.Metrics.setQueue(len(docs) - i - 1)
+		// Written docs are let go, so spilled ones are only in memory one
+		// at a time.
+		docs[i] = nil
+	}
+	return nil
+}
+
+// removeUnlisted deletes the docs in the manifest that weren't listed.
+func (s *Syncer) removeUnlisted(ctx context.Context, ids []DocID, now time.Time, res *SyncResult) error {
+	listed := map[DocID]bool{}
+	for _, id := range ids {
+		listed[id] = true
+	}
+	for id, e := range s.Manifest.Docs {
+		if listed[id] {
+			continue
+		}
+		if e.Path != "" {
+			if s.Trash != nil {
+				if err := s.Trash.prune(s.Dir, e); err != nil {
+					return err
+				}
+			}
+			if err := os.Remove(filepath.Join(s.Dir, e.Path)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if s.Sidecars {
+				if err := os.Remove(filepath.Join(s.Dir, sidecarPath(e.Path))); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			removeEmptyDirs(s.Dir, e.Path)
+		}
+		if s.FolderTree != nil {
+			s.FolderTree.Remove(id)
+		}
+		delete(s.Manifest.Docs, id)
+		if s.Index != nil {
+			s.Index.Remove(string(id))
+		}
+		if s.Catalog != nil {
+			if err := s.Catalog.MarkRemoved(ctx, id, now); err != nil {
+				return err
+			}
+		}
+		res.Removed = append(res.Removed, id)
+	}
+	sort.Slice(res.Removed, func(i, j int) bool { return res.Removed[i] < res.Removed[j] })
+	return nil
+}
+
+// save writes the manifest and the state kept alongside it.
+func (s *Syncer) save() error {
+	if err := s.Manifest.Save(filepath.Join(s.Dir, ManifestName)); err != nil {
+		return err
+	}
+	if s.Aliases != nil {
+		if err := s.Aliases.Save(filepath.Join(s.Dir, AliasesName)); err != nil {
+			return err
+		}
+	}
+	if s.Index != nil {
+		return s.Index.Save()
+	}
+	return nil
+}
+
+// ListAllDocIDs pages through docs/list and docs/list/continue and returns
+// every doc ID.
+func ListAllDocIDs(ctx context.Context, c Client, in *ListPaperDocsArgs) ([]DocID, error) {
+	var ids []DocID
+	err := EachDocID(ctx, c, in, func(id DocID) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func formatExt(format ExportFormat) string {
+	if format == ExportFormatHTML {
+		return ".html"
+	}
+	return ".md"
+}
@@ -0,0 +1,226 @@
+package paper
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TableMode controls how MarkdownConverter renders tables.
+type TableMode int
+
+const (
+	// TablesAuto renders GFM pipe tables, falling back to embedded HTML
+	// for tables with merged cells or block content a pipe table can't
+	// hold.
+	TablesAuto TableMode = iota
+
+	// TablesPipe always renders pipe tables. Merged cells are padded with
+	// empty cells and block content is flattened.
+	TablesPipe
+
+	// TablesHTML always embeds tables as HTML.
+	TablesHTML
+)
+
+type tableCell struct {
+	text    string
+	align   string
+	header  bool
+	colspan int
+	rowspan int
+	block   bool
+}
+
+func (c *MarkdownConverter) table(n *htmlNode) string {
+	if c.Tables == TablesHTML {
+		return tableHTML(n)
+	}
+	rows := c.tableRows(n)
+	if c.Tables == TablesAuto {
+		for _, row := range rows {
+			for _, cell := range row {
+				if cell.colspan > 1 || cell.rowspan > 1 || cell.block {
+					return tableHTML(n)
+				}
+			}
+		}
+	}
+	return pipeTable(rows)
+}
+
+// tableHTML embeds a table as sanitized HTML, dropping Paper's classes and
+// styles but keeping spans and alignment.
+func tableHTML(n *htmlNode) string {
+	return string(NewSanitizer().Sanitize([]byte(renderHTML(n))))
+}
+
+var tableBlocks = map[string]bool{
+	"ul": true, "ol": true, "pre": true, "table": true, "blockquote": true, "dl": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "hr": true,
+}
+
+func (c *MarkdownConverter) tableRows(n *htmlNode) [][]tableCell {
+	var rows [][]tableCell
+	var walk func(*htmlNode, bool)
+	walk = func(n *htmlNode, head bool) {
+		for _, child := range n.Children {
+			switch child.Tag {
+			case "thead":
+				walk(child, true)
+			case "tbody", "tfoot":
+				walk(child, false)
+			case "tr":
+				var row []tableCell
+				for _, cell := range child.Children {
+					if cell.Tag == "td" || cell.Tag == "th" {
+						row = append(row, c.tableCell(cell, head))
+					}
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	walk(n, false)
+	return rows
+}
+
+func (c *MarkdownConverter) tableCell(n *htmlNode, head bool) tableCell {
+	cell := tableCell{
+		align:   cellAlign(n),
+		header:  head || n.Tag == "th",
+		colspan: min(atoiDefault(n.attr("colspan"), 1), maxTableSpan),
+		rowspan: min(atoiDefault(n.attr("rowspan"), 1), maxTableSpan),
+	}
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		for _, child := range n.Children {
+			if tableBlocks[child.Tag] {
+				cell.block = true
+			}
+			walk(child)
+		}
+	}
+	walk(n)
+	lines := c.blocks(n.Children)
+	text := strings.Join(lines, "<br>")
+	text = strings.Replace(text, "\\\n", "<br>", -1)
+	text = strings.Replace(text, "\n", " ", -1)
+	cell.text = strings.Replace(text, "|", `\|`, -1)
+	return cell
+}
+
+func cellAlign(n *htmlNode) string {
+	if align := strings.ToLower(n.attr("align")); align != "" {
+		return align
+	}
+	for _, decl := range strings.Split(n.attr("style"), ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if len(kv) == 2 && strings.TrimSpace(strings.ToLower(kv[0])) == "text-align" {
+			return strings.TrimSpace(strings.ToLower(kv[1]))
+		}
+	}
+	return ""
+}
+
+// maxTableSpan caps colspan and rowspan, which a doc could otherwise set
+// high enough that expanding them takes forever.
+const maxTableSpan = 64
+
+func atoiDefault(s string, def int) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// pipeTable renders rows as a GFM pipe table. The first row is the header;
+// when it isn't marked as one, an empty header row is added so the data
+// rows keep their meaning. Column alignment comes from the first row that
+// sets one.
+func pipeTable(rows [][]tableCell) string {
+	if len(rows) == 0 {
+		return ""
+	}
+	// Expand spans so every row lines up with the columns it covers. Spans
+	// reach no further than the table's rows and its widest row's cells.
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	var grid [][]tableCell
+	pending := map[[2]int]bool{}
+	for r, row := range rows {
+		var out []tableCell
+		col := 0
+		for _, cell := range row {
+			for pending[[2]int{r, col}] {
+				out = append(out, tableCell{})
+				col++
+			}
+			cell.colspan = min(cell.colspan, width)
+			cell.rowspan = min(cell.rowspan, len(rows)-r)
+			out = append(out, cell)
+			for i := 1; i < cell.colspan; i++ {
+				out = append(out, tableCell{})
+			}
+			for dr := 1; dr < cell.rowspan; dr++ {
+				for dc := 0; dc < cell.colspan; dc++ {
+					pending[[2]int{r + dr, col + dc}] = true
+				}
+			}
+			col += cell.colspan
+		}
+		for pending[[2]int{r, col}] {
+			out = append(out, tableCell{})
+			col++
+		}
+		grid = append(grid, out)
+	}
+
+	cols := 0
+	for _, row := range grid {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+	aligns := make([]string, cols)
+	for _, row := range grid {
+		for i, cell := range row {
+			if aligns[i] == "" {
+				aligns[i] = cell.align
+			}
+		}
+	}
+	line := func(cells []tableCell) string {
+		texts := make([]string, cols)
+		for i := range texts {
+			if i < len(cells) {
+				texts[i] = cells[i].text
+			}
+		}
+		return "| " + strings.Join(texts, " | ") + " |"
+	}
+	sep := make([]string, cols)
+	for i, align := range aligns {
+		switch align {
+		case "center":
+			sep[i] = ":---:"
+		case "right":
+			sep[i] = "---:"
+		case "left":
+			sep[i] = ":---"
+		default:
+			sep[i] = "---"
+		}
+	}
+	body := grid
+	header := make([]tableCell, cols)
+	if len(grid[0]) > 0 && grid[0][0].header {
+		header, body = grid[0], grid[1:]
+	}
+	out := []string{line(header), "| " + strings.Join(sep, " | ") + " |"}
+	for _, row := range body {
+		out = append(out, line(row))
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,58 @@
+package paper
+
+import (
+	"regexp"
+	"strings"
+)
+
+// hashtag matches Paper's inline #hashtags. A tag must contain a letter, so
+// "#1" isn't one, and must follow whitespace, so URL fragments aren't.
+var hashtag = regexp.MustCompile(`(?:^|\s)\\?#([\pL\pN_-]*\pL[\pL\pN_-]*)`)
+
+// extractTags returns the hashtags in a doc, lowercased and in order of first
+// appearance. Tags inside code are ignored.
+func extractTags(format ExportFormat, content []byte) []string {
+	var text []string
+	if format == ExportFormatHTML {
+		var walk func(*htmlNode)
+		walk = func(n *htmlNode) {
+			switch n.Tag {
+			case "pre", "code":
+				return
+			case "":
+				text = append(text, n.Text)
+			}
+			for _, c := range n.Children {
+				walk(c)
+			}
+		}
+		walk(parseHTML(string(content)))
+	} else {
+		lines := strings.Split(string(content), "\n")
+		code := codeLines(lines)
+		for i, line := range lines {
+			if code[i] || strings.HasPrefix(line, "    ") {
+				continue
+			}
+			text = append(text, stripCodeSpans(line))
+		}
+	}
+	var tags []string
+	seen := map[string]bool{}
+	for _, t := range text {
+		for _, m := range hashtag.FindAllStringSubmatch(t, -1) {
+			tag := strings.ToLower(m[1])
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+var codeSpanRe = regexp.MustCompile("`+[^`]*`+")
+
+func stripCodeSpans(line string) string {
+	return codeSpanRe.ReplaceAllString(line, " ")
+}
@@ -0,0 +1,141 @@
+package paper
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// taskState reports whether li is a task list item and whether it's done.
+// Paper marks tasks with listtype-task and listtype-taskdone classes on the
+// list; plain HTML uses checkbox inputs.
+func taskState(list, li *htmlNode) (task, done bool) {
+	var walk func(*htmlNode) bool
+	walk = func(n *htmlNode) bool {
+		for _, c := range n.Children {
+			if c.Tag == "ul" || c.Tag == "ol" {
+				continue
+			}
+			if c.Tag == "input" && strings.EqualFold(c.attr("type"), "checkbox") {
+				_, checked := attrPresent(c, "checked")
+				task, done = true, checked
+				return true
+			}
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	if walk(li) {
+		return task, done
+	}
+	for _, n := range []*htmlNode{li, list} {
+		for _, class := range strings.Fields(strings.ToLower(n.attr("class"))) {
+			switch {
+			case strings.Contains(class, "taskdone"), class == "checked", class == "done", class == "completed":
+				return true, true
+			case strings.Contains(class, "task"), strings.Contains(class, "checklist"):
+				task = true
+			}
+		}
+	}
+	return task, false
+}
+
+func attrPresent(n *htmlNode, key string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// taskMeta removes assignee mentions and due dates from a task item and
+// returns them as metadata spans to append to the item.
+func taskMeta(li *htmlNode) []string {
+	var meta []string
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		kept := n.Children[:0]
+		for _, c := range n.Children {
+			if c.Tag == "ul" || c.Tag == "ol" {
+				kept = append(kept, c)
+				continue
+			}
+			switch kind := taskMetaKind(c); kind {
+			case "assignee", "due":
+				text := strings.TrimSpace(c.text())
+				if text == "" {
+					continue
+				}
+				val := text
+				if kind == "assignee" {
+					val = strings.TrimPrefix(text, "@")
+				} else if v := c.attr("data-date"); v != "" {
+					val = v
+				}
+				meta = append(meta, `<span class="task-`+kind+`" data-`+kind+`="`+html.EscapeString(val)+`">`+html.EscapeString(text)+`</span>`)
+				continue
+			}
+			walk(c)
+			kept = append(kept, c)
+		}
+		n.Children = kept
+	}
+	walk(li)
+	return meta
+}
+
+func taskMetaKind(n *htmlNode) string {
+	if n.Tag == "" {
+		return ""
+	}
+	for _, class := range strings.Fields(strings.ToLower(n.attr("class"))) {
+		switch {
+		case strings.Contains(class, "mention"), strings.Contains(class, "assignee"):
+			return "assignee"
+		case strings.Contains(class, "due"), strings.Contains(class, "date"):
+			return "due"
+		}
+	}
+	return ""
+}
+
+var taskLine = regexp.MustCompile(`^(\s*)(?:([-*+]|\d+[.)])\s+)?(?:\[([ xX])\]|(☐|☑|☒|✓|✔))\s*(.*)$`)
+
+// NormalizeTaskLists rewrites the task list variants found in Paper's
+// markdown export, including bare "[ ]" items and ballot box characters,
+// as GFM "- [ ]" and "- [x]" items. Fenced code is left alone.
+var NormalizeTaskLists = TransformFunc(func(doc *Doc) error {
+	if doc.Format == ExportFormatHTML {
+		return nil
+	}
+	lines := strings.Split(string(doc.Content), "\n")
+	code := codeLines(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		m := taskLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		indent, marker, box, glyph, text := m[1], m[2], m[3], m[4], m[5]
+		if box != "" && strings.HasPrefix(text, "(") {
+			// A link whose text happens to be "x".
+			continue
+		}
+		if marker == "" {
+			marker = "-"
+		}
+		check := "[ ]"
+		if box == "x" || box == "X" || (glyph != "" && glyph != "☐") {
+			check = "[x]"
+		}
+		lines[i] = indent + marker + " " + check + " " + text
+	}
+	doc.Content = []byte(strings.Join(lines, "\n"))
+	return nil
+})
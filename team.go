@@ -0,0 +1,174 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// The team endpoints below need a team token with member file access. They
+// let admins read every member's docs for org-wide backups and audits.
+
+type ListMembersArgs struct {
+	Limit          int  `json:"limit,omitempty"` // 1 to 1000, defaults to 1000
+	IncludeRemoved bool `json:"include_removed,omitempty"`
+}
+
+type ListMembersContinueArgs struct {
+	Cursor string `json:"cursor"`
+}
+
+type TeamMemberProfile struct {
+	TeamMemberID string `json:"team_member_id"`
+	AccountID    string `json:"account_id,omitempty"`
+	Email        string `json:"email"`
+	Status       struct {
+		Tag string `json:".tag"` // "active", "invited", "suspended" or "removed"
+	} `json:"status"`
+	Name struct {
+		DisplayName string `json:"display_name"`
+	} `json:"name"`
+}
+
+type TeamMemberInfo struct {
+	Profile TeamMemberProfile `json:"profile"`
+}
+
+type ListMembersResult struct {
+	Members []TeamMemberInfo `json:"members"`
+	Cursor  string           `json:"cursor"`
+	HasMore bool             `json:"has_more"`
+}
+
+func (c *APIClient) ListMembers(ctx context.Context, in *ListMembersArgs) (*ListMembersResult, error) {
+	var out ListMembersResult
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/team/members/list_v2", in, &out)
+}
+
+func (c *APIClient) ListMembersContinue(ctx context.Context, in *ListMembersContinueArgs) (*ListMembersResult, error) {
+	var out ListMembersResult
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/team/members/list/continue_v2", in, &out)
+}
+
+// ListAllMembers pages through the team's members.
+func (c *APIClient) ListAllMembers(ctx context.Context) ([]TeamMemberProfile, error) {
+	res, err := c.ListMembers(ctx, &ListMembersArgs{})
+	if err != nil {
+		return nil, err
+	}
+	var members []TeamMemberProfile
+	for {
+		for _, m := range res.Members {
+			members = append(members, m.Profile)
+		}
+		if !res.HasMore {
+			return members, nil
+		}
+		if res, err = c.ListMembersContinue(ctx, &ListMembersContinueArgs{Cursor: res.Cursor}); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// TeamDoc is a doc found by listing a team's docs.
+type TeamDoc struct {
+	ID      DocID
+	Members []string // IDs of the team members who can see it, in listing order
+}
+
+// ListTeamDocs lists the docs of every active team member, each doc once
+// however many members can see it, in the order they're first found.
+func (c *APIClient) ListTeamDocs(ctx context.Context, in *ListPaperDocsArgs) ([]*TeamDoc, error) {
+	members, err := c.ListAllMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var docs []*TeamDoc
+	seen := map[DocID]*TeamDoc{}
+	for _, m := range members {
+		if m.Status.Tag != "active" {
+			continue
+		}
+		err := EachDocID(WithMember(ctx, m.TeamMemberID), c, in, func(id DocID) error {
+			d, ok := seen[id]
+			if !ok {
+				d = &TeamDoc{ID: id}
+				seen[d.ID] = d
+				docs = append(docs, d)
+			}
+			d.Members = append(d.Members, m.TeamMemberID)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list docs of %s: %w", m.Email, err)
+		}
+	}
+	return docs, nil
+}
+
+// TeamClient is a Client over every doc in a team, so a Syncer or
+// Inventory can cover the whole organization. Listing returns every
+// member's docs in one page; each doc is then read as the first member
+// found to have it.
+type TeamClient struct {
+	Team *APIClient // with a team token
+
+	mu      sync.Mutex
+	readers map[DocID]string
+}
+
+func (t *TeamClient) ListDocs(ctx context.Context, in *ListPaperDocsArgs) (*ListPaperDocsResponse, error) {
+	docs, err := t.Team.ListTeamDocs(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	out := &ListPaperDocsResponse{DocIDs: []DocID{}}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.readers = map[DocID]string{}
+	for _, d := range docs {
+		out.DocIDs = append(out.DocIDs, d.ID)
+		t.readers[d.ID] = d.Members[0]
+	}
+	return out, nil
+}
+
+// ListDocsContinue always fails, since ListDocs returns everything at once.
+func (t *TeamClient) ListDocsContinue(ctx context.Context, in *ListPaperDocsContinueArgs) (*ListPaperDocsResponse, error) {
+	return nil, fmt.Errorf("paper: team listings aren't paged")
+}
+
+// reader returns ctx set to act as a member who can see the doc.
+func (t *TeamClient) reader(ctx context.Context, id DocID) (context.Context, error) {
+	t.mu.Lock()
+	member, ok := t.readers[id]
+	t.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("paper: doc %s isn't in the team listing", id)
+	}
+	return WithMember(ctx, member), nil
+}
+
+func (t *TeamClient) DownloadDoc(ctx context.Context, in *PaperDocExport) (*PaperDocExportResult, []byte, error) {
+	ctx, err := t.reader(ctx, in.DocID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t.Team.DownloadDoc(ctx, in)
+}
+
+func (t *TeamClient) GetDocFolderInfo(ctx context.Context, in *RefPaperDoc) (*FoldersContainingPaperDoc, error) {
+	ctx, err := t.reader(ctx, in.DocID)
+	if err != nil {
+		return nil, err
+	}
+	return t.Team.GetDocFolderInfo(ctx, in)
+}
+
+// Inventory writes a row per doc in the team, as APIClient.Inventory does.
+func (t *TeamClient) Inventory(ctx context.Context, w io.Writer, format InventoryFormat) error {
+	return inventory(ctx, t, w, format)
+}
+
+var _ Client = &TeamClient{}
@@ -0,0 +1,68 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholder matches a template variable, {{name}}, with optional spaces
+// inside the braces.
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][\w.-]*)\s*\}\}`)
+
+// ExpandTemplate replaces each {{name}} in content with vars[name]. It
+// fails, naming them, if any placeholders have no value.
+func ExpandTemplate(content []byte, vars map[string]string) ([]byte, error) {
+	missing := map[string]bool{}
+	out := placeholder.ReplaceAllFunc(content, func(m []byte) []byte {
+		name := string(placeholder.FindSubmatch(m)[1])
+		v, ok := vars[name]
+		if !ok {
+			missing[name] = true
+			return m
+		}
+		return []byte(v)
+	})
+	if len(missing) > 0 {
+		var names []string
+		for name := range missing {
+			names = append(names, "{{"+name+"}}")
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("no value for %s", strings.Join(names, ", "))
+	}
+	return out, nil
+}
+
+// CreateFromTemplate creates a doc from a template doc, such as weekly
+// meeting notes, with its placeholders replaced by vars; see
+// ExpandTemplate. The new doc is titled title, or the template's title
+// with its placeholders replaced if title is empty.
+func (c *APIClient) CreateFromTemplate(ctx context.Context, templateID DocID, vars map[string]string, title string) (*PaperDocCreateUpdateResult, error) {
+	doc, err := fetchDoc(ctx, c, &PaperDocExport{DocID: templateID, Format: ExportFormatMarkdown})
+	if err != nil {
+		return nil, fmt.Errorf("download template %s: %w", templateID, err)
+	}
+	content, err := ExpandTemplate(doc.Content, vars)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", templateID, err)
+	}
+	if title != "" {
+		content = retitle(content, title)
+	}
+	return c.CreateDoc(ctx, &PaperDocCreateArgs{ImportFormat: ImportFormatMarkdown}, content)
+}
+
+// retitle replaces the heading a markdown export starts with, which Paper
+// takes a doc's title from on import, or adds one.
+func retitle(content []byte, title string) []byte {
+	heading := "# " + escapeMarkdown(title)
+	text := strings.TrimLeft(string(content), "\n")
+	first, rest, _ := strings.Cut(text, "\n")
+	if m := atxHeading.FindStringSubmatch(first); m != nil && len(m[1]) == 1 {
+		return []byte(heading + "\n" + rest)
+	}
+	return []byte(heading + "\n\n" + text)
+}
@@ -0,0 +1,160 @@
+package paper
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed themes/default
+var defaultThemeFS embed.FS
+
+// Theme is a set of html/template templates that render a site. A theme
+// directory holds a layout.html base layout, one template per page type
+// (index.html, post.html, ...) that fills in the layout's blocks, shared
+// templates under partials/, and files under static/ that are copied into
+// the built site as-is.
+type Theme struct {
+	pages  map[string]*template.Template
+	static map[string]fs.FS // static file path to the FS that provides it
+}
+
+// PageData is what theme templates are executed with.
+type PageData struct {
+	Site  *Site
+	Post  *Post
+	Body  template.HTML // rendered post content
+	Posts []*Post
+	Page  *Pagination // set on index pages
+
+	Category   *Category   // set on category pages
+	Categories []*Category // set on category pages and the category index
+}
+
+var themeFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// DefaultTheme returns the minimal theme bundled with the package.
+func DefaultTheme() *Theme {
+	t, err := loadTheme(nil)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// LoadTheme loads a theme from dir. Files in dir replace the default theme's
+// files of the same name, so a theme only needs the templates it changes.
+func LoadTheme(dir string) (*Theme, error) {
+	return loadTheme(os.DirFS(dir))
+}
+
+func loadTheme(user fs.FS) (*Theme, error) {
+	base, err := fs.Sub(defaultThemeFS, "themes/default")
+	if err != nil {
+		return nil, err
+	}
+	// Later layers override earlier ones.
+	layers := []fs.FS{base}
+	if user != nil {
+		layers = append(layers, user)
+	}
+	files := map[string]fs.FS{}
+	for _, layer := range layers {
+		err := fs.WalkDir(layer, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			files[name] = layer
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t := &Theme{pages: map[string]*template.Template{}, static: map[string]fs.FS{}}
+	layout := template.New("layout.html").Funcs(themeFuncs)
+	parse := func(tmpl *template.Template, name string) error {
+		blob, err := fs.ReadFile(files[name], name)
+		if err != nil {
+			return err
+		}
+		if tmpl.Name() != name {
+			tmpl = tmpl.New(path.Base(name))
+		}
+		if _, err := tmpl.Parse(string(blob)); err != nil {
+			return fmt.Errorf("theme: %s: %w", name, err)
+		}
+		return nil
+	}
+	if _, ok := files["layout.html"]; !ok {
+		return nil, fmt.Errorf("theme: missing layout.html")
+	}
+	for name := range files {
+		switch {
+		case name == "layout.html", strings.HasPrefix(name, "partials/") && path.Ext(name) == ".html":
+			if err := parse(layout, name); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, "static/"):
+			t.static[strings.TrimPrefix(name, "static/")] = files[name]
+		}
+	}
+	for name := range files {
+		if strings.Contains(name, "/") || path.Ext(name) != ".html" || name == "layout.html" {
+			continue
+		}
+		page, err := layout.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err := parse(page, name); err != nil {
+			return nil, err
+		}
+		t.pages[strings.TrimSuffix(name, ".html")] = page
+	}
+	return t, nil
+}
+
+// Execute renders the named page, such as "index" or "post", inside the
+// theme's layout.
+func (t *Theme) Execute(w io.Writer, page string, data *PageData) error {
+	tmpl, ok := t.pages[page]
+	if !ok {
+		return fmt.Errorf("theme: no %s.html template", page)
+	}
+	return tmpl.ExecuteTemplate(w, "layout.html", data)
+}
+
+// CopyStatic copies the theme's static files into dir/static.
+func (t *Theme) CopyStatic(dir string) error {
+	for name, fsys := range t.static {
+		blob, err := fs.ReadFile(fsys, "static/"+name)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(dir, "static", filepath.FromSlash(name)), blob, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Static returns the contents of a static file, named relative to the
+// theme's static directory.
+func (t *Theme) Static(name string) ([]byte, bool) {
+	fsys, ok := t.static[name]
+	if !ok {
+		return nil, false
+	}
+	blob, err := fs.ReadFile(fsys, "static/"+name)
+	return blob, err == nil
+}
@@ -0,0 +1,223 @@
+package paper
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Heading is an entry in a doc's table of contents.
+type Heading struct {
+	Level    int
+	Text     string
+	ID       string // anchor the entry links to
+	Children []*Heading
+}
+
+// TOC builds a table of contents from a doc's headings. The result is stored
+// in Doc.TOC for templates and, if the doc contains Marker, rendered in its
+// place.
+type TOC struct {
+	MinLevel int    // defaults to 1
+	MaxLevel int    // defaults to 6
+	Marker   string // defaults to "[TOC]"
+
+	// HTML renders the table of contents as a <nav> element even in
+	// markdown docs.
+	HTML bool
+}
+
+func (t *TOC) Transform(doc *Doc) error {
+	min, max := t.MinLevel, t.MaxLevel
+	if min == 0 {
+		min = 1
+	}
+	if max == 0 {
+		max = 6
+	}
+	var flat []*Heading
+	if doc.Format == ExportFormatHTML {
+		doc.Content, flat = htmlHeadings(doc.Content)
+	} else {
+		flat = markdownHeadings(doc.Content)
+	}
+	var kept []*Heading
+	for _, h := range flat {
+		if h.Level >= min && h.Level <= max {
+			kept = append(kept, h)
+		}
+	}
+	doc.TOC = nestHeadings(kept)
+
+	marker := t.Marker
+	if marker == "" {
+		marker = "[TOC]"
+	}
+	var rendered string
+	if t.HTML || doc.Format == ExportFormatHTML {
+		rendered = TOCHTML(doc.TOC)
+	} else {
+		rendered = TOCMarkdown(doc.TOC)
+	}
+	for _, m := range []string{marker, escapeMarkdown(marker)} {
+		doc.Content = bytes.Replace(doc.Content, []byte(m), []byte(rendered), -1)
+	}
+	return nil
+}
+
+// TOCMarkdown renders headings as a nested markdown list of links.
+func TOCMarkdown(headings []*Heading) string {
+	var b strings.Builder
+	var walk func([]*Heading, int)
+	walk = func(hs []*Heading, depth int) {
+		for _, h := range hs {
+			b.WriteString(strings.Repeat("  ", depth) + "- [" + escapeMarkdown(h.Text) + "](#" + h.ID + ")\n")
+			walk(h.Children, depth+1)
+		}
+	}
+	walk(headings, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// TOCHTML renders headings as a <nav> containing nested lists.
+func TOCHTML(headings []*Heading) string {
+	var b strings.Builder
+	var walk func([]*Heading)
+	walk = func(hs []*Heading) {
+		b.WriteString("<ul>")
+		for _, h := range hs {
+			b.WriteString(`<li><a href="#` + html.EscapeString(h.ID) + `">` + html.EscapeString(h.Text) + "</a>")
+			if len(h.Children) > 0 {
+				walk(h.Children)
+			}
+			b.WriteString("</li>")
+		}
+		b.WriteString("</ul>")
+	}
+	b.WriteString(`<nav class="toc">`)
+	if len(headings) > 0 {
+		walk(headings)
+	}
+	b.WriteString("</nav>")
+	return b.String()
+}
+
+// nestHeadings arranges a flat list of headings into a tree by level.
+func nestHeadings(flat []*Heading) []*Heading {
+	var roots []*Heading
+	var stack []*Heading
+	for _, h := range flat {
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, h)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, h)
+		}
+		stack = append(stack, h)
+	}
+	return roots
+}
+
+var (
+	atxHeading  = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+	codeFence   = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	mdLinkText  = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	mdEmphasis  = regexp.MustCompile("[*_`~]+")
+	mdBackslash = regexp.MustCompile(`\\([!-/:-@\[-` + "`" + `{-~])`)
+)
+
+// markdownHeadings returns the ATX headings outside of fenced code blocks.
+func markdownHeadings(src []byte) []*Heading {
+	var out []*Heading
+	ids := map[string]int{}
+	lines := strings.Split(string(src), "\n")
+	code := codeLines(lines)
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		m := atxHeading.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		text := plainMarkdown(m[2])
+		out = append(out, &Heading{Level: len(m[1]), Text: text, ID: uniqueAnchor(ids, text)})
+	}
+	return out
+}
+
+// plainMarkdown strips inline markdown syntax, leaving the visible text.
+func plainMarkdown(s string) string {
+	s = mdLinkText.ReplaceAllString(s, "$1")
+	s = mdEmphasis.ReplaceAllString(s, "")
+	s = mdBackslash.ReplaceAllString(s, "$1")
+	return strings.TrimSpace(s)
+}
+
+// htmlHeadings returns the headings in src, adding an id attribute to every
+// heading that lacks one so the table of contents can link to it.
+func htmlHeadings(src []byte) ([]byte, []*Heading) {
+	var out []*Heading
+	var b strings.Builder
+	ids := map[string]int{}
+	toks := tokenizeHTML(string(src))
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		if tok.Type != htmlStartTag || len(tok.Data) != 2 || tok.Data[0] != 'h' || tok.Data[1] < '1' || tok.Data[1] > '6' {
+			b.WriteString(tok.String())
+			continue
+		}
+		level, _ := strconv.Atoi(tok.Data[1:])
+		var text strings.Builder
+		for j := i + 1; j < len(toks) && !(toks[j].Type == htmlEndTag && toks[j].Data == tok.Data); j++ {
+			if toks[j].Type == htmlText {
+				text.WriteString(html.UnescapeString(toks[j].Data))
+			}
+		}
+		h := &Heading{Level: level, Text: strings.Join(strings.Fields(text.String()), " ")}
+		if id, ok := tok.attr("id"); ok && id != "" {
+			h.ID = id
+			ids[id]++
+		} else {
+			h.ID = uniqueAnchor(ids, h.Text)
+			tok.Attrs = append(tok.Attrs, htmlAttr{Key: "id", Val: h.ID})
+		}
+		out = append(out, h)
+		b.WriteString(tok.String())
+	}
+	return []byte(b.String()), out
+}
+
+// uniqueAnchor derives a GitHub-style anchor from heading text, suffixing
+// repeats with -1, -2 and so on.
+func uniqueAnchor(seen map[string]int, text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || isAlnum(r):
+			b.WriteRune(r)
+		}
+	}
+	id := b.String()
+	if id == "" {
+		id = "section"
+	}
+	n := seen[id]
+	seen[id] = n + 1
+	if n > 0 {
+		return id + "-" + strconv.Itoa(n)
+	}
+	return id
+}
+
+func isAlnum(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,124 @@
+package paper
+
+import (
+	"context"
+	"strings"
+)
+
+// A Transformer rewrites a doc between download and export.
+type Transformer interface {
+	Transform(doc *Doc) error
+}
+
+// TransformFunc adapts an ordinary function to the Transformer interface.
+type TransformFunc func(doc *Doc) error
+
+func (f TransformFunc) Transform(doc *Doc) error {
+	return f(doc)
+}
+
+// ContextTransformer is implemented by transformers that make calls, such
+// as downloads, which should stop when the caller's context is canceled.
+type ContextTransformer interface {
+	TransformContext(ctx context.Context, doc *Doc) error
+}
+
+// Pipeline runs transformers in order, stopping at the first error.
+type Pipeline []Transformer
+
+func (p Pipeline) Transform(doc *Doc) error {
+	return p.TransformContext(context.Background(), doc)
+}
+
+// TransformContext runs the pipeline, passing ctx to the transformers that
+// are ContextTransformers.
+func (p Pipeline) TransformContext(ctx context.Context, doc *Doc) error {
+	for _, t := range p {
+		var err error
+		if ct, ok := t.(ContextTransformer); ok {
+			err = ct.TransformContext(ctx, doc)
+		} else {
+			err = t.Transform(doc)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *AssetRewriter) Transform(doc *Doc) error {
+	return a.Rewrite(context.Background(), doc)
+}
+
+func (a *AssetRewriter) TransformContext(ctx context.Context, doc *Doc) error {
+	return a.Rewrite(ctx, doc)
+}
+
+func (l *LinkRewriter) Transform(doc *Doc) error {
+	return l.Rewrite(doc)
+}
+
+// NormalizeWhitespace converts line endings to \n, strips trailing
+// whitespace, collapses runs of blank lines and ends the doc with a single
+// newline. Code blocks are left as they are, and lines ending in two or more
+// spaces, markdown's hard line breaks, keep two.
+var NormalizeWhitespace = TransformFunc(func(doc *Doc) error {
+	content := strings.ReplaceAll(string(doc.Content), "\r\n", "\n")
+	lines := strings.Split(strings.Trim(content, "\n"), "\n")
+	code := indentedCode(lines, codeLines(lines))
+	out := lines[:0]
+	for i, line := range lines {
+		if !code[i] {
+			trimmed := strings.TrimRight(line, " \t")
+			if trimmed != "" && strings.HasSuffix(line, "  ") {
+				trimmed += "  "
+			}
+			if trimmed == "" && len(out) > 0 && out[len(out)-1] == "" {
+				continue
+			}
+			line = trimmed
+		}
+		out = append(out, line)
+	}
+	for len(out) > 0 && strings.TrimSpace(out[len(out)-1]) == "" {
+		out = out[:len(out)-1]
+	}
+	doc.Content = []byte(strings.Join(out, "\n") + "\n")
+	return nil
+})
+
+// indentedCode adds the lines of indented code blocks to code: lines
+// indented four spaces or a tab that don't continue a paragraph, and the
+// blank lines between them.
+func indentedCode(lines []string, fenced []bool) []bool {
+	code := append([]bool(nil), fenced...)
+	inBlock := false
+	for i, line := range lines {
+		switch {
+		case fenced[i]:
+			inBlock = false
+		case strings.TrimSpace(line) == "":
+			// Blank lines are in a block if one resumes after them.
+			if inBlock {
+				j := i
+				for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+					j++
+				}
+				if j < len(lines) && !fenced[j] && indented(lines[j]) {
+					code[i] = true
+				}
+			}
+		case indented(line) && (inBlock || i == 0 || strings.TrimSpace(lines[i-1]) == ""):
+			code[i] = true
+			inBlock = true
+		default:
+			inBlock = false
+		}
+	}
+	return code
+}
+
+func indented(line string) bool {
+	return strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+}
@@ -0,0 +1,65 @@
+package paper
+
+import (
+	"net/http"
+	"time"
+)
+
+// TransportOptions tunes the connections an APIClient makes. Zero fields
+// keep http.DefaultTransport's settings.
+type TransportOptions struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int // http.DefaultTransport keeps only 2
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout bounds the wait for a response after a request
+	// is sent, which catches stalled connections without limiting how long
+	// a large export takes to download.
+	ResponseHeaderTimeout time.Duration
+}
+
+// BulkTransport is tuned for many concurrent downloads from Dropbox: enough
+// idle connections that parallel workers don't keep redialing and paying
+// for fresh TLS handshakes.
+var BulkTransport = TransportOptions{
+	MaxIdleConns:          256,
+	MaxIdleConnsPerHost:   64,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: time.Minute,
+}
+
+// Transport returns a transport with the options applied over
+// http.DefaultTransport's settings.
+func (o TransportOptions) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if o.MaxIdleConns != 0 {
+		t.MaxIdleConns = o.MaxIdleConns
+	}
+	if o.MaxIdleConnsPerHost != 0 {
+		t.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+	}
+	if o.MaxConnsPerHost != 0 {
+		t.MaxConnsPerHost = o.MaxConnsPerHost
+	}
+	if o.IdleConnTimeout != 0 {
+		t.IdleConnTimeout = o.IdleConnTimeout
+	}
+	if o.TLSHandshakeTimeout != 0 {
+		t.TLSHandshakeTimeout = o.TLSHandshakeTimeout
+	}
+	if o.ResponseHeaderTimeout != 0 {
+		t.ResponseHeaderTimeout = o.ResponseHeaderTimeout
+	}
+	return t
+}
+
+// NewClientWithTransport is NewClient with its connections tuned by opts,
+// e.g. NewClientWithTransport(token, BulkTransport).
+func NewClientWithTransport(token string, opts TransportOptions) *APIClient {
+	c := NewClient(token)
+	c.HTTP.Transport = opts.Transport()
+	return c
+}
@@ -0,0 +1,241 @@
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Trash ops, recording what put a doc in the trash.
+const (
+	TrashArchive = "archive" // archived in Paper
+	TrashDelete  = "delete"  // permanently deleted from Paper
+	TrashPrune   = "prune"   // removed from a sync directory
+)
+
+// Trash keeps a copy of every doc before it's archived, deleted or pruned
+// from a sync directory, so that mistakes by automation can be undone with
+// Restore. Entries are kept for Retention; Purge removes older ones.
+type Trash struct {
+	Dir       string
+	Retention time.Duration // defaults to 30 days
+
+	// Writer recreates archived and deleted docs on Restore, e.g. an
+	// *APIClient. Pruned docs are restored locally and don't need it.
+	Writer DocWriter
+}
+
+// TrashEntry describes a trashed doc. Its content is kept alongside.
+type TrashEntry struct {
+	ID       string       `json:"id"` // of the entry, not the doc
+	DocID    DocID        `json:"doc_id"`
+	Title    string       `json:"title"`
+	Owner    string       `json:"owner,omitempty"`
+	Revision int64        `json:"revision"`
+	Format   ExportFormat `json:"format"`
+	Op       string       `json:"op"`
+	Path     string       `json:"path,omitempty"` // the local file, for pruned docs
+	Trashed  time.Time    `json:"trashed"`
+
+	// RestoredAs is set once a remote doc has been restored, to the ID of
+	// the doc recreated from it.
+	RestoredAs DocID `json:"restored_as,omitempty"`
+}
+
+// ErrNotInTrash is returned by Restore for a doc with no trash entry.
+var ErrNotInTrash = errors.New("paper: doc isn't in the trash")
+
+const (
+	trashMeta    = "entry.json"
+	trashContent = "content"
+)
+
+func (t *Trash) retention() time.Duration {
+	if t.Retention == 0 {
+		return 30 * 24 * time.Hour
+	}
+	return t.Retention
+}
+
+// Stash puts a copy of doc in the trash. path is the local file for
+// TrashPrune and "" otherwise.
+func (t *Trash) Stash(doc *Doc, op, path string) (*TrashEntry, error) {
+	now := time.Now().UTC()
+	e := &TrashEntry{
+		ID:       now.Format("20060102T150405.000000000Z") + "-" + SafeFilename(string(doc.ID)),
+		DocID:    doc.ID,
+		Title:    doc.Title,
+		Owner:    doc.Owner,
+		Revision: doc.Revision,
+		Format:   doc.Format,
+		Op:       op,
+		Path:     path,
+		Trashed:  now,
+	}
+	dir := filepath.Join(t.Dir, e.ID)
+	if err := writeFileAtomic(filepath.Join(dir, trashContent), doc.Content, 0644); err != nil {
+		return nil, fmt.Errorf("trash %s: %w", doc.ID, err)
+	}
+	if err := t.save(e); err != nil {
+		return nil, fmt.Errorf("trash %s: %w", doc.ID, err)
+	}
+	return e, nil
+}
+
+func (t *Trash) save(e *TrashEntry) error {
+	blob, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(t.Dir, e.ID, trashMeta), append(blob, '\n'), 0644)
+}
+
+// List returns the entries in the trash, oldest first.
+func (t *Trash) List() ([]*TrashEntry, error) {
+	dirs, err := os.ReadDir(t.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []*TrashEntry
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		blob, err := os.ReadFile(filepath.Join(t.Dir, d.Name(), trashMeta))
+		if os.IsNotExist(err) {
+			continue // a stash that was interrupted
+		}
+		if err != nil {
+			return nil, err
+		}
+		var e TrashEntry
+		if err := json.Unmarshal(blob, &e); err != nil {
+			return nil, fmt.Errorf("trash entry %s: %w", d.Name(), err)
+		}
+		entries = append(entries, &e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// Content returns a trashed doc's content.
+func (t *Trash) Content(e *TrashEntry) ([]byte, error) {
+	return os.ReadFile(filepath.Join(t.Dir, e.ID, trashContent))
+}
+
+// Restore brings back the most recently trashed copy of a doc. A pruned
+// doc is written back to its file, under dir. An archived or deleted doc
+// is recreated with Writer; Paper gives it a new ID, recorded in the
+// entry's RestoredAs. The entry is removed once restored, except that
+// recreated docs keep theirs, marked, until Purge.
+func (t *Trash) Restore(ctx context.Context, docID DocID, dir string) (*TrashEntry, error) {
+	entries, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+	var e *TrashEntry
+	for _, c := range entries {
+		if c.DocID == docID && c.RestoredAs == "" {
+			e = c
+		}
+	}
+	if e == nil {
+		return nil, ErrNotInTrash
+	}
+	content, err := t.Content(e)
+	if err != nil {
+		return nil, err
+	}
+	if e.Op == TrashPrune {
+		if err := writeFileAtomic(filepath.Join(dir, e.Path), content, 0644); err != nil {
+			return nil, err
+		}
+		return e, os.RemoveAll(filepath.Join(t.Dir, e.ID))
+	}
+	if t.Writer == nil {
+		return nil, fmt.Errorf("paper: restoring %s needs Trash.Writer", docID)
+	}
+	format := ImportFormatMarkdown
+	if e.Format == ExportFormatHTML {
+		format = ImportFormatHTML
+	}
+	res, err := t.Writer.CreateDoc(ctx, &PaperDocCreateArgs{ImportFormat: format}, content)
+	if err != nil {
+		return nil, fmt.Errorf("restore %s: %w", docID, err)
+	}
+	e.RestoredAs = res.DocID
+	return e, t.save(e)
+}
+
+// Purge removes entries older than Retention and returns how many.
+func (t *Trash) Purge() (int, error) {
+	entries, err := t.List()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-t.retention())
+	n := 0
+	for _, e := range entries {
+		if e.Trashed.After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(t.Dir, e.ID)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Archive stashes a doc, then archives it.
+func (t *Trash) Archive(ctx context.Context, c *APIClient, id DocID) (*TrashEntry, error) {
+	return t.remove(ctx, c, id, TrashArchive, c.ArchiveDoc)
+}
+
+// Delete stashes a doc, then permanently deletes it.
+func (t *Trash) Delete(ctx context.Context, c *APIClient, id DocID) (*TrashEntry, error) {
+	return t.remove(ctx, c, id, TrashDelete, c.PermanentlyDeleteDoc)
+}
+
+func (t *Trash) remove(ctx context.Context, c *APIClient, id DocID, op string, do func(context.Context, *RefPaperDoc) error) (*TrashEntry, error) {
+	doc, err := c.FetchDoc(ctx, &PaperDocExport{DocID: id, Format: ExportFormatMarkdown})
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", id, err)
+	}
+	e, err := t.Stash(doc, op, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := do(ctx, &RefPaperDoc{DocID: id}); err != nil {
+		// Nothing was lost, so the copy isn't needed.
+		os.RemoveAll(filepath.Join(t.Dir, e.ID))
+		return nil, fmt.Errorf("%s %s: %w", op, id, err)
+	}
+	return e, nil
+}
+
+// prune stashes a synced doc's file before the Syncer removes it.
+func (t *Trash) prune(dir string, e *ManifestEntry) error {
+	content, err := os.ReadFile(filepath.Join(dir, e.Path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	format := ExportFormatMarkdown
+	if strings.HasSuffix(e.Path, ".html") {
+		format = ExportFormatHTML
+	}
+	_, err = t.Stash(&Doc{ID: e.ID, Title: e.Title, Owner: e.Owner, Revision: e.Revision, Format: format, Content: content}, TrashPrune, e.Path)
+	return err
+}
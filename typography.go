@@ -0,0 +1,101 @@
+package paper
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// Rules, setext underlines and table delimiter rows.
+	ruleLine = regexp.MustCompile(`^[ \t]*[-=*_|: \t]+$`)
+	// Reference link definitions.
+	linkDefinition = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:`)
+	// Inline HTML, autolinks and link destinations with their titles.
+	markdownMarkup = regexp.MustCompile(`<[^<>\n]*>|\]\([^()\n]*\)`)
+)
+
+// SmartTypography curls straight quotes and turns -- into an em dash and
+// ... into an ellipsis in markdown exports. Code, math, HTML tags, link
+// destinations and lines like rules and table delimiters are left alone,
+// as are HTML exports.
+var SmartTypography = TransformFunc(func(doc *Doc) error {
+	if doc.Format == ExportFormatHTML {
+		return nil
+	}
+	lines := strings.Split(string(doc.Content), "\n")
+	literal := literalLines(lines)
+	for i, line := range lines {
+		if !literal[i] && !ruleLine.MatchString(line) && !linkDefinition.MatchString(line) {
+			lines[i] = smarten(line)
+		}
+	}
+	doc.Content = []byte(strings.Join(lines, "\n"))
+	return nil
+})
+
+// smarten applies SmartTypography to a line of markdown.
+func smarten(line string) string {
+	skip := make([]bool, len(line))
+	for _, span := range literalSpans(line) {
+		for i := span[0]; i < span[1]; i++ {
+			skip[i] = true
+		}
+	}
+	for _, m := range markdownMarkup.FindAllStringIndex(line, -1) {
+		for i := m[0]; i < m[1]; i++ {
+			skip[i] = true
+		}
+	}
+	at := func(i int) byte {
+		if i < 0 || i >= len(line) {
+			return 0
+		}
+		return line[i]
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if skip[i] || at(i-1) == '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		switch {
+		case c == '.' && at(i+1) == '.' && at(i+2) == '.' && at(i+3) != '.' && at(i-1) != '.':
+			b.WriteString("…")
+			i += 2
+		case c == '-' && at(i+1) == '-' && at(i+2) != '-' && at(i+2) != '>' && at(i-1) != '-' && at(i-1) != '!':
+			b.WriteString("—")
+			i++
+		case c == '"':
+			if opensQuote(line[:i]) {
+				b.WriteString("“")
+			} else {
+				b.WriteString("”")
+			}
+		case c == '\'':
+			// An apostrophe abbreviating a year, as in '90s.
+			year := isDigit(at(i+1)) && isDigit(at(i+2))
+			if opensQuote(line[:i]) && !year {
+				b.WriteString("‘")
+			} else {
+				b.WriteString("’")
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// opensQuote reports whether a quote following before opens a quotation
+// rather than closing one.
+func opensQuote(before string) bool {
+	r, _ := utf8.DecodeLastRuneInString(before)
+	return r == utf8.RuneError || unicode.IsSpace(r) || strings.ContainsRune("([{<*_~—–-/“‘", r)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
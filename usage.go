@@ -0,0 +1,35 @@
+package paper
+
+import "context"
+
+type SpaceAllocation struct {
+	Tag       string `json:".tag"` // "individual", "team" or "other"
+	Allocated uint64 `json:"allocated"`
+
+	// Team allocations only.
+	Used                         uint64 `json:"used,omitempty"` // by the whole team
+	UserWithinTeamSpaceAllocated uint64 `json:"user_within_team_space_allocated,omitempty"`
+}
+
+type SpaceUsage struct {
+	Used       uint64          `json:"used"` // bytes, by this user
+	Allocation SpaceAllocation `json:"allocation"`
+}
+
+// Free returns the bytes left before the account, or its team, is full, or
+// 0 if the allocation isn't known.
+func (u *SpaceUsage) Free() uint64 {
+	used := u.Used
+	if u.Allocation.Tag == "team" {
+		used = u.Allocation.Used
+	}
+	if u.Allocation.Allocated <= used {
+		return 0
+	}
+	return u.Allocation.Allocated - used
+}
+
+func (c *APIClient) GetSpaceUsage(ctx context.Context) (*SpaceUsage, error) {
+	var out SpaceUsage
+	return &out, c.rpc(ctx, "https://api.dropboxapi.com/2/users/get_space_usage", nil, &out)
+}
@@ -0,0 +1,122 @@
+// Package webhook receives Dropbox webhook notifications, so a publish
+// pipeline can react to Paper edits as they happen instead of polling.
+//
+// See https://www.dropbox.com/developers/reference/webhooks.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// maxBody bounds how much of a notification is read. Real notifications are
+// a few hundred bytes.
+const maxBody = 1 << 20
+
+// Notification lists the accounts with changes. Dropbox doesn't say what
+// changed, only whose files did.
+type Notification struct {
+	Accounts []string // account IDs, from list_folder
+	Users    []int64  // legacy user IDs, from delta
+}
+
+type payload struct {
+	ListFolder struct {
+		Accounts []string `json:"accounts"`
+	} `json:"list_folder"`
+	Delta struct {
+		Users []int64 `json:"users"`
+	} `json:"delta"`
+}
+
+// Handler implements the webhook endpoint: it answers the GET verification
+// challenge and passes POSTed notifications to Notify.
+type Handler struct {
+	// Secret is the app secret notifications are signed with. Notifications
+	// that don't carry a valid signature for it, or any notification when
+	// it's empty, are rejected.
+	Secret string
+
+	// Notify is called on its own goroutine for every notification, since
+	// Dropbox expects a response within seconds.
+	Notify func(*Notification)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		io.WriteString(w, r.URL.Query().Get("challenge"))
+	case "POST":
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := Verify(h.Secret, body, r.Header.Get(SignatureHeader)); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		var p payload
+		if err := json.Unmarshal(body, &p); err != nil {
+			http.Error(w, "invalid notification", http.StatusBadRequest)
+			return
+		}
+		n := &Notification{Accounts: p.ListFolder.Accounts, Users: p.Delta.Users}
+		if h.Notify != nil {
+			go h.Notify(n)
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// SignatureHeader carries the hex-encoded HMAC-SHA256 of a notification's
+// body, keyed with the app secret.
+const SignatureHeader = "X-Dropbox-Signature"
+
+// SignatureError is returned by Verify for a missing or invalid signature.
+type SignatureError struct {
+	Signature string
+}
+
+func (e *SignatureError) Error() string {
+	if e.Signature == "" {
+		return "webhook: missing signature"
+	}
+	return "webhook: invalid signature"
+}
+
+// Verify checks a notification body against its X-Dropbox-Signature header
+// value in constant time.
+func Verify(secret string, body []byte, signature string) error {
+	got, err := hex.DecodeString(signature)
+	if secret == "" || signature == "" || err != nil {
+		return &SignatureError{Signature: signature}
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return &SignatureError{Signature: signature}
+	}
+	return nil
+}
+
+// Chan returns a Notify func that sends notifications on c. Notifications
+// that arrive while c is full are dropped; a pending one already means a
+// re-sync is due.
+func Chan(c chan<- *Notification) func(*Notification) {
+	return func(n *Notification) {
+		select {
+		case c <- n:
+		default:
+		}
+	}
+}
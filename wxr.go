@@ -0,0 +1,251 @@
+package paper
+
+import (
+	"encoding/xml"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WordPress eXtended RSS (WXR) is the format WordPress's importer reads:
+// RSS 2.0 with posts, authors, terms and attachments in the wp namespace.
+
+type wxr struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Excerpt string     `xml:"xmlns:excerpt,attr"`
+	Content string     `xml:"xmlns:content,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	WP      string     `xml:"xmlns:wp,attr"`
+	Channel wxrChannel `xml:"channel"`
+}
+
+type wxrChannel struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	PubDate     string        `xml:"pubDate"`
+	Version     string        `xml:"wp:wxr_version"`
+	BaseSiteURL string        `xml:"wp:base_site_url"`
+	BaseBlogURL string        `xml:"wp:base_blog_url"`
+	Authors     []wxrAuthor   `xml:"wp:author"`
+	Categories  []wxrCategory `xml:"wp:category"`
+	Tags        []wxrTag      `xml:"wp:tag"`
+	Items       []wxrItem     `xml:"item"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+type wxrAuthor struct {
+	ID          int   `xml:"wp:author_id"`
+	Login       cdata `xml:"wp:author_login"`
+	Email       cdata `xml:"wp:author_email"`
+	DisplayName cdata `xml:"wp:author_display_name"`
+}
+
+type wxrCategory struct {
+	ID       int    `xml:"wp:term_id"`
+	Nicename string `xml:"wp:category_nicename"`
+	Parent   string `xml:"wp:category_parent"`
+	Name     cdata  `xml:"wp:cat_name"`
+}
+
+type wxrTag struct {
+	ID   int    `xml:"wp:term_id"`
+	Slug string `xml:"wp:tag_slug"`
+	Name cdata  `xml:"wp:tag_name"`
+}
+
+type wxrTerm struct {
+	Domain   string `xml:"domain,attr"`
+	Nicename string `xml:"nicename,attr"`
+	Name     string `xml:",cdata"`
+}
+
+type wxrItem struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	PubDate       string    `xml:"pubDate"`
+	Creator       cdata     `xml:"dc:creator"`
+	GUID          rssGUID   `xml:"guid"`
+	Description   string    `xml:"description"`
+	Content       cdata     `xml:"content:encoded"`
+	Excerpt       cdata     `xml:"excerpt:encoded"`
+	ID            int       `xml:"wp:post_id"`
+	Date          string    `xml:"wp:post_date"`
+	DateGMT       string    `xml:"wp:post_date_gmt"`
+	Modified      string    `xml:"wp:post_modified,omitempty"`
+	ModifiedGMT   string    `xml:"wp:post_modified_gmt,omitempty"`
+	CommentStatus string    `xml:"wp:comment_status"`
+	PingStatus    string    `xml:"wp:ping_status"`
+	Name          string    `xml:"wp:post_name"`
+	Status        string    `xml:"wp:status"`
+	Parent        int       `xml:"wp:post_parent"`
+	MenuOrder     int       `xml:"wp:menu_order"`
+	Type          string    `xml:"wp:post_type"`
+	Password      string    `xml:"wp:post_password"`
+	Sticky        int       `xml:"wp:is_sticky"`
+	AttachmentURL string    `xml:"wp:attachment_url,omitempty"`
+	Terms         []wxrTerm `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// assetRef matches links to assets stored by AssetRewriter, which are named
+// by content hash wherever they're stored.
+var assetRef = regexp.MustCompile(`[^\s"'<>()\[\]]*/[0-9a-f]{64}(?:\.[A-Za-z0-9]+)?`)
+
+// ownerLogin derives a username from a doc owner's email address.
+func ownerLogin(email string) string {
+	if i := strings.Index(email, "@"); i > 0 {
+		return email[:i]
+	}
+	return email
+}
+
+const wxrDate = "2006-01-02 15:04:05"
+
+// WXR renders posts as a WordPress import file. Folders become categories,
+// hashtags become tags, doc owners become authors and each post's assets
+// become attachments, which the importer downloads when asked to. Assets
+// must be reachable at absolute URLs, so BaseURL should be set.
+func (s *Site) WXR(posts []*Post) ([]byte, error) {
+	ch := wxrChannel{
+		Title:       s.Title,
+		Link:        s.url("/"),
+		Description: s.Description,
+		PubDate:     time.Now().UTC().Format(time.RFC1123Z),
+		Version:     "1.2",
+		BaseSiteURL: s.url("/"),
+		BaseBlogURL: s.url("/"),
+	}
+
+	authors := map[string]string{} // email to login
+	for _, p := range posts {
+		if p.Owner == "" || authors[p.Owner] != "" {
+			continue
+		}
+		login := ownerLogin(p.Owner)
+		authors[p.Owner] = login
+		ch.Authors = append(ch.Authors, wxrAuthor{
+			ID:          len(ch.Authors) + 1,
+			Login:       cdata{login},
+			Email:       cdata{p.Owner},
+			DisplayName: cdata{login},
+		})
+	}
+	if len(ch.Authors) == 0 && s.Author != "" {
+		ch.Authors = append(ch.Authors, wxrAuthor{ID: 1, Login: cdata{s.Author}, DisplayName: cdata{s.Author}})
+	}
+
+	term := 0
+	for _, c := range s.CategoryList(posts) {
+		term++
+		ch.Categories = append(ch.Categories, wxrCategory{ID: term, Nicename: c.Slug, Name: cdata{c.Name}})
+	}
+	tags := map[string]bool{}
+	for _, p := range posts {
+		for _, t := range p.Tags {
+			tags[t] = true
+		}
+	}
+	names := make([]string, 0, len(tags))
+	for t := range tags {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	for _, t := range names {
+		term++
+		ch.Tags = append(ch.Tags, wxrTag{ID: term, Slug: Slugify(t), Name: cdata{t}})
+	}
+
+	id := 0
+	for _, p := range posts {
+		id++
+		// The importer swaps attachment URLs in content for their new
+		// locations, so asset links have to match them exactly.
+		body := assetRef.ReplaceAllStringFunc(string(RenderHTML(p.Format, p.Content)), s.absURL)
+		creator := authors[p.Owner]
+		if creator == "" {
+			creator = s.Author
+		}
+		item := wxrItem{
+			Title:         p.Title,
+			Link:          p.URL,
+			PubDate:       p.Published.Format(time.RFC1123Z),
+			Creator:       cdata{creator},
+			GUID:          rssGUID{Value: string(p.ID)},
+			Content:       cdata{body},
+			Excerpt:       cdata{p.Summary},
+			ID:            id,
+			Date:          p.Published.UTC().Format(wxrDate),
+			DateGMT:       p.Published.UTC().Format(wxrDate),
+			CommentStatus: "closed",
+			PingStatus:    "closed",
+			Name:          p.Slug,
+			Status:        "publish",
+			Type:          "post",
+		}
+		if p.Pinned {
+			item.Sticky = 1
+		}
+		if !p.Updated.IsZero() {
+			item.Modified = p.Updated.UTC().Format(wxrDate)
+			item.ModifiedGMT = item.Modified
+		}
+		if p.Category != nil {
+			item.Terms = append(item.Terms, wxrTerm{Domain: "category", Nicename: p.Category.Slug, Name: p.Category.Name})
+		}
+		for _, t := range p.Tags {
+			item.Terms = append(item.Terms, wxrTerm{Domain: "post_tag", Nicename: Slugify(t), Name: t})
+		}
+		ch.Items = append(ch.Items, item)
+
+		parent := id
+		seen := map[string]bool{}
+		for _, link := range assetRef.FindAllString(body, -1) {
+			if seen[link] {
+				continue
+			}
+			seen[link] = true
+			id++
+			ch.Items = append(ch.Items, wxrItem{
+				Title:         path.Base(link),
+				Link:          link,
+				PubDate:       item.PubDate,
+				Creator:       item.Creator,
+				GUID:          rssGUID{Value: link},
+				ID:            id,
+				Date:          item.Date,
+				DateGMT:       item.DateGMT,
+				CommentStatus: "closed",
+				PingStatus:    "closed",
+				Name:          strings.TrimSuffix(path.Base(link), path.Ext(link)),
+				Status:        "inherit",
+				Parent:        parent,
+				Type:          "attachment",
+				AttachmentURL: link,
+			})
+		}
+	}
+
+	blob, err := xml.MarshalIndent(wxr{
+		Version: "2.0",
+		Excerpt: "http://wordpress.org/export/1.2/excerpt/",
+		Content: "http://purl.org/rss/1.0/modules/content/",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		WP:      "http://wordpress.org/export/1.2/",
+		Channel: ch,
+	}, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(blob, '\n')...), nil
+}